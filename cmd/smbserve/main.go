@@ -13,8 +13,8 @@ import (
 	"github.com/gentlemanautomaton/smb/smbmultiproto"
 	"github.com/gentlemanautomaton/smb/smbpacket"
 	"github.com/gentlemanautomaton/smb/smbproto"
-	"github.com/gentlemanautomaton/smb/smbsecmode"
 	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
 	"github.com/gentlemanautomaton/smb/smbtcp"
 )
 
@@ -41,6 +41,7 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
+		defer conn.Disconnect()
 		for {
 			if shutdown.Signaled() {
 				return
@@ -97,9 +98,10 @@ func main() {
 
 					conn.Expand(1)
 					conn.Marshal(0, 1, smbproto.NegotiateResponse{
-						SecMode:         smbsecmode.SigningEnabled,
+						SecMode:         conn.SecurityMode(),
 						Dialect:         conn.Dialect.Revision(),
 						Server:          conn.Server,
+						Caps:            conn.Capabilities(),
 						MaxTransactSize: conn.MaxTransactSize,
 						MaxReadSize:     conn.MaxReadSize,
 						MaxWriteSize:    conn.MaxWriteSize,
@@ -111,17 +113,21 @@ func main() {
 				fmt.Printf("Conn %s: Received SMB2 %s (%d bytes)\n", remote, hdr.Command(), msg.Length())
 
 				if !conn.Dialect.Ready() {
-					if hdr.Command() == smbcommand.Negotiate {
-						// TODO: Send SMB2 Negotiate Response
-						//return true
+					if hdr.Command() != smbcommand.Negotiate {
 						return false
 					}
+					if conn.Dialect == smbdialect.Uninitialized {
+						// The first request of the connection is always
+						// granted a credit
+						conn.Expand(1)
+					}
+				}
 
+				if err := conn.Process(msg, smbserver.CommandHandlerFunc(handle)); err != nil {
+					fmt.Printf("Conn %s: %v\n", remote, err)
 					return false
 				}
-
-				//handle(request, hdr)
-				return false
+				return true
 			}()
 			if !ok {
 				fmt.Printf("Conn %s: Server initiated connection close.\n", remote)
@@ -131,14 +137,40 @@ func main() {
 	}))
 }
 
-func handle(request smbpacket.Request, hdr smbpacket.RequestHeader) {
+func handle(conn *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+	hdr := r.Header()
 	switch hdr.Command() {
+	case smbcommand.Negotiate:
+		return conn.Negotiate(r)
+	case smbcommand.SessionSetup:
+		return conn.SessionSetup(r)
+	case smbcommand.TreeConnect:
+		return conn.TreeConnect(r)
+	case smbcommand.TreeDisconnect:
+		return conn.TreeDisconnect(r)
 	case smbcommand.Create:
-		// TODO: Handle create
-		return
-	case smbcommand.Cancel:
-		// TODO: Handle cancel
-		return
+		return conn.CreateInTree(r)
+	case smbcommand.Close:
+		return conn.CloseFile(r)
+	case smbcommand.Read:
+		return conn.Read(r)
+	case smbcommand.Write:
+		return conn.Write(r)
+	case smbcommand.Lock:
+		return conn.Lock(r)
+	case smbcommand.OplockBreak:
+		return conn.OplockBreak(r)
+	case smbcommand.ChangeNotify:
+		return conn.ChangeNotify(r)
+	case smbcommand.QueryDirectory:
+		return conn.QueryDirectory(r)
+	case smbcommand.QueryInfo:
+		return conn.QueryInfo(r)
+	case smbcommand.SetInfo:
+		return conn.SetInfo(r)
+	case smbcommand.IOCTL:
+		return conn.Ioctl(r)
 	}
 	// TODO: Handle invalid or unexpected request
+	return smbproto.ErrorResponse{Cmd: hdr.Command(), Code: smbstatus.NotSupported}
 }
@@ -60,6 +60,6 @@ func (p *Pool) Get(length int) smb.Message {
 		msg.clear()
 		return msg
 	default:
-		return make(msgDynamic, 0, length)
+		return make(msgDynamic, length)
 	}
 }
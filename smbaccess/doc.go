@@ -0,0 +1,3 @@
+// Package smbaccess defines the access masks used by the SMB protocol to
+// describe the rights requested for or granted to an open.
+package smbaccess
@@ -0,0 +1,53 @@
+package smbaccess
+
+// Format describes a set of names for SMB access rights.
+type Format map[Mask]string
+
+// ProtoNames maps individual access rights to their names as defined by the
+// SMB protocol specification.
+var ProtoNames = Format{
+	ReadData:        "FILE_READ_DATA",
+	WriteData:       "FILE_WRITE_DATA",
+	AppendData:      "FILE_APPEND_DATA",
+	ReadEA:          "FILE_READ_EA",
+	WriteEA:         "FILE_WRITE_EA",
+	Execute:         "FILE_EXECUTE",
+	DeleteChild:     "FILE_DELETE_CHILD",
+	ReadAttributes:  "FILE_READ_ATTRIBUTES",
+	WriteAttributes: "FILE_WRITE_ATTRIBUTES",
+	Delete:          "DELETE",
+	ReadControl:     "READ_CONTROL",
+	WriteDAC:        "WRITE_DAC",
+	WriteOwner:      "WRITE_OWNER",
+	Synchronize:     "SYNCHRONIZE",
+	SystemSecurity:  "ACCESS_SYSTEM_SECURITY",
+	MaximumAllowed:  "MAXIMUM_ALLOWED",
+	GenericAll:      "GENERIC_ALL",
+	GenericExecute:  "GENERIC_EXECUTE",
+	GenericWrite:    "GENERIC_WRITE",
+	GenericRead:     "GENERIC_READ",
+}
+
+// GoNames maps individual access rights to their Go-style names.
+var GoNames = Format{
+	ReadData:        "ReadData",
+	WriteData:       "WriteData",
+	AppendData:      "AppendData",
+	ReadEA:          "ReadEA",
+	WriteEA:         "WriteEA",
+	Execute:         "Execute",
+	DeleteChild:     "DeleteChild",
+	ReadAttributes:  "ReadAttributes",
+	WriteAttributes: "WriteAttributes",
+	Delete:          "Delete",
+	ReadControl:     "ReadControl",
+	WriteDAC:        "WriteDAC",
+	WriteOwner:      "WriteOwner",
+	Synchronize:     "Synchronize",
+	SystemSecurity:  "SystemSecurity",
+	MaximumAllowed:  "MaximumAllowed",
+	GenericAll:      "GenericAll",
+	GenericExecute:  "GenericExecute",
+	GenericWrite:    "GenericWrite",
+	GenericRead:     "GenericRead",
+}
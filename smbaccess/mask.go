@@ -0,0 +1,103 @@
+package smbaccess
+
+import "strings"
+
+// Mask declares a set of access rights for a file, directory or pipe.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.13.1
+type Mask uint32
+
+// File and pipe access rights.
+const (
+	ReadData        = 0x00000001 // FILE_READ_DATA
+	WriteData       = 0x00000002 // FILE_WRITE_DATA
+	AppendData      = 0x00000004 // FILE_APPEND_DATA
+	ReadEA          = 0x00000008 // FILE_READ_EA
+	WriteEA         = 0x00000010 // FILE_WRITE_EA
+	Execute         = 0x00000020 // FILE_EXECUTE
+	DeleteChild     = 0x00000040 // FILE_DELETE_CHILD
+	ReadAttributes  = 0x00000080 // FILE_READ_ATTRIBUTES
+	WriteAttributes = 0x00000100 // FILE_WRITE_ATTRIBUTES
+	Delete          = 0x00010000 // DELETE
+	ReadControl     = 0x00020000 // READ_CONTROL
+	WriteDAC        = 0x00040000 // WRITE_DAC
+	WriteOwner      = 0x00080000 // WRITE_OWNER
+	Synchronize     = 0x00100000 // SYNCHRONIZE
+	SystemSecurity  = 0x01000000 // ACCESS_SYSTEM_SECURITY
+	MaximumAllowed  = 0x02000000 // MAXIMUM_ALLOWED
+	GenericAll      = 0x10000000 // GENERIC_ALL
+	GenericExecute  = 0x20000000 // GENERIC_EXECUTE
+	GenericWrite    = 0x40000000 // GENERIC_WRITE
+	GenericRead     = 0x80000000 // GENERIC_READ
+)
+
+// Directory access rights. These share values with the file access rights.
+const (
+	ListDirectory = ReadData   // FILE_LIST_DIRECTORY
+	AddFile       = WriteData  // FILE_ADD_FILE
+	AddSubdir     = AppendData // FILE_ADD_SUBDIRECTORY
+	Traverse      = Execute    // FILE_TRAVERSE
+)
+
+// Generic mappings for files, which determine the specific rights that are
+// implied by each of the generic rights.
+const (
+	FileGenericRead    = ReadData | ReadAttributes | ReadEA | ReadControl | Synchronize
+	FileGenericWrite   = WriteData | AppendData | WriteAttributes | WriteEA | ReadControl | Synchronize
+	FileGenericExecute = Execute | ReadAttributes | ReadControl | Synchronize
+	FileAllAccess      = 0x001F01FF
+)
+
+// Match reports whether m contains all of the rights specified by c.
+func (m Mask) Match(c Mask) bool {
+	return m&c == c
+}
+
+// Any reports whether m contains any of the rights specified by c.
+func (m Mask) Any(c Mask) bool {
+	return m&c != 0
+}
+
+// MapGeneric returns m with its generic rights replaced by the specific
+// file rights that they imply.
+func (m Mask) MapGeneric() Mask {
+	mapped := m &^ (GenericAll | GenericExecute | GenericWrite | GenericRead)
+	if m.Match(GenericRead) {
+		mapped |= FileGenericRead
+	}
+	if m.Match(GenericWrite) {
+		mapped |= FileGenericWrite
+	}
+	if m.Match(GenericExecute) {
+		mapped |= FileGenericExecute
+	}
+	if m.Match(GenericAll) {
+		mapped |= FileAllAccess
+	}
+	return mapped
+}
+
+// String returns a string representation of the access mask.
+func (m Mask) String() string {
+	return m.Format("|", GoNames)
+}
+
+// Format returns a string representation of the access mask using the given
+// separator and format.
+func (m Mask) Format(sep string, format Format) string {
+	if s, ok := format[m]; ok {
+		return s
+	}
+
+	var matched []string
+	for i := 0; i < 32; i++ {
+		flag := Mask(1 << uint32(i))
+		if m.Match(flag) {
+			if s, ok := format[flag]; ok {
+				matched = append(matched, s)
+			}
+		}
+	}
+
+	return strings.Join(matched, sep)
+}
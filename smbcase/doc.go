@@ -0,0 +1,7 @@
+// Package smbcase provides case-insensitive, case-preserving name
+// resolution for shares that are backed by case-sensitive file systems.
+//
+// Names are compared the way Windows compares them: each UTF-16 code unit
+// is converted to upper case with the upcase table, and the results are
+// compared exactly. No other normalization is performed.
+package smbcase
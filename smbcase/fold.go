@@ -0,0 +1,36 @@
+package smbcase
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Upcase returns the upper case form of r according to the Windows upcase
+// table.
+//
+// The table maps each character of the Basic Multilingual Plane to its
+// simple uppercase mapping in the Unicode character database, or to
+// itself if its mapping is outside the plane. Characters outside the
+// plane are encoded as surrogate pairs, which the table leaves unchanged.
+func Upcase(r rune) rune {
+	if r > 0xFFFF {
+		return r
+	}
+	if u := unicode.ToUpper(r); u <= 0xFFFF {
+		return u
+	}
+	return r
+}
+
+// Fold returns the upper case form of s according to the Windows upcase
+// table. Names that are equal when compared case-insensitively have the
+// same folded form.
+func Fold(s string) string {
+	return strings.Map(Upcase, s)
+}
+
+// Equal returns true if a and b are equal when compared
+// case-insensitively.
+func Equal(a, b string) bool {
+	return Fold(a) == Fold(b)
+}
@@ -0,0 +1,47 @@
+package smbcase_test
+
+import (
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbcase"
+)
+
+func TestUpcase(t *testing.T) {
+	tests := []struct {
+		r, want rune
+	}{
+		{'a', 'A'},
+		{'Z', 'Z'},
+		{'0', '0'},
+		{'é', 'É'},
+		{'ÿ', 'Ÿ'},
+		{'ß', 'ß'},         // The uppercase form is two characters
+		{'ω', 'Ω'},         // Greek
+		{'ж', 'Ж'},         // Cyrillic
+		{0x10428, 0x10428}, // Deseret is outside the Basic Multilingual Plane
+		{0x1F600, 0x1F600}, // So are emoji
+	}
+	for _, test := range tests {
+		if got := smbcase.Upcase(test.r); got != test.want {
+			t.Errorf("Upcase(%U) = %U (want %U)", test.r, got, test.want)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Report.DOCX", "report.docx", true},
+		{"ÉTÉ.txt", "été.TXT", true},
+		{"straße", "STRASSE", false},
+		{"a.txt", "b.txt", false},
+		{"\U00010400", "\U00010428", false},
+	}
+	for _, test := range tests {
+		if got := smbcase.Equal(test.a, test.b); got != test.want {
+			t.Errorf("Equal(%q, %q) = %t (want %t)", test.a, test.b, got, test.want)
+		}
+	}
+}
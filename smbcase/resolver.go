@@ -0,0 +1,182 @@
+package smbcase
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// DefaultCacheSize is the number of directories whose contents are cached
+// by resolvers created with a cache size of zero.
+const DefaultCacheSize = 256
+
+// Resolve returns the name of the file within fsys that name refers to when
+// names are compared case-insensitively. The name is a slash-separated path
+// relative to the root of fsys.
+//
+// Each element of name that exists is replaced by the name of the entry it
+// matches in its directory, preferring an entry with exactly the same name.
+// Elements that don't exist are preserved, along with the elements that
+// follow them, so that the result can be used to create a file.
+// Directories that can't be read are treated as if they were empty, which
+// leaves the operation that uses the result to report the problem.
+func Resolve(fsys smbfs.FileSystem, name string) string {
+	return resolve(name, func(dir string) (*directory, error) {
+		return readDirectory(fsys, dir)
+	})
+}
+
+// Resolver resolves names within a file system in the same manner as
+// Resolve, but caches the contents of the directories it reads.
+//
+// A cached directory is read again when its modification time changes.
+// Since modification times have limited precision, changes made through
+// the resolver's callers should also be reported with Invalidate.
+//
+// A Resolver must be created with NewResolver. It is safe for concurrent
+// use.
+type Resolver struct {
+	fsys smbfs.FileSystem
+	size int
+
+	mutex sync.Mutex
+	dirs  map[string]*directory // Cached directories keyed by name, protected by mutex
+}
+
+// NewResolver returns a resolver for fsys that caches the contents of up
+// to size directories. If size is zero, DefaultCacheSize is used.
+func NewResolver(fsys smbfs.FileSystem, size int) *Resolver {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &Resolver{
+		fsys: fsys,
+		size: size,
+		dirs: make(map[string]*directory),
+	}
+}
+
+// Resolve returns the name of the file within the file system of r that
+// name refers to when names are compared case-insensitively.
+func (r *Resolver) Resolve(name string) string {
+	return resolve(name, r.lookup)
+}
+
+// Invalidate discards the cached contents of the named directory. It
+// should be called when an entry is added to, removed from or renamed
+// within the directory.
+func (r *Resolver) Invalidate(dir string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.dirs, path.Clean(dir))
+}
+
+// lookup returns the contents of the named directory, reading it if it
+// isn't cached or has been modified since it was cached.
+func (r *Resolver) lookup(name string) (*directory, error) {
+	info, err := r.fsys.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	dir := r.dirs[name]
+	r.mutex.Unlock()
+	if dir != nil && dir.modTime.Equal(info.ModTime()) {
+		return dir, nil
+	}
+
+	dir, err = readDirectory(r.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	dir.modTime = info.ModTime()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.dirs[name]; !ok && len(r.dirs) >= r.size {
+		// Evict an arbitrary directory to make room
+		for evicted := range r.dirs {
+			delete(r.dirs, evicted)
+			break
+		}
+	}
+	r.dirs[name] = dir
+	return dir, nil
+}
+
+// directory holds the names of the entries of a directory.
+type directory struct {
+	modTime time.Time
+	names   map[string]struct{} // The names of the entries
+	folded  map[string]string   // The names of the entries keyed by folded name
+}
+
+// readDirectory reads the names of the entries of the named directory
+// within fsys.
+func readDirectory(fsys smbfs.FileSystem, name string) (*directory, error) {
+	f, err := fsys.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	infos, err := f.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := &directory{
+		names:  make(map[string]struct{}, len(infos)),
+		folded: make(map[string]string, len(infos)),
+	}
+	for _, info := range infos {
+		entry := info.Name()
+		dir.names[entry] = struct{}{}
+
+		// When several entries fold to the same name, the one that sorts
+		// first is chosen so that the choice is stable
+		key := Fold(entry)
+		if existing, ok := dir.folded[key]; !ok || entry < existing {
+			dir.folded[key] = entry
+		}
+	}
+	return dir, nil
+}
+
+// match returns the name of the entry of d that matches element.
+func (d *directory) match(element string) (string, bool) {
+	if _, ok := d.names[element]; ok {
+		return element, true
+	}
+	entry, ok := d.folded[Fold(element)]
+	return entry, ok
+}
+
+// resolve resolves name by looking up each of its elements in the
+// directories returned by lookup.
+func resolve(name string, lookup func(dir string) (*directory, error)) string {
+	if name == "." {
+		return name
+	}
+	elements := strings.Split(name, "/")
+	for i, element := range elements {
+		parent := "."
+		if i > 0 {
+			parent = path.Join(elements[:i]...)
+		}
+		dir, err := lookup(parent)
+		if err != nil {
+			break
+		}
+		entry, ok := dir.match(element)
+		if !ok {
+			break
+		}
+		elements[i] = entry
+	}
+	return strings.Join(elements, "/")
+}
@@ -0,0 +1,2 @@
+// Package smbclose provides types for SMB close requests and responses.
+package smbclose
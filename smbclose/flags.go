@@ -0,0 +1,16 @@
+package smbclose
+
+// Flags declares a set of close flags.
+type Flags uint16
+
+// Close flags.
+const (
+	// PostQueryAttrib indicates that the server should return the
+	// attributes of the file after it has been closed.
+	PostQueryAttrib = 0x0001 // SMB2_CLOSE_FLAG_POSTQUERY_ATTRIB
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
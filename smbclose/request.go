@@ -0,0 +1,59 @@
+package smbclose
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for an SMB close request.
+const RequestSize = 24
+
+// Request interprets a slice of bytes as an SMB close request packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.15
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 24
+	if r.Size() != 24 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r Request) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r Request) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// Flags returns the flags of the request.
+func (r Request) Flags() Flags {
+	return Flags(smbtype.Uint16(r[2:4]))
+}
+
+// SetFlags sets the flags of the request.
+func (r Request) SetFlags(flags Flags) {
+	smbtype.PutUint16(r[2:4], uint16(flags))
+}
+
+// FileID returns the file ID of the open to be closed.
+func (r Request) FileID() (id smbfile.ID) {
+	id.Read(r[8:24])
+	return
+}
+
+// SetFileID sets the file ID of the open to be closed.
+func (r Request) SetFileID(id smbfile.ID) {
+	id.Write(r[8:24])
+}
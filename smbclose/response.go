@@ -0,0 +1,120 @@
+package smbclose
+
+import (
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ResponseSize is the number of bytes required for an SMB close response.
+const ResponseSize = 60
+
+// Response interprets a slice of bytes as an SMB close response packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.16
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	if len(r) < ResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 60
+	if r.Size() != 60 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r Response) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r Response) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// Flags returns the flags of the response.
+func (r Response) Flags() Flags {
+	return Flags(smbtype.Uint16(r[2:4]))
+}
+
+// SetFlags sets the flags of the response.
+func (r Response) SetFlags(flags Flags) {
+	smbtype.PutUint16(r[2:4], uint16(flags))
+}
+
+// CreationTime returns the time the file was created.
+func (r Response) CreationTime() time.Time {
+	return smbtype.Time(r[8:16])
+}
+
+// SetCreationTime sets the time the file was created.
+func (r Response) SetCreationTime(t time.Time) {
+	smbtype.PutTime(r[8:16], t)
+}
+
+// LastAccessTime returns the time the file was last accessed.
+func (r Response) LastAccessTime() time.Time {
+	return smbtype.Time(r[16:24])
+}
+
+// SetLastAccessTime sets the time the file was last accessed.
+func (r Response) SetLastAccessTime(t time.Time) {
+	smbtype.PutTime(r[16:24], t)
+}
+
+// LastWriteTime returns the time the file was last written.
+func (r Response) LastWriteTime() time.Time {
+	return smbtype.Time(r[24:32])
+}
+
+// SetLastWriteTime sets the time the file was last written.
+func (r Response) SetLastWriteTime(t time.Time) {
+	smbtype.PutTime(r[24:32], t)
+}
+
+// ChangeTime returns the time the file was last changed.
+func (r Response) ChangeTime() time.Time {
+	return smbtype.Time(r[32:40])
+}
+
+// SetChangeTime sets the time the file was last changed.
+func (r Response) SetChangeTime(t time.Time) {
+	smbtype.PutTime(r[32:40], t)
+}
+
+// AllocationSize returns the number of bytes allocated to the file.
+func (r Response) AllocationSize() uint64 {
+	return smbtype.Uint64(r[40:48])
+}
+
+// SetAllocationSize sets the number of bytes allocated to the file.
+func (r Response) SetAllocationSize(size uint64) {
+	smbtype.PutUint64(r[40:48], size)
+}
+
+// EndOfFile returns the size of the file in bytes.
+func (r Response) EndOfFile() uint64 {
+	return smbtype.Uint64(r[48:56])
+}
+
+// SetEndOfFile sets the size of the file in bytes.
+func (r Response) SetEndOfFile(size uint64) {
+	smbtype.PutUint64(r[48:56], size)
+}
+
+// FileAttributes returns the attributes of the file.
+func (r Response) FileAttributes() smbfile.Attributes {
+	return smbfile.Attributes(smbtype.Uint32(r[56:60]))
+}
+
+// SetFileAttributes sets the attributes of the file.
+func (r Response) SetFileAttributes(attrs smbfile.Attributes) {
+	smbtype.PutUint32(r[56:60], uint32(attrs))
+}
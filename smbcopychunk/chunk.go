@@ -0,0 +1,42 @@
+package smbcopychunk
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ChunkSize is the number of bytes in a chunk.
+const ChunkSize = 24
+
+// Chunk interprets a slice of bytes as a chunk of a copy request. It
+// describes a single range of bytes to copy.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.31.1.1
+type Chunk []byte
+
+// SourceOffset returns the offset of the range within the source file.
+func (c Chunk) SourceOffset() uint64 {
+	return smbtype.Uint64(c[0:8])
+}
+
+// SetSourceOffset sets the offset of the range within the source file.
+func (c Chunk) SetSourceOffset(offset uint64) {
+	smbtype.PutUint64(c[0:8], offset)
+}
+
+// TargetOffset returns the offset of the range within the target file.
+func (c Chunk) TargetOffset() uint64 {
+	return smbtype.Uint64(c[8:16])
+}
+
+// SetTargetOffset sets the offset of the range within the target file.
+func (c Chunk) SetTargetOffset(offset uint64) {
+	smbtype.PutUint64(c[8:16], offset)
+}
+
+// Length returns the number of bytes to copy.
+func (c Chunk) Length() uint32 {
+	return smbtype.Uint32(c[16:20])
+}
+
+// SetLength sets the number of bytes to copy.
+func (c Chunk) SetLength(length uint32) {
+	smbtype.PutUint32(c[16:20], length)
+}
@@ -0,0 +1,4 @@
+// Package smbcopychunk provides types for SMB server-side copy requests and
+// responses, which are carried by FSCTL_SRV_REQUEST_RESUME_KEY,
+// FSCTL_SRV_COPYCHUNK and FSCTL_SRV_COPYCHUNK_WRITE.
+package smbcopychunk
@@ -0,0 +1,50 @@
+package smbcopychunk
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// RequestSize is the number of bytes required for the fixed portion of a
+// copy request.
+const RequestSize = 32
+
+// Request interprets a slice of bytes as a copy request.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.31.1
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+	return uint64(RequestSize)+uint64(r.ChunkCount())*ChunkSize <= uint64(len(r))
+}
+
+// SourceKey returns the resume key of the source file.
+func (r Request) SourceKey() []byte {
+	return r[0:KeySize:KeySize]
+}
+
+// SetSourceKey sets the resume key of the source file.
+func (r Request) SetSourceKey(key []byte) {
+	copy(r[0:KeySize], key)
+}
+
+// ChunkCount returns the number of chunks in the request.
+func (r Request) ChunkCount() uint32 {
+	return smbtype.Uint32(r[24:28])
+}
+
+// SetChunkCount sets the number of chunks in the request.
+func (r Request) SetChunkCount(count uint32) {
+	smbtype.PutUint32(r[24:28], count)
+}
+
+// Chunk returns the chunk at index i. It panics if i is out of range.
+func (r Request) Chunk(i int) Chunk {
+	if i < 0 || uint32(i) >= r.ChunkCount() {
+		panic("smbcopychunk: chunk index out of range")
+	}
+	start := RequestSize + i*ChunkSize
+	end := start + ChunkSize
+	return Chunk(r[start:end:end])
+}
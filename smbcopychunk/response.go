@@ -0,0 +1,58 @@
+package smbcopychunk
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ResponseSize is the number of bytes required for a copy response.
+const ResponseSize = 12
+
+// Response interprets a slice of bytes as a copy response.
+//
+// When a copy fails because the request exceeds the limits of the server,
+// the fields of the response hold those limits instead of the progress
+// of the copy.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.32.1
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	return len(r) >= ResponseSize
+}
+
+// ChunksWritten returns the number of chunks that were copied, or the
+// maximum number of chunks accepted by the server.
+func (r Response) ChunksWritten() uint32 {
+	return smbtype.Uint32(r[0:4])
+}
+
+// SetChunksWritten sets the number of chunks that were copied, or the
+// maximum number of chunks accepted by the server.
+func (r Response) SetChunksWritten(count uint32) {
+	smbtype.PutUint32(r[0:4], count)
+}
+
+// ChunkBytesWritten returns the number of bytes copied from a chunk that
+// was partially copied, or the maximum size of a chunk accepted by the
+// server.
+func (r Response) ChunkBytesWritten() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetChunkBytesWritten sets the number of bytes copied from a chunk that
+// was partially copied, or the maximum size of a chunk accepted by the
+// server.
+func (r Response) SetChunkBytesWritten(count uint32) {
+	smbtype.PutUint32(r[4:8], count)
+}
+
+// TotalBytesWritten returns the total number of bytes that were copied,
+// or the maximum number of bytes that the server copies for a request.
+func (r Response) TotalBytesWritten() uint32 {
+	return smbtype.Uint32(r[8:12])
+}
+
+// SetTotalBytesWritten sets the total number of bytes that were copied,
+// or the maximum number of bytes that the server copies for a request.
+func (r Response) SetTotalBytesWritten(count uint32) {
+	smbtype.PutUint32(r[8:12], count)
+}
@@ -0,0 +1,45 @@
+package smbcopychunk
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// KeySize is the number of bytes in a resume key.
+const KeySize = 24
+
+// ResumeKeyResponseSize is the number of bytes required for the fixed
+// portion of a resume key response.
+const ResumeKeyResponseSize = 28
+
+// ResumeKeyResponse interprets a slice of bytes as a resume key response.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.32.3
+type ResumeKeyResponse []byte
+
+// Valid returns true if the response is valid.
+func (r ResumeKeyResponse) Valid() bool {
+	if len(r) < ResumeKeyResponseSize {
+		return false
+	}
+	return uint64(ResumeKeyResponseSize)+uint64(r.ContextLength()) <= uint64(len(r))
+}
+
+// ResumeKey returns the resume key that identifies the source of a copy.
+func (r ResumeKeyResponse) ResumeKey() []byte {
+	return r[0:KeySize:KeySize]
+}
+
+// SetResumeKey sets the resume key that identifies the source of a copy.
+func (r ResumeKeyResponse) SetResumeKey(key []byte) {
+	copy(r[0:KeySize], key)
+}
+
+// ContextLength returns the length of the context that follows the
+// resume key.
+func (r ResumeKeyResponse) ContextLength() uint32 {
+	return smbtype.Uint32(r[24:28])
+}
+
+// SetContextLength sets the length of the context that follows the
+// resume key.
+func (r ResumeKeyResponse) SetContextLength(length uint32) {
+	smbtype.PutUint32(r[24:28], length)
+}
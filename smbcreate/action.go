@@ -0,0 +1,30 @@
+package smbcreate
+
+import "strconv"
+
+// Action describes the action that was taken by a create request.
+type Action uint32
+
+// Create actions.
+const (
+	Superseded  = 0x00000000 // FILE_SUPERSEDED
+	Opened      = 0x00000001 // FILE_OPENED
+	Created     = 0x00000002 // FILE_CREATED
+	Overwritten = 0x00000003 // FILE_OVERWRITTEN
+)
+
+// String returns a string representation of the action.
+func (a Action) String() string {
+	switch a {
+	case Superseded:
+		return "Superseded"
+	case Opened:
+		return "Opened"
+	case Created:
+		return "Created"
+	case Overwritten:
+		return "Overwritten"
+	default:
+		return "Action " + strconv.Itoa(int(a))
+	}
+}
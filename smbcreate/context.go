@@ -0,0 +1,222 @@
+package smbcreate
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ContextHeaderSize is the number of bytes required for the fixed portion
+// of an SMB create context.
+const ContextHeaderSize = 16
+
+// Create context names.
+const (
+	// RequestLease is the name of the SMB2_CREATE_REQUEST_LEASE and
+	// SMB2_CREATE_REQUEST_LEASE_V2 create contexts, and of the lease
+	// contexts returned in create responses.
+	RequestLease = "RqLs"
+
+	// DurableHandleRequest is the name of the
+	// SMB2_CREATE_DURABLE_HANDLE_REQUEST create context and of the
+	// SMB2_CREATE_DURABLE_HANDLE_RESPONSE context that grants it.
+	DurableHandleRequest = "DHnQ"
+
+	// DurableHandleRequestV2 is the name of the
+	// SMB2_CREATE_DURABLE_HANDLE_REQUEST_V2 create context and of the
+	// SMB2_CREATE_DURABLE_HANDLE_RESPONSE_V2 context that grants it.
+	DurableHandleRequestV2 = "DH2Q"
+
+	// DurableHandleReconnect is the name of the
+	// SMB2_CREATE_DURABLE_HANDLE_RECONNECT create context.
+	DurableHandleReconnect = "DHnC"
+
+	// DurableHandleReconnectV2 is the name of the
+	// SMB2_CREATE_DURABLE_HANDLE_RECONNECT_V2 create context.
+	DurableHandleReconnectV2 = "DH2C"
+
+	// TimewarpToken is the name of the SMB2_CREATE_TIMEWARP_TOKEN create
+	// context, which opens a file within a snapshot.
+	TimewarpToken = "TWrp"
+
+	// QueryMaximalAccess is the name of the
+	// SMB2_CREATE_QUERY_MAXIMAL_ACCESS_REQUEST create context and of the
+	// SMB2_CREATE_QUERY_MAXIMAL_ACCESS_RESPONSE context that answers it.
+	QueryMaximalAccess = "MxAc"
+
+	// POSIX is the name of the SMB2_POSIX_CREATE_CONTEXT create context,
+	// which carries the POSIX mode of a new file, and of the context that
+	// returns the POSIX information of the file. The name is the binary
+	// form of the SMB3 POSIX extensions ID.
+	POSIX = "\x93\xAD\x25\x50\x9C\xB4\x11\xE7\xB4\x23\x83\xDE\x96\x8B\xCD\x7C"
+)
+
+// Context interprets a slice of bytes as an SMB create context.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.13.2
+type Context []byte
+
+// Valid returns true if the context is valid.
+func (c Context) Valid() bool {
+	if len(c) < ContextHeaderSize {
+		return false
+	}
+
+	// The name must not overflow
+	start, length := int(c.NameOffset()), int(c.NameLength())
+	if start < ContextHeaderSize || start+length > len(c) {
+		return false
+	}
+
+	// The data must not overflow
+	if length := int(c.DataLength()); length > 0 {
+		start := int(c.DataOffset())
+		if start < ContextHeaderSize || start+length > len(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Next returns the offset of the next context in bytes from the start of
+// this context, or zero if this is the last context.
+func (c Context) Next() uint32 {
+	return smbtype.Uint32(c[0:4])
+}
+
+// SetNext sets the offset of the next context in bytes from the start of
+// this context.
+func (c Context) SetNext(next uint32) {
+	smbtype.PutUint32(c[0:4], next)
+}
+
+// NameOffset returns the offset of the context name in bytes from the start
+// of the context.
+func (c Context) NameOffset() uint16 {
+	return smbtype.Uint16(c[4:6])
+}
+
+// NameLength returns the length of the context name in bytes.
+func (c Context) NameLength() uint16 {
+	return smbtype.Uint16(c[6:8])
+}
+
+// DataOffset returns the offset of the context data in bytes from the start
+// of the context.
+func (c Context) DataOffset() uint16 {
+	return smbtype.Uint16(c[10:12])
+}
+
+// DataLength returns the length of the context data in bytes.
+func (c Context) DataLength() uint32 {
+	return smbtype.Uint32(c[12:16])
+}
+
+// Name returns the name of the context.
+func (c Context) Name() string {
+	start := int(c.NameOffset())
+	return string(c[start : start+int(c.NameLength())])
+}
+
+// Data returns the data of the context.
+func (c Context) Data() []byte {
+	length := int(c.DataLength())
+	if length == 0 {
+		return nil
+	}
+	start := int(c.DataOffset())
+	end := start + length
+	return c[start:end:end]
+}
+
+// ContextList interprets a slice of bytes as a chain of SMB create contexts.
+type ContextList []byte
+
+// Valid returns true if every context in the list is valid and aligned to
+// an 8-byte boundary.
+func (k ContextList) Valid() bool {
+	start := 0
+	for {
+		if start+ContextHeaderSize > len(k) {
+			return false
+		}
+		next := int(Context(k[start:]).Next())
+		end := len(k)
+		if next != 0 {
+			if next%8 != 0 || start+next > len(k) {
+				return false
+			}
+			end = start + next
+		}
+		if !Context(k[start:end:end]).Valid() {
+			return false
+		}
+		if next == 0 {
+			return true
+		}
+		start = end
+	}
+}
+
+// Contexts returns the members of the list. The list must be valid.
+func (k ContextList) Contexts() (contexts []Context) {
+	if len(k) == 0 {
+		return nil
+	}
+	start := 0
+	for {
+		next := int(Context(k[start:]).Next())
+		if next == 0 {
+			return append(contexts, Context(k[start:len(k):len(k)]))
+		}
+		end := start + next
+		contexts = append(contexts, Context(k[start:end:end]))
+		start = end
+	}
+}
+
+// Find returns the context with the given name. It returns false if the
+// list doesn't contain such a context. The list must be valid.
+func (k ContextList) Find(name string) (Context, bool) {
+	for _, c := range k.Contexts() {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// AppendContext appends a create context with the given name and data to
+// list, which holds zero or more serialized contexts, and returns the
+// extended list. The new context is aligned to an 8-byte boundary and
+// linked to the last context in the list.
+func AppendContext(list []byte, name string, data []byte) []byte {
+	start, last := 0, -1
+	if len(list) > 0 {
+		last = 0
+		for next := Context(list[last:]).Next(); next != 0; next = Context(list[last:]).Next() {
+			last += int(next)
+		}
+		start = align8(len(list))
+	}
+
+	dataOffset := 0
+	if len(data) > 0 {
+		dataOffset = align8(ContextHeaderSize + len(name))
+	}
+	length := ContextHeaderSize + len(name)
+	if len(data) > 0 {
+		length = dataOffset + len(data)
+	}
+
+	b := make([]byte, start+length)
+	copy(b, list)
+	if last >= 0 {
+		Context(b[last:]).SetNext(uint32(start - last))
+	}
+	c := Context(b[start:])
+	smbtype.PutUint16(c[4:6], ContextHeaderSize)
+	smbtype.PutUint16(c[6:8], uint16(len(name)))
+	smbtype.PutUint16(c[10:12], uint16(dataOffset))
+	smbtype.PutUint32(c[12:16], uint32(len(data)))
+	copy(c[ContextHeaderSize:], name)
+	copy(c[dataOffset:], data)
+	return b
+}
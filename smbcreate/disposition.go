@@ -0,0 +1,42 @@
+package smbcreate
+
+import "strconv"
+
+// Disposition declares the action to be taken when a file does or doesn't
+// already exist.
+type Disposition uint32
+
+// Create dispositions.
+const (
+	Supersede   = 0x00000000 // FILE_SUPERSEDE
+	Open        = 0x00000001 // FILE_OPEN
+	Create      = 0x00000002 // FILE_CREATE
+	OpenIf      = 0x00000003 // FILE_OPEN_IF
+	Overwrite   = 0x00000004 // FILE_OVERWRITE
+	OverwriteIf = 0x00000005 // FILE_OVERWRITE_IF
+)
+
+// Valid returns true if d is a known disposition.
+func (d Disposition) Valid() bool {
+	return d <= OverwriteIf
+}
+
+// String returns a string representation of the disposition.
+func (d Disposition) String() string {
+	switch d {
+	case Supersede:
+		return "Supersede"
+	case Open:
+		return "Open"
+	case Create:
+		return "Create"
+	case OpenIf:
+		return "OpenIf"
+	case Overwrite:
+		return "Overwrite"
+	case OverwriteIf:
+		return "OverwriteIf"
+	default:
+		return "Disposition " + strconv.Itoa(int(d))
+	}
+}
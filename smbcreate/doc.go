@@ -0,0 +1,2 @@
+// Package smbcreate provides types for SMB create requests and responses.
+package smbcreate
@@ -0,0 +1,44 @@
+package smbcreate
+
+import (
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// MaximalAccessResponseSize is the number of bytes required for the data of
+// an SMB2_CREATE_QUERY_MAXIMAL_ACCESS_RESPONSE create context.
+const MaximalAccessResponseSize = 8
+
+// MaximalAccessResponse interprets a slice of bytes as the data of an
+// SMB2_CREATE_QUERY_MAXIMAL_ACCESS_RESPONSE create context.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.14.2.5
+type MaximalAccessResponse []byte
+
+// Valid returns true if the context data is valid.
+func (r MaximalAccessResponse) Valid() bool {
+	return len(r) >= MaximalAccessResponseSize
+}
+
+// QueryStatus returns the status of the maximal access query.
+func (r MaximalAccessResponse) QueryStatus() smbstatus.Code {
+	return smbstatus.Code(smbtype.Uint32(r[0:4]))
+}
+
+// SetQueryStatus sets the status of the maximal access query.
+func (r MaximalAccessResponse) SetQueryStatus(status smbstatus.Code) {
+	smbtype.PutUint32(r[0:4], uint32(status))
+}
+
+// MaximalAccess returns the maximal access that the user of the session
+// has on the file.
+func (r MaximalAccessResponse) MaximalAccess() smbaccess.Mask {
+	return smbaccess.Mask(smbtype.Uint32(r[4:8]))
+}
+
+// SetMaximalAccess sets the maximal access that the user of the session
+// has on the file.
+func (r MaximalAccessResponse) SetMaximalAccess(access smbaccess.Mask) {
+	smbtype.PutUint32(r[4:8], uint32(access))
+}
@@ -0,0 +1,249 @@
+package smbcreate
+
+import (
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for the fixed portion of an
+// SMB create request.
+const RequestSize = 56
+
+// headerSize is the number of bytes in an SMB packet header. It's needed by
+// this package to calculate buffer offsets relative to the start of the
+// packet.
+const headerSize = 64
+
+// Request interprets a slice of bytes as an SMB create request packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.13
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 57
+	if r.Size() != 57 {
+		return false
+	}
+
+	// The name must not overflow and must hold whole utf16 code units
+	if length := int(r.NameLength()); length > 0 {
+		start := int(r.NameOffset()) - headerSize
+		if start < RequestSize || start+length > len(r) || length%2 != 0 {
+			return false
+		}
+	}
+
+	// The create contexts must not overflow
+	if length := int(r.CreateContextsLength()); length > 0 {
+		start := int(r.CreateContextsOffset()) - headerSize
+		if start < RequestSize || start+length > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r Request) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r Request) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// RequestedOplockLevel returns the oplock level requested by the client.
+func (r Request) RequestedOplockLevel() smboplock.Level {
+	return smboplock.Level(r[3])
+}
+
+// SetRequestedOplockLevel sets the oplock level requested by the client.
+func (r Request) SetRequestedOplockLevel(level smboplock.Level) {
+	r[3] = byte(level)
+}
+
+// ImpersonationLevel returns the impersonation level requested by the
+// client.
+func (r Request) ImpersonationLevel() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetImpersonationLevel sets the impersonation level requested by the
+// client.
+func (r Request) SetImpersonationLevel(level uint32) {
+	smbtype.PutUint32(r[4:8], level)
+}
+
+// DesiredAccess returns the access rights requested by the client.
+func (r Request) DesiredAccess() smbaccess.Mask {
+	return smbaccess.Mask(smbtype.Uint32(r[24:28]))
+}
+
+// SetDesiredAccess sets the access rights requested by the client.
+func (r Request) SetDesiredAccess(access smbaccess.Mask) {
+	smbtype.PutUint32(r[24:28], uint32(access))
+}
+
+// FileAttributes returns the attributes to be applied to a file that is
+// created.
+func (r Request) FileAttributes() smbfile.Attributes {
+	return smbfile.Attributes(smbtype.Uint32(r[28:32]))
+}
+
+// SetFileAttributes sets the attributes to be applied to a file that is
+// created.
+func (r Request) SetFileAttributes(attrs smbfile.Attributes) {
+	smbtype.PutUint32(r[28:32], uint32(attrs))
+}
+
+// ShareAccess returns the types of access that the open permits other opens
+// of the file to have.
+func (r Request) ShareAccess() ShareAccess {
+	return ShareAccess(smbtype.Uint32(r[32:36]))
+}
+
+// SetShareAccess sets the types of access that the open permits other opens
+// of the file to have.
+func (r Request) SetShareAccess(share ShareAccess) {
+	smbtype.PutUint32(r[32:36], uint32(share))
+}
+
+// CreateDisposition returns the action to be taken depending on whether
+// the file already exists.
+func (r Request) CreateDisposition() Disposition {
+	return Disposition(smbtype.Uint32(r[36:40]))
+}
+
+// SetCreateDisposition sets the action to be taken depending on whether
+// the file already exists.
+func (r Request) SetCreateDisposition(disposition Disposition) {
+	smbtype.PutUint32(r[36:40], uint32(disposition))
+}
+
+// CreateOptions returns the options to be applied when opening or creating
+// the file.
+func (r Request) CreateOptions() Options {
+	return Options(smbtype.Uint32(r[40:44]))
+}
+
+// SetCreateOptions sets the options to be applied when opening or creating
+// the file.
+func (r Request) SetCreateOptions(options Options) {
+	smbtype.PutUint32(r[40:44], uint32(options))
+}
+
+// NameOffset returns the offset of the file name in bytes from the start of
+// the packet header.
+func (r Request) NameOffset() uint16 {
+	return smbtype.Uint16(r[44:46])
+}
+
+// SetNameOffset sets the offset of the file name in bytes from the start of
+// the packet header.
+func (r Request) SetNameOffset(offset uint16) {
+	smbtype.PutUint16(r[44:46], offset)
+}
+
+// NameLength returns the length of the file name in bytes.
+func (r Request) NameLength() uint16 {
+	return smbtype.Uint16(r[46:48])
+}
+
+// SetNameLength sets the length of the file name in bytes.
+func (r Request) SetNameLength(length uint16) {
+	smbtype.PutUint16(r[46:48], length)
+}
+
+// Name returns the file name of the request, which is relative to the share
+// and uses backslashes as separators.
+func (r Request) Name() string {
+	length := uint(r.NameLength())
+	if length == 0 {
+		return ""
+	}
+	start := uint(r.NameOffset()) - headerSize
+	return smbtype.String(r[start : start+length])
+}
+
+// CreateContextsOffset returns the offset of the create contexts in bytes
+// from the start of the packet header.
+func (r Request) CreateContextsOffset() uint32 {
+	return smbtype.Uint32(r[48:52])
+}
+
+// SetCreateContextsOffset sets the offset of the create contexts in bytes
+// from the start of the packet header.
+func (r Request) SetCreateContextsOffset(offset uint32) {
+	smbtype.PutUint32(r[48:52], offset)
+}
+
+// CreateContextsLength returns the length of the create contexts in bytes.
+func (r Request) CreateContextsLength() uint32 {
+	return smbtype.Uint32(r[52:56])
+}
+
+// SetCreateContextsLength sets the length of the create contexts in bytes.
+func (r Request) SetCreateContextsLength(length uint32) {
+	smbtype.PutUint32(r[52:56], length)
+}
+
+// CreateContexts returns the serialized create contexts of the request.
+func (r Request) CreateContexts() []byte {
+	length := uint(r.CreateContextsLength())
+	if length == 0 {
+		return nil
+	}
+	start := uint(r.CreateContextsOffset()) - headerSize
+	end := start + length
+	return r[start:end:end]
+}
+
+// SetNameAndContexts lays out the file name and serialized create contexts
+// in the buffer that follows the fixed portion of the request. The create
+// contexts are aligned to an 8-byte boundary.
+//
+// If the request is too small to hold the name and contexts the call will
+// panic. Use BufferSize to determine the size of the buffer.
+func (r Request) SetNameAndContexts(name string, contexts []byte) {
+	length := smbtype.StringLen(name)
+	r.SetNameOffset(headerSize + RequestSize)
+	r.SetNameLength(uint16(length))
+	smbtype.PutString(r[RequestSize:RequestSize+length], name)
+
+	if len(contexts) == 0 {
+		r.SetCreateContextsOffset(0)
+		r.SetCreateContextsLength(0)
+		return
+	}
+	start := align8(RequestSize + length)
+	r.SetCreateContextsOffset(uint32(headerSize + start))
+	r.SetCreateContextsLength(uint32(len(contexts)))
+	copy(r[start:start+len(contexts)], contexts)
+}
+
+// BufferSize returns the number of bytes required to hold a request with
+// the given name and create contexts.
+func BufferSize(name string, contexts []byte) int {
+	length := RequestSize + smbtype.StringLen(name)
+	if len(contexts) > 0 {
+		length = align8(length) + len(contexts)
+	}
+	if length == RequestSize {
+		// The buffer must be at least one byte long
+		length++
+	}
+	return length
+}
+
+func align8(length int) int {
+	return (length + 7) &^ 7
+}
@@ -0,0 +1,205 @@
+package smbcreate
+
+import (
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ResponseSize is the number of bytes required for the fixed portion of an
+// SMB create response.
+const ResponseSize = 88
+
+// Response interprets a slice of bytes as an SMB create response packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.14
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	if len(r) < ResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 89
+	if r.Size() != 89 {
+		return false
+	}
+
+	// The create contexts must not overflow
+	if length := int(r.CreateContextsLength()); length > 0 {
+		start := int(r.CreateContextsOffset()) - headerSize
+		if start < ResponseSize || start+length > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r Response) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r Response) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// OplockLevel returns the oplock level granted to the client.
+func (r Response) OplockLevel() smboplock.Level {
+	return smboplock.Level(r[2])
+}
+
+// SetOplockLevel sets the oplock level granted to the client.
+func (r Response) SetOplockLevel(level smboplock.Level) {
+	r[2] = byte(level)
+}
+
+// CreateAction returns the action that was taken by the server.
+func (r Response) CreateAction() Action {
+	return Action(smbtype.Uint32(r[4:8]))
+}
+
+// SetCreateAction sets the action that was taken by the server.
+func (r Response) SetCreateAction(action Action) {
+	smbtype.PutUint32(r[4:8], uint32(action))
+}
+
+// CreationTime returns the time the file was created.
+func (r Response) CreationTime() time.Time {
+	return smbtype.Time(r[8:16])
+}
+
+// SetCreationTime sets the time the file was created.
+func (r Response) SetCreationTime(t time.Time) {
+	smbtype.PutTime(r[8:16], t)
+}
+
+// LastAccessTime returns the time the file was last accessed.
+func (r Response) LastAccessTime() time.Time {
+	return smbtype.Time(r[16:24])
+}
+
+// SetLastAccessTime sets the time the file was last accessed.
+func (r Response) SetLastAccessTime(t time.Time) {
+	smbtype.PutTime(r[16:24], t)
+}
+
+// LastWriteTime returns the time the file was last written.
+func (r Response) LastWriteTime() time.Time {
+	return smbtype.Time(r[24:32])
+}
+
+// SetLastWriteTime sets the time the file was last written.
+func (r Response) SetLastWriteTime(t time.Time) {
+	smbtype.PutTime(r[24:32], t)
+}
+
+// ChangeTime returns the time the file was last changed.
+func (r Response) ChangeTime() time.Time {
+	return smbtype.Time(r[32:40])
+}
+
+// SetChangeTime sets the time the file was last changed.
+func (r Response) SetChangeTime(t time.Time) {
+	smbtype.PutTime(r[32:40], t)
+}
+
+// AllocationSize returns the number of bytes allocated to the file.
+func (r Response) AllocationSize() uint64 {
+	return smbtype.Uint64(r[40:48])
+}
+
+// SetAllocationSize sets the number of bytes allocated to the file.
+func (r Response) SetAllocationSize(size uint64) {
+	smbtype.PutUint64(r[40:48], size)
+}
+
+// EndOfFile returns the size of the file in bytes.
+func (r Response) EndOfFile() uint64 {
+	return smbtype.Uint64(r[48:56])
+}
+
+// SetEndOfFile sets the size of the file in bytes.
+func (r Response) SetEndOfFile(size uint64) {
+	smbtype.PutUint64(r[48:56], size)
+}
+
+// FileAttributes returns the attributes of the file.
+func (r Response) FileAttributes() smbfile.Attributes {
+	return smbfile.Attributes(smbtype.Uint32(r[56:60]))
+}
+
+// SetFileAttributes sets the attributes of the file.
+func (r Response) SetFileAttributes(attrs smbfile.Attributes) {
+	smbtype.PutUint32(r[56:60], uint32(attrs))
+}
+
+// FileID returns the file ID of the open.
+func (r Response) FileID() (id smbfile.ID) {
+	id.Read(r[64:80])
+	return
+}
+
+// SetFileID sets the file ID of the open.
+func (r Response) SetFileID(id smbfile.ID) {
+	id.Write(r[64:80])
+}
+
+// CreateContextsOffset returns the offset of the create contexts in bytes
+// from the start of the packet header.
+func (r Response) CreateContextsOffset() uint32 {
+	return smbtype.Uint32(r[80:84])
+}
+
+// SetCreateContextsOffset sets the offset of the create contexts in bytes
+// from the start of the packet header.
+func (r Response) SetCreateContextsOffset(offset uint32) {
+	smbtype.PutUint32(r[80:84], offset)
+}
+
+// CreateContextsLength returns the length of the create contexts in bytes.
+func (r Response) CreateContextsLength() uint32 {
+	return smbtype.Uint32(r[84:88])
+}
+
+// SetCreateContextsLength sets the length of the create contexts in bytes.
+func (r Response) SetCreateContextsLength(length uint32) {
+	smbtype.PutUint32(r[84:88], length)
+}
+
+// CreateContexts returns the serialized create contexts of the response.
+func (r Response) CreateContexts() []byte {
+	length := uint(r.CreateContextsLength())
+	if length == 0 {
+		return nil
+	}
+	start := uint(r.CreateContextsOffset()) - headerSize
+	end := start + length
+	return r[start:end:end]
+}
+
+// SetCreateContextsLayout sets the offset and length of the create
+// contexts. The contexts are placed immediately after the fixed portion of
+// the response. It returns the create context buffer so that it can be
+// populated.
+//
+// If the response is too small to hold length bytes the call will panic.
+func (r Response) SetCreateContextsLayout(length int) []byte {
+	if len(r)-ResponseSize < length {
+		panic("smbcreate: response: create contexts are too large to fit in response")
+	}
+	if length == 0 {
+		r.SetCreateContextsOffset(0)
+		r.SetCreateContextsLength(0)
+		return nil
+	}
+	r.SetCreateContextsOffset(headerSize + ResponseSize)
+	r.SetCreateContextsLength(uint32(length))
+	end := ResponseSize + length
+	return r[ResponseSize:end:end]
+}
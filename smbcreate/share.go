@@ -0,0 +1,34 @@
+package smbcreate
+
+import "strings"
+
+// ShareAccess declares the types of access that an open permits other opens
+// of the same file to have.
+type ShareAccess uint32
+
+// Share access flags.
+const (
+	ShareRead   = 0x00000001 // FILE_SHARE_READ
+	ShareWrite  = 0x00000002 // FILE_SHARE_WRITE
+	ShareDelete = 0x00000004 // FILE_SHARE_DELETE
+)
+
+// Match reports whether s contains all of the flags specified by c.
+func (s ShareAccess) Match(c ShareAccess) bool {
+	return s&c == c
+}
+
+// String returns a string representation of the share access flags.
+func (s ShareAccess) String() string {
+	var names []string
+	if s.Match(ShareRead) {
+		names = append(names, "Read")
+	}
+	if s.Match(ShareWrite) {
+		names = append(names, "Write")
+	}
+	if s.Match(ShareDelete) {
+		names = append(names, "Delete")
+	}
+	return strings.Join(names, "|")
+}
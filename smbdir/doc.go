@@ -0,0 +1,3 @@
+// Package smbdir provides types for SMB QUERY_DIRECTORY requests and
+// responses.
+package smbdir
@@ -0,0 +1,26 @@
+package smbdir
+
+// Flags declares a set of QUERY_DIRECTORY request flags.
+type Flags uint8
+
+// QUERY_DIRECTORY request flags.
+const (
+	// RestartScans causes the enumeration to start again from the
+	// beginning of the directory.
+	RestartScans = 0x01 // SMB2_RESTART_SCANS
+
+	// ReturnSingleEntry limits the response to a single entry.
+	ReturnSingleEntry = 0x02 // SMB2_RETURN_SINGLE_ENTRY
+
+	// IndexSpecified causes the enumeration to resume from the entry
+	// with the given file index.
+	IndexSpecified = 0x04 // SMB2_INDEX_SPECIFIED
+
+	// Reopen restarts the enumeration with a new search pattern.
+	Reopen = 0x10 // SMB2_REOPEN
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
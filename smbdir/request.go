@@ -0,0 +1,164 @@
+package smbdir
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for the fixed portion of an
+// SMB QUERY_DIRECTORY request.
+const RequestSize = 32
+
+// headerSize is the number of bytes in an SMB packet header. It's needed by
+// this package to calculate buffer offsets relative to the start of the
+// packet.
+const headerSize = 64
+
+// RequestBufferSize returns the number of bytes required for a
+// QUERY_DIRECTORY request with the given search pattern.
+func RequestBufferSize(pattern string) int {
+	length := smbtype.StringLen(pattern)
+	if length == 0 {
+		// The buffer must be at least one byte long
+		length = 1
+	}
+	return RequestSize + length
+}
+
+// Request interprets a slice of bytes as an SMB QUERY_DIRECTORY request
+// packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.33
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 33
+	if r.Size() != 33 {
+		return false
+	}
+
+	// The file name must not overflow
+	if length := int(r.FileNameLength()); length > 0 {
+		start := int(r.FileNameOffset()) - headerSize
+		if start < RequestSize || start+length > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r Request) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r Request) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// FileInfoClass returns the class of information to be returned for each
+// entry.
+func (r Request) FileInfoClass() uint8 {
+	return r[2]
+}
+
+// SetFileInfoClass sets the class of information to be returned for each
+// entry.
+func (r Request) SetFileInfoClass(class uint8) {
+	r[2] = class
+}
+
+// Flags returns the flags of the request.
+func (r Request) Flags() Flags {
+	return Flags(r[3])
+}
+
+// SetFlags sets the flags of the request.
+func (r Request) SetFlags(flags Flags) {
+	r[3] = byte(flags)
+}
+
+// FileIndex returns the index of the entry to resume the enumeration from
+// if the IndexSpecified flag is set.
+func (r Request) FileIndex() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetFileIndex sets the index of the entry to resume the enumeration from.
+func (r Request) SetFileIndex(index uint32) {
+	smbtype.PutUint32(r[4:8], index)
+}
+
+// FileID returns the file ID of the directory being enumerated.
+func (r Request) FileID() (id smbfile.ID) {
+	id.Read(r[8:24])
+	return
+}
+
+// SetFileID sets the file ID of the directory being enumerated.
+func (r Request) SetFileID(id smbfile.ID) {
+	id.Write(r[8:24])
+}
+
+// FileNameOffset returns the offset of the search pattern in bytes from
+// the start of the packet header.
+func (r Request) FileNameOffset() uint16 {
+	return smbtype.Uint16(r[24:26])
+}
+
+// SetFileNameOffset sets the offset of the search pattern in bytes from
+// the start of the packet header.
+func (r Request) SetFileNameOffset(offset uint16) {
+	smbtype.PutUint16(r[24:26], offset)
+}
+
+// FileNameLength returns the length of the search pattern in bytes.
+func (r Request) FileNameLength() uint16 {
+	return smbtype.Uint16(r[26:28])
+}
+
+// SetFileNameLength sets the length of the search pattern in bytes.
+func (r Request) SetFileNameLength(length uint16) {
+	smbtype.PutUint16(r[26:28], length)
+}
+
+// OutputBufferLength returns the maximum number of bytes the server is
+// allowed to return in the response.
+func (r Request) OutputBufferLength() uint32 {
+	return smbtype.Uint32(r[28:32])
+}
+
+// SetOutputBufferLength sets the maximum number of bytes the server is
+// allowed to return in the response.
+func (r Request) SetOutputBufferLength(length uint32) {
+	smbtype.PutUint32(r[28:32], length)
+}
+
+// FileName returns the search pattern of the request, which may contain
+// wildcards.
+func (r Request) FileName() string {
+	length := uint(r.FileNameLength())
+	if length == 0 {
+		return ""
+	}
+	start := uint(r.FileNameOffset()) - headerSize
+	return smbtype.String(r[start : start+length])
+}
+
+// SetFileName sets the search pattern of the request, which is placed
+// immediately after the fixed portion of the request.
+//
+// If the request is too small to hold the pattern the call will panic.
+// Use RequestBufferSize to determine the size of the buffer.
+func (r Request) SetFileName(pattern string) {
+	n := smbtype.PutString(r[RequestSize:], pattern)
+	r.SetFileNameOffset(headerSize + RequestSize)
+	r.SetFileNameLength(uint16(n))
+}
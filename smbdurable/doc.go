@@ -0,0 +1,3 @@
+// Package smbdurable provides types for the create contexts that request
+// and reconnect durable handles.
+package smbdurable
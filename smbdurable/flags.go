@@ -0,0 +1,14 @@
+package smbdurable
+
+// Flags are the flags of a version 2 durable handle create context.
+type Flags uint32
+
+const (
+	// Persistent requests or indicates a persistent handle.
+	Persistent = 0x00000002 // SMB2_DHANDLE_FLAG_PERSISTENT
+)
+
+// Match reports whether f contains all of the flags in c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
@@ -0,0 +1,80 @@
+package smbdurable
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ReconnectSize is the number of bytes required for the data of an
+// SMB2_CREATE_DURABLE_HANDLE_RECONNECT create context.
+const ReconnectSize = 16
+
+// ReconnectV2Size is the number of bytes required for the data of an
+// SMB2_CREATE_DURABLE_HANDLE_RECONNECT_V2 create context.
+const ReconnectV2Size = 36
+
+// Reconnect interprets a slice of bytes as the data of an
+// SMB2_CREATE_DURABLE_HANDLE_RECONNECT create context.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.13.2.4
+type Reconnect []byte
+
+// Valid returns true if the context data is valid.
+func (r Reconnect) Valid() bool {
+	return len(r) >= ReconnectSize
+}
+
+// FileID returns the file ID of the open being reconnected.
+func (r Reconnect) FileID() (id smbfile.ID) {
+	id.Read(r[0:16])
+	return
+}
+
+// SetFileID sets the file ID of the open being reconnected.
+func (r Reconnect) SetFileID(id smbfile.ID) {
+	id.Write(r[0:16])
+}
+
+// ReconnectV2 interprets a slice of bytes as the data of an
+// SMB2_CREATE_DURABLE_HANDLE_RECONNECT_V2 create context.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.13.2.12
+type ReconnectV2 []byte
+
+// Valid returns true if the context data is valid.
+func (r ReconnectV2) Valid() bool {
+	return len(r) >= ReconnectV2Size
+}
+
+// FileID returns the file ID of the open being reconnected.
+func (r ReconnectV2) FileID() (id smbfile.ID) {
+	id.Read(r[0:16])
+	return
+}
+
+// SetFileID sets the file ID of the open being reconnected.
+func (r ReconnectV2) SetFileID(id smbfile.ID) {
+	id.Write(r[0:16])
+}
+
+// CreateGUID returns the create GUID of the open being reconnected.
+func (r ReconnectV2) CreateGUID() (id smbid.ID) {
+	id.Read(r[16:32])
+	return
+}
+
+// SetCreateGUID sets the create GUID of the open being reconnected.
+func (r ReconnectV2) SetCreateGUID(id smbid.ID) {
+	id.Write(r[16:32])
+}
+
+// Flags returns the flags of the context.
+func (r ReconnectV2) Flags() Flags {
+	return Flags(smbtype.Uint32(r[32:36]))
+}
+
+// SetFlags sets the flags of the context.
+func (r ReconnectV2) SetFlags(flags Flags) {
+	smbtype.PutUint32(r[32:36], uint32(flags))
+}
@@ -0,0 +1,75 @@
+package smbdurable
+
+import (
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for the data of an
+// SMB2_CREATE_DURABLE_HANDLE_REQUEST create context.
+const RequestSize = 16
+
+// RequestV2Size is the number of bytes required for the data of an
+// SMB2_CREATE_DURABLE_HANDLE_REQUEST_V2 create context.
+const RequestV2Size = 32
+
+// Request interprets a slice of bytes as the data of an
+// SMB2_CREATE_DURABLE_HANDLE_REQUEST create context. Its contents are
+// reserved.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.13.2.3
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	return len(r) >= RequestSize
+}
+
+// RequestV2 interprets a slice of bytes as the data of an
+// SMB2_CREATE_DURABLE_HANDLE_REQUEST_V2 create context.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.13.2.11
+type RequestV2 []byte
+
+// Valid returns true if the request is valid.
+func (r RequestV2) Valid() bool {
+	return len(r) >= RequestV2Size
+}
+
+// Timeout returns the amount of time the client would like the server to
+// preserve the open after the connection is lost. A value of zero lets the
+// server choose.
+func (r RequestV2) Timeout() time.Duration {
+	return time.Duration(smbtype.Uint32(r[0:4])) * time.Millisecond
+}
+
+// SetTimeout sets the amount of time the client would like the server to
+// preserve the open after the connection is lost. It is rounded down to
+// whole milliseconds.
+func (r RequestV2) SetTimeout(timeout time.Duration) {
+	smbtype.PutUint32(r[0:4], uint32(timeout/time.Millisecond))
+}
+
+// Flags returns the flags of the request.
+func (r RequestV2) Flags() Flags {
+	return Flags(smbtype.Uint32(r[4:8]))
+}
+
+// SetFlags sets the flags of the request.
+func (r RequestV2) SetFlags(flags Flags) {
+	smbtype.PutUint32(r[4:8], uint32(flags))
+}
+
+// CreateGUID returns the GUID that identifies the create request across
+// retries and reconnects.
+func (r RequestV2) CreateGUID() (id smbid.ID) {
+	id.Read(r[16:32])
+	return
+}
+
+// SetCreateGUID sets the GUID that identifies the create request.
+func (r RequestV2) SetCreateGUID(id smbid.ID) {
+	id.Write(r[16:32])
+}
@@ -0,0 +1,49 @@
+package smbdurable
+
+import (
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ResponseSize is the number of bytes required for the data of an
+// SMB2_CREATE_DURABLE_HANDLE_RESPONSE create context. Its contents are
+// reserved.
+const ResponseSize = 8
+
+// ResponseV2Size is the number of bytes required for the data of an
+// SMB2_CREATE_DURABLE_HANDLE_RESPONSE_V2 create context.
+const ResponseV2Size = 8
+
+// ResponseV2 interprets a slice of bytes as the data of an
+// SMB2_CREATE_DURABLE_HANDLE_RESPONSE_V2 create context.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.14.2.12
+type ResponseV2 []byte
+
+// Valid returns true if the context data is valid.
+func (r ResponseV2) Valid() bool {
+	return len(r) >= ResponseV2Size
+}
+
+// Timeout returns the amount of time the server will preserve the open
+// after the connection is lost.
+func (r ResponseV2) Timeout() time.Duration {
+	return time.Duration(smbtype.Uint32(r[0:4])) * time.Millisecond
+}
+
+// SetTimeout sets the amount of time the server will preserve the open
+// after the connection is lost. It is rounded down to whole milliseconds.
+func (r ResponseV2) SetTimeout(timeout time.Duration) {
+	smbtype.PutUint32(r[0:4], uint32(timeout/time.Millisecond))
+}
+
+// Flags returns the flags of the response.
+func (r ResponseV2) Flags() Flags {
+	return Flags(smbtype.Uint32(r[4:8]))
+}
+
+// SetFlags sets the flags of the response.
+func (r ResponseV2) SetFlags(flags Flags) {
+	smbtype.PutUint32(r[4:8], uint32(flags))
+}
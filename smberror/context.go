@@ -0,0 +1,69 @@
+package smberror
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ContextSize is the number of bytes required for the fixed portion of an
+// error context.
+const ContextSize = 8
+
+// ContextID identifies the type of an error context.
+type ContextID uint32
+
+// Error context identifiers.
+const (
+	DefaultContext = 0x00000000 // SMB2_ERROR_ID_DEFAULT
+)
+
+// Context interprets a slice of bytes as an error context, which holds
+// error data in SMB 3.1.1 error responses. Contexts are aligned to 8 byte
+// boundaries within the error data.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.2.1
+type Context []byte
+
+// ContextBufferSize returns the number of bytes required for an error
+// context holding the given number of bytes of data, including the padding
+// that aligns a subsequent context.
+func ContextBufferSize(length int) int {
+	return (ContextSize + length + 7) &^ 7
+}
+
+// Valid returns true if the context is valid.
+func (c Context) Valid() bool {
+	if len(c) < ContextSize {
+		return false
+	}
+	return uint64(ContextSize)+uint64(c.DataLength()) <= uint64(len(c))
+}
+
+// DataLength returns the number of bytes of data in the context.
+func (c Context) DataLength() uint32 {
+	return smbtype.Uint32(c[0:4])
+}
+
+// ID returns the identifier of the context.
+func (c Context) ID() ContextID {
+	return ContextID(smbtype.Uint32(c[4:8]))
+}
+
+// SetID sets the identifier of the context.
+func (c Context) SetID(id ContextID) {
+	smbtype.PutUint32(c[4:8], uint32(id))
+}
+
+// Data returns the data of the context.
+func (c Context) Data() []byte {
+	end := ContextSize + uint(c.DataLength())
+	return c[ContextSize:end:end]
+}
+
+// SetData sets the data of the context and its length.
+//
+// If the context is too small to hold all of v the call will panic.
+func (c Context) SetData(v []byte) {
+	if len(c)-ContextSize < len(v) {
+		panic("smberror: context: data is too large to fit in context")
+	}
+	smbtype.PutUint32(c[0:4], uint32(len(v)))
+	copy(c[ContextSize:], v)
+}
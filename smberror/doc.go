@@ -0,0 +1,3 @@
+// Package smberror facilitates serialization and deserialization of SMB
+// error responses.
+package smberror
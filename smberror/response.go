@@ -0,0 +1,85 @@
+package smberror
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ResponseSize is the number of bytes required for the fixed portion of an
+// SMB error response. The error data that follows must be at least one
+// byte long, even when it is empty.
+const ResponseSize = 8
+
+// Response interprets a slice of bytes as an SMB error response packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/d4da8b67-c180-47e3-ba7a-d24214ac4aaa
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	if len(r) < ResponseSize+1 {
+		return false
+	}
+
+	// The spec requires the size field to be 9
+	if r.Size() != 9 {
+		return false
+	}
+
+	// The error data must not overflow
+	if ResponseSize+int(r.ByteCount()) > len(r) {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response. The specification
+// requires that this be 9, regardless of the length of the error data.
+func (r Response) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r Response) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// ContextCount returns the number of error contexts in the error data.
+//
+// This field is only valid in the SMB 3.1.1 dialect.
+func (r Response) ContextCount() uint8 {
+	return r[2]
+}
+
+// SetContextCount sets the number of error contexts in the error data.
+//
+// This field is only valid in the SMB 3.1.1 dialect.
+func (r Response) SetContextCount(count uint8) {
+	r[2] = count
+}
+
+// ByteCount returns the number of bytes of error data in the response.
+func (r Response) ByteCount() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetByteCount sets the number of bytes of error data in the response.
+func (r Response) SetByteCount(count uint32) {
+	smbtype.PutUint32(r[4:8], count)
+}
+
+// Data returns the error data of the response.
+func (r Response) Data() []byte {
+	end := ResponseSize + uint(r.ByteCount())
+	return r[ResponseSize:end:end]
+}
+
+// SetData sets the error data of the response. It also updates the byte
+// count automatically.
+//
+// If the response is too small to hold all of v the call will panic.
+func (r Response) SetData(v []byte) {
+	if len(r)-ResponseSize < len(v) {
+		panic("smberror: response: error data is too large to fit in response")
+	}
+	r.SetByteCount(uint32(len(v)))
+	copy(r[ResponseSize:], v)
+}
@@ -0,0 +1,143 @@
+package smberror
+
+import (
+	"github.com/gentlemanautomaton/smb/smbreparse"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// SymlinkSize is the number of bytes required for the fixed portion of a
+// symbolic link error response.
+const SymlinkSize = 28
+
+// SymlinkErrorTag is the tag that identifies a symbolic link error
+// response.
+const SymlinkErrorTag = 0x4C4D5953
+
+// SymlinkBufferSize returns the number of bytes required for a symbolic
+// link error response with the given names.
+func SymlinkBufferSize(substitute, print string) int {
+	return SymlinkSize + smbtype.StringLen(substitute) + smbtype.StringLen(print)
+}
+
+// Symlink interprets a slice of bytes as a symbolic link error response,
+// which is the error data of STATUS_STOPPED_ON_SYMLINK. It tells the
+// client which part of the path it opened is a symbolic link and where the
+// link points, so that the client can follow it.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.2.2.1
+type Symlink []byte
+
+// Valid returns true if the response is valid.
+func (s Symlink) Valid() bool {
+	if len(s) < SymlinkSize || s.ErrorTag() != SymlinkErrorTag {
+		return false
+	}
+	if 4+uint64(s.SymlinkLength()) > uint64(len(s)) {
+		return false
+	}
+	path := uint(len(s) - SymlinkSize)
+	if uint(s.SubstituteNameOffset())+uint(s.SubstituteNameLength()) > path {
+		return false
+	}
+	if uint(s.PrintNameOffset())+uint(s.PrintNameLength()) > path {
+		return false
+	}
+	return true
+}
+
+// SymlinkLength returns the length of the response, excluding the symlink
+// length field itself.
+func (s Symlink) SymlinkLength() uint32 {
+	return smbtype.Uint32(s[0:4])
+}
+
+// ErrorTag returns the tag of the response, which must be
+// SymlinkErrorTag.
+func (s Symlink) ErrorTag() uint32 {
+	return smbtype.Uint32(s[4:8])
+}
+
+// ReparseTag returns the reparse tag of the link.
+func (s Symlink) ReparseTag() smbreparse.Tag {
+	return smbreparse.Tag(smbtype.Uint32(s[8:12]))
+}
+
+// UnparsedPathLength returns the length in bytes of the part of the path
+// that follows the symbolic link.
+func (s Symlink) UnparsedPathLength() uint16 {
+	return smbtype.Uint16(s[14:16])
+}
+
+// SetUnparsedPathLength sets the length in bytes of the part of the path
+// that follows the symbolic link.
+func (s Symlink) SetUnparsedPathLength(length uint16) {
+	smbtype.PutUint16(s[14:16], length)
+}
+
+// SubstituteNameOffset returns the offset of the substitute name within
+// the path buffer.
+func (s Symlink) SubstituteNameOffset() uint16 {
+	return smbtype.Uint16(s[16:18])
+}
+
+// SubstituteNameLength returns the length of the substitute name in bytes.
+func (s Symlink) SubstituteNameLength() uint16 {
+	return smbtype.Uint16(s[18:20])
+}
+
+// PrintNameOffset returns the offset of the print name within the path
+// buffer.
+func (s Symlink) PrintNameOffset() uint16 {
+	return smbtype.Uint16(s[20:22])
+}
+
+// PrintNameLength returns the length of the print name in bytes.
+func (s Symlink) PrintNameLength() uint16 {
+	return smbtype.Uint16(s[22:24])
+}
+
+// Flags returns the symbolic link flags.
+func (s Symlink) Flags() smbreparse.Flags {
+	return smbreparse.Flags(smbtype.Uint32(s[24:28]))
+}
+
+// SetFlags sets the symbolic link flags.
+func (s Symlink) SetFlags(flags smbreparse.Flags) {
+	smbtype.PutUint32(s[24:28], uint32(flags))
+}
+
+// SubstituteName returns the substitute name, which is the target of the
+// symbolic link.
+func (s Symlink) SubstituteName() string {
+	start := SymlinkSize + uint(s.SubstituteNameOffset())
+	return smbtype.String(s[start : start+uint(s.SubstituteNameLength())])
+}
+
+// PrintName returns the print name, which is the target of the symbolic
+// link in a form suitable for display.
+func (s Symlink) PrintName() string {
+	start := SymlinkSize + uint(s.PrintNameOffset())
+	return smbtype.String(s[start : start+uint(s.PrintNameLength())])
+}
+
+// SetNames writes the substitute name and print name to the path buffer.
+// It also sets the error tag, the reparse tag and the lengths of the
+// response.
+//
+// If the response is too small to hold the names the call will panic.
+func (s Symlink) SetNames(substitute, print string) {
+	if len(s) < SymlinkBufferSize(substitute, print) {
+		panic("smberror: symlink: names are too large to fit in response")
+	}
+	path := s[SymlinkSize:]
+	sublen := smbtype.PutString(path, substitute)
+	printlen := smbtype.PutString(path[sublen:], print)
+	smbtype.PutUint32(s[0:4], uint32(SymlinkSize-4+sublen+printlen))
+	smbtype.PutUint32(s[4:8], SymlinkErrorTag)
+	smbtype.PutUint32(s[8:12], smbreparse.Symlink)
+	smbtype.PutUint16(s[12:14], uint16(SymlinkSize-16+sublen+printlen))
+	smbtype.PutUint16(s[16:18], 0)
+	smbtype.PutUint16(s[18:20], uint16(sublen))
+	smbtype.PutUint16(s[20:22], uint16(sublen))
+	smbtype.PutUint16(s[22:24], uint16(printlen))
+}
@@ -0,0 +1,64 @@
+package smbfile
+
+import "strings"
+
+// Attributes declares a set of file attributes.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.6
+type Attributes uint32
+
+// File attributes.
+const (
+	ReadOnly          = 0x00000001 // FILE_ATTRIBUTE_READONLY
+	Hidden            = 0x00000002 // FILE_ATTRIBUTE_HIDDEN
+	System            = 0x00000004 // FILE_ATTRIBUTE_SYSTEM
+	Directory         = 0x00000010 // FILE_ATTRIBUTE_DIRECTORY
+	Archive           = 0x00000020 // FILE_ATTRIBUTE_ARCHIVE
+	Normal            = 0x00000080 // FILE_ATTRIBUTE_NORMAL
+	Temporary         = 0x00000100 // FILE_ATTRIBUTE_TEMPORARY
+	SparseFile        = 0x00000200 // FILE_ATTRIBUTE_SPARSE_FILE
+	ReparsePoint      = 0x00000400 // FILE_ATTRIBUTE_REPARSE_POINT
+	Compressed        = 0x00000800 // FILE_ATTRIBUTE_COMPRESSED
+	Offline           = 0x00001000 // FILE_ATTRIBUTE_OFFLINE
+	NotContentIndexed = 0x00002000 // FILE_ATTRIBUTE_NOT_CONTENT_INDEXED
+	Encrypted         = 0x00004000 // FILE_ATTRIBUTE_ENCRYPTED
+	IntegrityStream   = 0x00008000 // FILE_ATTRIBUTE_INTEGRITY_STREAM
+	NoScrubData       = 0x00020000 // FILE_ATTRIBUTE_NO_SCRUB_DATA
+)
+
+var attributeNames = []struct {
+	Attr Attributes
+	Name string
+}{
+	{ReadOnly, "ReadOnly"},
+	{Hidden, "Hidden"},
+	{System, "System"},
+	{Directory, "Directory"},
+	{Archive, "Archive"},
+	{Normal, "Normal"},
+	{Temporary, "Temporary"},
+	{SparseFile, "SparseFile"},
+	{ReparsePoint, "ReparsePoint"},
+	{Compressed, "Compressed"},
+	{Offline, "Offline"},
+	{NotContentIndexed, "NotContentIndexed"},
+	{Encrypted, "Encrypted"},
+	{IntegrityStream, "IntegrityStream"},
+	{NoScrubData, "NoScrubData"},
+}
+
+// Match reports whether a contains all of the attributes specified by c.
+func (a Attributes) Match(c Attributes) bool {
+	return a&c == c
+}
+
+// String returns a string representation of the attributes.
+func (a Attributes) String() string {
+	var names []string
+	for _, entry := range attributeNames {
+		if a.Match(entry.Attr) {
+			names = append(names, entry.Name)
+		}
+	}
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,3 @@
+// Package smbfile defines file identifiers and related structures for the
+// SMB protocol.
+package smbfile
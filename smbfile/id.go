@@ -0,0 +1,56 @@
+package smbfile
+
+import (
+	"strconv"
+
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// IDSize is the number of bytes used to encode a file ID.
+const IDSize = 16
+
+// RelatedID is the file ID used by related operations in a compounded
+// request chain. It refers to the file ID of the previous operation in the
+// chain.
+var RelatedID = ID{Persistent: 0xFFFFFFFFFFFFFFFF, Volatile: 0xFFFFFFFFFFFFFFFF}
+
+// ID identifies an open file on the server. It is composed of a persistent
+// portion that survives reconnection and a volatile portion that may change.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/f1d9b40d-e335-45fc-9d0b-199a31ede4c3
+type ID struct {
+	Persistent uint64
+	Volatile   uint64
+}
+
+// Read interprets a slice of bytes as an SMB2_FILEID structure and copies the
+// value to id. If v is less than 16 bytes long Read will panic.
+func (id *ID) Read(v []byte) {
+	_ = v[15] // bounds check hint to compiler; see golang.org/issue/14808
+	id.Persistent = smbtype.Uint64(v[0:8])
+	id.Volatile = smbtype.Uint64(v[8:16])
+}
+
+// Write writes id as an SMB2_FILEID structure to v. If v is less than 16
+// bytes long Write will panic.
+func (id ID) Write(v []byte) {
+	_ = v[15] // Early bounds check to guarantee safety of writes below
+	smbtype.PutUint64(v[0:8], id.Persistent)
+	smbtype.PutUint64(v[8:16], id.Volatile)
+}
+
+// IsRelated returns true if id refers to the file ID of the previous
+// operation in a compounded request chain.
+func (id ID) IsRelated() bool {
+	return id == RelatedID
+}
+
+// IsZero returns true if id is the zero value.
+func (id ID) IsZero() bool {
+	return id == ID{}
+}
+
+// String returns a string representation of the ID.
+func (id ID) String() string {
+	return strconv.FormatUint(id.Persistent, 16) + ":" + strconv.FormatUint(id.Volatile, 16)
+}
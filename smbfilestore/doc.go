@@ -0,0 +1,4 @@
+// Package smbfilestore provides an smbstore.Store implementation that keeps
+// each persistent handle in a file within a directory of the local
+// operating system's file system.
+package smbfilestore
@@ -0,0 +1,198 @@
+package smbfilestore
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smblease"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbstore"
+)
+
+// ErrInvalidHandle is returned when a handle file can't be decoded.
+var ErrInvalidHandle = errors.New("smbfilestore: invalid handle file")
+
+// version is the version of the encoding of handle files, which is the
+// first byte of each file.
+const version = 1
+
+// Flags recorded in handle files.
+const (
+	flagDirectory     = 1 << 0
+	flagDeleteOnClose = 1 << 1
+	flagLease         = 1 << 2
+	flagLeaseParent   = 1 << 3
+)
+
+// encode returns the contents of the file that holds h. All values are
+// little-endian, and variable-length values are preceded by their length.
+func encode(h smbstore.Handle) ([]byte, error) {
+	if len(h.Owner) > math.MaxUint16 || len(h.Share) > math.MaxUint16 || len(h.Name) > math.MaxUint16 {
+		return nil, ErrInvalidHandle
+	}
+	var flags uint8
+	if h.Directory {
+		flags |= flagDirectory
+	}
+	if h.DeleteOnClose {
+		flags |= flagDeleteOnClose
+	}
+	if h.Lease != nil {
+		flags |= flagLease
+		if h.Lease.HasParent {
+			flags |= flagLeaseParent
+		}
+	}
+
+	le := binary.LittleEndian
+	b := []byte{version, flags}
+	b = le.AppendUint64(b, h.FileID.Persistent)
+	b = le.AppendUint64(b, h.FileID.Volatile)
+	b = append(b, h.CreateGUID[:]...)
+	b = append(b, h.ClientGUID[:]...)
+	b = le.AppendUint16(b, uint16(len(h.Owner)))
+	b = append(b, h.Owner...)
+	b = le.AppendUint16(b, uint16(len(h.Share)))
+	b = append(b, h.Share...)
+	b = le.AppendUint16(b, uint16(len(h.Name)))
+	b = append(b, h.Name...)
+	b = le.AppendUint32(b, uint32(h.GrantedAccess))
+	b = le.AppendUint32(b, uint32(h.ShareAccess))
+	b = le.AppendUint64(b, uint64(h.Timeout))
+	if l := h.Lease; l != nil {
+		b = append(b, l.Key[:]...)
+		b = append(b, l.ParentKey[:]...)
+		b = le.AppendUint32(b, uint32(l.Version))
+		b = le.AppendUint32(b, uint32(l.State))
+		b = le.AppendUint16(b, l.Epoch)
+	}
+	b = le.AppendUint32(b, uint32(len(h.Locks)))
+	for _, l := range h.Locks {
+		b = le.AppendUint64(b, l.Offset)
+		b = le.AppendUint64(b, l.Length)
+		if l.Exclusive {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	}
+	b = le.AppendUint32(b, uint32(len(h.LockSequences)))
+	for _, s := range h.LockSequences {
+		b = le.AppendUint32(b, uint32(s.Index))
+		b = append(b, s.Number)
+	}
+	return b, nil
+}
+
+// decode returns the handle held in the contents of a handle file.
+func decode(data []byte) (smbstore.Handle, error) {
+	d := decoder{data: data}
+	if d.uint8() != version {
+		return smbstore.Handle{}, ErrInvalidHandle
+	}
+	flags := d.uint8()
+
+	var h smbstore.Handle
+	h.FileID.Persistent = d.uint64()
+	h.FileID.Volatile = d.uint64()
+	h.CreateGUID = d.id()
+	h.ClientGUID = d.id()
+	if owner := d.bytes(int(d.uint16())); len(owner) > 0 {
+		h.Owner = smbsecurity.SID(append([]byte(nil), owner...))
+	}
+	h.Share = string(d.bytes(int(d.uint16())))
+	h.Name = string(d.bytes(int(d.uint16())))
+	h.Directory = flags&flagDirectory != 0
+	h.GrantedAccess = smbaccess.Mask(d.uint32())
+	h.ShareAccess = smbcreate.ShareAccess(d.uint32())
+	h.DeleteOnClose = flags&flagDeleteOnClose != 0
+	h.Timeout = time.Duration(d.uint64())
+	if flags&flagLease != 0 {
+		h.Lease = &smbstore.Lease{
+			Key:       d.id(),
+			ParentKey: d.id(),
+			HasParent: flags&flagLeaseParent != 0,
+			Version:   int(d.uint32()),
+			State:     smblease.State(d.uint32()),
+			Epoch:     d.uint16(),
+		}
+	}
+	for n := d.count(17); n > 0; n-- {
+		h.Locks = append(h.Locks, smbstore.Lock{Offset: d.uint64(), Length: d.uint64(), Exclusive: d.uint8() != 0})
+	}
+	for n := d.count(5); n > 0; n-- {
+		h.LockSequences = append(h.LockSequences, smbstore.LockSequence{Index: int(d.uint32()), Number: d.uint8()})
+	}
+	if d.invalid || len(d.data) != 0 {
+		return smbstore.Handle{}, ErrInvalidHandle
+	}
+	return h, nil
+}
+
+// decoder reads little-endian values from the contents of a handle file.
+// Reads beyond the end of the data return zero values and mark the
+// decoder invalid.
+type decoder struct {
+	data    []byte
+	invalid bool
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if n > len(d.data) {
+		d.invalid, d.data = true, nil
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uint8() uint8 {
+	if b := d.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if b := d.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) id() (id smbid.ID) {
+	copy(id[:], d.bytes(len(id)))
+	return id
+}
+
+// count reads the number of entries in a list whose entries are size
+// bytes long. It marks the decoder invalid if the entries would run past
+// the end of the data.
+func (d *decoder) count(size int) int {
+	n := int(d.uint32())
+	if n > len(d.data)/size {
+		d.invalid, d.data = true, nil
+		return 0
+	}
+	return n
+}
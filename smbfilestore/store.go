@@ -0,0 +1,131 @@
+package smbfilestore
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gentlemanautomaton/smb/smbstore"
+)
+
+// extension is the file name extension of handle files.
+const extension = ".handle"
+
+// Store is a persistent handle store backed by a directory. Each handle is
+// written to its own file, which is replaced atomically when the handle
+// changes. It must be created by calling New.
+type Store struct {
+	dir string
+}
+
+// New returns a store that keeps handles in the given directory. The
+// directory is created if it doesn't exist.
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Store{dir: filepath.Clean(dir)}, nil
+}
+
+// Dir returns the directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save writes h to the store, replacing any handle with the same persistent
+// file ID. The handle is written to a temporary file that is synced and
+// then renamed over the previous version, after which the directory is
+// synced so that the rename survives a crash.
+func (s *Store) Save(h smbstore.Handle) error {
+	data, err := encode(h)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path(h.FileID.Persistent)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return s.sync()
+}
+
+// Delete removes the handle with the given persistent file ID from the
+// store.
+func (s *Store) Delete(persistent uint64) error {
+	err := os.Remove(s.path(persistent))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Load returns every handle in the store. Temporary files left behind by
+// interrupted saves are removed.
+func (s *Store) Load() ([]smbstore.Handle, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var handles []smbstore.Handle
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "tmp-") {
+			os.Remove(filepath.Join(s.dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, extension) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		h, err := decode(data)
+		if err != nil {
+			return nil, err
+		}
+		handles = append(handles, h)
+	}
+	return handles, nil
+}
+
+// path returns the path of the file that holds the handle with the given
+// persistent file ID.
+func (s *Store) path(persistent uint64) string {
+	return filepath.Join(s.dir, strconv.FormatUint(persistent, 16)+extension)
+}
+
+// sync commits the entries of the directory of the store to stable
+// storage.
+func (s *Store) sync() error {
+	dir, err := os.Open(s.dir)
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if cerr := dir.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
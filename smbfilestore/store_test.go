@@ -0,0 +1,122 @@
+package smbfilestore_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbfilestore"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smblease"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbstore"
+)
+
+func TestStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "handles")
+	store, err := smbfilestore.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := smbstore.Handle{
+		FileID:        smbfile.ID{Persistent: 1, Volatile: 1},
+		CreateGUID:    smbid.ID{1},
+		ClientGUID:    smbid.ID{2},
+		Owner:         smbsecurity.UnixUser(1000),
+		Share:         "Data",
+		Name:          "dir/doc.txt",
+		GrantedAccess: smbaccess.ReadData | smbaccess.WriteData,
+		ShareAccess:   smbcreate.ShareRead,
+		Timeout:       time.Minute,
+		Lease: &smbstore.Lease{
+			Key:       smbid.ID{3},
+			ParentKey: smbid.ID{4},
+			HasParent: true,
+			Version:   2,
+			State:     smblease.ReadCaching | smblease.HandleCaching,
+			Epoch:     4,
+		},
+		Locks:         []smbstore.Lock{{Offset: 10, Length: 5, Exclusive: true}},
+		LockSequences: []smbstore.LockSequence{{Index: 3, Number: 7}},
+	}
+	second := smbstore.Handle{
+		FileID:        smbfile.ID{Persistent: 0x1f, Volatile: 0x1f},
+		Share:         "Data",
+		Name:          "dir",
+		Directory:     true,
+		DeleteOnClose: true,
+	}
+	for _, h := range []smbstore.Handle{first, second} {
+		if err := store.Save(h); err != nil {
+			t.Fatalf("Save returned %v", err)
+		}
+	}
+
+	// Saving a handle again replaces it
+	first.Locks = nil
+	if err := store.Save(first); err != nil {
+		t.Fatalf("Save returned %v", err)
+	}
+
+	// Temporary files left behind by interrupted saves are ignored
+	if err := os.WriteFile(filepath.Join(dir, "tmp-123"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := smbfilestore.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handles, err := reopened.Load()
+	if err != nil {
+		t.Fatalf("Load returned %v", err)
+	}
+	if want := []smbstore.Handle{first, second}; !reflect.DeepEqual(handles, want) {
+		t.Errorf("Load returned %+v (want %+v)", handles, want)
+	}
+
+	if err := reopened.Delete(first.FileID.Persistent); err != nil {
+		t.Fatalf("Delete returned %v", err)
+	}
+	if err := reopened.Delete(first.FileID.Persistent); err != nil {
+		t.Errorf("Delete of a missing handle returned %v", err)
+	}
+	handles, err = reopened.Load()
+	if err != nil {
+		t.Fatalf("Load returned %v", err)
+	}
+	if want := []smbstore.Handle{second}; !reflect.DeepEqual(handles, want) {
+		t.Errorf("Load after Delete returned %+v (want %+v)", handles, want)
+	}
+}
+
+func TestStoreInvalidHandle(t *testing.T) {
+	dir := t.TempDir()
+	store, err := smbfilestore.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(smbstore.Handle{FileID: smbfile.ID{Persistent: 1}, Name: "doc.txt"}); err != nil {
+		t.Fatalf("Save returned %v", err)
+	}
+	path := filepath.Join(dir, "1.handle")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, corrupt := range [][]byte{data[:len(data)-1], append(data, 0), append([]byte{0}, data[1:]...)} {
+		if err := os.WriteFile(path, corrupt, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Load(); !errors.Is(err, smbfilestore.ErrInvalidHandle) {
+			t.Errorf("Load of a corrupt handle returned %v (want %v)", err, smbfilestore.ErrInvalidHandle)
+		}
+	}
+}
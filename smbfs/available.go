@@ -0,0 +1,10 @@
+package smbfs
+
+// ContinuouslyAvailable is a FileSystem that backs a continuously available
+// share. Opens within such file systems may be made persistent, which lets
+// their state survive a restart of the server.
+type ContinuouslyAvailable interface {
+	// ContinuouslyAvailable returns true if the file system is
+	// continuously available.
+	ContinuouslyAvailable() bool
+}
@@ -0,0 +1,20 @@
+package smbfs
+
+import "errors"
+
+// ErrCopyNotSupported is returned by file systems that are unable to copy
+// data between two files without reading it.
+var ErrCopyNotSupported = errors.New("smbfs: copying between files is not supported")
+
+// RangeCopier is a FileSystem that can copy data between files on behalf
+// of the server, which lets the copy be offloaded to the backend.
+type RangeCopier interface {
+	// CopyRange copies length bytes from src at srcOffset to dst at
+	// dstOffset and returns the number of bytes copied. If src ends before
+	// length bytes have been copied it returns io.EOF.
+	//
+	// If the file system is unable to copy between the files it returns
+	// ErrCopyNotSupported without copying anything, and the server copies
+	// the data itself.
+	CopyRange(dst File, dstOffset int64, src File, srcOffset int64, length int64) (int64, error)
+}
@@ -0,0 +1,10 @@
+// Package smbfs defines the file system interfaces that back SMB shares.
+//
+// A file system only needs to implement the FileSystem interface. Optional
+// features are provided by implementing additional interfaces, such as
+// Watcher, which are discovered with type assertions.
+//
+// File names are slash-separated paths relative to the root of the file
+// system, in the same form as those used by the io/fs package. The root of
+// the file system is named ".".
+package smbfs
@@ -0,0 +1,45 @@
+package smbfs
+
+import (
+	"io"
+	"os"
+)
+
+// FileSystem is a hierarchical file system that backs an SMB share.
+type FileSystem interface {
+	// OpenFile opens the named file with the given flags and permissions,
+	// in the same manner as os.OpenFile.
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+
+	// Stat returns information about the named file.
+	Stat(name string) (os.FileInfo, error)
+
+	// Mkdir creates a directory with the given name and permissions.
+	Mkdir(name string, perm os.FileMode) error
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+
+	// Rename renames a file or directory.
+	Rename(oldname, newname string) error
+}
+
+// File is an open file or directory within a FileSystem.
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+
+	// Stat returns information about the file.
+	Stat() (os.FileInfo, error)
+
+	// Readdir reads the contents of a directory in the same manner as
+	// os.File.Readdir.
+	Readdir(n int) ([]os.FileInfo, error)
+
+	// Truncate changes the size of the file.
+	Truncate(size int64) error
+
+	// Sync commits the contents of the file to stable storage.
+	Sync() error
+}
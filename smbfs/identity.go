@@ -0,0 +1,21 @@
+package smbfs
+
+import "os"
+
+// Identity uniquely identifies a file within a file system, independently
+// of the names by which it is known.
+type Identity struct {
+	Device uint64
+	Inode  uint64
+}
+
+// Identifier is a FileSystem that can identify files independently of their
+// names. Opens of files with the same identity share state such as share
+// access and byte-range locks, even when the files are opened by different
+// names.
+type Identifier interface {
+	// Identify returns the identity of the file described by info, which
+	// was returned by the file system. It returns false if the file can't
+	// be identified.
+	Identify(info os.FileInfo) (Identity, bool)
+}
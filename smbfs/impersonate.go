@@ -0,0 +1,34 @@
+package smbfs
+
+import (
+	"errors"
+
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+)
+
+// ErrImpersonationNotSupported is returned by file systems that can't
+// perform operations with the credentials of other users.
+var ErrImpersonationNotSupported = errors.New("smbfs: impersonation is not supported")
+
+// Impersonator is a FileSystem that can perform operations with the
+// credentials of the users that request them, so that the permissions of
+// the underlying storage are enforced for the users of a share.
+type Impersonator interface {
+	// Impersonate returns a file system whose operations are performed on
+	// behalf of the user represented by token. Files opened through it
+	// keep the access they were opened with.
+	//
+	// The returned file system is either the impersonator itself, or a
+	// View of it.
+	Impersonate(token *smbsecurity.Token) (FileSystem, error)
+}
+
+// View is a FileSystem that presents another file system to a particular
+// user. Files opened through different views of the same file system are
+// the same files.
+type View interface {
+	FileSystem
+
+	// Base returns the file system that the view presents.
+	Base() FileSystem
+}
@@ -0,0 +1,21 @@
+package smbfs
+
+import "os"
+
+// Linker is a FileSystem that supports symbolic links. The server doesn't
+// follow symbolic links within such file systems. Instead it checks each
+// element of a name with Lstat before the name is opened, and reports the
+// links it finds to clients, which follow them on their side.
+type Linker interface {
+	// Lstat returns information about the named file. If the file is a
+	// symbolic link the information describes the link.
+	Lstat(name string) (os.FileInfo, error)
+
+	// Readlink returns the target of the named symbolic link. Targets are
+	// slash-separated, and relative targets are relative to the directory
+	// that holds the link.
+	Readlink(name string) (string, error)
+
+	// Symlink creates newname as a symbolic link to target.
+	Symlink(target, newname string) error
+}
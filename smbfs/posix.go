@@ -0,0 +1,21 @@
+package smbfs
+
+import "os"
+
+// Ownership describes the POSIX owner, group and hard link count of a
+// file.
+type Ownership struct {
+	UID   uint32
+	GID   uint32
+	Links uint32
+}
+
+// Owner is a FileSystem that can report the POSIX ownership of its files.
+// It is used to describe files to clients that have negotiated the SMB3
+// POSIX extensions.
+type Owner interface {
+	// Ownership returns the ownership of the file described by info, which
+	// was returned by the file system. It returns false if the ownership
+	// of the file isn't known.
+	Ownership(info os.FileInfo) (Ownership, bool)
+}
@@ -0,0 +1,15 @@
+package smbfs
+
+import "github.com/gentlemanautomaton/smb/smbsecurity"
+
+// SecurityDescriptors is a FileSystem that stores a Windows security
+// descriptor for each of its files. Files within other file systems are
+// reported to have a default security descriptor that can't be changed.
+type SecurityDescriptors interface {
+	// Security returns the security descriptor of the named file.
+	Security(name string) (*smbsecurity.Descriptor, error)
+
+	// SetSecurity replaces the parts of the security descriptor of the
+	// named file that are selected by info with those of sd.
+	SetSecurity(name string, info smbsecurity.Information, sd *smbsecurity.Descriptor) error
+}
@@ -0,0 +1,27 @@
+package smbfs
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSnapshotNotFound is returned by file systems that don't have a
+// requested snapshot.
+var ErrSnapshotNotFound = errors.New("smbfs: snapshot not found")
+
+// Snapshotter is a FileSystem that keeps snapshots of its previous states.
+// Clients present them as previous versions of files.
+type Snapshotter interface {
+	// Snapshots returns the times at which the snapshots of the file
+	// system were taken.
+	Snapshots() ([]time.Time, error)
+
+	// Snapshot returns a read-only file system that holds the state of
+	// the file system when the snapshot taken at t was taken. Snapshot
+	// times have a precision of one second. If there is no such snapshot
+	// it returns ErrSnapshotNotFound.
+	//
+	// Each snapshot must be returned as the same file system each time it
+	// is requested, so that opens of its files share state.
+	Snapshot(t time.Time) (FileSystem, error)
+}
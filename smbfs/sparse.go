@@ -0,0 +1,32 @@
+package smbfs
+
+import "errors"
+
+// ErrSparseNotSupported is returned by file systems that are unable to
+// manage sparse files.
+var ErrSparseNotSupported = errors.New("smbfs: sparse files are not supported")
+
+// Range is a range of bytes within a file.
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+// SparseFiles is a FileSystem that supports sparse files, in which ranges
+// of zeros need not be allocated.
+type SparseFiles interface {
+	// Sparse returns true if f is a sparse file.
+	Sparse(f File) (bool, error)
+
+	// SetSparse determines whether f is a sparse file. Any unallocated
+	// ranges of a file that stops being sparse are allocated.
+	SetSparse(f File, sparse bool) error
+
+	// ZeroRange sets length bytes of f starting at offset to zero without
+	// changing the size of f. The range is deallocated if f is sparse.
+	ZeroRange(f File, offset, length int64) error
+
+	// AllocatedRanges returns the allocated ranges of f that fall within
+	// length bytes starting at offset, in order.
+	AllocatedRanges(f File, offset, length int64) ([]Range, error)
+}
@@ -0,0 +1,35 @@
+package smbfs
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	// ErrStreamsNotSupported is returned by file systems that are unable
+	// to store alternate data streams for a file.
+	ErrStreamsNotSupported = errors.New("smbfs: alternate data streams are not supported")
+
+	// ErrStreamTooLarge is returned when an alternate data stream can't
+	// hold any more data.
+	ErrStreamTooLarge = errors.New("smbfs: the stream is too large")
+)
+
+// Streamer is a FileSystem that supports alternate data streams, which are
+// named streams of data that belong to a file in addition to its contents.
+// Stream names are never empty, and don't contain colons, path separators
+// or null characters.
+type Streamer interface {
+	// OpenStream opens the named stream of the named file with the given
+	// flags, in the same manner as OpenFile. The file must exist. The
+	// information returned by the Stat method of the stream describes the
+	// stream, but identifies the file it belongs to.
+	OpenStream(name, stream string, flag int) (File, error)
+
+	// Streams returns information about the alternate data streams of the
+	// named file, whose names are the names of the streams.
+	Streams(name string) ([]os.FileInfo, error)
+
+	// RemoveStream removes the named stream of the named file.
+	RemoveStream(name, stream string) error
+}
@@ -0,0 +1,47 @@
+package smbfs
+
+import (
+	"errors"
+
+	"github.com/gentlemanautomaton/smb/smbnotify"
+)
+
+// ErrWatchNotSupported is returned by file systems that are unable to watch
+// a directory for changes.
+var ErrWatchNotSupported = errors.New("smbfs: watching for changes is not supported")
+
+// Watcher is a FileSystem that can report changes to directories.
+type Watcher interface {
+	// Watch starts watching the named directory for changes. If recursive
+	// is true changes within subdirectories are reported as well.
+	Watch(name string, recursive bool) (Watch, error)
+}
+
+// Watch is an active watch on a directory. It must be closed when it is no
+// longer needed.
+type Watch interface {
+	// Events returns a channel that receives change events. The channel is
+	// closed when the watch is closed.
+	Events() <-chan Event
+
+	// Close stops the watch and releases its resources.
+	Close() error
+}
+
+// Event describes a change to an entry within a watched directory.
+type Event struct {
+	// Action is the type of change.
+	Action smbnotify.Action
+
+	// Filter is the set of completion filter flags that the change matches.
+	Filter smbnotify.Filter
+
+	// Name is the slash-separated path of the entry that changed, relative
+	// to the watched directory.
+	Name string
+
+	// Overflow is true if events have been lost. When it is set the other
+	// fields are meaningless and the client should enumerate the directory
+	// again.
+	Overflow bool
+}
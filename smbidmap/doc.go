@@ -0,0 +1,4 @@
+// Package smbidmap maps Windows security principals to POSIX identities, so
+// that files accessed over SMB can be owned by and checked against the
+// users and groups of the local system.
+package smbidmap
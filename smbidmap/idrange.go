@@ -0,0 +1,70 @@
+package smbidmap
+
+import "github.com/gentlemanautomaton/smb/smbsecurity"
+
+// DomainUsers is the relative identifier of the Domain Users group, which
+// is the primary group of domain accounts.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.2.4
+const DomainUsers = 513
+
+// Range is a mapper that maps the principals of a domain algorithmically
+// to a range of POSIX IDs, in the manner of the idmap_rid backend of
+// Samba. A principal with relative identifier RID is mapped to Base+RID
+// both as a user ID and as a group ID, so every server configured with
+// the same range agrees on the mapping without sharing any state.
+//
+// Principals outside of the domain, and principals whose relative
+// identifiers are not less than Size, aren't mapped.
+type Range struct {
+	Domain smbsecurity.SID // The SID of the domain
+	Base   uint32          // The first ID in the range
+	Size   uint32          // The number of IDs in the range
+
+	// PrimaryGroup is the relative identifier of the group that is the
+	// primary group of every user. If it is zero, DomainUsers is used.
+	PrimaryGroup uint32
+}
+
+// User returns the user ID and primary group ID of the user identified by
+// sid.
+func (r Range) User(sid smbsecurity.SID) (uid, gid uint32, err error) {
+	uid, err = r.id(sid)
+	if err != nil {
+		return 0, 0, err
+	}
+	primary := r.PrimaryGroup
+	if primary == 0 {
+		primary = DomainUsers
+	}
+	if primary >= r.Size {
+		return 0, 0, ErrNotMapped
+	}
+	return uid, r.Base + primary, nil
+}
+
+// Group returns the group ID of the group identified by sid.
+func (r Range) Group(sid smbsecurity.SID) (gid uint32, err error) {
+	return r.id(sid)
+}
+
+// id returns the ID that sid maps to.
+func (r Range) id(sid smbsecurity.SID) (uint32, error) {
+	if !sid.Valid() || !r.Domain.Valid() {
+		return 0, ErrNotMapped
+	}
+	n := r.Domain.SubAuthorityCount()
+	if sid.SubAuthorityCount() != n+1 || sid.Authority() != r.Domain.Authority() {
+		return 0, ErrNotMapped
+	}
+	for i := 0; i < n; i++ {
+		if sid.SubAuthority(i) != r.Domain.SubAuthority(i) {
+			return 0, ErrNotMapped
+		}
+	}
+	rid := sid.RID()
+	if rid >= r.Size || uint64(r.Base)+uint64(rid) > 1<<32-1 {
+		return 0, ErrNotMapped
+	}
+	return r.Base + rid, nil
+}
@@ -0,0 +1,98 @@
+package smbidmap
+
+import (
+	"errors"
+
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+)
+
+// ErrNotMapped is returned by mappers that don't have a POSIX identity for
+// a principal.
+var ErrNotMapped = errors.New("smbidmap: the principal is not mapped to a POSIX identity")
+
+// Mapper maps the security identifiers of Windows principals to POSIX user
+// and group IDs.
+type Mapper interface {
+	// User returns the user ID and primary group ID of the user identified
+	// by sid. If the user isn't mapped it returns ErrNotMapped.
+	User(sid smbsecurity.SID) (uid, gid uint32, err error)
+
+	// Group returns the group ID of the group identified by sid. If the
+	// group isn't mapped it returns ErrNotMapped.
+	Group(sid smbsecurity.SID) (gid uint32, err error)
+}
+
+// Credentials are the POSIX credentials that operations are performed
+// with on behalf of a user.
+type Credentials struct {
+	UID    uint32
+	GID    uint32
+	Groups []uint32 // Supplementary group IDs
+}
+
+// Map returns the credentials of the user represented by token. The groups
+// of the token that m maps become the supplementary groups of the
+// credentials, and the rest are ignored. If the user of the token isn't
+// mapped it returns ErrNotMapped.
+func Map(m Mapper, token *smbsecurity.Token) (Credentials, error) {
+	uid, gid, err := m.User(token.User)
+	if err != nil {
+		return Credentials{}, err
+	}
+	cred := Credentials{UID: uid, GID: gid}
+	for _, sid := range token.Groups {
+		group, err := m.Group(sid)
+		switch {
+		case errors.Is(err, ErrNotMapped):
+			continue
+		case err != nil:
+			return Credentials{}, err
+		}
+		if !cred.hasGroup(group) {
+			cred.Groups = append(cred.Groups, group)
+		}
+	}
+	return cred, nil
+}
+
+// hasGroup returns true if gid is the primary or a supplementary group of
+// the credentials.
+func (c Credentials) hasGroup(gid uint32) bool {
+	if c.GID == gid {
+		return true
+	}
+	for _, group := range c.Groups {
+		if group == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// Chain is a mapper that consults a list of mappers in order, and returns
+// the first identity that is mapped.
+type Chain []Mapper
+
+// User returns the user ID and primary group ID of the first mapper in the
+// chain that maps the user identified by sid.
+func (chain Chain) User(sid smbsecurity.SID) (uid, gid uint32, err error) {
+	for _, m := range chain {
+		uid, gid, err = m.User(sid)
+		if !errors.Is(err, ErrNotMapped) {
+			return uid, gid, err
+		}
+	}
+	return 0, 0, ErrNotMapped
+}
+
+// Group returns the group ID of the first mapper in the chain that maps
+// the group identified by sid.
+func (chain Chain) Group(sid smbsecurity.SID) (gid uint32, err error) {
+	for _, m := range chain {
+		gid, err = m.Group(sid)
+		if !errors.Is(err, ErrNotMapped) {
+			return gid, err
+		}
+	}
+	return 0, ErrNotMapped
+}
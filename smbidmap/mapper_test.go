@@ -0,0 +1,79 @@
+package smbidmap_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbidmap"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+)
+
+var domain = smbsecurity.NewSID(5, 21, 1, 2, 3)
+
+func TestStatic(t *testing.T) {
+	table, err := smbidmap.ParseStatic(strings.NewReader(`
+# Users
+user  S-1-5-21-1-2-3-1000 1000 1000
+group S-1-5-21-1-2-3-513  100
+group S-1-1-0             65534
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := &smbsecurity.Token{
+		User:   smbsecurity.NewSID(5, 21, 1, 2, 3, 1000),
+		Groups: []smbsecurity.SID{smbsecurity.NewSID(5, 21, 1, 2, 3, 513), smbsecurity.Everyone, smbsecurity.AuthenticatedUsers},
+	}
+	cred, err := smbidmap.Map(table, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.UID != 1000 || cred.GID != 1000 || len(cred.Groups) != 2 || cred.Groups[0] != 100 || cred.Groups[1] != 65534 {
+		t.Fatalf("mapped credentials are %+v", cred)
+	}
+
+	if _, err := smbidmap.Map(table, &smbsecurity.Token{User: smbsecurity.Anonymous}); !errors.Is(err, smbidmap.ErrNotMapped) {
+		t.Fatalf("map of an unknown user returned %v", err)
+	}
+
+	for _, bad := range []string{"user S-1-5-21-1 1000", "group S-1-5-21-1 x", "member S-1-5-21-1 1", "group S-1-5 100 100"} {
+		if _, err := smbidmap.ParseStatic(strings.NewReader(bad)); err == nil {
+			t.Errorf("parse of %q succeeded", bad)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	r := smbidmap.Range{Domain: domain, Base: 100000, Size: 100000}
+
+	uid, gid, err := r.User(smbsecurity.NewSID(5, 21, 1, 2, 3, 1104))
+	if err != nil || uid != 101104 || gid != 100513 {
+		t.Fatalf("user mapped to %d:%d (%v)", uid, gid, err)
+	}
+	if gid, err := r.Group(smbsecurity.NewSID(5, 21, 1, 2, 3, 512)); err != nil || gid != 100512 {
+		t.Fatalf("group mapped to %d (%v)", gid, err)
+	}
+	for _, sid := range []smbsecurity.SID{
+		smbsecurity.NewSID(5, 21, 1, 2, 4, 1104),
+		smbsecurity.NewSID(5, 21, 1, 2, 3, 100000),
+		smbsecurity.NewSID(5, 21, 1, 2, 3),
+		smbsecurity.Administrators,
+	} {
+		if _, _, err := r.User(sid); !errors.Is(err, smbidmap.ErrNotMapped) {
+			t.Errorf("map of %s returned %v", sid, err)
+		}
+	}
+
+	// A chain falls back to later mappers
+	var table smbidmap.Static
+	table.AddUser(smbsecurity.NewSID(5, 21, 9, 9, 9, 500), 0, 0)
+	chain := smbidmap.Chain{&table, r}
+	if uid, _, err := chain.User(smbsecurity.NewSID(5, 21, 9, 9, 9, 500)); err != nil || uid != 0 {
+		t.Fatalf("chain mapped the static user to %d (%v)", uid, err)
+	}
+	if uid, _, err := chain.User(smbsecurity.NewSID(5, 21, 1, 2, 3, 1000)); err != nil || uid != 101000 {
+		t.Fatalf("chain mapped the domain user to %d (%v)", uid, err)
+	}
+}
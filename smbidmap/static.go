@@ -0,0 +1,136 @@
+package smbidmap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+)
+
+// Static is a mapper that holds a fixed table of identities. It is
+// typically loaded from a file with LoadStatic.
+//
+// The zero value is an empty table that is ready to use. A Static must not
+// be modified while it is in use.
+type Static struct {
+	users  map[string]staticUser // Keyed by the string form of the SID
+	groups map[string]uint32     // Keyed by the string form of the SID
+}
+
+// staticUser is a user entry within a static table.
+type staticUser struct {
+	uid uint32
+	gid uint32
+}
+
+// LoadStatic reads a static table from the named file. The format of the
+// file is described by ParseStatic.
+func LoadStatic(name string) (*Static, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseStatic(f)
+}
+
+// ParseStatic reads a static table from r. Each line of the table maps a
+// user or a group, and lines that are blank or begin with # are ignored:
+//
+//	# kind  SID                      ID(s)
+//	user    S-1-5-21-1-2-3-1000      1000 1000
+//	group   S-1-5-21-1-2-3-513       100
+//
+// User lines give the user ID and primary group ID of the user. Group
+// lines give the group ID of the group.
+func ParseStatic(r io.Reader) (*Static, error) {
+	var table Static
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if err := table.parseLine(fields); err != nil {
+			return nil, fmt.Errorf("smbidmap: line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
+
+// parseLine adds the entry held by the fields of a line to the table.
+func (s *Static) parseLine(fields []string) error {
+	var ids []uint32
+	switch fields[0] {
+	case "user":
+		if len(fields) != 4 {
+			return fmt.Errorf("user entries have a SID, a user ID and a group ID")
+		}
+	case "group":
+		if len(fields) != 3 {
+			return fmt.Errorf("group entries have a SID and a group ID")
+		}
+	default:
+		return fmt.Errorf("unknown entry kind %q", fields[0])
+	}
+	sid, err := smbsecurity.ParseSID(fields[1])
+	if err != nil {
+		return err
+	}
+	for _, field := range fields[2:] {
+		id, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid ID %q", field)
+		}
+		ids = append(ids, uint32(id))
+	}
+	if fields[0] == "user" {
+		s.AddUser(sid, ids[0], ids[1])
+	} else {
+		s.AddGroup(sid, ids[0])
+	}
+	return nil
+}
+
+// AddUser maps the user identified by sid to the given user ID and primary
+// group ID.
+func (s *Static) AddUser(sid smbsecurity.SID, uid, gid uint32) {
+	if s.users == nil {
+		s.users = make(map[string]staticUser)
+	}
+	s.users[sid.String()] = staticUser{uid: uid, gid: gid}
+}
+
+// AddGroup maps the group identified by sid to the given group ID.
+func (s *Static) AddGroup(sid smbsecurity.SID, gid uint32) {
+	if s.groups == nil {
+		s.groups = make(map[string]uint32)
+	}
+	s.groups[sid.String()] = gid
+}
+
+// User returns the user ID and primary group ID of the user identified by
+// sid.
+func (s *Static) User(sid smbsecurity.SID) (uid, gid uint32, err error) {
+	user, ok := s.users[sid.String()]
+	if !ok || !sid.Valid() {
+		return 0, 0, ErrNotMapped
+	}
+	return user.uid, user.gid, nil
+}
+
+// Group returns the group ID of the group identified by sid.
+func (s *Static) Group(sid smbsecurity.SID) (gid uint32, err error) {
+	gid, ok := s.groups[sid.String()]
+	if !ok || !sid.Valid() {
+		return 0, ErrNotMapped
+	}
+	return gid, nil
+}
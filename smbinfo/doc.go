@@ -0,0 +1,3 @@
+// Package smbinfo provides types for SMB QUERY_INFO and SET_INFO requests
+// and responses.
+package smbinfo
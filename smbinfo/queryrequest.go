@@ -0,0 +1,173 @@
+package smbinfo
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// QueryRequestSize is the number of bytes required for the fixed portion of
+// an SMB QUERY_INFO request.
+const QueryRequestSize = 40
+
+// headerSize is the number of bytes in an SMB packet header. It's needed by
+// this package to calculate buffer offsets relative to the start of the
+// packet.
+const headerSize = 64
+
+// QueryRequest interprets a slice of bytes as an SMB QUERY_INFO request
+// packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.37
+type QueryRequest []byte
+
+// Valid returns true if the request is valid.
+func (r QueryRequest) Valid() bool {
+	if len(r) < QueryRequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 41
+	if r.Size() != 41 {
+		return false
+	}
+
+	// The input buffer must not overflow
+	if length := int(r.InputLength()); length > 0 {
+		start := int(r.InputOffset()) - headerSize
+		if start < QueryRequestSize || start+length > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r QueryRequest) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r QueryRequest) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// InfoType returns the type of information being queried.
+func (r QueryRequest) InfoType() Type {
+	return Type(r[2])
+}
+
+// SetInfoType sets the type of information being queried.
+func (r QueryRequest) SetInfoType(t Type) {
+	r[2] = byte(t)
+}
+
+// FileInfoClass returns the class of file or file system information being
+// queried. It is zero for security and quota information.
+func (r QueryRequest) FileInfoClass() uint8 {
+	return r[3]
+}
+
+// SetFileInfoClass sets the class of file or file system information being
+// queried.
+func (r QueryRequest) SetFileInfoClass(class uint8) {
+	r[3] = class
+}
+
+// OutputBufferLength returns the maximum number of bytes of information
+// that the server can return.
+func (r QueryRequest) OutputBufferLength() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetOutputBufferLength sets the maximum number of bytes of information
+// that the server can return.
+func (r QueryRequest) SetOutputBufferLength(length uint32) {
+	smbtype.PutUint32(r[4:8], length)
+}
+
+// InputOffset returns the offset of the input buffer in bytes from the
+// start of the packet header.
+func (r QueryRequest) InputOffset() uint16 {
+	return smbtype.Uint16(r[8:10])
+}
+
+// SetInputOffset sets the offset of the input buffer in bytes from the
+// start of the packet header.
+func (r QueryRequest) SetInputOffset(offset uint16) {
+	smbtype.PutUint16(r[8:10], offset)
+}
+
+// InputLength returns the length of the input buffer in bytes.
+func (r QueryRequest) InputLength() uint32 {
+	return smbtype.Uint32(r[12:16])
+}
+
+// SetInputLength sets the length of the input buffer in bytes.
+func (r QueryRequest) SetInputLength(length uint32) {
+	smbtype.PutUint32(r[12:16], length)
+}
+
+// AdditionalInformation returns additional information about the query.
+// For security information it holds the security information flags that
+// select the parts of the security descriptor being queried.
+func (r QueryRequest) AdditionalInformation() uint32 {
+	return smbtype.Uint32(r[16:20])
+}
+
+// SetAdditionalInformation sets additional information about the query.
+func (r QueryRequest) SetAdditionalInformation(info uint32) {
+	smbtype.PutUint32(r[16:20], info)
+}
+
+// Flags returns the flags of a quota query.
+func (r QueryRequest) Flags() uint32 {
+	return smbtype.Uint32(r[20:24])
+}
+
+// SetFlags sets the flags of a quota query.
+func (r QueryRequest) SetFlags(flags uint32) {
+	smbtype.PutUint32(r[20:24], flags)
+}
+
+// FileID returns the file ID of the file being queried.
+func (r QueryRequest) FileID() (id smbfile.ID) {
+	id.Read(r[24:40])
+	return
+}
+
+// SetFileID sets the file ID of the file being queried.
+func (r QueryRequest) SetFileID(id smbfile.ID) {
+	id.Write(r[24:40])
+}
+
+// Input returns the input buffer of the request.
+func (r QueryRequest) Input() []byte {
+	length := uint(r.InputLength())
+	if length == 0 {
+		return nil
+	}
+	start := uint(r.InputOffset()) - headerSize
+	end := start + length
+	return r[start:end:end]
+}
+
+// SetInputLayout sets the offset and length of the input buffer. The
+// buffer is placed immediately after the fixed portion of the request.
+// It returns the input buffer so that it can be populated.
+//
+// If the request is too small to hold length bytes the call will panic.
+func (r QueryRequest) SetInputLayout(length int) []byte {
+	if len(r)-QueryRequestSize < length {
+		panic("smbinfo: query request: input buffer is too large to fit in request")
+	}
+	if length == 0 {
+		r.SetInputOffset(0)
+		r.SetInputLength(0)
+		return nil
+	}
+	r.SetInputOffset(headerSize + QueryRequestSize)
+	r.SetInputLength(uint32(length))
+	end := QueryRequestSize + length
+	return r[QueryRequestSize:end:end]
+}
@@ -0,0 +1,93 @@
+package smbinfo
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// QueryResponseSize is the number of bytes required for the fixed portion
+// of an SMB QUERY_INFO response.
+const QueryResponseSize = 8
+
+// QueryResponse interprets a slice of bytes as an SMB QUERY_INFO response
+// packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.38
+type QueryResponse []byte
+
+// Valid returns true if the response is valid.
+func (r QueryResponse) Valid() bool {
+	if len(r) < QueryResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 9
+	if r.Size() != 9 {
+		return false
+	}
+
+	// The output buffer must not overflow
+	if length := int(r.OutputLength()); length > 0 {
+		start := int(r.OutputOffset()) - headerSize
+		if start < QueryResponseSize || start+length > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r QueryResponse) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r QueryResponse) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// OutputOffset returns the offset of the output buffer in bytes from the
+// start of the packet header.
+func (r QueryResponse) OutputOffset() uint16 {
+	return smbtype.Uint16(r[2:4])
+}
+
+// SetOutputOffset sets the offset of the output buffer in bytes from the
+// start of the packet header.
+func (r QueryResponse) SetOutputOffset(offset uint16) {
+	smbtype.PutUint16(r[2:4], offset)
+}
+
+// OutputLength returns the length of the output buffer in bytes.
+func (r QueryResponse) OutputLength() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetOutputLength sets the length of the output buffer in bytes.
+func (r QueryResponse) SetOutputLength(length uint32) {
+	smbtype.PutUint32(r[4:8], length)
+}
+
+// Output returns the information returned by the query.
+func (r QueryResponse) Output() []byte {
+	length := uint(r.OutputLength())
+	if length == 0 {
+		return nil
+	}
+	start := uint(r.OutputOffset()) - headerSize
+	end := start + length
+	return r[start:end:end]
+}
+
+// SetOutputLayout sets the offset and length of the output buffer. The
+// buffer is placed immediately after the fixed portion of the response.
+// It returns the output buffer so that it can be populated.
+//
+// If the response is too small to hold length bytes the call will panic.
+func (r QueryResponse) SetOutputLayout(length int) []byte {
+	if len(r)-QueryResponseSize < length {
+		panic("smbinfo: query response: output buffer is too large to fit in response")
+	}
+	r.SetOutputOffset(headerSize + QueryResponseSize)
+	r.SetOutputLength(uint32(length))
+	end := QueryResponseSize + length
+	return r[QueryResponseSize:end:end]
+}
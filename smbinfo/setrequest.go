@@ -0,0 +1,140 @@
+package smbinfo
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// SetRequestSize is the number of bytes required for the fixed portion of
+// an SMB SET_INFO request.
+const SetRequestSize = 32
+
+// SetRequest interprets a slice of bytes as an SMB SET_INFO request packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.39
+type SetRequest []byte
+
+// Valid returns true if the request is valid.
+func (r SetRequest) Valid() bool {
+	if len(r) < SetRequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 33
+	if r.Size() != 33 {
+		return false
+	}
+
+	// The buffer must not overflow
+	if length := int(r.BufferLength()); length > 0 {
+		start := int(r.BufferOffset()) - headerSize
+		if start < SetRequestSize || start+length > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r SetRequest) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r SetRequest) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// InfoType returns the type of information being set.
+func (r SetRequest) InfoType() Type {
+	return Type(r[2])
+}
+
+// SetInfoType sets the type of information being set.
+func (r SetRequest) SetInfoType(t Type) {
+	r[2] = byte(t)
+}
+
+// FileInfoClass returns the class of file or file system information being
+// set. It is zero for security and quota information.
+func (r SetRequest) FileInfoClass() uint8 {
+	return r[3]
+}
+
+// SetFileInfoClass sets the class of file or file system information being
+// set.
+func (r SetRequest) SetFileInfoClass(class uint8) {
+	r[3] = class
+}
+
+// BufferLength returns the length of the buffer in bytes.
+func (r SetRequest) BufferLength() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetBufferLength sets the length of the buffer in bytes.
+func (r SetRequest) SetBufferLength(length uint32) {
+	smbtype.PutUint32(r[4:8], length)
+}
+
+// BufferOffset returns the offset of the buffer in bytes from the start of
+// the packet header.
+func (r SetRequest) BufferOffset() uint16 {
+	return smbtype.Uint16(r[8:10])
+}
+
+// SetBufferOffset sets the offset of the buffer in bytes from the start of
+// the packet header.
+func (r SetRequest) SetBufferOffset(offset uint16) {
+	smbtype.PutUint16(r[8:10], offset)
+}
+
+// AdditionalInformation returns additional information about the request.
+// For security information it holds the security information flags that
+// select the parts of the security descriptor being set.
+func (r SetRequest) AdditionalInformation() uint32 {
+	return smbtype.Uint32(r[12:16])
+}
+
+// SetAdditionalInformation sets additional information about the request.
+func (r SetRequest) SetAdditionalInformation(info uint32) {
+	smbtype.PutUint32(r[12:16], info)
+}
+
+// FileID returns the file ID of the file whose information is being set.
+func (r SetRequest) FileID() (id smbfile.ID) {
+	id.Read(r[16:32])
+	return
+}
+
+// SetFileID sets the file ID of the file whose information is being set.
+func (r SetRequest) SetFileID(id smbfile.ID) {
+	id.Write(r[16:32])
+}
+
+// Buffer returns the information being set.
+func (r SetRequest) Buffer() []byte {
+	length := uint(r.BufferLength())
+	if length == 0 {
+		return nil
+	}
+	start := uint(r.BufferOffset()) - headerSize
+	end := start + length
+	return r[start:end:end]
+}
+
+// SetBufferLayout sets the offset and length of the buffer. The buffer is
+// placed immediately after the fixed portion of the request. It returns
+// the buffer so that it can be populated.
+//
+// If the request is too small to hold length bytes the call will panic.
+func (r SetRequest) SetBufferLayout(length int) []byte {
+	if len(r)-SetRequestSize < length {
+		panic("smbinfo: set request: buffer is too large to fit in request")
+	}
+	r.SetBufferOffset(headerSize + SetRequestSize)
+	r.SetBufferLength(uint32(length))
+	end := SetRequestSize + length
+	return r[SetRequestSize:end:end]
+}
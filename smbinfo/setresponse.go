@@ -0,0 +1,32 @@
+package smbinfo
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// SetResponseSize is the number of bytes in an SMB SET_INFO response.
+const SetResponseSize = 2
+
+// SetResponse interprets a slice of bytes as an SMB SET_INFO response
+// packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.40
+type SetResponse []byte
+
+// Valid returns true if the response is valid.
+func (r SetResponse) Valid() bool {
+	if len(r) < SetResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 2
+	return r.Size() == 2
+}
+
+// Size returns the structure size of the response.
+func (r SetResponse) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r SetResponse) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
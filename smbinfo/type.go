@@ -0,0 +1,32 @@
+package smbinfo
+
+import "strconv"
+
+// Type identifies the kind of information that is queried or set.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.37
+type Type uint8
+
+// Information types.
+const (
+	File       = 0x01 // SMB2_0_INFO_FILE
+	FileSystem = 0x02 // SMB2_0_INFO_FILESYSTEM
+	Security   = 0x03 // SMB2_0_INFO_SECURITY
+	Quota      = 0x04 // SMB2_0_INFO_QUOTA
+)
+
+// String returns a string representation of the information type.
+func (t Type) String() string {
+	switch t {
+	case File:
+		return "File"
+	case FileSystem:
+		return "FileSystem"
+	case Security:
+		return "Security"
+	case Quota:
+		return "Quota"
+	default:
+		return "Type " + strconv.Itoa(int(t))
+	}
+}
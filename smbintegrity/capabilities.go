@@ -10,6 +10,22 @@ import (
 // https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/5a07bd66-4734-4af8-abcf-5a44ff7ee0e5
 type Capabilities []byte
 
+// CapabilitiesSize is the number of bytes required for the fixed portion of
+// a set of preauthentication integrity capabilities.
+const CapabilitiesSize = 4
+
+// Valid returns true if the capabilities are valid. The algorithm list and
+// the salt must not overflow, and at least one algorithm must be present.
+func (c Capabilities) Valid() bool {
+	if len(c) < CapabilitiesSize {
+		return false
+	}
+	if c.AlgorithmCount() == 0 {
+		return false
+	}
+	return CapabilitiesSize+int(c.AlgorithmCount())*2+int(c.SaltLength()) <= len(c)
+}
+
 // AlgorithmCount returns the number of supported preauthentication hash
 // algorithms.
 func (c Capabilities) AlgorithmCount() uint16 {
@@ -16,6 +16,12 @@ func (k List) Member(i int) Algorithm {
 	return Algorithm(smbtype.Uint16(k[i : i+2]))
 }
 
+// SetMember updates the member of the list at position i.
+func (k List) SetMember(i int, a Algorithm) {
+	i *= 2
+	smbtype.PutUint16(k[i:i+2], uint16(a))
+}
+
 // Contains returns true if the list contains a.
 func (k List) Contains(a Algorithm) bool {
 	count := k.Count()
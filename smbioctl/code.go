@@ -0,0 +1,60 @@
+package smbioctl
+
+import "strconv"
+
+// Code is the control code of an IOCTL or FSCTL request.
+type Code uint32
+
+// Control codes.
+const (
+	SetReparsePoint           = 0x000900A4 // FSCTL_SET_REPARSE_POINT
+	GetReparsePoint           = 0x000900A8 // FSCTL_GET_REPARSE_POINT
+	SetSparse                 = 0x000900C4 // FSCTL_SET_SPARSE
+	SetZeroData               = 0x000980C8 // FSCTL_SET_ZERO_DATA
+	QueryAllocatedRanges      = 0x000940CF // FSCTL_QUERY_ALLOCATED_RANGES
+	PipePeek                  = 0x0011400C // FSCTL_PIPE_PEEK
+	PipeWait                  = 0x00110018 // FSCTL_PIPE_WAIT
+	PipeTransceive            = 0x0011C017 // FSCTL_PIPE_TRANSCEIVE
+	SrvEnumerateSnapshots     = 0x00144064 // FSCTL_SRV_ENUMERATE_SNAPSHOTS
+	SrvRequestResumeKey       = 0x00140078 // FSCTL_SRV_REQUEST_RESUME_KEY
+	SrvCopyChunk              = 0x001440F2 // FSCTL_SRV_COPYCHUNK
+	SrvCopyChunkWrite         = 0x001480F2 // FSCTL_SRV_COPYCHUNK_WRITE
+	QueryNetworkInterfaceInfo = 0x001401FC // FSCTL_QUERY_NETWORK_INTERFACE_INFO
+	ValidateNegotiateInfo     = 0x00140204 // FSCTL_VALIDATE_NEGOTIATE_INFO
+)
+
+// String returns a string representation of the control code.
+func (c Code) String() string {
+	switch c {
+	case SetReparsePoint:
+		return "SetReparsePoint"
+	case GetReparsePoint:
+		return "GetReparsePoint"
+	case SetSparse:
+		return "SetSparse"
+	case SetZeroData:
+		return "SetZeroData"
+	case QueryAllocatedRanges:
+		return "QueryAllocatedRanges"
+	case PipePeek:
+		return "PipePeek"
+	case PipeWait:
+		return "PipeWait"
+	case PipeTransceive:
+		return "PipeTransceive"
+	case SrvEnumerateSnapshots:
+		return "SrvEnumerateSnapshots"
+	case SrvRequestResumeKey:
+		return "SrvRequestResumeKey"
+	case SrvCopyChunk:
+		return "SrvCopyChunk"
+	case SrvCopyChunkWrite:
+		return "SrvCopyChunkWrite"
+	case QueryNetworkInterfaceInfo:
+		return "QueryNetworkInterfaceInfo"
+	case ValidateNegotiateInfo:
+		return "ValidateNegotiateInfo"
+	default:
+		return "CtlCode 0x" + strconv.FormatUint(uint64(c), 16)
+	}
+}
@@ -0,0 +1,2 @@
+// Package smbioctl provides types for SMB IOCTL requests and responses.
+package smbioctl
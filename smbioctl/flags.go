@@ -0,0 +1,16 @@
+package smbioctl
+
+// Flags are the flags of an IOCTL request.
+type Flags uint32
+
+// IOCTL request flags.
+const (
+	// IsFSCTL indicates that the request is a file system control request.
+	// Otherwise it is a device control request.
+	IsFSCTL = 0x00000001 // SMB2_0_IOCTL_IS_FSCTL
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
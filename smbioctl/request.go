@@ -0,0 +1,188 @@
+package smbioctl
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for the fixed portion of an
+// SMB IOCTL request.
+const RequestSize = 56
+
+// headerSize is the number of bytes in an SMB packet header. It's needed by
+// this package to calculate buffer offsets relative to the start of the
+// packet.
+const headerSize = 64
+
+// Request interprets a slice of bytes as an SMB IOCTL request packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.31
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 57
+	if r.Size() != 57 {
+		return false
+	}
+
+	// The input and output buffers must not overflow
+	if count := int(r.InputCount()); count > 0 {
+		start := int(r.InputOffset()) - headerSize
+		if start < RequestSize || start+count > len(r) {
+			return false
+		}
+	}
+	if count := int(r.OutputCount()); count > 0 {
+		start := int(r.OutputOffset()) - headerSize
+		if start < RequestSize || start+count > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r Request) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r Request) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// CtlCode returns the control code of the request.
+func (r Request) CtlCode() Code {
+	return Code(smbtype.Uint32(r[4:8]))
+}
+
+// SetCtlCode sets the control code of the request.
+func (r Request) SetCtlCode(code Code) {
+	smbtype.PutUint32(r[4:8], uint32(code))
+}
+
+// FileID returns the ID of the file that the request applies to.
+func (r Request) FileID() (id smbfile.ID) {
+	id.Read(r[8:24])
+	return
+}
+
+// SetFileID sets the ID of the file that the request applies to.
+func (r Request) SetFileID(id smbfile.ID) {
+	id.Write(r[8:24])
+}
+
+// InputOffset returns the offset of the input buffer in bytes from the
+// start of the packet header.
+func (r Request) InputOffset() uint32 {
+	return smbtype.Uint32(r[24:28])
+}
+
+// SetInputOffset sets the offset of the input buffer in bytes from the
+// start of the packet header.
+func (r Request) SetInputOffset(offset uint32) {
+	smbtype.PutUint32(r[24:28], offset)
+}
+
+// InputCount returns the length of the input buffer in bytes.
+func (r Request) InputCount() uint32 {
+	return smbtype.Uint32(r[28:32])
+}
+
+// SetInputCount sets the length of the input buffer in bytes.
+func (r Request) SetInputCount(count uint32) {
+	smbtype.PutUint32(r[28:32], count)
+}
+
+// MaxInputResponse returns the maximum number of bytes that the server can
+// return in the input buffer of the response.
+func (r Request) MaxInputResponse() uint32 {
+	return smbtype.Uint32(r[32:36])
+}
+
+// SetMaxInputResponse sets the maximum number of bytes that the server can
+// return in the input buffer of the response.
+func (r Request) SetMaxInputResponse(max uint32) {
+	smbtype.PutUint32(r[32:36], max)
+}
+
+// OutputOffset returns the offset of the output buffer in bytes from the
+// start of the packet header.
+func (r Request) OutputOffset() uint32 {
+	return smbtype.Uint32(r[36:40])
+}
+
+// SetOutputOffset sets the offset of the output buffer in bytes from the
+// start of the packet header.
+func (r Request) SetOutputOffset(offset uint32) {
+	smbtype.PutUint32(r[36:40], offset)
+}
+
+// OutputCount returns the length of the output buffer in bytes.
+func (r Request) OutputCount() uint32 {
+	return smbtype.Uint32(r[40:44])
+}
+
+// SetOutputCount sets the length of the output buffer in bytes.
+func (r Request) SetOutputCount(count uint32) {
+	smbtype.PutUint32(r[40:44], count)
+}
+
+// MaxOutputResponse returns the maximum number of bytes that the server can
+// return in the output buffer of the response.
+func (r Request) MaxOutputResponse() uint32 {
+	return smbtype.Uint32(r[44:48])
+}
+
+// SetMaxOutputResponse sets the maximum number of bytes that the server can
+// return in the output buffer of the response.
+func (r Request) SetMaxOutputResponse(max uint32) {
+	smbtype.PutUint32(r[44:48], max)
+}
+
+// Flags returns the flags of the request.
+func (r Request) Flags() Flags {
+	return Flags(smbtype.Uint32(r[48:52]))
+}
+
+// SetFlags sets the flags of the request.
+func (r Request) SetFlags(flags Flags) {
+	smbtype.PutUint32(r[48:52], uint32(flags))
+}
+
+// Input returns the input buffer of the request.
+func (r Request) Input() []byte {
+	count := uint(r.InputCount())
+	if count == 0 {
+		return nil
+	}
+	start := uint(r.InputOffset()) - headerSize
+	end := start + count
+	return r[start:end:end]
+}
+
+// SetInputLayout sets the offset and length of the input buffer. The
+// buffer is placed immediately after the fixed portion of the request.
+// It returns the input buffer so that it can be populated.
+//
+// If the request is too small to hold length bytes the call will panic.
+func (r Request) SetInputLayout(length int) []byte {
+	if len(r)-RequestSize < length {
+		panic("smbioctl: request: input buffer is too large to fit in request")
+	}
+	if length == 0 {
+		r.SetInputOffset(0)
+		r.SetInputCount(0)
+		return nil
+	}
+	r.SetInputOffset(headerSize + RequestSize)
+	r.SetInputCount(uint32(length))
+	end := RequestSize + length
+	return r[RequestSize:end:end]
+}
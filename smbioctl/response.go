@@ -0,0 +1,147 @@
+package smbioctl
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ResponseSize is the number of bytes required for the fixed portion of an
+// SMB IOCTL response.
+const ResponseSize = 48
+
+// Response interprets a slice of bytes as an SMB IOCTL response packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.32
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	if len(r) < ResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 49
+	if r.Size() != 49 {
+		return false
+	}
+
+	// The input and output buffers must not overflow
+	if count := int(r.InputCount()); count > 0 {
+		start := int(r.InputOffset()) - headerSize
+		if start < ResponseSize || start+count > len(r) {
+			return false
+		}
+	}
+	if count := int(r.OutputCount()); count > 0 {
+		start := int(r.OutputOffset()) - headerSize
+		if start < ResponseSize || start+count > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r Response) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r Response) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// CtlCode returns the control code of the response.
+func (r Response) CtlCode() Code {
+	return Code(smbtype.Uint32(r[4:8]))
+}
+
+// SetCtlCode sets the control code of the response.
+func (r Response) SetCtlCode(code Code) {
+	smbtype.PutUint32(r[4:8], uint32(code))
+}
+
+// FileID returns the ID of the file that the response applies to.
+func (r Response) FileID() (id smbfile.ID) {
+	id.Read(r[8:24])
+	return
+}
+
+// SetFileID sets the ID of the file that the response applies to.
+func (r Response) SetFileID(id smbfile.ID) {
+	id.Write(r[8:24])
+}
+
+// InputOffset returns the offset of the input buffer in bytes from the
+// start of the packet header.
+func (r Response) InputOffset() uint32 {
+	return smbtype.Uint32(r[24:28])
+}
+
+// SetInputOffset sets the offset of the input buffer in bytes from the
+// start of the packet header.
+func (r Response) SetInputOffset(offset uint32) {
+	smbtype.PutUint32(r[24:28], offset)
+}
+
+// InputCount returns the length of the input buffer in bytes.
+func (r Response) InputCount() uint32 {
+	return smbtype.Uint32(r[28:32])
+}
+
+// SetInputCount sets the length of the input buffer in bytes.
+func (r Response) SetInputCount(count uint32) {
+	smbtype.PutUint32(r[28:32], count)
+}
+
+// OutputOffset returns the offset of the output buffer in bytes from the
+// start of the packet header.
+func (r Response) OutputOffset() uint32 {
+	return smbtype.Uint32(r[32:36])
+}
+
+// SetOutputOffset sets the offset of the output buffer in bytes from the
+// start of the packet header.
+func (r Response) SetOutputOffset(offset uint32) {
+	smbtype.PutUint32(r[32:36], offset)
+}
+
+// OutputCount returns the length of the output buffer in bytes.
+func (r Response) OutputCount() uint32 {
+	return smbtype.Uint32(r[36:40])
+}
+
+// SetOutputCount sets the length of the output buffer in bytes.
+func (r Response) SetOutputCount(count uint32) {
+	smbtype.PutUint32(r[36:40], count)
+}
+
+// Output returns the output buffer of the response.
+func (r Response) Output() []byte {
+	count := uint(r.OutputCount())
+	if count == 0 {
+		return nil
+	}
+	start := uint(r.OutputOffset()) - headerSize
+	end := start + count
+	return r[start:end:end]
+}
+
+// SetOutputLayout sets the offset and length of the output buffer. The
+// buffer is placed immediately after the fixed portion of the response and
+// the input buffer is left empty. It returns the output buffer so that it
+// can be populated.
+//
+// If the response is too small to hold length bytes the call will panic.
+func (r Response) SetOutputLayout(length int) []byte {
+	if len(r)-ResponseSize < length {
+		panic("smbioctl: response: output buffer is too large to fit in response")
+	}
+	r.SetInputOffset(headerSize + ResponseSize)
+	r.SetInputCount(0)
+	r.SetOutputOffset(headerSize + ResponseSize)
+	r.SetOutputCount(uint32(length))
+	end := ResponseSize + length
+	return r[ResponseSize:end:end]
+}
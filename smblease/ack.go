@@ -0,0 +1,62 @@
+package smblease
+
+import (
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// AckSize is the number of bytes required for an SMB lease break
+// acknowledgment or response.
+const AckSize = 36
+
+// Ack interprets a slice of bytes as an SMB lease break acknowledgment or
+// response packet, which share the same layout.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ sections 2.2.24.2 and 2.2.25.2
+type Ack []byte
+
+// Valid returns true if the message is valid.
+func (a Ack) Valid() bool {
+	if len(a) < AckSize {
+		return false
+	}
+
+	// The spec requires the size field to be 36
+	if a.Size() != 36 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the message.
+func (a Ack) Size() uint16 {
+	return smbtype.Uint16(a[0:2])
+}
+
+// SetSize sets the structure size of the message.
+func (a Ack) SetSize(size uint16) {
+	smbtype.PutUint16(a[0:2], size)
+}
+
+// Key returns the key of the lease being acknowledged.
+func (a Ack) Key() (key smbid.ID) {
+	key.Read(a[8:24])
+	return
+}
+
+// SetKey sets the key of the lease being acknowledged.
+func (a Ack) SetKey(key smbid.ID) {
+	key.Write(a[8:24])
+}
+
+// State returns the lease state of the message. In an acknowledgment it is
+// the state the client accepts, and in a response it is the state granted.
+func (a Ack) State() State {
+	return State(smbtype.Uint32(a[24:28]))
+}
+
+// SetState sets the lease state of the message.
+func (a Ack) SetState(state State) {
+	smbtype.PutUint32(a[24:28], uint32(state))
+}
@@ -0,0 +1,91 @@
+package smblease
+
+import (
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// BreakNotificationSize is the number of bytes required for an SMB lease
+// break notification.
+const BreakNotificationSize = 44
+
+// BreakNotification interprets a slice of bytes as an SMB lease break
+// notification packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.23.2
+type BreakNotification []byte
+
+// Valid returns true if the notification is valid.
+func (n BreakNotification) Valid() bool {
+	if len(n) < BreakNotificationSize {
+		return false
+	}
+
+	// The spec requires the size field to be 44
+	if n.Size() != 44 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the notification.
+func (n BreakNotification) Size() uint16 {
+	return smbtype.Uint16(n[0:2])
+}
+
+// SetSize sets the structure size of the notification.
+func (n BreakNotification) SetSize(size uint16) {
+	smbtype.PutUint16(n[0:2], size)
+}
+
+// NewEpoch returns the epoch of a version 2 lease after the break.
+func (n BreakNotification) NewEpoch() uint16 {
+	return smbtype.Uint16(n[2:4])
+}
+
+// SetNewEpoch sets the epoch of a version 2 lease after the break.
+func (n BreakNotification) SetNewEpoch(epoch uint16) {
+	smbtype.PutUint16(n[2:4], epoch)
+}
+
+// Flags returns the flags of the notification.
+func (n BreakNotification) Flags() BreakFlags {
+	return BreakFlags(smbtype.Uint32(n[4:8]))
+}
+
+// SetFlags sets the flags of the notification.
+func (n BreakNotification) SetFlags(flags BreakFlags) {
+	smbtype.PutUint32(n[4:8], uint32(flags))
+}
+
+// Key returns the key of the lease being broken.
+func (n BreakNotification) Key() (key smbid.ID) {
+	key.Read(n[8:24])
+	return
+}
+
+// SetKey sets the key of the lease being broken.
+func (n BreakNotification) SetKey(key smbid.ID) {
+	key.Write(n[8:24])
+}
+
+// CurrentState returns the state of the lease before the break.
+func (n BreakNotification) CurrentState() State {
+	return State(smbtype.Uint32(n[24:28]))
+}
+
+// SetCurrentState sets the state of the lease before the break.
+func (n BreakNotification) SetCurrentState(state State) {
+	smbtype.PutUint32(n[24:28], uint32(state))
+}
+
+// NewState returns the state the lease is being broken to.
+func (n BreakNotification) NewState() State {
+	return State(smbtype.Uint32(n[28:32]))
+}
+
+// SetNewState sets the state the lease is being broken to.
+func (n BreakNotification) SetNewState(state State) {
+	smbtype.PutUint32(n[28:32], uint32(state))
+}
@@ -0,0 +1,85 @@
+package smblease
+
+import (
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ContextSize is the number of bytes required for a version 1 lease create
+// context.
+const ContextSize = 32
+
+// ContextV2Size is the number of bytes required for a version 2 lease
+// create context.
+const ContextV2Size = 52
+
+// Context interprets a slice of bytes as the data of an SMB2_CREATE_REQUEST_LEASE
+// or SMB2_CREATE_REQUEST_LEASE_V2 create context. The same layouts are used
+// for the lease contexts of create responses. The version of the context is
+// determined by its length.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ sections 2.2.13.2.8, 2.2.13.2.10, 2.2.14.2.10 and 2.2.14.2.11
+type Context []byte
+
+// Valid returns true if the context is a valid version 1 or version 2
+// context.
+func (c Context) Valid() bool {
+	return len(c) == ContextSize || len(c) >= ContextV2Size
+}
+
+// V2 returns true if c is a version 2 context.
+func (c Context) V2() bool {
+	return len(c) >= ContextV2Size
+}
+
+// Key returns the lease key of the context.
+func (c Context) Key() (key smbid.ID) {
+	key.Read(c[0:16])
+	return
+}
+
+// SetKey sets the lease key of the context.
+func (c Context) SetKey(key smbid.ID) {
+	key.Write(c[0:16])
+}
+
+// State returns the lease state of the context.
+func (c Context) State() State {
+	return State(smbtype.Uint32(c[16:20]))
+}
+
+// SetState sets the lease state of the context.
+func (c Context) SetState(state State) {
+	smbtype.PutUint32(c[16:20], uint32(state))
+}
+
+// Flags returns the lease flags of the context.
+func (c Context) Flags() Flags {
+	return Flags(smbtype.Uint32(c[20:24]))
+}
+
+// SetFlags sets the lease flags of the context.
+func (c Context) SetFlags(flags Flags) {
+	smbtype.PutUint32(c[20:24], uint32(flags))
+}
+
+// ParentKey returns the parent lease key of a version 2 context.
+func (c Context) ParentKey() (key smbid.ID) {
+	key.Read(c[32:48])
+	return
+}
+
+// SetParentKey sets the parent lease key of a version 2 context.
+func (c Context) SetParentKey(key smbid.ID) {
+	key.Write(c[32:48])
+}
+
+// Epoch returns the lease epoch of a version 2 context.
+func (c Context) Epoch() uint16 {
+	return smbtype.Uint16(c[48:50])
+}
+
+// SetEpoch sets the lease epoch of a version 2 context.
+func (c Context) SetEpoch(epoch uint16) {
+	smbtype.PutUint16(c[48:50], epoch)
+}
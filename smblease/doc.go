@@ -0,0 +1,3 @@
+// Package smblease provides types for SMB leases, lease create contexts and
+// lease break messages.
+package smblease
@@ -0,0 +1,33 @@
+package smblease
+
+// Flags are the flags of a lease create context.
+type Flags uint32
+
+// Lease create context flags.
+const (
+	// BreakInProgress indicates that a break of the lease is in progress.
+	BreakInProgress = 0x00000002 // SMB2_LEASE_FLAG_BREAK_IN_PROGRESS
+
+	// ParentKeySet indicates that the parent lease key of a version 2
+	// lease create context is set.
+	ParentKeySet = 0x00000004 // SMB2_LEASE_FLAG_PARENT_LEASE_KEY_SET
+)
+
+// Match reports whether f contains all of the flags in c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
+
+// BreakFlags are the flags of a lease break notification.
+type BreakFlags uint32
+
+// Lease break notification flags.
+const (
+	// AckRequired indicates that the client must acknowledge the break.
+	AckRequired = 0x00000001 // SMB2_NOTIFY_BREAK_LEASE_FLAG_ACK_REQUIRED
+)
+
+// Match reports whether f contains all of the flags in c.
+func (f BreakFlags) Match(c BreakFlags) bool {
+	return f&c == c
+}
@@ -0,0 +1,50 @@
+package smblease
+
+// State is a set of lease caching states.
+type State uint32
+
+// Lease caching states.
+const (
+	None          = 0x00 // SMB2_LEASE_NONE
+	ReadCaching   = 0x01 // SMB2_LEASE_READ_CACHING
+	HandleCaching = 0x02 // SMB2_LEASE_HANDLE_CACHING
+	WriteCaching  = 0x04 // SMB2_LEASE_WRITE_CACHING
+)
+
+// Match reports whether s contains all of the caching states in c.
+func (s State) Match(c State) bool {
+	return s&c == c
+}
+
+// Valid returns true if s is a combination of caching states that can be
+// granted: none, R, RH, RW or RWH.
+func (s State) Valid() bool {
+	switch s {
+	case None, ReadCaching, ReadCaching | HandleCaching, ReadCaching | WriteCaching, ReadCaching | HandleCaching | WriteCaching:
+		return true
+	default:
+		return false
+	}
+}
+
+// String returns a string representation of the lease state in the
+// abbreviated form used by Windows, such as "RWH".
+func (s State) String() string {
+	if s == None {
+		return "None"
+	}
+	var b []byte
+	if s.Match(ReadCaching) {
+		b = append(b, 'R')
+	}
+	if s.Match(WriteCaching) {
+		b = append(b, 'W')
+	}
+	if s.Match(HandleCaching) {
+		b = append(b, 'H')
+	}
+	if extra := s &^ (ReadCaching | WriteCaching | HandleCaching); extra != 0 {
+		b = append(b, '?')
+	}
+	return string(b)
+}
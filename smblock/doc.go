@@ -0,0 +1,3 @@
+// Package smblock provides types for SMB byte-range lock requests and
+// responses.
+package smblock
@@ -0,0 +1,44 @@
+package smblock
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ElementSize is the number of bytes in an SMB lock element.
+const ElementSize = 24
+
+// Element interprets a slice of bytes as an SMB lock element, which
+// describes a byte range to be locked or unlocked.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.26.1
+type Element []byte
+
+// Offset returns the starting offset in bytes of the range.
+func (e Element) Offset() uint64 {
+	return smbtype.Uint64(e[0:8])
+}
+
+// SetOffset sets the starting offset in bytes of the range.
+func (e Element) SetOffset(offset uint64) {
+	smbtype.PutUint64(e[0:8], offset)
+}
+
+// Length returns the length in bytes of the range.
+func (e Element) Length() uint64 {
+	return smbtype.Uint64(e[8:16])
+}
+
+// SetLength sets the length in bytes of the range.
+func (e Element) SetLength(length uint64) {
+	smbtype.PutUint64(e[8:16], length)
+}
+
+// Flags returns the flags that describe how the range is to be locked or
+// unlocked.
+func (e Element) Flags() Flags {
+	return Flags(smbtype.Uint32(e[16:20]))
+}
+
+// SetFlags sets the flags that describe how the range is to be locked or
+// unlocked.
+func (e Element) SetFlags(flags Flags) {
+	smbtype.PutUint32(e[16:20], uint32(flags))
+}
@@ -0,0 +1,48 @@
+package smblock
+
+import "strings"
+
+// Flags declares a set of lock element flags.
+type Flags uint32
+
+// Lock element flags.
+const (
+	Shared          = 0x00000001 // SMB2_LOCKFLAG_SHARED_LOCK
+	Exclusive       = 0x00000002 // SMB2_LOCKFLAG_EXCLUSIVE_LOCK
+	Unlock          = 0x00000004 // SMB2_LOCKFLAG_UNLOCK
+	FailImmediately = 0x00000010 // SMB2_LOCKFLAG_FAIL_IMMEDIATELY
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
+
+// Valid reports whether f is one of the combinations of flags permitted by
+// the protocol.
+func (f Flags) Valid() bool {
+	switch f {
+	case Shared, Exclusive, Unlock, Shared | FailImmediately, Exclusive | FailImmediately:
+		return true
+	default:
+		return false
+	}
+}
+
+// String returns a string representation of the flags.
+func (f Flags) String() string {
+	var names []string
+	if f.Match(Shared) {
+		names = append(names, "Shared")
+	}
+	if f.Match(Exclusive) {
+		names = append(names, "Exclusive")
+	}
+	if f.Match(Unlock) {
+		names = append(names, "Unlock")
+	}
+	if f.Match(FailImmediately) {
+		names = append(names, "FailImmediately")
+	}
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,88 @@
+package smblock
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for an SMB lock request with
+// a single lock element.
+const RequestSize = 48
+
+// Request interprets a slice of bytes as an SMB lock request packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.26
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 48
+	if r.Size() != 48 {
+		return false
+	}
+
+	// The lock elements must not overflow
+	if len(r) < 24+int(r.LockCount())*ElementSize {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r Request) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r Request) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// LockCount returns the number of lock elements in the request.
+func (r Request) LockCount() uint16 {
+	return smbtype.Uint16(r[2:4])
+}
+
+// SetLockCount sets the number of lock elements in the request.
+func (r Request) SetLockCount(count uint16) {
+	smbtype.PutUint16(r[2:4], count)
+}
+
+// LockSequenceNumber returns the 4-bit lock sequence number of the
+// request.
+func (r Request) LockSequenceNumber() uint8 {
+	return uint8(smbtype.Uint32(r[4:8]) & 0xF)
+}
+
+// LockSequenceIndex returns the 28-bit lock sequence index of the request.
+func (r Request) LockSequenceIndex() uint32 {
+	return smbtype.Uint32(r[4:8]) >> 4
+}
+
+// SetLockSequence sets the lock sequence number and index of the request.
+func (r Request) SetLockSequence(number uint8, index uint32) {
+	smbtype.PutUint32(r[4:8], index<<4|uint32(number&0xF))
+}
+
+// FileID returns the file ID of the file to be locked or unlocked.
+func (r Request) FileID() (id smbfile.ID) {
+	id.Read(r[8:24])
+	return
+}
+
+// SetFileID sets the file ID of the file to be locked or unlocked.
+func (r Request) SetFileID(id smbfile.ID) {
+	id.Write(r[8:24])
+}
+
+// Lock returns the lock element at index i.
+func (r Request) Lock(i int) Element {
+	start := 24 + i*ElementSize
+	end := start + ElementSize
+	return Element(r[start:end:end])
+}
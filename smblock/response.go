@@ -0,0 +1,35 @@
+package smblock
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ResponseSize is the number of bytes required for an SMB lock response.
+const ResponseSize = 4
+
+// Response interprets a slice of bytes as an SMB lock response packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.27
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	if len(r) < ResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 4
+	if r.Size() != 4 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r Response) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r Response) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
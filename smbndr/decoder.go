@@ -0,0 +1,160 @@
+package smbndr
+
+import (
+	"errors"
+
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+var (
+	// ErrShortBuffer is returned when NDR data ends before all of its
+	// values have been decoded.
+	ErrShortBuffer = errors.New("smbndr: the data is too short")
+
+	// ErrInvalidString is returned when the counts of a conformant and
+	// varying string are inconsistent.
+	ErrInvalidString = errors.New("smbndr: the string is not valid")
+
+	// ErrInvalidUnion is returned when the discriminant of a union doesn't
+	// select one of its arms.
+	ErrInvalidUnion = errors.New("smbndr: the union discriminant is not valid")
+)
+
+// Decoder decodes NDR data.
+//
+// Once a decoding error has occurred all subsequent values decode as zero
+// and the error is reported by Err.
+type Decoder struct {
+	buf      []byte
+	off      int
+	err      error
+	deferred []func()
+}
+
+// NewDecoder returns a decoder that decodes b.
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{buf: b}
+}
+
+// Err returns the first error that occurred while decoding, if any.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// Align skips padding until the offset of the decoder is a multiple of n,
+// which must be a power of two.
+func (d *Decoder) Align(n int) {
+	d.off = (d.off + n - 1) &^ (n - 1)
+}
+
+// Fail records err as a decoding error, unless an error has already
+// occurred.
+func (d *Decoder) Fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// next returns the next n bytes, or nil if the data is too short.
+func (d *Decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.off+n > len(d.buf) || d.off+n < d.off {
+		d.err = ErrShortBuffer
+		return nil
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+// Uint16 decodes a 16-bit value after aligning to 2 bytes.
+func (d *Decoder) Uint16() uint16 {
+	d.Align(2)
+	b := d.next(2)
+	if b == nil {
+		return 0
+	}
+	return smbtype.Uint16(b)
+}
+
+// Uint32 decodes a 32-bit value after aligning to 4 bytes.
+func (d *Decoder) Uint32() uint32 {
+	d.Align(4)
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return smbtype.Uint32(b)
+}
+
+// Pointer decodes a unique or full pointer and reports whether it is
+// non-null.
+//
+// The caller is responsible for decoding the pointee of a non-null
+// pointer, either immediately for top-level pointers or with Defer for
+// embedded pointers.
+func (d *Decoder) Pointer() bool {
+	return d.Uint32() != 0
+}
+
+// String decodes a conformant and varying string of 16-bit characters. A
+// null terminator, if present, is removed.
+//
+// See C706 section 14.3.4.2.
+func (d *Decoder) String() string {
+	max := d.Uint32()
+	offset := d.Uint32()
+	count := d.Uint32()
+	if d.err != nil {
+		return ""
+	}
+	if offset != 0 || count > max || int(count) > (len(d.buf)-d.off)/2 {
+		d.err = ErrInvalidString
+		return ""
+	}
+	b := d.next(int(count) * 2)
+	if n := len(b); n >= 2 && b[n-2] == 0 && b[n-1] == 0 {
+		b = b[:n-2]
+	}
+	return smbtype.String(b)
+}
+
+// Defer queues f to be called by Flush. It is used to decode the pointees
+// of embedded pointers after the construct that holds them.
+func (d *Decoder) Defer(f func()) {
+	d.deferred = append(d.deferred, f)
+}
+
+// Flush calls the deferred functions in the order that they were queued,
+// including those that are queued while it runs. It is called at the end
+// of each top-level parameter.
+func (d *Decoder) Flush() {
+	for len(d.deferred) > 0 {
+		f := d.deferred[0]
+		d.deferred = d.deferred[1:]
+		f()
+	}
+}
+
+// ArrayCount decodes the maximum count of a conformant array whose elements
+// each occupy at least size bytes. If the remaining data is too short to
+// hold that many elements it fails with ErrShortBuffer.
+func (d *Decoder) ArrayCount(size int) int {
+	count := d.Uint32()
+	if d.err != nil {
+		return 0
+	}
+	if uint64(count)*uint64(size) > uint64(len(d.buf)-d.off) {
+		d.err = ErrShortBuffer
+		return 0
+	}
+	return int(count)
+}
+
+// Skip skips n bytes without alignment, such as the body of a byte array
+// that the caller doesn't need.
+func (d *Decoder) Skip(n int) {
+	d.next(n)
+}
@@ -0,0 +1,13 @@
+// Package smbndr encodes and decodes the stub data of DCE/RPC calls with
+// the NDR 2.0 transfer syntax.
+//
+// Only the little-endian data representation with 32-bit pointers is
+// supported, which is what every Windows client proposes when it binds
+// over a named pipe. Pointees of embedded pointers are deferred until the
+// end of the top-level parameter that holds them, as NDR requires; callers
+// queue them with Defer and write or read them with Flush.
+//
+// See C706 chapter 14.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-rpce/ section 2.2.5
+package smbndr
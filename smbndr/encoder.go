@@ -0,0 +1,94 @@
+package smbndr
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// firstReferent is the first referent ID issued by encoders. Windows
+// issues referent IDs from the same base.
+const firstReferent = 0x00020000
+
+// Encoder encodes NDR data. The zero value is an empty encoder that is
+// ready to use.
+type Encoder struct {
+	buf      []byte
+	referent uint32
+	deferred []func()
+}
+
+// Bytes returns the encoded data.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// Align pads the encoded data with zeros until its length is a multiple of
+// n, which must be a power of two.
+func (e *Encoder) Align(n int) {
+	for len(e.buf)&(n-1) != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// Uint16 encodes v after aligning the data to 2 bytes.
+func (e *Encoder) Uint16(v uint16) {
+	e.Align(2)
+	e.buf = append(e.buf, 0, 0)
+	smbtype.PutUint16(e.buf[len(e.buf)-2:], v)
+}
+
+// Uint32 encodes v after aligning the data to 4 bytes.
+func (e *Encoder) Uint32(v uint32) {
+	e.Align(4)
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	smbtype.PutUint32(e.buf[len(e.buf)-4:], v)
+}
+
+// Pointer encodes a unique or full pointer. It writes a new referent ID if
+// present is true and a null pointer otherwise. It returns present.
+//
+// The caller is responsible for encoding the pointee, either immediately
+// for top-level pointers or with Defer for embedded pointers.
+func (e *Encoder) Pointer(present bool) bool {
+	if !present {
+		e.Uint32(0)
+		return false
+	}
+	if e.referent == 0 {
+		e.referent = firstReferent
+	} else {
+		e.referent += 4
+	}
+	e.Uint32(e.referent)
+	return true
+}
+
+// String encodes s as a conformant and varying string of 16-bit
+// characters, including its null terminator. This is the encoding of
+// [string] wchar_t* pointees.
+//
+// See C706 section 14.3.4.2.
+func (e *Encoder) String(s string) {
+	length := smbtype.StringLen(s)
+	count := uint32(length/2 + 1)
+	e.Uint32(count) // Maximum count
+	e.Uint32(0)     // Offset
+	e.Uint32(count) // Actual count
+	start := len(e.buf)
+	e.buf = append(e.buf, make([]byte, length+2)...)
+	smbtype.PutString(e.buf[start:], s)
+}
+
+// Defer queues f to be called by Flush. It is used to encode the pointees
+// of embedded pointers after the construct that holds them.
+func (e *Encoder) Defer(f func()) {
+	e.deferred = append(e.deferred, f)
+}
+
+// Flush calls the deferred functions in the order that they were queued,
+// including those that are queued while it runs. It is called at the end
+// of each top-level parameter.
+func (e *Encoder) Flush() {
+	for len(e.deferred) > 0 {
+		f := e.deferred[0]
+		e.deferred = e.deferred[1:]
+		f()
+	}
+}
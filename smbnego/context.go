@@ -1,6 +1,8 @@
 package smbnego
 
 import (
+	"bytes"
+
 	"github.com/gentlemanautomaton/smb/smbtype"
 	"github.com/gentlemanautomaton/smb/smbcompression"
 	"github.com/gentlemanautomaton/smb/smbencryption"
@@ -17,6 +19,15 @@ const ContextHeaderLength = 8
 // https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/15332256-522e-4a53-8cd7-0bd17678a2f7
 type Context []byte
 
+// NewContext returns a negotiation context with the given type and data.
+func NewContext(t ContextType, data []byte) Context {
+	c := make(Context, ContextHeaderLength+len(data))
+	smbtype.PutUint16(c[0:2], uint16(t))
+	smbtype.PutUint16(c[2:4], uint16(len(data)))
+	copy(c[ContextHeaderLength:], data)
+	return c
+}
+
 // Type returns the type of the context.
 func (c Context) Type() ContextType {
 	return ContextType(smbtype.Uint16(c[0:2]))
@@ -50,6 +61,12 @@ func (c Context) CompressionCaps() smbcompression.Capabilities {
 	return smbcompression.Capabilities(c.Data())
 }
 
+// POSIXExtensions returns true if the context offers or acknowledges the
+// version of the SMB3 POSIX extensions identified by id.
+func (c Context) POSIXExtensions(id []byte) bool {
+	return c.Type() == POSIXExtensions && bytes.Equal(c.Data(), id)
+}
+
 // NetName interprets the context's data as the name of the server the client
 // wishes to connect to.
 func (c Context) NetName() Name {
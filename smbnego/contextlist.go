@@ -20,26 +20,43 @@ func (k ContextList) Valid(count uint16) bool {
 			return false
 		}
 		ctx := Context(k[start:end:end])
-		length := ContextOffset(ctx.Length())
-		end = start + length
+		end += ContextOffset(ctx.Length())
 		if end > listLength {
 			return false
 		}
 		// TODO: Validate each individual context?
-		start = end
+		start = align8(end)
 	}
 	return true
 }
 
 // Member returns the context at the given offset within the list.
 func (k ContextList) Member(offset ContextOffset) Context {
-	end := k.Next(offset)
+	end := offset + ContextHeaderLength + ContextOffset(Context(k[offset:]).Length())
 	return Context(k[offset:end:end])
 }
 
 // Next returns the offset of the next member within the list after last.
+// Each member is aligned to an 8-byte boundary.
 func (k ContextList) Next(last ContextOffset) (next ContextOffset) {
-	end := last + ContextHeaderLength
-	ctx := Context(k[last:end:end])
-	return last + ContextOffset(ctx.Length())
+	return align8(last + ContextOffset(len(k.Member(last))))
+}
+
+// Find returns the first of the count contexts in the list that has the
+// given type. It returns false if there is no such context. The list must
+// be valid.
+func (k ContextList) Find(count uint16, t ContextType) (Context, bool) {
+	offset := ContextOffset(0)
+	for i := uint16(0); i < count; i++ {
+		if ctx := k.Member(offset); ctx.Type() == t {
+			return ctx, true
+		}
+		offset = k.Next(offset)
+	}
+	return nil, false
+}
+
+// align8 rounds offset up to a multiple of 8.
+func align8(offset ContextOffset) ContextOffset {
+	return (offset + 7) &^ 7
 }
@@ -14,6 +14,7 @@ const (
 	EncryptionCaps       = 0x0002 // SMB2_ENCRYPTION_CAPABILITIES
 	CompressionCaps      = 0x0003 // SMB2_COMPRESSION_CAPABILITIES
 	NetnameID            = 0x0004 // SMB2_NETNAME_NEGOTIATE_CONTEXT_ID
+	POSIXExtensions      = 0x0100 // SMB2_POSIX_EXTENSIONS_AVAILABLE
 )
 
 // String returns a string representation of the context type.
@@ -27,6 +28,8 @@ func (t ContextType) String() string {
 		return "CompressionCaps"
 	case NetnameID:
 		return "NetnameID"
+	case POSIXExtensions:
+		return "POSIXExtensions"
 	default:
 		return "ContextType-" + strconv.Itoa(int(t))
 	}
@@ -52,8 +52,11 @@ func (r Request) Valid() bool {
 	if r.Dialects().Contains(smbdialect.SMB311) {
 		// Make sure the context count is compatible with the size of the
 		// request. The size of each context is variable but at least 8 bytes.
-		minimumLength := uint(r.ContextOffset()) + uint(r.ContextCount())*ContextHeaderLength
-		if minimumLength > uint(len(r)) {
+		if r.ContextCount() > 0 && uint(r.ContextOffset()) < headerSize+RequestSize {
+			return false
+		}
+		minimumLength := uint(r.ContextOffset()) + uint(r.ContextCount())*ContextHeaderLength - headerSize
+		if r.ContextCount() > 0 && minimumLength > uint(len(r)) {
 			return false
 		}
 
@@ -119,16 +122,16 @@ func (r Request) SetClientID(id smbid.ID) {
 	id.Write(r[12:28])
 }
 
-// ContextOffset returns the offset of the first negotiation context in the
-// request.
+// ContextOffset returns the offset of the first negotiation context in
+// bytes from the start of the packet header.
 //
 // This field is only valid in the SMB 3.1.1 dialect.
 func (r Request) ContextOffset() uint32 {
 	return smbtype.Uint32(r[28:32])
 }
 
-// SetContextOffset sets the offset of the first negotiation context in the
-// request.
+// SetContextOffset sets the offset of the first negotiation context in
+// bytes from the start of the packet header.
 //
 // This field is only valid in the SMB 3.1.1 dialect.
 func (r Request) SetContextOffset(size uint32) {
@@ -162,7 +165,11 @@ func (r Request) Dialects() smbdialect.List {
 //
 // This field is only valid in the SMB 3.1.1 dialect.
 func (r Request) ContextList() ContextList {
-	return ContextList(r[r.ContextOffset():])
+	if r.ContextCount() == 0 {
+		return nil
+	}
+	start := uint(r.ContextOffset()) - headerSize
+	return ContextList(r[start:])
 }
 
 // Summary returns a multi-line string representation of the request.
@@ -38,10 +38,13 @@ func (r Response) Valid() bool {
 	}
 
 	// In SMB 3.1.1 the negotiation contexts must not overflow
-	if r.DialectRevision() == smbdialect.SMB311 {
+	if r.DialectRevision() == smbdialect.SMB311 && r.ContextCount() > 0 {
 		// Make sure the context count is compatible with the size of the
 		// response. The size of each context is variable but at least 8 bytes.
-		minimumLength := uint(r.ContextOffset()) + uint(r.ContextCount())*ContextHeaderLength
+		if uint(r.ContextOffset()) < headerSize+ResponseSize {
+			return false
+		}
+		minimumLength := uint(r.ContextOffset()) + uint(r.ContextCount())*ContextHeaderLength - headerSize
 		if minimumLength > uint(len(r)) {
 			return false
 		}
@@ -0,0 +1,15 @@
+package smbnetif
+
+// Capabilities describe the capabilities of a network interface.
+type Capabilities uint32
+
+// Network interface capabilities.
+const (
+	RSS  = 0x00000001 // RSS_CAPABLE
+	RDMA = 0x00000002 // RDMA_CAPABLE
+)
+
+// Match reports whether c contains all of the capabilities specified by v.
+func (c Capabilities) Match(v Capabilities) bool {
+	return c&v == v
+}
@@ -0,0 +1,4 @@
+// Package smbnetif provides types for the network interface information
+// that servers report to clients so that they can establish additional
+// channels.
+package smbnetif
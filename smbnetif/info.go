@@ -0,0 +1,147 @@
+package smbnetif
+
+import (
+	"net"
+
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// InfoSize is the number of bytes used to encode the information about a
+// network interface.
+const InfoSize = 152
+
+// Address families of socket addresses.
+const (
+	familyIPv4 = 0x0002 // InterNetwork
+	familyIPv6 = 0x0017 // InterNetworkV6
+)
+
+// Info interprets a slice of bytes as information about a network
+// interface and one of its addresses.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.32.5
+type Info []byte
+
+// Valid returns true if the information is valid.
+func (info Info) Valid() bool {
+	if len(info) < InfoSize {
+		return false
+	}
+	switch info.family() {
+	case familyIPv4, familyIPv6:
+		return true
+	default:
+		return false
+	}
+}
+
+// Next returns the offset of the next entry in bytes from the start of
+// this one, or zero if this is the last entry.
+func (info Info) Next() uint32 {
+	return smbtype.Uint32(info[0:4])
+}
+
+// SetNext sets the offset of the next entry in bytes from the start of
+// this one.
+func (info Info) SetNext(next uint32) {
+	smbtype.PutUint32(info[0:4], next)
+}
+
+// Index returns the index of the network interface.
+func (info Info) Index() uint32 {
+	return smbtype.Uint32(info[4:8])
+}
+
+// SetIndex sets the index of the network interface.
+func (info Info) SetIndex(index uint32) {
+	smbtype.PutUint32(info[4:8], index)
+}
+
+// Capabilities returns the capabilities of the network interface.
+func (info Info) Capabilities() Capabilities {
+	return Capabilities(smbtype.Uint32(info[8:12]))
+}
+
+// SetCapabilities sets the capabilities of the network interface.
+func (info Info) SetCapabilities(caps Capabilities) {
+	smbtype.PutUint32(info[8:12], uint32(caps))
+}
+
+// LinkSpeed returns the speed of the network interface in bits per
+// second.
+func (info Info) LinkSpeed() uint64 {
+	return smbtype.Uint64(info[16:24])
+}
+
+// SetLinkSpeed sets the speed of the network interface in bits per second.
+func (info Info) SetLinkSpeed(speed uint64) {
+	smbtype.PutUint64(info[16:24], speed)
+}
+
+func (info Info) family() uint16 {
+	return smbtype.Uint16(info[24:26])
+}
+
+// Address returns the IP address held by the socket address of the entry.
+// It returns nil if the address family isn't recognized.
+func (info Info) Address() net.IP {
+	sockaddr := info[24:InfoSize]
+	switch info.family() {
+	case familyIPv4:
+		// SOCKADDR_IN: Family, Port, IPv4Address, Reserved
+		return net.IP(append([]byte(nil), sockaddr[4:8]...))
+	case familyIPv6:
+		// SOCKADDR_IN6: Family, Port, FlowInfo, IPv6Address, ScopeID
+		return net.IP(append([]byte(nil), sockaddr[8:24]...))
+	default:
+		return nil
+	}
+}
+
+// SetAddress sets the socket address of the entry to hold ip. IPv4
+// addresses, including IPv4-mapped IPv6 addresses, are encoded as
+// SOCKADDR_IN and all others as SOCKADDR_IN6.
+func (info Info) SetAddress(ip net.IP) {
+	sockaddr := info[24:InfoSize]
+	for i := range sockaddr {
+		sockaddr[i] = 0
+	}
+	if v4 := ip.To4(); v4 != nil {
+		smbtype.PutUint16(sockaddr[0:2], familyIPv4)
+		copy(sockaddr[4:8], v4)
+		return
+	}
+	smbtype.PutUint16(sockaddr[0:2], familyIPv6)
+	copy(sockaddr[8:24], ip.To16())
+}
+
+// List interprets a slice of bytes as a chain of network interface
+// entries.
+type List []byte
+
+// Entries returns the entries in the list. It returns false if the chain
+// of entries is malformed.
+func (list List) Entries() (entries []Info, ok bool) {
+	if len(list) == 0 {
+		return nil, true
+	}
+	offset := 0
+	for {
+		if offset+InfoSize > len(list) {
+			return nil, false
+		}
+		info := Info(list[offset : offset+InfoSize])
+		if !info.Valid() {
+			return nil, false
+		}
+		entries = append(entries, info)
+		next := int(info.Next())
+		if next == 0 {
+			return entries, true
+		}
+		if next < InfoSize {
+			return nil, false
+		}
+		offset += next
+	}
+}
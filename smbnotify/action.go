@@ -0,0 +1,43 @@
+package smbnotify
+
+import "strconv"
+
+// Action identifies the type of change described by a file notify
+// information entry.
+type Action uint32
+
+// File notify actions.
+const (
+	Added          = 0x00000001 // FILE_ACTION_ADDED
+	Removed        = 0x00000002 // FILE_ACTION_REMOVED
+	Modified       = 0x00000003 // FILE_ACTION_MODIFIED
+	RenamedOldName = 0x00000004 // FILE_ACTION_RENAMED_OLD_NAME
+	RenamedNewName = 0x00000005 // FILE_ACTION_RENAMED_NEW_NAME
+	AddedStream    = 0x00000006 // FILE_ACTION_ADDED_STREAM
+	RemovedStream  = 0x00000007 // FILE_ACTION_REMOVED_STREAM
+	ModifiedStream = 0x00000008 // FILE_ACTION_MODIFIED_STREAM
+)
+
+// String returns a string representation of the action.
+func (a Action) String() string {
+	switch a {
+	case Added:
+		return "Added"
+	case Removed:
+		return "Removed"
+	case Modified:
+		return "Modified"
+	case RenamedOldName:
+		return "RenamedOldName"
+	case RenamedNewName:
+		return "RenamedNewName"
+	case AddedStream:
+		return "AddedStream"
+	case RemovedStream:
+		return "RemovedStream"
+	case ModifiedStream:
+		return "ModifiedStream"
+	default:
+		return "Action " + strconv.Itoa(int(a))
+	}
+}
@@ -0,0 +1,8 @@
+package smbnotify
+
+// Change describes a single change to be reported in a file notify
+// information entry.
+type Change struct {
+	Action Action
+	Name   string // Backslash-separated path relative to the monitored directory
+}
@@ -0,0 +1,3 @@
+// Package smbnotify facilitates serialization and deserialization of SMB
+// change notification requests and responses.
+package smbnotify
@@ -0,0 +1,94 @@
+package smbnotify
+
+import "strings"
+
+// Filter is a completion filter that specifies the types of changes that
+// a change notification request is interested in.
+type Filter uint32
+
+// Change notification completion filter flags.
+const (
+	FileName    = 0x00000001 // FILE_NOTIFY_CHANGE_FILE_NAME
+	DirName     = 0x00000002 // FILE_NOTIFY_CHANGE_DIR_NAME
+	Attributes  = 0x00000004 // FILE_NOTIFY_CHANGE_ATTRIBUTES
+	Size        = 0x00000008 // FILE_NOTIFY_CHANGE_SIZE
+	LastWrite   = 0x00000010 // FILE_NOTIFY_CHANGE_LAST_WRITE
+	LastAccess  = 0x00000020 // FILE_NOTIFY_CHANGE_LAST_ACCESS
+	Creation    = 0x00000040 // FILE_NOTIFY_CHANGE_CREATION
+	EA          = 0x00000080 // FILE_NOTIFY_CHANGE_EA
+	Security    = 0x00000100 // FILE_NOTIFY_CHANGE_SECURITY
+	StreamName  = 0x00000200 // FILE_NOTIFY_CHANGE_STREAM_NAME
+	StreamSize  = 0x00000400 // FILE_NOTIFY_CHANGE_STREAM_SIZE
+	StreamWrite = 0x00000800 // FILE_NOTIFY_CHANGE_STREAM_WRITE
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f Filter) Match(c Filter) bool {
+	return f&c == c
+}
+
+// Overlaps reports whether f contains any of the flags specified by c.
+func (f Filter) Overlaps(c Filter) bool {
+	return f&c != 0
+}
+
+// String returns a string representation of the completion filter.
+func (f Filter) String() string {
+	return f.Format("|", FilterNames)
+}
+
+// Format returns a string representation of the completion filter using
+// the given separator and format.
+func (f Filter) Format(sep string, format FilterFormat) string {
+	if s, ok := format[f]; ok {
+		return s
+	}
+
+	var matched []string
+	for i := 0; i < 32; i++ {
+		flag := Filter(1 << uint32(i))
+		if f.Match(flag) {
+			if s, ok := format[flag]; ok {
+				matched = append(matched, s)
+			}
+		}
+	}
+
+	return strings.Join(matched, sep)
+}
+
+// FilterFormat describes a set of names for completion filter flags.
+type FilterFormat map[Filter]string
+
+// FilterProtoNames maps individual flags to their names as defined by the
+// SMB protocol specification.
+var FilterProtoNames = FilterFormat{
+	FileName:    "FILE_NOTIFY_CHANGE_FILE_NAME",
+	DirName:     "FILE_NOTIFY_CHANGE_DIR_NAME",
+	Attributes:  "FILE_NOTIFY_CHANGE_ATTRIBUTES",
+	Size:        "FILE_NOTIFY_CHANGE_SIZE",
+	LastWrite:   "FILE_NOTIFY_CHANGE_LAST_WRITE",
+	LastAccess:  "FILE_NOTIFY_CHANGE_LAST_ACCESS",
+	Creation:    "FILE_NOTIFY_CHANGE_CREATION",
+	EA:          "FILE_NOTIFY_CHANGE_EA",
+	Security:    "FILE_NOTIFY_CHANGE_SECURITY",
+	StreamName:  "FILE_NOTIFY_CHANGE_STREAM_NAME",
+	StreamSize:  "FILE_NOTIFY_CHANGE_STREAM_SIZE",
+	StreamWrite: "FILE_NOTIFY_CHANGE_STREAM_WRITE",
+}
+
+// FilterNames maps individual flags to their Go-style names.
+var FilterNames = FilterFormat{
+	FileName:    "FileName",
+	DirName:     "DirName",
+	Attributes:  "Attributes",
+	Size:        "Size",
+	LastWrite:   "LastWrite",
+	LastAccess:  "LastAccess",
+	Creation:    "Creation",
+	EA:          "EA",
+	Security:    "Security",
+	StreamName:  "StreamName",
+	StreamSize:  "StreamSize",
+	StreamWrite: "StreamWrite",
+}
@@ -0,0 +1,16 @@
+package smbnotify
+
+// Flags declares a set of change notification request flags.
+type Flags uint16
+
+// Change notification request flags.
+const (
+	// WatchTree indicates that changes within subdirectories of the
+	// directory should be reported.
+	WatchTree = 0x0001 // SMB2_WATCH_TREE
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
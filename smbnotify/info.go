@@ -0,0 +1,106 @@
+package smbnotify
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// InfoHeaderSize is the number of bytes required for the fixed portion of a
+// file notify information entry.
+const InfoHeaderSize = 12
+
+// InfoAlignment is the byte alignment required for each entry within a
+// file notify information list.
+const InfoAlignment = 4
+
+// InfoSize returns the number of bytes required to hold a file notify
+// information entry for the given file name, excluding alignment padding.
+func InfoSize(name string) int {
+	return InfoHeaderSize + smbtype.StringLen(name)
+}
+
+// Info interprets a slice of bytes as a FILE_NOTIFY_INFORMATION entry.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/634043d7-7b39-47e9-9e26-bda64685e4c9
+type Info []byte
+
+// NextEntryOffset returns the offset of the next entry in the list. It
+// returns zero if this is the last entry.
+func (i Info) NextEntryOffset() uint32 {
+	return smbtype.Uint32(i[0:4])
+}
+
+// SetNextEntryOffset sets the offset of the next entry in the list.
+func (i Info) SetNextEntryOffset(offset uint32) {
+	smbtype.PutUint32(i[0:4], offset)
+}
+
+// Action returns the type of change described by the entry.
+func (i Info) Action() Action {
+	return Action(smbtype.Uint32(i[4:8]))
+}
+
+// SetAction sets the type of change described by the entry.
+func (i Info) SetAction(action Action) {
+	smbtype.PutUint32(i[4:8], uint32(action))
+}
+
+// FileNameLength returns the length of the file name in bytes.
+func (i Info) FileNameLength() uint32 {
+	return smbtype.Uint32(i[8:12])
+}
+
+// FileName returns the name of the file that changed, relative to the
+// directory being monitored.
+func (i Info) FileName() string {
+	end := InfoHeaderSize + uint(i.FileNameLength())
+	return smbtype.String(i[InfoHeaderSize:end])
+}
+
+// SetFileName sets the name of the file that changed and updates the file
+// name length.
+func (i Info) SetFileName(name string) {
+	n := smbtype.PutString(i[InfoHeaderSize:], name)
+	smbtype.PutUint32(i[8:12], uint32(n))
+}
+
+// InfoList interprets a slice of bytes as a list of FILE_NOTIFY_INFORMATION
+// entries.
+type InfoList []byte
+
+// Valid returns true if every entry in the list is in bounds.
+func (k InfoList) Valid() bool {
+	if len(k) == 0 {
+		return true
+	}
+	offset := uint(0)
+	for {
+		if offset+InfoHeaderSize > uint(len(k)) {
+			return false
+		}
+		entry := Info(k[offset:])
+		if offset+InfoHeaderSize+uint(entry.FileNameLength()) > uint(len(k)) {
+			return false
+		}
+		next := uint(entry.NextEntryOffset())
+		if next == 0 {
+			return true
+		}
+		if next%InfoAlignment != 0 || next < InfoHeaderSize {
+			return false
+		}
+		offset += next
+	}
+}
+
+// Member returns the entry at the given offset within the list.
+func (k InfoList) Member(offset uint32) Info {
+	return Info(k[offset:])
+}
+
+// Next returns the offset of the entry that follows the entry at the given
+// offset. It returns zero if the entry at offset is the last in the list.
+func (k InfoList) Next(offset uint32) uint32 {
+	next := k.Member(offset).NextEntryOffset()
+	if next == 0 {
+		return 0
+	}
+	return offset + next
+}
@@ -0,0 +1,83 @@
+package smbnotify
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for an SMB change
+// notification request.
+const RequestSize = 32
+
+// Request interprets a slice of bytes as an SMB change notification request
+// packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/598f395a-e7a2-4cc8-afb3-ccb30dd2df7c
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 32
+	if r.Size() != 32 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r Request) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r Request) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// Flags returns the flags of the request.
+func (r Request) Flags() Flags {
+	return Flags(smbtype.Uint16(r[2:4]))
+}
+
+// SetFlags sets the flags of the request.
+func (r Request) SetFlags(flags Flags) {
+	smbtype.PutUint16(r[2:4], uint16(flags))
+}
+
+// OutputBufferLength returns the maximum number of bytes the server is
+// allowed to return in the response.
+func (r Request) OutputBufferLength() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetOutputBufferLength sets the maximum number of bytes the server is
+// allowed to return in the response.
+func (r Request) SetOutputBufferLength(length uint32) {
+	smbtype.PutUint32(r[4:8], length)
+}
+
+// FileID returns the file ID of the directory to be monitored.
+func (r Request) FileID() (id smbfile.ID) {
+	id.Read(r[8:24])
+	return
+}
+
+// SetFileID sets the file ID of the directory to be monitored.
+func (r Request) SetFileID(id smbfile.ID) {
+	id.Write(r[8:24])
+}
+
+// CompletionFilter returns the types of changes to be monitored.
+func (r Request) CompletionFilter() Filter {
+	return Filter(smbtype.Uint32(r[24:28]))
+}
+
+// SetCompletionFilter sets the types of changes to be monitored.
+func (r Request) SetCompletionFilter(filter Filter) {
+	smbtype.PutUint32(r[24:28], uint32(filter))
+}
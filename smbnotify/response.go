@@ -0,0 +1,100 @@
+package smbnotify
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ResponseSize is the number of bytes required for the fixed portion of an
+// SMB change notification response.
+const ResponseSize = 8
+
+// headerSize is the number of bytes in an SMB packet header. It's needed by
+// this package to calculate buffer offsets relative to the start of the
+// packet.
+const headerSize = 64
+
+// Response interprets a slice of bytes as an SMB change notification
+// response packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/14f9d050-27b2-49df-b009-54e08e8bf7b5
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	if len(r) < ResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 9
+	if r.Size() != 9 {
+		return false
+	}
+
+	// The output buffer must not overflow
+	if r.OutputBufferLength() > 0 {
+		start := int(r.OutputBufferOffset()) - headerSize
+		if start < ResponseSize || start+int(r.OutputBufferLength()) > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r Response) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r Response) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// OutputBufferOffset returns the offset of the output buffer in bytes from
+// the start of the packet header.
+func (r Response) OutputBufferOffset() uint16 {
+	return smbtype.Uint16(r[2:4])
+}
+
+// SetOutputBufferOffset sets the offset of the output buffer in bytes from
+// the start of the packet header.
+func (r Response) SetOutputBufferOffset(offset uint16) {
+	smbtype.PutUint16(r[2:4], offset)
+}
+
+// OutputBufferLength returns the length of the output buffer.
+func (r Response) OutputBufferLength() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetOutputBufferLength sets the length of the output buffer.
+func (r Response) SetOutputBufferLength(length uint32) {
+	smbtype.PutUint32(r[4:8], length)
+}
+
+// OutputBuffer returns the output buffer of the response, which holds a
+// list of file notify information entries.
+func (r Response) OutputBuffer() InfoList {
+	start := uint(r.OutputBufferOffset()) - headerSize
+	end := start + uint(r.OutputBufferLength())
+	return InfoList(r[start:end:end])
+}
+
+// SetOutputBufferLayout sets the offset and length of the output buffer.
+// The buffer is placed immediately after the fixed portion of the response.
+// It returns the output buffer so that it can be populated.
+//
+// If the response is too small to hold length bytes the call will panic.
+func (r Response) SetOutputBufferLayout(length int) InfoList {
+	if len(r)-ResponseSize < length {
+		panic("smbnotify: response: output buffer is too large to fit in response")
+	}
+	if length == 0 {
+		r.SetOutputBufferOffset(0)
+		r.SetOutputBufferLength(0)
+		return nil
+	}
+	r.SetOutputBufferOffset(headerSize + ResponseSize)
+	r.SetOutputBufferLength(uint32(length))
+	end := ResponseSize + length
+	return InfoList(r[ResponseSize:end:end])
+}
@@ -0,0 +1,63 @@
+package smboplock
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// BreakSize is the number of bytes required for an SMB oplock break
+// notification, acknowledgment or response.
+const BreakSize = 24
+
+// Break interprets a slice of bytes as an SMB oplock break notification,
+// acknowledgment or response packet, all of which share the same layout.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ sections 2.2.23.1, 2.2.24.1 and 2.2.25.1
+type Break []byte
+
+// Valid returns true if the message is valid.
+func (b Break) Valid() bool {
+	if len(b) < BreakSize {
+		return false
+	}
+
+	// The spec requires the size field to be 24
+	if b.Size() != 24 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the message.
+func (b Break) Size() uint16 {
+	return smbtype.Uint16(b[0:2])
+}
+
+// SetSize sets the structure size of the message.
+func (b Break) SetSize(size uint16) {
+	smbtype.PutUint16(b[0:2], size)
+}
+
+// OplockLevel returns the oplock level of the message. In a notification it
+// is the level the oplock is being broken to, in an acknowledgment it is the
+// level the client accepts, and in a response it is the level granted.
+func (b Break) OplockLevel() Level {
+	return Level(b[2])
+}
+
+// SetOplockLevel sets the oplock level of the message.
+func (b Break) SetOplockLevel(level Level) {
+	b[2] = byte(level)
+}
+
+// FileID returns the file ID of the open whose oplock is being broken.
+func (b Break) FileID() (id smbfile.ID) {
+	id.Read(b[8:24])
+	return
+}
+
+// SetFileID sets the file ID of the open whose oplock is being broken.
+func (b Break) SetFileID(id smbfile.ID) {
+	id.Write(b[8:24])
+}
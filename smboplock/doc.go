@@ -0,0 +1,3 @@
+// Package smboplock provides types for SMB opportunistic locks and oplock
+// break messages.
+package smboplock
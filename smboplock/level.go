@@ -0,0 +1,38 @@
+package smboplock
+
+import "strconv"
+
+// Level is an oplock level.
+type Level uint8
+
+// Oplock levels.
+const (
+	None      = 0x00 // SMB2_OPLOCK_LEVEL_NONE
+	LevelII   = 0x01 // SMB2_OPLOCK_LEVEL_II
+	Exclusive = 0x08 // SMB2_OPLOCK_LEVEL_EXCLUSIVE
+	Batch     = 0x09 // SMB2_OPLOCK_LEVEL_BATCH
+	Lease     = 0xFF // SMB2_OPLOCK_LEVEL_LEASE
+)
+
+// Exclusive returns true if l is an exclusive or batch oplock level.
+func (l Level) Exclusive() bool {
+	return l == Exclusive || l == Batch
+}
+
+// String returns a string representation of the oplock level.
+func (l Level) String() string {
+	switch l {
+	case None:
+		return "None"
+	case LevelII:
+		return "LevelII"
+	case Exclusive:
+		return "Exclusive"
+	case Batch:
+		return "Batch"
+	case Lease:
+		return "Lease"
+	default:
+		return "Level " + strconv.Itoa(int(l))
+	}
+}
@@ -0,0 +1,22 @@
+package smbosfs
+
+import (
+	"os"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// CopyRange copies length bytes from src at srcOffset to dst at dstOffset.
+// Both files must have been opened by an FS, otherwise it returns
+// smbfs.ErrCopyNotSupported.
+func (fs *FS) CopyRange(dst smbfs.File, dstOffset int64, src smbfs.File, srcOffset int64, length int64) (int64, error) {
+	d, ok := dst.(*os.File)
+	if !ok {
+		return 0, smbfs.ErrCopyNotSupported
+	}
+	s, ok := src.(*os.File)
+	if !ok {
+		return 0, smbfs.ErrCopyNotSupported
+	}
+	return copyFileRange(d, dstOffset, s, srcOffset, length)
+}
@@ -0,0 +1,62 @@
+package smbosfs
+
+import (
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// copyFileRange copies length bytes between the files with
+// (*os.File).ReadFrom, which uses copy_file_range where the kernel and the
+// file systems support it. That lets the kernel copy the data without
+// passing it through user space, or share extents on file systems that
+// support reflinks.
+//
+// ReadFrom copies from and to the offsets of the files, so the files are
+// reopened through /proc/self/fd, which leaves the offsets of the opens of
+// other callers untouched. If /proc isn't available
+// smbfs.ErrCopyNotSupported is returned.
+func copyFileRange(dst *os.File, dstOffset int64, src *os.File, srcOffset int64, length int64) (int64, error) {
+	s, err := reopen(src, os.O_RDONLY)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	d, err := reopen(dst, os.O_WRONLY)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+
+	if _, err := s.Seek(srcOffset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err := d.Seek(dstOffset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	copied, err := d.ReadFrom(io.LimitReader(s, length))
+	if err == nil && copied < length {
+		err = io.EOF
+	}
+	return copied, err
+}
+
+// reopen opens the file of the open file with the given flags.
+func reopen(file *os.File, flag int) (*os.File, error) {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var reopened *os.File
+	if cerr := conn.Control(func(fd uintptr) {
+		reopened, err = os.OpenFile("/proc/self/fd/"+strconv.FormatUint(uint64(fd), 10), flag, 0)
+	}); cerr != nil {
+		return nil, cerr
+	}
+	if os.IsNotExist(err) {
+		return nil, smbfs.ErrCopyNotSupported
+	}
+	return reopened, err
+}
@@ -0,0 +1,50 @@
+package smbosfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+)
+
+func TestCopyRange(t *testing.T) {
+	root := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	if err := os.WriteFile(filepath.Join(root, "src.bin"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := smbosfs.New(root)
+	src, err := fs.OpenFile("src.bin", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := fs.OpenFile("dst.bin", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	n, err := fs.CopyRange(dst, 100, src, 10, 5000)
+	if errors.Is(err, smbfs.ErrCopyNotSupported) {
+		t.Skip("copy_file_range is not supported")
+	}
+	if err != nil || n != 5000 {
+		t.Fatalf("CopyRange returned %d, %v", n, err)
+	}
+	got := make([]byte, 5000)
+	if _, err := dst.ReadAt(got, 100); err != nil || !bytes.Equal(got, data[10:5010]) {
+		t.Errorf("CopyRange copied the wrong data (err %v)", err)
+	}
+
+	n, err = fs.CopyRange(dst, 0, src, int64(len(data))-10, 20)
+	if n != 10 || !errors.Is(err, io.EOF) {
+		t.Errorf("CopyRange beyond the end of the source returned %d, %v (want 10, EOF)", n, err)
+	}
+}
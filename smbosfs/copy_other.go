@@ -0,0 +1,14 @@
+//go:build !linux
+
+package smbosfs
+
+import (
+	"os"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// copyFileRange returns smbfs.ErrCopyNotSupported on this platform.
+func copyFileRange(dst *os.File, dstOffset int64, src *os.File, srcOffset int64, length int64) (int64, error) {
+	return 0, smbfs.ErrCopyNotSupported
+}
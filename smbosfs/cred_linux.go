@@ -0,0 +1,256 @@
+//go:build linux && (amd64 || arm64 || loong64 || riscv64)
+
+package smbosfs
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbidmap"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+)
+
+// Impersonate returns a view of the file system whose operations are
+// performed with the credentials that the identity mapper of the file
+// system maps the user of token to. Users that aren't mapped are denied
+// access. If the file system doesn't have an identity mapper it returns
+// the file system itself.
+//
+// Operations are performed on a locked thread whose file system user ID,
+// file system group ID and supplementary groups are changed to those of
+// the user for the duration of the operation, which requires the process
+// to have the CAP_SETUID and CAP_SETGID capabilities.
+func (fs *FS) Impersonate(token *smbsecurity.Token) (smbfs.FileSystem, error) {
+	if fs.mapper == nil {
+		return fs, nil
+	}
+	cred, err := smbidmap.Map(fs.mapper, token)
+	if err != nil {
+		return nil, &os.PathError{Op: "impersonate", Path: token.User.String(), Err: os.ErrPermission}
+	}
+	return &userFS{FS: fs, cred: cred}, nil
+}
+
+// userFS is a view of a file system whose operations are performed with
+// the credentials of a user. Operations on files that have already been
+// opened don't need to be impersonated, because the kernel checks access
+// when a file is opened.
+type userFS struct {
+	*FS
+	cred smbidmap.Credentials
+}
+
+// Base returns the file system presented by the view.
+func (u *userFS) Base() smbfs.FileSystem {
+	return u.FS
+}
+
+// OpenFile opens the named file on behalf of the user.
+func (u *userFS) OpenFile(name string, flag int, perm os.FileMode) (f smbfs.File, err error) {
+	err = u.do(func() error {
+		f, err = u.FS.OpenFile(name, flag, perm)
+		return err
+	})
+	return f, err
+}
+
+// Stat returns information about the named file on behalf of the user.
+func (u *userFS) Stat(name string) (info os.FileInfo, err error) {
+	err = u.do(func() error {
+		info, err = u.FS.Stat(name)
+		return err
+	})
+	return info, err
+}
+
+// Lstat returns information about the named file without following a
+// symbolic link at the end of name, on behalf of the user.
+func (u *userFS) Lstat(name string) (info os.FileInfo, err error) {
+	err = u.do(func() error {
+		info, err = u.FS.Lstat(name)
+		return err
+	})
+	return info, err
+}
+
+// Readlink returns the target of the named symbolic link on behalf of the
+// user.
+func (u *userFS) Readlink(name string) (target string, err error) {
+	err = u.do(func() error {
+		target, err = u.FS.Readlink(name)
+		return err
+	})
+	return target, err
+}
+
+// Symlink creates newname as a symbolic link to target on behalf of the
+// user.
+func (u *userFS) Symlink(target, newname string) error {
+	return u.do(func() error {
+		return u.FS.Symlink(target, newname)
+	})
+}
+
+// Mkdir creates a directory on behalf of the user.
+func (u *userFS) Mkdir(name string, perm os.FileMode) error {
+	return u.do(func() error {
+		return u.FS.Mkdir(name, perm)
+	})
+}
+
+// Remove removes the named file or empty directory on behalf of the user.
+// Its alternate data streams are removed by the process, which owns the
+// stream directory.
+func (u *userFS) Remove(name string) error {
+	var info os.FileInfo
+	if err := u.do(func() (err error) {
+		info, err = u.FS.remove(name)
+		return err
+	}); err != nil {
+		return err
+	}
+	u.FS.removeSidecars(info)
+	return nil
+}
+
+// Rename renames a file or directory on behalf of the user.
+func (u *userFS) Rename(oldname, newname string) error {
+	var replaced os.FileInfo
+	if err := u.do(func() (err error) {
+		replaced, err = u.FS.rename(oldname, newname)
+		return err
+	}); err != nil {
+		return err
+	}
+	u.FS.removeSidecars(replaced)
+	return nil
+}
+
+// OpenStream opens the named stream of the named file, which is opened on
+// behalf of the user. The stream itself is accessed by the process.
+func (u *userFS) OpenStream(name, stream string, flag int) (smbfs.File, error) {
+	return u.FS.openStream(u.OpenFile, name, stream, flag)
+}
+
+// Streams returns information about the alternate data streams of the
+// named file, which is opened on behalf of the user.
+func (u *userFS) Streams(name string) ([]os.FileInfo, error) {
+	return u.FS.streams(u.OpenFile, name)
+}
+
+// RemoveStream removes the named stream of the named file, which is
+// opened on behalf of the user.
+func (u *userFS) RemoveStream(name, stream string) error {
+	return u.FS.removeStream(u.OpenFile, name, stream)
+}
+
+// Watch starts watching the named directory on behalf of the user.
+func (u *userFS) Watch(name string, recursive bool) (w smbfs.Watch, err error) {
+	err = u.do(func() error {
+		w, err = u.FS.Watch(name, recursive)
+		return err
+	})
+	return w, err
+}
+
+// Snapshot returns a view of the snapshot taken at t that is presented to
+// the same user.
+func (u *userFS) Snapshot(t time.Time) (smbfs.FileSystem, error) {
+	snapshot, err := u.FS.Snapshot(t)
+	if err != nil {
+		return nil, err
+	}
+	return &userFS{FS: snapshot.(*FS), cred: u.cred}, nil
+}
+
+// do performs op with the credentials of the user.
+//
+// The credentials are changed on a thread that is locked to a goroutine
+// created for the operation. If the credentials of the process can't be
+// restored afterwards the thread remains locked, which causes it to be
+// terminated when the goroutine exits instead of being reused.
+func (u *userFS) do(op func() error) error {
+	process, err := processCredentials()
+	if err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := setCredentials(u.cred); err != nil {
+			if setCredentials(process) == nil {
+				runtime.UnlockOSThread()
+			}
+			done <- err
+			return
+		}
+		err := op()
+		if setCredentials(process) == nil {
+			runtime.UnlockOSThread()
+		}
+		done <- err
+	}()
+	return <-done
+}
+
+var (
+	processOnce sync.Once
+	processCred smbidmap.Credentials
+	processErr  error
+)
+
+// processCredentials returns the effective credentials of the process,
+// which threads return to once an operation has been performed.
+func processCredentials() (smbidmap.Credentials, error) {
+	processOnce.Do(func() {
+		processCred.UID = uint32(os.Geteuid())
+		processCred.GID = uint32(os.Getegid())
+		groups, err := os.Getgroups()
+		if err != nil {
+			processErr = err
+			return
+		}
+		for _, gid := range groups {
+			processCred.Groups = append(processCred.Groups, uint32(gid))
+		}
+	})
+	return processCred, processErr
+}
+
+// setCredentials changes the file system user ID, file system group ID and
+// supplementary groups of the calling thread. Unlike the functions of the
+// syscall package it doesn't change the credentials of other threads.
+//
+// syscall.Setgroups applies the groups to every thread of the process, so
+// setgroups is called directly, which needs the address of the group list.
+// This is the only use of the unsafe package in smbosfs.
+func setCredentials(cred smbidmap.Credentials) error {
+	var groups unsafe.Pointer
+	if len(cred.Groups) > 0 {
+		groups = unsafe.Pointer(&cred.Groups[0])
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SETGROUPS, uintptr(len(cred.Groups)), uintptr(groups), 0); errno != 0 {
+		return errno
+	}
+	if err := setfsid(syscall.SYS_SETFSGID, cred.GID); err != nil {
+		return err
+	}
+	return setfsid(syscall.SYS_SETFSUID, cred.UID)
+}
+
+// setfsid calls setfsuid or setfsgid, which don't report failure, and then
+// checks whether the ID was changed.
+func setfsid(trap uintptr, id uint32) error {
+	syscall.RawSyscall(trap, uintptr(id), 0, 0)
+	// An invalid ID leaves the current ID unchanged and returns it
+	current, _, _ := syscall.RawSyscall(trap, uintptr(^uint32(0)), 0, 0)
+	if uint32(current) != id {
+		return syscall.EPERM
+	}
+	return nil
+}
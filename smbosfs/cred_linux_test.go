@@ -0,0 +1,69 @@
+//go:build linux && (amd64 || arm64 || loong64 || riscv64)
+
+package smbosfs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbidmap"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+)
+
+func TestImpersonate(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("impersonation requires root")
+	}
+	// The user must be able to reach the root
+	root := t.TempDir()
+	for _, dir := range []string{filepath.Dir(root), root} {
+		if err := os.Chmod(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "private"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	user := smbsecurity.NewSID(5, 21, 1, 2, 3, 1000)
+	var table smbidmap.Static
+	table.AddUser(user, 1000, 1000)
+	fs := smbosfs.New(root)
+	fs.SetIdentityMapper(&table)
+
+	if _, err := fs.Impersonate(&smbsecurity.Token{User: smbsecurity.Anonymous}); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("impersonation of an unmapped user returned %v", err)
+	}
+	view, err := fs.Impersonate(&smbsecurity.Token{User: user})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files are created with the credentials of the user
+	f, err := view.OpenFile("a.txt", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	info, err := os.Stat(filepath.Join(root, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := info.Sys().(*syscall.Stat_t); st.Uid != 1000 || st.Gid != 1000 {
+		t.Fatalf("file is owned by %d:%d", st.Uid, st.Gid)
+	}
+
+	// The permissions of the local file system apply to the user
+	if _, err := view.OpenFile("private/b.txt", os.O_RDWR|os.O_CREATE, 0644); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("create in a private directory returned %v", err)
+	}
+
+	// The credentials of the process are unchanged
+	if err := os.WriteFile(filepath.Join(root, "private", "b.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux || !(amd64 || arm64 || loong64 || riscv64)
+
+package smbosfs
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+)
+
+// Impersonate returns the file system itself if it doesn't have an
+// identity mapper. Otherwise it returns smbfs.ErrImpersonationNotSupported
+// on this platform.
+func (fs *FS) Impersonate(token *smbsecurity.Token) (smbfs.FileSystem, error) {
+	if fs.mapper == nil {
+		return fs, nil
+	}
+	return nil, smbfs.ErrImpersonationNotSupported
+}
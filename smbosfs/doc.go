@@ -0,0 +1,3 @@
+// Package smbosfs provides an smbfs.FileSystem implementation that is
+// backed by a directory in the local operating system's file system.
+package smbosfs
@@ -0,0 +1,231 @@
+package smbosfs
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbidmap"
+)
+
+// ErrInvalidName is returned when a file name is not a valid
+// slash-separated path relative to the root of the file system.
+var ErrInvalidName = errors.New("smbosfs: invalid file name")
+
+// FS is a file system rooted at a directory in the local operating system's
+// file system. It must be created by calling New.
+type FS struct {
+	root     string
+	ca       bool
+	readOnly bool
+	mapper   smbidmap.Mapper // Maps users to the credentials they act with, if any
+
+	snapshotDir    string // The directory holding snapshots, if any
+	snapshotLayout string // The time layout of snapshot names
+
+	streamDir   string // The directory holding streams that don't fit in extended attributes, if any
+	streamLimit int64  // The size of the largest stream held in an extended attribute
+
+	mutex     sync.Mutex
+	snapshots map[string]*FS // Snapshot file systems keyed by name, protected by mutex
+
+	streamMutex sync.Mutex // Serializes changes to streams held in extended attributes
+}
+
+// New returns a file system rooted at the given directory.
+func New(root string) *FS {
+	return &FS{root: filepath.Clean(root)}
+}
+
+// Root returns the root directory of the file system.
+func (fs *FS) Root() string {
+	return fs.root
+}
+
+// SetContinuouslyAvailable determines whether the file system backs a
+// continuously available share. It must be called before the file system
+// is used.
+func (fs *FS) SetContinuouslyAvailable(ca bool) {
+	fs.ca = ca
+}
+
+// SetIdentityMapper causes operations to be performed with the POSIX
+// credentials that m maps the users of the file system to, so that the
+// permissions and ownership of files within the local file system apply
+// to them. It must be called before the file system is used.
+func (fs *FS) SetIdentityMapper(m smbidmap.Mapper) {
+	fs.mapper = m
+}
+
+// ContinuouslyAvailable returns true if the file system backs a
+// continuously available share.
+func (fs *FS) ContinuouslyAvailable() bool {
+	return fs.ca
+}
+
+// ReadOnly returns true if the file system is read-only, which is the case
+// for snapshots.
+func (fs *FS) ReadOnly() bool {
+	return fs.readOnly
+}
+
+// OpenFile opens the named file with the given flags and permissions.
+func (fs *FS) OpenFile(name string, flag int, perm os.FileMode) (smbfs.File, error) {
+	p, err := fs.path(name)
+	if err != nil {
+		return nil, err
+	}
+	if fs.readOnly && flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, readOnlyError("open", name)
+	}
+	f, err := os.OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Stat returns information about the named file.
+func (fs *FS) Stat(name string) (os.FileInfo, error) {
+	p, err := fs.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+// Lstat returns information about the named file without following a
+// symbolic link at the end of name.
+func (fs *FS) Lstat(name string) (os.FileInfo, error) {
+	p, err := fs.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(p)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *FS) Readlink(name string) (string, error) {
+	p, err := fs.path(name)
+	if err != nil {
+		return "", err
+	}
+	target, err := os.Readlink(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(target), nil
+}
+
+// Symlink creates newname as a symbolic link to target.
+func (fs *FS) Symlink(target, newname string) error {
+	p, err := fs.path(newname)
+	if err != nil {
+		return err
+	}
+	if fs.readOnly {
+		return readOnlyError("symlink", newname)
+	}
+	return os.Symlink(filepath.FromSlash(target), p)
+}
+
+// Mkdir creates a directory with the given name and permissions.
+func (fs *FS) Mkdir(name string, perm os.FileMode) error {
+	p, err := fs.path(name)
+	if err != nil {
+		return err
+	}
+	if fs.readOnly {
+		return readOnlyError("mkdir", name)
+	}
+	return os.Mkdir(p, perm)
+}
+
+// Remove removes the named file or empty directory, along with any of its
+// alternate data streams that are held in the stream directory.
+func (fs *FS) Remove(name string) error {
+	info, err := fs.remove(name)
+	if err != nil {
+		return err
+	}
+	fs.removeSidecars(info)
+	return nil
+}
+
+// remove removes the named file or empty directory. If a stream directory
+// is configured it returns information about the removed file.
+func (fs *FS) remove(name string) (os.FileInfo, error) {
+	p, err := fs.path(name)
+	if err != nil {
+		return nil, err
+	}
+	if fs.readOnly {
+		return nil, readOnlyError("remove", name)
+	}
+	var info os.FileInfo
+	if fs.streamDir != "" {
+		info, _ = os.Lstat(p)
+	}
+	return info, os.Remove(p)
+}
+
+// Rename renames a file or directory. The alternate data streams of a file
+// that is replaced are removed along with it.
+func (fs *FS) Rename(oldname, newname string) error {
+	replaced, err := fs.rename(oldname, newname)
+	if err != nil {
+		return err
+	}
+	fs.removeSidecars(replaced)
+	return nil
+}
+
+// rename renames a file or directory. If a stream directory is configured
+// it returns information about the file that was replaced, if any.
+func (fs *FS) rename(oldname, newname string) (os.FileInfo, error) {
+	oldpath, err := fs.path(oldname)
+	if err != nil {
+		return nil, err
+	}
+	newpath, err := fs.path(newname)
+	if err != nil {
+		return nil, err
+	}
+	if fs.readOnly {
+		return nil, readOnlyError("rename", oldname)
+	}
+	var replaced os.FileInfo
+	if fs.streamDir != "" {
+		if info, err := os.Lstat(newpath); err == nil {
+			if old, err := os.Lstat(oldpath); err == nil && !os.SameFile(info, old) {
+				replaced = info
+			}
+		}
+	}
+	return replaced, os.Rename(oldpath, newpath)
+}
+
+// path converts a slash-separated file name to a path within the local
+// file system. It rejects names that would escape the root.
+func (fs *FS) path(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, "/") {
+		return "", ErrInvalidName
+	}
+	if cleaned := path.Clean(name); cleaned != name || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidName
+	}
+	if name == "." {
+		return fs.root, nil
+	}
+	return filepath.Join(fs.root, filepath.FromSlash(name)), nil
+}
+
+// readOnlyError returns the error reported when an operation would modify
+// a read-only file system.
+func readOnlyError(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package smbosfs
+
+import (
+	"os"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// Identify returns false on this platform, which causes files to be
+// identified by name.
+func (fs *FS) Identify(info os.FileInfo) (smbfs.Identity, bool) {
+	return smbfs.Identity{}, false
+}
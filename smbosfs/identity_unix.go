@@ -0,0 +1,20 @@
+//go:build unix
+
+package smbosfs
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// Identify returns the device and inode numbers of the file described by
+// info.
+func (fs *FS) Identify(info os.FileInfo) (smbfs.Identity, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return smbfs.Identity{}, false
+	}
+	return smbfs.Identity{Device: uint64(st.Dev), Inode: uint64(st.Ino)}, true
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package smbosfs
+
+import (
+	"os"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// Ownership returns false on this platform, which doesn't have POSIX
+// owners.
+func (fs *FS) Ownership(info os.FileInfo) (smbfs.Ownership, bool) {
+	return smbfs.Ownership{}, false
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package smbosfs
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// Ownership returns the owner, group and hard link count of the file
+// described by info.
+func (fs *FS) Ownership(info os.FileInfo) (smbfs.Ownership, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return smbfs.Ownership{}, false
+	}
+	return smbfs.Ownership{UID: st.Uid, GID: st.Gid, Links: uint32(st.Nlink)}, true
+}
@@ -0,0 +1,100 @@
+package smbosfs
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// DefaultSnapshotLayout is the layout of snapshot names used when none is
+// configured. It matches the @GMT tokens that name snapshots in SMB.
+const DefaultSnapshotLayout = "@GMT-2006.01.02-15.04.05"
+
+// SetSnapshotDir configures the directory that holds snapshots of the file
+// system, such as the .snapshots directory of a btrfs subvolume. Each
+// subdirectory of dir whose name can be parsed with layout holds a
+// snapshot that was taken at that time in UTC. If layout is empty
+// DefaultSnapshotLayout is used. Relative directories are relative to the
+// root of the file system.
+//
+// It must be called before the file system is used.
+func (fs *FS) SetSnapshotDir(dir, layout string) {
+	if dir != "" && !filepath.IsAbs(dir) {
+		dir = filepath.Join(fs.root, dir)
+	}
+	if layout == "" {
+		layout = DefaultSnapshotLayout
+	}
+	fs.snapshotDir = dir
+	fs.snapshotLayout = layout
+}
+
+// Snapshots returns the times at which the snapshots in the snapshot
+// directory were taken. Entries whose names don't match the snapshot
+// layout are ignored.
+func (fs *FS) Snapshots() ([]time.Time, error) {
+	names, err := fs.snapshotNames()
+	if err != nil {
+		return nil, err
+	}
+	times := make([]time.Time, 0, len(names))
+	for _, t := range names {
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// Snapshot returns a read-only file system rooted at the snapshot taken at
+// t.
+func (fs *FS) Snapshot(t time.Time) (smbfs.FileSystem, error) {
+	names, err := fs.snapshotNames()
+	if err != nil {
+		return nil, err
+	}
+	for name, taken := range names {
+		if !taken.Equal(t) {
+			continue
+		}
+		fs.mutex.Lock()
+		defer fs.mutex.Unlock()
+		if snapshot, ok := fs.snapshots[name]; ok {
+			return snapshot, nil
+		}
+		if fs.snapshots == nil {
+			fs.snapshots = make(map[string]*FS)
+		}
+		snapshot := &FS{root: filepath.Join(fs.snapshotDir, name), readOnly: true}
+		fs.snapshots[name] = snapshot
+		return snapshot, nil
+	}
+	return nil, smbfs.ErrSnapshotNotFound
+}
+
+// snapshotNames returns the names of the directories in the snapshot
+// directory that hold snapshots, along with the times they were taken.
+func (fs *FS) snapshotNames() (map[string]time.Time, error) {
+	if fs.snapshotDir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(fs.snapshotDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]time.Time)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		t, err := time.Parse(fs.snapshotLayout, entry.Name())
+		if err != nil {
+			continue
+		}
+		names[entry.Name()] = t
+	}
+	return names, nil
+}
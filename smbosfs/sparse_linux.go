@@ -0,0 +1,184 @@
+package smbosfs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// Flags for fallocate and lseek that are missing from the syscall package.
+const (
+	fallocKeepSize  = 0x01 // FALLOC_FL_KEEP_SIZE
+	fallocPunchHole = 0x02 // FALLOC_FL_PUNCH_HOLE
+	fallocZeroRange = 0x10 // FALLOC_FL_ZERO_RANGE
+	seekData        = 3    // SEEK_DATA
+	seekHole        = 4    // SEEK_HOLE
+)
+
+// sparseAttr is the extended attribute that marks a file as sparse. Files
+// that have holes are sparse whether or not they're marked.
+const sparseAttr = "user.smb.sparse"
+
+// Sparse returns true if f has been marked as sparse, or if it has holes.
+func (fs *FS) Sparse(f smbfs.File) (bool, error) {
+	file, ok := f.(*os.File)
+	if !ok {
+		return false, smbfs.ErrSparseNotSupported
+	}
+	_, err := syscall.Getxattr(file.Name(), sparseAttr, nil)
+	switch {
+	case err == nil:
+		return true, nil
+	case err != syscall.ENODATA && err != syscall.ENOTSUP:
+		return false, os.NewSyscallError("getxattr", err)
+	}
+	return hasHoles(file)
+}
+
+// SetSparse marks f as sparse, or allocates its holes and removes the mark.
+// Marks are stored in an extended attribute, and aren't recorded by file
+// systems that lack support for them.
+func (fs *FS) SetSparse(f smbfs.File, sparse bool) error {
+	file, ok := f.(*os.File)
+	if !ok {
+		return smbfs.ErrSparseNotSupported
+	}
+	if fs.readOnly {
+		return readOnlyError("setsparse", file.Name())
+	}
+	if sparse {
+		err := syscall.Setxattr(file.Name(), sparseAttr, nil, 0)
+		if err != nil && err != syscall.ENOTSUP {
+			return os.NewSyscallError("setxattr", err)
+		}
+		return nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if size := info.Size(); size > 0 {
+		if err := fallocate(file, 0, 0, size); err != nil {
+			return err
+		}
+	}
+	err = syscall.Removexattr(file.Name(), sparseAttr)
+	if err != nil && err != syscall.ENODATA && err != syscall.ENOTSUP {
+		return os.NewSyscallError("removexattr", err)
+	}
+	return nil
+}
+
+// ZeroRange punches a hole in f if it's sparse, and zeroes the range
+// otherwise. If the file system can't do either smbfs.ErrSparseNotSupported
+// is returned.
+func (fs *FS) ZeroRange(f smbfs.File, offset, length int64) error {
+	file, ok := f.(*os.File)
+	if !ok {
+		return smbfs.ErrSparseNotSupported
+	}
+	if fs.readOnly {
+		return readOnlyError("zerorange", file.Name())
+	}
+	sparse, err := fs.Sparse(f)
+	if err != nil {
+		return err
+	}
+	mode := uint32(fallocKeepSize | fallocZeroRange)
+	if sparse {
+		mode = fallocKeepSize | fallocPunchHole
+	}
+	err = fallocate(file, mode, offset, length)
+	if errors.Is(err, syscall.EOPNOTSUPP) {
+		return smbfs.ErrSparseNotSupported
+	}
+	return err
+}
+
+// AllocatedRanges returns the ranges of f that hold data within length
+// bytes starting at offset. They are found with SEEK_DATA and SEEK_HOLE.
+// If the file system doesn't support them the whole range is reported as
+// allocated.
+func (fs *FS) AllocatedRanges(f smbfs.File, offset, length int64) ([]smbfs.Range, error) {
+	file, ok := f.(*os.File)
+	if !ok {
+		return nil, smbfs.ErrSparseNotSupported
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	end := info.Size()
+	if length < end-offset {
+		end = offset + length
+	}
+
+	var ranges []smbfs.Range
+	for pos := offset; pos < end; {
+		data, err := file.Seek(pos, seekData)
+		switch {
+		case errors.Is(err, syscall.ENXIO):
+			// There is no data beyond pos
+			return ranges, nil
+		case errors.Is(err, syscall.EINVAL):
+			return []smbfs.Range{{Offset: pos, Length: end - pos}}, nil
+		case err != nil:
+			return nil, err
+		}
+		if data >= end {
+			break
+		}
+		hole, err := file.Seek(data, seekHole)
+		if err != nil {
+			return nil, err
+		}
+		if hole > end {
+			hole = end
+		}
+		ranges = append(ranges, smbfs.Range{Offset: data, Length: hole - data})
+		pos = hole
+	}
+	return ranges, nil
+}
+
+// hasHoles returns true if file has a hole before its end.
+func hasHoles(file *os.File) (bool, error) {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	hole, err := file.Seek(0, seekHole)
+	switch {
+	case errors.Is(err, syscall.EINVAL), errors.Is(err, syscall.ENXIO):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return hole < info.Size(), nil
+}
+
+// fallocate calls fallocate for file.
+func fallocate(file *os.File, mode uint32, offset, length int64) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	if err := conn.Control(func(fd uintptr) {
+		for {
+			ferr = syscall.Fallocate(int(fd), mode, offset, length)
+			if ferr != syscall.EINTR {
+				break
+			}
+		}
+	}); err != nil {
+		return err
+	}
+	if ferr != nil {
+		return os.NewSyscallError("fallocate", ferr)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package smbosfs
+
+import "github.com/gentlemanautomaton/smb/smbfs"
+
+// Sparse returns smbfs.ErrSparseNotSupported on this platform.
+func (fs *FS) Sparse(f smbfs.File) (bool, error) {
+	return false, smbfs.ErrSparseNotSupported
+}
+
+// SetSparse returns smbfs.ErrSparseNotSupported on this platform.
+func (fs *FS) SetSparse(f smbfs.File, sparse bool) error {
+	return smbfs.ErrSparseNotSupported
+}
+
+// ZeroRange returns smbfs.ErrSparseNotSupported on this platform.
+func (fs *FS) ZeroRange(f smbfs.File, offset, length int64) error {
+	return smbfs.ErrSparseNotSupported
+}
+
+// AllocatedRanges returns smbfs.ErrSparseNotSupported on this platform.
+func (fs *FS) AllocatedRanges(f smbfs.File, offset, length int64) ([]smbfs.Range, error) {
+	return nil, smbfs.ErrSparseNotSupported
+}
@@ -0,0 +1,678 @@
+package smbosfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// DefaultStreamLimit is the size of the largest alternate data stream that
+// is held in an extended attribute when no limit is configured. It is the
+// largest extended attribute value that Linux supports, although many file
+// systems support less.
+const DefaultStreamLimit = 64 * 1024
+
+// streamAttr is the prefix of the names of the extended attributes that
+// hold alternate data streams.
+const streamAttr = "user.smb.stream."
+
+// sidecarSuffix is appended to the names of the files in the stream
+// directory that hold alternate data streams, so that streams named . and
+// .. can be held.
+const sidecarSuffix = ":$DATA"
+
+// sidecarRecord is the name of the file in each directory within the stream
+// directory that records the tag of the file the streams belong to. It
+// lacks sidecarSuffix, so it's never mistaken for a stream.
+const sidecarRecord = "tag"
+
+// maxAttrName is the length of the longest extended attribute name.
+const maxAttrName = 255
+
+// opener opens files on behalf of a file system or a view of it.
+type opener func(name string, flag int, perm os.FileMode) (smbfs.File, error)
+
+// SetStreamDir configures the directory that holds alternate data streams
+// that can't be held in extended attributes, either because they're larger
+// than the stream limit or because the underlying file system rejects
+// them. The streams of each file are held in a subdirectory named after
+// the device and inode numbers of the file, which is removed when the file
+// is removed through the file system. Inode numbers are reused, so each
+// subdirectory also records a tag that identifies its file, and is
+// discarded if it was left behind by another file. On Linux files are
+// tagged with an extended attribute, and elsewhere by their birth time, so
+// files that can't be tagged can't hold streams in the stream directory.
+// Relative directories are relative to the root of the file system, where
+// they're visible to clients.
+//
+// If no directory is configured, writes that would make a stream larger
+// than the limit fail with smbfs.ErrStreamTooLarge.
+//
+// It must be called before the file system is used.
+func (fs *FS) SetStreamDir(dir string) {
+	if dir != "" && !filepath.IsAbs(dir) {
+		dir = filepath.Join(fs.root, dir)
+	}
+	fs.streamDir = dir
+}
+
+// SetStreamLimit sets the size of the largest alternate data stream that
+// is held in an extended attribute. If limit is zero DefaultStreamLimit is
+// used.
+//
+// It must be called before the file system is used.
+func (fs *FS) SetStreamLimit(limit int64) {
+	fs.streamLimit = limit
+}
+
+// OpenStream opens the named alternate data stream of the named file.
+// Streams are held in the extended attribute of the file named
+// user.smb.stream.<stream>, or in the stream directory once they no longer
+// fit.
+func (fs *FS) OpenStream(name, stream string, flag int) (smbfs.File, error) {
+	return fs.openStream(fs.OpenFile, name, stream, flag)
+}
+
+// Streams returns information about the alternate data streams of the
+// named file.
+func (fs *FS) Streams(name string) ([]os.FileInfo, error) {
+	return fs.streams(fs.OpenFile, name)
+}
+
+// RemoveStream removes the named alternate data stream of the named file.
+func (fs *FS) RemoveStream(name, stream string) error {
+	return fs.removeStream(fs.OpenFile, name, stream)
+}
+
+// openStream opens the named stream of the named file, which is opened
+// with open and the access mode of flag.
+func (fs *FS) openStream(open opener, name, stream string, flag int) (smbfs.File, error) {
+	if !validStream(stream) {
+		return nil, ErrInvalidName
+	}
+	if fs.readOnly && flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, readOnlyError("open", name+":"+stream)
+	}
+	base, err := openBase(open, name, flag&(os.O_WRONLY|os.O_RDWR))
+	if err != nil {
+		return nil, err
+	}
+	f := &streamFile{
+		fs:       fs,
+		base:     base,
+		stream:   stream,
+		writable: flag&(os.O_WRONLY|os.O_RDWR) != 0,
+	}
+	if err := f.open(flag); err != nil {
+		base.Close()
+		return nil, &os.PathError{Op: "open", Path: name + ":" + stream, Err: err}
+	}
+	return f, nil
+}
+
+// streams returns information about the streams of the named file, which
+// is opened with open.
+func (fs *FS) streams(open opener, name string) ([]os.FileInfo, error) {
+	base, err := openBase(open, name, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer base.Close()
+	info, err := base.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	fs.streamMutex.Lock()
+	defer fs.streamMutex.Unlock()
+
+	var infos []os.FileInfo
+	attrs, err := flistxattr(base)
+	if err != nil && !attrUnsupported(err) {
+		return nil, err
+	}
+	for _, attr := range attrs {
+		if !strings.HasPrefix(attr, streamAttr) {
+			continue
+		}
+		size, err := fgetxattr(base, attr, nil)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, streamInfo{FileInfo: info, name: strings.TrimPrefix(attr, streamAttr), size: int64(size)})
+	}
+	dir, err := fs.sidecars(base, info)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), sidecarSuffix) {
+				continue
+			}
+			sidecar, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			infos = append(infos, streamInfo{FileInfo: info, name: strings.TrimSuffix(entry.Name(), sidecarSuffix), size: sidecar.Size()})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// removeStream removes the named stream of the named file, which is opened
+// with open.
+func (fs *FS) removeStream(open opener, name, stream string) error {
+	if !validStream(stream) {
+		return ErrInvalidName
+	}
+	if fs.readOnly {
+		return readOnlyError("remove", name+":"+stream)
+	}
+	base, err := openBase(open, name, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer base.Close()
+
+	fs.streamMutex.Lock()
+	defer fs.streamMutex.Unlock()
+
+	removed := false
+	if info, err := base.Stat(); err == nil {
+		dir, err := fs.sidecars(base, info)
+		if err != nil {
+			return err
+		}
+		if dir != "" {
+			err := os.Remove(filepath.Join(dir, stream+sidecarSuffix))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err == nil {
+				removed = true
+				removeEmptySidecars(dir)
+			}
+		}
+	}
+	err = fremovexattr(base, streamAttr+stream)
+	switch {
+	case err == nil:
+		removed = true
+	case !noAttr(err) && !attrUnsupported(err):
+		return err
+	}
+	if !removed {
+		return &os.PathError{Op: "remove", Path: name + ":" + stream, Err: os.ErrNotExist}
+	}
+	return nil
+}
+
+// removeSidecars removes the streams held in the stream directory for the
+// file described by info, which has been removed. Streams are kept while
+// other hard links to the file remain.
+func (fs *FS) removeSidecars(info os.FileInfo) {
+	if info == nil {
+		return
+	}
+	dir := fs.sidecarDir(info)
+	if dir == "" {
+		return
+	}
+	if ownership, ok := fs.Ownership(info); ok && !info.IsDir() && ownership.Links > 1 {
+		return
+	}
+	os.RemoveAll(dir)
+}
+
+// sidecarDir returns the directory within the stream directory that holds
+// the streams of the file described by info. It returns an empty string if
+// there is no stream directory or the file can't be identified.
+func (fs *FS) sidecarDir(info os.FileInfo) string {
+	if fs.streamDir == "" {
+		return ""
+	}
+	identity, ok := fs.Identify(info)
+	if !ok {
+		return ""
+	}
+	return filepath.Join(fs.streamDir, fmt.Sprintf("%x-%x", identity.Device, identity.Inode))
+}
+
+// sidecars returns the directory within the stream directory that holds
+// the streams of base, which info describes. A directory that records
+// another tag than base carries was left behind by a file that was removed
+// outside of the file system and whose inode number has been reused by
+// base, so it's discarded. It returns an empty string if the streams of
+// base can't be held in the stream directory. It must be called with
+// fs.streamMutex held.
+func (fs *FS) sidecars(base *os.File, info os.FileInfo) (string, error) {
+	dir := fs.sidecarDir(info)
+	if dir == "" {
+		return "", nil
+	}
+	tag, ok := sidecarTag(base)
+	if !ok {
+		return "", nil
+	}
+	record, err := os.ReadFile(filepath.Join(dir, sidecarRecord))
+	switch {
+	case err == nil && tag != "" && string(record) == tag:
+	case err == nil || os.IsNotExist(err):
+		if err := os.RemoveAll(dir); err != nil {
+			return "", err
+		}
+	default:
+		return "", err
+	}
+	return dir, nil
+}
+
+// removeEmptySidecars removes a directory within the stream directory once
+// it holds no streams.
+func removeEmptySidecars(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || entries[0].Name() != sidecarRecord {
+		return
+	}
+	os.Remove(filepath.Join(dir, sidecarRecord))
+	os.Remove(dir)
+}
+
+// maxStreamAttr returns the size of the largest stream that is held in an
+// extended attribute.
+func (fs *FS) maxStreamAttr() int64 {
+	if fs.streamLimit <= 0 {
+		return DefaultStreamLimit
+	}
+	return fs.streamLimit
+}
+
+// openBase opens the file that holds streams with the given access mode.
+func openBase(open opener, name string, flag int) (*os.File, error) {
+	file, err := open(name, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	base, ok := file.(*os.File)
+	if !ok {
+		file.Close()
+		return nil, smbfs.ErrStreamsNotSupported
+	}
+	return base, nil
+}
+
+// validStream returns true if stream is a valid name for a stream that can
+// be held in an extended attribute.
+func validStream(stream string) bool {
+	return stream != "" && !strings.ContainsAny(stream, "\x00:/\\") && len(streamAttr)+len(stream) <= maxAttrName
+}
+
+// streamFile is an open alternate data stream. The contents of streams
+// held in extended attributes are read and written in full by each
+// operation, and streams that grow too large are moved to the stream
+// directory, after which they're accessed like any other file.
+type streamFile struct {
+	fs       *FS
+	base     *os.File // The file the stream belongs to
+	stream   string
+	sidecar  string // The path of the stream within the stream directory, if any
+	writable bool
+
+	mutex sync.Mutex
+	file  *os.File // The stream within the stream directory, once it's there
+}
+
+// open opens or creates the stream according to flag.
+func (f *streamFile) open(flag int) error {
+	create := flag&os.O_CREATE != 0
+	exclusive := create && flag&os.O_EXCL != 0
+
+	f.fs.streamMutex.Lock()
+	defer f.fs.streamMutex.Unlock()
+
+	info, err := f.base.Stat()
+	if err != nil {
+		return err
+	}
+	dir, err := f.fs.sidecars(f.base, info)
+	if err != nil {
+		return err
+	}
+	if dir != "" {
+		f.sidecar = filepath.Join(dir, f.stream+sidecarSuffix)
+		if _, err := os.Stat(f.sidecar); err == nil {
+			if exclusive {
+				return os.ErrExist
+			}
+			return f.openSidecar(flag & os.O_TRUNC)
+		}
+	}
+
+	_, err = fgetxattr(f.base, f.attr(), nil)
+	switch {
+	case err == nil && exclusive:
+		return os.ErrExist
+	case err == nil && flag&os.O_TRUNC == 0:
+		return nil
+	case err != nil && !noAttr(err) && !attrUnsupported(err):
+		return err
+	case err != nil && !create:
+		return os.ErrNotExist
+	}
+	return f.store(nil)
+}
+
+// Name returns the name of the stream, prefixed by the path of its file.
+func (f *streamFile) Name() string {
+	return f.base.Name() + ":" + f.stream
+}
+
+// ReadAt reads len(p) bytes from the stream starting at offset off.
+func (f *streamFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &os.PathError{Op: "readat", Path: f.Name(), Err: os.ErrInvalid}
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	data, err := f.contents()
+	if err != nil {
+		return 0, err
+	}
+	if f.file != nil {
+		return f.file.ReadAt(p, off)
+	}
+	if off >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt writes len(p) bytes to the stream starting at offset off.
+func (f *streamFile) WriteAt(p []byte, off int64) (int, error) {
+	if !f.writable {
+		return 0, &os.PathError{Op: "write", Path: f.Name(), Err: os.ErrPermission}
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "writeat", Path: f.Name(), Err: os.ErrInvalid}
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	end := off + int64(len(p))
+	done, err := f.modify(end, func(data []byte) []byte {
+		if end > int64(len(data)) {
+			data = append(data, make([]byte, end-int64(len(data)))...)
+		}
+		copy(data[off:], p)
+		return data
+	})
+	switch {
+	case err != nil:
+		return 0, err
+	case done:
+		return len(p), nil
+	}
+	return f.file.WriteAt(p, off)
+}
+
+// Truncate changes the size of the stream.
+func (f *streamFile) Truncate(size int64) error {
+	if !f.writable {
+		return &os.PathError{Op: "truncate", Path: f.Name(), Err: os.ErrPermission}
+	}
+	if size < 0 {
+		return &os.PathError{Op: "truncate", Path: f.Name(), Err: os.ErrInvalid}
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	done, err := f.modify(size, func(data []byte) []byte {
+		if size <= int64(len(data)) {
+			return data[:size]
+		}
+		return append(data, make([]byte, size-int64(len(data)))...)
+	})
+	if err != nil || done {
+		return err
+	}
+	return f.file.Truncate(size)
+}
+
+// Stat returns information about the stream. Its Sys method returns that
+// of the file the stream belongs to.
+func (f *streamFile) Stat() (os.FileInfo, error) {
+	info, err := f.base.Stat()
+	if err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	data, err := f.contents()
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(data))
+	if f.file != nil {
+		sidecar, err := f.file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		size = sidecar.Size()
+	}
+	return streamInfo{FileInfo: info, name: f.stream, size: size}, nil
+}
+
+// Readdir fails, because streams aren't directories.
+func (f *streamFile) Readdir(n int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.Name(), Err: os.ErrInvalid}
+}
+
+// Sync commits the stream to stable storage if it is held in the stream
+// directory. Extended attributes are written as soon as they change.
+func (f *streamFile) Sync() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file != nil {
+		return f.file.Sync()
+	}
+	return nil
+}
+
+// Close closes the stream and the file it belongs to.
+func (f *streamFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+	}
+	if berr := f.base.Close(); err == nil {
+		err = berr
+	}
+	return err
+}
+
+// attr returns the name of the extended attribute that holds the stream.
+func (f *streamFile) attr() string {
+	return streamAttr + f.stream
+}
+
+// contents returns the contents of a stream held in an extended attribute.
+// If the stream has been moved to the stream directory it opens the
+// stream there instead. It must be called with f.mutex held.
+func (f *streamFile) contents() ([]byte, error) {
+	if f.file != nil {
+		return nil, nil
+	}
+	f.fs.streamMutex.Lock()
+	defer f.fs.streamMutex.Unlock()
+	return f.load()
+}
+
+// modify replaces the contents of a stream held in an extended attribute
+// with the result of change, which is called with the current contents. If
+// the stream would be end bytes long and that's more than the stream
+// limit, the stream is moved to the stream directory unchanged instead. It
+// returns true if the stream was changed, and false if it must be changed
+// in the stream directory. It must be called with f.mutex held.
+func (f *streamFile) modify(end int64, change func([]byte) []byte) (bool, error) {
+	if f.file != nil {
+		return false, nil
+	}
+	f.fs.streamMutex.Lock()
+	defer f.fs.streamMutex.Unlock()
+
+	data, err := f.load()
+	if err != nil || f.file != nil {
+		return false, err
+	}
+	if end > f.fs.maxStreamAttr() {
+		return false, f.move(data, smbfs.ErrStreamTooLarge)
+	}
+	return true, f.store(change(data))
+}
+
+// load reads the extended attribute that holds the stream. It must be
+// called with f.fs.streamMutex held.
+func (f *streamFile) load() ([]byte, error) {
+	for {
+		size, err := fgetxattr(f.base, f.attr(), nil)
+		if err == nil && size > 0 {
+			data := make([]byte, size)
+			size, err = fgetxattr(f.base, f.attr(), data)
+			if err == nil {
+				return data[:size], nil
+			}
+		}
+		switch {
+		case err == nil:
+			return nil, nil
+		case noAttr(err) || attrUnsupported(err):
+			return nil, f.follow()
+		case !attrFull(err):
+			return nil, err
+		}
+		// The stream grew between the two reads
+	}
+}
+
+// follow opens a stream that another open has moved to the stream
+// directory. It must be called with f.fs.streamMutex held.
+func (f *streamFile) follow() error {
+	if f.sidecar != "" {
+		if _, err := os.Stat(f.sidecar); err == nil {
+			return f.openSidecar(0)
+		}
+	}
+	return &os.PathError{Op: "read", Path: f.Name(), Err: os.ErrNotExist}
+}
+
+// store writes data to the extended attribute that holds the stream, or
+// moves the stream to the stream directory if the attribute can't hold it.
+// It must be called with f.fs.streamMutex held.
+func (f *streamFile) store(data []byte) error {
+	if int64(len(data)) > f.fs.maxStreamAttr() {
+		return f.move(data, smbfs.ErrStreamTooLarge)
+	}
+	err := fsetxattr(f.base, f.attr(), data)
+	switch {
+	case err == nil:
+		return nil
+	case attrFull(err):
+		return f.move(data, smbfs.ErrStreamTooLarge)
+	case attrUnsupported(err):
+		return f.move(data, smbfs.ErrStreamsNotSupported)
+	default:
+		return err
+	}
+}
+
+// move writes data to the stream directory and removes the extended
+// attribute that held the stream. If there is no stream directory it
+// returns reason. It must be called with f.fs.streamMutex held.
+func (f *streamFile) move(data []byte, reason error) error {
+	if f.sidecar == "" {
+		return reason
+	}
+	tag, err := tagSidecars(f.base)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(f.sidecar)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, sidecarRecord), []byte(tag), 0600); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.sidecar, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteAt(data, 0); err != nil {
+		file.Close()
+		os.Remove(f.sidecar)
+		removeEmptySidecars(dir)
+		return err
+	}
+	if err := fremovexattr(f.base, f.attr()); err != nil && !noAttr(err) && !attrUnsupported(err) {
+		file.Close()
+		os.Remove(f.sidecar)
+		removeEmptySidecars(dir)
+		return err
+	}
+	f.file = file
+	return nil
+}
+
+// openSidecar opens the stream within the stream directory with the given
+// additional flags.
+func (f *streamFile) openSidecar(flag int) error {
+	if f.writable {
+		flag |= os.O_RDWR
+	}
+	file, err := os.OpenFile(f.sidecar, flag, 0)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	return nil
+}
+
+// streamInfo describes an alternate data stream. It embeds the information
+// of the file the stream belongs to, which identifies the file.
+type streamInfo struct {
+	os.FileInfo
+	name string
+	size int64
+}
+
+// Name returns the name of the stream.
+func (i streamInfo) Name() string { return i.name }
+
+// Size returns the size of the stream in bytes.
+func (i streamInfo) Size() int64 { return i.size }
+
+// Mode returns the permissions of the file the stream belongs to.
+func (i streamInfo) Mode() os.FileMode { return i.FileInfo.Mode() &^ os.ModeType }
+
+// IsDir returns false, because streams aren't directories.
+func (i streamInfo) IsDir() bool { return false }
@@ -0,0 +1,135 @@
+package smbosfs_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+)
+
+func TestStreamLimit(t *testing.T) {
+	root, sidecars := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file.txt"), []byte("contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	limited := smbosfs.New(root)
+	limited.SetStreamLimit(16)
+
+	stream, err := limited.OpenStream("file.txt", "extra", os.O_RDWR|os.O_CREATE)
+	if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, smbfs.ErrStreamsNotSupported) {
+		t.Skip("extended attributes are not supported")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if _, err := stream.WriteAt([]byte("0123456789"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.WriteAt([]byte("0123456789"), 10); !errors.Is(err, smbfs.ErrStreamTooLarge) {
+		t.Fatalf("a write beyond the limit returned %v (want %v)", err, smbfs.ErrStreamTooLarge)
+	}
+
+	// Streams that outgrow the limit move to the stream directory
+	fs := smbosfs.New(root)
+	fs.SetStreamLimit(16)
+	fs.SetStreamDir(sidecars)
+	reader, err := fs.OpenStream("file.txt", "extra", os.O_RDONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	writer, err := fs.OpenStream("file.txt", "extra", os.O_RDWR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if _, err := writer.WriteAt([]byte("0123456789"), 10); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 20)
+	if n, err := reader.ReadAt(buf, 0); n != 20 || string(buf) != "01234567890123456789" {
+		t.Fatalf("another open of the stream read %q, %v", buf[:n], err)
+	}
+	if info, err := reader.Stat(); err != nil || info.Size() != 20 || info.Name() != "extra" {
+		t.Fatalf("Stat returned %v, %v", info, err)
+	}
+
+	infos, err := fs.Streams("file.txt")
+	if err != nil || len(infos) != 1 || infos[0].Name() != "extra" || infos[0].Size() != 20 {
+		t.Fatalf("Streams returned %v, %v", infos, err)
+	}
+	if entries, _ := os.ReadDir(sidecars); len(entries) != 1 {
+		t.Fatalf("the stream directory holds %d entries (want 1)", len(entries))
+	}
+
+	// Streams are removed along with their file
+	if err := fs.Remove("file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(sidecars); len(entries) != 0 {
+		t.Fatalf("the stream directory holds %d entries after the file was removed", len(entries))
+	}
+}
+
+func TestStreamDirReuse(t *testing.T) {
+	root, sidecars := t.TempDir(), t.TempDir()
+	fs := smbosfs.New(root)
+	fs.SetStreamLimit(16)
+	fs.SetStreamDir(sidecars)
+	for _, name := range []string{"old.txt", "new.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream, err := fs.OpenStream("old.txt", "extra", os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.WriteAt([]byte("01234567890123456789"), 0)
+	stream.Close()
+	if errors.Is(err, smbfs.ErrStreamTooLarge) {
+		t.Skip("extended attributes are not supported")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(sidecars)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("the stream directory holds %d entries, %v", len(entries), err)
+	}
+
+	// A file that was removed outside of the file system leaves its streams
+	// behind, where they'd be found by a new file given its inode number
+	if err := os.Remove(filepath.Join(root, "old.txt")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(root, "new.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, ok := fs.Identify(info)
+	if !ok {
+		t.Skip("files can't be identified")
+	}
+	reused := filepath.Join(sidecars, fmt.Sprintf("%x-%x", identity.Device, identity.Inode))
+	if err := os.Rename(filepath.Join(sidecars, entries[0].Name()), reused); err != nil {
+		t.Fatal(err)
+	}
+
+	if infos, err := fs.Streams("new.txt"); err != nil || len(infos) != 0 {
+		t.Fatalf("Streams returned %v, %v for a file without streams", infos, err)
+	}
+	if _, err := os.Stat(reused); !os.IsNotExist(err) {
+		t.Fatalf("the streams left behind weren't discarded: %v", err)
+	}
+	if _, err := fs.OpenStream("new.txt", "extra", os.O_RDONLY); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("open of a stream left behind returned %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build darwin || freebsd || netbsd
+
+package smbosfs
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// sidecarTag returns the tag that identifies file to its directory within
+// the stream directory, which is its birth time followed by its inode
+// generation number. It returns false if the file system doesn't record
+// birth times.
+func sidecarTag(file *os.File) (string, bool) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", false
+	}
+	sec, nsec := st.Birthtimespec.Unix()
+	if sec <= 0 && nsec == 0 {
+		return "", false
+	}
+	return strconv.FormatInt(sec, 10) + "." + strconv.FormatInt(nsec, 10) + "-" + strconv.FormatUint(uint64(st.Gen), 10), true
+}
+
+// tagSidecars returns the tag of file, which is recorded by the file
+// system when file is created.
+func tagSidecars(file *os.File) (string, error) {
+	if tag, ok := sidecarTag(file); ok {
+		return tag, nil
+	}
+	return "", smbfs.ErrStreamsNotSupported
+}
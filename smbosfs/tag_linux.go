@@ -0,0 +1,45 @@
+package smbosfs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+)
+
+// tagAttr is the extended attribute that holds the tag of a file whose
+// streams are held in the stream directory.
+const tagAttr = "user.smb.sidecar"
+
+// sidecarTag returns the tag that identifies file to its directory within
+// the stream directory, or an empty string if file hasn't been tagged. New
+// files never carry a tag, even when they're given the inode number of a
+// removed file. It returns false if file can't be tagged because its file
+// system doesn't support extended attributes.
+func sidecarTag(file *os.File) (string, bool) {
+	buf := make([]byte, hex.EncodedLen(16))
+	n, err := fgetxattr(file, tagAttr, buf)
+	switch {
+	case err == nil:
+		return string(buf[:n]), true
+	case noAttr(err) || attrFull(err):
+		return "", true
+	}
+	return "", false
+}
+
+// tagSidecars returns the tag of file, tagging it with a random tag first
+// if it hasn't been tagged.
+func tagSidecars(file *os.File) (string, error) {
+	if tag, ok := sidecarTag(file); ok && tag != "" {
+		return tag, nil
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	tag := hex.EncodeToString(id)
+	if err := fsetxattr(file, tagAttr, []byte(tag)); err != nil {
+		return "", err
+	}
+	return tag, nil
+}
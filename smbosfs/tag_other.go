@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd && !netbsd
+
+package smbosfs
+
+import (
+	"os"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+)
+
+// sidecarTag returns false on this platform, which keeps streams out of the
+// stream directory.
+func sidecarTag(file *os.File) (string, bool) {
+	return "", false
+}
+
+// tagSidecars returns smbfs.ErrStreamsNotSupported on this platform.
+func tagSidecars(file *os.File) (string, error) {
+	return "", smbfs.ErrStreamsNotSupported
+}
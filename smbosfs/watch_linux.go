@@ -0,0 +1,254 @@
+package smbosfs
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbnotify"
+)
+
+// watchEventBuffer is the number of events that can be queued by a watch
+// before it overflows.
+const watchEventBuffer = 256
+
+// watchMask is the set of inotify events that are monitored for each
+// directory.
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_ONLYDIR | syscall.IN_EXCL_UNLINK
+
+// Watch starts watching the named directory for changes using inotify. If
+// recursive is true every subdirectory is watched as well.
+func (fs *FS) Watch(name string, recursive bool) (smbfs.Watch, error) {
+	root, err := fs.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	w := &inotifyWatch{
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		fd:        fd,
+		root:      root,
+		recursive: recursive,
+		dirs:      make(map[int32]string),
+		events:    make(chan smbfs.Event, watchEventBuffer),
+	}
+
+	if err := w.add(""); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// inotifyWatch is a watch on a directory that is implemented with inotify.
+type inotifyWatch struct {
+	file      *os.File
+	fd        int
+	root      string
+	recursive bool
+
+	mutex sync.Mutex
+	dirs  map[int32]string // Maps watch descriptors to relative paths
+
+	events   chan smbfs.Event
+	overflow bool // True when events have been dropped
+}
+
+// Events returns a channel that receives change events.
+func (w *inotifyWatch) Events() <-chan smbfs.Event {
+	return w.events
+}
+
+// Close stops the watch and releases its resources.
+func (w *inotifyWatch) Close() error {
+	return w.file.Close()
+}
+
+// add adds a watch for the directory at the given relative path. If the
+// watch is recursive its subdirectories are added as well.
+func (w *inotifyWatch) add(rel string) error {
+	dir := w.root
+	if rel != "" {
+		dir = filepath.Join(w.root, filepath.FromSlash(rel))
+	}
+
+	if !w.recursive {
+		return w.addDir(dir, rel)
+	}
+
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir {
+				return err
+			}
+			// The entry may have been removed during the walk
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		sub, err := filepath.Rel(w.root, p)
+		if err != nil {
+			return err
+		}
+		if sub == "." {
+			sub = ""
+		}
+		if err := w.addDir(p, filepath.ToSlash(sub)); err != nil && p == dir {
+			return err
+		}
+		return nil
+	})
+}
+
+// addDir adds an inotify watch for a single directory.
+func (w *inotifyWatch) addDir(dir, rel string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, watchMask)
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	w.mutex.Lock()
+	w.dirs[int32(wd)] = rel
+	w.mutex.Unlock()
+	return nil
+}
+
+// removeTree removes the watches for the directory at the given relative
+// path and all of its subdirectories.
+func (w *inotifyWatch) removeTree(rel string) {
+	prefix := rel + "/"
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for wd, dir := range w.dirs {
+		if dir == rel || strings.HasPrefix(dir, prefix) {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+// run reads inotify events until the watch is closed.
+func (w *inotifyWatch) run() {
+	defer close(w.events)
+
+	var buf [64 * (syscall.SizeofInotifyEvent + syscall.NAME_MAX + 1)]byte
+	for {
+		n, err := w.file.Read(buf[:])
+		if err != nil {
+			return
+		}
+		w.parse(buf[:n])
+	}
+}
+
+// parse interprets a buffer of raw inotify events.
+func (w *inotifyWatch) parse(b []byte) {
+	for len(b) >= syscall.SizeofInotifyEvent {
+		var (
+			wd     = int32(binary.NativeEndian.Uint32(b[0:4]))
+			mask   = binary.NativeEndian.Uint32(b[4:8])
+			length = binary.NativeEndian.Uint32(b[12:16])
+			end    = syscall.SizeofInotifyEvent + int(length)
+		)
+		if end > len(b) {
+			return
+		}
+		name := strings.TrimRight(string(b[syscall.SizeofInotifyEvent:end]), "\x00")
+		b = b[end:]
+
+		if mask&syscall.IN_Q_OVERFLOW != 0 {
+			w.overflow = true
+			w.flush()
+			continue
+		}
+
+		w.mutex.Lock()
+		dir, ok := w.dirs[wd]
+		if mask&syscall.IN_IGNORED != 0 {
+			delete(w.dirs, wd)
+		}
+		w.mutex.Unlock()
+		if !ok || name == "" {
+			continue
+		}
+
+		rel := path.Join(dir, name)
+		isDir := mask&syscall.IN_ISDIR != 0
+
+		nameFilter := smbnotify.Filter(smbnotify.FileName)
+		if isDir {
+			nameFilter = smbnotify.DirName
+		}
+
+		switch {
+		case mask&syscall.IN_CREATE != 0:
+			if isDir && w.recursive {
+				w.add(rel)
+			}
+			w.send(smbfs.Event{Action: smbnotify.Added, Filter: nameFilter, Name: rel})
+		case mask&syscall.IN_DELETE != 0:
+			w.send(smbfs.Event{Action: smbnotify.Removed, Filter: nameFilter, Name: rel})
+		case mask&syscall.IN_MOVED_FROM != 0:
+			if isDir && w.recursive {
+				w.removeTree(rel)
+			}
+			w.send(smbfs.Event{Action: smbnotify.RenamedOldName, Filter: nameFilter, Name: rel})
+		case mask&syscall.IN_MOVED_TO != 0:
+			if isDir && w.recursive {
+				w.add(rel)
+			}
+			w.send(smbfs.Event{Action: smbnotify.RenamedNewName, Filter: nameFilter, Name: rel})
+		case mask&syscall.IN_MODIFY != 0:
+			w.send(smbfs.Event{Action: smbnotify.Modified, Filter: smbnotify.Size | smbnotify.LastWrite, Name: rel})
+		case mask&syscall.IN_ATTRIB != 0:
+			const filter = smbnotify.Attributes | smbnotify.LastWrite | smbnotify.LastAccess |
+				smbnotify.Creation | smbnotify.Security | smbnotify.EA
+			w.send(smbfs.Event{Action: smbnotify.Modified, Filter: filter, Name: rel})
+		}
+	}
+}
+
+// send attempts to queue an event without blocking. If the queue is full
+// the event is dropped and an overflow event will be sent when space is
+// available.
+func (w *inotifyWatch) send(ev smbfs.Event) {
+	if !w.flush() {
+		return
+	}
+	select {
+	case w.events <- ev:
+	default:
+		w.overflow = true
+	}
+}
+
+// flush attempts to queue a pending overflow event. It returns true if
+// there is no overflow pending.
+func (w *inotifyWatch) flush() bool {
+	if !w.overflow {
+		return true
+	}
+	select {
+	case w.events <- smbfs.Event{Overflow: true}:
+		w.overflow = false
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,41 @@
+package smbosfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbnotify"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+)
+
+func TestWatch(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := smbosfs.New(root)
+	w, err := fs.Watch(".", true)
+	if err != nil {
+		t.Fatalf("Watch returned %v", err)
+	}
+	defer w.Close()
+
+	if err := os.WriteFile(filepath.Join(root, "sub", "file.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-w.Events():
+		if ev.Action != smbnotify.Added || ev.Name != "sub/file.txt" {
+			t.Fatalf("received %s %q (want %s %q)", ev.Action, ev.Name, smbnotify.Action(smbnotify.Added), "sub/file.txt")
+		}
+		if !ev.Filter.Match(smbnotify.FileName) {
+			t.Fatalf("received filter %s (want %s)", ev.Filter, smbnotify.Filter(smbnotify.FileName))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
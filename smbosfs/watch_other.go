@@ -0,0 +1,10 @@
+//go:build !linux
+
+package smbosfs
+
+import "github.com/gentlemanautomaton/smb/smbfs"
+
+// Watch returns smbfs.ErrWatchNotSupported on this platform.
+func (fs *FS) Watch(name string, recursive bool) (smbfs.Watch, error) {
+	return nil, smbfs.ErrWatchNotSupported
+}
@@ -0,0 +1,101 @@
+package smbosfs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Extended attributes of open files are accessed through the names of the
+// files with the wrappers of the syscall package, as sparse marks are.
+
+// fgetxattr reads the value of the named extended attribute of file into
+// dest and returns its size. If dest is empty it returns the size of the
+// value without reading it.
+func fgetxattr(file *os.File, attr string, dest []byte) (int, error) {
+	var size int
+	err := retryxattr("getxattr", func() (err error) {
+		size, err = syscall.Getxattr(file.Name(), attr, dest)
+		return err
+	})
+	return size, err
+}
+
+// fsetxattr creates or replaces the named extended attribute of file.
+func fsetxattr(file *os.File, attr string, data []byte) error {
+	return retryxattr("setxattr", func() error {
+		return syscall.Setxattr(file.Name(), attr, data, 0)
+	})
+}
+
+// fremovexattr removes the named extended attribute of file.
+func fremovexattr(file *os.File, attr string) error {
+	return retryxattr("removexattr", func() error {
+		return syscall.Removexattr(file.Name(), attr)
+	})
+}
+
+// flistxattr returns the names of the extended attributes of file.
+func flistxattr(file *os.File) ([]string, error) {
+	for {
+		var size int
+		err := retryxattr("listxattr", func() (err error) {
+			size, err = syscall.Listxattr(file.Name(), nil)
+			return err
+		})
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		err = retryxattr("listxattr", func() (err error) {
+			size, err = syscall.Listxattr(file.Name(), buf)
+			return err
+		})
+		if errors.Is(err, syscall.ERANGE) {
+			// An attribute was added between the two calls
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, name := range bytes.Split(buf[:size], []byte{0}) {
+			if len(name) > 0 {
+				names = append(names, string(name))
+			}
+		}
+		return names, nil
+	}
+}
+
+// retryxattr calls op, retrying it if it is interrupted.
+func retryxattr(name string, op func() error) error {
+	for {
+		err := op()
+		switch {
+		case err == nil:
+			return nil
+		case err != syscall.EINTR:
+			return os.NewSyscallError(name, err)
+		}
+	}
+}
+
+// noAttr returns true if err reports that an extended attribute doesn't
+// exist.
+func noAttr(err error) bool {
+	return errors.Is(err, syscall.ENODATA)
+}
+
+// attrFull returns true if err reports that an extended attribute value
+// is too large for the file system, or for the buffer it was read into.
+func attrFull(err error) bool {
+	return errors.Is(err, syscall.E2BIG) || errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.ERANGE)
+}
+
+// attrUnsupported returns true if err reports that the file system
+// doesn't support extended attributes.
+func attrUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP)
+}
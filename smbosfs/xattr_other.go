@@ -0,0 +1,48 @@
+//go:build !linux
+
+package smbosfs
+
+import (
+	"errors"
+	"os"
+)
+
+// errNoXattrs is returned by extended attribute operations on platforms
+// where they aren't implemented, which causes streams to be held in the
+// stream directory.
+var errNoXattrs = errors.New("smbosfs: extended attributes are not supported")
+
+// fgetxattr returns errNoXattrs on this platform.
+func fgetxattr(file *os.File, attr string, dest []byte) (int, error) {
+	return 0, errNoXattrs
+}
+
+// fsetxattr returns errNoXattrs on this platform.
+func fsetxattr(file *os.File, attr string, data []byte) error {
+	return errNoXattrs
+}
+
+// fremovexattr returns errNoXattrs on this platform.
+func fremovexattr(file *os.File, attr string) error {
+	return errNoXattrs
+}
+
+// flistxattr returns errNoXattrs on this platform.
+func flistxattr(file *os.File) ([]string, error) {
+	return nil, errNoXattrs
+}
+
+// noAttr returns false on this platform.
+func noAttr(err error) bool {
+	return false
+}
+
+// attrFull returns false on this platform.
+func attrFull(err error) bool {
+	return false
+}
+
+// attrUnsupported returns true if err is errNoXattrs.
+func attrUnsupported(err error) bool {
+	return errors.Is(err, errNoXattrs)
+}
@@ -0,0 +1,115 @@
+package smbpacket
+
+import "errors"
+
+// Alignment is the byte alignment required for each packet within a
+// compounded chain.
+const Alignment = 8
+
+var (
+	// ErrTruncated is returned when a compounded chain ends before the
+	// packet at a given offset is complete.
+	ErrTruncated = errors.New("smbpacket: compound: truncated packet")
+
+	// ErrMisaligned is returned when the next command offset of a packet
+	// within a compounded chain is not 8-byte aligned.
+	ErrMisaligned = errors.New("smbpacket: compound: next command offset is not 8-byte aligned")
+
+	// ErrBadNextCommand is returned when the next command offset of a packet
+	// within a compounded chain is too small to hold a packet header or
+	// extends beyond the end of the chain.
+	ErrBadNextCommand = errors.New("smbpacket: compound: next command offset is out of bounds")
+)
+
+// Align returns length rounded up to the nearest multiple of Alignment.
+func Align(length int) int {
+	return (length + Alignment - 1) &^ (Alignment - 1)
+}
+
+// Compound interprets a slice of bytes as a chain of one or more SMB request
+// packets that have been compounded into a single message. Each packet in the
+// chain indicates the offset of the next with its NextCommand field.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/46dd4182-62d3-4e30-9fe5-e2ec124edca1
+type Compound []byte
+
+// Valid returns true if every packet in the chain has a valid header and
+// every next command offset is aligned and in bounds.
+func (c Compound) Valid() bool {
+	offset := uint32(0)
+	for {
+		if !c.Member(offset).Header().Valid() {
+			return false
+		}
+		next, err := c.Next(offset)
+		if err != nil {
+			return false
+		}
+		if next == 0 {
+			return true
+		}
+		offset = next
+	}
+}
+
+// Member returns the request packet at the given offset within the chain.
+// The returned packet is truncated to the length indicated by its
+// NextCommand field when that field is in bounds.
+//
+// Member returns nil if the chain is not long enough to hold a packet header
+// at offset.
+func (c Compound) Member(offset uint32) Request {
+	start := uint(offset)
+	if start+HeaderSize > uint(len(c)) {
+		return nil
+	}
+	end := uint(len(c))
+	if next := uint(RequestHeader(c[start : start+HeaderSize]).NextCommand()); next != 0 {
+		if next >= HeaderSize && start+next <= end {
+			end = start + next
+		}
+	}
+	return Request(c[start:end:end])
+}
+
+// Next returns the offset of the packet that follows the packet at the
+// given offset. It returns zero if the packet at offset is the last packet
+// in the chain.
+//
+// Next returns an error if the packet at offset is truncated or if its
+// NextCommand field is misaligned or out of bounds.
+func (c Compound) Next(offset uint32) (next uint32, err error) {
+	start := uint(offset)
+	if start+HeaderSize > uint(len(c)) {
+		return 0, ErrTruncated
+	}
+	length := uint(RequestHeader(c[start : start+HeaderSize]).NextCommand())
+	switch {
+	case length == 0:
+		return 0, nil
+	case length%Alignment != 0:
+		return 0, ErrMisaligned
+	case length < HeaderSize, start+length+HeaderSize > uint(len(c)):
+		return 0, ErrBadNextCommand
+	}
+	return offset + uint32(length), nil
+}
+
+// Count returns the number of packets in the chain. If the chain is invalid
+// the count includes the packets up to and including the first invalid
+// packet.
+func (c Compound) Count() int {
+	count := 0
+	offset := uint32(0)
+	for {
+		if c.Member(offset) == nil {
+			return count
+		}
+		count++
+		next, err := c.Next(offset)
+		if err != nil || next == 0 {
+			return count
+		}
+		offset = next
+	}
+}
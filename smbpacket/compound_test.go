@@ -0,0 +1,96 @@
+package smbpacket_test
+
+import (
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbpacket"
+)
+
+// makeChain builds a compounded chain from a list of packet lengths. Each
+// packet's NextCommand field is set to the given offset.
+func makeChain(lengths []int, nexts []uint32) smbpacket.Compound {
+	total := 0
+	for _, length := range lengths {
+		total += length
+	}
+	b := make([]byte, total)
+	offset := 0
+	for i, length := range lengths {
+		if length < smbpacket.HeaderSize {
+			break
+		}
+		hdr := smbpacket.RequestHeader(b[offset : offset+smbpacket.HeaderSize])
+		hdr.SetProtocol(smbpacket.SMB2)
+		hdr.SetSize(smbpacket.HeaderSize)
+		hdr.SetNextCommand(nexts[i])
+		offset += length
+	}
+	return smbpacket.Compound(b)
+}
+
+type compoundTest struct {
+	Name    string
+	Lengths []int
+	Nexts   []uint32
+	Valid   bool
+	Count   int
+	Err     error
+}
+
+var compoundTests = []compoundTest{
+	{"single", []int{72}, []uint32{0}, true, 1, nil},
+	{"pair", []int{72, 80}, []uint32{72, 0}, true, 2, nil},
+	{"triple", []int{104, 88, 65}, []uint32{104, 88, 0}, true, 3, nil},
+	{"misaligned", []int{70, 72}, []uint32{70, 0}, false, 1, smbpacket.ErrMisaligned},
+	{"short", []int{72, 72}, []uint32{32, 0}, false, 1, smbpacket.ErrBadNextCommand},
+	{"overflow", []int{72, 72}, []uint32{152, 0}, false, 1, smbpacket.ErrBadNextCommand},
+	{"truncated", []int{72, 40}, []uint32{72, 0}, false, 1, smbpacket.ErrBadNextCommand},
+}
+
+func TestCompound(t *testing.T) {
+	for _, tt := range compoundTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			c := makeChain(tt.Lengths, tt.Nexts)
+			if valid := c.Valid(); valid != tt.Valid {
+				t.Errorf("Valid() = %v (want %v)", valid, tt.Valid)
+			}
+			if count := c.Count(); count != tt.Count {
+				t.Errorf("Count() = %d (want %d)", count, tt.Count)
+			}
+
+			offset := uint32(0)
+			for i := 0; ; i++ {
+				member := c.Member(offset)
+				if member == nil {
+					t.Fatalf("Member(%d) = nil", offset)
+				}
+				next, err := c.Next(offset)
+				if err != nil {
+					if err != tt.Err {
+						t.Fatalf("Next(%d) returned %v (want %v)", offset, err, tt.Err)
+					}
+					return
+				}
+				if length := len(member); i+1 < len(tt.Lengths) && length != tt.Lengths[i] {
+					t.Errorf("Member(%d) has length %d (want %d)", offset, length, tt.Lengths[i])
+				}
+				if next == 0 {
+					break
+				}
+				offset = next
+			}
+			if tt.Err != nil {
+				t.Fatalf("Next did not return %v", tt.Err)
+			}
+		})
+	}
+}
+
+func TestAlign(t *testing.T) {
+	tests := [][2]int{{0, 0}, {1, 8}, {7, 8}, {8, 8}, {65, 72}, {72, 72}, {73, 80}}
+	for _, tt := range tests {
+		if aligned := smbpacket.Align(tt[0]); aligned != tt[1] {
+			t.Errorf("Align(%d) = %d (want %d)", tt[0], aligned, tt[1])
+		}
+	}
+}
@@ -2,6 +2,7 @@ package smbpacket
 
 import (
 	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbstatus"
 	"github.com/gentlemanautomaton/smb/smbtype"
 )
 
@@ -81,8 +82,8 @@ func (h RequestHeader) SetChannelSequence(sequence uint16) {
 // Status returns the status from the request.
 //
 // This field is only valid in the SMB 2.0.2 and 2.1 dialects. It must be 0.
-func (h RequestHeader) Status() uint32 {
-	return smbtype.Uint32(h[8:12])
+func (h RequestHeader) Status() smbstatus.Code {
+	return smbstatus.Code(smbtype.Uint32(h[8:12]))
 }
 
 // Command returns the command code of the request.
@@ -171,8 +172,8 @@ func (h RequestHeader) SessionID() uint64 {
 }
 
 // SetSessionID sets the session ID of the request.
-func (h RequestHeader) SetSessionID(tree uint32) {
-	smbtype.PutUint32(h[40:48], tree)
+func (h RequestHeader) SetSessionID(session uint64) {
+	smbtype.PutUint64(h[40:48], session)
 }
 
 // Signature returns the cryptographic signature of the request.
@@ -2,6 +2,7 @@ package smbpacket
 
 import (
 	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbstatus"
 	"github.com/gentlemanautomaton/smb/smbtype"
 )
 
@@ -65,14 +66,14 @@ func (h ResponseHeader) SetCreditCharge(charge uint16) {
 
 // Status returns the status from the response. It indicates the success or
 // failure of the command.
-func (h ResponseHeader) Status() uint32 {
-	return smbtype.Uint32(h[8:12])
+func (h ResponseHeader) Status() smbstatus.Code {
+	return smbstatus.Code(smbtype.Uint32(h[8:12]))
 }
 
 // SetStatus sets the status of the response. It indicates the success or
 // failure of the command.
-func (h ResponseHeader) SetStatus(status uint32) {
-	smbtype.PutUint32(h[8:12], status)
+func (h ResponseHeader) SetStatus(status smbstatus.Code) {
+	smbtype.PutUint32(h[8:12], uint32(status))
 }
 
 // Command returns the command code of the response.
@@ -161,8 +162,8 @@ func (h ResponseHeader) SessionID() uint64 {
 }
 
 // SetSessionID sets the session ID of the response.
-func (h ResponseHeader) SetSessionID(tree uint32) {
-	smbtype.PutUint32(h[40:48], tree)
+func (h ResponseHeader) SetSessionID(session uint64) {
+	smbtype.PutUint64(h[40:48], session)
 }
 
 // Signature returns the cryptographic signature of the response.
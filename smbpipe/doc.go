@@ -0,0 +1,5 @@
+// Package smbpipe provides types for the named pipe control requests and
+// replies that are carried by FSCTL_PIPE_WAIT and FSCTL_PIPE_PEEK.
+// FSCTL_PIPE_TRANSCEIVE carries raw pipe messages and needs no types of its
+// own.
+package smbpipe
@@ -0,0 +1,70 @@
+package smbpipe
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// PeekReplySize is the number of bytes required for the fixed portion of an
+// FSCTL_PIPE_PEEK reply.
+const PeekReplySize = 16
+
+// PeekReply interprets a slice of bytes as an FSCTL_PIPE_PEEK reply, which
+// describes the data waiting to be read from a named pipe and holds as much
+// of its first message as fits.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.3 (FSCTL_PIPE_PEEK)
+type PeekReply []byte
+
+// Valid returns true if the reply is valid.
+func (r PeekReply) Valid() bool {
+	return len(r) >= PeekReplySize
+}
+
+// State returns the state of the pipe.
+func (r PeekReply) State() State {
+	return State(smbtype.Uint32(r[0:4]))
+}
+
+// SetState sets the state of the pipe.
+func (r PeekReply) SetState(state State) {
+	smbtype.PutUint32(r[0:4], uint32(state))
+}
+
+// ReadDataAvailable returns the number of bytes waiting to be read from the
+// pipe.
+func (r PeekReply) ReadDataAvailable() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetReadDataAvailable sets the number of bytes waiting to be read from the
+// pipe.
+func (r PeekReply) SetReadDataAvailable(n uint32) {
+	smbtype.PutUint32(r[4:8], n)
+}
+
+// NumberOfMessages returns the number of messages waiting to be read from
+// the pipe.
+func (r PeekReply) NumberOfMessages() uint32 {
+	return smbtype.Uint32(r[8:12])
+}
+
+// SetNumberOfMessages sets the number of messages waiting to be read from
+// the pipe.
+func (r PeekReply) SetNumberOfMessages(n uint32) {
+	smbtype.PutUint32(r[8:12], n)
+}
+
+// MessageLength returns the length of the first message waiting to be read
+// from the pipe.
+func (r PeekReply) MessageLength() uint32 {
+	return smbtype.Uint32(r[12:16])
+}
+
+// SetMessageLength sets the length of the first message waiting to be read
+// from the pipe.
+func (r PeekReply) SetMessageLength(length uint32) {
+	smbtype.PutUint32(r[12:16], length)
+}
+
+// Data returns the data that follows the fixed portion of the reply.
+func (r PeekReply) Data() []byte {
+	return r[PeekReplySize:]
+}
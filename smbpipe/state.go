@@ -0,0 +1,30 @@
+package smbpipe
+
+import "strconv"
+
+// State is the state of a named pipe.
+type State uint32
+
+// Named pipe states.
+const (
+	Disconnected = 0x00000001 // FILE_PIPE_DISCONNECTED_STATE
+	Listening    = 0x00000002 // FILE_PIPE_LISTENING_STATE
+	Connected    = 0x00000003 // FILE_PIPE_CONNECTED_STATE
+	Closing      = 0x00000004 // FILE_PIPE_CLOSING_STATE
+)
+
+// String returns a string representation of the pipe state.
+func (s State) String() string {
+	switch s {
+	case Disconnected:
+		return "Disconnected"
+	case Listening:
+		return "Listening"
+	case Connected:
+		return "Connected"
+	case Closing:
+		return "Closing"
+	default:
+		return "State " + strconv.FormatUint(uint64(s), 10)
+	}
+}
@@ -0,0 +1,89 @@
+package smbpipe
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// WaitRequestSize is the number of bytes required for the fixed portion of
+// an FSCTL_PIPE_WAIT request.
+const WaitRequestSize = 14
+
+// WaitRequest interprets a slice of bytes as an FSCTL_PIPE_WAIT request,
+// which waits for an instance of a named pipe to become available.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.3 (FSCTL_PIPE_WAIT)
+type WaitRequest []byte
+
+// Valid returns true if the request is valid.
+func (r WaitRequest) Valid() bool {
+	if len(r) < WaitRequestSize {
+		return false
+	}
+
+	// The name must not overflow and must hold whole utf16 code units
+	length := int(r.NameLength())
+	if WaitRequestSize+length > len(r) || length%2 != 0 {
+		return false
+	}
+
+	return true
+}
+
+// Timeout returns the amount of time to wait for the pipe, as a count of
+// 100-nanosecond intervals. It is only meaningful if TimeoutSpecified
+// returns true.
+func (r WaitRequest) Timeout() int64 {
+	return int64(smbtype.Uint64(r[0:8]))
+}
+
+// SetTimeout sets the amount of time to wait for the pipe, as a count of
+// 100-nanosecond intervals.
+func (r WaitRequest) SetTimeout(timeout int64) {
+	smbtype.PutUint64(r[0:8], uint64(timeout))
+}
+
+// NameLength returns the length of the pipe name in bytes.
+func (r WaitRequest) NameLength() uint32 {
+	return smbtype.Uint32(r[8:12])
+}
+
+// SetNameLength sets the length of the pipe name in bytes.
+func (r WaitRequest) SetNameLength(length uint32) {
+	smbtype.PutUint32(r[8:12], length)
+}
+
+// TimeoutSpecified returns true if the request carries a timeout. If it
+// doesn't, the server's default timeout applies.
+func (r WaitRequest) TimeoutSpecified() bool {
+	return r[12] != 0
+}
+
+// SetTimeoutSpecified sets whether the request carries a timeout.
+func (r WaitRequest) SetTimeoutSpecified(specified bool) {
+	if specified {
+		r[12] = 1
+	} else {
+		r[12] = 0
+	}
+	r[13] = 0
+}
+
+// Name returns the name of the pipe, which is relative to the IPC$ share.
+func (r WaitRequest) Name() string {
+	end := WaitRequestSize + int(r.NameLength())
+	return smbtype.String(r[WaitRequestSize:end])
+}
+
+// SetName writes the name of the pipe and its length to the request.
+//
+// If the request is too small to hold the name the call will panic. Use
+// WaitRequestBufferSize to determine the size of the buffer.
+func (r WaitRequest) SetName(name string) {
+	length := smbtype.StringLen(name)
+	r.SetNameLength(uint32(length))
+	smbtype.PutString(r[WaitRequestSize:WaitRequestSize+length], name)
+}
+
+// WaitRequestBufferSize returns the number of bytes required to hold a
+// request with the given pipe name.
+func WaitRequestBufferSize(name string) int {
+	return WaitRequestSize + smbtype.StringLen(name)
+}
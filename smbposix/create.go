@@ -0,0 +1,101 @@
+package smbposix
+
+import (
+	"github.com/gentlemanautomaton/smb/smbreparse"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// CreateRequestSize is the number of bytes required for the data of a
+// POSIX create context within a create request.
+const CreateRequestSize = 4
+
+// CreateRequest interprets a slice of bytes as the data of a POSIX create
+// context within a create request. Clients send it to request POSIX
+// semantics for an open, such as case-sensitive name lookup.
+type CreateRequest []byte
+
+// Valid returns true if the context data is valid.
+func (r CreateRequest) Valid() bool {
+	return len(r) >= CreateRequestSize
+}
+
+// Mode returns the POSIX mode that is given to the file if it is created.
+func (r CreateRequest) Mode() Mode {
+	return Mode(smbtype.Uint32(r[0:4]))
+}
+
+// SetMode sets the POSIX mode that is given to the file if it is created.
+func (r CreateRequest) SetMode(mode Mode) {
+	smbtype.PutUint32(r[0:4], uint32(mode))
+}
+
+// CreateResponseHeaderSize is the number of bytes required for the fixed
+// portion of the data of a POSIX create context within a create response.
+// It is followed by the owner and group SIDs.
+const CreateResponseHeaderSize = 12
+
+// CreateResponseSize returns the number of bytes required for the data of
+// a POSIX create context within a create response that reports the given
+// owner and group.
+func CreateResponseSize(owner, group smbsecurity.SID) int {
+	return CreateResponseHeaderSize + owner.Len() + group.Len()
+}
+
+// CreateResponse interprets a slice of bytes as the data of a POSIX create
+// context within a create response.
+type CreateResponse []byte
+
+// Valid returns true if the context data and its SIDs are in bounds.
+func (r CreateResponse) Valid() bool {
+	if len(r) < CreateResponseHeaderSize {
+		return false
+	}
+	return validSIDs(r[CreateResponseHeaderSize:])
+}
+
+// Links returns the number of hard links to the file.
+func (r CreateResponse) Links() uint32 {
+	return smbtype.Uint32(r[0:4])
+}
+
+// SetLinks sets the number of hard links to the file.
+func (r CreateResponse) SetLinks(n uint32) {
+	smbtype.PutUint32(r[0:4], n)
+}
+
+// ReparseTag returns the reparse tag of the file, or zero if the file
+// isn't a reparse point.
+func (r CreateResponse) ReparseTag() smbreparse.Tag {
+	return smbreparse.Tag(smbtype.Uint32(r[4:8]))
+}
+
+// SetReparseTag sets the reparse tag of the file.
+func (r CreateResponse) SetReparseTag(tag smbreparse.Tag) {
+	smbtype.PutUint32(r[4:8], uint32(tag))
+}
+
+// Mode returns the POSIX mode of the file.
+func (r CreateResponse) Mode() Mode {
+	return Mode(smbtype.Uint32(r[8:12]))
+}
+
+// SetMode sets the POSIX mode of the file.
+func (r CreateResponse) SetMode(mode Mode) {
+	smbtype.PutUint32(r[8:12], uint32(mode))
+}
+
+// Owner returns the SID of the owner of the file.
+func (r CreateResponse) Owner() smbsecurity.SID {
+	return owner(r[CreateResponseHeaderSize:])
+}
+
+// Group returns the SID of the group of the file.
+func (r CreateResponse) Group() smbsecurity.SID {
+	return group(r[CreateResponseHeaderSize:])
+}
+
+// SetOwnerGroup sets the SIDs of the owner and group of the file.
+func (r CreateResponse) SetOwnerGroup(owner, group smbsecurity.SID) {
+	putSIDs(r[CreateResponseHeaderSize:], owner, group)
+}
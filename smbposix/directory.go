@@ -0,0 +1,129 @@
+package smbposix
+
+import (
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// DirectoryInfoAlignment is the byte alignment required for each entry
+// within a list of POSIX directory information entries.
+const DirectoryInfoAlignment = 8
+
+// DirectoryInfoSize returns the number of bytes required to hold a POSIX
+// directory information entry for a file with the given owner, group and
+// name, excluding alignment padding.
+func DirectoryInfoSize(owner, group smbsecurity.SID, name string) int {
+	return 8 + InfoSize(owner, group) + 4 + smbtype.StringLen(name)
+}
+
+// DirectoryInfo interprets a slice of bytes as a POSIX directory
+// information entry, which is returned by QUERY_DIRECTORY requests for
+// the FilePOSIXInformation class. The entry holds the POSIX file
+// information of a file, followed by its name.
+type DirectoryInfo []byte
+
+// Valid returns true if the entry and its name are in bounds.
+func (d DirectoryInfo) Valid() bool {
+	if len(d) < 8 || !d.Info().Valid() {
+		return false
+	}
+	start := d.nameLengthOffset()
+	if start+4 > len(d) {
+		return false
+	}
+	return start+4+int(smbtype.Uint32(d[start:start+4])) <= len(d)
+}
+
+// NextEntryOffset returns the offset of the next entry in the list. It
+// returns zero if this is the last entry.
+func (d DirectoryInfo) NextEntryOffset() uint32 {
+	return smbtype.Uint32(d[0:4])
+}
+
+// SetNextEntryOffset sets the offset of the next entry in the list.
+func (d DirectoryInfo) SetNextEntryOffset(offset uint32) {
+	smbtype.PutUint32(d[0:4], offset)
+}
+
+// FileIndex returns the position of the entry within the directory. It is
+// set to zero by servers, and ignored by clients.
+func (d DirectoryInfo) FileIndex() uint32 {
+	return smbtype.Uint32(d[4:8])
+}
+
+// SetFileIndex sets the position of the entry within the directory.
+func (d DirectoryInfo) SetFileIndex(index uint32) {
+	smbtype.PutUint32(d[4:8], index)
+}
+
+// Info returns the POSIX file information of the entry. Its owner and
+// group must be set before the name of the entry.
+func (d DirectoryInfo) Info() Info {
+	return Info(d[8:])
+}
+
+// FileName returns the name of the file.
+func (d DirectoryInfo) FileName() string {
+	start := d.nameLengthOffset()
+	end := start + 4 + int(smbtype.Uint32(d[start:start+4]))
+	return smbtype.String(d[start+4 : end])
+}
+
+// SetFileName sets the name of the file and its length.
+func (d DirectoryInfo) SetFileName(name string) {
+	start := d.nameLengthOffset()
+	n := smbtype.PutString(d[start+4:], name)
+	smbtype.PutUint32(d[start:start+4], uint32(n))
+}
+
+// nameLengthOffset returns the offset of the file name length, which
+// follows the owner and group SIDs.
+func (d DirectoryInfo) nameLengthOffset() int {
+	sids := d[8+InfoHeaderSize:]
+	owner := smbsecurity.SID(sids).Len()
+	return 8 + InfoHeaderSize + owner + smbsecurity.SID(sids[owner:]).Len()
+}
+
+// DirectoryInfoList interprets a slice of bytes as a list of POSIX
+// directory information entries.
+type DirectoryInfoList []byte
+
+// Valid returns true if every entry in the list is in bounds.
+func (k DirectoryInfoList) Valid() bool {
+	if len(k) == 0 {
+		return true
+	}
+	offset := uint(0)
+	for {
+		if offset >= uint(len(k)) {
+			return false
+		}
+		entry := DirectoryInfo(k[offset:])
+		if !entry.Valid() {
+			return false
+		}
+		next := uint(entry.NextEntryOffset())
+		if next == 0 {
+			return true
+		}
+		if next%DirectoryInfoAlignment != 0 {
+			return false
+		}
+		offset += next
+	}
+}
+
+// Member returns the entry at the given offset within the list.
+func (k DirectoryInfoList) Member(offset uint32) DirectoryInfo {
+	return DirectoryInfo(k[offset:])
+}
+
+// Next returns the offset of the entry that follows the entry at the given
+// offset. It returns zero if the entry at offset is the last in the list.
+func (k DirectoryInfoList) Next(offset uint32) uint32 {
+	next := k.Member(offset).NextEntryOffset()
+	if next == 0 {
+		return 0
+	}
+	return offset + next
+}
@@ -0,0 +1,8 @@
+// Package smbposix implements the data structures of the SMB3 POSIX
+// extensions, which let clients such as the Linux kernel's SMB client work
+// with case-sensitive names, POSIX permissions, owners and hard link
+// counts.
+//
+// The extensions are specified by the Samba project in "SMB3 POSIX
+// Extensions" rather than by Microsoft.
+package smbposix
@@ -0,0 +1,10 @@
+package smbposix
+
+// ExtensionsID identifies the version of the SMB3 POSIX extensions that is
+// implemented by this package. It is carried by the
+// SMB2_POSIX_EXTENSIONS_AVAILABLE negotiate context, and is also the name
+// of the POSIX create context.
+var ExtensionsID = []byte{
+	0x93, 0xAD, 0x25, 0x50, 0x9C, 0xB4, 0x11, 0xE7,
+	0xB4, 0x23, 0x83, 0xDE, 0x96, 0x8B, 0xCD, 0x7C,
+}
@@ -0,0 +1,204 @@
+package smbposix
+
+import (
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbreparse"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// InfoClass is the file information class of POSIX file information. It
+// is used by QUERY_INFO and QUERY_DIRECTORY requests, which return Info and
+// DirectoryInfo structures respectively.
+const InfoClass = 100 // SMB_FIND_FILE_POSIX_INFO
+
+// InfoHeaderSize is the number of bytes required for the fixed portion of
+// POSIX file information. It is followed by the owner and group SIDs.
+const InfoHeaderSize = 80
+
+// InfoSize returns the number of bytes required to hold POSIX file
+// information with the given owner and group.
+func InfoSize(owner, group smbsecurity.SID) int {
+	return InfoHeaderSize + owner.Len() + group.Len()
+}
+
+// Info interprets a slice of bytes as POSIX file information, which is
+// returned by queries of the FilePOSIXInformation class.
+type Info []byte
+
+// Valid returns true if the information and its SIDs are in bounds.
+func (i Info) Valid() bool {
+	if len(i) < InfoHeaderSize {
+		return false
+	}
+	return validSIDs(i[InfoHeaderSize:])
+}
+
+// CreationTime returns the time the file was created.
+func (i Info) CreationTime() time.Time {
+	return smbtype.Time(i[0:8])
+}
+
+// SetCreationTime sets the time the file was created.
+func (i Info) SetCreationTime(t time.Time) {
+	smbtype.PutTime(i[0:8], t)
+}
+
+// LastAccessTime returns the time the file was last accessed.
+func (i Info) LastAccessTime() time.Time {
+	return smbtype.Time(i[8:16])
+}
+
+// SetLastAccessTime sets the time the file was last accessed.
+func (i Info) SetLastAccessTime(t time.Time) {
+	smbtype.PutTime(i[8:16], t)
+}
+
+// LastWriteTime returns the time the file was last written to.
+func (i Info) LastWriteTime() time.Time {
+	return smbtype.Time(i[16:24])
+}
+
+// SetLastWriteTime sets the time the file was last written to.
+func (i Info) SetLastWriteTime(t time.Time) {
+	smbtype.PutTime(i[16:24], t)
+}
+
+// ChangeTime returns the time the file was last changed.
+func (i Info) ChangeTime() time.Time {
+	return smbtype.Time(i[24:32])
+}
+
+// SetChangeTime sets the time the file was last changed.
+func (i Info) SetChangeTime(t time.Time) {
+	smbtype.PutTime(i[24:32], t)
+}
+
+// EndOfFile returns the size of the file in bytes.
+func (i Info) EndOfFile() uint64 {
+	return smbtype.Uint64(i[32:40])
+}
+
+// SetEndOfFile sets the size of the file in bytes.
+func (i Info) SetEndOfFile(size uint64) {
+	smbtype.PutUint64(i[32:40], size)
+}
+
+// AllocationSize returns the number of bytes allocated to the file.
+func (i Info) AllocationSize() uint64 {
+	return smbtype.Uint64(i[40:48])
+}
+
+// SetAllocationSize sets the number of bytes allocated to the file.
+func (i Info) SetAllocationSize(size uint64) {
+	smbtype.PutUint64(i[40:48], size)
+}
+
+// Attributes returns the file attributes of the file.
+func (i Info) Attributes() smbfile.Attributes {
+	return smbfile.Attributes(smbtype.Uint32(i[48:52]))
+}
+
+// SetAttributes sets the file attributes of the file.
+func (i Info) SetAttributes(attrs smbfile.Attributes) {
+	smbtype.PutUint32(i[48:52], uint32(attrs))
+}
+
+// Inode returns the inode number of the file.
+func (i Info) Inode() uint64 {
+	return smbtype.Uint64(i[52:60])
+}
+
+// SetInode sets the inode number of the file.
+func (i Info) SetInode(inode uint64) {
+	smbtype.PutUint64(i[52:60], inode)
+}
+
+// Device returns the number of the device that holds the file.
+func (i Info) Device() uint32 {
+	return smbtype.Uint32(i[60:64])
+}
+
+// SetDevice sets the number of the device that holds the file. The
+// reserved field that follows it is cleared.
+func (i Info) SetDevice(device uint32) {
+	smbtype.PutUint32(i[60:64], device)
+	smbtype.PutUint32(i[64:68], 0)
+}
+
+// Links returns the number of hard links to the file.
+func (i Info) Links() uint32 {
+	return smbtype.Uint32(i[68:72])
+}
+
+// SetLinks sets the number of hard links to the file.
+func (i Info) SetLinks(n uint32) {
+	smbtype.PutUint32(i[68:72], n)
+}
+
+// ReparseTag returns the reparse tag of the file, or zero if the file
+// isn't a reparse point.
+func (i Info) ReparseTag() smbreparse.Tag {
+	return smbreparse.Tag(smbtype.Uint32(i[72:76]))
+}
+
+// SetReparseTag sets the reparse tag of the file.
+func (i Info) SetReparseTag(tag smbreparse.Tag) {
+	smbtype.PutUint32(i[72:76], uint32(tag))
+}
+
+// Mode returns the POSIX mode of the file.
+func (i Info) Mode() Mode {
+	return Mode(smbtype.Uint32(i[76:80]))
+}
+
+// SetMode sets the POSIX mode of the file.
+func (i Info) SetMode(mode Mode) {
+	smbtype.PutUint32(i[76:80], uint32(mode))
+}
+
+// Owner returns the SID of the owner of the file.
+func (i Info) Owner() smbsecurity.SID {
+	return owner(i[InfoHeaderSize:])
+}
+
+// Group returns the SID of the group of the file.
+func (i Info) Group() smbsecurity.SID {
+	return group(i[InfoHeaderSize:])
+}
+
+// SetOwnerGroup sets the SIDs of the owner and group of the file. It
+// returns the number of bytes written after the fixed portion of the
+// information.
+func (i Info) SetOwnerGroup(owner, group smbsecurity.SID) int {
+	return putSIDs(i[InfoHeaderSize:], owner, group)
+}
+
+// validSIDs returns true if b begins with two valid SIDs.
+func validSIDs(b []byte) bool {
+	owner := smbsecurity.SID(b)
+	if !owner.Valid() {
+		return false
+	}
+	return smbsecurity.SID(b[owner.Len():]).Valid()
+}
+
+// owner returns the first of the SIDs at the start of b.
+func owner(b []byte) smbsecurity.SID {
+	n := smbsecurity.SID(b).Len()
+	return smbsecurity.SID(b[:n:n])
+}
+
+// group returns the second of the SIDs at the start of b.
+func group(b []byte) smbsecurity.SID {
+	return owner(b[smbsecurity.SID(b).Len():])
+}
+
+// putSIDs writes owner and group to the start of b and returns the number
+// of bytes written.
+func putSIDs(b []byte, owner, group smbsecurity.SID) int {
+	n := copy(b, owner[:owner.Len()])
+	return n + copy(b[n:], group[:group.Len()])
+}
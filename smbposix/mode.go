@@ -0,0 +1,55 @@
+package smbposix
+
+import (
+	"os"
+	"strconv"
+)
+
+// Mode holds the POSIX permission bits of a file, including the set-user-ID,
+// set-group-ID and sticky bits. The type of the file isn't included; it is
+// conveyed by the file attributes and reparse tag of the file.
+type Mode uint32
+
+// Mode bits.
+const (
+	SetUID    = 0o4000 // S_ISUID
+	SetGID    = 0o2000 // S_ISGID
+	Sticky    = 0o1000 // S_ISVTX
+	PermMask  = 0o0777 // The read, write and execute bits of the owner, group and others
+	ValidMask = 0o7777 // Every valid bit
+)
+
+// ModeOf returns the POSIX mode that describes m.
+func ModeOf(m os.FileMode) Mode {
+	mode := Mode(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		mode |= SetUID
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= SetGID
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= Sticky
+	}
+	return mode
+}
+
+// FileMode returns the os.FileMode that holds the bits of m.
+func (m Mode) FileMode() os.FileMode {
+	mode := os.FileMode(m & PermMask)
+	if m&SetUID != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&SetGID != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&Sticky != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// String returns the mode in octal.
+func (m Mode) String() string {
+	return "0" + strconv.FormatUint(uint64(m), 8)
+}
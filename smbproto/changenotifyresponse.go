@@ -0,0 +1,68 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbnotify"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// ChangeNotifyResponse holds SMB change notification response data that can
+// be serialized as an SMB packet.
+type ChangeNotifyResponse struct {
+	Code    smbstatus.Code // Typically Success or NotifyEnumDir
+	Changes []smbnotify.Change
+}
+
+// Command returns the type of command of the response.
+func (r ChangeNotifyResponse) Command() smbcommand.Code {
+	return smbcommand.ChangeNotify
+}
+
+// Status returns the status of the response.
+func (r ChangeNotifyResponse) Status() smbstatus.Code {
+	return r.Code
+}
+
+// Size returns the number of bytes required to marshal the change
+// notification response. It excludes the packet header.
+func (r ChangeNotifyResponse) Size() int {
+	return smbnotify.ResponseSize + r.bufferLength()
+}
+
+// bufferLength returns the number of bytes required to hold the file notify
+// information entries. Every entry but the last is padded to a 4-byte
+// boundary.
+func (r ChangeNotifyResponse) bufferLength() int {
+	length := 0
+	for i := range r.Changes {
+		if i > 0 {
+			length = align4(length)
+		}
+		length += smbnotify.InfoSize(r.Changes[i].Name)
+	}
+	return length
+}
+
+// Marshal marshals r as an SMB change notification response to data.
+func (r ChangeNotifyResponse) Marshal(data []byte) {
+	response := smbnotify.Response(data)
+	response.SetSize(9)
+	buffer := response.SetOutputBufferLayout(r.bufferLength())
+
+	offset := 0
+	for i := range r.Changes {
+		change := &r.Changes[i]
+		entry := smbnotify.Info(buffer[offset:])
+		entry.SetAction(change.Action)
+		entry.SetFileName(change.Name)
+		if i < len(r.Changes)-1 {
+			next := align4(smbnotify.InfoSize(change.Name))
+			entry.SetNextEntryOffset(uint32(next))
+			offset += next
+		}
+	}
+}
+
+func align4(length int) int {
+	return (length + 3) &^ 3
+}
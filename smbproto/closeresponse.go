@@ -0,0 +1,54 @@
+package smbproto
+
+import (
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbclose"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// CloseResponse holds SMB close response data that can be serialized as an
+// SMB packet. The file information should be left empty unless Flags
+// contains smbclose.PostQueryAttrib.
+type CloseResponse struct {
+	Flags          smbclose.Flags
+	CreationTime   time.Time
+	LastAccessTime time.Time
+	LastWriteTime  time.Time
+	ChangeTime     time.Time
+	AllocationSize uint64
+	EndOfFile      uint64
+	Attributes     smbfile.Attributes
+}
+
+// Command returns the type of command of the response.
+func (r CloseResponse) Command() smbcommand.Code {
+	return smbcommand.Close
+}
+
+// Status returns the status of the response.
+func (r CloseResponse) Status() smbstatus.Code {
+	return smbstatus.Success
+}
+
+// Size returns the number of bytes required to marshal the close response.
+// It excludes the packet header.
+func (r CloseResponse) Size() int {
+	return smbclose.ResponseSize
+}
+
+// Marshal marshals r as an SMB close response to data.
+func (r CloseResponse) Marshal(data []byte) {
+	response := smbclose.Response(data)
+	response.SetSize(60)
+	response.SetFlags(r.Flags)
+	response.SetCreationTime(r.CreationTime)
+	response.SetLastAccessTime(r.LastAccessTime)
+	response.SetLastWriteTime(r.LastWriteTime)
+	response.SetChangeTime(r.ChangeTime)
+	response.SetAllocationSize(r.AllocationSize)
+	response.SetEndOfFile(r.EndOfFile)
+	response.SetFileAttributes(r.Attributes)
+}
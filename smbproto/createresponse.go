@@ -0,0 +1,64 @@
+package smbproto
+
+import (
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// CreateResponse holds SMB create response data that can be serialized as
+// an SMB packet.
+type CreateResponse struct {
+	OplockLevel    smboplock.Level
+	Action         smbcreate.Action
+	CreationTime   time.Time
+	LastAccessTime time.Time
+	LastWriteTime  time.Time
+	ChangeTime     time.Time
+	AllocationSize uint64
+	EndOfFile      uint64
+	Attributes     smbfile.Attributes
+	FileID         smbfile.ID
+	Contexts       []byte // Serialized create contexts
+}
+
+// Command returns the type of command of the response.
+func (r CreateResponse) Command() smbcommand.Code {
+	return smbcommand.Create
+}
+
+// Status returns the status of the response.
+func (r CreateResponse) Status() smbstatus.Code {
+	return smbstatus.Success
+}
+
+// Size returns the number of bytes required to marshal the create response.
+// It excludes the packet header.
+func (r CreateResponse) Size() int {
+	if len(r.Contexts) == 0 {
+		// The buffer must be at least one byte long
+		return smbcreate.ResponseSize + 1
+	}
+	return smbcreate.ResponseSize + len(r.Contexts)
+}
+
+// Marshal marshals r as an SMB create response to data.
+func (r CreateResponse) Marshal(data []byte) {
+	response := smbcreate.Response(data)
+	response.SetSize(89)
+	response.SetOplockLevel(r.OplockLevel)
+	response.SetCreateAction(r.Action)
+	response.SetCreationTime(r.CreationTime)
+	response.SetLastAccessTime(r.LastAccessTime)
+	response.SetLastWriteTime(r.LastWriteTime)
+	response.SetChangeTime(r.ChangeTime)
+	response.SetAllocationSize(r.AllocationSize)
+	response.SetEndOfFile(r.EndOfFile)
+	response.SetFileAttributes(r.Attributes)
+	response.SetFileID(r.FileID)
+	copy(response.SetCreateContextsLayout(len(r.Contexts)), r.Contexts)
+}
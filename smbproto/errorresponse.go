@@ -0,0 +1,48 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smberror"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// ErrorResponse holds SMB error response data that can be serialized as an
+// SMB packet. It is sent in place of a command's normal response when the
+// command fails.
+//
+// In the SMB 3.1.1 dialect Data holds ContextCount error contexts.
+// Otherwise ContextCount is zero.
+type ErrorResponse struct {
+	Cmd          smbcommand.Code
+	Code         smbstatus.Code
+	ContextCount uint8
+	Data         []byte
+}
+
+// Command returns the type of command of the response.
+func (r ErrorResponse) Command() smbcommand.Code {
+	return r.Cmd
+}
+
+// Status returns the status of the response.
+func (r ErrorResponse) Status() smbstatus.Code {
+	return r.Code
+}
+
+// Size returns the number of bytes required to marshal the error response.
+// It excludes the packet header.
+func (r ErrorResponse) Size() int {
+	if len(r.Data) == 0 {
+		// The error data must be at least one byte long
+		return smberror.ResponseSize + 1
+	}
+	return smberror.ResponseSize + len(r.Data)
+}
+
+// Marshal marshals r as an SMB error response to data.
+func (r ErrorResponse) Marshal(data []byte) {
+	response := smberror.Response(data)
+	response.SetSize(9)
+	response.SetContextCount(r.ContextCount)
+	response.SetData(r.Data)
+}
@@ -0,0 +1,45 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// IoctlResponse holds SMB IOCTL response data that can be serialized as an
+// SMB packet.
+//
+// Code is usually STATUS_SUCCESS, which is its zero value. Some control
+// codes return output along with a failure or warning status.
+type IoctlResponse struct {
+	Code    smbstatus.Code
+	CtlCode smbioctl.Code
+	FileID  smbfile.ID
+	Output  []byte
+}
+
+// Command returns the type of command of the response.
+func (r IoctlResponse) Command() smbcommand.Code {
+	return smbcommand.IOCTL
+}
+
+// Status returns the status of the response.
+func (r IoctlResponse) Status() smbstatus.Code {
+	return r.Code
+}
+
+// Size returns the number of bytes required to marshal the IOCTL response.
+// It excludes the packet header.
+func (r IoctlResponse) Size() int {
+	return smbioctl.ResponseSize + len(r.Output)
+}
+
+// Marshal marshals r as an SMB IOCTL response to data.
+func (r IoctlResponse) Marshal(data []byte) {
+	response := smbioctl.Response(data)
+	response.SetSize(49)
+	response.SetCtlCode(r.CtlCode)
+	response.SetFileID(r.FileID)
+	copy(response.SetOutputLayout(len(r.Output)), r.Output)
+}
@@ -0,0 +1,45 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smblease"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// LeaseBreakNotification holds SMB lease break notification data that can
+// be serialized as an SMB packet.
+type LeaseBreakNotification struct {
+	Epoch   uint16
+	Flags   smblease.BreakFlags
+	Key     smbid.ID
+	Current smblease.State
+	New     smblease.State
+}
+
+// Command returns the type of command of the notification.
+func (r LeaseBreakNotification) Command() smbcommand.Code {
+	return smbcommand.OplockBreak
+}
+
+// Status returns the status of the notification.
+func (r LeaseBreakNotification) Status() smbstatus.Code {
+	return smbstatus.Success
+}
+
+// Size returns the number of bytes required to marshal the notification.
+// It excludes the packet header.
+func (r LeaseBreakNotification) Size() int {
+	return smblease.BreakNotificationSize
+}
+
+// Marshal marshals r as an SMB lease break notification to data.
+func (r LeaseBreakNotification) Marshal(data []byte) {
+	n := smblease.BreakNotification(data)
+	n.SetSize(44)
+	n.SetNewEpoch(r.Epoch)
+	n.SetFlags(r.Flags)
+	n.SetKey(r.Key)
+	n.SetCurrentState(r.Current)
+	n.SetNewState(r.New)
+}
@@ -0,0 +1,39 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smblease"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// LeaseBreakResponse holds SMB lease break response data that can be
+// serialized as an SMB packet.
+type LeaseBreakResponse struct {
+	Key   smbid.ID
+	State smblease.State
+}
+
+// Command returns the type of command of the response.
+func (r LeaseBreakResponse) Command() smbcommand.Code {
+	return smbcommand.OplockBreak
+}
+
+// Status returns the status of the response.
+func (r LeaseBreakResponse) Status() smbstatus.Code {
+	return smbstatus.Success
+}
+
+// Size returns the number of bytes required to marshal the response. It
+// excludes the packet header.
+func (r LeaseBreakResponse) Size() int {
+	return smblease.AckSize
+}
+
+// Marshal marshals r as an SMB lease break response to data.
+func (r LeaseBreakResponse) Marshal(data []byte) {
+	response := smblease.Ack(data)
+	response.SetSize(36)
+	response.SetKey(r.Key)
+	response.SetState(r.State)
+}
@@ -0,0 +1,33 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smblock"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// LockResponse holds SMB lock response data that can be serialized as an
+// SMB packet.
+type LockResponse struct{}
+
+// Command returns the type of command of the response.
+func (r LockResponse) Command() smbcommand.Code {
+	return smbcommand.Lock
+}
+
+// Status returns the status of the response.
+func (r LockResponse) Status() smbstatus.Code {
+	return smbstatus.Success
+}
+
+// Size returns the number of bytes required to marshal the lock response.
+// It excludes the packet header.
+func (r LockResponse) Size() int {
+	return smblock.ResponseSize
+}
+
+// Marshal marshals r as an SMB lock response to data.
+func (r LockResponse) Marshal(data []byte) {
+	response := smblock.Response(data)
+	response.SetSize(4)
+}
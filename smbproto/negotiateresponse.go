@@ -1,7 +1,6 @@
 package smbproto
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/gentlemanautomaton/smb/smbcap"
@@ -9,7 +8,9 @@ import (
 	"github.com/gentlemanautomaton/smb/smbdialect"
 	"github.com/gentlemanautomaton/smb/smbid"
 	"github.com/gentlemanautomaton/smb/smbnego"
+	"github.com/gentlemanautomaton/smb/smbpacket"
 	"github.com/gentlemanautomaton/smb/smbsecmode"
+	"github.com/gentlemanautomaton/smb/smbstatus"
 )
 
 // NegotiateResponse holds SMB negotiation response data that can be
@@ -24,6 +25,10 @@ type NegotiateResponse struct {
 	MaxWriteSize    uint32
 	SystemTime      time.Time
 	SecurityBuffer  []byte
+
+	// Contexts are the negotiation contexts of the response, which are
+	// only sent with the SMB 3.1.1 dialect.
+	Contexts []smbnego.Context
 }
 
 // Command returns the type of command of the response.
@@ -32,8 +37,8 @@ func (r NegotiateResponse) Command() smbcommand.Code {
 }
 
 // Status returns the status of the response.
-func (r NegotiateResponse) Status() uint32 {
-	return 0
+func (r NegotiateResponse) Status() smbstatus.Code {
+	return smbstatus.Success
 }
 
 // Size returns the number of bytes required to marshal the negotiation
@@ -41,7 +46,11 @@ func (r NegotiateResponse) Status() uint32 {
 func (r NegotiateResponse) Size() int {
 	s := smbnego.ResponseSize
 	s += len(r.SecurityBuffer)
-	// TODO: Include negotiation contexts
+	if r.Dialect == smbdialect.SMB311 {
+		for _, ctx := range r.Contexts {
+			s = align8(s) + len(ctx)
+		}
+	}
 	return s
 }
 
@@ -51,9 +60,6 @@ func (r NegotiateResponse) Marshal(data []byte) {
 	response.SetSize(65)
 	response.SetSecurityMode(r.SecMode | smbsecmode.SigningEnabled)
 	response.SetDialectRevision(r.Dialect)
-	if r.Dialect == smbdialect.SMB311 {
-		//response.SetContextCount(3)
-	}
 	response.SetServerID(r.Server)
 	response.SetCapabilities(r.Caps)
 	response.SetMaxTransactSize(r.MaxTransactSize)
@@ -61,6 +67,19 @@ func (r NegotiateResponse) Marshal(data []byte) {
 	response.SetMaxWriteSize(r.MaxWriteSize)
 	response.SetSystemTime(r.SystemTime)
 	response.SetSecurityBuffer(r.SecurityBuffer)
+	if r.Dialect == smbdialect.SMB311 && len(r.Contexts) > 0 {
+		// Each context is aligned to an 8-byte boundary
+		offset := align8(smbnego.ResponseSize + len(r.SecurityBuffer))
+		response.SetContextOffset(uint32(smbpacket.HeaderSize + offset))
+		response.SetContextCount(uint16(len(r.Contexts)))
+		for _, ctx := range r.Contexts {
+			offset = align8(offset)
+			offset += copy(data[offset:], ctx)
+		}
+	}
+}
 
-	fmt.Println(response.Summary())
+// align8 rounds n up to a multiple of 8.
+func align8(n int) int {
+	return (n + 7) &^ 7
 }
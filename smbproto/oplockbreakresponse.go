@@ -0,0 +1,40 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// OplockBreakResponse holds SMB oplock break data that can be serialized as
+// an SMB packet. It is used both for oplock break notifications sent by the
+// server and for responses to oplock break acknowledgments.
+type OplockBreakResponse struct {
+	Level  smboplock.Level
+	FileID smbfile.ID
+}
+
+// Command returns the type of command of the response.
+func (r OplockBreakResponse) Command() smbcommand.Code {
+	return smbcommand.OplockBreak
+}
+
+// Status returns the status of the response.
+func (r OplockBreakResponse) Status() smbstatus.Code {
+	return smbstatus.Success
+}
+
+// Size returns the number of bytes required to marshal the oplock break
+// response. It excludes the packet header.
+func (r OplockBreakResponse) Size() int {
+	return smboplock.BreakSize
+}
+
+// Marshal marshals r as an SMB oplock break message to data.
+func (r OplockBreakResponse) Marshal(data []byte) {
+	b := smboplock.Break(data)
+	b.SetSize(24)
+	b.SetOplockLevel(r.Level)
+	b.SetFileID(r.FileID)
+}
@@ -0,0 +1,44 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbdir"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// QueryDirectoryResponse holds SMB QUERY_DIRECTORY response data that can be
+// serialized as an SMB packet.
+//
+// Code is usually STATUS_SUCCESS, which is its zero value. It is
+// STATUS_BUFFER_OVERFLOW when the first entry doesn't fit in the output buffer.
+type QueryDirectoryResponse struct {
+	Code   smbstatus.Code
+	Output []byte
+}
+
+// Command returns the type of command of the response.
+func (r QueryDirectoryResponse) Command() smbcommand.Code {
+	return smbcommand.QueryDirectory
+}
+
+// Status returns the status of the response.
+func (r QueryDirectoryResponse) Status() smbstatus.Code {
+	return r.Code
+}
+
+// Size returns the number of bytes required to marshal the QUERY_DIRECTORY
+// response. It excludes the packet header.
+func (r QueryDirectoryResponse) Size() int {
+	if len(r.Output) == 0 {
+		// The buffer must be at least one byte long
+		return smbdir.ResponseSize + 1
+	}
+	return smbdir.ResponseSize + len(r.Output)
+}
+
+// Marshal marshals r as an SMB QUERY_DIRECTORY response to data.
+func (r QueryDirectoryResponse) Marshal(data []byte) {
+	response := smbdir.Response(data)
+	response.SetSize(9)
+	copy(response.SetOutputLayout(len(r.Output)), r.Output)
+}
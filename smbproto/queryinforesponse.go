@@ -0,0 +1,44 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbinfo"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// QueryInfoResponse holds SMB QUERY_INFO response data that can be
+// serialized as an SMB packet.
+//
+// Code is usually STATUS_SUCCESS, which is its zero value. It is
+// STATUS_BUFFER_OVERFLOW when the output has been truncated.
+type QueryInfoResponse struct {
+	Code   smbstatus.Code
+	Output []byte
+}
+
+// Command returns the type of command of the response.
+func (r QueryInfoResponse) Command() smbcommand.Code {
+	return smbcommand.QueryInfo
+}
+
+// Status returns the status of the response.
+func (r QueryInfoResponse) Status() smbstatus.Code {
+	return r.Code
+}
+
+// Size returns the number of bytes required to marshal the QUERY_INFO
+// response. It excludes the packet header.
+func (r QueryInfoResponse) Size() int {
+	if len(r.Output) == 0 {
+		// The buffer must be at least one byte long
+		return smbinfo.QueryResponseSize + 1
+	}
+	return smbinfo.QueryResponseSize + len(r.Output)
+}
+
+// Marshal marshals r as an SMB QUERY_INFO response to data.
+func (r QueryInfoResponse) Marshal(data []byte) {
+	response := smbinfo.QueryResponse(data)
+	response.SetSize(9)
+	copy(response.SetOutputLayout(len(r.Output)), r.Output)
+}
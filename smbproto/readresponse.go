@@ -0,0 +1,45 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbread"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// ReadResponse holds SMB read response data that can be serialized as an
+// SMB packet.
+//
+// Code is usually STATUS_SUCCESS, which is its zero value. Reads of named
+// pipes return STATUS_BUFFER_OVERFLOW along with the first part of a
+// message that doesn't fit in the read.
+type ReadResponse struct {
+	Code smbstatus.Code
+	Data []byte
+}
+
+// Command returns the type of command of the response.
+func (r ReadResponse) Command() smbcommand.Code {
+	return smbcommand.Read
+}
+
+// Status returns the status of the response.
+func (r ReadResponse) Status() smbstatus.Code {
+	return r.Code
+}
+
+// Size returns the number of bytes required to marshal the read response.
+// It excludes the packet header.
+func (r ReadResponse) Size() int {
+	if len(r.Data) == 0 {
+		// The buffer must be at least one byte long
+		return smbread.ResponseSize + 1
+	}
+	return smbread.ResponseSize + len(r.Data)
+}
+
+// Marshal marshals r as an SMB read response to data.
+func (r ReadResponse) Marshal(data []byte) {
+	response := smbread.Response(data)
+	response.SetSize(17)
+	copy(response.SetDataLayout(len(r.Data)), r.Data)
+}
@@ -0,0 +1,42 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbsession"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// SessionSetupResponse holds SMB session setup response data that can be
+// serialized as an SMB packet.
+//
+// Code is usually STATUS_SUCCESS, or STATUS_MORE_PROCESSING_REQUIRED when
+// the authentication exchange needs another round trip.
+type SessionSetupResponse struct {
+	Code           smbstatus.Code
+	Flags          smbsession.SessionFlags
+	SecurityBuffer []byte
+}
+
+// Command returns the type of command of the response.
+func (r SessionSetupResponse) Command() smbcommand.Code {
+	return smbcommand.SessionSetup
+}
+
+// Status returns the status of the response.
+func (r SessionSetupResponse) Status() smbstatus.Code {
+	return r.Code
+}
+
+// Size returns the number of bytes required to marshal the session setup
+// response. It excludes the packet header.
+func (r SessionSetupResponse) Size() int {
+	return smbsession.ResponseSize + len(r.SecurityBuffer)
+}
+
+// Marshal marshals r as an SMB session setup response to data.
+func (r SessionSetupResponse) Marshal(data []byte) {
+	response := smbsession.Response(data)
+	response.SetSize(9)
+	response.SetSessionFlags(r.Flags)
+	copy(response.SetSecurityBufferLayout(len(r.SecurityBuffer)), r.SecurityBuffer)
+}
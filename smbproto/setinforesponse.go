@@ -0,0 +1,33 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbinfo"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// SetInfoResponse holds SMB SET_INFO response data that can be serialized
+// as an SMB packet.
+type SetInfoResponse struct{}
+
+// Command returns the type of command of the response.
+func (r SetInfoResponse) Command() smbcommand.Code {
+	return smbcommand.SetInfo
+}
+
+// Status returns the status of the response.
+func (r SetInfoResponse) Status() smbstatus.Code {
+	return smbstatus.Success
+}
+
+// Size returns the number of bytes required to marshal the SET_INFO
+// response. It excludes the packet header.
+func (r SetInfoResponse) Size() int {
+	return smbinfo.SetResponseSize
+}
+
+// Marshal marshals r as an SMB SET_INFO response to data.
+func (r SetInfoResponse) Marshal(data []byte) {
+	response := smbinfo.SetResponse(data)
+	response.SetSize(2)
+}
@@ -0,0 +1,43 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+// TreeConnectResponse holds SMB tree connect response data that can be
+// serialized as an SMB packet. The tree ID is carried in the packet header.
+type TreeConnectResponse struct {
+	ShareType     smbtree.ShareType
+	ShareFlags    smbtree.ShareFlags
+	Capabilities  smbtree.Capabilities
+	MaximalAccess smbaccess.Mask
+}
+
+// Command returns the type of command of the response.
+func (r TreeConnectResponse) Command() smbcommand.Code {
+	return smbcommand.TreeConnect
+}
+
+// Status returns the status of the response.
+func (r TreeConnectResponse) Status() smbstatus.Code {
+	return smbstatus.Success
+}
+
+// Size returns the number of bytes required to marshal the tree connect
+// response. It excludes the packet header.
+func (r TreeConnectResponse) Size() int {
+	return smbtree.ConnectResponseSize
+}
+
+// Marshal marshals r as an SMB tree connect response to data.
+func (r TreeConnectResponse) Marshal(data []byte) {
+	response := smbtree.ConnectResponse(data)
+	response.SetSize(16)
+	response.SetShareType(r.ShareType)
+	response.SetShareFlags(r.ShareFlags)
+	response.SetCapabilities(r.Capabilities)
+	response.SetMaximalAccess(r.MaximalAccess)
+}
@@ -0,0 +1,33 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+// TreeDisconnectResponse holds SMB tree disconnect response data that can
+// be serialized as an SMB packet.
+type TreeDisconnectResponse struct{}
+
+// Command returns the type of command of the response.
+func (r TreeDisconnectResponse) Command() smbcommand.Code {
+	return smbcommand.TreeDisconnect
+}
+
+// Status returns the status of the response.
+func (r TreeDisconnectResponse) Status() smbstatus.Code {
+	return smbstatus.Success
+}
+
+// Size returns the number of bytes required to marshal the tree disconnect
+// response. It excludes the packet header.
+func (r TreeDisconnectResponse) Size() int {
+	return smbtree.DisconnectSize
+}
+
+// Marshal marshals r as an SMB tree disconnect response to data.
+func (r TreeDisconnectResponse) Marshal(data []byte) {
+	response := smbtree.DisconnectResponse(data)
+	response.SetSize(4)
+}
@@ -0,0 +1,37 @@
+package smbproto
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbwrite"
+)
+
+// WriteResponse holds SMB write response data that can be serialized as an
+// SMB packet.
+type WriteResponse struct {
+	Count uint32 // The number of bytes written
+}
+
+// Command returns the type of command of the response.
+func (r WriteResponse) Command() smbcommand.Code {
+	return smbcommand.Write
+}
+
+// Status returns the status of the response.
+func (r WriteResponse) Status() smbstatus.Code {
+	return smbstatus.Success
+}
+
+// Size returns the number of bytes required to marshal the write response.
+// It excludes the packet header.
+func (r WriteResponse) Size() int {
+	// The buffer must be at least one byte long
+	return smbwrite.ResponseSize + 1
+}
+
+// Marshal marshals r as an SMB write response to data.
+func (r WriteResponse) Marshal(data []byte) {
+	response := smbwrite.Response(data)
+	response.SetSize(17)
+	response.SetCount(r.Count)
+}
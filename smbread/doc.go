@@ -0,0 +1,2 @@
+// Package smbread provides types for SMB read requests and responses.
+package smbread
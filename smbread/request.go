@@ -0,0 +1,84 @@
+package smbread
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for the fixed portion of an
+// SMB read request.
+const RequestSize = 48
+
+// Request interprets a slice of bytes as an SMB read request packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.19
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 49
+	if r.Size() != 49 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r Request) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r Request) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// Length returns the number of bytes to be read.
+func (r Request) Length() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetLength sets the number of bytes to be read.
+func (r Request) SetLength(length uint32) {
+	smbtype.PutUint32(r[4:8], length)
+}
+
+// Offset returns the offset in bytes within the file at which the read
+// begins.
+func (r Request) Offset() uint64 {
+	return smbtype.Uint64(r[8:16])
+}
+
+// SetOffset sets the offset in bytes within the file at which the read
+// begins.
+func (r Request) SetOffset(offset uint64) {
+	smbtype.PutUint64(r[8:16], offset)
+}
+
+// FileID returns the file ID of the file to be read.
+func (r Request) FileID() (id smbfile.ID) {
+	id.Read(r[16:32])
+	return
+}
+
+// SetFileID sets the file ID of the file to be read.
+func (r Request) SetFileID(id smbfile.ID) {
+	id.Write(r[16:32])
+}
+
+// MinimumCount returns the minimum number of bytes that must be read for
+// the read to succeed.
+func (r Request) MinimumCount() uint32 {
+	return smbtype.Uint32(r[32:36])
+}
+
+// SetMinimumCount sets the minimum number of bytes that must be read for
+// the read to succeed.
+func (r Request) SetMinimumCount(count uint32) {
+	smbtype.PutUint32(r[32:36], count)
+}
@@ -0,0 +1,93 @@
+package smbread
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ResponseSize is the number of bytes required for the fixed portion of an
+// SMB read response.
+const ResponseSize = 16
+
+// headerSize is the number of bytes in an SMB packet header. It's needed by
+// this package to calculate buffer offsets relative to the start of the
+// packet.
+const headerSize = 64
+
+// Response interprets a slice of bytes as an SMB read response packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.20
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	if len(r) < ResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 17
+	if r.Size() != 17 {
+		return false
+	}
+
+	// The data must not overflow
+	if r.DataLength() > 0 {
+		start := int(r.DataOffset()) - headerSize
+		if start < ResponseSize || start+int(r.DataLength()) > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r Response) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r Response) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// DataOffset returns the offset of the data in bytes from the start of the
+// packet header.
+func (r Response) DataOffset() uint8 {
+	return r[2]
+}
+
+// SetDataOffset sets the offset of the data in bytes from the start of the
+// packet header.
+func (r Response) SetDataOffset(offset uint8) {
+	r[2] = offset
+}
+
+// DataLength returns the number of bytes that were read.
+func (r Response) DataLength() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetDataLength sets the number of bytes that were read.
+func (r Response) SetDataLength(length uint32) {
+	smbtype.PutUint32(r[4:8], length)
+}
+
+// Data returns the data that was read.
+func (r Response) Data() []byte {
+	start := uint(r.DataOffset()) - headerSize
+	end := start + uint(r.DataLength())
+	return r[start:end:end]
+}
+
+// SetDataLayout sets the offset and length of the data. The data is placed
+// immediately after the fixed portion of the response. It returns the data
+// buffer so that it can be populated.
+//
+// If the response is too small to hold length bytes the call will panic.
+func (r Response) SetDataLayout(length int) []byte {
+	if len(r)-ResponseSize < length {
+		panic("smbread: response: data is too large to fit in response")
+	}
+	r.SetDataOffset(headerSize + ResponseSize)
+	r.SetDataLength(uint32(length))
+	end := ResponseSize + length
+	return r[ResponseSize:end:end]
+}
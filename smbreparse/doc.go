@@ -0,0 +1,3 @@
+// Package smbreparse provides types for the reparse point buffers carried
+// by FSCTL_GET_REPARSE_POINT and FSCTL_SET_REPARSE_POINT.
+package smbreparse
@@ -0,0 +1,14 @@
+package smbreparse
+
+// Flags is a set of symbolic link flags.
+type Flags uint32
+
+// Symbolic link flags.
+const (
+	Relative = 0x00000001 // SYMLINK_FLAG_RELATIVE
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
@@ -0,0 +1,133 @@
+package smbreparse
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// SymlinkSize is the number of bytes required for the fixed portion of a
+// symbolic link reparse data buffer.
+const SymlinkSize = 20
+
+// headerSize is the number of bytes that precede the reparse data.
+const headerSize = 8
+
+// SymlinkBufferSize returns the number of bytes required for a symbolic
+// link reparse data buffer with the given names.
+func SymlinkBufferSize(substitute, print string) int {
+	return SymlinkSize + smbtype.StringLen(substitute) + smbtype.StringLen(print)
+}
+
+// SymlinkBuffer interprets a slice of bytes as a symbolic link reparse
+// data buffer.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.1.2.4
+type SymlinkBuffer []byte
+
+// Valid returns true if the buffer is valid.
+func (b SymlinkBuffer) Valid() bool {
+	if len(b) < SymlinkSize {
+		return false
+	}
+	if headerSize+int(b.ReparseDataLength()) > len(b) {
+		return false
+	}
+	path := uint(len(b) - SymlinkSize)
+	if uint(b.SubstituteNameOffset())+uint(b.SubstituteNameLength()) > path {
+		return false
+	}
+	if uint(b.PrintNameOffset())+uint(b.PrintNameLength()) > path {
+		return false
+	}
+	return true
+}
+
+// Tag returns the reparse tag of the buffer.
+func (b SymlinkBuffer) Tag() Tag {
+	return Tag(smbtype.Uint32(b[0:4]))
+}
+
+// SetTag sets the reparse tag of the buffer.
+func (b SymlinkBuffer) SetTag(tag Tag) {
+	smbtype.PutUint32(b[0:4], uint32(tag))
+}
+
+// ReparseDataLength returns the number of bytes of reparse data that
+// follow the header of the buffer.
+func (b SymlinkBuffer) ReparseDataLength() uint16 {
+	return smbtype.Uint16(b[4:6])
+}
+
+// SetReparseDataLength sets the number of bytes of reparse data that
+// follow the header of the buffer.
+func (b SymlinkBuffer) SetReparseDataLength(length uint16) {
+	smbtype.PutUint16(b[4:6], length)
+}
+
+// SubstituteNameOffset returns the offset of the substitute name within
+// the path buffer.
+func (b SymlinkBuffer) SubstituteNameOffset() uint16 {
+	return smbtype.Uint16(b[8:10])
+}
+
+// SubstituteNameLength returns the length of the substitute name in
+// bytes.
+func (b SymlinkBuffer) SubstituteNameLength() uint16 {
+	return smbtype.Uint16(b[10:12])
+}
+
+// PrintNameOffset returns the offset of the print name within the path
+// buffer.
+func (b SymlinkBuffer) PrintNameOffset() uint16 {
+	return smbtype.Uint16(b[12:14])
+}
+
+// PrintNameLength returns the length of the print name in bytes.
+func (b SymlinkBuffer) PrintNameLength() uint16 {
+	return smbtype.Uint16(b[14:16])
+}
+
+// Flags returns the symbolic link flags.
+func (b SymlinkBuffer) Flags() Flags {
+	return Flags(smbtype.Uint32(b[16:20]))
+}
+
+// SetFlags sets the symbolic link flags.
+func (b SymlinkBuffer) SetFlags(flags Flags) {
+	smbtype.PutUint32(b[16:20], uint32(flags))
+}
+
+// SubstituteName returns the substitute name, which is the target of the
+// symbolic link.
+func (b SymlinkBuffer) SubstituteName() string {
+	return pathName(b[SymlinkSize:], b.SubstituteNameOffset(), b.SubstituteNameLength())
+}
+
+// PrintName returns the print name, which is the target of the symbolic
+// link in a form suitable for display.
+func (b SymlinkBuffer) PrintName() string {
+	return pathName(b[SymlinkSize:], b.PrintNameOffset(), b.PrintNameLength())
+}
+
+// SetNames writes the substitute name and print name to the path buffer,
+// and updates their offsets and lengths and the reparse data length.
+//
+// If the buffer is too small to hold the names the call will panic.
+func (b SymlinkBuffer) SetNames(substitute, print string) {
+	if len(b) < SymlinkBufferSize(substitute, print) {
+		panic("smbreparse: symlink: names are too large to fit in buffer")
+	}
+	path := b[SymlinkSize:]
+	sublen := smbtype.PutString(path, substitute)
+	printlen := smbtype.PutString(path[sublen:], print)
+	smbtype.PutUint16(b[8:10], 0)
+	smbtype.PutUint16(b[10:12], uint16(sublen))
+	smbtype.PutUint16(b[12:14], uint16(sublen))
+	smbtype.PutUint16(b[14:16], uint16(printlen))
+	b.SetReparseDataLength(uint16(SymlinkSize - headerSize + sublen + printlen))
+}
+
+// pathName returns the name at the given offset and length within a path
+// buffer.
+func pathName(path []byte, offset, length uint16) string {
+	start := uint(offset)
+	end := start + uint(length)
+	return smbtype.String(path[start:end])
+}
@@ -0,0 +1,24 @@
+package smbreparse
+
+import "strconv"
+
+// Tag identifies the type of a reparse point.
+type Tag uint32
+
+// Reparse tags.
+const (
+	MountPoint = 0xA0000003 // IO_REPARSE_TAG_MOUNT_POINT
+	Symlink    = 0xA000000C // IO_REPARSE_TAG_SYMLINK
+)
+
+// String returns a string representation of the reparse tag.
+func (t Tag) String() string {
+	switch t {
+	case MountPoint:
+		return "MountPoint"
+	case Symlink:
+		return "Symlink"
+	default:
+		return "Tag 0x" + strconv.FormatUint(uint64(t), 16)
+	}
+}
@@ -0,0 +1,187 @@
+package smbrpc
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// BindSize is the number of bytes required for the fixed portion of a bind
+// or alter_context PDU, including its header.
+const BindSize = 28
+
+// ContextSize is the number of bytes required for the fixed portion of a
+// presentation context element.
+const ContextSize = 24
+
+// Bind interprets a slice of bytes as a bind or alter_context PDU, which
+// proposes presentation contexts for the RPC interfaces that a client
+// intends to call.
+//
+// See C706 section 12.6.4.3.
+type Bind []byte
+
+// Valid returns true if the PDU is valid. Its presentation context list
+// must fit within the fragment.
+func (b Bind) Valid() bool {
+	if len(b) < BindSize || !Header(b).Valid() {
+		return false
+	}
+	if t := Header(b).Type(); t != TypeBind && t != TypeAlterContext {
+		return false
+	}
+	body := Header(b).body()
+	if len(body) < BindSize-HeaderSize {
+		return false
+	}
+	offset := BindSize - HeaderSize
+	for i := 0; i < b.ContextCount(); i++ {
+		if offset+ContextSize > len(body) {
+			return false
+		}
+		length := Context(body[offset:]).Len()
+		if offset+length > len(body) {
+			return false
+		}
+		offset += length
+	}
+	return true
+}
+
+// Header returns the common header of the PDU.
+func (b Bind) Header() Header {
+	return Header(b[:HeaderSize])
+}
+
+// MaxXmitFrag returns the largest fragment the client can send.
+func (b Bind) MaxXmitFrag() uint16 {
+	return smbtype.Uint16(b[16:18])
+}
+
+// SetMaxXmitFrag sets the largest fragment the client can send.
+func (b Bind) SetMaxXmitFrag(size uint16) {
+	smbtype.PutUint16(b[16:18], size)
+}
+
+// MaxRecvFrag returns the largest fragment the client can receive.
+func (b Bind) MaxRecvFrag() uint16 {
+	return smbtype.Uint16(b[18:20])
+}
+
+// SetMaxRecvFrag sets the largest fragment the client can receive.
+func (b Bind) SetMaxRecvFrag(size uint16) {
+	smbtype.PutUint16(b[18:20], size)
+}
+
+// AssocGroupID returns the association group the client would like to join,
+// or zero if it would like a new one.
+func (b Bind) AssocGroupID() uint32 {
+	return smbtype.Uint32(b[20:24])
+}
+
+// SetAssocGroupID sets the association group the client would like to
+// join.
+func (b Bind) SetAssocGroupID(id uint32) {
+	smbtype.PutUint32(b[20:24], id)
+}
+
+// ContextCount returns the number of presentation contexts proposed by the
+// client.
+func (b Bind) ContextCount() int {
+	return int(b[24])
+}
+
+// SetContextCount sets the number of presentation contexts proposed by the
+// client.
+func (b Bind) SetContextCount(count int) {
+	b[24] = byte(count)
+	b[25] = 0
+	smbtype.PutUint16(b[26:28], 0)
+}
+
+// Contexts returns the presentation contexts proposed by the client. The
+// PDU must be valid.
+func (b Bind) Contexts() []Context {
+	body := Header(b).body()
+	offset := BindSize - HeaderSize
+	contexts := make([]Context, 0, b.ContextCount())
+	for i := 0; i < b.ContextCount(); i++ {
+		length := Context(body[offset:]).Len()
+		contexts = append(contexts, Context(body[offset:offset+length:offset+length]))
+		offset += length
+	}
+	return contexts
+}
+
+// SetContexts lays out the given presentation contexts after the fixed
+// portion of the PDU and updates its context count.
+//
+// If the PDU is too small to hold the contexts the call will panic. Use
+// BindBufferSize to determine the size of the buffer.
+func (b Bind) SetContexts(contexts ...Context) {
+	b.SetContextCount(len(contexts))
+	offset := BindSize
+	for _, c := range contexts {
+		offset += copy(b[offset:offset+len(c)], c)
+	}
+}
+
+// BindBufferSize returns the number of bytes required to hold a bind PDU
+// with the given presentation contexts.
+func BindBufferSize(contexts ...Context) int {
+	length := BindSize
+	for _, c := range contexts {
+		length += len(c)
+	}
+	return length
+}
+
+// Context interprets a slice of bytes as a presentation context element,
+// which proposes an abstract syntax and the transfer syntaxes the client
+// can use to call it.
+//
+// See C706 section 12.6.3.1.
+type Context []byte
+
+// NewContext returns a presentation context element with the given
+// context identifier, abstract syntax and transfer syntaxes.
+func NewContext(id uint16, abstract SyntaxID, transfers ...SyntaxID) Context {
+	c := make(Context, ContextSize+len(transfers)*SyntaxIDSize)
+	c.SetID(id)
+	c[2] = byte(len(transfers))
+	abstract.Write(c[4:24])
+	for i, s := range transfers {
+		s.Write(c[ContextSize+i*SyntaxIDSize:])
+	}
+	return c
+}
+
+// Len returns the length of the element in bytes.
+func (c Context) Len() int {
+	return ContextSize + c.TransferSyntaxCount()*SyntaxIDSize
+}
+
+// ID returns the presentation context identifier.
+func (c Context) ID() uint16 {
+	return smbtype.Uint16(c[0:2])
+}
+
+// SetID sets the presentation context identifier.
+func (c Context) SetID(id uint16) {
+	smbtype.PutUint16(c[0:2], id)
+}
+
+// TransferSyntaxCount returns the number of transfer syntaxes proposed for
+// the context.
+func (c Context) TransferSyntaxCount() int {
+	return int(c[2])
+}
+
+// AbstractSyntax returns the abstract syntax of the context.
+func (c Context) AbstractSyntax() (s SyntaxID) {
+	s.Read(c[4:24])
+	return
+}
+
+// TransferSyntax returns the transfer syntax at index i.
+func (c Context) TransferSyntax(i int) (s SyntaxID) {
+	offset := ContextSize + i*SyntaxIDSize
+	s.Read(c[offset : offset+SyntaxIDSize])
+	return
+}
@@ -0,0 +1,144 @@
+package smbrpc
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// BindAck interprets a slice of bytes as a bind_ack or alter_context_resp
+// PDU, which reports the results of the presentation contexts proposed by
+// a bind or alter_context PDU.
+//
+// The secondary address is variable in length, so the result list must be
+// laid out with SetAddress before results are written.
+//
+// See C706 section 12.6.4.4.
+type BindAck []byte
+
+// Valid returns true if the PDU is valid. Its address and result list must
+// fit within the fragment.
+func (b BindAck) Valid() bool {
+	if len(b) < 28 || !Header(b).Valid() {
+		return false
+	}
+	if t := Header(b).Type(); t != TypeBindAck && t != TypeAlterContextResp {
+		return false
+	}
+	end := HeaderSize + len(Header(b).body())
+	results := b.resultsOffset()
+	if results+4 > end {
+		return false
+	}
+	return results+4+b.ResultCount()*ResultSize <= end
+}
+
+// Header returns the common header of the PDU.
+func (b BindAck) Header() Header {
+	return Header(b[:HeaderSize])
+}
+
+// MaxXmitFrag returns the largest fragment the server can send.
+func (b BindAck) MaxXmitFrag() uint16 {
+	return smbtype.Uint16(b[16:18])
+}
+
+// SetMaxXmitFrag sets the largest fragment the server can send.
+func (b BindAck) SetMaxXmitFrag(size uint16) {
+	smbtype.PutUint16(b[16:18], size)
+}
+
+// MaxRecvFrag returns the largest fragment the server can receive.
+func (b BindAck) MaxRecvFrag() uint16 {
+	return smbtype.Uint16(b[18:20])
+}
+
+// SetMaxRecvFrag sets the largest fragment the server can receive.
+func (b BindAck) SetMaxRecvFrag(size uint16) {
+	smbtype.PutUint16(b[18:20], size)
+}
+
+// AssocGroupID returns the association group the client has joined.
+func (b BindAck) AssocGroupID() uint32 {
+	return smbtype.Uint32(b[20:24])
+}
+
+// SetAssocGroupID sets the association group the client has joined.
+func (b BindAck) SetAssocGroupID(id uint32) {
+	smbtype.PutUint32(b[20:24], id)
+}
+
+// Address returns the secondary address of the PDU, which is the name of
+// the pipe the client is bound to, such as \PIPE\srvsvc.
+func (b BindAck) Address() string {
+	length := int(smbtype.Uint16(b[24:26]))
+	if length == 0 {
+		return ""
+	}
+	if 26+length > len(b) {
+		return ""
+	}
+	// The length includes a null terminator
+	return string(b[26 : 26+length-1])
+}
+
+// SetAddress writes the secondary address of the PDU, which determines
+// where its result list begins. Alter context responses carry an empty
+// address.
+//
+// If the PDU is too small to hold the address the call will panic. Use
+// BindAckBufferSize to determine the size of the buffer.
+func (b BindAck) SetAddress(address string) {
+	length := 0
+	if address != "" {
+		length = len(address) + 1
+		copy(b[26:], address)
+		b[26+len(address)] = 0
+	}
+	smbtype.PutUint16(b[24:26], uint16(length))
+	for i := 26 + length; i < b.resultsOffset(); i++ {
+		b[i] = 0
+	}
+}
+
+// ResultCount returns the number of presentation context results.
+func (b BindAck) ResultCount() int {
+	return int(b[b.resultsOffset()])
+}
+
+// SetResultCount sets the number of presentation context results.
+func (b BindAck) SetResultCount(count int) {
+	offset := b.resultsOffset()
+	b[offset] = byte(count)
+	b[offset+1] = 0
+	smbtype.PutUint16(b[offset+2:offset+4], 0)
+}
+
+// Result returns the presentation context result at index i.
+func (b BindAck) Result(i int) (r Result) {
+	offset := b.resultsOffset() + 4 + i*ResultSize
+	r.Read(b[offset : offset+ResultSize])
+	return
+}
+
+// SetResult sets the presentation context result at index i.
+func (b BindAck) SetResult(i int, r Result) {
+	offset := b.resultsOffset() + 4 + i*ResultSize
+	r.Write(b[offset : offset+ResultSize])
+}
+
+// resultsOffset returns the offset of the result list, which follows the
+// secondary address and is aligned to a 4-byte boundary.
+func (b BindAck) resultsOffset() int {
+	return align4(26 + int(smbtype.Uint16(b[24:26])))
+}
+
+// BindAckBufferSize returns the number of bytes required to hold a
+// bind_ack PDU with the given secondary address and number of results.
+func BindAckBufferSize(address string, results int) int {
+	length := 26
+	if address != "" {
+		length += len(address) + 1
+	}
+	return align4(length) + 4 + results*ResultSize
+}
+
+func align4(length int) int {
+	return (length + 3) &^ 3
+}
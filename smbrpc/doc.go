@@ -0,0 +1,11 @@
+// Package smbrpc provides types for the connection-oriented DCE/RPC protocol
+// data units that are exchanged over SMB named pipes, such as the bind,
+// bind_ack, request, response and fault PDUs.
+//
+// Only little-endian data representations are supported, which are used by
+// every Windows client.
+//
+// See C706 chapter 12.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-rpce/ section 2.2.2
+package smbrpc
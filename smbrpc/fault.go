@@ -0,0 +1,108 @@
+package smbrpc
+
+import (
+	"strconv"
+
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// FaultSize is the number of bytes in a fault PDU, including its header.
+const FaultSize = 32
+
+// FaultStatus is the status code carried by a fault PDU.
+type FaultStatus uint32
+
+// Fault status codes.
+const (
+	AccessDenied      = 0x00000005 // ERROR_ACCESS_DENIED
+	BadStubData       = 0x000006F7 // RPC_X_BAD_STUB_DATA
+	UnspecifiedReject = 0x1C000009 // nca_s_unspec_reject
+	InvalidContextID  = 0x1C00001C // nca_s_invalid_pres_context_id
+	OpRangeError      = 0x1C010002 // nca_s_op_rng_error
+	UnknownInterface  = 0x1C010003 // nca_s_unk_if
+	ProtocolError     = 0x1C01000B // nca_s_proto_error
+)
+
+// String returns a string representation of the fault status.
+func (s FaultStatus) String() string {
+	switch s {
+	case AccessDenied:
+		return "AccessDenied"
+	case BadStubData:
+		return "BadStubData"
+	case UnspecifiedReject:
+		return "UnspecifiedReject"
+	case InvalidContextID:
+		return "InvalidContextID"
+	case OpRangeError:
+		return "OpRangeError"
+	case UnknownInterface:
+		return "UnknownInterface"
+	case ProtocolError:
+		return "ProtocolError"
+	default:
+		return "FaultStatus 0x" + strconv.FormatUint(uint64(s), 16)
+	}
+}
+
+// Fault interprets a slice of bytes as a fault PDU, which reports that a
+// call failed.
+//
+// See C706 section 12.6.4.7.
+type Fault []byte
+
+// Valid returns true if the PDU is valid.
+func (f Fault) Valid() bool {
+	if len(f) < FaultSize || !Header(f).Valid() || Header(f).Type() != TypeFault {
+		return false
+	}
+	return FaultSize <= HeaderSize+len(Header(f).body())
+}
+
+// Header returns the common header of the PDU.
+func (f Fault) Header() Header {
+	return Header(f[:HeaderSize])
+}
+
+// AllocHint returns the length of the stub data that follows the fault, if
+// any.
+func (f Fault) AllocHint() uint32 {
+	return smbtype.Uint32(f[16:20])
+}
+
+// SetAllocHint sets the length of the stub data that follows the fault.
+func (f Fault) SetAllocHint(hint uint32) {
+	smbtype.PutUint32(f[16:20], hint)
+}
+
+// ContextID returns the presentation context of the failed call.
+func (f Fault) ContextID() uint16 {
+	return smbtype.Uint16(f[20:22])
+}
+
+// SetContextID sets the presentation context of the failed call.
+func (f Fault) SetContextID(id uint16) {
+	smbtype.PutUint16(f[20:22], id)
+}
+
+// CancelCount returns the number of cancels received for the call.
+func (f Fault) CancelCount() uint8 {
+	return f[22]
+}
+
+// SetCancelCount sets the number of cancels received for the call.
+func (f Fault) SetCancelCount(count uint8) {
+	f[22] = count
+	f[23] = 0
+}
+
+// Status returns the status of the fault.
+func (f Fault) Status() FaultStatus {
+	return FaultStatus(smbtype.Uint32(f[24:28]))
+}
+
+// SetStatus sets the status of the fault.
+func (f Fault) SetStatus(status FaultStatus) {
+	smbtype.PutUint32(f[24:28], uint32(status))
+	smbtype.PutUint32(f[28:32], 0)
+}
@@ -0,0 +1,20 @@
+package smbrpc
+
+// Flags are the flags of a PDU.
+type Flags uint8
+
+// PDU flags.
+const (
+	FirstFrag     = 0x01 // PFC_FIRST_FRAG
+	LastFrag      = 0x02 // PFC_LAST_FRAG
+	PendingCancel = 0x04 // PFC_PENDING_CANCEL
+	ConcMpx       = 0x10 // PFC_CONC_MPX
+	DidNotExecute = 0x20 // PFC_DID_NOT_EXECUTE
+	Maybe         = 0x40 // PFC_MAYBE
+	ObjectUUID    = 0x80 // PFC_OBJECT_UUID
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
@@ -0,0 +1,151 @@
+package smbrpc
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// HeaderSize is the number of bytes in the common header of a
+// connection-oriented PDU.
+const HeaderSize = 16
+
+// DataRepresentation is the packed data representation label for
+// little-endian integers, ASCII characters and IEEE floating point numbers.
+const DataRepresentation = 0x00000010
+
+// AuthTrailerSize is the number of bytes in the sec_trailer that precedes
+// the authentication value of a PDU.
+const AuthTrailerSize = 8
+
+// Header interprets a slice of bytes as the common header of a
+// connection-oriented PDU.
+//
+// See C706 section 12.6.1.
+type Header []byte
+
+// Valid returns true if the header is valid. The header must be for
+// version 5.0 or 5.1 of the protocol, must use little-endian integers and
+// must describe a fragment that is no longer than h.
+func (h Header) Valid() bool {
+	if len(h) < HeaderSize {
+		return false
+	}
+	if h.Version() != 5 || h.MinorVersion() > 1 {
+		return false
+	}
+	if h.DataRepresentation()&0xF0 != DataRepresentation {
+		return false
+	}
+	length := int(h.FragLength())
+	if length < HeaderSize || length > len(h) {
+		return false
+	}
+	if auth := int(h.AuthLength()); auth > 0 && HeaderSize+AuthTrailerSize+auth > length {
+		return false
+	}
+	return true
+}
+
+// Version returns the major version of the protocol.
+func (h Header) Version() uint8 {
+	return h[0]
+}
+
+// SetVersion sets the major version of the protocol.
+func (h Header) SetVersion(version uint8) {
+	h[0] = version
+}
+
+// MinorVersion returns the minor version of the protocol.
+func (h Header) MinorVersion() uint8 {
+	return h[1]
+}
+
+// SetMinorVersion sets the minor version of the protocol.
+func (h Header) SetMinorVersion(version uint8) {
+	h[1] = version
+}
+
+// Type returns the type of the PDU.
+func (h Header) Type() PacketType {
+	return PacketType(h[2])
+}
+
+// SetType sets the type of the PDU.
+func (h Header) SetType(t PacketType) {
+	h[2] = byte(t)
+}
+
+// Flags returns the flags of the PDU.
+func (h Header) Flags() Flags {
+	return Flags(h[3])
+}
+
+// SetFlags sets the flags of the PDU.
+func (h Header) SetFlags(flags Flags) {
+	h[3] = byte(flags)
+}
+
+// DataRepresentation returns the packed data representation label of the
+// PDU.
+func (h Header) DataRepresentation() uint32 {
+	return smbtype.Uint32(h[4:8])
+}
+
+// SetDataRepresentation sets the packed data representation label of the
+// PDU.
+func (h Header) SetDataRepresentation(drep uint32) {
+	smbtype.PutUint32(h[4:8], drep)
+}
+
+// FragLength returns the length of the fragment in bytes, including the
+// header.
+func (h Header) FragLength() uint16 {
+	return smbtype.Uint16(h[8:10])
+}
+
+// SetFragLength sets the length of the fragment in bytes, including the
+// header.
+func (h Header) SetFragLength(length uint16) {
+	smbtype.PutUint16(h[8:10], length)
+}
+
+// AuthLength returns the length of the authentication value in bytes.
+func (h Header) AuthLength() uint16 {
+	return smbtype.Uint16(h[10:12])
+}
+
+// SetAuthLength sets the length of the authentication value in bytes.
+func (h Header) SetAuthLength(length uint16) {
+	smbtype.PutUint16(h[10:12], length)
+}
+
+// CallID returns the call identifier of the PDU.
+func (h Header) CallID() uint32 {
+	return smbtype.Uint32(h[12:16])
+}
+
+// SetCallID sets the call identifier of the PDU.
+func (h Header) SetCallID(id uint32) {
+	smbtype.PutUint32(h[12:16], id)
+}
+
+// Init writes a version 5.0 header with a little-endian data
+// representation and no authentication value.
+func (h Header) Init(t PacketType, flags Flags, fragLength uint16, callID uint32) {
+	h.SetVersion(5)
+	h.SetMinorVersion(0)
+	h.SetType(t)
+	h.SetFlags(flags)
+	h.SetDataRepresentation(DataRepresentation)
+	h.SetFragLength(fragLength)
+	h.SetAuthLength(0)
+	h.SetCallID(callID)
+}
+
+// body returns the bytes of a PDU that follow its common header and
+// precede its authentication trailer, if any. The header must be valid.
+func (h Header) body() []byte {
+	end := int(h.FragLength())
+	if auth := int(h.AuthLength()); auth > 0 {
+		end -= AuthTrailerSize + auth
+	}
+	return h[HeaderSize:end:end]
+}
@@ -0,0 +1,84 @@
+package smbrpc
+
+import (
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for the fixed portion of a
+// request PDU, including its header.
+const RequestSize = 24
+
+// Request interprets a slice of bytes as a request PDU, which carries a
+// fragment of the stub data of a call to an RPC interface.
+//
+// See C706 section 12.6.4.9.
+type Request []byte
+
+// Valid returns true if the PDU is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize || !Header(r).Valid() || Header(r).Type() != TypeRequest {
+		return false
+	}
+	start := RequestSize
+	if Header(r).Flags().Match(ObjectUUID) {
+		start += 16
+	}
+	return start <= HeaderSize+len(Header(r).body())
+}
+
+// Header returns the common header of the PDU.
+func (r Request) Header() Header {
+	return Header(r[:HeaderSize])
+}
+
+// AllocHint returns the total length of the stub data of the call, if
+// known, or zero.
+func (r Request) AllocHint() uint32 {
+	return smbtype.Uint32(r[16:20])
+}
+
+// SetAllocHint sets the total length of the stub data of the call.
+func (r Request) SetAllocHint(hint uint32) {
+	smbtype.PutUint32(r[16:20], hint)
+}
+
+// ContextID returns the presentation context of the call.
+func (r Request) ContextID() uint16 {
+	return smbtype.Uint16(r[20:22])
+}
+
+// SetContextID sets the presentation context of the call.
+func (r Request) SetContextID(id uint16) {
+	smbtype.PutUint16(r[20:22], id)
+}
+
+// Opnum returns the operation number of the call within its interface.
+func (r Request) Opnum() uint16 {
+	return smbtype.Uint16(r[22:24])
+}
+
+// SetOpnum sets the operation number of the call within its interface.
+func (r Request) SetOpnum(opnum uint16) {
+	smbtype.PutUint16(r[22:24], opnum)
+}
+
+// Object returns the object UUID of the call. It returns false if the
+// request doesn't carry one.
+func (r Request) Object() (id smbid.ID, ok bool) {
+	if !Header(r).Flags().Match(ObjectUUID) {
+		return id, false
+	}
+	id.Read(r[24:40])
+	return id, true
+}
+
+// Stub returns the stub data of the fragment. The PDU must be valid.
+func (r Request) Stub() []byte {
+	body := Header(r).body()
+	start := RequestSize - HeaderSize
+	if Header(r).Flags().Match(ObjectUUID) {
+		start += 16
+	}
+	return body[start:]
+}
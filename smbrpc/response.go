@@ -0,0 +1,64 @@
+package smbrpc
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ResponseSize is the number of bytes required for the fixed portion of a
+// response PDU, including its header.
+const ResponseSize = 24
+
+// Response interprets a slice of bytes as a response PDU, which carries a
+// fragment of the stub data returned by a call to an RPC interface.
+//
+// See C706 section 12.6.4.10.
+type Response []byte
+
+// Valid returns true if the PDU is valid.
+func (r Response) Valid() bool {
+	if len(r) < ResponseSize || !Header(r).Valid() || Header(r).Type() != TypeResponse {
+		return false
+	}
+	return ResponseSize <= HeaderSize+len(Header(r).body())
+}
+
+// Header returns the common header of the PDU.
+func (r Response) Header() Header {
+	return Header(r[:HeaderSize])
+}
+
+// AllocHint returns the total length of the stub data returned by the call,
+// if known, or zero.
+func (r Response) AllocHint() uint32 {
+	return smbtype.Uint32(r[16:20])
+}
+
+// SetAllocHint sets the total length of the stub data returned by the call.
+func (r Response) SetAllocHint(hint uint32) {
+	smbtype.PutUint32(r[16:20], hint)
+}
+
+// ContextID returns the presentation context of the call.
+func (r Response) ContextID() uint16 {
+	return smbtype.Uint16(r[20:22])
+}
+
+// SetContextID sets the presentation context of the call.
+func (r Response) SetContextID(id uint16) {
+	smbtype.PutUint16(r[20:22], id)
+}
+
+// CancelCount returns the number of cancels received for the call.
+func (r Response) CancelCount() uint8 {
+	return r[22]
+}
+
+// SetCancelCount sets the number of cancels received for the call.
+func (r Response) SetCancelCount(count uint8) {
+	r[22] = count
+	r[23] = 0
+}
+
+// Stub returns the stub data of the fragment. The PDU must be valid, or its
+// header must at least hold its fragment length.
+func (r Response) Stub() []byte {
+	return Header(r).body()[ResponseSize-HeaderSize:]
+}
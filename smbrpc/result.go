@@ -0,0 +1,80 @@
+package smbrpc
+
+import (
+	"strconv"
+
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ResultSize is the number of bytes in a serialized presentation context
+// result.
+const ResultSize = 24
+
+// ResultCode indicates whether a proposed presentation context was
+// accepted.
+type ResultCode uint16
+
+// Presentation context results.
+const (
+	Acceptance        = 0 // acceptance
+	UserRejection     = 1 // user_rejection
+	ProviderRejection = 2 // provider_rejection
+	NegotiateAck      = 3 // negotiate_ack
+)
+
+// String returns a string representation of the result code.
+func (r ResultCode) String() string {
+	switch r {
+	case Acceptance:
+		return "Acceptance"
+	case UserRejection:
+		return "UserRejection"
+	case ProviderRejection:
+		return "ProviderRejection"
+	case NegotiateAck:
+		return "NegotiateAck"
+	default:
+		return "ResultCode " + strconv.Itoa(int(r))
+	}
+}
+
+// RejectReason explains why a proposed presentation context was rejected.
+type RejectReason uint16
+
+// Presentation context rejection reasons.
+const (
+	ReasonNotSpecified                   = 0 // reason_not_specified
+	AbstractSyntaxNotSupported           = 1 // abstract_syntax_not_supported
+	ProposedTransferSyntaxesNotSupported = 2 // proposed_transfer_syntaxes_not_supported
+	LocalLimitExceeded                   = 3 // local_limit_exceeded
+)
+
+// Result is the result of a proposed presentation context. The transfer
+// syntax is the one selected by the server for accepted contexts, and is
+// zero otherwise.
+//
+// See C706 section 12.6.3.1.
+type Result struct {
+	Result         ResultCode
+	Reason         RejectReason
+	TransferSyntax SyntaxID
+}
+
+// Read interprets a slice of bytes as a serialized presentation context
+// result and copies its value to r. If v is less than 24 bytes long Read
+// will panic.
+func (r *Result) Read(v []byte) {
+	_ = v[23] // bounds check hint to compiler; see golang.org/issue/14808
+	r.Result = ResultCode(smbtype.Uint16(v[0:2]))
+	r.Reason = RejectReason(smbtype.Uint16(v[2:4]))
+	r.TransferSyntax.Read(v[4:24])
+}
+
+// Write writes r to v as a serialized presentation context result. If v is
+// less than 24 bytes long Write will panic.
+func (r Result) Write(v []byte) {
+	_ = v[23] // Early bounds check to guarantee safety of writes below
+	smbtype.PutUint16(v[0:2], uint16(r.Result))
+	smbtype.PutUint16(v[2:4], uint16(r.Reason))
+	r.TransferSyntax.Write(v[4:24])
+}
@@ -0,0 +1,61 @@
+package smbrpc
+
+import (
+	"strconv"
+
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// SyntaxIDSize is the number of bytes in a serialized syntax identifier.
+const SyntaxIDSize = 20
+
+// SyntaxID identifies an abstract syntax, which is an RPC interface, or a
+// transfer syntax, which is an encoding of RPC parameters, by UUID and
+// version.
+//
+// See C706 section 12.6.3.1.
+type SyntaxID struct {
+	UUID         smbid.ID
+	Version      uint16 // Major version
+	MinorVersion uint16
+}
+
+// Transfer syntaxes.
+var (
+	// NDR is the NDR 2.0 transfer syntax, which is the only transfer syntax
+	// that is supported by this package.
+	NDR = SyntaxID{
+		UUID:    smbid.ID{0x8a, 0x88, 0x5d, 0x04, 0x1c, 0xeb, 0x11, 0xc9, 0x9f, 0xe8, 0x08, 0x00, 0x2b, 0x10, 0x48, 0x60},
+		Version: 2,
+	}
+
+	// NDR64 is the NDR64 transfer syntax.
+	NDR64 = SyntaxID{
+		UUID:    smbid.ID{0x71, 0x71, 0x05, 0x33, 0xbe, 0xba, 0x49, 0x37, 0x83, 0x19, 0xb5, 0xdb, 0xef, 0x9c, 0xcc, 0x36},
+		Version: 1,
+	}
+)
+
+// Read interprets a slice of bytes as a serialized syntax identifier and
+// copies its value to s. If v is less than 20 bytes long Read will panic.
+func (s *SyntaxID) Read(v []byte) {
+	_ = v[19] // bounds check hint to compiler; see golang.org/issue/14808
+	s.UUID.Read(v[0:16])
+	s.Version = smbtype.Uint16(v[16:18])
+	s.MinorVersion = smbtype.Uint16(v[18:20])
+}
+
+// Write writes s to v as a serialized syntax identifier. If v is less than
+// 20 bytes long Write will panic.
+func (s SyntaxID) Write(v []byte) {
+	_ = v[19] // Early bounds check to guarantee safety of writes below
+	s.UUID.Write(v[0:16])
+	smbtype.PutUint16(v[16:18], s.Version)
+	smbtype.PutUint16(v[18:20], s.MinorVersion)
+}
+
+// String returns a string representation of the syntax identifier.
+func (s SyntaxID) String() string {
+	return s.UUID.String() + " v" + strconv.Itoa(int(s.Version)) + "." + strconv.Itoa(int(s.MinorVersion))
+}
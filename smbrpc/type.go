@@ -0,0 +1,54 @@
+package smbrpc
+
+import "strconv"
+
+// PacketType identifies the type of a PDU.
+type PacketType uint8
+
+// Connection-oriented PDU types.
+const (
+	TypeRequest          = 0  // request
+	TypeResponse         = 2  // response
+	TypeFault            = 3  // fault
+	TypeBind             = 11 // bind
+	TypeBindAck          = 12 // bind_ack
+	TypeBindNak          = 13 // bind_nak
+	TypeAlterContext     = 14 // alter_context
+	TypeAlterContextResp = 15 // alter_context_resp
+	TypeAuth3            = 16 // auth3
+	TypeShutdown         = 17 // shutdown
+	TypeCoCancel         = 18 // co_cancel
+	TypeOrphaned         = 19 // orphaned
+)
+
+// String returns a string representation of the packet type.
+func (t PacketType) String() string {
+	switch t {
+	case TypeRequest:
+		return "Request"
+	case TypeResponse:
+		return "Response"
+	case TypeFault:
+		return "Fault"
+	case TypeBind:
+		return "Bind"
+	case TypeBindAck:
+		return "BindAck"
+	case TypeBindNak:
+		return "BindNak"
+	case TypeAlterContext:
+		return "AlterContext"
+	case TypeAlterContextResp:
+		return "AlterContextResp"
+	case TypeAuth3:
+		return "Auth3"
+	case TypeShutdown:
+		return "Shutdown"
+	case TypeCoCancel:
+		return "CoCancel"
+	case TypeOrphaned:
+		return "Orphaned"
+	default:
+		return "PacketType " + strconv.Itoa(int(t))
+	}
+}
@@ -11,6 +11,11 @@ const (
 	SigningRequired = 0x0002 // SMB2_NEGOTIATE_SIGNING_REQUIRED
 )
 
+// Match reports whether f contains all of the flags specified by c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
+
 // Flags returns a string representation of the security mode flags.
 func (f Flags) String() string {
 	switch f {
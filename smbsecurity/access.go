@@ -0,0 +1,118 @@
+package smbsecurity
+
+import "github.com/gentlemanautomaton/smb/smbaccess"
+
+// ownerImplicitRights are the rights that the owner of an object holds
+// unless its DACL holds an OWNER RIGHTS ACE.
+const ownerImplicitRights = smbaccess.ReadControl | smbaccess.WriteDAC
+
+// Rights granted by privileges.
+const (
+	backupRights  = smbaccess.FileGenericRead | smbaccess.Traverse | smbaccess.SystemSecurity
+	restoreRights = smbaccess.FileGenericWrite | smbaccess.WriteDAC | smbaccess.WriteOwner | smbaccess.Delete |
+		smbaccess.AddFile | smbaccess.AddSubdir | smbaccess.SystemSecurity
+)
+
+// AccessCheck evaluates sd against token and determines whether the access
+// in desired is granted. Generic rights in desired are mapped to file
+// rights. If desired includes MAXIMUM_ALLOWED every right that sd grants
+// to token is returned; otherwise the returned mask is desired itself. If
+// any of the rights in desired aren't granted AccessCheck returns zero and
+// false.
+//
+// The ACEs of the DACL are evaluated in order. Each right is granted or
+// denied by the first ACE for one of the token's SIDs that mentions it, so
+// a DACL in canonical order denies rights before it grants them. A
+// descriptor without a DACL, or with a null DACL, grants every right. The
+// owner of the object is always granted READ_CONTROL and WRITE_DAC, unless
+// the DACL holds an ACE for OWNER RIGHTS. ACCESS_SYSTEM_SECURITY is only
+// granted by privileges.
+//
+// Object ACEs that name an object type and callback ACEs that grant access
+// are ignored, because file objects have no object types and conditional
+// expressions aren't evaluated. Callback ACEs that deny access are always
+// applied.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.5.3.2
+func AccessCheck(sd *Descriptor, token *Token, desired smbaccess.Mask) (granted smbaccess.Mask, ok bool) {
+	maximum := desired.Match(smbaccess.MaximumAllowed)
+	desired = desired.MapGeneric() &^ smbaccess.MaximumAllowed
+
+	var privileged smbaccess.Mask
+	if token.Privileges.Match(SecurityPrivilege) {
+		privileged |= smbaccess.SystemSecurity
+	}
+	if token.Privileges.Match(TakeOwnershipPrivilege) {
+		privileged |= smbaccess.WriteOwner
+	}
+	if token.Privileges.Match(BackupPrivilege) {
+		privileged |= backupRights
+	}
+	if token.Privileges.Match(RestorePrivilege) {
+		privileged |= restoreRights
+	}
+
+	var allowed smbaccess.Mask
+	if sd.DACL == nil {
+		allowed = smbaccess.FileAllAccess
+	} else {
+		allowed = evaluateDACL(sd, token)
+	}
+	allowed |= privileged
+
+	if !allowed.Match(desired) {
+		return 0, false
+	}
+	if maximum {
+		return allowed, true
+	}
+	return desired, true
+}
+
+// evaluateDACL returns the rights granted to token by the DACL of sd,
+// which must not be nil.
+func evaluateDACL(sd *Descriptor, token *Token) smbaccess.Mask {
+	owner := sd.Owner.Valid() && token.Contains(sd.Owner)
+
+	var allowed, denied smbaccess.Mask
+	if owner && !hasOwnerRights(sd.DACL) {
+		allowed = ownerImplicitRights
+	}
+
+	for i := range sd.DACL.ACEs {
+		ace := &sd.DACL.ACEs[i]
+		if !ace.Applies() {
+			continue
+		}
+		if ace.Type.Object() && ace.ObjectFlags.Match(ObjectTypePresent) {
+			continue
+		}
+		if ace.SID.Equal(OwnerRights) {
+			if !owner {
+				continue
+			}
+		} else if !token.Contains(ace.SID) {
+			continue
+		}
+
+		mask := ace.Mask.MapGeneric() &^ smbaccess.SystemSecurity
+		switch {
+		case ace.Type.Deny():
+			denied |= mask &^ allowed
+		case ace.Type.Allow() && !ace.Type.Callback():
+			allowed |= mask &^ denied
+		}
+	}
+	return allowed
+}
+
+// hasOwnerRights returns true if acl holds an ACE for OWNER RIGHTS that
+// applies to the object, which replaces the implicit rights of its owner.
+func hasOwnerRights(acl *ACL) bool {
+	for i := range acl.ACEs {
+		if acl.ACEs[i].Applies() && acl.ACEs[i].SID.Equal(OwnerRights) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package smbsecurity
+
+import (
+	"errors"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ErrInvalidACE is returned when an access control entry is malformed.
+var ErrInvalidACE = errors.New("smbsecurity: invalid access control entry")
+
+// aceHeaderSize is the number of bytes in the header of an ACE.
+const aceHeaderSize = 4
+
+// ACE is an access control entry, which allows, denies or audits access by
+// a principal.
+//
+// Every type of ACE other than AccessAllowedCompound holds an access mask
+// followed by a SID. Object ACEs also identify the types of objects they
+// apply to. Any data that follows the SID, such as the condition of a
+// callback ACE, is held in Data.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.4
+type ACE struct {
+	Type                ACEType
+	Flags               ACEFlags
+	Mask                smbaccess.Mask
+	ObjectFlags         ObjectFlags // Object ACEs only
+	ObjectType          smbid.ID    // Object ACEs with ObjectTypePresent only
+	InheritedObjectType smbid.ID    // Object ACEs with InheritedObjectTypePresent only
+	SID                 SID
+	Data                []byte
+}
+
+// Applies returns true if the ACE takes part in access checks for the
+// object it is attached to, rather than only being inherited by its
+// children.
+func (ace *ACE) Applies() bool {
+	return !ace.Flags.Match(InheritOnly)
+}
+
+// Len returns the number of bytes in the serialized ACE, which is a
+// multiple of 4.
+func (ace *ACE) Len() int {
+	n := aceHeaderSize + 4 + ace.SID.Len() + len(ace.Data)
+	if ace.Type.Object() {
+		n += 4
+		if ace.ObjectFlags.Match(ObjectTypePresent) {
+			n += 16
+		}
+		if ace.ObjectFlags.Match(InheritedObjectTypePresent) {
+			n += 16
+		}
+	}
+	return (n + 3) &^ 3
+}
+
+// write writes the ACE to b, which must be at least ace.Len() bytes long.
+func (ace *ACE) write(b []byte) {
+	n := ace.Len()
+	b[0] = uint8(ace.Type)
+	b[1] = uint8(ace.Flags)
+	smbtype.PutUint16(b[2:4], uint16(n))
+	smbtype.PutUint32(b[4:8], uint32(ace.Mask))
+	off := 8
+	if ace.Type.Object() {
+		smbtype.PutUint32(b[off:off+4], uint32(ace.ObjectFlags))
+		off += 4
+		if ace.ObjectFlags.Match(ObjectTypePresent) {
+			ace.ObjectType.Write(b[off : off+16])
+			off += 16
+		}
+		if ace.ObjectFlags.Match(InheritedObjectTypePresent) {
+			ace.InheritedObjectType.Write(b[off : off+16])
+			off += 16
+		}
+	}
+	off += copy(b[off:], ace.SID[:ace.SID.Len()])
+	off += copy(b[off:], ace.Data)
+	for ; off < n; off++ {
+		b[off] = 0
+	}
+}
+
+// readACE decodes the ACE at the start of b and returns it along with its
+// length.
+func readACE(b []byte) (ace ACE, n int, err error) {
+	if len(b) < aceHeaderSize+4 {
+		return ACE{}, 0, ErrInvalidACE
+	}
+	n = int(smbtype.Uint16(b[2:4]))
+	if n < aceHeaderSize+4 || n > len(b) {
+		return ACE{}, 0, ErrInvalidACE
+	}
+	b = b[:n]
+
+	ace.Type = ACEType(b[0])
+	ace.Flags = ACEFlags(b[1])
+	if ace.Type == AccessAllowedCompound {
+		// Compound ACEs aren't used by Windows; their bodies are kept
+		// intact
+		ace.Mask = smbaccess.Mask(smbtype.Uint32(b[4:8]))
+		ace.Data = append([]byte(nil), b[8:]...)
+		return ace, n, nil
+	}
+	ace.Mask = smbaccess.Mask(smbtype.Uint32(b[4:8]))
+	off := 8
+	if ace.Type.Object() {
+		if len(b) < off+4 {
+			return ACE{}, 0, ErrInvalidACE
+		}
+		ace.ObjectFlags = ObjectFlags(smbtype.Uint32(b[off : off+4]))
+		off += 4
+		if ace.ObjectFlags.Match(ObjectTypePresent) {
+			if len(b) < off+16 {
+				return ACE{}, 0, ErrInvalidACE
+			}
+			ace.ObjectType.Read(b[off : off+16])
+			off += 16
+		}
+		if ace.ObjectFlags.Match(InheritedObjectTypePresent) {
+			if len(b) < off+16 {
+				return ACE{}, 0, ErrInvalidACE
+			}
+			ace.InheritedObjectType.Read(b[off : off+16])
+			off += 16
+		}
+	}
+
+	sid := SID(b[off:])
+	if !sid.Valid() {
+		return ACE{}, 0, ErrInvalidACE
+	}
+	ace.SID = append(SID(nil), sid[:sid.Len()]...)
+	off += sid.Len()
+	if data := b[off:]; len(data) > 0 && !allZero(data) {
+		ace.Data = append([]byte(nil), data...)
+	}
+	return ace, n, nil
+}
+
+// allZero returns true if b holds nothing but zeros.
+func allZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package smbsecurity
+
+// ACEFlags control the inheritance and auditing behavior of an access
+// control entry.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.4.1
+type ACEFlags uint8
+
+// ACE flags.
+const (
+	ObjectInherit      = 0x01 // OBJECT_INHERIT_ACE
+	ContainerInherit   = 0x02 // CONTAINER_INHERIT_ACE
+	NoPropagateInherit = 0x04 // NO_PROPAGATE_INHERIT_ACE
+	InheritOnly        = 0x08 // INHERIT_ONLY_ACE
+	Inherited          = 0x10 // INHERITED_ACE
+	SuccessfulAccess   = 0x40 // SUCCESSFUL_ACCESS_ACE_FLAG
+	FailedAccess       = 0x80 // FAILED_ACCESS_ACE_FLAG
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f ACEFlags) Match(c ACEFlags) bool {
+	return f&c == c
+}
+
+// ObjectFlags indicate which object types are present in an object ACE.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.4.3
+type ObjectFlags uint32
+
+// Object ACE flags.
+const (
+	ObjectTypePresent          = 0x00000001 // ACE_OBJECT_TYPE_PRESENT
+	InheritedObjectTypePresent = 0x00000002 // ACE_INHERITED_OBJECT_TYPE_PRESENT
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f ObjectFlags) Match(c ObjectFlags) bool {
+	return f&c == c
+}
@@ -0,0 +1,119 @@
+package smbsecurity
+
+import "strconv"
+
+// ACEType is the type of an access control entry.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.4.1
+type ACEType uint8
+
+// ACE types.
+const (
+	AccessAllowed               = 0x00 // ACCESS_ALLOWED_ACE_TYPE
+	AccessDenied                = 0x01 // ACCESS_DENIED_ACE_TYPE
+	SystemAudit                 = 0x02 // SYSTEM_AUDIT_ACE_TYPE
+	SystemAlarm                 = 0x03 // SYSTEM_ALARM_ACE_TYPE
+	AccessAllowedCompound       = 0x04 // ACCESS_ALLOWED_COMPOUND_ACE_TYPE
+	AccessAllowedObject         = 0x05 // ACCESS_ALLOWED_OBJECT_ACE_TYPE
+	AccessDeniedObject          = 0x06 // ACCESS_DENIED_OBJECT_ACE_TYPE
+	SystemAuditObject           = 0x07 // SYSTEM_AUDIT_OBJECT_ACE_TYPE
+	SystemAlarmObject           = 0x08 // SYSTEM_ALARM_OBJECT_ACE_TYPE
+	AccessAllowedCallback       = 0x09 // ACCESS_ALLOWED_CALLBACK_ACE_TYPE
+	AccessDeniedCallback        = 0x0A // ACCESS_DENIED_CALLBACK_ACE_TYPE
+	AccessAllowedCallbackObject = 0x0B // ACCESS_ALLOWED_CALLBACK_OBJECT_ACE_TYPE
+	AccessDeniedCallbackObject  = 0x0C // ACCESS_DENIED_CALLBACK_OBJECT_ACE_TYPE
+	SystemAuditCallback         = 0x0D // SYSTEM_AUDIT_CALLBACK_ACE_TYPE
+	SystemAlarmCallback         = 0x0E // SYSTEM_ALARM_CALLBACK_ACE_TYPE
+	SystemAuditCallbackObject   = 0x0F // SYSTEM_AUDIT_CALLBACK_OBJECT_ACE_TYPE
+	SystemAlarmCallbackObject   = 0x10 // SYSTEM_ALARM_CALLBACK_OBJECT_ACE_TYPE
+	SystemMandatoryLabel        = 0x11 // SYSTEM_MANDATORY_LABEL_ACE_TYPE
+	SystemResourceAttribute     = 0x12 // SYSTEM_RESOURCE_ATTRIBUTE_ACE_TYPE
+	SystemScopedPolicyID        = 0x13 // SYSTEM_SCOPED_POLICY_ID_ACE_TYPE
+)
+
+// Object returns true if ACEs of type t carry object types.
+func (t ACEType) Object() bool {
+	switch t {
+	case AccessAllowedObject, AccessDeniedObject, SystemAuditObject, SystemAlarmObject,
+		AccessAllowedCallbackObject, AccessDeniedCallbackObject, SystemAuditCallbackObject, SystemAlarmCallbackObject:
+		return true
+	}
+	return false
+}
+
+// Allow returns true if ACEs of type t grant access.
+func (t ACEType) Allow() bool {
+	switch t {
+	case AccessAllowed, AccessAllowedObject, AccessAllowedCallback, AccessAllowedCallbackObject:
+		return true
+	}
+	return false
+}
+
+// Deny returns true if ACEs of type t deny access.
+func (t ACEType) Deny() bool {
+	switch t {
+	case AccessDenied, AccessDeniedObject, AccessDeniedCallback, AccessDeniedCallbackObject:
+		return true
+	}
+	return false
+}
+
+// Callback returns true if ACEs of type t carry a condition that must be
+// evaluated by a callback.
+func (t ACEType) Callback() bool {
+	switch t {
+	case AccessAllowedCallback, AccessDeniedCallback, AccessAllowedCallbackObject, AccessDeniedCallbackObject,
+		SystemAuditCallback, SystemAlarmCallback, SystemAuditCallbackObject, SystemAlarmCallbackObject:
+		return true
+	}
+	return false
+}
+
+// String returns a string representation of the ACE type.
+func (t ACEType) String() string {
+	switch t {
+	case AccessAllowed:
+		return "AccessAllowed"
+	case AccessDenied:
+		return "AccessDenied"
+	case SystemAudit:
+		return "SystemAudit"
+	case SystemAlarm:
+		return "SystemAlarm"
+	case AccessAllowedCompound:
+		return "AccessAllowedCompound"
+	case AccessAllowedObject:
+		return "AccessAllowedObject"
+	case AccessDeniedObject:
+		return "AccessDeniedObject"
+	case SystemAuditObject:
+		return "SystemAuditObject"
+	case SystemAlarmObject:
+		return "SystemAlarmObject"
+	case AccessAllowedCallback:
+		return "AccessAllowedCallback"
+	case AccessDeniedCallback:
+		return "AccessDeniedCallback"
+	case AccessAllowedCallbackObject:
+		return "AccessAllowedCallbackObject"
+	case AccessDeniedCallbackObject:
+		return "AccessDeniedCallbackObject"
+	case SystemAuditCallback:
+		return "SystemAuditCallback"
+	case SystemAlarmCallback:
+		return "SystemAlarmCallback"
+	case SystemAuditCallbackObject:
+		return "SystemAuditCallbackObject"
+	case SystemAlarmCallbackObject:
+		return "SystemAlarmCallbackObject"
+	case SystemMandatoryLabel:
+		return "SystemMandatoryLabel"
+	case SystemResourceAttribute:
+		return "SystemResourceAttribute"
+	case SystemScopedPolicyID:
+		return "SystemScopedPolicyID"
+	default:
+		return "ACEType " + strconv.Itoa(int(t))
+	}
+}
@@ -0,0 +1,96 @@
+package smbsecurity
+
+import (
+	"errors"
+
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ErrInvalidACL is returned when an access control list is malformed.
+var ErrInvalidACL = errors.New("smbsecurity: invalid access control list")
+
+// aclHeaderSize is the number of bytes in the header of an ACL.
+const aclHeaderSize = 8
+
+// ACL revisions.
+const (
+	ACLRevision   = 2 // ACL_REVISION
+	ACLRevisionDS = 4 // ACL_REVISION_DS, required for object ACEs
+)
+
+// ACL is an access control list. The discretionary ACL of a security
+// descriptor determines who can access an object, and the system ACL
+// determines which accesses are audited.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.5
+type ACL struct {
+	Revision uint8 // Computed from the ACEs if zero
+	ACEs     []ACE
+}
+
+// Len returns the number of bytes in the serialized ACL.
+func (acl *ACL) Len() int {
+	n := aclHeaderSize
+	for i := range acl.ACEs {
+		n += acl.ACEs[i].Len()
+	}
+	return n
+}
+
+// revision returns the revision of the ACL, which is ACLRevisionDS if it
+// holds object ACEs and ACLRevision otherwise, unless a revision has been
+// specified.
+func (acl *ACL) revision() uint8 {
+	if acl.Revision != 0 {
+		return acl.Revision
+	}
+	for i := range acl.ACEs {
+		if acl.ACEs[i].Type.Object() {
+			return ACLRevisionDS
+		}
+	}
+	return ACLRevision
+}
+
+// write writes the ACL to b, which must be at least acl.Len() bytes long.
+func (acl *ACL) write(b []byte) {
+	b[0] = acl.revision()
+	b[1] = 0
+	smbtype.PutUint16(b[2:4], uint16(acl.Len()))
+	smbtype.PutUint16(b[4:6], uint16(len(acl.ACEs)))
+	smbtype.PutUint16(b[6:8], 0)
+	off := aclHeaderSize
+	for i := range acl.ACEs {
+		acl.ACEs[i].write(b[off:])
+		off += acl.ACEs[i].Len()
+	}
+}
+
+// readACL decodes the ACL at the start of b.
+func readACL(b []byte) (*ACL, error) {
+	if len(b) < aclHeaderSize {
+		return nil, ErrInvalidACL
+	}
+	revision := b[0]
+	size := int(smbtype.Uint16(b[2:4]))
+	count := int(smbtype.Uint16(b[4:6]))
+	if revision < ACLRevision || revision > ACLRevisionDS || size < aclHeaderSize || size > len(b) {
+		return nil, ErrInvalidACL
+	}
+
+	if count > (size-aclHeaderSize)/(aceHeaderSize+4) {
+		return nil, ErrInvalidACL
+	}
+
+	acl := &ACL{Revision: revision, ACEs: make([]ACE, 0, count)}
+	off := aclHeaderSize
+	for i := 0; i < count; i++ {
+		ace, n, err := readACE(b[off:size])
+		if err != nil {
+			return nil, err
+		}
+		acl.ACEs = append(acl.ACEs, ace)
+		off += n
+	}
+	return acl, nil
+}
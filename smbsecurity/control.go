@@ -0,0 +1,37 @@
+package smbsecurity
+
+// Control holds flags that qualify the contents of a security descriptor.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.6
+type Control uint16
+
+// Security descriptor control flags.
+const (
+	OwnerDefaulted          = 0x0001 // SE_OWNER_DEFAULTED
+	GroupDefaulted          = 0x0002 // SE_GROUP_DEFAULTED
+	DACLPresent             = 0x0004 // SE_DACL_PRESENT
+	DACLDefaulted           = 0x0008 // SE_DACL_DEFAULTED
+	SACLPresent             = 0x0010 // SE_SACL_PRESENT
+	SACLDefaulted           = 0x0020 // SE_SACL_DEFAULTED
+	DACLTrusted             = 0x0040 // SE_DACL_TRUSTED
+	ServerSecurity          = 0x0080 // SE_SERVER_SECURITY
+	DACLAutoInheritRequired = 0x0100 // SE_DACL_AUTO_INHERIT_REQ
+	SACLAutoInheritRequired = 0x0200 // SE_SACL_AUTO_INHERIT_REQ
+	DACLAutoInherited       = 0x0400 // SE_DACL_AUTO_INHERITED
+	SACLAutoInherited       = 0x0800 // SE_SACL_AUTO_INHERITED
+	DACLProtected           = 0x1000 // SE_DACL_PROTECTED
+	SACLProtected           = 0x2000 // SE_SACL_PROTECTED
+	RMControlValid          = 0x4000 // SE_RM_CONTROL_VALID
+	SelfRelative            = 0x8000 // SE_SELF_RELATIVE
+)
+
+// Flags that describe the discretionary and system ACLs.
+const (
+	DACLControl = DACLPresent | DACLDefaulted | DACLTrusted | DACLAutoInheritRequired | DACLAutoInherited | DACLProtected
+	SACLControl = SACLPresent | SACLDefaulted | SACLAutoInheritRequired | SACLAutoInherited | SACLProtected
+)
+
+// Match reports whether c contains all of the flags specified by f.
+func (c Control) Match(f Control) bool {
+	return c&f == f
+}
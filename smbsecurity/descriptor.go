@@ -0,0 +1,237 @@
+package smbsecurity
+
+import (
+	"errors"
+
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ErrInvalidDescriptor is returned when a security descriptor is
+// malformed.
+var ErrInvalidDescriptor = errors.New("smbsecurity: invalid security descriptor")
+
+// DescriptorHeaderSize is the number of bytes in the fixed portion of a
+// self-relative security descriptor.
+const DescriptorHeaderSize = 20
+
+// Descriptor is a security descriptor, which holds the owner, group and
+// access control lists of an object.
+//
+// A nil ACL with its present flag set in Control is a null ACL, which for
+// a DACL grants everyone full access. A non-nil ACL is always present.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.6
+type Descriptor struct {
+	Control Control
+	Owner   SID // Nil if the descriptor has no owner
+	Group   SID // Nil if the descriptor has no group
+	SACL    *ACL
+	DACL    *ACL
+}
+
+// control returns the control flags of the descriptor in its
+// self-relative form.
+func (sd *Descriptor) control() Control {
+	c := sd.Control | SelfRelative
+	if sd.DACL != nil {
+		c |= DACLPresent
+	}
+	if sd.SACL != nil {
+		c |= SACLPresent
+	}
+	return c
+}
+
+// Len returns the number of bytes in the self-relative form of the
+// descriptor.
+func (sd *Descriptor) Len() int {
+	n := DescriptorHeaderSize + sd.Owner.Len() + sd.Group.Len()
+	if sd.SACL != nil {
+		n += sd.SACL.Len()
+	}
+	if sd.DACL != nil {
+		n += sd.DACL.Len()
+	}
+	return n
+}
+
+// Marshal returns the self-relative form of the descriptor. Its parts are
+// laid out in the same order as those of Windows: the SACL, the DACL, the
+// owner and then the group.
+func (sd *Descriptor) Marshal() []byte {
+	b := make([]byte, sd.Len())
+	b[0] = 1 // Revision
+	smbtype.PutUint16(b[2:4], uint16(sd.control()))
+
+	off := DescriptorHeaderSize
+	if sd.SACL != nil {
+		smbtype.PutUint32(b[12:16], uint32(off))
+		sd.SACL.write(b[off:])
+		off += sd.SACL.Len()
+	}
+	if sd.DACL != nil {
+		smbtype.PutUint32(b[16:20], uint32(off))
+		sd.DACL.write(b[off:])
+		off += sd.DACL.Len()
+	}
+	if n := sd.Owner.Len(); n > 0 {
+		smbtype.PutUint32(b[4:8], uint32(off))
+		off += copy(b[off:], sd.Owner[:n])
+	}
+	if n := sd.Group.Len(); n > 0 {
+		smbtype.PutUint32(b[8:12], uint32(off))
+		copy(b[off:], sd.Group[:n])
+	}
+	return b
+}
+
+// Unmarshal decodes the self-relative security descriptor in b into sd.
+func (sd *Descriptor) Unmarshal(b []byte) error {
+	if len(b) < DescriptorHeaderSize || b[0] != 1 {
+		return ErrInvalidDescriptor
+	}
+	control := Control(smbtype.Uint16(b[2:4]))
+	if !control.Match(SelfRelative) {
+		return ErrInvalidDescriptor
+	}
+
+	// part returns the bytes at the offset stored in b[field:], or nil if
+	// the offset is zero
+	valid := true
+	part := func(field int) []byte {
+		off := smbtype.Uint32(b[field : field+4])
+		if off == 0 {
+			return nil
+		}
+		if off < DescriptorHeaderSize || uint64(off) >= uint64(len(b)) {
+			valid = false
+			return nil
+		}
+		return b[off:]
+	}
+
+	var result Descriptor
+	result.Control = control
+	if owner := SID(part(4)); owner != nil {
+		if !owner.Valid() {
+			return ErrInvalidDescriptor
+		}
+		result.Owner = append(SID(nil), owner[:owner.Len()]...)
+	}
+	if group := SID(part(8)); group != nil {
+		if !group.Valid() {
+			return ErrInvalidDescriptor
+		}
+		result.Group = append(SID(nil), group[:group.Len()]...)
+	}
+	var err error
+	if sacl := part(12); sacl != nil && control.Match(SACLPresent) {
+		if result.SACL, err = readACL(sacl); err != nil {
+			return err
+		}
+	}
+	if dacl := part(16); dacl != nil && control.Match(DACLPresent) {
+		if result.DACL, err = readACL(dacl); err != nil {
+			return err
+		}
+	}
+	if !valid {
+		return ErrInvalidDescriptor
+	}
+
+	*sd = result
+	return nil
+}
+
+// Filter returns a copy of the descriptor that holds only the parts
+// selected by info. The label selects the mandatory label ACEs of the
+// SACL.
+func (sd *Descriptor) Filter(info Information) *Descriptor {
+	info = info.Selected()
+	result := &Descriptor{Control: sd.Control &^ (DACLControl | SACLControl)}
+	if info.Match(OwnerSecurityInformation) {
+		result.Owner = sd.Owner
+	} else {
+		result.Control &^= OwnerDefaulted
+	}
+	if info.Match(GroupSecurityInformation) {
+		result.Group = sd.Group
+	} else {
+		result.Control &^= GroupDefaulted
+	}
+	if info.Match(DACLSecurityInformation) {
+		result.Control |= sd.Control & DACLControl
+		result.DACL = sd.DACL
+	}
+	switch {
+	case info.Match(SACLSecurityInformation):
+		result.Control |= sd.Control & SACLControl
+		result.SACL = sd.SACL
+	case info.Match(LabelSecurityInformation) && sd.SACL != nil:
+		labels := &ACL{Revision: sd.SACL.Revision}
+		for _, ace := range sd.SACL.ACEs {
+			if ace.Type == SystemMandatoryLabel {
+				labels.ACEs = append(labels.ACEs, ace)
+			}
+		}
+		result.Control |= SACLPresent
+		result.SACL = labels
+	}
+	return result
+}
+
+// Merge returns a copy of the descriptor in which the parts selected by
+// info have been replaced by those of update. The protected and
+// unprotected flags of info set or clear the protection of the ACLs.
+func (sd *Descriptor) Merge(info Information, update *Descriptor) *Descriptor {
+	result := *sd
+	selected := info.Selected()
+	if selected.Match(OwnerSecurityInformation) {
+		result.Owner = update.Owner
+		result.Control = result.Control&^OwnerDefaulted | update.Control&OwnerDefaulted
+	}
+	if selected.Match(GroupSecurityInformation) {
+		result.Group = update.Group
+		result.Control = result.Control&^GroupDefaulted | update.Control&GroupDefaulted
+	}
+	if selected.Match(DACLSecurityInformation) {
+		result.DACL = update.DACL
+		result.Control = result.Control&^DACLControl | update.Control&DACLControl
+		switch {
+		case info.Match(ProtectedDACLSecurityInformation):
+			result.Control |= DACLProtected
+		case info.Match(UnprotectedDACLSecurityInformation):
+			result.Control &^= DACLProtected
+		}
+	}
+	if selected.Match(SACLSecurityInformation) {
+		result.SACL = update.SACL
+		result.Control = result.Control&^SACLControl | update.Control&SACLControl
+		switch {
+		case info.Match(ProtectedSACLSecurityInformation):
+			result.Control |= SACLProtected
+		case info.Match(UnprotectedSACLSecurityInformation):
+			result.Control &^= SACLProtected
+		}
+	} else if selected.Match(LabelSecurityInformation) {
+		// Only the mandatory label ACEs of the SACL are replaced
+		sacl := &ACL{}
+		if sd.SACL != nil {
+			sacl.Revision = sd.SACL.Revision
+			for _, ace := range sd.SACL.ACEs {
+				if ace.Type != SystemMandatoryLabel {
+					sacl.ACEs = append(sacl.ACEs, ace)
+				}
+			}
+		}
+		if update.SACL != nil {
+			for _, ace := range update.SACL.ACEs {
+				if ace.Type == SystemMandatoryLabel {
+					sacl.ACEs = append(sacl.ACEs, ace)
+				}
+			}
+		}
+		result.SACL = sacl
+	}
+	return &result
+}
@@ -0,0 +1,9 @@
+// Package smbsecurity provides Windows security identifiers, security
+// descriptors and access control lists, along with the access check that
+// evaluates a security descriptor against the SIDs of a token.
+//
+// Security descriptors are exchanged by SMB2 QUERY_INFO and SET_INFO
+// requests in their self-relative form.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ sections 2.4 and 2.5.3
+package smbsecurity
@@ -0,0 +1,50 @@
+package smbsecurity
+
+// Information selects the parts of a security descriptor that are queried
+// or set. It is carried by the AdditionalInformation field of SMB2
+// QUERY_INFO and SET_INFO requests.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.7
+type Information uint32
+
+// Security information flags.
+const (
+	OwnerSecurityInformation           = 0x00000001 // OWNER_SECURITY_INFORMATION
+	GroupSecurityInformation           = 0x00000002 // GROUP_SECURITY_INFORMATION
+	DACLSecurityInformation            = 0x00000004 // DACL_SECURITY_INFORMATION
+	SACLSecurityInformation            = 0x00000008 // SACL_SECURITY_INFORMATION
+	LabelSecurityInformation           = 0x00000010 // LABEL_SECURITY_INFORMATION
+	AttributeSecurityInformation       = 0x00000020 // ATTRIBUTE_SECURITY_INFORMATION
+	ScopeSecurityInformation           = 0x00000040 // SCOPE_SECURITY_INFORMATION
+	BackupSecurityInformation          = 0x00010000 // BACKUP_SECURITY_INFORMATION
+	UnprotectedSACLSecurityInformation = 0x10000000 // UNPROTECTED_SACL_SECURITY_INFORMATION
+	UnprotectedDACLSecurityInformation = 0x20000000 // UNPROTECTED_DACL_SECURITY_INFORMATION
+	ProtectedSACLSecurityInformation   = 0x40000000 // PROTECTED_SACL_SECURITY_INFORMATION
+	ProtectedDACLSecurityInformation   = 0x80000000 // PROTECTED_DACL_SECURITY_INFORMATION
+)
+
+// Match reports whether i contains all of the flags specified by f.
+func (i Information) Match(f Information) bool {
+	return i&f == f
+}
+
+// Any reports whether i contains any of the flags specified by f.
+func (i Information) Any(f Information) bool {
+	return i&f != 0
+}
+
+// Selected returns the information flags for the parts of a security
+// descriptor selected by i. Backup security information selects the
+// owner, group and both ACLs.
+func (i Information) Selected() Information {
+	if i.Match(BackupSecurityInformation) {
+		i |= OwnerSecurityInformation | GroupSecurityInformation | DACLSecurityInformation | SACLSecurityInformation
+	}
+	if i.Any(ProtectedDACLSecurityInformation | UnprotectedDACLSecurityInformation) {
+		i |= DACLSecurityInformation
+	}
+	if i.Any(ProtectedSACLSecurityInformation | UnprotectedSACLSecurityInformation) {
+		i |= SACLSecurityInformation
+	}
+	return i & (OwnerSecurityInformation | GroupSecurityInformation | DACLSecurityInformation | SACLSecurityInformation | LabelSecurityInformation)
+}
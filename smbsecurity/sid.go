@@ -0,0 +1,195 @@
+package smbsecurity
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ErrInvalidSID is returned when a string isn't a valid SID.
+var ErrInvalidSID = errors.New("smbsecurity: invalid security identifier")
+
+// MaxSubAuthorities is the largest number of sub-authorities in a SID.
+const MaxSubAuthorities = 15
+
+// SID interprets a slice of bytes as a security identifier in its binary
+// form.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.2.2
+type SID []byte
+
+// SIDSize returns the number of bytes in a SID with n sub-authorities.
+func SIDSize(n int) int {
+	return 8 + 4*n
+}
+
+// NewSID returns a SID with the given identifier authority and
+// sub-authorities. It panics if there are more than 15 sub-authorities.
+func NewSID(authority uint64, subAuthorities ...uint32) SID {
+	if len(subAuthorities) > MaxSubAuthorities {
+		panic("smbsecurity: too many sub-authorities")
+	}
+	sid := make(SID, SIDSize(len(subAuthorities)))
+	sid[0] = 1
+	sid[1] = uint8(len(subAuthorities))
+	for i := 0; i < 6; i++ {
+		sid[7-i] = uint8(authority >> (8 * uint(i)))
+	}
+	for i, sub := range subAuthorities {
+		smbtype.PutUint32(sid[8+4*i:], sub)
+	}
+	return sid
+}
+
+// ParseSID parses the string form of a SID, such as S-1-5-32-544. The
+// identifier authority may be given in decimal or, if it begins with 0x,
+// in hexadecimal.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.2.1
+func ParseSID(s string) (SID, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 || len(parts) > 3+MaxSubAuthorities || !strings.EqualFold(parts[0], "S") || parts[1] != "1" {
+		return nil, ErrInvalidSID
+	}
+
+	var (
+		authority uint64
+		err       error
+	)
+	if a := parts[2]; len(a) > 2 && (a[:2] == "0x" || a[:2] == "0X") {
+		authority, err = strconv.ParseUint(a[2:], 16, 48)
+	} else {
+		authority, err = strconv.ParseUint(a, 10, 48)
+	}
+	if err != nil {
+		return nil, ErrInvalidSID
+	}
+
+	subs := make([]uint32, len(parts)-3)
+	for i, part := range parts[3:] {
+		sub, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, ErrInvalidSID
+		}
+		subs[i] = uint32(sub)
+	}
+	return NewSID(authority, subs...), nil
+}
+
+// Valid returns true if the SID is valid.
+func (sid SID) Valid() bool {
+	if len(sid) < 8 {
+		return false
+	}
+	if sid.Revision() != 1 {
+		return false
+	}
+	n := sid.SubAuthorityCount()
+	return n <= MaxSubAuthorities && len(sid) >= SIDSize(n)
+}
+
+// Len returns the number of bytes in the SID, which may be less than the
+// length of the slice. It returns zero for an empty SID.
+func (sid SID) Len() int {
+	if len(sid) < 2 {
+		return 0
+	}
+	return SIDSize(sid.SubAuthorityCount())
+}
+
+// Revision returns the revision of the SID, which is always 1.
+func (sid SID) Revision() uint8 {
+	return sid[0]
+}
+
+// SubAuthorityCount returns the number of sub-authorities in the SID.
+func (sid SID) SubAuthorityCount() int {
+	return int(sid[1])
+}
+
+// Authority returns the 48-bit identifier authority of the SID.
+func (sid SID) Authority() uint64 {
+	var authority uint64
+	for _, b := range sid[2:8] {
+		authority = authority<<8 | uint64(b)
+	}
+	return authority
+}
+
+// SubAuthority returns the sub-authority of the SID with index i.
+func (sid SID) SubAuthority(i int) uint32 {
+	return smbtype.Uint32(sid[8+4*i:])
+}
+
+// RID returns the last sub-authority of the SID, which is its relative
+// identifier. It returns zero if the SID has no sub-authorities.
+func (sid SID) RID() uint32 {
+	n := sid.SubAuthorityCount()
+	if n == 0 {
+		return 0
+	}
+	return sid.SubAuthority(n - 1)
+}
+
+// Equal returns true if sid and other are valid and identify the same
+// principal.
+func (sid SID) Equal(other SID) bool {
+	if !sid.Valid() || !other.Valid() {
+		return false
+	}
+	n := sid.Len()
+	return n == other.Len() && bytes.Equal(sid[:n], other[:n])
+}
+
+// String returns the string form of the SID, such as S-1-5-32-544.
+func (sid SID) String() string {
+	if !sid.Valid() {
+		return "<invalid SID>"
+	}
+	var b strings.Builder
+	b.WriteString("S-1-")
+	if authority := sid.Authority(); authority >= 1<<32 {
+		b.WriteString("0x")
+		b.WriteString(strings.ToUpper(strconv.FormatUint(authority, 16)))
+	} else {
+		b.WriteString(strconv.FormatUint(authority, 10))
+	}
+	for i := 0; i < sid.SubAuthorityCount(); i++ {
+		b.WriteByte('-')
+		b.WriteString(strconv.FormatUint(uint64(sid.SubAuthority(i)), 10))
+	}
+	return b.String()
+}
+
+// Well-known SIDs.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.4.2.4
+var (
+	Null               = NewSID(0, 0)       // S-1-0-0
+	Everyone           = NewSID(1, 0)       // S-1-1-0
+	CreatorOwner       = NewSID(3, 0)       // S-1-3-0
+	CreatorGroup       = NewSID(3, 1)       // S-1-3-1
+	OwnerRights        = NewSID(3, 4)       // S-1-3-4
+	Network            = NewSID(5, 2)       // S-1-5-2
+	Anonymous          = NewSID(5, 7)       // S-1-5-7
+	AuthenticatedUsers = NewSID(5, 11)      // S-1-5-11
+	LocalSystem        = NewSID(5, 18)      // S-1-5-18
+	Administrators     = NewSID(5, 32, 544) // S-1-5-32-544
+	Users              = NewSID(5, 32, 545) // S-1-5-32-545
+	Guests             = NewSID(5, 32, 546) // S-1-5-32-546
+)
+
+// UnixUser returns the SID that represents the POSIX user with the given
+// user ID, which is S-1-22-1-uid.
+func UnixUser(uid uint32) SID {
+	return NewSID(22, 1, uid)
+}
+
+// UnixGroup returns the SID that represents the POSIX group with the given
+// group ID, which is S-1-22-2-gid.
+func UnixGroup(gid uint32) SID {
+	return NewSID(22, 2, gid)
+}
@@ -0,0 +1,53 @@
+package smbsecurity
+
+// Privileges is a set of privileges held by a token that bypass or extend
+// the access granted by security descriptors.
+type Privileges uint32
+
+// Privileges.
+const (
+	// SecurityPrivilege allows ACCESS_SYSTEM_SECURITY to be granted, which
+	// is needed to read or write system ACLs.
+	SecurityPrivilege = 0x00000001 // SeSecurityPrivilege
+
+	// TakeOwnershipPrivilege allows WRITE_OWNER to be granted regardless
+	// of the DACL.
+	TakeOwnershipPrivilege = 0x00000002 // SeTakeOwnershipPrivilege
+
+	// BackupPrivilege allows read access to be granted regardless of the
+	// DACL.
+	BackupPrivilege = 0x00000004 // SeBackupPrivilege
+
+	// RestorePrivilege allows write access to be granted regardless of the
+	// DACL.
+	RestorePrivilege = 0x00000008 // SeRestorePrivilege
+)
+
+// Match reports whether p contains all of the privileges specified by c.
+func (p Privileges) Match(c Privileges) bool {
+	return p&c == c
+}
+
+// Token describes the security context of a principal, which is evaluated
+// against security descriptors by access checks.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.5.2
+type Token struct {
+	User       SID
+	Groups     []SID // Including well-known groups such as Everyone
+	Privileges Privileges
+}
+
+// Contains returns true if sid is the user of the token or one of its
+// groups.
+func (t *Token) Contains(sid SID) bool {
+	if t.User.Equal(sid) {
+		return true
+	}
+	for _, group := range t.Groups {
+		if group.Equal(sid) {
+			return true
+		}
+	}
+	return false
+}
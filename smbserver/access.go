@@ -0,0 +1,141 @@
+package smbserver
+
+import (
+	"path"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// anonymousToken is the security token of sessions whose users haven't
+// been identified, and of requests made outside of a session. It holds the
+// Everyone group, so files that grant access to everyone remain accessible
+// without authentication.
+var anonymousToken = &smbsecurity.Token{
+	User:   smbsecurity.Anonymous,
+	Groups: []smbsecurity.SID{smbsecurity.Everyone, smbsecurity.Network},
+}
+
+// token returns the security token of the user of the given session.
+func (c *Conn) token(sessionID uint64) *smbsecurity.Token {
+	if c.Sessions != nil {
+		if s := c.Sessions.Lookup(sessionID); s != nil {
+			return s.Token()
+		}
+	}
+	return anonymousToken
+}
+
+// shareAccess returns the maximal access that the permissions of share
+// grant to token. Shares without permissions grant full access to
+// everyone.
+func shareAccess(share *Share, token *smbsecurity.Token) smbaccess.Mask {
+	if share.Security == nil {
+		return smbaccess.FileAllAccess
+	}
+	granted, _ := smbsecurity.AccessCheck(share.Security, token, smbaccess.MaximumAllowed)
+	return granted &^ smbaccess.SystemSecurity
+}
+
+// fileSecurity returns the security descriptor of the named file within
+// fsys.
+func fileSecurity(fsys smbfs.FileSystem, name string) (*smbsecurity.Descriptor, error) {
+	if fsys, ok := fsys.(smbfs.SecurityDescriptors); ok {
+		return fsys.Security(name)
+	}
+	return defaultSecurity(), nil
+}
+
+// fileAccess returns the maximal access that the security descriptor of
+// the named file within fsys grants to token.
+func fileAccess(fsys smbfs.FileSystem, name string, token *smbsecurity.Token) (smbaccess.Mask, error) {
+	sd, err := fileSecurity(fsys, name)
+	if err != nil {
+		return 0, err
+	}
+	granted, _ := smbsecurity.AccessCheck(sd, token, smbaccess.MaximumAllowed)
+	return granted, nil
+}
+
+// grantAccess determines the access granted to the open being created by
+// p, and the maximal access that could have been granted to it.
+//
+// The maximal access is limited by the share permissions of the tree and
+// by the security descriptor of the file. DELETE is also granted if the
+// parent directory grants FILE_DELETE_CHILD. Files that are created are
+// owned by their creator, which has full access to them, but their parent
+// directory must grant FILE_ADD_FILE or FILE_ADD_SUBDIRECTORY.
+//
+// Creates that ask for MAXIMUM_ALLOWED are granted the maximal access.
+// Other creates are granted the access they ask for, and fail with
+// STATUS_ACCESS_DENIED if any of it isn't available. Creates that
+// overwrite a file also need FILE_WRITE_DATA.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.9
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/ section 2.5.3.2
+func (c *Conn) grantAccess(p *createParams, exists, directory, truncate bool) (granted, maximal smbaccess.Mask, code smbstatus.Code) {
+	token := c.token(p.sessionID)
+
+	limit := smbaccess.Mask(smbaccess.FileAllAccess)
+	if c.Trees != nil {
+		if tree := c.Trees.Lookup(p.sessionID, p.treeID); tree != nil {
+			limit = tree.MaximalAccess
+		}
+	}
+
+	var parent smbaccess.Mask
+	if p.name != "." {
+		var err error
+		if parent, err = fileAccess(p.fsys, path.Dir(p.name), token); err != nil {
+			return 0, 0, fileStatus(err)
+		}
+	}
+
+	if exists {
+		var err error
+		if maximal, err = fileAccess(p.fsys, p.name, token); err != nil {
+			return 0, 0, fileStatus(err)
+		}
+		if parent.Match(smbaccess.DeleteChild) {
+			maximal |= smbaccess.Delete
+		}
+	} else {
+		required := smbaccess.Mask(smbaccess.AddFile)
+		if directory {
+			required = smbaccess.AddSubdir
+		}
+		if !parent.Match(required) || !limit.Match(required) {
+			return 0, 0, smbstatus.AccessDenied
+		}
+		maximal = smbaccess.FileAllAccess | parent&smbaccess.SystemSecurity
+	}
+	maximal &= limit | smbaccess.SystemSecurity
+
+	desired := p.access &^ smbaccess.MaximumAllowed
+	required := desired
+	if truncate {
+		required |= smbaccess.WriteData
+	}
+	if !maximal.Match(required) {
+		return 0, maximal, smbstatus.AccessDenied
+	}
+	if p.access.Match(smbaccess.MaximumAllowed) {
+		return maximal, maximal, smbstatus.Success
+	}
+	return desired, maximal, smbstatus.Success
+}
+
+// appendMaximalAccess appends a query maximal access response context that
+// reports the given maximal access to contexts.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.14.2.5
+func appendMaximalAccess(contexts []byte, maximal smbaccess.Mask) []byte {
+	data := make([]byte, smbcreate.MaximalAccessResponseSize)
+	response := smbcreate.MaximalAccessResponse(data)
+	response.SetQueryStatus(smbstatus.Success)
+	response.SetMaximalAccess(maximal)
+	return smbcreate.AppendContext(contexts, smbcreate.QueryMaximalAccess, data)
+}
@@ -0,0 +1,183 @@
+package smbserver_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+	"github.com/gentlemanautomaton/smb/smbread"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtree"
+	"github.com/gentlemanautomaton/smb/smbwrite"
+)
+
+// everyone returns a security descriptor that grants access to everyone.
+func everyone(access smbaccess.Mask) *smbsecurity.Descriptor {
+	return &smbsecurity.Descriptor{
+		Owner: testOwner,
+		Group: smbsecurity.Users,
+		DACL: &smbsecurity.ACL{ACEs: []smbsecurity.ACE{
+			{Type: smbsecurity.AccessAllowed, Mask: access, SID: smbsecurity.Everyone},
+		}},
+	}
+}
+
+// readFile reads from an open within the current tree.
+func (tt *treeTest) readFile(desc string, id smbfile.ID, status smbstatus.Code) {
+	tt.t.Helper()
+	body := make([]byte, smbread.RequestSize+1)
+	request := smbread.Request(body)
+	request.SetSize(49)
+	request.SetLength(4)
+	request.SetFileID(id)
+	tt.check(desc, tt.send(smbcommand.Read, body), status)
+}
+
+// writeFile writes to an open within the current tree.
+func (tt *treeTest) writeFile(desc string, id smbfile.ID, status smbstatus.Code) {
+	tt.t.Helper()
+	data := []byte("data")
+	body := make([]byte, smbwrite.RequestSize+len(data))
+	request := smbwrite.Request(body)
+	request.SetSize(49)
+	request.SetFileID(id)
+	copy(request.SetDataLayout(len(data)), data)
+	tt.check(desc, tt.send(smbcommand.Write, body), status)
+}
+
+func TestAccessCheck(t *testing.T) {
+	tt := newTreeTest(t)
+	dir := t.TempDir()
+	fsys := newSecurityFS(dir)
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Data", Type: smbtree.Disk, FS: fsys})
+	tt.connect(`\\server\Data`, smbstatus.Success)
+
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fsys.descriptors["a.txt"] = everyone(smbaccess.FileGenericRead)
+	fsys.descriptors["dir"] = everyone(smbaccess.FileGenericRead)
+
+	// Access that the descriptor doesn't grant is denied
+	spec := createSpec{Name: "a.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open}
+	tt.check("read/write open", tt.send(smbcommand.Create, createBody(spec)), smbstatus.AccessDenied)
+	spec.Access = smbaccess.ReadData
+	spec.Disposition = smbcreate.Overwrite
+	tt.check("overwrite", tt.send(smbcommand.Create, createBody(spec)), smbstatus.AccessDenied)
+
+	// Opens that ask for the maximum allowed access are granted what the
+	// descriptor allows, which is reported in the maximal access context
+	spec.Access = smbaccess.MaximumAllowed
+	spec.Disposition = smbcreate.Open
+	spec.Contexts = smbcreate.AppendContext(nil, smbcreate.QueryMaximalAccess, nil)
+	packet := tt.send(smbcommand.Create, createBody(spec))
+	tt.check("maximum allowed open", packet, smbstatus.Success)
+	response := smbcreate.Response(packet.Data())
+	context, ok := smbcreate.ContextList(response.CreateContexts()).Find(smbcreate.QueryMaximalAccess)
+	if !ok {
+		t.Fatal("maximum allowed open: no maximal access context was returned")
+	}
+	maximal := smbcreate.MaximalAccessResponse(context.Data())
+	if !maximal.Valid() || maximal.QueryStatus() != smbstatus.Success {
+		t.Fatal("maximum allowed open: invalid maximal access context")
+	}
+	// The parent directory grants FILE_DELETE_CHILD, which grants DELETE
+	if access := maximal.MaximalAccess(); access != smbaccess.FileGenericRead|smbaccess.Delete {
+		t.Fatalf("maximum allowed open: maximal access is %s", access)
+	}
+	id := response.FileID()
+	tt.readFile("read", id, smbstatus.Success)
+	tt.writeFile("write", id, smbstatus.AccessDenied)
+
+	// Creates need FILE_ADD_FILE on the parent directory
+	spec = createSpec{Name: `dir\b.txt`, Access: readWrite, Share: shareAll, Disposition: smbcreate.Create}
+	tt.check("create in a read-only directory", tt.send(smbcommand.Create, createBody(spec)), smbstatus.AccessDenied)
+	spec.Name = "b.txt"
+	tt.check("create in the root", tt.send(smbcommand.Create, createBody(spec)), smbstatus.Success)
+}
+
+func TestShareAccess(t *testing.T) {
+	tt := newTreeTest(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Data", Type: smbtree.Disk, FS: smbosfs.New(dir), Security: everyone(smbaccess.FileGenericRead)})
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Private", Type: smbtree.Disk, FS: smbosfs.New(dir), Security: &smbsecurity.Descriptor{DACL: &smbsecurity.ACL{}}})
+
+	tt.connect(`\\server\Private`, smbstatus.AccessDenied)
+
+	// The share permissions limit the access granted within the share
+	response := tt.connect(`\\server\Data`, smbstatus.Success)
+	if access := response.MaximalAccess(); access != smbaccess.FileGenericRead {
+		t.Fatalf("connect: maximal access is %s", access)
+	}
+	spec := createSpec{Name: "a.txt", Access: smbaccess.WriteData, Share: shareAll, Disposition: smbcreate.Open}
+	tt.check("write open", tt.send(smbcommand.Create, createBody(spec)), smbstatus.AccessDenied)
+	spec = createSpec{Name: "b.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Create}
+	tt.check("create", tt.send(smbcommand.Create, createBody(spec)), smbstatus.AccessDenied)
+	tt.open("a.txt", smbaccess.ReadData)
+}
+
+// impersonatingFS is a file system that hands out a new view for each
+// create, and counts the files opened through its views.
+type impersonatingFS struct {
+	smbfs.FileSystem
+	mutex  sync.Mutex
+	users  []smbsecurity.SID
+	opened int
+}
+
+func (fsys *impersonatingFS) Impersonate(token *smbsecurity.Token) (smbfs.FileSystem, error) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	fsys.users = append(fsys.users, token.User)
+	return &userView{FileSystem: fsys.FileSystem, base: fsys}, nil
+}
+
+// userView is a view of an impersonatingFS.
+type userView struct {
+	smbfs.FileSystem
+	base *impersonatingFS
+}
+
+func (v *userView) Base() smbfs.FileSystem {
+	return v.base
+}
+
+func (v *userView) OpenFile(name string, flag int, perm os.FileMode) (smbfs.File, error) {
+	v.base.mutex.Lock()
+	v.base.opened++
+	v.base.mutex.Unlock()
+	return v.FileSystem.OpenFile(name, flag, perm)
+}
+
+func TestImpersonation(t *testing.T) {
+	tt := newTreeTest(t)
+	fsys := &impersonatingFS{FileSystem: smbosfs.New(t.TempDir())}
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Data", Type: smbtree.Disk, FS: fsys})
+	tt.connect(`\\server\Data`, smbstatus.Success)
+
+	spec := createSpec{Name: "a.txt", Access: readWrite, Disposition: smbcreate.Create}
+	tt.check("create", tt.send(smbcommand.Create, createBody(spec)), smbstatus.Success)
+	if len(fsys.users) != 1 || !fsys.users[0].Equal(smbsecurity.Anonymous) || fsys.opened != 1 {
+		t.Fatalf("create: %d impersonations of %v, %d files opened through views", len(fsys.users), fsys.users, fsys.opened)
+	}
+
+	// Opens made through different views of the file system are opens of
+	// the same file
+	spec.Disposition = smbcreate.Open
+	tt.check("second open", tt.send(smbcommand.Create, createBody(spec)), smbstatus.SharingViolation)
+}
@@ -0,0 +1,320 @@
+package smbserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbsign"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// ErrAsyncDone is returned when an asynchronous command is completed after
+// it has already been completed or cancelled.
+var ErrAsyncDone = errors.New("smbserver: asynchronous command has already finished")
+
+// AsyncCommandList keeps track of the commands on a connection that are
+// being processed asynchronously. It must be created with
+// NewAsyncCommandList.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/0055d1e1-18fa-4c1c-8941-df7203d440c7
+type AsyncCommandList struct {
+	mutex     sync.Mutex
+	last      uint64                   // The last async ID issued
+	commands  map[uint64]*AsyncCommand // Keyed by async ID
+	byMessage map[uint64]*AsyncCommand // Keyed by message ID
+}
+
+// NewAsyncCommandList returns an empty asynchronous command list that is
+// ready for use.
+func NewAsyncCommandList() *AsyncCommandList {
+	return &AsyncCommandList{
+		commands:  make(map[uint64]*AsyncCommand),
+		byMessage: make(map[uint64]*AsyncCommand),
+	}
+}
+
+// Len returns the number of outstanding asynchronous commands in the list.
+func (l *AsyncCommandList) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.commands)
+}
+
+// Lookup returns the outstanding command with the given async ID, or nil.
+func (l *AsyncCommandList) Lookup(asyncID uint64) *AsyncCommand {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.commands[asyncID]
+}
+
+// LookupMessage returns the outstanding command that was started by the
+// request with the given message ID, or nil.
+func (l *AsyncCommandList) LookupMessage(messageID uint64) *AsyncCommand {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.byMessage[messageID]
+}
+
+// Abort cancels the contexts of all outstanding commands in the list without
+// sending responses for them. It should be called when the connection is
+// closed.
+func (l *AsyncCommandList) Abort() {
+	l.mutex.Lock()
+	commands := make([]*AsyncCommand, 0, len(l.commands))
+	for _, a := range l.commands {
+		commands = append(commands, a)
+	}
+	l.mutex.Unlock()
+
+	for _, a := range commands {
+		a.finish()
+	}
+}
+
+// add creates a new asynchronous command and adds it to the list.
+func (l *AsyncCommandList) add(c *Conn, r *Request) *AsyncCommand {
+	hdr := r.Header()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Async IDs are never zero
+	l.last++
+	a := &AsyncCommand{
+		conn:      c,
+		list:      l,
+		ctx:       ctx,
+		cancel:    cancel,
+		command:   hdr.Command(),
+		asyncID:   l.last,
+		messageID: hdr.MessageID(),
+		sessionID: r.SessionID,
+		signing:   r.signingKey,
+	}
+	l.commands[a.asyncID] = a
+	l.byMessage[a.messageID] = a
+	return a
+}
+
+// remove removes a from the list.
+func (l *AsyncCommandList) remove(a *AsyncCommand) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.commands, a.asyncID)
+	if l.byMessage[a.messageID] == a {
+		delete(l.byMessage, a.messageID)
+	}
+}
+
+// AsyncCommand is a command that is being processed asynchronously. It
+// acts as the interim response that is sent to the client when the command
+// goes async, and it can be completed later from any goroutine.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.4.2
+type AsyncCommand struct {
+	conn      *Conn
+	list      *AsyncCommandList
+	ctx       context.Context
+	cancel    context.CancelFunc
+	command   smbcommand.Code
+	asyncID   uint64
+	messageID uint64
+	sessionID uint64
+	signing   []byte // Key used to sign the final response, if any
+
+	mutex   sync.Mutex
+	done    bool     // True once the command has been completed or cancelled
+	interim bool     // True once the interim response has been sent
+	final   Response // A final response waiting on the interim response
+}
+
+// GoAsync converts the request r into an asynchronous command. The handler
+// processing r should return the command as its response, which causes an
+// interim STATUS_PENDING response to be sent to the client. The command
+// must later be finished by calling Complete.
+//
+// If the client cancels the command its context is cancelled and a
+// STATUS_CANCELLED response is sent on the handler's behalf.
+//
+// The command may be completed from any goroutine, including the handler's
+// own. The final response is never sent before the interim response.
+func (c *Conn) GoAsync(r *Request) *AsyncCommand {
+	if c.AsyncCommands == nil {
+		c.AsyncCommands = NewAsyncCommandList()
+	}
+	return c.AsyncCommands.add(c, r)
+}
+
+// ID returns the async ID of the command.
+func (a *AsyncCommand) ID() uint64 {
+	return a.asyncID
+}
+
+// MessageID returns the message ID of the request that started the command.
+func (a *AsyncCommand) MessageID() uint64 {
+	return a.messageID
+}
+
+// SessionID returns the session ID of the request that started the command.
+func (a *AsyncCommand) SessionID() uint64 {
+	return a.sessionID
+}
+
+// Context returns a context that is cancelled when the client cancels the
+// command, when the command is completed or when the connection is closed.
+func (a *AsyncCommand) Context() context.Context {
+	return a.ctx
+}
+
+// Command returns the type of command of the interim response.
+func (a *AsyncCommand) Command() smbcommand.Code {
+	return a.command
+}
+
+// Status returns the status of the interim response, which is always
+// STATUS_PENDING.
+func (a *AsyncCommand) Status() smbstatus.Code {
+	return smbstatus.Pending
+}
+
+// Size returns the number of bytes required to marshal the interim
+// response. It excludes the packet header.
+func (a *AsyncCommand) Size() int {
+	return a.interimResponse().Size()
+}
+
+// Marshal marshals the interim response to data.
+func (a *AsyncCommand) Marshal(data []byte) {
+	a.interimResponse().Marshal(data)
+}
+
+func (a *AsyncCommand) interimResponse() smbproto.ErrorResponse {
+	return smbproto.ErrorResponse{Cmd: a.command, Code: smbstatus.Pending}
+}
+
+// Complete sends r to the client as the final response for the command and
+// removes the command from the connection's list of asynchronous commands.
+//
+// If the command has already been completed or cancelled it returns
+// ErrAsyncDone.
+func (a *AsyncCommand) Complete(r Response) error {
+	if !a.finish() {
+		return ErrAsyncDone
+	}
+	return a.deliver(r)
+}
+
+// Cancel cancels the command and sends a STATUS_CANCELLED response to the
+// client. It returns false if the command has already been completed or
+// cancelled.
+func (a *AsyncCommand) Cancel() bool {
+	if !a.finish() {
+		return false
+	}
+	a.deliver(smbproto.ErrorResponse{Cmd: a.command, Code: smbstatus.Cancelled})
+	return true
+}
+
+// Done returns true if the command has been completed or cancelled.
+func (a *AsyncCommand) Done() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.done
+}
+
+// finish marks the command as done, cancels its context and removes it from
+// the list. It returns false if the command was already done.
+func (a *AsyncCommand) finish() bool {
+	a.mutex.Lock()
+	if a.done {
+		a.mutex.Unlock()
+		return false
+	}
+	a.done = true
+	a.mutex.Unlock()
+
+	a.cancel()
+	a.list.remove(a)
+	return true
+}
+
+// deliver sends r as the final response if the interim response has been
+// sent. Otherwise it holds on to r until the interim response is sent.
+func (a *AsyncCommand) deliver(r Response) error {
+	a.mutex.Lock()
+	if !a.interim {
+		a.final = r
+		a.mutex.Unlock()
+		return nil
+	}
+	a.mutex.Unlock()
+	return a.send(r)
+}
+
+// sentInterim records that the interim response has been sent and sends the
+// final response if it is already waiting.
+func (a *AsyncCommand) sentInterim() {
+	a.mutex.Lock()
+	a.interim = true
+	r := a.final
+	a.final = nil
+	a.mutex.Unlock()
+	if r != nil {
+		a.send(r)
+	}
+}
+
+// send marshals the final response for the command and sends it. Credits
+// are granted by the interim response, so the final response grants none.
+func (a *AsyncCommand) send(r Response) error {
+	msg := a.conn.Create(smbpacket.HeaderSize + r.Size())
+	defer msg.Close()
+
+	packet := smbpacket.Response(msg.Bytes())
+
+	hdr := packet.Header()
+	writeHeader(hdr, r)
+	flags := smbpacket.Flags(smbpacket.ServerToClient | smbpacket.Async)
+	if a.signing != nil {
+		flags |= smbpacket.Signed
+	}
+	hdr.SetFlags(flags)
+	hdr.SetMessageID(a.messageID)
+	hdr.SetAsyncID(a.asyncID)
+	hdr.SetSessionID(a.sessionID)
+
+	r.Marshal(packet.Data())
+	if a.signing != nil {
+		smbsign.Sign(a.signing, a.conn.Dialect.Revision(), packet)
+	}
+
+	return a.conn.Send(msg)
+}
+
+// cancel handles an SMB2 CANCEL request. The command to be cancelled is
+// identified by its async ID if the request is asynchronous, or by its
+// message ID otherwise.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.16
+func (c *Conn) cancel(hdr smbpacket.RequestHeader) {
+	if c.AsyncCommands == nil {
+		return
+	}
+
+	var a *AsyncCommand
+	if hdr.Flags().Match(smbpacket.Async) {
+		a = c.AsyncCommands.Lookup(hdr.AsyncID())
+	} else {
+		a = c.AsyncCommands.LookupMessage(hdr.MessageID())
+	}
+	if a == nil || a.sessionID != hdr.SessionID() {
+		return
+	}
+
+	a.Cancel()
+}
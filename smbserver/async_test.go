@@ -0,0 +1,142 @@
+package smbserver_test
+
+import (
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// asyncHandler sends each asynchronous command it creates to commands.
+func asyncHandler(commands chan<- *smbserver.AsyncCommand) smbserver.CommandHandler {
+	return smbserver.CommandHandlerFunc(func(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+		a := c.GoAsync(r)
+		commands <- a
+		return a
+	})
+}
+
+func checkAsyncResponse(t *testing.T, b []byte, messageID, asyncID uint64, status smbstatus.Code) {
+	t.Helper()
+	if b == nil {
+		t.Fatal("no response was sent")
+	}
+	hdr := smbpacket.Response(b).Header()
+	if !hdr.Flags().Match(smbpacket.Async) {
+		t.Errorf("response is missing the async flag")
+	}
+	if id := hdr.MessageID(); id != messageID {
+		t.Errorf("response has message ID %d (want %d)", id, messageID)
+	}
+	if id := hdr.AsyncID(); id != asyncID {
+		t.Errorf("response has async ID %d (want %d)", id, asyncID)
+	}
+	if s := hdr.Status(); s != status {
+		t.Errorf("response has status %s (want %s)", s, status)
+	}
+}
+
+func TestAsyncComplete(t *testing.T) {
+	conn, transport := newTestConn(1)
+	commands := make(chan *smbserver.AsyncCommand, 1)
+	msg := makeMessage(testRequest{Command: smbcommand.ChangeNotify, MessageID: 0, SessionID: 9})
+	if err := conn.Process(msg, asyncHandler(commands)); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	a := <-commands
+	if a.ID() == 0 {
+		t.Fatal("async command has a zero async ID")
+	}
+	checkAsyncResponse(t, transport.Next(), 0, a.ID(), smbstatus.Pending)
+
+	done := make(chan error)
+	go func() {
+		done <- a.Complete(testResponse{cmd: smbcommand.ChangeNotify, size: 8})
+	}()
+	if err := <-done; err != nil {
+		t.Fatalf("Complete returned %v", err)
+	}
+	checkAsyncResponse(t, transport.Next(), 0, a.ID(), smbstatus.Success)
+
+	if err := a.Complete(testResponse{cmd: smbcommand.ChangeNotify, size: 8}); err != smbserver.ErrAsyncDone {
+		t.Fatalf("second Complete returned %v (want %v)", err, smbserver.ErrAsyncDone)
+	}
+	if n := conn.AsyncCommands.Len(); n != 0 {
+		t.Fatalf("async command list has %d commands after completion", n)
+	}
+}
+
+func TestAsyncCompleteImmediately(t *testing.T) {
+	conn, transport := newTestConn(1)
+	handler := smbserver.CommandHandlerFunc(func(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+		a := c.GoAsync(r)
+		a.Complete(testResponse{cmd: smbcommand.Lock, size: 4})
+		return a
+	})
+	msg := makeMessage(testRequest{Command: smbcommand.Lock, MessageID: 0})
+	if err := conn.Process(msg, handler); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	interim := smbpacket.Response(transport.Next()).Header()
+	if interim.Status() != smbstatus.Pending {
+		t.Fatalf("first response has status %s (want %s)", interim.Status(), smbstatus.Code(smbstatus.Pending))
+	}
+	checkAsyncResponse(t, transport.Next(), 0, interim.AsyncID(), smbstatus.Success)
+}
+
+func TestAsyncCancel(t *testing.T) {
+	for _, byAsyncID := range []bool{true, false} {
+		conn, transport := newTestConn(1)
+		commands := make(chan *smbserver.AsyncCommand, 1)
+		handler := asyncHandler(commands)
+		msg := makeMessage(testRequest{Command: smbcommand.ChangeNotify, MessageID: 0, SessionID: 9})
+		if err := conn.Process(msg, handler); err != nil {
+			t.Fatalf("Process returned %v", err)
+		}
+		a := <-commands
+		transport.Next()
+
+		// Build a cancel request
+		cancel := makeMessage(testRequest{Command: smbcommand.Cancel, MessageID: 0, SessionID: 9})
+		if byAsyncID {
+			hdr := smbpacket.Request(cancel.Bytes()).Header()
+			hdr.SetFlags(smbpacket.Async)
+			hdr.SetAsyncID(a.ID())
+		}
+		if err := conn.Process(cancel, handler); err != nil {
+			t.Fatalf("Process returned %v for cancel request", err)
+		}
+
+		select {
+		case <-a.Context().Done():
+		default:
+			t.Fatal("command context was not cancelled")
+		}
+		checkAsyncResponse(t, transport.Next(), 0, a.ID(), smbstatus.Cancelled)
+		if err := a.Complete(testResponse{cmd: smbcommand.ChangeNotify, size: 8}); err != smbserver.ErrAsyncDone {
+			t.Fatalf("Complete after Cancel returned %v (want %v)", err, smbserver.ErrAsyncDone)
+		}
+	}
+}
+
+func TestAsyncCancelWrongSession(t *testing.T) {
+	conn, transport := newTestConn(1)
+	commands := make(chan *smbserver.AsyncCommand, 1)
+	handler := asyncHandler(commands)
+	msg := makeMessage(testRequest{Command: smbcommand.ChangeNotify, MessageID: 0, SessionID: 9})
+	if err := conn.Process(msg, handler); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	a := <-commands
+	transport.Next()
+
+	cancel := makeMessage(testRequest{Command: smbcommand.Cancel, MessageID: 0, SessionID: 10})
+	if err := conn.Process(cancel, handler); err != nil {
+		t.Fatalf("Process returned %v for cancel request", err)
+	}
+	if a.Done() {
+		t.Fatal("command was cancelled by a request from another session")
+	}
+}
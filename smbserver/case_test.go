@@ -0,0 +1,94 @@
+package smbserver_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+// listDir returns the sorted names of the entries of a directory.
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCaseInsensitiveShare(t *testing.T) {
+	tt := newTreeTest(t)
+	dir := t.TempDir()
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Exact", Type: smbtree.Disk, FS: smbosfs.New(dir)})
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Data", Type: smbtree.Disk, FS: smbosfs.New(dir), CaseInsensitive: true})
+
+	create := func(desc, name string, disposition smbcreate.Disposition, status smbstatus.Code) {
+		t.Helper()
+		spec := createSpec{Name: name, Access: readWrite, Share: shareAll, Disposition: disposition}
+		tt.check(desc, tt.send(smbcommand.Create, createBody(spec)), status)
+	}
+
+	tt.connect(`\\server\Data`, smbstatus.Success)
+	create("create", "Report.DOCX", smbcreate.Create, smbstatus.Success)
+	create("open with another case", "report.docx", smbcreate.Open, smbstatus.Success)
+	create("create with another case", "REPORT.docx", smbcreate.Create, smbstatus.ObjectNameCollision)
+	create("overwrite with another case", "rEPORT.DOCX", smbcreate.OverwriteIf, smbstatus.Success)
+
+	// The case of new names is preserved, including within directories
+	// that are named with another case
+	if err := os.Mkdir(filepath.Join(dir, "Dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	create("create within a directory", `DIR\New.txt`, smbcreate.Create, smbstatus.Success)
+	create("open within a directory", `dir\NEW.TXT`, smbcreate.Open, smbstatus.Success)
+	if names, want := listDir(t, filepath.Join(dir, "Dir")), []string{"New.txt"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("the directory holds %q (want %q)", names, want)
+	}
+
+	// Files added by others are found once the directory has changed
+	if err := os.WriteFile(filepath.Join(dir, "Other.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(dir, later, later); err != nil {
+		t.Fatal(err)
+	}
+	create("open of a file added by another", "OTHER.TXT", smbcreate.Open, smbstatus.Success)
+
+	// Files deleted on close can be created again with another case
+	spec := createSpec{Name: "temp.txt", Access: readWrite | smbaccess.Delete, Share: shareAll, Disposition: smbcreate.Create, Options: smbcreate.DeleteOnClose}
+	packet := tt.send(smbcommand.Create, createBody(spec))
+	tt.check("create of a temporary file", packet, smbstatus.Success)
+	tt.close(smbcreate.Response(packet.Data()).FileID())
+	create("create of a deleted file with another case", "TEMP.TXT", smbcreate.Create, smbstatus.Success)
+
+	// Creates with a POSIX create context are case-sensitive
+	tt.conn.POSIXExtensions = true
+	spec = createSpec{Name: "report.docx", Access: readWrite, Share: shareAll, Disposition: smbcreate.Create, Contexts: posixContext(0644)}
+	tt.check("POSIX create", tt.send(smbcommand.Create, createBody(spec)), smbstatus.Success)
+	tt.conn.POSIXExtensions = false
+
+	if names, want := listDir(t, dir), []string{"Dir", "Other.txt", "Report.DOCX", "TEMP.TXT", "report.docx"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("the share holds %q (want %q)", names, want)
+	}
+
+	// Names are case-sensitive within other shares
+	tt.connect(`\\server\Exact`, smbstatus.Success)
+	create("open with another case in a case-sensitive share", "REPORT.DOCX", smbcreate.Open, smbstatus.ObjectNameNotFound)
+}
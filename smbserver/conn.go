@@ -1,10 +1,17 @@
 package smbserver
 
 import (
+	"sync"
+
 	"github.com/gentlemanautomaton/smb"
 	"github.com/gentlemanautomaton/smb/smbpacket"
 )
 
+// UnsolicitedMessageID is the message ID of responses that are sent by the
+// server without a corresponding request, such as oplock break
+// notifications.
+const UnsolicitedMessageID = 0xFFFFFFFFFFFFFFFF
+
 // Conn represents the server's view of an SMB connection. It holds
 // connection-specific state.
 //
@@ -24,9 +31,7 @@ func (c *Conn) Marshal(messageID uint64, credits uint16, r Response) error {
 	packet := smbpacket.Response(msg.Bytes())
 
 	hdr := packet.Header()
-	hdr.SetProtocol(smbpacket.SMB2)
-	hdr.SetSize(smbpacket.HeaderSize)
-	hdr.SetCommand(r.Command())
+	writeHeader(hdr, r)
 	hdr.SetCreditResponse(credits)
 	hdr.SetFlags(smbpacket.ServerToClient)
 	hdr.SetMessageID(messageID)
@@ -35,3 +40,82 @@ func (c *Conn) Marshal(messageID uint64, credits uint16, r Response) error {
 
 	return c.Send(msg)
 }
+
+// Notify sends an unsolicited response to the client, such as an oplock
+// break notification. Its message ID is 0xFFFFFFFFFFFFFFFF and it grants no
+// credits.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.4.6
+func (c *Conn) Notify(r Response) error {
+	msg := c.Create(smbpacket.HeaderSize + r.Size())
+	defer msg.Close()
+
+	packet := smbpacket.Response(msg.Bytes())
+
+	hdr := packet.Header()
+	writeHeader(hdr, r)
+	hdr.SetFlags(smbpacket.ServerToClient)
+	hdr.SetMessageID(UnsolicitedMessageID)
+
+	r.Marshal(packet.Data())
+
+	return c.Send(msg)
+}
+
+// NotifyQueue holds unsolicited responses that are waiting to be sent to
+// the client. The zero value is an empty queue that is ready for use.
+type NotifyQueue struct {
+	mutex   sync.Mutex
+	pending []Response
+	sending bool // True while a goroutine is sending the pending responses
+}
+
+// queueNotify queues an unsolicited response to be sent to the client
+// without waiting for it to be sent. Responses are sent in the order in
+// which they were queued, so a client never sees a break notification
+// before an earlier one for the same oplock or lease. If c has no
+// notification queue the response is sent immediately.
+func (c *Conn) queueNotify(r Response) {
+	q := c.Notifications
+	if q == nil {
+		c.Notify(r)
+		return
+	}
+	q.mutex.Lock()
+	q.pending = append(q.pending, r)
+	if q.sending {
+		q.mutex.Unlock()
+		return
+	}
+	q.sending = true
+	q.mutex.Unlock()
+
+	go c.sendNotifications(q)
+}
+
+// sendNotifications sends the unsolicited responses queued in q until it
+// is empty.
+func (c *Conn) sendNotifications(q *NotifyQueue) {
+	for {
+		q.mutex.Lock()
+		if len(q.pending) == 0 {
+			q.pending = nil
+			q.sending = false
+			q.mutex.Unlock()
+			return
+		}
+		r := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mutex.Unlock()
+
+		c.Notify(r)
+	}
+}
+
+// writeHeader writes the header fields common to all responses.
+func writeHeader(hdr smbpacket.ResponseHeader, r Response) {
+	hdr.SetProtocol(smbpacket.SMB2)
+	hdr.SetSize(smbpacket.HeaderSize)
+	hdr.SetCommand(r.Command())
+	hdr.SetStatus(r.Status())
+}
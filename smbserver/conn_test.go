@@ -0,0 +1,182 @@
+package smbserver_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/smb"
+	"github.com/gentlemanautomaton/smb/msgpool"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbsequencer"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// testTransport is an smb.Conn that records the messages sent to it.
+type testTransport struct {
+	pool   *msgpool.Pool
+	sent   chan []byte
+	closed atomic.Bool
+}
+
+func newTestConn(credits int) (*smbserver.Conn, *testTransport) {
+	transport := &testTransport{
+		pool: msgpool.New(),
+		sent: make(chan []byte, 64),
+	}
+	seq := smbsequencer.New(128)
+	seq.Expand(credits)
+	return &smbserver.Conn{
+		Conn:      transport,
+		Sequencer: seq,
+		ConnState: smbserver.ConnState{
+			Dialect:            smbdialect.SMB311,
+			CreationTime:       time.Now(),
+			SupportMultiCredit: true,
+			Notifications:      new(smbserver.NotifyQueue),
+		},
+	}, transport
+}
+
+func (t *testTransport) Create(length int) smb.Message {
+	return t.pool.Get(length)
+}
+
+func (t *testTransport) Send(msg smb.Message) error {
+	b := make([]byte, msg.Length())
+	copy(b, msg.Bytes())
+	t.sent <- b
+	return nil
+}
+
+func (t *testTransport) Receive() (smb.Message, error) {
+	return nil, errors.New("receive not supported")
+}
+
+func (t *testTransport) Close() error         { t.closed.Store(true); return nil }
+func (t *testTransport) LocalAddr() smb.Addr  { return nil }
+func (t *testTransport) RemoteAddr() smb.Addr { return nil }
+
+// Next returns the next message that was sent, or nil if a message isn't
+// sent within a reasonable amount of time.
+func (t *testTransport) Next() []byte {
+	select {
+	case b := <-t.sent:
+		return b
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+// testResponse is a response with a fixed size and status.
+type testResponse struct {
+	cmd    smbcommand.Code
+	status smbstatus.Code
+	size   int
+}
+
+func (r testResponse) Command() smbcommand.Code { return r.cmd }
+func (r testResponse) Status() smbstatus.Code   { return r.status }
+func (r testResponse) Size() int                { return r.size }
+func (r testResponse) Marshal(data []byte) {
+	for i := range data {
+		data[i] = byte(r.cmd)
+	}
+}
+
+// testRequest describes a request within a test message.
+type testRequest struct {
+	Command   smbcommand.Code
+	MessageID uint64
+	Flags     smbpacket.Flags
+	SessionID uint64
+	TreeID    uint32
+	Channel   uint16
+	Body      []byte
+}
+
+// makeMessage builds a message from a set of requests, compounding them if
+// there is more than one.
+func makeMessage(requests ...testRequest) smb.Message {
+	total := 0
+	for i, r := range requests {
+		length := smbpacket.HeaderSize + len(r.Body)
+		if i < len(requests)-1 {
+			length = smbpacket.Align(length)
+		}
+		total += length
+	}
+	b := make([]byte, total)
+	offset := 0
+	for i, r := range requests {
+		length := smbpacket.HeaderSize + len(r.Body)
+		if i < len(requests)-1 {
+			length = smbpacket.Align(length)
+		}
+		hdr := smbpacket.RequestHeader(b[offset : offset+smbpacket.HeaderSize])
+		hdr.SetProtocol(smbpacket.SMB2)
+		hdr.SetSize(smbpacket.HeaderSize)
+		hdr.SetCommand(r.Command)
+		hdr.SetMessageID(r.MessageID)
+		hdr.SetFlags(r.Flags)
+		hdr.SetChannelSequence(r.Channel)
+		hdr.SetSessionID(r.SessionID)
+		hdr.SetTreeID(r.TreeID)
+		hdr.SetCreditRequest(1)
+		if i < len(requests)-1 {
+			hdr.SetNextCommand(uint32(length))
+		}
+		copy(b[offset+smbpacket.HeaderSize:], r.Body)
+		offset += length
+	}
+	return testMessage(b)
+}
+
+type testMessage []byte
+
+func (m testMessage) Length() int   { return len(m) }
+func (m testMessage) Bytes() []byte { return m }
+func (m testMessage) Close() error  { return nil }
+
+// splitResponses splits a compounded response message into its members.
+func splitResponses(b []byte) (responses []smbpacket.Response) {
+	for {
+		hdr := smbpacket.ResponseHeader(b[:smbpacket.HeaderSize])
+		next := hdr.NextCommand()
+		if next == 0 {
+			return append(responses, smbpacket.Response(b))
+		}
+		responses = append(responses, smbpacket.Response(b[:next]))
+		b = b[next:]
+	}
+}
+
+// waitFor waits for condition to become true.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// addOpen adds o to the open table of conn, creating the table if needed.
+func addOpen(t *testing.T, conn *smbserver.Conn, o *smbserver.Open) smbfile.ID {
+	t.Helper()
+	if conn.Opens == nil {
+		conn.Opens = smbserver.NewOpenTable()
+	}
+	id, err := conn.Opens.Add(o)
+	if err != nil {
+		t.Fatalf("failed to add open: %v", err)
+	}
+	return id
+}
@@ -5,12 +5,14 @@ import (
 
 	"github.com/gentlemanautomaton/smb/smbcap"
 	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbid"
 	"github.com/gentlemanautomaton/smb/smbsecmode"
 )
 
 // ConnState stores information about a connection on the server.
 type ConnState struct {
 	ClientCapabilities smbcap.Flags
+	ClientGUID         smbid.ID
 	Dialect            smbdialect.State
 	CreationTime       time.Time
 	ClientSecurity     smbsecmode.Flags
@@ -18,8 +20,24 @@ type ConnState struct {
 	MaxTransactSize    uint32
 	MaxReadSize        uint32
 	MaxWriteSize       uint32
+	AsyncCommands      *AsyncCommandList
+
+	// PreauthIntegrityHash is the preauthentication integrity hash of the
+	// negotiation of an SMB 3.1.1 connection. Session setup exchanges
+	// extend it to derive their signing keys.
+	PreauthIntegrityHash []byte
+
+	// POSIXExtensions is true if the SMB3 POSIX extensions were negotiated
+	// for the connection.
+	POSIXExtensions bool
+
+	// Notifications holds the unsolicited responses, such as oplock and
+	// lease break notifications, that are waiting to be sent. It should be
+	// created when the connection is set up. If it is nil, notifications
+	// are sent before the request that caused them completes.
+	Notifications *NotifyQueue
+
 	// RequestList
-	// AsyncCommandList
 	// SessionTable
 	// PreauthSessionTable
 }
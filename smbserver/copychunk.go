@@ -0,0 +1,262 @@
+package smbserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcopychunk"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// CopyChunkLimits limits the server-side copies requested by clients.
+// They are returned to clients that exceed them.
+type CopyChunkLimits struct {
+	MaxChunks    uint32 // Maximum number of chunks in a request
+	MaxChunkSize uint32 // Maximum number of bytes in a chunk
+	MaxTotalSize uint32 // Maximum number of bytes copied by a request
+}
+
+// DefaultCopyChunkLimits are the copy limits used by connections that
+// don't specify their own.
+var DefaultCopyChunkLimits = CopyChunkLimits{
+	MaxChunks:    256,
+	MaxChunkSize: 1 << 20,
+	MaxTotalSize: 16 << 20,
+}
+
+// copyBufferSize is the size of the buffer used by the server when the
+// file system of the target can't copy data itself.
+const copyBufferSize = 64 << 10
+
+// copyChunkLimits returns the copy limits of the connection.
+func (c *Conn) copyChunkLimits() CopyChunkLimits {
+	if c.CopyChunkLimits == (CopyChunkLimits{}) {
+		return DefaultCopyChunkLimits
+	}
+	return c.CopyChunkLimits
+}
+
+// resumeKey returns the resume key of o, which identifies it as the source
+// of server-side copies. The key is generated the first time it's
+// requested. It holds the file ID of the open followed by random bytes, so
+// that keys can't be guessed by clients that haven't been given them.
+func (o *Open) resumeKey() ([]byte, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.resume == nil {
+		key := make([]byte, smbcopychunk.KeySize)
+		o.ID.Write(key[0:16])
+		if _, err := rand.Read(key[16:]); err != nil {
+			return nil, err
+		}
+		o.resume = key
+	}
+	return o.resume, nil
+}
+
+// lookupResumeKey returns the open identified by a resume key, or nil.
+func (c *Conn) lookupResumeKey(key []byte) *Open {
+	if c.Opens == nil {
+		return nil
+	}
+	var id smbfile.ID
+	id.Read(key[0:16])
+	o := c.Opens.Lookup(id)
+	if o == nil {
+		return nil
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.resume == nil || subtle.ConstantTimeCompare(o.resume, key) != 1 {
+		return nil
+	}
+	return o
+}
+
+// requestResumeKey processes an FSCTL_SRV_REQUEST_RESUME_KEY request. It
+// returns a key that identifies the open as the source of a subsequent
+// FSCTL_SRV_COPYCHUNK or FSCTL_SRV_COPYCHUNK_WRITE request.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.15.5
+func (c *Conn) requestResumeKey(r *Request, ctl FSCTL) Response {
+	open := c.lookupOpen(r, ctl.FileID)
+	if open == nil {
+		return ioctlError(smbstatus.FileClosed)
+	}
+	if ctl.MaxOutput < smbcopychunk.ResumeKeyResponseSize {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	key, err := open.resumeKey()
+	if err != nil {
+		return ioctlError(smbstatus.InsufficientResources)
+	}
+
+	output := make([]byte, smbcopychunk.ResumeKeyResponseSize)
+	response := smbcopychunk.ResumeKeyResponse(output)
+	response.SetResumeKey(key)
+
+	return smbproto.IoctlResponse{
+		CtlCode: smbioctl.SrvRequestResumeKey,
+		FileID:  ctl.FileID,
+		Output:  output,
+	}
+}
+
+// copyChunk processes an FSCTL_SRV_COPYCHUNK or FSCTL_SRV_COPYCHUNK_WRITE
+// request. Each chunk of the request is copied from the open identified by
+// the source key to the open of the request, in order. The copy is
+// delegated to the file system of the target if it implements
+// smbfs.RangeCopier.
+//
+// Requests that exceed the copy limits of the connection fail with
+// STATUS_INVALID_PARAMETER, and the limits are returned in the response.
+// Requests that fail part way return the progress of the copy.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.15.6
+func (c *Conn) copyChunk(r *Request, ctl FSCTL) Response {
+	target := c.lookupOpen(r, ctl.FileID)
+	if target == nil {
+		return ioctlError(smbstatus.FileClosed)
+	}
+	if target.Directory || target.File == nil {
+		return ioctlError(smbstatus.InvalidDeviceRequest)
+	}
+	request := smbcopychunk.Request(ctl.Input)
+	if !request.Valid() || ctl.MaxOutput < smbcopychunk.ResponseSize {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+
+	// FSCTL_SRV_COPYCHUNK requires read access to the target as well
+	required := smbaccess.Mask(smbaccess.WriteData)
+	if ctl.Code == smbioctl.SrvCopyChunk {
+		required |= smbaccess.ReadData
+	}
+	if !target.GrantedAccess.Match(required) {
+		return ioctlError(smbstatus.AccessDenied)
+	}
+
+	limits := c.copyChunkLimits()
+	if !limits.allow(request) {
+		return copyChunkResponse(ctl, smbstatus.InvalidParameter, limits.MaxChunks, limits.MaxChunkSize, limits.MaxTotalSize)
+	}
+
+	source := c.lookupResumeKey(request.SourceKey())
+	if source == nil {
+		return ioctlError(smbstatus.ObjectNameNotFound)
+	}
+	if source.Directory || source.File == nil {
+		return ioctlError(smbstatus.InvalidDeviceRequest)
+	}
+	if !source.GrantedAccess.Any(smbaccess.ReadData) {
+		return ioctlError(smbstatus.AccessDenied)
+	}
+
+	target.file.breakReadCaching(target, c.oplockBreakTimeout())
+
+	var chunks, total uint32
+	for i, count := 0, int(request.ChunkCount()); i < count; i++ {
+		chunk := request.Chunk(i)
+		length := chunk.Length()
+		srcOffset, dstOffset := chunk.SourceOffset(), chunk.TargetOffset()
+		if srcOffset > math.MaxInt64-uint64(length) || dstOffset > math.MaxInt64-uint64(length) {
+			return copyChunkResponse(ctl, smbstatus.InvalidParameter, chunks, 0, total)
+		}
+		if !source.checkLock(srcOffset, uint64(length), false) || !target.checkLock(dstOffset, uint64(length), true) {
+			return copyChunkResponse(ctl, smbstatus.FileLockConflict, chunks, 0, total)
+		}
+
+		n, err := copyRange(target, int64(dstOffset), source, int64(srcOffset), int64(length))
+		total += uint32(n)
+		switch {
+		case errors.Is(err, io.EOF):
+			// The chunk extends beyond the end of the source
+			return copyChunkResponse(ctl, smbstatus.InvalidViewSize, chunks, uint32(n), total)
+		case err != nil:
+			return copyChunkResponse(ctl, fileStatus(err), chunks, uint32(n), total)
+		}
+		chunks++
+	}
+
+	return copyChunkResponse(ctl, smbstatus.Success, chunks, 0, total)
+}
+
+// allow returns true if the request is within the limits.
+func (limits CopyChunkLimits) allow(request smbcopychunk.Request) bool {
+	count := request.ChunkCount()
+	if count > limits.MaxChunks {
+		return false
+	}
+	var total uint64
+	for i := 0; i < int(count); i++ {
+		length := request.Chunk(i).Length()
+		if length == 0 || length > limits.MaxChunkSize {
+			return false
+		}
+		total += uint64(length)
+	}
+	return total <= uint64(limits.MaxTotalSize)
+}
+
+// copyChunkResponse returns an IOCTL response for a copy request.
+func copyChunkResponse(ctl FSCTL, code smbstatus.Code, chunks, chunkBytes, total uint32) Response {
+	output := make([]byte, smbcopychunk.ResponseSize)
+	response := smbcopychunk.Response(output)
+	response.SetChunksWritten(chunks)
+	response.SetChunkBytesWritten(chunkBytes)
+	response.SetTotalBytesWritten(total)
+
+	return smbproto.IoctlResponse{
+		Code:    code,
+		CtlCode: ctl.Code,
+		FileID:  ctl.FileID,
+		Output:  output,
+	}
+}
+
+// copyRange copies length bytes from the file of src to the file of dst.
+// If the file system of dst implements smbfs.RangeCopier the copy is
+// delegated to it, otherwise the data is read and written by the server.
+func copyRange(dst *Open, dstOffset int64, src *Open, srcOffset int64, length int64) (int64, error) {
+	if copier, ok := dst.FS.(smbfs.RangeCopier); ok {
+		n, err := copier.CopyRange(dst.File, dstOffset, src.File, srcOffset, length)
+		if !errors.Is(err, smbfs.ErrCopyNotSupported) {
+			return n, err
+		}
+	}
+
+	size := int64(copyBufferSize)
+	if length < size {
+		size = length
+	}
+	buf := make([]byte, size)
+	var copied int64
+	for copied < length {
+		b := buf
+		if remaining := length - copied; remaining < int64(len(b)) {
+			b = b[:remaining]
+		}
+		n, rerr := src.File.ReadAt(b, srcOffset+copied)
+		if n > 0 {
+			written, werr := dst.File.WriteAt(b[:n], dstOffset+copied)
+			copied += int64(written)
+			if werr != nil {
+				return copied, werr
+			}
+		}
+		if rerr != nil {
+			if errors.Is(rerr, io.EOF) && copied == length {
+				break
+			}
+			return copied, rerr
+		}
+	}
+	return copied, nil
+}
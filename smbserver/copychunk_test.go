@@ -0,0 +1,171 @@
+package smbserver_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcopychunk"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+type copyChunk struct {
+	Source, Target uint64
+	Length         uint32
+}
+
+// copyChunkInput returns the input of a copy request.
+func copyChunkInput(key []byte, chunks ...copyChunk) []byte {
+	input := make([]byte, smbcopychunk.RequestSize+len(chunks)*smbcopychunk.ChunkSize)
+	request := smbcopychunk.Request(input)
+	request.SetSourceKey(key)
+	request.SetChunkCount(uint32(len(chunks)))
+	for i, c := range chunks {
+		chunk := request.Chunk(i)
+		chunk.SetSourceOffset(c.Source)
+		chunk.SetTargetOffset(c.Target)
+		chunk.SetLength(c.Length)
+	}
+	return input
+}
+
+// resumeKey requests the resume key of the open with the given file ID.
+func (ct *createTest) resumeKey(id smbfile.ID) []byte {
+	ct.t.Helper()
+	packet := ct.send(1, smbcommand.IOCTL, ioctlBody(smbioctl.SrvRequestResumeKey, id, nil, 32))
+	if s := packet.Header().Status(); s != smbstatus.Success {
+		ct.t.Fatalf("resume key request returned %s", s)
+	}
+	output := smbcopychunk.ResumeKeyResponse(smbioctl.Response(packet.Data()).Output())
+	if !output.Valid() {
+		ct.t.Fatal("resume key request returned an invalid response")
+	}
+	return output.ResumeKey()
+}
+
+// copyChunk sends a copy request and checks its status and response.
+func (ct *createTest) copyChunk(desc string, code smbioctl.Code, id smbfile.ID, input []byte, status smbstatus.Code, chunks, chunkBytes, total uint32) {
+	ct.t.Helper()
+	packet := ct.send(1, smbcommand.IOCTL, ioctlBody(code, id, input, smbcopychunk.ResponseSize))
+	checkCopyChunk(ct.t, desc, packet, status, chunks, chunkBytes, total)
+}
+
+// copyChunkFails sends a copy request that is expected to fail without
+// returning the progress of the copy.
+func (ct *createTest) copyChunkFails(desc string, code smbioctl.Code, id smbfile.ID, input []byte, status smbstatus.Code) {
+	ct.t.Helper()
+	packet := ct.send(1, smbcommand.IOCTL, ioctlBody(code, id, input, smbcopychunk.ResponseSize))
+	if s := packet.Header().Status(); s != status {
+		ct.t.Fatalf("%s: returned %s (want %s)", desc, s, status)
+	}
+	if smbioctl.Response(packet.Data()).Valid() {
+		ct.t.Errorf("%s: returned an IOCTL response", desc)
+	}
+}
+
+// checkCopyChunk checks the status of a copy response and the progress
+// or limits that it holds.
+func checkCopyChunk(t *testing.T, desc string, packet smbpacket.Response, status smbstatus.Code, chunks, chunkBytes, total uint32) {
+	t.Helper()
+	if s := packet.Header().Status(); s != status {
+		t.Fatalf("%s: returned %s (want %s)", desc, s, status)
+	}
+	output := smbcopychunk.Response(smbioctl.Response(packet.Data()).Output())
+	if !output.Valid() {
+		t.Fatalf("%s: returned an invalid response", desc)
+	}
+	if output.ChunksWritten() != chunks || output.ChunkBytesWritten() != chunkBytes || output.TotalBytesWritten() != total {
+		t.Errorf("%s: returned %d chunks, %d chunk bytes, %d total (want %d, %d, %d)", desc,
+			output.ChunksWritten(), output.ChunkBytesWritten(), output.TotalBytesWritten(), chunks, chunkBytes, total)
+	}
+}
+
+func TestCopyChunk(t *testing.T) {
+	ct := newCreateTest(t)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 8192)
+	if err := os.WriteFile(filepath.Join(ct.root, "source.bin"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := ct.create("open source", 1, createSpec{Name: "source.bin", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+	target := ct.create("create target", 1, createSpec{Name: "target.bin", Access: readWrite, Share: shareAll, Disposition: smbcreate.Create}, smbstatus.Success)
+	writeOnly := ct.create("open target for writing", 1, createSpec{Name: "target.bin", Access: smbaccess.WriteData, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+	key := ct.resumeKey(source)
+
+	half := uint32(len(data) / 2)
+	ct.copyChunk("copy", smbioctl.SrvCopyChunk, target, copyChunkInput(key,
+		copyChunk{Source: uint64(half), Target: uint64(half), Length: half},
+		copyChunk{Source: 0, Target: 0, Length: half},
+	), smbstatus.Success, 2, 0, uint32(len(data)))
+	if b, err := os.ReadFile(filepath.Join(ct.root, "target.bin")); err != nil || !bytes.Equal(b, data) {
+		t.Errorf("copy produced %d bytes that don't match the source (err %v)", len(b), err)
+	}
+
+	ct.copyChunkFails("copy to write-only target", smbioctl.SrvCopyChunk, writeOnly, copyChunkInput(key,
+		copyChunk{Length: 16},
+	), smbstatus.AccessDenied)
+	ct.copyChunk("copy write to write-only target", smbioctl.SrvCopyChunkWrite, writeOnly, copyChunkInput(key,
+		copyChunk{Target: uint64(len(data)), Length: 16},
+	), smbstatus.Success, 1, 0, 16)
+
+	ct.copyChunk("copy beyond end of source", smbioctl.SrvCopyChunk, target, copyChunkInput(key,
+		copyChunk{Source: 0, Target: 0, Length: 16},
+		copyChunk{Source: uint64(len(data)) - 8, Target: 16, Length: 16},
+	), smbstatus.InvalidViewSize, 1, 8, 24)
+
+	bad := append([]byte(nil), key...)
+	bad[len(bad)-1] ^= 0xFF
+	ct.copyChunkFails("copy with unknown key", smbioctl.SrvCopyChunk, target, copyChunkInput(bad,
+		copyChunk{Length: 16},
+	), smbstatus.ObjectNameNotFound)
+
+	ct.close(1, source)
+	ct.copyChunkFails("copy from closed source", smbioctl.SrvCopyChunk, target, copyChunkInput(key,
+		copyChunk{Length: 16},
+	), smbstatus.ObjectNameNotFound)
+}
+
+func TestCopyChunkLimits(t *testing.T) {
+	ct := newCreateTest(t)
+	ct.conn.CopyChunkLimits = smbserver.CopyChunkLimits{MaxChunks: 2, MaxChunkSize: 64, MaxTotalSize: 100}
+	id := ct.create("create", 1, createSpec{Name: "a.bin", Access: readWrite, Share: shareAll, Disposition: smbcreate.Create}, smbstatus.Success)
+	key := ct.resumeKey(id)
+
+	tests := []struct {
+		desc   string
+		chunks []copyChunk
+	}{
+		{"too many chunks", []copyChunk{{Length: 1}, {Length: 1}, {Length: 1}}},
+		{"chunk too large", []copyChunk{{Length: 65}}},
+		{"empty chunk", []copyChunk{{Length: 0}}},
+		{"too many bytes", []copyChunk{{Length: 64}, {Length: 64}}},
+	}
+	for _, test := range tests {
+		ct.copyChunk(test.desc, smbioctl.SrvCopyChunkWrite, id, copyChunkInput(key, test.chunks...), smbstatus.InvalidParameter, 2, 64, 100)
+	}
+}
+
+func TestCopyChunkFallback(t *testing.T) {
+	lt := newLockTest(t)
+	lt.a.GrantedAccess = smbaccess.ReadData | smbaccess.WriteData
+	copy(lt.a.File.(*memFile).data, "abcdefgh")
+
+	packet := lt.send(lt.a, smbcommand.IOCTL, ioctlBody(smbioctl.SrvRequestResumeKey, lt.a.ID, nil, 32))
+	key := smbcopychunk.ResumeKeyResponse(smbioctl.Response(packet.Data()).Output()).ResumeKey()
+
+	// The memory file system can't copy, so the server copies the data
+	input := copyChunkInput(key, copyChunk{Source: 0, Target: 64, Length: 8})
+	packet = lt.send(lt.a, smbcommand.IOCTL, ioctlBody(smbioctl.SrvCopyChunk, lt.a.ID, input, smbcopychunk.ResponseSize))
+	checkCopyChunk(t, "copy", packet, smbstatus.Success, 1, 0, 8)
+	if got := string(lt.a.File.(*memFile).data[64:]); got != "abcdefgh" {
+		t.Errorf("copy wrote %q", got)
+	}
+}
@@ -0,0 +1,223 @@
+package smbserver_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbclose"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// createTest holds a connection that serves files from a temporary
+// directory.
+type createTest struct {
+	t         *testing.T
+	root      string
+	conn      *smbserver.Conn
+	transport *testTransport
+	fs        *smbosfs.FS
+	messageID uint64
+
+	// Flags and Channel are applied to the header of each request
+	flags   smbpacket.Flags
+	channel uint16
+}
+
+func newCreateTest(t *testing.T) *createTest {
+	conn, transport := newTestConn(64)
+	conn.Opens = smbserver.NewOpenTable()
+	root := t.TempDir()
+	return &createTest{t: t, root: root, conn: conn, transport: transport, fs: smbosfs.New(root)}
+}
+
+// createSpec describes a create request.
+type createSpec struct {
+	Name        string
+	Access      smbaccess.Mask
+	Share       smbcreate.ShareAccess
+	Disposition smbcreate.Disposition
+	Options     smbcreate.Options
+	Oplock      smboplock.Level
+	Contexts    []byte
+}
+
+// process processes a request from the given session without waiting for
+// its response.
+func (ct *createTest) process(session uint64, cmd smbcommand.Code, body []byte) {
+	ct.t.Helper()
+	r := testRequest{Command: cmd, MessageID: ct.messageID, Flags: ct.flags, SessionID: session, TreeID: 1, Channel: ct.channel, Body: body}
+	ct.messageID++
+	handler := smbserver.CommandHandlerFunc(func(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+		switch r.Header().Command() {
+		case smbcommand.SessionSetup:
+			return c.SessionSetup(r)
+		case smbcommand.Create:
+			return c.CreateFile(r, ct.fs)
+		case smbcommand.Close:
+			return c.CloseFile(r)
+		case smbcommand.Write:
+			return c.Write(r)
+		case smbcommand.Lock:
+			return c.Lock(r)
+		case smbcommand.OplockBreak:
+			return c.OplockBreak(r)
+		case smbcommand.IOCTL:
+			return c.Ioctl(r)
+		}
+		return nil
+	})
+	if err := ct.conn.Process(makeMessage(r), handler); err != nil {
+		ct.t.Fatalf("Process returned %v", err)
+	}
+}
+
+// send sends a request from the given session and returns the response.
+func (ct *createTest) send(session uint64, cmd smbcommand.Code, body []byte) smbpacket.Response {
+	ct.t.Helper()
+	ct.process(session, cmd, body)
+	b := ct.transport.Next()
+	if b == nil {
+		ct.t.Fatal("no response was sent")
+	}
+	return smbpacket.Response(b)
+}
+
+// create sends a create request and checks its status. It returns the file
+// ID of the open if it succeeded.
+func (ct *createTest) create(desc string, session uint64, spec createSpec, status smbstatus.Code) smbfile.ID {
+	ct.t.Helper()
+	packet := ct.send(session, smbcommand.Create, createBody(spec))
+	response := ct.checkCreate(desc, packet, status)
+	if response == nil {
+		return smbfile.ID{}
+	}
+	return response.FileID()
+}
+
+// checkCreate checks the status of a create response. It returns the
+// response if the create succeeded.
+func (ct *createTest) checkCreate(desc string, packet smbpacket.Response, status smbstatus.Code) smbcreate.Response {
+	ct.t.Helper()
+	if s := packet.Header().Status(); s != status {
+		ct.t.Fatalf("%s: returned %s (want %s)", desc, s, status)
+	}
+	if status != smbstatus.Success {
+		return nil
+	}
+	response := smbcreate.Response(packet.Data())
+	if !response.Valid() {
+		ct.t.Fatalf("%s: returned an invalid response", desc)
+	}
+	return response
+}
+
+// createBody returns the body of a create request described by spec.
+func createBody(spec createSpec) []byte {
+	body := make([]byte, smbcreate.BufferSize(spec.Name, spec.Contexts))
+	request := smbcreate.Request(body)
+	request.SetSize(57)
+	request.SetRequestedOplockLevel(spec.Oplock)
+	request.SetDesiredAccess(spec.Access)
+	request.SetShareAccess(spec.Share)
+	request.SetCreateDisposition(spec.Disposition)
+	request.SetCreateOptions(spec.Options)
+	request.SetNameAndContexts(spec.Name, spec.Contexts)
+	return body
+}
+
+// close closes the open with the given file ID.
+func (ct *createTest) close(session uint64, id smbfile.ID) {
+	ct.t.Helper()
+	body := make([]byte, smbclose.RequestSize)
+	request := smbclose.Request(body)
+	request.SetSize(24)
+	request.SetFileID(id)
+	if s := ct.send(session, smbcommand.Close, body).Header().Status(); s != smbstatus.Success {
+		ct.t.Fatalf("close returned %s", s)
+	}
+}
+
+const (
+	readWrite = smbaccess.ReadData | smbaccess.WriteData
+	shareAll  = smbcreate.ShareRead | smbcreate.ShareWrite | smbcreate.ShareDelete
+)
+
+func TestCreateDisposition(t *testing.T) {
+	ct := newCreateTest(t)
+
+	ct.create("open of missing file", 1, createSpec{Name: "a.txt", Access: readWrite, Disposition: smbcreate.Open}, smbstatus.ObjectNameNotFound)
+	ct.create("open in missing directory", 1, createSpec{Name: `missing\a.txt`, Access: readWrite, Disposition: smbcreate.Open}, smbstatus.ObjectPathNotFound)
+	id := ct.create("create", 1, createSpec{Name: "a.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Create}, smbstatus.Success)
+	ct.create("create of existing file", 1, createSpec{Name: "a.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Create}, smbstatus.ObjectNameCollision)
+	ct.create("directory open of file", 1, createSpec{Name: "a.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Options: smbcreate.DirectoryFile}, smbstatus.NotADirectory)
+	ct.close(1, id)
+
+	id = ct.create("directory create", 1, createSpec{Name: "dir", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Create, Options: smbcreate.DirectoryFile}, smbstatus.Success)
+	ct.close(1, id)
+	if info, err := os.Stat(filepath.Join(ct.root, "dir")); err != nil || !info.IsDir() {
+		t.Fatalf("directory was not created")
+	}
+	ct.create("file open of directory", 1, createSpec{Name: "dir", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Options: smbcreate.NonDirectoryFile}, smbstatus.FileIsADirectory)
+	ct.create("invalid name", 1, createSpec{Name: `dir\..\..\a.txt`, Access: smbaccess.ReadData, Disposition: smbcreate.Open}, smbstatus.ObjectNameInvalid)
+}
+
+func TestCreateSharingViolation(t *testing.T) {
+	ct := newCreateTest(t)
+	if err := os.WriteFile(filepath.Join(ct.root, "doc.txt"), []byte("contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first := ct.create("first open", 1, createSpec{Name: "doc.txt", Access: readWrite, Share: smbcreate.ShareRead, Disposition: smbcreate.Open}, smbstatus.Success)
+
+	ct.create("write without share", 2, createSpec{Name: "doc.txt", Access: smbaccess.WriteData, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.SharingViolation)
+	ct.create("read denying write", 2, createSpec{Name: "doc.txt", Access: smbaccess.ReadData, Share: smbcreate.ShareRead, Disposition: smbcreate.Open}, smbstatus.SharingViolation)
+	ct.create("overwrite", 2, createSpec{Name: "doc.txt", Access: smbaccess.WriteData, Share: shareAll, Disposition: smbcreate.Overwrite}, smbstatus.SharingViolation)
+	if data, err := os.ReadFile(filepath.Join(ct.root, "doc.txt")); err != nil || string(data) != "contents" {
+		t.Fatalf("file was modified by a create that failed with a sharing violation")
+	}
+
+	reader := ct.create("shared read", 2, createSpec{Name: "doc.txt", Access: smbaccess.ReadData, Share: smbcreate.ShareRead | smbcreate.ShareWrite, Disposition: smbcreate.Open}, smbstatus.Success)
+	attrs := ct.create("attribute access", 3, createSpec{Name: "doc.txt", Access: smbaccess.ReadAttributes, Disposition: smbcreate.Open}, smbstatus.Success)
+
+	// Hard links refer to the same file
+	if err := os.Link(filepath.Join(ct.root, "doc.txt"), filepath.Join(ct.root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	ct.create("write through hard link", 2, createSpec{Name: "link.txt", Access: smbaccess.WriteData, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.SharingViolation)
+
+	ct.close(1, first)
+	ct.close(2, reader)
+	ct.create("write after close", 2, createSpec{Name: "link.txt", Access: smbaccess.WriteData, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+	ct.close(3, attrs)
+}
+
+func TestCreateDeleteOnClose(t *testing.T) {
+	ct := newCreateTest(t)
+	name := filepath.Join(ct.root, "temp.txt")
+
+	ct.create("delete on close without delete access", 1, createSpec{Name: "temp.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.OpenIf, Options: smbcreate.DeleteOnClose}, smbstatus.AccessDenied)
+
+	first := ct.create("delete on close", 1, createSpec{Name: "temp.txt", Access: readWrite | smbaccess.Delete, Share: shareAll, Disposition: smbcreate.OpenIf, Options: smbcreate.DeleteOnClose}, smbstatus.Success)
+	second := ct.create("second open", 2, createSpec{Name: "temp.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+
+	ct.close(1, first)
+	ct.create("open of delete pending file", 2, createSpec{Name: "temp.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.DeletePending)
+	if _, err := os.Stat(name); err != nil {
+		t.Fatalf("file was deleted while it was still open")
+	}
+
+	ct.close(2, second)
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("file was not deleted after its last open was closed")
+	}
+	ct.create("open after delete", 2, createSpec{Name: "temp.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.ObjectNameNotFound)
+}
@@ -0,0 +1,205 @@
+package smbserver
+
+import (
+	"os"
+	"path"
+	"sort"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbdir"
+	"github.com/gentlemanautomaton/smb/smbposix"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// directoryScan is the state of the enumeration of a directory by an open.
+type directoryScan struct {
+	pattern string
+	entries []os.FileInfo // The entries of the directory that match the pattern
+	names   []string      // The names of the entries, which include . and ..
+	next    int           // The index of the next entry to return
+}
+
+// QueryDirectory processes an SMB2 QUERY_DIRECTORY request. It returns
+// entries of the directory referred to by the request that match the
+// request's search pattern, which may contain * and ? wildcards. Patterns
+// are matched case-sensitively.
+//
+// An open enumerates its directory once. The first query of an open reads
+// the directory, and subsequent queries continue from where the last one
+// stopped until the directory is exhausted, after which they fail with
+// STATUS_NO_MORE_FILES. Queries with SMB2_RESTART_SCANS or SMB2_REOPEN
+// start the enumeration again, and the latter also change its pattern.
+//
+// Only POSIX file information on connections that negotiated the SMB3
+// POSIX extensions is supported; queries of other classes of information
+// fail with STATUS_NOT_SUPPORTED.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.18
+func (c *Conn) QueryDirectory(r *Request) Response {
+	request := smbdir.Request(r.Data())
+	if !request.Valid() {
+		return queryDirectoryError(smbstatus.InvalidParameter)
+	}
+	length := request.OutputBufferLength()
+	if c.MaxTransactSize > 0 && length > c.MaxTransactSize {
+		return queryDirectoryError(smbstatus.InvalidParameter)
+	}
+
+	id, ok := r.ResolveFileID(request.FileID())
+	if !ok {
+		return queryDirectoryError(smbstatus.InvalidParameter)
+	}
+	open := c.lookupOpen(r, id)
+	if open == nil {
+		return queryDirectoryError(smbstatus.FileClosed)
+	}
+	r.SetFileID(id)
+
+	if !open.Directory || open.File == nil {
+		return queryDirectoryError(smbstatus.InvalidParameter)
+	}
+	if !open.GrantedAccess.Match(smbaccess.ListDirectory) {
+		return queryDirectoryError(smbstatus.AccessDenied)
+	}
+	if !c.POSIXExtensions || request.FileInfoClass() != smbposix.InfoClass {
+		return queryDirectoryError(smbstatus.NotSupported)
+	}
+	if int(length) < smbposix.DirectoryInfoSize(nil, nil, "") {
+		return queryDirectoryError(smbstatus.InfoLengthMismatch)
+	}
+
+	open.mutex.Lock()
+	defer open.mutex.Unlock()
+
+	flags := request.Flags()
+	first := open.scan == nil || flags.Match(smbdir.RestartScans) || flags.Match(smbdir.Reopen)
+	if first {
+		pattern := request.FileName()
+		if pattern == "" {
+			pattern = "*"
+		}
+		if open.scan != nil && !flags.Match(smbdir.Reopen) {
+			pattern = open.scan.pattern
+		}
+		scan, err := scanDirectory(open, pattern)
+		if err != nil {
+			return queryDirectoryError(fileStatus(err))
+		}
+		open.scan = scan
+	}
+
+	scan := open.scan
+	if scan.next >= len(scan.entries) {
+		if first {
+			return queryDirectoryError(smbstatus.NoSuchFile)
+		}
+		return queryDirectoryError(smbstatus.NoMoreFiles)
+	}
+
+	var output []byte
+	last := -1 // The offset of the last entry in output
+	for scan.next < len(scan.entries) {
+		info, name := scan.entries[scan.next], scan.names[scan.next]
+		f := describePOSIX(open.FS, path.Join(open.Name, name), info, infoAttributes(info))
+		size := smbposix.DirectoryInfoSize(f.owner, f.group, name)
+		start := align8(len(output))
+		if start+size > int(length) {
+			if last < 0 {
+				return queryDirectoryError(smbstatus.BufferOverflow)
+			}
+			break
+		}
+
+		output = append(output, make([]byte, start+size-len(output))...)
+		if last >= 0 {
+			smbposix.DirectoryInfo(output[last:]).SetNextEntryOffset(uint32(start - last))
+		}
+		entry := smbposix.DirectoryInfo(output[start:])
+		f.put(entry.Info())
+		entry.SetFileName(name)
+		last = start
+		scan.next++
+
+		if flags.Match(smbdir.ReturnSingleEntry) {
+			break
+		}
+	}
+
+	return smbproto.QueryDirectoryResponse{Output: output}
+}
+
+// scanDirectory reads the entries of the directory of open whose names
+// match pattern. The directory itself and its parent are described by the
+// . and .. entries.
+func scanDirectory(open *Open, pattern string) (*directoryScan, error) {
+	dir, err := open.FS.OpenFile(open.Name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	self, err := open.stat()
+	if err != nil {
+		return nil, err
+	}
+	parent, err := open.FS.Stat(path.Dir(open.Name))
+	if err != nil {
+		parent = self
+	}
+
+	scan := &directoryScan{pattern: pattern}
+	add := func(name string, info os.FileInfo) {
+		if matchPattern(pattern, name) {
+			scan.entries = append(scan.entries, info)
+			scan.names = append(scan.names, name)
+		}
+	}
+	add(".", self)
+	add("..", parent)
+	for _, info := range infos {
+		add(info.Name(), info)
+	}
+	return scan, nil
+}
+
+// matchPattern returns true if name matches pattern, in which * matches
+// any sequence of characters and ? matches any single character.
+func matchPattern(pattern, name string) bool {
+	p, n := []rune(pattern), []rune(name)
+	i, j, star, mark := 0, 0, -1, 0
+	for j < len(n) {
+		switch {
+		case i < len(p) && p[i] == '*':
+			star, mark = i, j
+			i++
+		case i < len(p) && (p[i] == '?' || p[i] == n[j]):
+			i++
+			j++
+		case star >= 0:
+			mark++
+			i, j = star+1, mark
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
+
+// align8 rounds n up to a multiple of 8.
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
+
+func queryDirectoryError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.QueryDirectory, Code: code}
+}
@@ -0,0 +1,493 @@
+package smbserver
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbdurable"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smblease"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+const (
+	// DefaultDurableTimeout is the amount of time a disconnected durable
+	// open is preserved when the client doesn't request a timeout and
+	// DurableTable.Timeout is zero.
+	DefaultDurableTimeout = 60 * time.Second
+
+	// DefaultMaxDurableTimeout is the longest timeout that may be
+	// requested by a client when DurableTable.MaxTimeout is zero.
+	DefaultMaxDurableTimeout = 5 * time.Minute
+)
+
+// DurableTable holds the durable opens of the server. Durable opens survive
+// the loss of the connection they were created on for a period of time,
+// during which the client may reconnect to them from a new connection. The
+// table is independent of any connection. It must be created with
+// NewDurableTable.
+type DurableTable struct {
+	// Timeout is the amount of time a disconnected durable open is
+	// preserved when the client doesn't request a timeout. If zero,
+	// DefaultDurableTimeout is used.
+	Timeout time.Duration
+
+	// MaxTimeout is the longest timeout that a client may request. If
+	// zero, DefaultMaxDurableTimeout is used.
+	MaxTimeout time.Duration
+
+	mutex    sync.Mutex
+	byID     map[uint64]*Open   // Keyed by persistent file ID
+	byGUID   map[smbid.ID]*Open // Keyed by create GUID
+	timers   map[*Open]*time.Timer
+	reclaims map[uint64]*reclaimable // Restored persistent handles, keyed by persistent file ID
+}
+
+// NewDurableTable returns an empty durable open table that is ready for
+// use.
+func NewDurableTable() *DurableTable {
+	return &DurableTable{
+		byID:     make(map[uint64]*Open),
+		byGUID:   make(map[smbid.ID]*Open),
+		timers:   make(map[*Open]*time.Timer),
+		reclaims: make(map[uint64]*reclaimable),
+	}
+}
+
+// Lookup returns the durable open with the given persistent file ID, or
+// nil.
+func (t *DurableTable) Lookup(persistent uint64) *Open {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.byID[persistent]
+}
+
+// LookupGUID returns the durable open that was created with the given
+// create GUID, or nil.
+func (t *DurableTable) LookupGUID(id smbid.ID) *Open {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.byGUID[id]
+}
+
+// Len returns the number of durable opens in the table.
+func (t *DurableTable) Len() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.byID)
+}
+
+// Disconnected returns the number of durable opens in the table that are
+// waiting for their clients to reconnect.
+func (t *DurableTable) Disconnected() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.timers)
+}
+
+// timeout returns the timeout of a durable open for which the client
+// requested the given timeout.
+func (t *DurableTable) timeout(requested time.Duration) time.Duration {
+	max := t.MaxTimeout
+	if max <= 0 {
+		max = DefaultMaxDurableTimeout
+	}
+	switch {
+	case requested <= 0 && t.Timeout > 0:
+		requested = t.Timeout
+	case requested <= 0:
+		requested = DefaultDurableTimeout
+	}
+	if requested > max {
+		requested = max
+	}
+	return requested
+}
+
+// add adds the durable open o to the table.
+func (t *DurableTable) add(o *Open) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.byID[o.ID.Persistent] = o
+	if o.CreateGUID != (smbid.ID{}) {
+		t.byGUID[o.CreateGUID] = o
+	}
+}
+
+// remove removes o from the table. It returns false if o wasn't in the
+// table.
+func (t *DurableTable) remove(o *Open) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.byID[o.ID.Persistent] != o {
+		return false
+	}
+	delete(t.byID, o.ID.Persistent)
+	if t.byGUID[o.CreateGUID] == o {
+		delete(t.byGUID, o.CreateGUID)
+	}
+	if timer := t.timers[o]; timer != nil {
+		timer.Stop()
+		delete(t.timers, o)
+	}
+	return true
+}
+
+// disconnect starts the timeout of the durable open o, which has lost its
+// connection. If the client doesn't reconnect before the timeout elapses,
+// o is removed from the table and expire is called.
+func (t *DurableTable) disconnect(o *Open, expire func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.byID[o.ID.Persistent] != o {
+		return
+	}
+	t.timers[o] = time.AfterFunc(o.DurableTimeout, func() {
+		if t.remove(o) {
+			expire()
+		}
+	})
+}
+
+// reconnect stops the timeout of the disconnected durable open o. It
+// returns false if o isn't disconnected or has already expired.
+func (t *DurableTable) reconnect(o *Open) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	timer := t.timers[o]
+	if timer == nil || !timer.Stop() {
+		return false
+	}
+	delete(t.timers, o)
+	return true
+}
+
+// durableRequest holds the durable handle requested by a create request.
+type durableRequest struct {
+	v2         bool
+	timeout    time.Duration
+	flags      smbdurable.Flags
+	createGUID smbid.ID
+}
+
+// reconnectRequest holds the durable open that a create request asks to
+// reconnect to.
+type reconnectRequest struct {
+	v2         bool
+	fileID     smbfile.ID
+	createGUID smbid.ID
+}
+
+// parseDurableContexts returns the durable handle request or reconnect
+// request held by contexts. It returns false if the contexts are invalid or
+// conflict with each other.
+func parseDurableContexts(contexts smbcreate.ContextList) (durable *durableRequest, reconnect *reconnectRequest, ok bool) {
+	var found []string
+	for _, c := range contexts.Contexts() {
+		switch name := c.Name(); name {
+		case smbcreate.DurableHandleRequest:
+			if !smbdurable.Request(c.Data()).Valid() {
+				return nil, nil, false
+			}
+			durable = &durableRequest{}
+			found = append(found, name)
+		case smbcreate.DurableHandleRequestV2:
+			data := smbdurable.RequestV2(c.Data())
+			if !data.Valid() {
+				return nil, nil, false
+			}
+			durable = &durableRequest{v2: true, timeout: data.Timeout(), flags: data.Flags(), createGUID: data.CreateGUID()}
+			found = append(found, name)
+		case smbcreate.DurableHandleReconnect:
+			data := smbdurable.Reconnect(c.Data())
+			if !data.Valid() {
+				return nil, nil, false
+			}
+			reconnect = &reconnectRequest{fileID: data.FileID()}
+			found = append(found, name)
+		case smbcreate.DurableHandleReconnectV2:
+			data := smbdurable.ReconnectV2(c.Data())
+			if !data.Valid() {
+				return nil, nil, false
+			}
+			reconnect = &reconnectRequest{v2: true, fileID: data.FileID(), createGUID: data.CreateGUID()}
+			found = append(found, name)
+		}
+	}
+
+	// At most one durable handle context may be present, except that a
+	// version 1 reconnect takes precedence over a version 1 request
+	switch len(found) {
+	case 0, 1:
+		return durable, reconnect, true
+	case 2:
+		if found[0] != found[1] && (found[0] == smbcreate.DurableHandleReconnect || found[1] == smbcreate.DurableHandleReconnect) &&
+			(found[0] == smbcreate.DurableHandleRequest || found[1] == smbcreate.DurableHandleRequest) {
+			return nil, reconnect, true
+		}
+	}
+	return nil, nil, false
+}
+
+// grantDurable makes o durable if it caches handles through a batch oplock
+// or a lease with handle caching, and adds it to the durable open table.
+// Durable opens survive the loss of their connection until their durable
+// timeout elapses.
+// Opens within continuously available file systems are made persistent
+// when the client requests it, regardless of their caching, and their
+// state is written to the state store.
+//
+// It appends the durable handle create context for the create response to
+// contexts and returns the extended list. If o is not made durable,
+// contexts is returned unchanged.
+func (c *Conn) grantDurable(contexts []byte, o *Open, req *durableRequest) []byte {
+	if c.Durable == nil {
+		return contexts
+	}
+
+	persistent := req.v2 && req.flags.Match(smbdurable.Persistent) && c.persistentHandles() && continuouslyAvailable(o.FS)
+	if !persistent && !o.cachesHandle() {
+		return contexts
+	}
+
+	o.Durable = true
+	o.DurableTimeout = c.Durable.timeout(req.timeout)
+	o.DurableOwner = c.token(o.SessionID).User
+	if !req.v2 {
+		c.Durable.add(o)
+		return smbcreate.AppendContext(contexts, smbcreate.DurableHandleRequest, make([]byte, smbdurable.ResponseSize))
+	}
+
+	o.CreateGUID = req.createGUID
+	data := smbdurable.ResponseV2(make([]byte, smbdurable.ResponseV2Size))
+	data.SetTimeout(o.DurableTimeout)
+	if persistent {
+		o.Persistent = true
+		o.store = c.Store
+		if err := o.persist(); err == nil {
+			data.SetFlags(smbdurable.Persistent)
+		} else {
+			o.Persistent = false
+			o.store = nil
+			if !o.cachesHandle() {
+				o.Durable = false
+				return contexts
+			}
+		}
+	}
+	c.Durable.add(o)
+	return smbcreate.AppendContext(contexts, smbcreate.DurableHandleRequestV2, data)
+}
+
+// cachesHandle returns true if o holds a batch oplock or a lease with
+// handle caching.
+func (o *Open) cachesHandle() bool {
+	f := o.file
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if o.lease != nil {
+		return o.lease.state.Match(smblease.HandleCaching)
+	}
+	return o.oplock.level == smboplock.Batch
+}
+
+// reconnectDurable processes a create request that reconnects to the
+// durable open described by req. The open is reattached to the connection
+// with its locks, oplock and lease intact, instead of the file being
+// opened again. Persistent opens can also be reconnected after the server
+// restarts, once they have been reclaimed from the state store. Opens are
+// only reconnected within the share they were made in.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ sections 3.3.5.9.7 and 3.3.5.9.12
+func (c *Conn) reconnectDurable(p *createParams, req *reconnectRequest) Response {
+	if c.Durable == nil {
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+	o := c.Durable.Lookup(req.fileID.Persistent)
+	if o == nil {
+		if h, ok := c.Durable.reclaimable(req.fileID.Persistent); ok {
+			return c.reclaim(p, req, h)
+		}
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+	if req.v2 && (o.CreateGUID != req.createGUID || o.ClientGUID != c.ClientGUID) {
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+	if !strings.EqualFold(o.share, p.shareName) {
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+	if o.Name != p.name {
+		return createError(smbstatus.InvalidParameter)
+	}
+
+	// Opens that hold a lease must be reconnected by the lease holder
+	f := o.file
+	f.mutex.Lock()
+	l := o.lease
+	f.mutex.Unlock()
+	switch {
+	case l == nil && p.lease != nil:
+		return createError(smbstatus.ObjectNameNotFound)
+	case l != nil && (p.lease == nil || p.lease.key != l.Key || l.Client != c.ClientGUID):
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+	if !c.token(p.sessionID).User.Equal(o.DurableOwner) {
+		return createError(smbstatus.AccessDenied)
+	}
+
+	if !c.Durable.reconnect(o) {
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+
+	info, err := o.File.Stat()
+	if err != nil {
+		return createError(fileStatus(err))
+	}
+
+	o.attachConn(c, p.sessionID, p.treeID)
+
+	response := smbproto.CreateResponse{
+		Action: smbcreate.Opened,
+		FileID: o.ID,
+	}
+	f.mutex.Lock()
+	if l != nil {
+		l.conn = c
+		response.OplockLevel = smboplock.Lease
+		response.Contexts = l.responseContext()
+	} else {
+		response.OplockLevel = o.oplock.level
+	}
+	f.mutex.Unlock()
+	setCreateInfo(&response, o, info)
+	return response
+}
+
+// Disconnect releases the opens made through the connection after it has
+// been lost or closed. Durable opens are preserved in the durable open
+// table so that the client can reconnect to them, and are closed if the
+// client doesn't reconnect in time. All other opens are closed.
+//
+// The state of persistent opens is written to the state store when they
+// are disconnected, and removed from it when they expire.
+//
+// The connection is removed from the channels of its sessions. Opens that
+// belong to a session with other channels remain open and are moved to one
+// of the remaining channels.
+func (c *Conn) Disconnect() {
+	var survivors map[uint64]*Conn
+	if c.Sessions != nil {
+		survivors = c.Sessions.unbind(c)
+	}
+
+	opens := c.Opens
+	if opens == nil {
+		return
+	}
+	for _, o := range opens.connectedTo(c) {
+		if next := survivors[o.sessionID()]; next != nil {
+			o.moveConn(c, next)
+			continue
+		}
+		if o.Durable && c.Durable != nil {
+			o.detachConn(c)
+			o.persist()
+			c.Durable.disconnect(o, func() {
+				o.unpersist()
+				if o := opens.Remove(o.ID); o != nil {
+					o.Close()
+				}
+			})
+			continue
+		}
+		if o := opens.Remove(o.ID); o != nil {
+			o.Close()
+		}
+	}
+}
+
+// connectedTo returns the opens in the table that were made or reconnected
+// through c.
+func (t *OpenTable) connectedTo(c *Conn) []*Open {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	var opens []*Open
+	for _, o := range t.opens {
+		if o.connection() == c {
+			opens = append(opens, o)
+		}
+	}
+	return opens
+}
+
+// connection returns the connection of the open, or nil if the open has
+// been disconnected.
+func (o *Open) connection() *Conn {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.conn
+}
+
+// belongsTo returns true if the open is connected and belongs to the given
+// session and tree.
+func (o *Open) belongsTo(sessionID uint64, treeID uint32) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return !o.detached && o.SessionID == sessionID && o.TreeID == treeID
+}
+
+// detachConn disconnects the open from c. Break notifications for the
+// open's lease are no longer sent to c.
+func (o *Open) detachConn(c *Conn) {
+	o.mutex.Lock()
+	o.conn = nil
+	o.detached = true
+	o.mutex.Unlock()
+
+	f := o.file
+	f.mutex.Lock()
+	if l := o.lease; l != nil && l.conn == c {
+		l.conn = nil
+	}
+	f.mutex.Unlock()
+}
+
+// sessionID returns the ID of the session that the open belongs to.
+func (o *Open) sessionID() uint64 {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.SessionID
+}
+
+// moveConn moves the open from c to another channel of its session. Break
+// notifications for the open's lease are sent to the new channel.
+func (o *Open) moveConn(c, next *Conn) {
+	o.mutex.Lock()
+	o.conn = next
+	o.mutex.Unlock()
+
+	f := o.file
+	if f == nil {
+		return
+	}
+	f.mutex.Lock()
+	if l := o.lease; l != nil && l.conn == c {
+		l.conn = next
+	}
+	f.mutex.Unlock()
+}
+
+// attachConn reconnects the open to c within the given session and tree.
+func (o *Open) attachConn(c *Conn, sessionID uint64, treeID uint32) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.conn = c
+	o.detached = false
+	o.SessionID = sessionID
+	o.TreeID = treeID
+}
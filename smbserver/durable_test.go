@@ -0,0 +1,296 @@
+package smbserver_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbdurable"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smblease"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbsession"
+	"github.com/gentlemanautomaton/smb/smbsign"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+var (
+	createGUID = smbid.ID{0xC1}
+	otherGUID  = smbid.ID{0xC2}
+)
+
+// newDurableTest returns a pair of connections from different clients that
+// share an open table, a lease table and a durable open table.
+func newDurableTest(t *testing.T) (a, b *createTest) {
+	a, b = newLeaseTest(t)
+	a.conn.Durable = smbserver.NewDurableTable()
+	b.conn.Durable = a.conn.Durable
+	return a, b
+}
+
+// reconnect returns a new connection from the client of ct that shares its
+// tables, as though the client had reconnected to the server.
+func (ct *createTest) reconnect() *createTest {
+	conn, transport := newTestConn(64)
+	conn.Opens = ct.conn.Opens
+	conn.Leases = ct.conn.Leases
+	conn.Durable = ct.conn.Durable
+	conn.ClientGUID = ct.conn.ClientGUID
+	return &createTest{t: ct.t, root: ct.root, conn: conn, transport: transport, fs: ct.fs}
+}
+
+// durableContext appends a serialized version 1 durable handle request
+// create context to list.
+func durableContext(list []byte) []byte {
+	return smbcreate.AppendContext(list, smbcreate.DurableHandleRequest, make([]byte, smbdurable.RequestSize))
+}
+
+// durableContextV2 appends a serialized version 2 durable handle request
+// create context to list.
+func durableContextV2(list []byte, guid smbid.ID, timeout time.Duration) []byte {
+	data := smbdurable.RequestV2(make([]byte, smbdurable.RequestV2Size))
+	data.SetTimeout(timeout)
+	data.SetCreateGUID(guid)
+	return smbcreate.AppendContext(list, smbcreate.DurableHandleRequestV2, data)
+}
+
+// reconnectContext appends a serialized version 1 durable handle reconnect
+// create context to list.
+func reconnectContext(list []byte, id smbfile.ID) []byte {
+	data := smbdurable.Reconnect(make([]byte, smbdurable.ReconnectSize))
+	data.SetFileID(id)
+	return smbcreate.AppendContext(list, smbcreate.DurableHandleReconnect, data)
+}
+
+// reconnectContextV2 appends a serialized version 2 durable handle
+// reconnect create context to list.
+func reconnectContextV2(list []byte, id smbfile.ID, guid smbid.ID) []byte {
+	data := smbdurable.ReconnectV2(make([]byte, smbdurable.ReconnectV2Size))
+	data.SetFileID(id)
+	data.SetCreateGUID(guid)
+	return smbcreate.AppendContext(list, smbcreate.DurableHandleReconnectV2, data)
+}
+
+// durableResponse returns the durable handle context with the given name in
+// a create response, or false if there is none.
+func (ct *createTest) durableResponse(response smbcreate.Response, name string) ([]byte, bool) {
+	ct.t.Helper()
+	contexts := smbcreate.ContextList(response.CreateContexts())
+	if len(contexts) > 0 && !contexts.Valid() {
+		ct.t.Fatal("create returned invalid create contexts")
+	}
+	c, ok := contexts.Find(name)
+	if !ok {
+		return nil, false
+	}
+	return c.Data(), true
+}
+
+func TestDurableGrant(t *testing.T) {
+	a, _ := newDurableTest(t)
+
+	// Opens without handle caching are not made durable
+	shared := a.checkCreate("level II open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.LevelII, Contexts: durableContext(nil)})), smbstatus.Success)
+	if _, ok := a.durableResponse(shared, smbcreate.DurableHandleRequest); ok {
+		t.Error("open with a level II oplock was made durable")
+	}
+	a.close(1, shared.FileID())
+
+	batch := a.checkCreate("batch open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Batch, Contexts: durableContext(nil)})), smbstatus.Success)
+	if data, ok := a.durableResponse(batch, smbcreate.DurableHandleRequest); !ok {
+		t.Error("open with a batch oplock was not made durable")
+	} else if len(data) != smbdurable.ResponseSize {
+		t.Errorf("durable handle response has %d bytes (want %d)", len(data), smbdurable.ResponseSize)
+	}
+	if n := a.conn.Durable.Len(); n != 1 {
+		t.Errorf("durable open table holds %d opens (want 1)", n)
+	}
+	a.close(1, batch.FileID())
+	if n := a.conn.Durable.Len(); n != 0 {
+		t.Errorf("durable open table holds %d opens after close (want 0)", n)
+	}
+
+	contexts := durableContextV2(leaseContext(leaseKey, rh, nil), createGUID, 30*time.Second)
+	leased := a.checkCreate("leased open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: contexts})), smbstatus.Success)
+	data, ok := a.durableResponse(leased, smbcreate.DurableHandleRequestV2)
+	if !ok {
+		t.Fatal("open with a handle caching lease was not made durable")
+	}
+	if timeout := smbdurable.ResponseV2(data).Timeout(); timeout != 30*time.Second {
+		t.Errorf("durable handle response has timeout %v (want 30s)", timeout)
+	}
+	if open := a.conn.Durable.LookupGUID(createGUID); open == nil || open.ID != leased.FileID() {
+		t.Error("durable open was not registered under its create GUID")
+	}
+
+	// Create GUIDs must be unique
+	a.create("duplicate create GUID", 1, createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Batch, Contexts: durableContextV2(nil, createGUID, 0)}, smbstatus.DuplicateObjectID)
+
+	// Version 1 and version 2 requests can't be combined
+	contexts = durableContextV2(durableContext(nil), otherGUID, 0)
+	a.create("conflicting contexts", 1, createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Batch, Contexts: contexts}, smbstatus.InvalidParameter)
+}
+
+func TestDurableReconnect(t *testing.T) {
+	a, b := newDurableTest(t)
+
+	contexts := durableContextV2(leaseContext(leaseKey, rh, nil), createGUID, 0)
+	spec := createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: contexts}
+	durable := a.checkCreate("durable open", a.send(1, smbcommand.Create, createBody(spec)), smbstatus.Success)
+	id := durable.FileID()
+	if s := a.send(1, smbcommand.Lock, lockBody(id, 0, 0, lockRange{Offset: 0, Length: 4, Flags: exclusiveImmediate})).Header().Status(); s != smbstatus.Success {
+		t.Fatalf("lock returned %s", s)
+	}
+	other := b.create("other open", 2, createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+
+	a.conn.Disconnect()
+	if n := a.conn.Durable.Disconnected(); n != 1 {
+		t.Fatalf("durable open table holds %d disconnected opens (want 1)", n)
+	}
+	if a.conn.Opens.Lookup(id) == nil {
+		t.Fatal("durable open was closed when its connection was lost")
+	}
+
+	// The locks of a disconnected open are preserved
+	if s := b.send(2, smbcommand.Lock, lockBody(other, 0, 0, lockRange{Offset: 0, Length: 4, Flags: exclusiveImmediate})).Header().Status(); s != smbstatus.LockNotGranted {
+		t.Errorf("conflicting lock returned %s while disconnected (want LockNotGranted)", s)
+	}
+
+	// Reconnects must identify the open and hold its lease
+	c := a.reconnect()
+	reconnect := func(desc string, contexts []byte, status smbstatus.Code) smbcreate.Response {
+		t.Helper()
+		return c.checkCreate(desc, c.send(3, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: contexts})), status)
+	}
+	reconnect("wrong create GUID", reconnectContextV2(leaseContext(leaseKey, rh, nil), id, otherGUID), smbstatus.ObjectNameNotFound)
+	reconnect("wrong lease key", reconnectContextV2(leaseContext(dirLeaseKey, rh, nil), id, createGUID), smbstatus.ObjectNameNotFound)
+	reconnect("missing lease", reconnectContextV2(nil, id, createGUID), smbstatus.ObjectNameNotFound)
+
+	reconnected := reconnect("reconnect", reconnectContextV2(leaseContext(leaseKey, rh, nil), id, createGUID), smbstatus.Success)
+	if got := reconnected.FileID(); got != id {
+		t.Errorf("reconnect returned file ID %v (want %v)", got, id)
+	}
+	if action := reconnected.CreateAction(); action != smbcreate.Opened {
+		t.Errorf("reconnect returned action %s (want Opened)", action)
+	}
+	if state := c.grantedLease(reconnected).State(); state != rh {
+		t.Errorf("reconnect returned lease state %s (want %s)", state, smblease.State(rh))
+	}
+	if n := c.conn.Durable.Disconnected(); n != 0 {
+		t.Errorf("durable open table holds %d disconnected opens after reconnect (want 0)", n)
+	}
+	reconnect("second reconnect", reconnectContextV2(leaseContext(leaseKey, rh, nil), id, createGUID), smbstatus.ObjectNameNotFound)
+
+	// The reconnected open still holds its lock and can release it
+	if s := c.send(3, smbcommand.Lock, lockBody(id, 0, 0, lockRange{Offset: 0, Length: 4, Flags: unlock})).Header().Status(); s != smbstatus.Success {
+		t.Errorf("unlock after reconnect returned %s", s)
+	}
+	if s := b.send(2, smbcommand.Lock, lockBody(other, 0, 0, lockRange{Offset: 0, Length: 4, Flags: exclusiveImmediate})).Header().Status(); s != smbstatus.Success {
+		t.Errorf("lock after unlock returned %s", s)
+	}
+}
+
+// testUsers are the users identified by userAuthenticator.
+var testUsers = map[string]smbsecurity.SID{
+	"alice": smbsecurity.NewSID(5, 21, 1001),
+	"bob":   smbsecurity.NewSID(5, 21, 1002),
+}
+
+// userAuthenticator carries out a single step exchange that identifies the
+// user named by the token.
+type userAuthenticator struct{}
+
+func (userAuthenticator) Begin() smbserver.AuthExchange {
+	return &userExchange{}
+}
+
+type userExchange struct {
+	user smbsecurity.SID
+}
+
+func (e *userExchange) Step(token []byte) ([]byte, bool, error) {
+	user, ok := testUsers[string(token)]
+	if !ok {
+		return nil, false, errors.New("unknown user")
+	}
+	e.user = user
+	return nil, true, nil
+}
+
+func (e *userExchange) SessionKey() []byte {
+	return make([]byte, smbsign.KeySize)
+}
+
+func (e *userExchange) Token() *smbsecurity.Token {
+	return &smbsecurity.Token{User: e.user, Groups: []smbsecurity.SID{smbsecurity.Everyone}}
+}
+
+// login establishes a session for the named user and returns its ID.
+func (ct *createTest) login(user string) uint64 {
+	ct.t.Helper()
+	if ct.conn.Sessions == nil {
+		ct.conn.Sessions = smbserver.NewSessionTable()
+		ct.conn.Authenticator = userAuthenticator{}
+	}
+	body := make([]byte, smbsession.RequestSize+len(user))
+	request := smbsession.Request(body)
+	request.SetSize(25)
+	copy(request.SetSecurityBufferLayout(len(user)), user)
+	response := ct.send(0, smbcommand.SessionSetup, body)
+	if s := response.Header().Status(); s != smbstatus.Success {
+		ct.t.Fatalf("session setup for %s returned %s", user, s)
+	}
+	return response.Header().SessionID()
+}
+
+func TestDurableOwner(t *testing.T) {
+	a, _ := newDurableTest(t)
+	alice := a.login("alice")
+
+	contexts := durableContextV2(leaseContext(leaseKey, rh, nil), createGUID, 0)
+	spec := createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: contexts}
+	id := a.checkCreate("durable open", a.send(alice, smbcommand.Create, createBody(spec)), smbstatus.Success).FileID()
+	a.conn.Disconnect()
+
+	// Only the user that made the open can reconnect to it
+	c := a.reconnect()
+	spec.Contexts = reconnectContextV2(leaseContext(leaseKey, rh, nil), id, createGUID)
+	c.checkCreate("reconnect by another user", c.send(c.login("bob"), smbcommand.Create, createBody(spec)), smbstatus.AccessDenied)
+	c.checkCreate("reconnect by the owner", c.send(c.login("alice"), smbcommand.Create, createBody(spec)), smbstatus.Success)
+}
+
+func TestDurableTimeout(t *testing.T) {
+	a, _ := newDurableTest(t)
+	a.conn.Durable.Timeout = 20 * time.Millisecond
+
+	id := a.create("durable open", 1, createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Batch, Contexts: durableContext(nil)}, smbstatus.Success)
+	a.conn.Disconnect()
+
+	waitFor(t, func() bool { return a.conn.Opens.Len() == 0 })
+	if n := a.conn.Durable.Len(); n != 0 {
+		t.Errorf("durable open table holds %d opens after the timeout (want 0)", n)
+	}
+
+	c := a.reconnect()
+	c.create("reconnect after timeout", 1, createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Batch, Contexts: reconnectContext(nil, id)}, smbstatus.ObjectNameNotFound)
+}
+
+func TestDisconnectClosesOpens(t *testing.T) {
+	a, b := newDurableTest(t)
+
+	a.create("open", 1, createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+	b.create("other open", 2, createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+
+	a.conn.Disconnect()
+	if n := a.conn.Opens.Len(); n != 1 {
+		t.Errorf("open table holds %d opens after disconnect (want 1)", n)
+	}
+	if n := a.conn.Durable.Len(); n != 0 {
+		t.Errorf("durable open table holds %d opens (want 0)", n)
+	}
+}
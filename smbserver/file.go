@@ -0,0 +1,167 @@
+package smbserver
+
+import (
+	"os"
+	"path"
+	"sync"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smboplock"
+)
+
+// sharedAccess is the set of access rights that are subject to share
+// access checks. Opens that have none of these rights don't conflict with
+// other opens.
+const sharedAccess = smbaccess.ReadData | smbaccess.WriteData | smbaccess.AppendData |
+	smbaccess.Execute | smbaccess.Delete
+
+// fileKey identifies a file that may be opened more than once. Files are
+// identified by their backend identity when the file system provides one,
+// and by name otherwise. Each alternate data stream of a file is
+// identified separately.
+type fileKey struct {
+	fs       smbfs.FileSystem
+	identity smbfs.Identity
+	name     string // Only set when the identity is unknown
+	stream   string // The alternate data stream of the file, if any
+}
+
+// sharedFile holds state that is shared by all opens of the same file,
+// such as share access, oplocks and the byte-range locks held on it.
+type sharedFile struct {
+	key fileKey
+
+	mutex         sync.Mutex
+	opens         map[*Open]struct{}
+	deletePending bool   // True once the file is to be deleted
+	deleteName    string // The name to be removed when the last open closes
+	locks         []byteRangeLock
+	waiters       []chan struct{} // Lock requests waiting for locks to be released
+}
+
+// attach adds o to the set of opens of the file. It returns
+// ErrDeletePending if the file is about to be deleted, or
+// ErrSharingViolation if the access or share access of o conflicts with an
+// existing open.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fsa/ section 2.1.5.1.2
+func (f *sharedFile) attach(o *Open) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.deletePending {
+		return ErrDeletePending
+	}
+	for other := range f.opens {
+		if shareConflict(o, other) {
+			return ErrSharingViolation
+		}
+	}
+
+	f.opens[o] = struct{}{}
+	return nil
+}
+
+// detach removes o from the set of opens of the file and releases its
+// byte-range locks. Any oplock break in progress for o is completed, and o
+// is removed from its lease. It returns the number of opens that remain.
+//
+// If o was opened with delete-on-close semantics the file becomes delete
+// pending. When the last open of a delete pending file is detached, the
+// name that should be removed from the file system is returned.
+func (f *sharedFile) detach(o *Open) (remaining int, remove string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.opens, o)
+	f.releaseLocks(o)
+	f.completeBreak(o, smboplock.None)
+	f.releaseLease(o)
+	if o.DeleteOnClose && !f.deletePending {
+		f.deletePending = true
+		f.deleteName = o.Name
+	}
+	if len(f.opens) == 0 && f.deletePending {
+		remove = f.deleteName
+	}
+	return len(f.opens), remove
+}
+
+// attached returns true if o is an open of the file.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) attached(o *Open) bool {
+	_, ok := f.opens[o]
+	return ok
+}
+
+// shareConflict returns true if the access or share access of o conflicts
+// with that of other.
+func shareConflict(o, other *Open) bool {
+	if !o.GrantedAccess.Any(sharedAccess) || !other.GrantedAccess.Any(sharedAccess) {
+		return false
+	}
+	return accessDenied(o.GrantedAccess, other.ShareAccess) || accessDenied(other.GrantedAccess, o.ShareAccess)
+}
+
+// accessDenied returns true if access includes rights that aren't permitted
+// by share.
+func accessDenied(access smbaccess.Mask, share smbcreate.ShareAccess) bool {
+	switch {
+	case access.Any(smbaccess.ReadData|smbaccess.Execute) && !share.Match(smbcreate.ShareRead):
+		return true
+	case access.Any(smbaccess.WriteData|smbaccess.AppendData) && !share.Match(smbcreate.ShareWrite):
+		return true
+	case access.Any(smbaccess.Delete) && !share.Match(smbcreate.ShareDelete):
+		return true
+	default:
+		return false
+	}
+}
+
+// file returns the shared state for the file identified by key, creating
+// it if necessary.
+//
+// The caller must hold a lock on t.mutex.
+func (t *OpenTable) file(key fileKey) *sharedFile {
+	f, ok := t.files[key]
+	if !ok {
+		f = &sharedFile{
+			key:   key,
+			opens: make(map[*Open]struct{}),
+		}
+		t.files[key] = f
+	}
+	return f
+}
+
+// keyFor returns the key of the file referred to by o. The file is
+// identified by its backend identity if the open has an underlying file
+// and its file system implements smbfs.Identifier.
+func keyFor(o *Open) fileKey {
+	var info os.FileInfo
+	if o.File != nil {
+		info, _ = o.File.Stat()
+	}
+	key := fileKeyOf(o.FS, o.Name, info)
+	key.stream = o.Stream
+	return key
+}
+
+// fileKeyOf returns the key of the file with the given name and
+// information within fsys. The information may be nil. Files opened
+// through views of a file system have the same keys as those opened
+// through the file system itself.
+func fileKeyOf(fsys smbfs.FileSystem, name string, info os.FileInfo) fileKey {
+	base := fsys
+	if view, ok := fsys.(smbfs.View); ok {
+		base = view.Base()
+	}
+	if identifier, ok := fsys.(smbfs.Identifier); ok && info != nil {
+		if identity, ok := identifier.Identify(info); ok {
+			return fileKey{fs: base, identity: identity}
+		}
+	}
+	return fileKey{fs: base, name: path.Clean(name)}
+}
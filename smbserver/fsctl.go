@@ -0,0 +1,99 @@
+package smbserver
+
+import (
+	"sync"
+
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+)
+
+// FSCTL is a file system control request carried by an SMB2 IOCTL request.
+type FSCTL struct {
+	Code smbioctl.Code
+
+	// FileID is the file ID of the request, resolved for related
+	// operations. Requests that don't apply to a file carry
+	// 0xFFFFFFFFFFFFFFFF in both halves.
+	FileID smbfile.ID
+
+	Input     []byte
+	MaxOutput uint32 // Maximum number of bytes of output
+}
+
+// An FSCTLHandler handles file system control requests.
+//
+// ServeFSCTL returns the response that should be sent to the client,
+// which is usually an smbproto.IoctlResponse. If it returns nil no
+// response is sent for the request.
+type FSCTLHandler interface {
+	ServeFSCTL(c *Conn, r *Request, ctl FSCTL) Response
+}
+
+// FSCTLHandlerFunc is a function that can act as an FSCTLHandler.
+type FSCTLHandlerFunc func(c *Conn, r *Request, ctl FSCTL) Response
+
+// ServeFSCTL handles the given file system control request.
+func (h FSCTLHandlerFunc) ServeFSCTL(c *Conn, r *Request, ctl FSCTL) Response {
+	return h(c, r, ctl)
+}
+
+// FSCTLRegistry maps control codes to the handlers of file system control
+// requests. It must be created with NewFSCTLRegistry.
+type FSCTLRegistry struct {
+	mutex    sync.RWMutex
+	handlers map[smbioctl.Code]FSCTLHandler
+}
+
+// NewFSCTLRegistry returns a registry that holds the handlers of the
+// control codes that are built into the server. Additional handlers can be
+// registered, and built-in handlers can be replaced.
+func NewFSCTLRegistry() *FSCTLRegistry {
+	reg := &FSCTLRegistry{
+		handlers: make(map[smbioctl.Code]FSCTLHandler),
+	}
+	reg.Register(smbioctl.GetReparsePoint, FSCTLHandlerFunc((*Conn).getReparsePoint))
+	reg.Register(smbioctl.SetReparsePoint, FSCTLHandlerFunc((*Conn).setReparsePoint))
+	reg.Register(smbioctl.SetSparse, FSCTLHandlerFunc((*Conn).setSparse))
+	reg.Register(smbioctl.SetZeroData, FSCTLHandlerFunc((*Conn).setZeroData))
+	reg.Register(smbioctl.QueryAllocatedRanges, FSCTLHandlerFunc((*Conn).queryAllocatedRanges))
+	reg.Register(smbioctl.PipeTransceive, FSCTLHandlerFunc((*Conn).pipeTransceive))
+	reg.Register(smbioctl.PipePeek, FSCTLHandlerFunc((*Conn).pipePeek))
+	reg.Register(smbioctl.PipeWait, FSCTLHandlerFunc((*Conn).pipeWait))
+	reg.Register(smbioctl.SrvEnumerateSnapshots, FSCTLHandlerFunc((*Conn).enumerateSnapshots))
+	reg.Register(smbioctl.SrvRequestResumeKey, FSCTLHandlerFunc((*Conn).requestResumeKey))
+	reg.Register(smbioctl.SrvCopyChunk, FSCTLHandlerFunc((*Conn).copyChunk))
+	reg.Register(smbioctl.SrvCopyChunkWrite, FSCTLHandlerFunc((*Conn).copyChunk))
+	reg.Register(smbioctl.QueryNetworkInterfaceInfo, FSCTLHandlerFunc((*Conn).queryNetworkInterfaceInfo))
+	reg.Register(smbioctl.ValidateNegotiateInfo, FSCTLHandlerFunc((*Conn).validateNegotiateInfo))
+	return reg
+}
+
+// Register causes h to handle requests with the given control code. If h
+// is nil the control code is no longer handled.
+func (reg *FSCTLRegistry) Register(code smbioctl.Code, h FSCTLHandler) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	if h == nil {
+		delete(reg.handlers, code)
+		return
+	}
+	reg.handlers[code] = h
+}
+
+// Handler returns the handler for the given control code, or nil.
+func (reg *FSCTLRegistry) Handler(code smbioctl.Code) FSCTLHandler {
+	reg.mutex.RLock()
+	defer reg.mutex.RUnlock()
+	return reg.handlers[code]
+}
+
+// builtinFSCTLs is used by connections that don't have a registry.
+var builtinFSCTLs = NewFSCTLRegistry()
+
+// fsctls returns the registry of the connection.
+func (c *Conn) fsctls() *FSCTLRegistry {
+	if c.FSCTLs != nil {
+		return c.FSCTLs
+	}
+	return builtinFSCTLs
+}
@@ -0,0 +1,161 @@
+package smbserver_test
+
+import (
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbcap"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbsecmode"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbvalidate"
+)
+
+// validateInput returns the input of a validate negotiate info request.
+func validateInput(caps smbcap.Flags, client smbid.ID, mode smbsecmode.Flags, dialects ...smbdialect.Revision) []byte {
+	input := make([]byte, smbvalidate.RequestSize+len(dialects)*2)
+	request := smbvalidate.Request(input)
+	request.SetCapabilities(caps)
+	request.SetClientID(client)
+	request.SetSecurityMode(mode)
+	request.SetDialectCount(uint16(len(dialects)))
+	for i, dialect := range dialects {
+		request.Dialects().SetMember(i, dialect)
+	}
+	return input
+}
+
+func TestFSCTLRegistry(t *testing.T) {
+	const custom = smbioctl.Code(0x00090000)
+
+	conn, transport := newTestConn(8)
+	conn.FSCTLs = smbserver.NewFSCTLRegistry()
+	conn.FSCTLs.Register(custom, smbserver.FSCTLHandlerFunc(func(c *smbserver.Conn, r *smbserver.Request, ctl smbserver.FSCTL) smbserver.Response {
+		return smbproto.IoctlResponse{CtlCode: ctl.Code, FileID: ctl.FileID, Output: append([]byte("echo:"), ctl.Input...)}
+	}))
+
+	id := smbfile.ID{Persistent: 1, Volatile: 2}
+	packet := ioctl(t, conn, transport, 0, ioctlBody(custom, id, []byte("ping"), 64))
+	if s := packet.Header().Status(); s != smbstatus.Success {
+		t.Fatalf("custom FSCTL returned %s", s)
+	}
+	response := smbioctl.Response(packet.Data())
+	if !response.Valid() || response.FileID() != id || string(response.Output()) != "echo:ping" {
+		t.Fatalf("custom FSCTL returned %q for %s", response.Output(), response.FileID())
+	}
+
+	// Built-in handlers are present
+	if conn.FSCTLs.Handler(smbioctl.ValidateNegotiateInfo) == nil {
+		t.Errorf("registry lacks a built-in handler")
+	}
+
+	// Unregistered control codes aren't supported
+	conn.FSCTLs.Register(custom, nil)
+	packet = ioctl(t, conn, transport, 1, ioctlBody(custom, id, nil, 64))
+	if s := packet.Header().Status(); s != smbstatus.InvalidDeviceRequest {
+		t.Errorf("unregistered FSCTL returned %s", s)
+	}
+
+	// Requests must be file system control requests
+	body := ioctlBody(custom, id, nil, 64)
+	smbioctl.Request(body).SetFlags(0)
+	packet = ioctl(t, conn, transport, 2, body)
+	if s := packet.Header().Status(); s != smbstatus.NotSupported {
+		t.Errorf("device control request returned %s", s)
+	}
+}
+
+func TestValidateNegotiateInfo(t *testing.T) {
+	const (
+		caps = smbcap.DFS | smbcap.LargeMTU
+		mode = smbsecmode.SigningEnabled
+	)
+	client := smbid.ID{7, 7, 7}
+
+	newConn := func() (*smbserver.Conn, *testTransport) {
+		conn, transport := newTestConn(8)
+		conn.Dialect = smbdialect.SMB302
+		conn.Server = smbid.ID{9, 9, 9}
+		conn.ClientGUID = client
+		conn.ClientCapabilities = caps
+		conn.ClientSecurity = mode
+		return conn, transport
+	}
+
+	conn, transport := newConn()
+	input := validateInput(caps, client, mode, smbdialect.SMB202, smbdialect.SMB21, smbdialect.SMB3, smbdialect.SMB302)
+	packet := ioctl(t, conn, transport, 0, ioctlBody(smbioctl.ValidateNegotiateInfo, smbfile.RelatedID, input, 64))
+	if s := packet.Header().Status(); s != smbstatus.Success {
+		t.Fatalf("validation returned %s", s)
+	}
+	response := smbvalidate.Response(smbioctl.Response(packet.Data()).Output())
+	if !response.Valid() {
+		t.Fatal("validation returned an invalid response")
+	}
+	if response.ServerID() != conn.Server || response.Dialect() != smbdialect.SMB302 ||
+		response.Capabilities() != conn.Capabilities() || response.SecurityMode() != conn.SecurityMode() {
+		t.Errorf("validation returned server %s, dialect %s, capabilities %s, security mode %s",
+			response.ServerID(), response.Dialect(), response.Capabilities(), response.SecurityMode())
+	}
+
+	// The output must fit within the client's limit
+	packet = ioctl(t, conn, transport, 1, ioctlBody(smbioctl.ValidateNegotiateInfo, smbfile.RelatedID, input, smbvalidate.ResponseSize-1))
+	if s := packet.Header().Status(); s != smbstatus.InvalidParameter {
+		t.Errorf("validation with small output limit returned %s", s)
+	}
+
+	// Tampered negotiations cause the connection to be closed
+	tampered := []struct {
+		desc  string
+		input []byte
+	}{
+		{"capabilities", validateInput(caps|smbcap.Encryption, client, mode, smbdialect.SMB302)},
+		{"client GUID", validateInput(caps, smbid.ID{8}, mode, smbdialect.SMB302)},
+		{"security mode", validateInput(caps, client, mode|smbsecmode.SigningRequired, smbdialect.SMB302)},
+		{"dialects", validateInput(caps, client, mode, smbdialect.SMB302, smbdialect.SMB311)},
+	}
+	for _, test := range tampered {
+		conn, transport := newConn()
+		r := testRequest{Command: smbcommand.IOCTL, MessageID: 0, SessionID: 1, TreeID: 1, Body: ioctlBody(smbioctl.ValidateNegotiateInfo, smbfile.RelatedID, test.input, 64)}
+		if err := conn.Process(makeMessage(r), smbserver.CommandHandlerFunc(func(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+			return c.Ioctl(r)
+		})); err != nil {
+			t.Fatalf("%s: Process returned %v", test.desc, err)
+		}
+		if !transport.closed.Load() {
+			t.Errorf("%s: connection wasn't closed", test.desc)
+		}
+		select {
+		case <-transport.sent:
+			t.Errorf("%s: response was sent", test.desc)
+		default:
+		}
+	}
+}
+
+func TestValidateNegotiateInfoSigned(t *testing.T) {
+	st := newSessionTest(t, smbdialect.SMB302)
+	a := st.dial()
+	st.tables()
+	a.authenticate(0, nil, []byte("0123456789abcdef"))
+
+	input := validateInput(0, testClientGUID, 0, smbdialect.SMB3, smbdialect.SMB302)
+	body := ioctlBody(smbioctl.ValidateNegotiateInfo, smbfile.RelatedID, input, 64)
+
+	// Unsigned requests are rejected
+	key := a.key
+	a.key = nil
+	a.expect("unsigned validation", smbcommand.IOCTL, body, smbstatus.AccessDenied)
+	a.key = key
+
+	// Signed requests receive signed responses
+	response := a.expect("signed validation", smbcommand.IOCTL, body, smbstatus.Success)
+	if dialect := smbvalidate.Response(smbioctl.Response(response.Data()).Output()).Dialect(); dialect != smbdialect.SMB302 {
+		t.Errorf("validation returned dialect %s", dialect)
+	}
+}
@@ -1,6 +1,11 @@
 package smbserver
 
-import "github.com/gentlemanautomaton/smb/smbid"
+import (
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbstore"
+)
 
 // GlobalState stores global information about the server.
 type GlobalState struct {
@@ -8,4 +13,28 @@ type GlobalState struct {
 	RequireMessageSigning bool
 	EncryptionSupported   bool
 	CompressionSupported  bool
+	Opens                 *OpenTable
+	Leases                *LeaseTable    // Leasing is disabled if nil
+	Durable               *DurableTable  // Durable handles are disabled if nil
+	Store                 smbstore.Store // Persistent handles are disabled if nil
+	Sessions              *SessionTable  // Sessions are disabled if nil
+	Authenticator         Authenticator
+	Interfaces            InterfaceFunc  // LocalInterfaces is used if nil
+	FSCTLs                *FSCTLRegistry // Built-in handlers are used if nil
+	Shares                *ShareTable    // Tree connects fail if nil
+	Trees                 *TreeTable     // Tree connects fail if nil
+	Pipes                 *PipeRegistry  // Named pipes are unavailable if nil
+
+	// POSIXExtensionsSupported causes the server to offer the SMB3 POSIX
+	// extensions to SMB 3.1.1 clients that ask for them.
+	POSIXExtensionsSupported bool
+
+	// OplockBreakTimeout is the amount of time to wait for a client to
+	// acknowledge an oplock break before the oplock is revoked. If zero,
+	// DefaultOplockBreakTimeout is used.
+	OplockBreakTimeout time.Duration
+
+	// CopyChunkLimits limits server-side copies. If zero,
+	// DefaultCopyChunkLimits is used.
+	CopyChunkLimits CopyChunkLimits
 }
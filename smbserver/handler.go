@@ -12,3 +12,19 @@ type HandlerFunc func(c Conn)
 func (h HandlerFunc) ServeSMB(c Conn) {
 	h(c)
 }
+
+// A CommandHandler handles individual SMB requests.
+//
+// ServeCommand returns the response that should be sent to the client. If
+// it returns nil no response is sent for the request.
+type CommandHandler interface {
+	ServeCommand(c *Conn, r *Request) Response
+}
+
+// CommandHandlerFunc is a function that can act as a CommandHandler.
+type CommandHandlerFunc func(c *Conn, r *Request) Response
+
+// ServeCommand handles the given SMB request.
+func (h CommandHandlerFunc) ServeCommand(c *Conn, r *Request) Response {
+	return h(c, r)
+}
@@ -0,0 +1,103 @@
+package smbserver
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbinfo"
+	"github.com/gentlemanautomaton/smb/smbposix"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbstream"
+)
+
+// QueryInfo processes an SMB2 QUERY_INFO request. It returns information
+// about the file referred to by the request. Only security information,
+// stream information, and POSIX file information on connections that
+// negotiated the SMB3 POSIX extensions, are supported; queries of other
+// types of information fail with STATUS_NOT_SUPPORTED.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.20
+func (c *Conn) QueryInfo(r *Request) Response {
+	request := smbinfo.QueryRequest(r.Data())
+	if !request.Valid() {
+		return queryInfoError(smbstatus.InvalidParameter)
+	}
+	if max := c.MaxTransactSize; max > 0 && (request.OutputBufferLength() > max || request.InputLength() > max) {
+		return queryInfoError(smbstatus.InvalidParameter)
+	}
+
+	id, ok := r.ResolveFileID(request.FileID())
+	if !ok {
+		return queryInfoError(smbstatus.InvalidParameter)
+	}
+	open := c.lookupOpen(r, id)
+	if open == nil {
+		return queryInfoError(smbstatus.FileClosed)
+	}
+	r.SetFileID(id)
+
+	switch request.InfoType() {
+	case smbinfo.Security:
+		return c.querySecurity(open, request)
+	case smbinfo.File:
+		switch {
+		case c.POSIXExtensions && request.FileInfoClass() == smbposix.InfoClass:
+			return c.queryPOSIX(open, request)
+		case request.FileInfoClass() == smbstream.InfoClass:
+			return c.queryStreams(open, request)
+		}
+		return queryInfoError(smbstatus.NotSupported)
+	case smbinfo.FileSystem, smbinfo.Quota:
+		return queryInfoError(smbstatus.NotSupported)
+	default:
+		return queryInfoError(smbstatus.InvalidParameter)
+	}
+}
+
+// SetInfo processes an SMB2 SET_INFO request. It changes information about
+// the file referred to by the request. Only security information is
+// supported; requests to set other types of information fail with
+// STATUS_NOT_SUPPORTED.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.21
+func (c *Conn) SetInfo(r *Request) Response {
+	request := smbinfo.SetRequest(r.Data())
+	if !request.Valid() {
+		return setInfoError(smbstatus.InvalidParameter)
+	}
+	if max := c.MaxTransactSize; max > 0 && request.BufferLength() > max {
+		return setInfoError(smbstatus.InvalidParameter)
+	}
+
+	id, ok := r.ResolveFileID(request.FileID())
+	if !ok {
+		return setInfoError(smbstatus.InvalidParameter)
+	}
+	open := c.lookupOpen(r, id)
+	if open == nil {
+		return setInfoError(smbstatus.FileClosed)
+	}
+	r.SetFileID(id)
+
+	done, ok := c.verifyChannelSequence(r, open)
+	if !ok {
+		return setInfoError(smbstatus.FileNotAvailable)
+	}
+	defer done()
+
+	switch request.InfoType() {
+	case smbinfo.Security:
+		return c.setSecurity(open, request)
+	case smbinfo.File, smbinfo.FileSystem, smbinfo.Quota:
+		return setInfoError(smbstatus.NotSupported)
+	default:
+		return setInfoError(smbstatus.InvalidParameter)
+	}
+}
+
+func queryInfoError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.QueryInfo, Code: code}
+}
+
+func setInfoError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.SetInfo, Code: code}
+}
@@ -0,0 +1,59 @@
+package smbserver
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// Ioctl processes an SMB2 IOCTL request. Only file system control requests
+// are supported. Each request is passed to the handler registered for its
+// control code in the connection's FSCTL registry, or in a registry of the
+// built-in handlers if the connection doesn't have one.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.15
+func (c *Conn) Ioctl(r *Request) Response {
+	request := smbioctl.Request(r.Data())
+	if !request.Valid() {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	if !request.Flags().Match(smbioctl.IsFSCTL) {
+		return ioctlError(smbstatus.NotSupported)
+	}
+	if max := c.MaxTransactSize; max > 0 {
+		if request.InputCount() > max || request.MaxInputResponse() > max || request.MaxOutputResponse() > max {
+			return ioctlError(smbstatus.InvalidParameter)
+		}
+	}
+
+	id, ok := r.ResolveFileID(request.FileID())
+	if !ok {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+
+	// Control requests that don't refer to an open, such as those sent with
+	// a file ID of all ones, aren't subject to channel sequence checks
+	if open := c.lookupOpen(r, id); open != nil {
+		done, ok := c.verifyChannelSequence(r, open)
+		if !ok {
+			return ioctlError(smbstatus.FileNotAvailable)
+		}
+		defer done()
+	}
+
+	h := c.fsctls().Handler(request.CtlCode())
+	if h == nil {
+		return ioctlError(smbstatus.InvalidDeviceRequest)
+	}
+	return h.ServeFSCTL(c, r, FSCTL{
+		Code:      request.CtlCode(),
+		FileID:    id,
+		Input:     request.Input(),
+		MaxOutput: request.MaxOutputResponse(),
+	})
+}
+
+func ioctlError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.IOCTL, Code: code}
+}
@@ -0,0 +1,513 @@
+package smbserver
+
+import (
+	"errors"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbcap"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smblease"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// errLeaseKeyInUse is returned when a client requests a lease with a lease
+// key that it already uses for a different file.
+var errLeaseKeyInUse = errors.New("smbserver: the lease key is in use for a different file")
+
+// leaseID identifies a lease by the client that holds it and its lease key.
+type leaseID struct {
+	client smbid.ID
+	key    smbid.ID
+}
+
+// Lease represents a lease held by a client on a file or directory. All
+// opens of a file made by the same client with the same lease key share a
+// single lease.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.1.13
+type Lease struct {
+	Client    smbid.ID // The GUID of the client that holds the lease
+	Key       smbid.ID
+	ParentKey smbid.ID // The lease key of the parent directory, if HasParent
+	HasParent bool
+	Version   int // 1 or 2
+
+	table *LeaseTable
+	file  *sharedFile
+
+	// The remaining fields are protected by file.mutex
+	opens    map[*Open]struct{}
+	conn     *Conn // The connection that receives break notifications
+	state    smblease.State
+	epoch    uint16
+	breaking bool           // True while waiting for an acknowledgment
+	notified smblease.State // The state sent in the last break notification
+	breakTo  smblease.State // The state the lease is being broken to
+	done     chan struct{}  // Closed when the break completes
+	timer    *time.Timer    // Revokes the lease if the break isn't acknowledged
+}
+
+// State returns the caching state of the lease. While a lease break is in
+// progress it returns the state held before the break.
+func (l *Lease) State() smblease.State {
+	l.file.mutex.Lock()
+	defer l.file.mutex.Unlock()
+	return l.state
+}
+
+// Epoch returns the epoch of the lease, which is incremented each time the
+// state of the lease changes.
+func (l *Lease) Epoch() uint16 {
+	l.file.mutex.Lock()
+	defer l.file.mutex.Unlock()
+	return l.epoch
+}
+
+// LeaseTable holds the leases granted by the server, keyed by client GUID
+// and lease key. It holds values for the Server.GlobalLeaseTableList
+// variable in the SMB protocol. It must be created with NewLeaseTable.
+type LeaseTable struct {
+	mutex  sync.Mutex
+	leases map[leaseID]*Lease
+}
+
+// NewLeaseTable returns an empty lease table that is ready for use.
+func NewLeaseTable() *LeaseTable {
+	return &LeaseTable{leases: make(map[leaseID]*Lease)}
+}
+
+// Lookup returns the lease held by client with the given lease key, or nil.
+func (t *LeaseTable) Lookup(client, key smbid.ID) *Lease {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.leases[leaseID{client: client, key: key}]
+}
+
+// Len returns the number of leases in the table.
+func (t *LeaseTable) Len() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.leases)
+}
+
+// acquire returns the lease with the given ID for f, creating it if
+// necessary. It returns errLeaseKeyInUse if the lease exists for another
+// file.
+//
+// The caller must hold a lock on f.mutex.
+func (t *LeaseTable) acquire(id leaseID, f *sharedFile) (l *Lease, created bool, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if l = t.leases[id]; l != nil {
+		if l.file != f {
+			return nil, false, errLeaseKeyInUse
+		}
+		return l, false, nil
+	}
+	l = &Lease{
+		Client: id.client,
+		Key:    id.key,
+		table:  t,
+		file:   f,
+		opens:  make(map[*Open]struct{}),
+	}
+	t.leases[id] = l
+	return l, true, nil
+}
+
+// remove removes l from the table.
+func (t *LeaseTable) remove(l *Lease) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	id := leaseID{client: l.Client, key: l.Key}
+	if t.leases[id] == l {
+		delete(t.leases, id)
+	}
+}
+
+// Capabilities returns the capabilities that the server advertises to the
+// client during negotiation of the connection's dialect.
+func (c *Conn) Capabilities() smbcap.Flags {
+	var caps smbcap.Flags
+	if c.Leases != nil && c.Dialect != smbdialect.Uninitialized && c.Dialect != smbdialect.SMB202 {
+		// The wildcard dialect is only offered to clients that support
+		// SMB 2.1 or later
+		caps |= smbcap.Leasing
+		if c.directoryLeasing() {
+			caps |= smbcap.DirectoryLeasing
+		}
+	}
+	if c.persistentHandles() {
+		caps |= smbcap.PersistentHandles
+	}
+	if c.multiChannel() {
+		caps |= smbcap.MultiChannel
+	}
+	return caps
+}
+
+// leasing returns true if leases can be granted on the connection. Leases
+// require SMB 2.1 or later.
+func (c *Conn) leasing() bool {
+	return c.Leases != nil && c.Dialect.Ready() && c.Dialect.Revision() >= smbdialect.SMB21
+}
+
+// directoryLeasing returns true if leases on directories can be granted on
+// the connection. Directory leases require SMB 3.0 or later.
+func (c *Conn) directoryLeasing() bool {
+	return c.Leases != nil && c.Dialect.Ready() && c.Dialect.Revision() >= smbdialect.SMB3
+}
+
+// leaseRequest holds the lease requested by a create request.
+type leaseRequest struct {
+	key       smbid.ID
+	state     smblease.State
+	parentKey smbid.ID
+	hasParent bool
+	epoch     uint16
+	v2        bool
+}
+
+// parseLeaseRequest returns the lease requested by the lease create context
+// in contexts, or nil if a lease wasn't requested. Version 2 leases are only
+// recognized on SMB 3.x connections.
+func (c *Conn) parseLeaseRequest(contexts smbcreate.ContextList) *leaseRequest {
+	ctx, ok := contexts.Find(smbcreate.RequestLease)
+	if !ok {
+		return nil
+	}
+	data := smblease.Context(ctx.Data())
+	if !data.Valid() {
+		return nil
+	}
+	req := &leaseRequest{
+		key:   data.Key(),
+		state: data.State(),
+	}
+	if data.V2() && c.Dialect.Revision() >= smbdialect.SMB3 {
+		req.v2 = true
+		req.epoch = data.Epoch()
+		if data.Flags().Match(smblease.ParentKeySet) {
+			req.parentKey = data.ParentKey()
+			req.hasParent = true
+		}
+	}
+	return req
+}
+
+// id returns the ID of the lease requested by a client.
+func (req *leaseRequest) id(client smbid.ID) leaseID {
+	return leaseID{client: client, key: req.key}
+}
+
+// grantLease adds o to the lease requested by req, creating the lease if
+// necessary, and grants as much of the requested caching state as the other
+// opens of the file permit. A lease is never downgraded by a create. It
+// returns the lease create context for the create response.
+func (f *sharedFile) grantLease(o *Open, c *Conn, req *leaseRequest) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	l, created, err := c.Leases.acquire(req.id(c.ClientGUID), f)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		l.Version = 1
+		if req.v2 {
+			l.Version = 2
+			l.ParentKey, l.HasParent = req.parentKey, req.hasParent
+			l.epoch = req.epoch
+		}
+	}
+	l.opens[o] = struct{}{}
+	l.conn = c
+	o.lease = l
+
+	requested := req.state
+	if o.Directory {
+		requested &^= smblease.WriteCaching
+	}
+	if !requested.Valid() {
+		requested &= smblease.ReadCaching
+	}
+
+	if granted := l.state | requested; !l.breaking && granted != l.state {
+		for other := range f.opens {
+			if other.lease == l {
+				continue
+			}
+			switch {
+			case other.oplock.level.Exclusive() || other.oplock.breaking:
+				granted = l.state
+			case other.lease != nil && (other.lease.state.Match(smblease.WriteCaching) || other.lease.breaking):
+				granted = l.state
+			default:
+				// Write caching requires that every open of the file
+				// belongs to the lease
+				granted &^= smblease.WriteCaching
+			}
+		}
+		if granted |= l.state; granted != l.state {
+			l.state = granted
+			l.epoch++
+		}
+	}
+
+	return l.responseContext(), nil
+}
+
+// responseContext returns the lease create context that describes l in a
+// create response.
+//
+// The caller must hold a lock on l.file.mutex.
+func (l *Lease) responseContext() []byte {
+	size := smblease.ContextSize
+	if l.Version == 2 {
+		size = smblease.ContextV2Size
+	}
+	data := smblease.Context(make([]byte, size))
+	data.SetKey(l.Key)
+	data.SetState(l.state)
+	var flags smblease.Flags
+	if l.breaking {
+		flags |= smblease.BreakInProgress
+	}
+	if l.Version == 2 {
+		if l.HasParent {
+			flags |= smblease.ParentKeySet
+			data.SetParentKey(l.ParentKey)
+		}
+		data.SetEpoch(l.epoch)
+	}
+	data.SetFlags(flags)
+	return smbcreate.AppendContext(nil, smbcreate.RequestLease, data)
+}
+
+// leases returns the leases held on the file, excluding the lease with the
+// given ID.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) leases(except *leaseID) []*Lease {
+	var leases []*Lease
+	seen := make(map[*Lease]bool)
+	for o := range f.opens {
+		l := o.lease
+		if l == nil || seen[l] {
+			continue
+		}
+		seen[l] = true
+		if except != nil && l.Client == except.client && l.Key == except.key {
+			continue
+		}
+		leases = append(leases, l)
+	}
+	return leases
+}
+
+// breakHandleCaching breaks the handle caching state of the leases held on
+// the file identified by key, excluding the lease with the given ID. It is
+// called when a create fails with a sharing violation so that clients
+// caching handles have a chance to close them.
+//
+// It returns a channel that is closed when the breaks have completed, or
+// nil if there is nothing to wait for.
+func (t *OpenTable) breakHandleCaching(key fileKey, except *leaseID, timeout time.Duration) <-chan struct{} {
+	t.mutex.RLock()
+	f := t.files[key]
+	t.mutex.RUnlock()
+	if f == nil {
+		return nil
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var wait <-chan struct{}
+	for _, l := range f.leases(except) {
+		if !l.state.Match(smblease.HandleCaching) {
+			continue
+		}
+		if done := f.breakLease(l, l.state&^smblease.HandleCaching, timeout); done != nil {
+			wait = done
+		}
+	}
+	return wait
+}
+
+// breakParentLeases breaks the leases held on the parent directory of the
+// file with the given name within fsys after the contents of the directory
+// have changed. The lease of the client that made the change is excluded if
+// changer names it as its parent lease.
+func (t *OpenTable) breakParentLeases(fsys smbfs.FileSystem, name string, changer *Lease, timeout time.Duration) {
+	dir := path.Dir(name)
+	info, err := fsys.Stat(dir)
+	if err != nil {
+		return
+	}
+	key := fileKeyOf(fsys, dir, info)
+
+	t.mutex.RLock()
+	f := t.files[key]
+	t.mutex.RUnlock()
+	if f == nil {
+		return
+	}
+
+	var except *leaseID
+	if changer != nil && changer.HasParent {
+		except = &leaseID{client: changer.Client, key: changer.ParentKey}
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, l := range f.leases(except) {
+		f.breakLease(l, smblease.None, timeout)
+	}
+}
+
+// breakLease breaks the lease l to the given state and notifies its client.
+// Breaks of leases that hold more than read caching require acknowledgment
+// and return a channel that is closed when the break completes. If the
+// break isn't acknowledged within timeout the lease is revoked.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) breakLease(l *Lease, to smblease.State, timeout time.Duration) <-chan struct{} {
+	if l.breaking {
+		l.breakTo &= to
+		return l.done
+	}
+	to &= l.state
+	if to == l.state {
+		return nil
+	}
+
+	current := l.state
+	l.epoch++
+	if current == smblease.ReadCaching {
+		// Breaks from read caching don't require acknowledgment
+		l.state = to
+		l.notifyBreak(current, to, 0)
+		return nil
+	}
+
+	done := make(chan struct{})
+	l.breaking = true
+	l.notified = to
+	l.breakTo = to
+	l.done = done
+	l.timer = time.AfterFunc(timeout, func() {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if l.breaking && l.done == done {
+			f.completeLeaseBreak(l, smblease.None)
+		}
+	})
+	l.notifyBreak(current, to, smblease.AckRequired)
+	return done
+}
+
+// completeLeaseBreak finishes a lease break in progress for l, leaving it
+// with the given state.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) completeLeaseBreak(l *Lease, state smblease.State) {
+	l.state = state
+	if !l.breaking {
+		return
+	}
+	l.breaking = false
+	l.timer.Stop()
+	close(l.done)
+	l.done = nil
+	l.timer = nil
+}
+
+// releaseLease removes o from its lease. When the last open of a lease is
+// released the lease is removed from its table and any break in progress
+// is completed.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) releaseLease(o *Open) {
+	l := o.lease
+	if l == nil {
+		return
+	}
+	delete(l.opens, o)
+	if len(l.opens) == 0 {
+		f.completeLeaseBreak(l, smblease.None)
+		l.table.remove(l)
+	}
+}
+
+// notifyBreak sends a lease break notification to the client of l.
+//
+// The caller must hold a lock on l.file.mutex.
+func (l *Lease) notifyBreak(current, to smblease.State, flags smblease.BreakFlags) {
+	if l.conn == nil {
+		return
+	}
+	n := smbproto.LeaseBreakNotification{
+		Flags:   flags,
+		Key:     l.Key,
+		Current: current,
+		New:     to,
+	}
+	if l.Version == 2 {
+		n.Epoch = l.epoch
+	}
+	l.conn.queueNotify(n)
+}
+
+// leaseBreak processes an SMB2 OPLOCK_BREAK request that acknowledges a
+// lease break. The lease is downgraded to the acknowledged state and any
+// creates waiting on the break are allowed to proceed.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.22.2
+func (c *Conn) leaseBreak(r *Request) Response {
+	ack := smblease.Ack(r.Data())
+	if !ack.Valid() {
+		return oplockError(smbstatus.InvalidParameter)
+	}
+
+	if c.Leases == nil {
+		return oplockError(smbstatus.ObjectNameNotFound)
+	}
+	l := c.Leases.Lookup(c.ClientGUID, ack.Key())
+	if l == nil {
+		return oplockError(smbstatus.ObjectNameNotFound)
+	}
+
+	defer l.persist()
+
+	f := l.file
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !l.breaking {
+		return oplockError(smbstatus.Unsuccessful)
+	}
+	state := ack.State()
+	if state&^l.notified != 0 {
+		return oplockError(smbstatus.RequestNotAccepted)
+	}
+
+	if state&^l.breakTo != 0 {
+		// The lease must be broken further than the client was told
+		// when the break started
+		l.state = state
+		l.notified = l.breakTo
+		l.epoch++
+		l.notifyBreak(state, l.breakTo, smblease.AckRequired)
+	} else {
+		f.completeLeaseBreak(l, state)
+	}
+
+	return smbproto.LeaseBreakResponse{Key: l.Key, State: state}
+}
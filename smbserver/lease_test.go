@@ -0,0 +1,291 @@
+package smbserver_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcap"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smblease"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+var (
+	clientA     = smbid.ID{0xA}
+	clientB     = smbid.ID{0xB}
+	leaseKey    = smbid.ID{1}
+	dirLeaseKey = smbid.ID{2}
+)
+
+// newLeaseTest returns a pair of connections from different clients that
+// share an open table and a lease table.
+func newLeaseTest(t *testing.T) (a, b *createTest) {
+	a = newOplockTest(t)
+	a.conn.Leases = smbserver.NewLeaseTable()
+	a.conn.ClientGUID = clientA
+
+	conn, transport := newTestConn(64)
+	conn.Opens = a.conn.Opens
+	conn.Leases = a.conn.Leases
+	conn.ClientGUID = clientB
+	b = &createTest{t: t, root: a.root, conn: conn, transport: transport, fs: a.fs}
+	return a, b
+}
+
+// leaseContext returns a serialized version 2 lease create context.
+func leaseContext(key smbid.ID, state smblease.State, parent *smbid.ID) []byte {
+	data := smblease.Context(make([]byte, smblease.ContextV2Size))
+	data.SetKey(key)
+	data.SetState(state)
+	if parent != nil {
+		data.SetFlags(smblease.ParentKeySet)
+		data.SetParentKey(*parent)
+	}
+	return smbcreate.AppendContext(nil, smbcreate.RequestLease, data)
+}
+
+// grantedLease returns the lease context of a create response.
+func (ct *createTest) grantedLease(response smbcreate.Response) smblease.Context {
+	ct.t.Helper()
+	if level := response.OplockLevel(); level != smboplock.Lease {
+		ct.t.Fatalf("create returned oplock level %s (want Lease)", level)
+	}
+	contexts := smbcreate.ContextList(response.CreateContexts())
+	if !contexts.Valid() {
+		ct.t.Fatal("create returned invalid create contexts")
+	}
+	c, ok := contexts.Find(smbcreate.RequestLease)
+	if !ok {
+		ct.t.Fatal("create did not return a lease context")
+	}
+	data := smblease.Context(c.Data())
+	if !data.Valid() {
+		ct.t.Fatal("create returned an invalid lease context")
+	}
+	return data
+}
+
+// leaseNotification returns the lease break notification within packets.
+func (ct *createTest) leaseNotification(packets []smbpacket.Response) smblease.BreakNotification {
+	ct.t.Helper()
+	for _, p := range packets {
+		if p.Header().MessageID() == smbserver.UnsolicitedMessageID {
+			n := smblease.BreakNotification(p.Data())
+			if !n.Valid() {
+				ct.t.Fatal("received an invalid lease break notification")
+			}
+			return n
+		}
+	}
+	ct.t.Fatal("no lease break notification was sent")
+	return nil
+}
+
+// acknowledgeLease sends a lease break acknowledgment without waiting for
+// its response.
+func (ct *createTest) acknowledgeLease(key smbid.ID, state smblease.State) {
+	ct.t.Helper()
+	body := make([]byte, smblease.AckSize)
+	ack := smblease.Ack(body)
+	ack.SetSize(36)
+	ack.SetKey(key)
+	ack.SetState(state)
+	ct.process(1, smbcommand.OplockBreak, body)
+}
+
+// quiet fails the test if a message is sent within a short period of time.
+func (ct *createTest) quiet(desc string) {
+	ct.t.Helper()
+	select {
+	case <-time.After(50 * time.Millisecond):
+	case <-ct.transport.sent:
+		ct.t.Fatalf("%s: an unexpected message was sent", desc)
+	}
+}
+
+const (
+	rh  = smblease.ReadCaching | smblease.HandleCaching
+	rwh = smblease.ReadCaching | smblease.WriteCaching | smblease.HandleCaching
+)
+
+func TestLeaseCapabilities(t *testing.T) {
+	conn, _ := newTestConn(1)
+	if caps := conn.Capabilities(); caps != 0 {
+		t.Errorf("capabilities without a lease table are %s", caps)
+	}
+	conn.Leases = smbserver.NewLeaseTable()
+	if caps := conn.Capabilities(); caps != smbcap.Leasing|smbcap.DirectoryLeasing {
+		t.Errorf("SMB 3.1.1 capabilities are %s (want Leasing|DirectoryLeasing)", caps)
+	}
+	conn.Dialect = smbdialect.SMB21
+	if caps := conn.Capabilities(); caps != smbcap.Leasing {
+		t.Errorf("SMB 2.1 capabilities are %s (want Leasing)", caps)
+	}
+}
+
+func TestLeaseGrant(t *testing.T) {
+	a, _ := newLeaseTest(t)
+
+	first := a.checkCreate("first open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: leaseContext(leaseKey, rwh, nil)})), smbstatus.Success)
+	lease := a.grantedLease(first)
+	if state := lease.State(); state != rwh {
+		t.Errorf("first open was granted lease state %s (want RWH)", state)
+	}
+	if epoch := lease.Epoch(); epoch != 1 {
+		t.Errorf("first open was granted lease epoch %d (want 1)", epoch)
+	}
+
+	// A second open with the same lease key shares the lease without
+	// breaking it
+	second := a.checkCreate("second open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: leaseContext(leaseKey, rwh, nil)})), smbstatus.Success)
+	if state := a.grantedLease(second).State(); state != rwh {
+		t.Errorf("second open was granted lease state %s (want RWH)", state)
+	}
+	if n := a.conn.Leases.Len(); n != 1 {
+		t.Errorf("lease table holds %d leases (want 1)", n)
+	}
+
+	// The lease key can't be used for another file while the lease exists
+	a.checkCreate("reused lease key", a.send(1, smbcommand.Create, createBody(createSpec{Name: "other.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Create, Oplock: smboplock.Lease, Contexts: leaseContext(leaseKey, rwh, nil)})), smbstatus.InvalidParameter)
+
+	// Directory leases never include write caching
+	dir := a.checkCreate("directory open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "dir", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: leaseContext(dirLeaseKey, rwh, nil)})), smbstatus.Success)
+	if state := a.grantedLease(dir).State(); state != rh {
+		t.Errorf("directory open was granted lease state %s (want RH)", state)
+	}
+
+	a.close(1, first.FileID())
+	a.close(1, second.FileID())
+	a.close(1, dir.FileID())
+	if n := a.conn.Leases.Len(); n != 0 {
+		t.Errorf("lease table holds %d leases after every open was closed", n)
+	}
+}
+
+func TestLeaseBreak(t *testing.T) {
+	a, b := newLeaseTest(t)
+
+	first := a.checkCreate("leased open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: leaseContext(leaseKey, rwh, nil)})), smbstatus.Success)
+
+	// An open by another client must wait for write caching to be broken
+	b.process(2, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open}))
+	if !pending(b.collect(1)) {
+		t.Fatal("create that broke a lease did not complete asynchronously")
+	}
+	n := a.leaseNotification(a.collect(1))
+	if n.Key() != leaseKey || n.CurrentState() != rwh || n.NewState() != rh {
+		t.Fatalf("lease break notification for %s breaks %s to %s (want %s from RWH to RH)", n.Key(), n.CurrentState(), n.NewState(), leaseKey)
+	}
+	if !n.Flags().Match(smblease.AckRequired) {
+		t.Error("lease break notification does not require acknowledgment")
+	}
+	if epoch := n.NewEpoch(); epoch != 2 {
+		t.Errorf("lease break notification has epoch %d (want 2)", epoch)
+	}
+
+	a.acknowledgeLease(leaseKey, rh)
+	if s := a.collect(1)[0].Header().Status(); s != smbstatus.Success {
+		t.Fatalf("lease break acknowledgment returned %s", s)
+	}
+	second := b.checkCreate("second open", b.response(b.collect(1), smbcommand.Create), smbstatus.Success)
+	lease := a.conn.Leases.Lookup(clientA, leaseKey)
+	if state := lease.State(); state != rh {
+		t.Errorf("lease has state %s after the break (want RH)", state)
+	}
+
+	// An acknowledgment without a break in progress fails
+	a.acknowledgeLease(leaseKey, smblease.ReadCaching)
+	if s := a.collect(1)[0].Header().Status(); s != smbstatus.Unsuccessful {
+		t.Errorf("unexpected lease break acknowledgment returned %s (want Unsuccessful)", s)
+	}
+
+	// A write by the other client breaks the lease to none
+	b.process(2, smbcommand.Write, writeBody(second.FileID(), "data"))
+	if s := b.response(b.collect(1), smbcommand.Write).Header().Status(); s != smbstatus.Success {
+		t.Fatalf("write returned %s", s)
+	}
+	n = a.leaseNotification(a.collect(1))
+	if n.NewState() != smblease.None {
+		t.Errorf("lease break notification after write breaks to %s (want None)", n.NewState())
+	}
+	a.acknowledgeLease(leaseKey, smblease.None)
+	if s := a.collect(1)[0].Header().Status(); s != smbstatus.Success {
+		t.Fatalf("lease break acknowledgment returned %s", s)
+	}
+	if state := lease.State(); state != smblease.None {
+		t.Errorf("lease has state %s after the write (want None)", state)
+	}
+	a.close(1, first.FileID())
+}
+
+func TestLeaseBreakHandleOnSharingViolation(t *testing.T) {
+	a, b := newLeaseTest(t)
+
+	first := a.checkCreate("leased open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: smbaccess.ReadData, Share: smbcreate.ShareRead, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: leaseContext(leaseKey, rh, nil)})), smbstatus.Success)
+
+	b.process(2, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: smbaccess.WriteData, Share: shareAll, Disposition: smbcreate.Open}))
+	if !pending(b.collect(1)) {
+		t.Fatal("create that broke handle caching did not complete asynchronously")
+	}
+	n := a.leaseNotification(a.collect(1))
+	if n.NewState() != smblease.ReadCaching {
+		t.Fatalf("lease break notification breaks to %s (want R)", n.NewState())
+	}
+
+	// The client closes its cached handle before acknowledging the break
+	a.close(1, first.FileID())
+	b.checkCreate("create after handle break", b.response(b.collect(1), smbcommand.Create), smbstatus.Success)
+}
+
+func TestDirectoryLease(t *testing.T) {
+	a, b := newLeaseTest(t)
+
+	dir := a.checkCreate("directory open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "dir", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: leaseContext(dirLeaseKey, rh, nil)})), smbstatus.Success)
+	if state := a.grantedLease(dir).State(); state != rh {
+		t.Fatalf("directory open was granted lease state %s (want RH)", state)
+	}
+
+	// Changes made by the lease holder through a child lease that names
+	// the directory lease as its parent don't break it
+	parent := dirLeaseKey
+	child := a.checkCreate("child create", a.send(1, smbcommand.Create, createBody(createSpec{Name: `dir\a.txt`, Access: readWrite, Share: shareAll, Disposition: smbcreate.Create, Oplock: smboplock.Lease, Contexts: leaseContext(leaseKey, rwh, &parent)})), smbstatus.Success)
+	if lease := a.grantedLease(child); !lease.Flags().Match(smblease.ParentKeySet) || lease.ParentKey() != dirLeaseKey {
+		t.Error("child lease does not have the expected parent lease key")
+	}
+	a.quiet("child create by lease holder")
+
+	// Changes made by another client do
+	other := b.create("other client create", 2, createSpec{Name: `dir\b.txt`, Access: readWrite, Share: shareAll, Disposition: smbcreate.Create}, smbstatus.Success)
+	b.close(2, other)
+	n := a.leaseNotification(a.collect(1))
+	if n.Key() != dirLeaseKey || n.NewState() != smblease.None {
+		t.Fatalf("lease break notification for %s breaks to %s (want %s to None)", n.Key(), n.NewState(), dirLeaseKey)
+	}
+	a.acknowledgeLease(dirLeaseKey, smblease.None)
+	if s := a.collect(1)[0].Header().Status(); s != smbstatus.Success {
+		t.Fatalf("lease break acknowledgment returned %s", s)
+	}
+
+	// Deleting a file within the directory breaks a new directory lease
+	dir2 := a.checkCreate("directory reopen", a.send(1, smbcommand.Create, createBody(createSpec{Name: "dir", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: leaseContext(dirLeaseKey, rh, nil)})), smbstatus.Success)
+	if state := a.grantedLease(dir2).State(); state != rh {
+		t.Fatalf("directory reopen was granted lease state %s (want RH)", state)
+	}
+	id := b.create("delete on close", 2, createSpec{Name: `dir\b.txt`, Access: smbaccess.Delete, Share: shareAll, Disposition: smbcreate.Open, Options: smbcreate.DeleteOnClose}, smbstatus.Success)
+	b.close(2, id)
+	if _, err := os.Stat(filepath.Join(a.root, "dir", "b.txt")); !os.IsNotExist(err) {
+		t.Fatal("file was not deleted")
+	}
+	if n := a.leaseNotification(a.collect(1)); n.Key() != dirLeaseKey || n.NewState() != smblease.None {
+		t.Fatalf("lease break notification for %s breaks to %s after delete (want %s to None)", n.Key(), n.NewState(), dirLeaseKey)
+	}
+}
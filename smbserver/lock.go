@@ -0,0 +1,410 @@
+package smbserver
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smblock"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// lockSequenceCount is the number of lock sequence entries that are kept
+// for each open.
+const lockSequenceCount = 64
+
+// lockSequence records the lock sequence number of the last successful
+// lock request that used a particular lock sequence index.
+type lockSequence struct {
+	valid  bool
+	number uint8
+}
+
+// byteRangeLock is a byte-range lock held by an open.
+type byteRangeLock struct {
+	owner     *Open
+	offset    uint64
+	length    uint64
+	exclusive bool
+}
+
+// overlaps returns true if the lock overlaps the given range. Zero-length
+// ranges don't overlap anything.
+func (l byteRangeLock) overlaps(offset, length uint64) bool {
+	if l.length == 0 || length == 0 {
+		return false
+	}
+	return offset <= l.offset+(l.length-1) && l.offset <= offset+(length-1)
+}
+
+// lockElement is an element of a lock request.
+type lockElement struct {
+	offset uint64
+	length uint64
+	flags  smblock.Flags
+}
+
+// validRange returns true if the range of e doesn't extend beyond the
+// largest possible file offset.
+func (e lockElement) validRange() bool {
+	return e.length == 0 || e.offset+(e.length-1) >= e.offset
+}
+
+// Lock processes an SMB2 LOCK request. It locks or unlocks one or more byte
+// ranges of the file referred to by the request.
+//
+// Locks are either shared or exclusive, and are owned by the open through
+// which they were acquired. Locks that can't be granted immediately fail
+// with STATUS_LOCK_NOT_GRANTED if SMB2_LOCKFLAG_FAIL_IMMEDIATELY is set.
+// Otherwise the request completes asynchronously once the conflicting locks
+// have been released. If any lock in the request can't be granted, the
+// locks granted earlier in the same request are released.
+//
+// Lock requests on resilient, durable and persistent opens are checked for
+// replays using the lock sequence number and index of the request.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.14
+func (c *Conn) Lock(r *Request) Response {
+	request := smblock.Request(r.Data())
+	if !request.Valid() || request.LockCount() == 0 {
+		return lockError(smbstatus.InvalidParameter)
+	}
+
+	id, ok := r.ResolveFileID(request.FileID())
+	if !ok {
+		return lockError(smbstatus.InvalidParameter)
+	}
+	open := c.lookupOpen(r, id)
+	if open == nil {
+		return lockError(smbstatus.FileClosed)
+	}
+	r.SetFileID(id)
+
+	elements := make([]lockElement, request.LockCount())
+	for i := range elements {
+		e := request.Lock(i)
+		elements[i] = lockElement{offset: e.Offset(), length: e.Length(), flags: e.Flags()}
+	}
+
+	// Either every element is an unlock or none of them are
+	unlock := elements[0].flags == smblock.Unlock
+	for _, e := range elements {
+		if !e.flags.Valid() || (e.flags == smblock.Unlock) != unlock {
+			return lockError(smbstatus.InvalidParameter)
+		}
+	}
+
+	done, ok := c.verifyChannelSequence(r, open)
+	if !ok {
+		return lockError(smbstatus.FileNotAvailable)
+	}
+
+	index := 0
+	if c.checkLockSequence(open) {
+		index = int(request.LockSequenceIndex())
+		if index > lockSequenceCount {
+			index = 0
+		}
+		if index > 0 && open.replayedLockSequence(index, request.LockSequenceNumber()) {
+			done()
+			return smbproto.LockResponse{}
+		}
+	}
+
+	lr := &lockRequest{
+		open:     open,
+		elements: elements,
+		index:    index,
+		number:   request.LockSequenceNumber(),
+	}
+
+	if unlock {
+		defer done()
+		return lr.unlock()
+	}
+
+	open.file.breakReadCaching(open, c.oplockBreakTimeout())
+
+	wake := make(chan struct{}, 1)
+	status, blocked := lr.acquire(wake)
+	if !blocked {
+		done()
+		return lr.finish(status)
+	}
+
+	// Blocked requests remain outstanding until they complete
+	a := c.GoAsync(r)
+	go func() {
+		lr.wait(a, wake)
+		done()
+	}()
+	return a
+}
+
+// checkLockSequence returns true if lock sequence numbers should be checked
+// for lock requests on o.
+func (c *Conn) checkLockSequence(o *Open) bool {
+	switch rev := c.Dialect.Revision(); {
+	case rev == smbdialect.SMB21:
+		return o.Resilient
+	case rev.Major() == 3:
+		return o.Resilient || o.Durable || o.Persistent
+	default:
+		return false
+	}
+}
+
+// replayedLockSequence returns true if number matches the lock sequence
+// number recorded for the given lock sequence index, in which case the
+// lock request is a replay of one that already succeeded. Otherwise the
+// entry is invalidated until the request succeeds.
+func (o *Open) replayedLockSequence(index int, number uint8) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	entry := &o.lockSequences[index-1]
+	if entry.valid && entry.number == number {
+		return true
+	}
+	entry.valid = false
+	return false
+}
+
+// recordLockSequence records the lock sequence number of a successful lock
+// request for the given lock sequence index.
+func (o *Open) recordLockSequence(index int, number uint8) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.lockSequences[index-1] = lockSequence{valid: true, number: number}
+}
+
+// checkLock returns true if the given range of the file can be read, or
+// written if write is true, without conflicting with a byte-range lock.
+//
+// Reads conflict with exclusive locks held by other opens. Writes conflict
+// with exclusive locks held by other opens and with shared locks held by
+// any open.
+func (o *Open) checkLock(offset, length uint64, write bool) bool {
+	f := o.file
+	if f == nil {
+		return true
+	}
+
+	// Clamp ranges that extend beyond the largest possible file offset
+	if length > 0 && offset+(length-1) < offset {
+		length = -offset
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, l := range f.locks {
+		if !l.overlaps(offset, length) {
+			continue
+		}
+		if l.exclusive && l.owner != o {
+			return false
+		}
+		if !l.exclusive && write {
+			return false
+		}
+	}
+	return true
+}
+
+// lockRequest is a lock request that is being processed.
+type lockRequest struct {
+	open     *Open
+	elements []lockElement
+	granted  int // The number of elements that have been granted
+	index    int // Lock sequence index, or zero
+	number   uint8
+}
+
+// unlock releases the ranges described by the request. It stops at the
+// first range that isn't locked.
+func (lr *lockRequest) unlock() Response {
+	f := lr.open.file
+	f.mutex.Lock()
+	status := smbstatus.Code(smbstatus.Success)
+	for _, e := range lr.elements {
+		if !f.unlock(lr.open, e.offset, e.length) {
+			status = smbstatus.RangeNotLocked
+			break
+		}
+	}
+	f.mutex.Unlock()
+	return lr.finish(status)
+}
+
+// acquire attempts to grant the remaining elements of the request. If an
+// element conflicts with an existing lock and may wait, acquire registers
+// wake to be signaled when locks are released and returns with blocked set
+// to true.
+//
+// If an element can't be granted the locks granted by the request are
+// released and a failure status is returned.
+func (lr *lockRequest) acquire(wake chan struct{}) (status smbstatus.Code, blocked bool) {
+	f := lr.open.file
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.attached(lr.open) {
+		return smbstatus.Cancelled, false
+	}
+
+	for lr.granted < len(lr.elements) {
+		e := lr.elements[lr.granted]
+		if !e.validRange() {
+			lr.rollback()
+			return smbstatus.InvalidLockRange, false
+		}
+		exclusive := e.flags.Match(smblock.Exclusive)
+		if f.conflicts(e.offset, e.length, exclusive) {
+			if e.flags.Match(smblock.FailImmediately) {
+				lr.rollback()
+				return smbstatus.LockNotGranted, false
+			}
+			f.waiters = append(f.waiters, wake)
+			return smbstatus.Pending, true
+		}
+		f.locks = append(f.locks, byteRangeLock{
+			owner:     lr.open,
+			offset:    e.offset,
+			length:    e.length,
+			exclusive: exclusive,
+		})
+		lr.granted++
+	}
+
+	return smbstatus.Success, false
+}
+
+// rollback releases the locks granted by the request so far.
+//
+// The caller must hold a lock on the file's mutex.
+func (lr *lockRequest) rollback() {
+	f := lr.open.file
+	for i := lr.granted - 1; i >= 0; i-- {
+		e := lr.elements[i]
+		f.unlock(lr.open, e.offset, e.length)
+	}
+	lr.granted = 0
+}
+
+// wait waits for conflicting locks to be released and completes the
+// asynchronous command a once the request has been granted or has failed.
+// If the command is cancelled the locks granted by the request are
+// released.
+func (lr *lockRequest) wait(a *AsyncCommand, wake chan struct{}) {
+	f := lr.open.file
+	for {
+		select {
+		case <-a.Context().Done():
+			f.mutex.Lock()
+			f.removeWaiter(wake)
+			lr.rollback()
+			f.mutex.Unlock()
+			return
+		case <-wake:
+		}
+
+		status, blocked := lr.acquire(wake)
+		if blocked {
+			continue
+		}
+		if status == smbstatus.Cancelled {
+			a.Cancel()
+			return
+		}
+		if err := a.Complete(lr.finish(status)); err != nil {
+			// The command was cancelled while the locks were being granted
+			f.mutex.Lock()
+			lr.rollback()
+			f.mutex.Unlock()
+		}
+		return
+	}
+}
+
+// finish records the lock sequence of a successful request and returns
+// the response for the given status.
+func (lr *lockRequest) finish(status smbstatus.Code) Response {
+	if status != smbstatus.Success {
+		return lockError(status)
+	}
+	if lr.index > 0 {
+		lr.open.recordLockSequence(lr.index, lr.number)
+	}
+	lr.open.persist()
+	return smbproto.LockResponse{}
+}
+
+func lockError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.Lock, Code: code}
+}
+
+// conflicts returns true if a lock on the given range conflicts with an
+// existing lock. Exclusive locks conflict with every overlapping lock,
+// including those held by the same open.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) conflicts(offset, length uint64, exclusive bool) bool {
+	for _, l := range f.locks {
+		if l.overlaps(offset, length) && (exclusive || l.exclusive) {
+			return true
+		}
+	}
+	return false
+}
+
+// unlock releases the lock held by owner on the given range. It returns
+// false if owner doesn't hold a lock on exactly that range.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) unlock(owner *Open, offset, length uint64) bool {
+	for i, l := range f.locks {
+		if l.owner == owner && l.offset == offset && l.length == length {
+			f.locks = append(f.locks[:i], f.locks[i+1:]...)
+			f.wakeWaiters()
+			return true
+		}
+	}
+	return false
+}
+
+// releaseLocks releases every lock held by owner.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) releaseLocks(owner *Open) {
+	locks := f.locks[:0]
+	for _, l := range f.locks {
+		if l.owner != owner {
+			locks = append(locks, l)
+		}
+	}
+	f.locks = locks
+	f.wakeWaiters()
+}
+
+// wakeWaiters signals every waiting lock request to try again.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) wakeWaiters() {
+	for _, wake := range f.waiters {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+	f.waiters = nil
+}
+
+// removeWaiter removes a waiting lock request.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) removeWaiter(wake chan struct{}) {
+	for i := range f.waiters {
+		if f.waiters[i] == wake {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return
+		}
+	}
+}
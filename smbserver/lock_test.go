@@ -0,0 +1,330 @@
+package smbserver_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/msgpool"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smblock"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbread"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbwrite"
+)
+
+// memFS is a file system that can't open anything. Its files are added to
+// opens directly.
+type memFS struct {
+	smbfs.FileSystem
+}
+
+// memFile is an in-memory file.
+type memFile struct {
+	smbfs.File
+	mutex sync.Mutex
+	data  []byte
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) WriteAt(b []byte, off int64) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if end := int(off) + len(b); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	return copy(f.data[off:], b), nil
+}
+
+func (f *memFile) Close() error               { return nil }
+func (f *memFile) Stat() (os.FileInfo, error) { return nil, os.ErrInvalid }
+
+// lockTest holds a connection with two opens of the same file from
+// different sessions.
+type lockTest struct {
+	t         *testing.T
+	conn      *smbserver.Conn
+	transport *testTransport
+	a, b      *smbserver.Open
+	messageID uint64
+}
+
+func newLockTest(t *testing.T) *lockTest {
+	conn, transport := newTestConn(64)
+	conn.Opens = smbserver.NewOpenTable()
+	fs, file := &memFS{}, &memFile{data: make([]byte, 64)}
+	a := &smbserver.Open{SessionID: 1, TreeID: 1, FS: fs, Name: "file.txt", File: file, GrantedAccess: readWrite, ShareAccess: shareAll}
+	b := &smbserver.Open{SessionID: 2, TreeID: 1, FS: fs, Name: "file.txt", File: file, GrantedAccess: readWrite, ShareAccess: shareAll}
+	addOpen(t, conn, a)
+	addOpen(t, conn, b)
+	return &lockTest{t: t, conn: conn, transport: transport, a: a, b: b}
+}
+
+func lockHandler(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+	switch r.Header().Command() {
+	case smbcommand.Read:
+		return c.Read(r)
+	case smbcommand.Write:
+		return c.Write(r)
+	case smbcommand.Lock:
+		return c.Lock(r)
+	case smbcommand.IOCTL:
+		return c.Ioctl(r)
+	}
+	return nil
+}
+
+// send sends a request on behalf of open o and returns the response.
+func (lt *lockTest) send(o *smbserver.Open, cmd smbcommand.Code, body []byte) smbpacket.Response {
+	lt.t.Helper()
+	r := testRequest{Command: cmd, MessageID: lt.messageID, SessionID: o.SessionID, TreeID: o.TreeID, Body: body}
+	lt.messageID++
+	if err := lt.conn.Process(makeMessage(r), smbserver.CommandHandlerFunc(lockHandler)); err != nil {
+		lt.t.Fatalf("Process returned %v", err)
+	}
+	b := lt.transport.Next()
+	if b == nil {
+		lt.t.Fatal("no response was sent")
+	}
+	return smbpacket.Response(b)
+}
+
+// expect checks the status of a response.
+func (lt *lockTest) expect(desc string, response smbpacket.Response, status smbstatus.Code) {
+	lt.t.Helper()
+	if s := response.Header().Status(); s != status {
+		lt.t.Errorf("%s: returned %s (want %s)", desc, s, status)
+	}
+}
+
+type lockRange struct {
+	Offset uint64
+	Length uint64
+	Flags  smblock.Flags
+}
+
+func lockBody(id smbfile.ID, index uint32, number uint8, ranges ...lockRange) []byte {
+	body := make([]byte, 24+len(ranges)*smblock.ElementSize)
+	request := smblock.Request(body)
+	request.SetSize(48)
+	request.SetLockCount(uint16(len(ranges)))
+	request.SetLockSequence(number, index)
+	request.SetFileID(id)
+	for i, r := range ranges {
+		e := request.Lock(i)
+		e.SetOffset(r.Offset)
+		e.SetLength(r.Length)
+		e.SetFlags(r.Flags)
+	}
+	return body
+}
+
+func (lt *lockTest) lock(o *smbserver.Open, ranges ...lockRange) smbpacket.Response {
+	lt.t.Helper()
+	return lt.send(o, smbcommand.Lock, lockBody(o.ID, 0, 0, ranges...))
+}
+
+func (lt *lockTest) read(o *smbserver.Open, offset uint64, length uint32) smbpacket.Response {
+	lt.t.Helper()
+	body := make([]byte, smbread.RequestSize+1)
+	request := smbread.Request(body)
+	request.SetSize(49)
+	request.SetOffset(offset)
+	request.SetLength(length)
+	request.SetFileID(o.ID)
+	return lt.send(o, smbcommand.Read, body)
+}
+
+func (lt *lockTest) write(o *smbserver.Open, offset uint64, data []byte) smbpacket.Response {
+	lt.t.Helper()
+	return lt.send(o, smbcommand.Write, lockWriteBody(o, offset, data))
+}
+
+func lockWriteBody(o *smbserver.Open, offset uint64, data []byte) []byte {
+	body := make([]byte, smbwrite.RequestSize+len(data))
+	request := smbwrite.Request(body)
+	request.SetSize(49)
+	request.SetOffset(offset)
+	request.SetFileID(o.ID)
+	copy(request.SetDataLayout(len(data)), data)
+	return body
+}
+
+const (
+	shared             = smblock.Shared
+	exclusive          = smblock.Exclusive
+	unlock             = smblock.Unlock
+	sharedImmediate    = smblock.Shared | smblock.FailImmediately
+	exclusiveImmediate = smblock.Exclusive | smblock.FailImmediately
+)
+
+func TestLock(t *testing.T) {
+	lt := newLockTest(t)
+	a, b := lt.a, lt.b
+
+	lt.expect("exclusive lock", lt.lock(a, lockRange{0, 10, exclusiveImmediate}), smbstatus.Success)
+	lt.expect("overlapping shared lock", lt.lock(b, lockRange{5, 10, sharedImmediate}), smbstatus.LockNotGranted)
+	lt.expect("overlapping exclusive lock by owner", lt.lock(a, lockRange{9, 1, exclusiveImmediate}), smbstatus.LockNotGranted)
+	lt.expect("adjacent shared lock", lt.lock(b, lockRange{10, 5, sharedImmediate}), smbstatus.Success)
+	lt.expect("second shared lock", lt.lock(a, lockRange{12, 2, sharedImmediate}), smbstatus.Success)
+	lt.expect("zero-length lock", lt.lock(b, lockRange{5, 0, exclusiveImmediate}), smbstatus.Success)
+
+	lt.expect("read by owner of exclusive lock", lt.read(a, 0, 10), smbstatus.Success)
+	lt.expect("read of exclusive lock", lt.read(b, 0, 10), smbstatus.FileLockConflict)
+	lt.expect("read of shared lock", lt.read(b, 10, 5), smbstatus.Success)
+	lt.expect("write by owner of exclusive lock", lt.write(a, 0, []byte("data")), smbstatus.Success)
+	lt.expect("write of exclusive lock", lt.write(b, 8, []byte("data")), smbstatus.FileLockConflict)
+	lt.expect("write of own shared lock", lt.write(b, 10, []byte("data")), smbstatus.FileLockConflict)
+	lt.expect("write of unlocked range", lt.write(b, 20, []byte("data")), smbstatus.Success)
+
+	lt.expect("unlock", lt.lock(a, lockRange{0, 10, unlock}), smbstatus.Success)
+	lt.expect("unlock of unlocked range", lt.lock(a, lockRange{0, 10, unlock}), smbstatus.RangeNotLocked)
+	lt.expect("unlock of another open's range", lt.lock(a, lockRange{10, 5, unlock}), smbstatus.RangeNotLocked)
+	lt.expect("read of unlocked range", lt.read(b, 0, 10), smbstatus.Success)
+
+	lt.expect("mixed lock and unlock", lt.lock(a, lockRange{30, 1, exclusiveImmediate}, lockRange{12, 2, unlock}), smbstatus.InvalidParameter)
+	lt.expect("invalid flags", lt.lock(a, lockRange{30, 1, shared | exclusive}), smbstatus.InvalidParameter)
+	lt.expect("invalid range", lt.lock(a, lockRange{1<<64 - 2, 4, exclusiveImmediate}), smbstatus.InvalidLockRange)
+}
+
+func TestLockRollback(t *testing.T) {
+	lt := newLockTest(t)
+	a, b := lt.a, lt.b
+
+	lt.expect("exclusive lock", lt.lock(b, lockRange{20, 10, exclusiveImmediate}), smbstatus.Success)
+	lt.expect("partially conflicting locks", lt.lock(a,
+		lockRange{0, 10, exclusiveImmediate},
+		lockRange{10, 5, exclusiveImmediate},
+		lockRange{25, 1, exclusiveImmediate},
+	), smbstatus.LockNotGranted)
+	lt.expect("lock of rolled back range", lt.lock(b, lockRange{0, 15, exclusiveImmediate}), smbstatus.Success)
+}
+
+func TestLockBlocking(t *testing.T) {
+	lt := newLockTest(t)
+	a, b := lt.a, lt.b
+
+	lt.expect("exclusive lock", lt.lock(a, lockRange{0, 10, exclusiveImmediate}), smbstatus.Success)
+
+	interim := lt.lock(b, lockRange{0, 10, shared})
+	lt.expect("blocking lock", interim, smbstatus.Pending)
+	asyncID := interim.Header().AsyncID()
+
+	lt.expect("unlock", lt.lock(a, lockRange{0, 10, unlock}), smbstatus.Success)
+
+	final := smbpacket.Response(lt.transport.Next())
+	if final == nil {
+		t.Fatal("blocking lock was not completed")
+	}
+	checkAsyncResponse(t, final, interim.Header().MessageID(), asyncID, smbstatus.Success)
+
+	lt.expect("write of granted range", lt.write(a, 0, []byte("data")), smbstatus.FileLockConflict)
+}
+
+func TestLockBlockingCancel(t *testing.T) {
+	lt := newLockTest(t)
+	a, b := lt.a, lt.b
+
+	lt.expect("exclusive lock", lt.lock(a, lockRange{10, 10, exclusiveImmediate}), smbstatus.Success)
+
+	// The first range is granted before the request blocks on the second
+	interim := lt.lock(b, lockRange{0, 5, exclusiveImmediate}, lockRange{10, 10, exclusive})
+	lt.expect("blocking lock", interim, smbstatus.Pending)
+
+	cancel := testRequest{Command: smbcommand.Cancel, MessageID: interim.Header().MessageID(), SessionID: b.SessionID}
+	if err := lt.conn.Process(makeMessage(cancel), smbserver.CommandHandlerFunc(lockHandler)); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	checkAsyncResponse(t, lt.transport.Next(), interim.Header().MessageID(), interim.Header().AsyncID(), smbstatus.Cancelled)
+
+	waitFor(t, func() bool {
+		return lt.lock(a, lockRange{0, 5, exclusiveImmediate}).Header().Status() == smbstatus.Success
+	})
+}
+
+func TestLockClose(t *testing.T) {
+	lt := newLockTest(t)
+	a, b := lt.a, lt.b
+
+	lt.expect("exclusive lock", lt.lock(a, lockRange{0, 10, exclusiveImmediate}), smbstatus.Success)
+	interim := lt.lock(b, lockRange{0, 10, exclusive})
+	lt.expect("blocking lock", interim, smbstatus.Pending)
+
+	lt.conn.Opens.Remove(a.ID).Close()
+
+	checkAsyncResponse(t, lt.transport.Next(), interim.Header().MessageID(), interim.Header().AsyncID(), smbstatus.Success)
+
+	// Closing an open cancels its pending lock requests
+	c := &smbserver.Open{SessionID: 1, TreeID: 1, FS: a.FS, Name: a.Name, File: a.File, GrantedAccess: a.GrantedAccess, ShareAccess: a.ShareAccess}
+	addOpen(t, lt.conn, c)
+	interim = lt.lock(c, lockRange{5, 1, shared})
+	lt.expect("blocking lock", interim, smbstatus.Pending)
+	lt.conn.Opens.Remove(c.ID).Close()
+	checkAsyncResponse(t, lt.transport.Next(), interim.Header().MessageID(), interim.Header().AsyncID(), smbstatus.Cancelled)
+}
+
+func TestLockSequence(t *testing.T) {
+	lt := newLockTest(t)
+	a := lt.a
+	a.Durable = true
+
+	body := lockBody(a.ID, 1, 3, lockRange{0, 10, exclusiveImmediate})
+	lt.expect("lock", lt.send(a, smbcommand.Lock, body), smbstatus.Success)
+	lt.expect("replayed lock", lt.send(a, smbcommand.Lock, body), smbstatus.Success)
+
+	body = lockBody(a.ID, 1, 4, lockRange{0, 10, exclusiveImmediate})
+	lt.expect("lock with new sequence number", lt.send(a, smbcommand.Lock, body), smbstatus.LockNotGranted)
+	lt.expect("lock with new sequence number", lt.send(a, smbcommand.Lock, body), smbstatus.LockNotGranted)
+
+	// Lock sequences aren't checked for opens that aren't durable
+	b := lt.b
+	body = lockBody(b.ID, 1, 3, lockRange{20, 10, exclusiveImmediate})
+	lt.expect("lock", lt.send(b, smbcommand.Lock, body), smbstatus.Success)
+	lt.expect("repeated lock", lt.send(b, smbcommand.Lock, body), smbstatus.LockNotGranted)
+}
+
+func TestLargeReadWrite(t *testing.T) {
+	lt := newLockTest(t)
+	lt.conn.MaxReadSize, lt.conn.MaxWriteSize = 1<<20, 1<<20
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+
+	// Messages larger than the largest pooled message are received into
+	// messages allocated by the pool
+	request := makeMessage(testRequest{Command: smbcommand.Write, MessageID: lt.messageID, SessionID: lt.a.SessionID, TreeID: lt.a.TreeID, Body: lockWriteBody(lt.a, 0, data)})
+	lt.messageID++
+	received := msgpool.New().Get(request.Length())
+	copy(received.Bytes(), request.Bytes())
+	if err := lt.conn.Process(received, smbserver.CommandHandlerFunc(lockHandler)); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	response := smbpacket.Response(lt.transport.Next())
+	lt.expect("write of 1 MiB", response, smbstatus.Success)
+	if written := smbwrite.Response(response.Data()).Count(); written != uint32(len(data)) {
+		t.Fatalf("write of 1 MiB wrote %d bytes", written)
+	}
+
+	response = lt.read(lt.a, 0, uint32(len(data)))
+	lt.expect("read of 1 MiB", response, smbstatus.Success)
+	if !bytes.Equal(smbread.Response(response.Data()).Data(), data) {
+		t.Fatal("read of 1 MiB returned different data")
+	}
+	lt.expect("read beyond the limit", lt.read(lt.a, 0, 1<<20+1), smbstatus.InvalidParameter)
+}
@@ -0,0 +1,180 @@
+package smbserver
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbintegrity"
+	"github.com/gentlemanautomaton/smb/smbnego"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbsecmode"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// defaultMaxSize is the maximum transaction, read and write size that is
+// advertised to clients if the connection doesn't set its own limits.
+const defaultMaxSize = 8388608
+
+// preauthSaltSize is the number of bytes of salt that are sent with the
+// preauthentication integrity capabilities of negotiate responses.
+const preauthSaltSize = 32
+
+// supportedDialects lists the dialects supported by the server in order of
+// preference.
+var supportedDialects = []smbdialect.Revision{
+	smbdialect.SMB311,
+	smbdialect.SMB302,
+	smbdialect.SMB3,
+	smbdialect.SMB21,
+	smbdialect.SMB202,
+}
+
+// selectDialect returns the dialect that the server selects from the
+// dialects offered by a client. It returns false if none of them are
+// supported.
+func selectDialect(offered smbdialect.List) (smbdialect.Revision, bool) {
+	for _, dialect := range supportedDialects {
+		if offered.Contains(dialect) {
+			return dialect, true
+		}
+	}
+	return 0, false
+}
+
+// SecurityMode returns the security mode that the server advertises to the
+// client during negotiation of the connection's dialect.
+func (c *Conn) SecurityMode() smbsecmode.Flags {
+	mode := smbsecmode.Flags(smbsecmode.SigningEnabled)
+	if c.RequireMessageSigning {
+		mode |= smbsecmode.SigningRequired
+	}
+	return mode
+}
+
+// Negotiate processes an SMB2 NEGOTIATE request. It selects the dialect of
+// the connection from the dialects offered by the client, and records the
+// client's capabilities, GUID and security mode so that they can be checked
+// by FSCTL_VALIDATE_NEGOTIATE_INFO requests later on.
+//
+// SMB 3.1.1 requests must offer SHA-512 preauthentication integrity. The
+// request and the response begin the preauthentication integrity hash of
+// the connection, and the SMB3 POSIX extensions are enabled if both sides
+// support them.
+//
+// Connections that have already negotiated a dialect are closed without a
+// response.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.4
+func (c *Conn) Negotiate(r *Request) Response {
+	if c.Dialect.Ready() {
+		c.Close()
+		return nil
+	}
+
+	request := smbnego.Request(r.Data())
+	if !request.Valid() {
+		return negotiateError(smbstatus.InvalidParameter)
+	}
+	dialect, ok := selectDialect(request.Dialects())
+	if !ok || !c.Dialect.CanTransition(smbdialect.State(dialect)) {
+		return negotiateError(smbstatus.NotSupported)
+	}
+
+	var contexts []smbnego.Context
+	if dialect == smbdialect.SMB311 {
+		context, ok := request.ContextList().Find(request.ContextCount(), smbnego.PreauthIntegrityCaps)
+		if !ok {
+			return negotiateError(smbstatus.InvalidParameter)
+		}
+		caps := context.PreauthIntegrityCaps()
+		if !caps.Valid() || !caps.Algorithms().Contains(smbintegrity.SHA512) {
+			return negotiateError(smbstatus.InvalidParameter)
+		}
+		ack, err := preauthContext()
+		if err != nil {
+			return negotiateError(smbstatus.InsufficientResources)
+		}
+		contexts = append(contexts, ack)
+	}
+
+	c.Dialect = smbdialect.State(dialect)
+	c.ClientCapabilities = request.Capabilities()
+	c.ClientGUID = request.ClientID()
+	c.ClientSecurity = request.SecurityMode()
+	if dialect != smbdialect.SMB202 {
+		c.SupportMultiCredit = true
+	}
+	if c.MaxTransactSize == 0 {
+		c.MaxTransactSize = defaultMaxSize
+	}
+	if c.MaxReadSize == 0 {
+		c.MaxReadSize = defaultMaxSize
+	}
+	if c.MaxWriteSize == 0 {
+		c.MaxWriteSize = defaultMaxSize
+	}
+	if context := c.NegotiatePOSIX(request); context != nil {
+		contexts = append(contexts, context)
+	}
+
+	response := smbproto.NegotiateResponse{
+		Dialect:         dialect,
+		SecMode:         c.SecurityMode(),
+		Server:          c.Server,
+		Caps:            c.Capabilities(),
+		MaxTransactSize: c.MaxTransactSize,
+		MaxReadSize:     c.MaxReadSize,
+		MaxWriteSize:    c.MaxWriteSize,
+		SystemTime:      time.Now(),
+		Contexts:        contexts,
+	}
+	if dialect != smbdialect.SMB311 {
+		return response
+	}
+
+	c.PreauthIntegrityHash = extendPreauth(make([]byte, sha512.Size), r.Packet)
+	return negotiateResponse{NegotiateResponse: response, conn: c}
+}
+
+// preauthContext returns the preauthentication integrity capabilities of
+// the server, which select SHA-512 with a random salt.
+func preauthContext() (smbnego.Context, error) {
+	caps := smbintegrity.Capabilities(make([]byte, smbintegrity.CapabilitiesSize+2+preauthSaltSize))
+	caps.SetAlgorithmCount(1)
+	caps.SetSaltLength(preauthSaltSize)
+	caps.Algorithms().SetMember(0, smbintegrity.SHA512)
+	if _, err := rand.Read(caps.Salt()); err != nil {
+		return nil, err
+	}
+	return smbnego.NewContext(smbnego.PreauthIntegrityCaps, caps), nil
+}
+
+// extendPreauth returns the preauthentication integrity hash that results
+// from extending hash with the bytes of packet.
+func extendPreauth(hash, packet []byte) []byte {
+	h := sha512.New()
+	h.Write(hash)
+	h.Write(packet)
+	return h.Sum(nil)
+}
+
+// negotiateResponse is an SMB 3.1.1 negotiate response. The bytes of the
+// response are added to the preauthentication integrity hash of the
+// connection once it has been assembled.
+type negotiateResponse struct {
+	smbproto.NegotiateResponse
+	conn *Conn
+}
+
+// sent updates the preauthentication integrity hash of the connection with
+// the bytes of the response.
+func (r negotiateResponse) sent(packet []byte) {
+	r.conn.PreauthIntegrityHash = extendPreauth(r.conn.PreauthIntegrityHash, packet)
+}
+
+func negotiateError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.Negotiate, Code: code}
+}
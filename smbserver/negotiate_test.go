@@ -0,0 +1,206 @@
+package smbserver_test
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbcap"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbintegrity"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbnego"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbposix"
+	"github.com/gentlemanautomaton/smb/smbsecmode"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// negotiateRequest returns a negotiate request that offers the given
+// dialects and carries the given negotiate contexts.
+func negotiateRequest(caps smbcap.Flags, client smbid.ID, mode smbsecmode.Flags, dialects []smbdialect.Revision, contexts ...smbnego.Context) []byte {
+	contextStart := (smbnego.RequestSize + 2*len(dialects) + 7) &^ 7
+	body := make([]byte, contextStart)
+	request := smbnego.Request(body)
+	request.SetSize(36)
+	request.SetSecurityMode(mode)
+	request.SetCapabilities(caps)
+	request.SetClientID(client)
+	request.SetDialectCount(uint16(len(dialects)))
+	for i, dialect := range dialects {
+		request.Dialects().SetMember(i, dialect)
+	}
+	if len(contexts) > 0 {
+		request.SetContextOffset(uint32(smbpacket.HeaderSize + contextStart))
+		request.SetContextCount(uint16(len(contexts)))
+	}
+	for _, context := range contexts {
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+		body = append(body, context...)
+	}
+	return body
+}
+
+// negotiateBody returns an SMB 3.1.1 negotiate request that carries the
+// given negotiate contexts.
+func negotiateBody(contexts ...smbnego.Context) []byte {
+	return negotiateRequest(0, smbid.ID{}, smbsecmode.SigningEnabled, []smbdialect.Revision{smbdialect.SMB311}, contexts...)
+}
+
+// preauthCaps returns a preauthentication integrity capabilities context
+// that offers the given algorithms.
+func preauthCaps(algorithms ...smbintegrity.Algorithm) smbnego.Context {
+	caps := smbintegrity.Capabilities(make([]byte, smbintegrity.CapabilitiesSize+2*len(algorithms)+32))
+	caps.SetAlgorithmCount(uint16(len(algorithms)))
+	caps.SetSaltLength(32)
+	for i, algorithm := range algorithms {
+		caps.Algorithms().SetMember(i, algorithm)
+	}
+	return smbnego.NewContext(smbnego.PreauthIntegrityCaps, caps)
+}
+
+// newNegotiateConn returns a connection that hasn't negotiated a dialect.
+func newNegotiateConn() (*smbserver.Conn, *testTransport) {
+	conn, transport := newTestConn(8)
+	conn.Dialect = smbdialect.Uninitialized
+	conn.Server = smbid.ID{9, 9, 9}
+	return conn, transport
+}
+
+func negotiateHandler(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+	switch r.Header().Command() {
+	case smbcommand.Negotiate:
+		return c.Negotiate(r)
+	case smbcommand.IOCTL:
+		return c.Ioctl(r)
+	}
+	return nil
+}
+
+// negotiate sends a negotiate request and returns the request message and
+// the response.
+func negotiate(t *testing.T, conn *smbserver.Conn, transport *testTransport, messageID uint64, body []byte) (request []byte, response smbpacket.Response) {
+	t.Helper()
+	msg := makeMessage(testRequest{Command: smbcommand.Negotiate, MessageID: messageID, Body: body})
+	request = append([]byte(nil), msg.Bytes()...)
+	if err := conn.Process(msg, smbserver.CommandHandlerFunc(negotiateHandler)); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	return request, smbpacket.Response(transport.Next())
+}
+
+func TestNegotiateValidate(t *testing.T) {
+	const (
+		caps = smbcap.DFS | smbcap.LargeMTU
+		mode = smbsecmode.SigningEnabled
+	)
+	client := smbid.ID{7, 7, 7}
+	dialects := []smbdialect.Revision{smbdialect.SMB202, smbdialect.SMB21, smbdialect.SMB3, smbdialect.SMB302}
+
+	conn, transport := newNegotiateConn()
+	_, packet := negotiate(t, conn, transport, 0, negotiateRequest(caps, client, mode, dialects))
+	if s := packet.Header().Status(); s != smbstatus.Success {
+		t.Fatalf("negotiate returned %s", s)
+	}
+	response := smbnego.Response(packet.Data())
+	if !response.Valid() {
+		t.Fatal("negotiate returned an invalid response")
+	}
+	if response.DialectRevision() != smbdialect.SMB302 || response.ServerID() != conn.Server || response.Capabilities() != conn.Capabilities() {
+		t.Errorf("negotiate returned dialect %s, server %s, capabilities %s", response.DialectRevision(), response.ServerID(), response.Capabilities())
+	}
+	if response.MaxTransactSize() == 0 || response.MaxReadSize() == 0 || response.MaxWriteSize() == 0 {
+		t.Errorf("negotiate returned transact, read and write limits %d, %d, %d", response.MaxTransactSize(), response.MaxReadSize(), response.MaxWriteSize())
+	}
+
+	// The negotiated parameters are validated later on
+	validate := func(messageID uint64, input []byte) smbpacket.Response {
+		t.Helper()
+		r := testRequest{Command: smbcommand.IOCTL, MessageID: messageID, SessionID: 1, TreeID: 1, Body: ioctlBody(smbioctl.ValidateNegotiateInfo, smbfile.RelatedID, input, 64)}
+		if err := conn.Process(makeMessage(r), smbserver.CommandHandlerFunc(negotiateHandler)); err != nil {
+			t.Fatalf("Process returned %v", err)
+		}
+		select {
+		case b := <-transport.sent:
+			return smbpacket.Response(b)
+		default:
+			return nil
+		}
+	}
+	if packet := validate(1, validateInput(caps, client, mode, dialects...)); packet == nil || packet.Header().Status() != smbstatus.Success {
+		t.Fatal("validation of the negotiated parameters failed")
+	}
+	if packet := validate(2, validateInput(caps, smbid.ID{8}, mode, dialects...)); packet != nil || !transport.closed.Load() {
+		t.Fatal("validation of a tampered client GUID didn't close the connection")
+	}
+
+	// Connections can only be negotiated once
+	conn, transport = newNegotiateConn()
+	negotiate(t, conn, transport, 0, negotiateRequest(caps, client, mode, dialects))
+	msg := makeMessage(testRequest{Command: smbcommand.Negotiate, MessageID: 1, Body: negotiateRequest(caps, client, mode, dialects)})
+	if err := conn.Process(msg, smbserver.CommandHandlerFunc(negotiateHandler)); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	if !transport.closed.Load() {
+		t.Error("second negotiate didn't close the connection")
+	}
+}
+
+func TestNegotiateSMB311(t *testing.T) {
+	posix := smbnego.NewContext(smbnego.POSIXExtensions, smbposix.ExtensionsID)
+
+	conn, transport := newNegotiateConn()
+	conn.POSIXExtensionsSupported = true
+	request, packet := negotiate(t, conn, transport, 0, negotiateBody(preauthCaps(smbintegrity.SHA512), posix))
+	if s := packet.Header().Status(); s != smbstatus.Success {
+		t.Fatalf("negotiate returned %s", s)
+	}
+	response := smbnego.Response(packet.Data())
+	if !response.Valid() || response.DialectRevision() != smbdialect.SMB311 {
+		t.Fatalf("negotiate returned an invalid response for dialect %s", response.DialectRevision())
+	}
+	found := make(map[smbnego.ContextType]smbnego.Context)
+	list := response.ContextList()
+	for i, offset := uint16(0), smbnego.ContextOffset(0); i < response.ContextCount(); i, offset = i+1, list.Next(offset) {
+		context := list.Member(offset)
+		found[context.Type()] = context
+	}
+	if caps := found[smbnego.PreauthIntegrityCaps].PreauthIntegrityCaps(); !caps.Valid() || !caps.Algorithms().Contains(smbintegrity.SHA512) || len(caps.Salt()) == 0 {
+		t.Errorf("negotiate returned preauthentication integrity capabilities %x", []byte(caps))
+	}
+	if context, ok := found[smbnego.POSIXExtensions]; !ok || !conn.POSIXExtensions || !bytes.Equal(context.Data(), smbposix.ExtensionsID) {
+		t.Error("negotiate didn't enable the POSIX extensions")
+	}
+
+	// The request and response begin the preauthentication integrity hash
+	hash := make([]byte, sha512.Size)
+	for _, b := range [][]byte{request, packet} {
+		sum := sha512.Sum512(append(hash, b...))
+		hash = sum[:]
+	}
+	if !bytes.Equal(conn.PreauthIntegrityHash, hash) {
+		t.Error("the preauthentication integrity hash doesn't cover the negotiate request and response")
+	}
+
+	tests := []struct {
+		desc   string
+		body   []byte
+		status smbstatus.Code
+	}{
+		{"missing preauthentication integrity", negotiateBody(posix), smbstatus.InvalidParameter},
+		{"unknown hash algorithm", negotiateBody(preauthCaps(smbintegrity.Algorithm(2))), smbstatus.InvalidParameter},
+		{"unsupported dialect", negotiateRequest(0, smbid.ID{}, smbsecmode.SigningEnabled, []smbdialect.Revision{smbdialect.Revision(0x0310)}), smbstatus.NotSupported},
+	}
+	for _, test := range tests {
+		conn, transport := newNegotiateConn()
+		if _, packet := negotiate(t, conn, transport, 0, test.body); packet.Header().Status() != test.status {
+			t.Errorf("%s: negotiate returned %s (want %s)", test.desc, packet.Header().Status(), test.status)
+		}
+	}
+}
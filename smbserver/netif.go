@@ -0,0 +1,130 @@
+package smbserver
+
+import (
+	"net"
+
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbnetif"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// DefaultLinkSpeed is the link speed reported for network interfaces whose
+// speed can't be determined, in bits per second.
+const DefaultLinkSpeed = 1000000000
+
+// NetworkInterface describes an address of a network interface that is
+// reported to clients, which use it to establish additional channels.
+type NetworkInterface struct {
+	Index        uint32
+	Capabilities smbnetif.Capabilities
+	LinkSpeed    uint64 // In bits per second
+	Address      net.IP
+}
+
+// InterfaceFunc returns the network interfaces that are reported to
+// clients.
+type InterfaceFunc func() ([]NetworkInterface, error)
+
+// StaticInterfaces returns an InterfaceFunc that always reports the given
+// interfaces. It can be used to advertise specific addresses.
+func StaticInterfaces(interfaces ...NetworkInterface) InterfaceFunc {
+	return func() ([]NetworkInterface, error) {
+		return interfaces, nil
+	}
+}
+
+// LocalInterfaces returns the addresses of the network interfaces of the
+// host that are up, excluding loopback interfaces. Link-local IPv6
+// addresses are skipped. The link speed and RSS capability of each
+// interface are read from the operating system where possible.
+func LocalInterfaces() ([]NetworkInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []NetworkInterface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		speed := linkSpeed(iface.Name)
+		if speed == 0 {
+			speed = DefaultLinkSpeed
+		}
+		var caps smbnetif.Capabilities
+		if rssCapable(iface.Name) {
+			caps |= smbnetif.RSS
+		}
+
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			interfaces = append(interfaces, NetworkInterface{
+				Index:        uint32(iface.Index),
+				Capabilities: caps,
+				LinkSpeed:    speed,
+				Address:      ipnet.IP,
+			})
+		}
+	}
+	return interfaces, nil
+}
+
+// interfaces returns the network interfaces that are reported to clients.
+func (c *Conn) interfaces() ([]NetworkInterface, error) {
+	if c.Interfaces != nil {
+		return c.Interfaces()
+	}
+	return LocalInterfaces()
+}
+
+// queryNetworkInterfaceInfo processes an FSCTL_QUERY_NETWORK_INTERFACE_INFO
+// request. It returns an entry for each address of the server's network
+// interfaces.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.15.11
+func (c *Conn) queryNetworkInterfaceInfo(r *Request, ctl FSCTL) Response {
+	if !ctl.FileID.IsRelated() {
+		// The file ID must be 0xFFFFFFFFFFFFFFFF
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	if c.Dialect.Revision().Major() < 3 {
+		return ioctlError(smbstatus.NotSupported)
+	}
+
+	interfaces, err := c.interfaces()
+	if err != nil {
+		return ioctlError(smbstatus.InternalError)
+	}
+
+	output := make([]byte, len(interfaces)*smbnetif.InfoSize)
+	if len(output) > int(ctl.MaxOutput) {
+		return ioctlError(smbstatus.BufferTooSmall)
+	}
+	for i, iface := range interfaces {
+		info := smbnetif.Info(output[i*smbnetif.InfoSize : (i+1)*smbnetif.InfoSize])
+		if i < len(interfaces)-1 {
+			info.SetNext(smbnetif.InfoSize)
+		}
+		info.SetIndex(iface.Index)
+		info.SetCapabilities(iface.Capabilities)
+		info.SetLinkSpeed(iface.LinkSpeed)
+		info.SetAddress(iface.Address)
+	}
+
+	return smbproto.IoctlResponse{
+		CtlCode: smbioctl.QueryNetworkInterfaceInfo,
+		FileID:  smbfile.RelatedID,
+		Output:  output,
+	}
+}
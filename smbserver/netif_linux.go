@@ -0,0 +1,32 @@
+//go:build linux
+
+package smbserver
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// linkSpeed returns the speed of the named network interface in bits per
+// second, or zero if it isn't known.
+func linkSpeed(name string) uint64 {
+	b, err := os.ReadFile(filepath.Join("/sys/class/net", name, "speed"))
+	if err != nil {
+		return 0
+	}
+	// The speed is given in megabits per second, or -1 if it's unknown
+	mbps, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil || mbps <= 0 {
+		return 0
+	}
+	return uint64(mbps) * 1000000
+}
+
+// rssCapable returns true if the named network interface has more than one
+// receive queue, which lets the client spread its channels across them.
+func rssCapable(name string) bool {
+	queues, err := filepath.Glob(filepath.Join("/sys/class/net", name, "queues", "rx-*"))
+	return err == nil && len(queues) > 1
+}
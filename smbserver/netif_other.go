@@ -0,0 +1,14 @@
+//go:build !linux
+
+package smbserver
+
+// linkSpeed returns zero on this platform, which causes the default link
+// speed to be reported.
+func linkSpeed(name string) uint64 {
+	return 0
+}
+
+// rssCapable returns false on this platform.
+func rssCapable(name string) bool {
+	return false
+}
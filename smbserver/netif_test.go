@@ -0,0 +1,110 @@
+package smbserver_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbnetif"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// ioctlBody returns the body of an FSCTL request.
+func ioctlBody(code smbioctl.Code, id smbfile.ID, input []byte, maxOutput uint32) []byte {
+	body := make([]byte, smbioctl.RequestSize+len(input))
+	request := smbioctl.Request(body)
+	request.SetSize(57)
+	request.SetCtlCode(code)
+	request.SetFileID(id)
+	request.SetFlags(smbioctl.IsFSCTL)
+	request.SetMaxOutputResponse(maxOutput)
+	copy(request.SetInputLayout(len(input)), input)
+	return body
+}
+
+// ioctl sends an IOCTL request through conn and returns its response.
+func ioctl(t *testing.T, conn *smbserver.Conn, transport *testTransport, messageID uint64, body []byte) smbpacket.Response {
+	t.Helper()
+	handler := smbserver.CommandHandlerFunc(func(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+		return c.Ioctl(r)
+	})
+	r := testRequest{Command: smbcommand.IOCTL, MessageID: messageID, SessionID: 1, TreeID: 1, Body: body}
+	if err := conn.Process(makeMessage(r), handler); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	b := transport.Next()
+	if b == nil {
+		t.Fatal("no response was sent")
+	}
+	return smbpacket.Response(b)
+}
+
+func TestQueryNetworkInterfaceInfo(t *testing.T) {
+	conn, transport := newTestConn(8)
+	advertised := []smbserver.NetworkInterface{
+		{Index: 2, Capabilities: smbnetif.RSS, LinkSpeed: 10000000000, Address: net.ParseIP("192.0.2.10")},
+		{Index: 3, LinkSpeed: 1000000000, Address: net.ParseIP("2001:db8::10")},
+	}
+	conn.Interfaces = smbserver.StaticInterfaces(advertised...)
+
+	packet := ioctl(t, conn, transport, 0, ioctlBody(smbioctl.QueryNetworkInterfaceInfo, smbfile.RelatedID, nil, 65536))
+	if s := packet.Header().Status(); s != smbstatus.Success {
+		t.Fatalf("query returned %s", s)
+	}
+	response := smbioctl.Response(packet.Data())
+	if !response.Valid() || response.CtlCode() != smbioctl.QueryNetworkInterfaceInfo {
+		t.Fatal("query returned an invalid response")
+	}
+	entries, ok := smbnetif.List(response.Output()).Entries()
+	if !ok || len(entries) != len(advertised) {
+		t.Fatalf("query returned %d entries (want %d)", len(entries), len(advertised))
+	}
+	for i, info := range entries {
+		want := advertised[i]
+		if info.Index() != want.Index || info.Capabilities() != want.Capabilities || info.LinkSpeed() != want.LinkSpeed {
+			t.Errorf("entry %d: got index %d, capabilities %d, speed %d", i, info.Index(), info.Capabilities(), info.LinkSpeed())
+		}
+		if !info.Address().Equal(want.Address) {
+			t.Errorf("entry %d: got address %s (want %s)", i, info.Address(), want.Address)
+		}
+	}
+	if len(entries[0].Address()) != net.IPv4len {
+		t.Errorf("IPv4 address wasn't encoded as SOCKADDR_IN")
+	}
+
+	// The output must fit within the client's limit
+	packet = ioctl(t, conn, transport, 1, ioctlBody(smbioctl.QueryNetworkInterfaceInfo, smbfile.RelatedID, nil, smbnetif.InfoSize))
+	if s := packet.Header().Status(); s != smbstatus.BufferTooSmall {
+		t.Errorf("query with small output limit returned %s", s)
+	}
+
+	// The request must not refer to a file
+	packet = ioctl(t, conn, transport, 2, ioctlBody(smbioctl.QueryNetworkInterfaceInfo, smbfile.ID{Persistent: 1}, nil, 65536))
+	if s := packet.Header().Status(); s != smbstatus.InvalidParameter {
+		t.Errorf("query with file ID returned %s", s)
+	}
+
+	// Multichannel requires SMB 3.x
+	conn.Dialect = smbdialect.SMB21
+	packet = ioctl(t, conn, transport, 3, ioctlBody(smbioctl.QueryNetworkInterfaceInfo, smbfile.RelatedID, nil, 65536))
+	if s := packet.Header().Status(); s != smbstatus.NotSupported {
+		t.Errorf("query on %s returned %s", conn.Dialect, s)
+	}
+}
+
+func TestLocalInterfaces(t *testing.T) {
+	interfaces, err := smbserver.LocalInterfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iface := range interfaces {
+		if iface.Address.IsLoopback() || iface.LinkSpeed == 0 {
+			t.Errorf("unexpected interface %+v", iface)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package smbserver
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbnotify"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// maxPendingChanges is the maximum number of changes that are held for an
+// open between change notification requests. When it is exceeded the next
+// request completes with STATUS_NOTIFY_ENUM_DIR.
+const maxPendingChanges = 1024
+
+// ChangeNotify processes an SMB2 CHANGE_NOTIFY request. It monitors the
+// directory referred to by the request for changes and completes
+// asynchronously when one occurs. The file system backing the directory
+// must implement smbfs.Watcher.
+//
+// The directory is watched from the first change notification request on
+// an open until the open is closed. Each request is completed with the
+// changes that match its own completion filter, and with changes within
+// subdirectories only if it sets SMB2_WATCH_TREE. Changes that occur
+// between requests are held and reported by the next request. If too many
+// changes occur, or they don't fit in the client's output buffer, the
+// request completes with STATUS_NOTIFY_ENUM_DIR to indicate that the
+// client should enumerate the directory again.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.19
+func (c *Conn) ChangeNotify(r *Request) Response {
+	request := smbnotify.Request(r.Data())
+	if !request.Valid() {
+		return notifyError(smbstatus.InvalidParameter)
+	}
+
+	id, ok := r.ResolveFileID(request.FileID())
+	if !ok {
+		return notifyError(smbstatus.InvalidParameter)
+	}
+	open := c.lookupOpen(r, id)
+	if open == nil {
+		return notifyError(smbstatus.FileClosed)
+	}
+	r.SetFileID(id)
+
+	if !open.Directory {
+		return notifyError(smbstatus.InvalidParameter)
+	}
+	length := request.OutputBufferLength()
+	if c.MaxTransactSize > 0 && length > c.MaxTransactSize {
+		return notifyError(smbstatus.InvalidParameter)
+	}
+
+	req := notifyRequest{
+		filter:    request.CompletionFilter(),
+		recursive: request.Flags().Match(smbnotify.WatchTree),
+		length:    length,
+	}
+	n, err := open.watch(req.recursive)
+	if err != nil {
+		if err == smbfs.ErrWatchNotSupported {
+			return notifyError(smbstatus.NotSupported)
+		}
+		return notifyError(smbstatus.Unsuccessful)
+	}
+
+	return n.Wait(c, r, req)
+}
+
+func notifyError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.ChangeNotify, Code: code}
+}
+
+// watch returns the change notifier for the open, creating it if necessary.
+// If recursive is true and the directory is only being watched itself, the
+// watch is replaced by one that includes subdirectories.
+func (o *Open) watch(recursive bool) (*changeNotifier, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.notifier != nil && (o.notifier.recursive || !recursive) {
+		return o.notifier, nil
+	}
+
+	watcher, ok := o.FS.(smbfs.Watcher)
+	if !ok {
+		return nil, smbfs.ErrWatchNotSupported
+	}
+	w, err := watcher.Watch(o.Name, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.notifier != nil {
+		o.notifier.replace(w)
+		return o.notifier, nil
+	}
+	o.notifier = newChangeNotifier(w, recursive)
+	return o.notifier, nil
+}
+
+// changeNotifier collects changes from a watch and delivers them to change
+// notification requests.
+type changeNotifier struct {
+	mutex     sync.Mutex
+	watch     smbfs.Watch
+	recursive bool             // True if the watch includes subdirectories
+	filter    smbnotify.Filter // The union of the filters of the requests so far
+	pending   []pendingChange
+	overflow  bool
+	waiters   []notifyWaiter
+	closed    bool
+}
+
+// notifyRequest describes the changes a change notification request asks
+// for.
+type notifyRequest struct {
+	filter    smbnotify.Filter
+	recursive bool   // True if changes within subdirectories are wanted
+	length    uint32 // Output buffer length
+}
+
+// wants returns true if the request asks for change c.
+func (req notifyRequest) wants(c pendingChange) bool {
+	return req.filter.Overlaps(c.filter) && (req.recursive || !c.nested)
+}
+
+// pendingChange is a change that has not been reported yet.
+type pendingChange struct {
+	change smbnotify.Change
+	filter smbnotify.Filter // The completion filter flags the change matches
+	nested bool             // True if the change is within a subdirectory
+}
+
+// notifyWaiter is a change notification request that is waiting for
+// changes.
+type notifyWaiter struct {
+	notifyRequest
+	command *AsyncCommand
+}
+
+func newChangeNotifier(w smbfs.Watch, recursive bool) *changeNotifier {
+	n := &changeNotifier{
+		watch:     w,
+		recursive: recursive,
+	}
+	go n.run(w)
+	return n
+}
+
+// run receives events from w until it is closed.
+func (n *changeNotifier) run(w smbfs.Watch) {
+	for ev := range w.Events() {
+		n.deliver(ev)
+	}
+}
+
+// replace replaces the watch of n with w, which includes subdirectories.
+func (n *changeNotifier) replace(w smbfs.Watch) {
+	n.mutex.Lock()
+	if n.closed {
+		n.mutex.Unlock()
+		w.Close()
+		return
+	}
+	old := n.watch
+	n.watch, n.recursive = w, true
+	n.mutex.Unlock()
+
+	old.Close()
+	go n.run(w)
+}
+
+// Wait returns a response for the change notification request r if changes
+// that it asks for are already pending. Otherwise it converts r into an
+// asynchronous command that will be completed when such a change occurs.
+func (n *changeNotifier) Wait(c *Conn, r *Request, req notifyRequest) Response {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.closed {
+		return notifyError(smbstatus.NotifyCleanup)
+	}
+
+	n.filter |= req.filter
+	if n.overflow || n.wanted(req) {
+		return n.take(req)
+	}
+	// Changes held for the request that it doesn't ask for are discarded
+	n.pending = nil
+
+	a := c.GoAsync(r)
+	n.waiters = append(n.waiters, notifyWaiter{notifyRequest: req, command: a})
+	return a
+}
+
+// Close stops the watch and completes any waiting requests with
+// STATUS_NOTIFY_CLEANUP.
+func (n *changeNotifier) Close() {
+	n.mutex.Lock()
+	n.closed = true
+	waiters := n.waiters
+	n.waiters = nil
+	n.pending = nil
+	w := n.watch
+	n.mutex.Unlock()
+
+	w.Close()
+
+	for _, w := range waiters {
+		w.command.Complete(smbproto.ChangeNotifyResponse{Code: smbstatus.NotifyCleanup})
+	}
+}
+
+// deliver records an event and completes the first waiting request that
+// asks for the pending changes. If requests are waiting but none of them
+// ask for the pending changes, the changes are discarded.
+func (n *changeNotifier) deliver(ev smbfs.Event) {
+	n.mutex.Lock()
+
+	switch {
+	case n.closed:
+	case ev.Overflow:
+		n.overflow = true
+		n.pending = nil
+	case n.overflow:
+	case n.filter.Overlaps(ev.Filter):
+		if len(n.pending) >= maxPendingChanges {
+			n.overflow = true
+			n.pending = nil
+			break
+		}
+		n.pending = append(n.pending, pendingChange{
+			change: smbnotify.Change{
+				Action: ev.Action,
+				Name:   strings.Replace(ev.Name, "/", `\`, -1),
+			},
+			filter: ev.Filter,
+			nested: n.recursive && strings.Contains(ev.Name, "/"),
+		})
+	}
+
+	if !n.overflow && len(n.pending) == 0 {
+		n.mutex.Unlock()
+		return
+	}
+
+	// Find the first waiter that hasn't been cancelled and asks for the
+	// pending changes
+	waiting := false
+	for i := 0; i < len(n.waiters); {
+		w := n.waiters[i]
+		if w.command.Done() {
+			n.waiters = append(n.waiters[:i], n.waiters[i+1:]...)
+			continue
+		}
+		if !n.overflow && !n.wanted(w.notifyRequest) {
+			waiting = true
+			i++
+			continue
+		}
+		n.waiters = append(n.waiters[:i], n.waiters[i+1:]...)
+		response := n.take(w.notifyRequest)
+		n.mutex.Unlock()
+		w.command.Complete(response)
+		return
+	}
+	if waiting {
+		n.pending = nil
+	}
+
+	n.mutex.Unlock()
+}
+
+// wanted returns true if any of the pending changes are asked for by req.
+//
+// The caller must hold a lock on n.mutex.
+func (n *changeNotifier) wanted(req notifyRequest) bool {
+	for _, c := range n.pending {
+		if req.wants(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// take returns a response that holds the pending changes that req asks for,
+// and clears all pending changes. If the changes overflowed or don't fit
+// in the output buffer of req the response has a status of
+// STATUS_NOTIFY_ENUM_DIR and no changes.
+//
+// The caller must hold a lock on n.mutex.
+func (n *changeNotifier) take(req notifyRequest) Response {
+	var changes []smbnotify.Change
+	for _, c := range n.pending {
+		if req.wants(c) {
+			changes = append(changes, c.change)
+		}
+	}
+	response := smbproto.ChangeNotifyResponse{Code: smbstatus.Success, Changes: changes}
+	if n.overflow || response.Size()-smbnotify.ResponseSize > int(req.length) {
+		response = smbproto.ChangeNotifyResponse{Code: smbstatus.NotifyEnumDir}
+	}
+	n.pending = nil
+	n.overflow = false
+	return response
+}
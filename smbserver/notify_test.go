@@ -0,0 +1,256 @@
+package smbserver_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbnotify"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// watchFS is a file system that reports the events sent to it.
+type watchFS struct {
+	smbfs.FileSystem
+	events chan smbfs.Event
+	closed chan struct{}
+}
+
+func newWatchFS() *watchFS {
+	return &watchFS{
+		events: make(chan smbfs.Event, 16),
+		closed: make(chan struct{}),
+	}
+}
+
+func (fs *watchFS) Watch(name string, recursive bool) (smbfs.Watch, error) {
+	return fs, nil
+}
+
+func (fs *watchFS) Events() <-chan smbfs.Event {
+	return fs.events
+}
+
+func (fs *watchFS) Close() error {
+	close(fs.closed)
+	return nil
+}
+
+func (fs *watchFS) Stat(name string) (os.FileInfo, error) {
+	return nil, os.ErrNotExist
+}
+
+func notifyRequest(id smbfile.ID, messageID uint64, length uint32, filter smbnotify.Filter) testRequest {
+	body := make([]byte, smbnotify.RequestSize)
+	request := smbnotify.Request(body)
+	request.SetSize(32)
+	request.SetOutputBufferLength(length)
+	request.SetFileID(id)
+	request.SetCompletionFilter(filter)
+	return testRequest{Command: smbcommand.ChangeNotify, MessageID: messageID, SessionID: 1, TreeID: 1, Body: body}
+}
+
+func notifyHandler(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+	return c.ChangeNotify(r)
+}
+
+func TestChangeNotify(t *testing.T) {
+	conn, transport := newTestConn(8)
+	conn.Opens = smbserver.NewOpenTable()
+	fs := newWatchFS()
+	open := &smbserver.Open{SessionID: 1, TreeID: 1, FS: fs, Name: "dir", Directory: true}
+	id := addOpen(t, conn, open)
+	handler := smbserver.CommandHandlerFunc(notifyHandler)
+
+	// The first request goes async
+	if err := conn.Process(makeMessage(notifyRequest(id, 0, 1024, smbnotify.FileName)), handler); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	interim := smbpacket.Response(transport.Next())
+	if status := interim.Header().Status(); status != smbstatus.Pending {
+		t.Fatalf("first request returned %s (want %s)", status, smbstatus.Code(smbstatus.Pending))
+	}
+
+	// Changes that don't match the filter are ignored
+	fs.events <- smbfs.Event{Action: smbnotify.Modified, Filter: smbnotify.Size, Name: "ignored.txt"}
+	fs.events <- smbfs.Event{Action: smbnotify.Added, Filter: smbnotify.FileName, Name: "sub/report.docx"}
+
+	final := smbpacket.Response(transport.Next())
+	hdr := final.Header()
+	if status := hdr.Status(); status != smbstatus.Success {
+		t.Fatalf("final response returned %s", status)
+	}
+	if id := hdr.AsyncID(); id != interim.Header().AsyncID() {
+		t.Fatalf("final response has async ID %d (want %d)", id, interim.Header().AsyncID())
+	}
+	response := smbnotify.Response(final.Data())
+	if !response.Valid() {
+		t.Fatal("final response is invalid")
+	}
+	list := response.OutputBuffer()
+	if !list.Valid() {
+		t.Fatal("file notify information list is invalid")
+	}
+	entry := list.Member(0)
+	if action := entry.Action(); action != smbnotify.Added {
+		t.Errorf("change has action %s (want %s)", action, smbnotify.Action(smbnotify.Added))
+	}
+	if name := entry.FileName(); name != `sub\report.docx` {
+		t.Errorf("change has name %q (want %q)", name, `sub\report.docx`)
+	}
+	if next := list.Next(0); next != 0 {
+		t.Errorf("change list has more than one entry")
+	}
+
+	// Changes between requests are held for the next request
+	fs.events <- smbfs.Event{Action: smbnotify.Removed, Filter: smbnotify.FileName, Name: "a.txt"}
+	fs.events <- smbfs.Event{Action: smbnotify.Removed, Filter: smbnotify.FileName, Name: "b.txt"}
+	waitFor(t, func() bool {
+		return len(fs.events) == 0
+	})
+	if err := conn.Process(makeMessage(notifyRequest(id, 1, 1024, smbnotify.FileName)), handler); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	held := smbpacket.Response(transport.Next())
+	if held.Header().Status() != smbstatus.Success || held.Header().Flags().Match(smbpacket.Async) {
+		t.Fatalf("held changes were not returned synchronously")
+	}
+	list = smbnotify.Response(held.Data()).OutputBuffer()
+	count := 0
+	for offset := uint32(0); ; offset = list.Next(offset) {
+		count++
+		if list.Next(offset) == 0 {
+			break
+		}
+	}
+	if count != 2 {
+		t.Fatalf("held response has %d changes (want 2)", count)
+	}
+
+	// Closing the open completes outstanding requests
+	if err := conn.Process(makeMessage(notifyRequest(id, 2, 1024, smbnotify.FileName)), handler); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	transport.Next()
+	conn.Opens.Remove(id).Close()
+	if status := smbpacket.Response(transport.Next()).Header().Status(); status != smbstatus.NotifyCleanup {
+		t.Fatalf("close returned %s (want %s)", status, smbstatus.Code(smbstatus.NotifyCleanup))
+	}
+}
+
+func TestChangeNotifyFilters(t *testing.T) {
+	conn, transport := newTestConn(8)
+	conn.Opens = smbserver.NewOpenTable()
+	fs := newWatchFS()
+	id := addOpen(t, conn, &smbserver.Open{SessionID: 1, TreeID: 1, FS: fs, Name: "dir", Directory: true})
+	handler := smbserver.CommandHandlerFunc(notifyHandler)
+
+	// Two requests on the same open with different filters
+	for i, filter := range []smbnotify.Filter{smbnotify.FileName, smbnotify.Size} {
+		if err := conn.Process(makeMessage(notifyRequest(id, uint64(i), 1024, filter)), handler); err != nil {
+			t.Fatalf("Process returned %v", err)
+		}
+		if status := smbpacket.Response(transport.Next()).Header().Status(); status != smbstatus.Pending {
+			t.Fatalf("request %d returned %s (want %s)", i, status, smbstatus.Code(smbstatus.Pending))
+		}
+	}
+
+	// Each change completes the request that asks for it
+	changes := []struct {
+		Event     smbfs.Event
+		MessageID uint64
+	}{
+		{smbfs.Event{Action: smbnotify.Modified, Filter: smbnotify.Size, Name: "grown.txt"}, 1},
+		{smbfs.Event{Action: smbnotify.Added, Filter: smbnotify.FileName, Name: "added.txt"}, 0},
+	}
+	for _, change := range changes {
+		fs.events <- change.Event
+		final := smbpacket.Response(transport.Next())
+		if status := final.Header().Status(); status != smbstatus.Success {
+			t.Fatalf("change to %s returned %s", change.Event.Name, status)
+		}
+		if messageID := final.Header().MessageID(); messageID != change.MessageID {
+			t.Fatalf("change to %s completed request %d (want %d)", change.Event.Name, messageID, change.MessageID)
+		}
+		list := smbnotify.Response(final.Data()).OutputBuffer()
+		if name := list.Member(0).FileName(); name != change.Event.Name || list.Next(0) != 0 {
+			t.Fatalf("change to %s was reported as %q", change.Event.Name, name)
+		}
+	}
+
+	// Changes that the waiting request doesn't ask for aren't reported to
+	// later requests
+	if err := conn.Process(makeMessage(notifyRequest(id, 2, 1024, smbnotify.LastWrite)), handler); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	transport.Next()
+	fs.events <- smbfs.Event{Action: smbnotify.Removed, Filter: smbnotify.FileName, Name: "removed.txt"}
+	fs.events <- smbfs.Event{Action: smbnotify.Modified, Filter: smbnotify.LastWrite, Name: "written.txt"}
+	list := smbnotify.Response(smbpacket.Response(transport.Next()).Data()).OutputBuffer()
+	if name := list.Member(0).FileName(); name != "written.txt" || list.Next(0) != 0 {
+		t.Fatalf("write was reported as %q", name)
+	}
+	if err := conn.Process(makeMessage(notifyRequest(id, 3, 1024, smbnotify.FileName)), handler); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	if status := smbpacket.Response(transport.Next()).Header().Status(); status != smbstatus.Pending {
+		t.Fatalf("request after a discarded change returned %s (want %s)", status, smbstatus.Code(smbstatus.Pending))
+	}
+	conn.Opens.Remove(id).Close()
+	transport.Next()
+}
+
+func TestChangeNotifyEnumDir(t *testing.T) {
+	conn, transport := newTestConn(8)
+	conn.Opens = smbserver.NewOpenTable()
+	fs := newWatchFS()
+	id := addOpen(t, conn, &smbserver.Open{SessionID: 1, TreeID: 1, FS: fs, Name: "dir", Directory: true})
+	handler := smbserver.CommandHandlerFunc(notifyHandler)
+
+	// A change that doesn't fit in the output buffer
+	if err := conn.Process(makeMessage(notifyRequest(id, 0, 8, smbnotify.FileName)), handler); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	transport.Next()
+	fs.events <- smbfs.Event{Action: smbnotify.Added, Filter: smbnotify.FileName, Name: "long-file-name.txt"}
+	if status := smbpacket.Response(transport.Next()).Header().Status(); status != smbstatus.NotifyEnumDir {
+		t.Fatalf("oversized change returned %s (want %s)", status, smbstatus.Code(smbstatus.NotifyEnumDir))
+	}
+
+	// An overflow reported by the file system
+	if err := conn.Process(makeMessage(notifyRequest(id, 1, 1024, smbnotify.FileName)), handler); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	transport.Next()
+	fs.events <- smbfs.Event{Overflow: true}
+	if status := smbpacket.Response(transport.Next()).Header().Status(); status != smbstatus.NotifyEnumDir {
+		t.Fatalf("overflow returned %s (want %s)", status, smbstatus.Code(smbstatus.NotifyEnumDir))
+	}
+}
+
+func TestChangeNotifyInvalid(t *testing.T) {
+	conn, transport := newTestConn(8)
+	conn.Opens = smbserver.NewOpenTable()
+	fileID := addOpen(t, conn, &smbserver.Open{SessionID: 1, TreeID: 1, FS: newWatchFS(), Name: "file.txt"})
+	handler := smbserver.CommandHandlerFunc(notifyHandler)
+
+	tests := []struct {
+		Request testRequest
+		Status  smbstatus.Code
+	}{
+		{notifyRequest(fileID, 0, 1024, smbnotify.FileName), smbstatus.InvalidParameter},
+		{notifyRequest(smbfile.ID{Persistent: 99, Volatile: 99}, 1, 1024, smbnotify.FileName), smbstatus.FileClosed},
+	}
+	for i, tt := range tests {
+		if err := conn.Process(makeMessage(tt.Request), handler); err != nil {
+			t.Fatalf("test %d: Process returned %v", i, err)
+		}
+		if status := smbpacket.Response(transport.Next()).Header().Status(); status != tt.Status {
+			t.Errorf("test %d: returned %s (want %s)", i, status, tt.Status)
+		}
+	}
+}
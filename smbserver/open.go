@@ -0,0 +1,302 @@
+package smbserver
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcase"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbstore"
+)
+
+var (
+	// ErrSharingViolation is returned when an open can't be added to an
+	// open table because its access conflicts with the share access of
+	// another open of the same file, or vice versa.
+	ErrSharingViolation = errors.New("smbserver: the file is in use by another open")
+
+	// ErrDeletePending is returned when an open can't be added to an open
+	// table because the file is about to be deleted.
+	ErrDeletePending = errors.New("smbserver: the file is pending deletion")
+
+	// errFileIDInUse is returned when an open is restored with a file ID
+	// that is held by another open.
+	errFileIDInUse = errors.New("smbserver: the file ID is in use")
+)
+
+// Open represents an open file or directory on the server.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.1.10
+type Open struct {
+	ID        smbfile.ID
+	SessionID uint64
+	TreeID    uint32
+	FS        smbfs.FileSystem
+	Name      string     // Slash-separated path relative to the root of FS
+	Stream    string     // The name of the alternate data stream of the file, if any
+	Directory bool       // True if the open refers to a directory
+	File      smbfs.File // The underlying file, which may be nil
+
+	// GrantedAccess is the access granted to the open, and ShareAccess is
+	// the access the open permits other opens of the same file to have.
+	GrantedAccess smbaccess.Mask
+	ShareAccess   smbcreate.ShareAccess
+
+	// DeleteOnClose causes the file to be deleted once the open and all
+	// other opens of the file have been closed.
+	DeleteOnClose bool
+
+	// Resilient, Durable and Persistent indicate whether the open survives
+	// the loss of its connection. They determine whether lock sequence
+	// numbers are checked for replayed lock requests.
+	Resilient  bool
+	Durable    bool
+	Persistent bool
+
+	// DurableTimeout is the amount of time a durable open is preserved
+	// after its connection is lost. CreateGUID identifies the create
+	// request that made a version 2 durable open, and ClientGUID
+	// identifies the client that made it. DurableOwner is the user of the
+	// session that made a durable open, which is the only user that may
+	// reconnect to it.
+	DurableTimeout time.Duration
+	CreateGUID     smbid.ID
+	ClientGUID     smbid.ID
+	DurableOwner   smbsecurity.SID
+
+	conn     *Conn          // The connection that receives oplock break notifications, protected by mutex
+	detached bool           // True while a durable open awaits reconnection, protected by mutex
+	file     *sharedFile    // Set while the open is in an open table
+	remove   string         // The name to remove from FS when the open is closed
+	share    string         // The name of the share the open was made in, if known
+	oplock   oplockState    // Protected by file.mutex
+	lease    *Lease         // The lease the open belongs to, if any
+	store    smbstore.Store // Receives the state of persistent opens
+
+	mutex         sync.Mutex
+	notifier      *changeNotifier
+	lockSequences [lockSequenceCount]lockSequence
+	channel       channelState
+	replay        smbproto.CreateResponse // The create response served to replayed creates
+	resume        []byte                  // The resume key of the open, if one has been requested
+	pipe          *namedPipe              // The named pipe the open refers to, if any
+	scan          *directoryScan          // The enumeration of the directory, if one has started
+	names         *smbcase.Resolver       // Told about the removal of the file if names are case-insensitive
+}
+
+// Close releases the resources held by the open, including its underlying
+// file or named pipe. Any outstanding change notification requests are completed with
+// STATUS_NOTIFY_CLEANUP.
+//
+// If the open was the last open of a file or stream that is pending
+// deletion, it is removed from its file system and the leases held on its
+// parent directory are broken.
+func (o *Open) Close() error {
+	o.mutex.Lock()
+	notifier := o.notifier
+	o.notifier = nil
+	o.mutex.Unlock()
+
+	if notifier != nil {
+		notifier.Close()
+	}
+
+	var err error
+	if o.File != nil {
+		err = o.File.Close()
+	}
+	if o.pipe != nil {
+		err = o.pipe.close()
+	}
+	if o.remove != "" {
+		var rerr error
+		if o.Stream != "" {
+			rerr = o.FS.(smbfs.Streamer).RemoveStream(o.remove, o.Stream)
+		} else {
+			rerr = o.FS.Remove(o.remove)
+			if o.names != nil {
+				o.names.Invalidate(path.Dir(o.remove))
+			}
+		}
+		if conn := o.connection(); rerr == nil && conn != nil && conn.Opens != nil {
+			conn.Opens.breakParentLeases(o.FS, o.remove, o.lease, conn.oplockBreakTimeout())
+		}
+		if err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// OpenTable holds the set of open files on the server, keyed by file ID.
+// It holds values for the Server.GlobalOpenTable variable in the SMB
+// protocol. It must be created with NewOpenTable.
+type OpenTable struct {
+	mutex      sync.RWMutex
+	last       uint64              // The last volatile file ID assigned
+	persistent map[uint64]struct{} // Persistent file IDs in use or reserved
+	opens      map[smbfile.ID]*Open
+	files      map[fileKey]*sharedFile
+}
+
+// NewOpenTable returns an empty open table that is ready for use.
+func NewOpenTable() *OpenTable {
+	return &OpenTable{
+		persistent: make(map[uint64]struct{}),
+		opens:      make(map[smbfile.ID]*Open),
+		files:      make(map[fileKey]*sharedFile),
+	}
+}
+
+// Add assigns a new file ID to o and adds it to the table. It returns the
+// assigned file ID. Persistent file IDs are chosen at random, so that
+// clients can't guess the IDs of durable opens made by others.
+//
+// Opens of the same file share state such as share access and byte-range
+// locks. Files are identified by their backend identity if the file system
+// of o implements smbfs.Identifier, and by name otherwise. The file system
+// must be comparable.
+//
+// If the access or share access of o conflicts with another open of the
+// same file Add returns ErrSharingViolation. If the file is pending
+// deletion it returns ErrDeletePending.
+func (t *OpenTable) Add(o *Open) (smbfile.ID, error) {
+	return t.add(o, smbfile.ID{})
+}
+
+// restore adds o to the table with the file ID it held before the server
+// restarted.
+func (t *OpenTable) restore(o *Open, id smbfile.ID) error {
+	_, err := t.add(o, id)
+	return err
+}
+
+// reserve ensures that the table doesn't assign the given persistent file
+// ID, which was restored from a state store, to another open.
+func (t *OpenTable) reserve(persistent uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.persistent[persistent] = struct{}{}
+}
+
+// randomID returns a random persistent file ID that isn't in use,
+// reserved or the persistent part of smbfile.RelatedID.
+//
+// The caller must hold a lock on t.mutex.
+func (t *OpenTable) randomID() (uint64, error) {
+	var b [8]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			return 0, err
+		}
+		id := binary.LittleEndian.Uint64(b[:])
+		if _, used := t.persistent[id]; id != 0 && id != smbfile.RelatedID.Persistent && !used {
+			return id, nil
+		}
+	}
+}
+
+// add adds o to the table with the given file ID. If id is zero a new file
+// ID is assigned.
+func (t *OpenTable) add(o *Open, id smbfile.ID) (smbfile.ID, error) {
+	key := keyFor(o)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if id != (smbfile.ID{}) && t.opens[id] != nil {
+		return smbfile.ID{}, errFileIDInUse
+	}
+	if id == (smbfile.ID{}) {
+		persistent, err := t.randomID()
+		if err != nil {
+			return smbfile.ID{}, err
+		}
+		t.last++
+		id = smbfile.ID{Persistent: persistent, Volatile: t.last}
+	}
+	f := t.file(key)
+	if err := f.attach(o); err != nil {
+		if len(f.opens) == 0 {
+			delete(t.files, key)
+		}
+		return smbfile.ID{}, err
+	}
+	o.ID = id
+	o.file = f
+	t.opens[o.ID] = o
+	t.persistent[id.Persistent] = struct{}{}
+	return o.ID, nil
+}
+
+// Lookup returns the open with the given file ID, or nil.
+func (t *OpenTable) Lookup(id smbfile.ID) *Open {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.opens[id]
+}
+
+// Remove removes the open with the given file ID from the table and returns
+// it. It returns nil if there is no such open. Any byte-range locks held by
+// the open are released and its pending lock requests are cancelled. The
+// caller is responsible for closing the open, which deletes the file if it
+// is pending deletion and this was its last open.
+func (t *OpenTable) Remove(id smbfile.ID) *Open {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	o := t.opens[id]
+	if o == nil {
+		return nil
+	}
+	delete(t.opens, id)
+	delete(t.persistent, id.Persistent)
+	remaining, remove := o.file.detach(o)
+	if remaining == 0 {
+		delete(t.files, o.file.key)
+	}
+	o.remove = remove
+	return o
+}
+
+// Len returns the number of opens in the table.
+func (t *OpenTable) Len() int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return len(t.opens)
+}
+
+// within returns the opens in the table that belong to the given session
+// and tree.
+func (t *OpenTable) within(sessionID uint64, treeID uint32) []*Open {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	var opens []*Open
+	for _, o := range t.opens {
+		if o.belongsTo(sessionID, treeID) {
+			opens = append(opens, o)
+		}
+	}
+	return opens
+}
+
+// lookupOpen returns the open with the given file ID if it belongs to the
+// session and tree of r.
+func (c *Conn) lookupOpen(r *Request, id smbfile.ID) *Open {
+	if c.Opens == nil {
+		return nil
+	}
+	open := c.Opens.Lookup(id)
+	if open == nil || !open.belongsTo(r.SessionID, r.TreeID) {
+		return nil
+	}
+	return open
+}
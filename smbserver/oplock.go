@@ -0,0 +1,269 @@
+package smbserver
+
+import (
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smblease"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// DefaultOplockBreakTimeout is the amount of time the server waits for a
+// client to acknowledge an oplock break when GlobalState.OplockBreakTimeout
+// is zero.
+const DefaultOplockBreakTimeout = 35 * time.Second
+
+// oplockState holds the state of the oplock held by an open. It is
+// protected by the mutex of the open's shared file.
+type oplockState struct {
+	level    smboplock.Level
+	breaking bool            // True while waiting for an acknowledgment
+	breakTo  smboplock.Level // The level the oplock is being broken to
+	done     chan struct{}   // Closed when the break completes
+	timer    *time.Timer     // Revokes the oplock if the break isn't acknowledged
+}
+
+// OplockLevel returns the level of the oplock held by the open. While an
+// oplock break is in progress it returns the level held before the break.
+func (o *Open) OplockLevel() smboplock.Level {
+	f := o.file
+	if f == nil {
+		return smboplock.None
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return o.oplock.level
+}
+
+// OplockBreak processes an SMB2 OPLOCK_BREAK acknowledgment sent by a client
+// in response to an oplock or lease break notification. The open's oplock
+// or the lease is downgraded to the acknowledged level and any creates
+// waiting on the break are allowed to proceed.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.22
+func (c *Conn) OplockBreak(r *Request) Response {
+	if smblease.Ack(r.Data()).Valid() {
+		return c.leaseBreak(r)
+	}
+
+	ack := smboplock.Break(r.Data())
+	if !ack.Valid() {
+		return oplockError(smbstatus.InvalidParameter)
+	}
+
+	id, ok := r.ResolveFileID(ack.FileID())
+	if !ok {
+		return oplockError(smbstatus.InvalidParameter)
+	}
+	open := c.lookupOpen(r, id)
+	if open == nil {
+		return oplockError(smbstatus.FileClosed)
+	}
+	r.SetFileID(id)
+
+	level := ack.OplockLevel()
+	if level != smboplock.None && level != smboplock.LevelII {
+		return oplockError(smbstatus.InvalidParameter)
+	}
+
+	f := open.file
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !open.oplock.breaking {
+		return oplockError(smbstatus.InvalidOplockProtocol)
+	}
+	if level > open.oplock.breakTo {
+		f.completeBreak(open, smboplock.None)
+		return oplockError(smbstatus.InvalidOplockProtocol)
+	}
+	f.completeBreak(open, level)
+
+	return smbproto.OplockBreakResponse{Level: level, FileID: id}
+}
+
+func oplockError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.OplockBreak, Code: code}
+}
+
+// oplockBreakTimeout returns the amount of time to wait for oplock break
+// acknowledgments.
+func (c *Conn) oplockBreakTimeout() time.Duration {
+	if c.OplockBreakTimeout > 0 {
+		return c.OplockBreakTimeout
+	}
+	return DefaultOplockBreakTimeout
+}
+
+// grantOplock grants o the requested oplock level, or the highest level
+// that doesn't conflict with the other opens of the file. Exclusive and
+// batch oplocks are only granted to the sole open of a file. It returns the
+// granted level.
+func (f *sharedFile) grantOplock(o *Open, requested smboplock.Level) smboplock.Level {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch requested {
+	case smboplock.LevelII, smboplock.Exclusive, smboplock.Batch:
+	default:
+		return smboplock.None
+	}
+	if o.Directory {
+		return smboplock.None
+	}
+
+	level := requested
+	for other := range f.opens {
+		if other == o {
+			continue
+		}
+		if other.oplock.level.Exclusive() || other.oplock.breaking {
+			return smboplock.None
+		}
+		if l := other.lease; l != nil && (l.state.Match(smblease.WriteCaching) || l.breaking) {
+			return smboplock.None
+		}
+		level = smboplock.LevelII
+	}
+
+	o.oplock.level = level
+	return level
+}
+
+// breakOplocks breaks the oplocks and leases held on the file identified by
+// key that conflict with a new open of the file. See
+// sharedFile.breakOplocks.
+func (t *OpenTable) breakOplocks(key fileKey, except *leaseID, overwrite bool, timeout time.Duration) <-chan struct{} {
+	t.mutex.RLock()
+	f := t.files[key]
+	t.mutex.RUnlock()
+	if f == nil {
+		return nil
+	}
+	return f.breakOplocks(except, overwrite, timeout)
+}
+
+// breakOplocks breaks the oplocks and leases that conflict with a new open
+// of the file. Exclusive and batch oplocks are broken to level II, and
+// leases lose write caching. If the new open overwrites the file, all
+// oplocks and leases are broken to none. The lease with the given ID, which
+// the new open will belong to, is left alone.
+//
+// It returns a channel that is closed when the breaks that require
+// acknowledgment have completed, or nil if there is nothing to wait for.
+func (f *sharedFile) breakOplocks(except *leaseID, overwrite bool, timeout time.Duration) <-chan struct{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	to := smboplock.Level(smboplock.LevelII)
+	if overwrite {
+		to = smboplock.None
+	}
+
+	var wait <-chan struct{}
+	for o := range f.opens {
+		if done := f.breakOplock(o, to, timeout); done != nil {
+			wait = done
+		}
+	}
+	for _, l := range f.leases(except) {
+		state := l.state &^ smblease.WriteCaching
+		if overwrite {
+			state = smblease.None
+		}
+		if done := f.breakLease(l, state, timeout); done != nil {
+			wait = done
+		}
+	}
+	return wait
+}
+
+// breakReadCaching breaks the level II oplocks and the read caching leases
+// held by opens other than o to none. It is called before o modifies the
+// file, and doesn't wait for acknowledgments.
+func (f *sharedFile) breakReadCaching(o *Open, timeout time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for other := range f.opens {
+		if other != o && other.oplock.level == smboplock.LevelII && !other.oplock.breaking {
+			f.breakOplock(other, smboplock.None, 0)
+		}
+	}
+
+	var except *leaseID
+	if l := o.lease; l != nil {
+		except = &leaseID{client: l.Client, key: l.Key}
+	}
+	for _, l := range f.leases(except) {
+		if l.state.Match(smblease.ReadCaching) {
+			f.breakLease(l, smblease.None, timeout)
+		}
+	}
+}
+
+// breakOplock breaks the oplock held by o to the given level and notifies
+// its client. Breaks of exclusive and batch oplocks require acknowledgment
+// and return a channel that is closed when the break completes. If the
+// break isn't acknowledged within timeout the oplock is revoked.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) breakOplock(o *Open, to smboplock.Level, timeout time.Duration) <-chan struct{} {
+	state := &o.oplock
+	switch {
+	case state.breaking:
+		if to < state.breakTo {
+			state.breakTo = to
+		}
+		return state.done
+	case state.level <= to:
+		return nil
+	case state.level == smboplock.LevelII:
+		// Breaks from level II don't require acknowledgment
+		state.level = smboplock.None
+		o.notifyBreak(smboplock.None)
+		return nil
+	}
+
+	done := make(chan struct{})
+	state.breaking = true
+	state.breakTo = to
+	state.done = done
+	state.timer = time.AfterFunc(timeout, func() {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if state.breaking && state.done == done {
+			f.completeBreak(o, smboplock.None)
+		}
+	})
+	o.notifyBreak(to)
+	return done
+}
+
+// completeBreak finishes an oplock break in progress for o, leaving it with
+// the given oplock level.
+//
+// The caller must hold a lock on f.mutex.
+func (f *sharedFile) completeBreak(o *Open, level smboplock.Level) {
+	state := &o.oplock
+	state.level = level
+	if !state.breaking {
+		return
+	}
+	state.breaking = false
+	state.timer.Stop()
+	close(state.done)
+	state.done = nil
+	state.timer = nil
+}
+
+// notifyBreak sends an oplock break notification to the client of o.
+func (o *Open) notifyBreak(to smboplock.Level) {
+	conn := o.connection()
+	if conn == nil {
+		return
+	}
+	conn.queueNotify(smbproto.OplockBreakResponse{Level: to, FileID: o.ID})
+}
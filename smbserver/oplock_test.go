@@ -0,0 +1,224 @@
+package smbserver_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbwrite"
+)
+
+// collect returns the next n messages sent by the connection. Messages sent
+// by different goroutines may arrive in any order.
+func (ct *createTest) collect(n int) []smbpacket.Response {
+	ct.t.Helper()
+	packets := make([]smbpacket.Response, n)
+	for i := range packets {
+		b := ct.transport.Next()
+		if b == nil {
+			ct.t.Fatalf("received %d of %d expected messages", i, n)
+		}
+		packets[i] = smbpacket.Response(b)
+	}
+	return packets
+}
+
+// notification returns the oplock break notification within packets.
+func (ct *createTest) notification(packets []smbpacket.Response) smboplock.Break {
+	ct.t.Helper()
+	for _, p := range packets {
+		if p.Header().MessageID() == smbserver.UnsolicitedMessageID {
+			b := smboplock.Break(p.Data())
+			if !b.Valid() {
+				ct.t.Fatal("received an invalid oplock break notification")
+			}
+			return b
+		}
+	}
+	ct.t.Fatal("no oplock break notification was sent")
+	return nil
+}
+
+// response returns the response within packets for the given command that
+// isn't an interim response or an oplock break notification.
+func (ct *createTest) response(packets []smbpacket.Response, cmd smbcommand.Code) smbpacket.Response {
+	ct.t.Helper()
+	for _, p := range packets {
+		hdr := p.Header()
+		if hdr.Command() == cmd && hdr.Status() != smbstatus.Pending && hdr.MessageID() != smbserver.UnsolicitedMessageID {
+			return p
+		}
+	}
+	ct.t.Fatalf("no %s response was sent", cmd)
+	return nil
+}
+
+// pending returns true if packets includes an interim response.
+func pending(packets []smbpacket.Response) bool {
+	for _, p := range packets {
+		if p.Header().Status() == smbstatus.Pending {
+			return true
+		}
+	}
+	return false
+}
+
+// acknowledge sends an oplock break acknowledgment without waiting for its
+// response.
+func (ct *createTest) acknowledge(session uint64, id smbfile.ID, level smboplock.Level) {
+	ct.t.Helper()
+	body := make([]byte, smboplock.BreakSize)
+	ack := smboplock.Break(body)
+	ack.SetSize(24)
+	ack.SetOplockLevel(level)
+	ack.SetFileID(id)
+	ct.process(session, smbcommand.OplockBreak, body)
+}
+
+// writeBody returns the body of a request that writes data to the start of
+// a file.
+func writeBody(id smbfile.ID, data string) []byte {
+	body := make([]byte, smbwrite.RequestSize+len(data))
+	request := smbwrite.Request(body)
+	request.SetSize(49)
+	request.SetFileID(id)
+	copy(request.SetDataLayout(len(data)), data)
+	return body
+}
+
+// oplockLevel returns the oplock level of the open with the given file ID.
+func (ct *createTest) oplockLevel(id smbfile.ID) smboplock.Level {
+	ct.t.Helper()
+	open := ct.conn.Opens.Lookup(id)
+	if open == nil {
+		ct.t.Fatalf("open %v not found", id)
+	}
+	return open.OplockLevel()
+}
+
+func newOplockTest(t *testing.T) *createTest {
+	ct := newCreateTest(t)
+	if err := os.WriteFile(filepath.Join(ct.root, "doc.txt"), []byte("contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(ct.root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return ct
+}
+
+func TestOplockGrant(t *testing.T) {
+	ct := newOplockTest(t)
+
+	dir := ct.checkCreate("directory", ct.send(1, smbcommand.Create, createBody(createSpec{Name: "dir", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Batch})), smbstatus.Success)
+	if level := dir.OplockLevel(); level != smboplock.None {
+		t.Errorf("directory open was granted a %s oplock", level)
+	}
+
+	first := ct.checkCreate("first open", ct.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.LevelII})), smbstatus.Success)
+	if level := first.OplockLevel(); level != smboplock.LevelII {
+		t.Errorf("first open was granted a %s oplock (want LevelII)", level)
+	}
+
+	second := ct.checkCreate("second open", ct.send(2, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Batch})), smbstatus.Success)
+	if level := second.OplockLevel(); level != smboplock.LevelII {
+		t.Errorf("second open was granted a %s oplock (want LevelII)", level)
+	}
+
+	ct.close(1, first.FileID())
+	ct.close(2, second.FileID())
+
+	sole := ct.checkCreate("sole open", ct.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Exclusive})), smbstatus.Success)
+	if level := sole.OplockLevel(); level != smboplock.Exclusive {
+		t.Errorf("sole open was granted a %s oplock (want Exclusive)", level)
+	}
+}
+
+func TestOplockBreak(t *testing.T) {
+	ct := newOplockTest(t)
+
+	first := ct.checkCreate("batch open", ct.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Batch})), smbstatus.Success)
+	if level := first.OplockLevel(); level != smboplock.Batch {
+		t.Fatalf("first open was granted a %s oplock (want Batch)", level)
+	}
+
+	// The second open must wait for the batch oplock to be broken
+	ct.process(2, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.LevelII}))
+	packets := ct.collect(2)
+	if !pending(packets) {
+		t.Fatal("create that broke an oplock did not complete asynchronously")
+	}
+	notification := ct.notification(packets)
+	if level := notification.OplockLevel(); level != smboplock.LevelII {
+		t.Errorf("oplock break notification has level %s (want LevelII)", level)
+	}
+	if id := notification.FileID(); id != first.FileID() {
+		t.Errorf("oplock break notification refers to file %v (want %v)", id, first.FileID())
+	}
+
+	ct.acknowledge(1, first.FileID(), smboplock.LevelII)
+	packets = ct.collect(2)
+	if s := ct.response(packets, smbcommand.OplockBreak).Header().Status(); s != smbstatus.Success {
+		t.Fatalf("oplock break acknowledgment returned %s", s)
+	}
+	second := ct.checkCreate("second open", ct.response(packets, smbcommand.Create), smbstatus.Success)
+	if level := second.OplockLevel(); level != smboplock.LevelII {
+		t.Errorf("second open was granted a %s oplock (want LevelII)", level)
+	}
+	if level := ct.oplockLevel(first.FileID()); level != smboplock.LevelII {
+		t.Errorf("first open has a %s oplock after the break (want LevelII)", level)
+	}
+
+	// A write by the second open breaks the level II oplock of the first
+	// without waiting for an acknowledgment
+	ct.process(2, smbcommand.Write, writeBody(second.FileID(), "data"))
+	packets = ct.collect(2)
+	if s := ct.response(packets, smbcommand.Write).Header().Status(); s != smbstatus.Success {
+		t.Fatalf("write returned %s", s)
+	}
+	if level := ct.notification(packets).OplockLevel(); level != smboplock.None {
+		t.Errorf("oplock break notification has level %s (want None)", level)
+	}
+	if level := ct.oplockLevel(first.FileID()); level != smboplock.None {
+		t.Errorf("first open has a %s oplock after the write (want None)", level)
+	}
+	if level := ct.oplockLevel(second.FileID()); level != smboplock.LevelII {
+		t.Errorf("writer has a %s oplock after the write (want LevelII)", level)
+	}
+}
+
+func TestOplockBreakTimeout(t *testing.T) {
+	ct := newOplockTest(t)
+	ct.conn.OplockBreakTimeout = 20 * time.Millisecond
+
+	first := ct.checkCreate("exclusive open", ct.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Exclusive})), smbstatus.Success)
+
+	// An overwrite breaks the oplock to none. The break is never
+	// acknowledged, so the oplock is revoked once the timeout elapses.
+	ct.process(2, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Overwrite}))
+	packets := ct.collect(3)
+	if level := ct.notification(packets).OplockLevel(); level != smboplock.None {
+		t.Errorf("oplock break notification has level %s (want None)", level)
+	}
+	created := ct.checkCreate("overwrite", ct.response(packets, smbcommand.Create), smbstatus.Success)
+	if action := created.CreateAction(); action != smbcreate.Overwritten {
+		t.Errorf("create returned action %s (want Overwritten)", action)
+	}
+	if level := ct.oplockLevel(first.FileID()); level != smboplock.None {
+		t.Errorf("first open has a %s oplock after the timeout (want None)", level)
+	}
+
+	ct.acknowledge(1, first.FileID(), smboplock.None)
+	if s := ct.collect(1)[0].Header().Status(); s != smbstatus.InvalidOplockProtocol {
+		t.Errorf("late oplock break acknowledgment returned %s (want InvalidOplockProtocol)", s)
+	}
+}
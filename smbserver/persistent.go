@@ -0,0 +1,354 @@
+package smbserver
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbstore"
+)
+
+// persistentHandles returns true if persistent handles can be granted on
+// the connection. Persistent handles require SMB 3.0 or later, a durable
+// open table and a state store.
+func (c *Conn) persistentHandles() bool {
+	return c.Store != nil && c.Durable != nil && c.Dialect.Ready() && c.Dialect.Revision() >= smbdialect.SMB3
+}
+
+// continuouslyAvailable returns true if fsys backs a continuously available
+// share.
+func continuouslyAvailable(fsys smbfs.FileSystem) bool {
+	ca, ok := fsys.(smbfs.ContinuouslyAvailable)
+	return ok && ca.ContinuouslyAvailable()
+}
+
+// handle returns the persistent state of o.
+func (o *Open) handle() smbstore.Handle {
+	h := smbstore.Handle{
+		FileID:        o.ID,
+		CreateGUID:    o.CreateGUID,
+		ClientGUID:    o.ClientGUID,
+		Owner:         o.DurableOwner,
+		Share:         o.share,
+		Name:          o.Name,
+		Directory:     o.Directory,
+		GrantedAccess: o.GrantedAccess,
+		ShareAccess:   o.ShareAccess,
+		DeleteOnClose: o.DeleteOnClose,
+		Timeout:       o.DurableTimeout,
+	}
+
+	o.mutex.Lock()
+	for i, entry := range o.lockSequences {
+		if entry.valid {
+			h.LockSequences = append(h.LockSequences, smbstore.LockSequence{Index: i + 1, Number: entry.number})
+		}
+	}
+	o.mutex.Unlock()
+
+	f := o.file
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, l := range f.locks {
+		if l.owner == o {
+			h.Locks = append(h.Locks, smbstore.Lock{Offset: l.offset, Length: l.length, Exclusive: l.exclusive})
+		}
+	}
+	if l := o.lease; l != nil {
+		h.Lease = &smbstore.Lease{
+			Key:       l.Key,
+			ParentKey: l.ParentKey,
+			HasParent: l.HasParent,
+			Version:   l.Version,
+			State:     l.state,
+			Epoch:     l.epoch,
+		}
+	}
+	return h
+}
+
+// persist writes the state of o to its store if o is persistent. It is
+// called whenever the state of a persistent open changes.
+func (o *Open) persist() error {
+	if o.store == nil {
+		return nil
+	}
+	return o.store.Save(o.handle())
+}
+
+// unpersist removes the state of o from its store if o is persistent. It
+// is called once a persistent open has been closed or has expired.
+func (o *Open) unpersist() error {
+	if o.store == nil {
+		return nil
+	}
+	return o.store.Delete(o.ID.Persistent)
+}
+
+// persist writes the state of the persistent opens that belong to l to
+// their stores.
+func (l *Lease) persist() {
+	f := l.file
+	f.mutex.Lock()
+	var opens []*Open
+	for o := range l.opens {
+		if o.store != nil {
+			opens = append(opens, o)
+		}
+	}
+	f.mutex.Unlock()
+	for _, o := range opens {
+		o.persist()
+	}
+}
+
+// releaseDurable removes o from the durable open table and the state
+// store before it is closed.
+func (c *Conn) releaseDurable(o *Open) {
+	if c.Durable != nil {
+		c.Durable.remove(o)
+	}
+	o.unpersist()
+}
+
+// reclaim processes a create request that reconnects to a persistent open
+// whose state was restored from the state store after the server
+// restarted. The file is opened again and the open's locks and lease are
+// reinstated. The store holds the handles of every share, so the open is
+// only reclaimed within the share it was made in.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.9.12
+func (c *Conn) reclaim(p *createParams, req *reconnectRequest, h smbstore.Handle) Response {
+	if !req.v2 || h.CreateGUID != req.createGUID || h.ClientGUID != c.ClientGUID {
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+	if !c.persistentHandles() || !continuouslyAvailable(p.fsys) {
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+	if !strings.EqualFold(h.Share, p.shareName) {
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+	if h.Name != p.name {
+		return createError(smbstatus.InvalidParameter)
+	}
+	switch {
+	case h.Lease == nil && p.lease != nil:
+		return createError(smbstatus.ObjectNameNotFound)
+	case h.Lease != nil && (p.lease == nil || p.lease.key != h.Lease.Key || c.Leases == nil):
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+	if !c.token(p.sessionID).User.Equal(h.Owner) {
+		return createError(smbstatus.AccessDenied)
+	}
+
+	if !c.Durable.claim(h.FileID.Persistent) {
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+
+	var (
+		file smbfs.File
+		err  error
+	)
+	if h.Directory {
+		file, err = p.fsys.OpenFile(h.Name, os.O_RDONLY, 0)
+	} else {
+		file, err = p.fsys.OpenFile(h.Name, openFlag(h.GrantedAccess, false), 0)
+	}
+	if err != nil {
+		c.Store.Delete(h.FileID.Persistent)
+		return createError(createStatus(err))
+	}
+
+	open := &Open{
+		SessionID:      p.sessionID,
+		TreeID:         p.treeID,
+		FS:             p.fsys,
+		Name:           h.Name,
+		Directory:      h.Directory,
+		File:           file,
+		GrantedAccess:  h.GrantedAccess,
+		ShareAccess:    h.ShareAccess,
+		DeleteOnClose:  h.DeleteOnClose,
+		Durable:        true,
+		Persistent:     true,
+		DurableTimeout: h.Timeout,
+		CreateGUID:     h.CreateGUID,
+		ClientGUID:     h.ClientGUID,
+		DurableOwner:   h.Owner,
+		conn:           c,
+		share:          h.Share,
+		store:          c.Store,
+		channel:        channelState{sequence: p.channel},
+	}
+	for _, entry := range h.LockSequences {
+		if entry.Index > 0 && entry.Index <= lockSequenceCount {
+			open.lockSequences[entry.Index-1] = lockSequence{valid: true, number: entry.Number}
+		}
+	}
+
+	if err := c.Opens.restore(open, h.FileID); err != nil {
+		file.Close()
+		c.Store.Delete(h.FileID.Persistent)
+		switch err {
+		case ErrSharingViolation:
+			return createError(smbstatus.SharingViolation)
+		case ErrDeletePending:
+			return createError(smbstatus.DeletePending)
+		default:
+			return createError(smbstatus.ObjectNameNotFound)
+		}
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		c.Opens.Remove(open.ID).Close()
+		c.Store.Delete(h.FileID.Persistent)
+		return createError(fileStatus(err))
+	}
+
+	response := smbproto.CreateResponse{
+		Action:      smbcreate.Opened,
+		FileID:      open.ID,
+		OplockLevel: smboplock.None,
+	}
+	if err := open.file.reinstate(open, c, h); err != nil {
+		c.Opens.Remove(open.ID).Close()
+		c.Store.Delete(h.FileID.Persistent)
+		return createError(smbstatus.InvalidParameter)
+	}
+	if h.Lease != nil {
+		response.OplockLevel = smboplock.Lease
+		response.Contexts = open.file.leaseContext(open)
+	}
+	setCreateInfo(&response, open, info)
+	open.cacheCreate(response)
+
+	c.Durable.add(open)
+	open.persist()
+	return response
+}
+
+// reinstate restores the byte-range locks and the lease recorded in h for
+// o, which has been reclaimed after a restart of the server.
+func (f *sharedFile) reinstate(o *Open, c *Conn, h smbstore.Handle) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, l := range h.Locks {
+		f.locks = append(f.locks, byteRangeLock{owner: o, offset: l.Offset, length: l.Length, exclusive: l.Exclusive})
+	}
+
+	if h.Lease == nil {
+		return nil
+	}
+	l, created, err := c.Leases.acquire(leaseID{client: c.ClientGUID, key: h.Lease.Key}, f)
+	if err != nil {
+		return err
+	}
+	if created {
+		l.Version = h.Lease.Version
+		l.ParentKey, l.HasParent = h.Lease.ParentKey, h.Lease.HasParent
+		l.state = h.Lease.State
+		l.epoch = h.Lease.Epoch
+	}
+	l.opens[o] = struct{}{}
+	l.conn = c
+	o.lease = l
+	return nil
+}
+
+// leaseContext returns the lease create context that describes the lease
+// of o.
+func (f *sharedFile) leaseContext(o *Open) []byte {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return o.lease.responseContext()
+}
+
+// reclaimable is the restored state of a persistent open that is waiting
+// to be reclaimed by its client after a restart of the server.
+type reclaimable struct {
+	handle smbstore.Handle
+	timer  *time.Timer
+}
+
+// Restore loads the persistent handles held by store and adds them to the
+// table, where they can be reclaimed by their clients until their timeouts
+// elapse. Expired handles are deleted from the store. The file IDs of the
+// handles are reserved in opens so that they aren't assigned to new opens.
+// Restore should be called once, before connections are served.
+func (t *DurableTable) Restore(store smbstore.Store, opens *OpenTable) error {
+	handles, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, h := range handles {
+		persistent := h.FileID.Persistent
+		opens.reserve(persistent)
+		t.reclaims[persistent] = &reclaimable{
+			handle: h,
+			timer: time.AfterFunc(t.timeout(h.Timeout), func() {
+				if t.claim(persistent) {
+					store.Delete(persistent)
+				}
+			}),
+		}
+	}
+	return nil
+}
+
+// reclaimable returns the restored handle with the given persistent file
+// ID, if there is one.
+func (t *DurableTable) reclaimable(persistent uint64) (smbstore.Handle, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	r := t.reclaims[persistent]
+	if r == nil {
+		return smbstore.Handle{}, false
+	}
+	return r.handle, true
+}
+
+// claim removes the restored handle with the given persistent file ID from
+// the table. It returns false if the handle has already been claimed or
+// has expired.
+func (t *DurableTable) claim(persistent uint64) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	r := t.reclaims[persistent]
+	if r == nil {
+		return false
+	}
+	r.timer.Stop()
+	delete(t.reclaims, persistent)
+	return true
+}
+
+// Reclaimable returns the number of persistent handles restored from the
+// state store that are waiting to be reclaimed.
+func (t *DurableTable) Reclaimable() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.reclaims)
+}
+
+// Restore loads the persistent handles held by store and makes them
+// available for reclaiming by reconnecting clients. Persistent handles are
+// granted on continuously available shares once a store has been set. It
+// should be called once, before s starts serving connections.
+func (s *Server) Restore(store smbstore.Store) error {
+	if err := s.durable.Restore(store, s.opens); err != nil {
+		return err
+	}
+	s.store = store
+	return nil
+}
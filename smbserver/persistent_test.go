@@ -0,0 +1,219 @@
+package smbserver_test
+
+import (
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbcap"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbdurable"
+	"github.com/gentlemanautomaton/smb/smbfilestore"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbstore"
+)
+
+// persistentContext appends a serialized version 2 durable handle request
+// create context that requests a persistent handle to list.
+func persistentContext(list []byte, guid smbid.ID) []byte {
+	data := smbdurable.RequestV2(make([]byte, smbdurable.RequestV2Size))
+	data.SetFlags(smbdurable.Persistent)
+	data.SetCreateGUID(guid)
+	return smbcreate.AppendContext(list, smbcreate.DurableHandleRequestV2, data)
+}
+
+// newPersistentTest returns a pair of connections that share their tables
+// and a file-based state store, and serve a continuously available file
+// system.
+func newPersistentTest(t *testing.T) (a, b *createTest, store *smbfilestore.Store) {
+	a, b = newDurableTest(t)
+	store, err := smbfilestore.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.conn.Store = store
+	b.conn.Store = store
+	a.fs.SetContinuouslyAvailable(true)
+	return a, b, store
+}
+
+// handles returns the handles in store.
+func handles(t *testing.T, store smbstore.Store) []smbstore.Handle {
+	t.Helper()
+	handles, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load returned %v", err)
+	}
+	return handles
+}
+
+// restart returns a connection from the client of ct to a server that has
+// restarted and restored its state from store.
+func (ct *createTest) restart(store smbstore.Store) *createTest {
+	ct.t.Helper()
+	conn, transport := newTestConn(64)
+	conn.Opens = smbserver.NewOpenTable()
+	conn.Leases = smbserver.NewLeaseTable()
+	conn.Durable = smbserver.NewDurableTable()
+	conn.Store = store
+	conn.ClientGUID = ct.conn.ClientGUID
+	if err := conn.Durable.Restore(store, conn.Opens); err != nil {
+		ct.t.Fatalf("Restore returned %v", err)
+	}
+	return &createTest{t: ct.t, root: ct.root, conn: conn, transport: transport, fs: ct.fs}
+}
+
+func TestPersistentCapabilities(t *testing.T) {
+	a, _, _ := newPersistentTest(t)
+	if caps := a.conn.Capabilities(); !caps.Match(smbcap.PersistentHandles) {
+		t.Errorf("connection with a state store advertises %s", caps)
+	}
+	a.conn.Store = nil
+	if caps := a.conn.Capabilities(); caps.Match(smbcap.PersistentHandles) {
+		t.Errorf("connection without a state store advertises %s", caps)
+	}
+}
+
+func TestPersistentGrant(t *testing.T) {
+	a, _, store := newPersistentTest(t)
+
+	// Persistent handles don't require handle caching
+	response := a.checkCreate("persistent open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Contexts: persistentContext(nil, createGUID)})), smbstatus.Success)
+	data, ok := a.durableResponse(response, smbcreate.DurableHandleRequestV2)
+	if !ok {
+		t.Fatal("open was not made persistent")
+	}
+	if flags := smbdurable.ResponseV2(data).Flags(); !flags.Match(smbdurable.Persistent) {
+		t.Errorf("durable handle response has flags %d (want Persistent)", flags)
+	}
+	id := response.FileID()
+
+	// Lock changes are written through to the store
+	if s := a.send(1, smbcommand.Lock, lockBody(id, 0, 0, lockRange{Offset: 8, Length: 2, Flags: exclusiveImmediate})).Header().Status(); s != smbstatus.Success {
+		t.Fatalf("lock returned %s", s)
+	}
+	saved := handles(t, store)
+	if len(saved) != 1 {
+		t.Fatalf("store holds %d handles (want 1)", len(saved))
+	}
+	if h := saved[0]; h.FileID != id || h.Name != "doc.txt" || h.CreateGUID != createGUID || len(h.Locks) != 1 || h.Locks[0] != (smbstore.Lock{Offset: 8, Length: 2, Exclusive: true}) {
+		t.Errorf("store holds %+v", h)
+	}
+
+	a.close(1, id)
+	if n := len(handles(t, store)); n != 0 {
+		t.Errorf("store holds %d handles after close (want 0)", n)
+	}
+
+	// File systems that aren't continuously available don't get
+	// persistent handles
+	a.fs.SetContinuouslyAvailable(false)
+	response = a.checkCreate("open on a regular share", a.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Batch, Contexts: persistentContext(nil, otherGUID)})), smbstatus.Success)
+	data, ok = a.durableResponse(response, smbcreate.DurableHandleRequestV2)
+	if !ok {
+		t.Fatal("open with a batch oplock was not made durable")
+	}
+	if flags := smbdurable.ResponseV2(data).Flags(); flags.Match(smbdurable.Persistent) {
+		t.Error("open on a regular share was made persistent")
+	}
+	if n := len(handles(t, store)); n != 0 {
+		t.Errorf("store holds %d handles for a durable open (want 0)", n)
+	}
+}
+
+func TestPersistentReclaim(t *testing.T) {
+	a, _, store := newPersistentTest(t)
+
+	contexts := persistentContext(leaseContext(leaseKey, rh, nil), createGUID)
+	response := a.checkCreate("persistent open", a.send(1, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: contexts})), smbstatus.Success)
+	id := response.FileID()
+	if s := a.send(1, smbcommand.Lock, lockBody(id, 0, 0, lockRange{Offset: 0, Length: 4, Flags: exclusiveImmediate})).Header().Status(); s != smbstatus.Success {
+		t.Fatalf("lock returned %s", s)
+	}
+
+	// The server restarts without closing the open
+	c := a.restart(store)
+	if n := c.conn.Durable.Reclaimable(); n != 1 {
+		t.Fatalf("durable open table holds %d reclaimable handles (want 1)", n)
+	}
+
+	// New opens don't reuse the file ID of the restored handle
+	other := c.create("new open", 2, createSpec{Name: "other.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.OpenIf}, smbstatus.Success)
+	if other.Persistent == id.Persistent {
+		t.Errorf("new open was assigned the file ID %v of a restored handle %v", other, id)
+	}
+
+	reclaim := func(desc string, contexts []byte, status smbstatus.Code) smbcreate.Response {
+		t.Helper()
+		return c.checkCreate(desc, c.send(3, smbcommand.Create, createBody(createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: contexts})), status)
+	}
+	reclaim("wrong create GUID", reconnectContextV2(leaseContext(leaseKey, rh, nil), id, otherGUID), smbstatus.ObjectNameNotFound)
+	reclaim("version 1 reconnect", reconnectContext(leaseContext(leaseKey, rh, nil), id), smbstatus.ObjectNameNotFound)
+
+	reclaimed := reclaim("reclaim", reconnectContextV2(leaseContext(leaseKey, rh, nil), id, createGUID), smbstatus.Success)
+	if got := reclaimed.FileID(); got != id {
+		t.Errorf("reclaim returned file ID %v (want %v)", got, id)
+	}
+	if state := c.grantedLease(reclaimed).State(); state != rh {
+		t.Errorf("reclaim returned lease state %s (want RH)", state)
+	}
+	if n := c.conn.Durable.Reclaimable(); n != 0 {
+		t.Errorf("durable open table holds %d reclaimable handles after reclaim (want 0)", n)
+	}
+
+	// The lock was reinstated
+	second := c.create("second open", 2, createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+	if s := c.send(2, smbcommand.Lock, lockBody(second, 0, 0, lockRange{Offset: 0, Length: 4, Flags: exclusiveImmediate})).Header().Status(); s != smbstatus.LockNotGranted {
+		t.Errorf("conflicting lock returned %s (want LockNotGranted)", s)
+	}
+	if s := c.send(3, smbcommand.Lock, lockBody(id, 0, 0, lockRange{Offset: 0, Length: 4, Flags: unlock})).Header().Status(); s != smbstatus.Success {
+		t.Errorf("unlock of the reinstated lock returned %s", s)
+	}
+
+	c.close(3, id)
+	if n := len(handles(t, store)); n != 0 {
+		t.Errorf("store holds %d handles after close (want 0)", n)
+	}
+}
+
+func TestPersistentOwner(t *testing.T) {
+	a, _, store := newPersistentTest(t)
+	alice := a.login("alice")
+
+	spec := createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: persistentContext(leaseContext(leaseKey, rh, nil), createGUID)}
+	id := a.checkCreate("persistent open", a.send(alice, smbcommand.Create, createBody(spec)), smbstatus.Success).FileID()
+
+	// Only the user that made the open can reclaim it after a restart
+	c := a.restart(store)
+	spec.Contexts = reconnectContextV2(leaseContext(leaseKey, rh, nil), id, createGUID)
+	c.checkCreate("reclaim by another user", c.send(c.login("bob"), smbcommand.Create, createBody(spec)), smbstatus.AccessDenied)
+	if n := c.conn.Durable.Reclaimable(); n != 1 {
+		t.Fatalf("durable open table holds %d reclaimable handles after a denied reclaim (want 1)", n)
+	}
+	c.checkCreate("reclaim by the owner", c.send(c.login("alice"), smbcommand.Create, createBody(spec)), smbstatus.Success)
+}
+
+func TestPersistentShare(t *testing.T) {
+	a, _, store := newPersistentTest(t)
+	spec := createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Lease, Contexts: persistentContext(leaseContext(leaseKey, rh, nil), createGUID)}
+	id := a.checkCreate("persistent open", a.send(1, smbcommand.Create, createBody(spec)), smbstatus.Success).FileID()
+
+	// Handles are only reclaimed within the share they were made in
+	saved := handles(t, store)
+	if len(saved) != 1 {
+		t.Fatalf("store holds %d handles (want 1)", len(saved))
+	}
+	h := saved[0]
+	h.Share = "Other"
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+	c := a.restart(store)
+	spec.Contexts = reconnectContextV2(leaseContext(leaseKey, rh, nil), id, createGUID)
+	c.checkCreate("reclaim within another share", c.send(1, smbcommand.Create, createBody(spec)), smbstatus.ObjectNameNotFound)
+	if n := c.conn.Durable.Reclaimable(); n != 1 {
+		t.Fatalf("durable open table holds %d reclaimable handles after a reclaim within another share (want 1)", n)
+	}
+}
@@ -0,0 +1,405 @@
+package smbserver
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbpipe"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+// errPipeBusy is returned when a pipe transaction is attempted while
+// replies to an earlier message are still waiting to be read.
+var errPipeBusy = errors.New("smbserver: the pipe has unread data")
+
+// Pipe is the server end of an open named pipe. Pipes operate in message
+// mode: each message written by the client is passed to Transact, and the
+// replies it returns are queued for the client to read, one message per
+// reply.
+//
+// Calls to Transact are serialized for each open of the pipe.
+type Pipe interface {
+	// Transact processes a message written to the pipe by the client and
+	// returns the replies that the client should read, which may be empty.
+	// If it returns an error the write fails with STATUS_PIPE_BROKEN.
+	Transact(message []byte) (replies [][]byte, err error)
+
+	// Close releases the resources held by the pipe when its open is
+	// closed.
+	Close() error
+}
+
+// A PipeHandler creates the server end of a named pipe each time a client
+// opens the pipe. The name is the name the pipe was registered with.
+type PipeHandler interface {
+	OpenPipe(c *Conn, name string) (Pipe, error)
+}
+
+// PipeHandlerFunc is a function that can act as a PipeHandler.
+type PipeHandlerFunc func(c *Conn, name string) (Pipe, error)
+
+// OpenPipe creates the server end of the named pipe.
+func (h PipeHandlerFunc) OpenPipe(c *Conn, name string) (Pipe, error) {
+	return h(c, name)
+}
+
+// PipeRegistry maps pipe names to the handlers of named pipes within the
+// IPC$ share. Names are case-insensitive. It must be created with
+// NewPipeRegistry.
+type PipeRegistry struct {
+	mutex    sync.RWMutex
+	handlers map[string]PipeHandler
+}
+
+// NewPipeRegistry returns an empty pipe registry that is ready for use.
+func NewPipeRegistry() *PipeRegistry {
+	return &PipeRegistry{
+		handlers: make(map[string]PipeHandler),
+	}
+}
+
+// Register causes h to serve the named pipe with the given name. If h is
+// nil the pipe is no longer served.
+func (reg *PipeRegistry) Register(name string, h PipeHandler) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	name = strings.ToLower(name)
+	if h == nil {
+		delete(reg.handlers, name)
+		return
+	}
+	reg.handlers[name] = h
+}
+
+// Handler returns the handler for the pipe with the given name, or nil.
+func (reg *PipeRegistry) Handler(name string) PipeHandler {
+	reg.mutex.RLock()
+	defer reg.mutex.RUnlock()
+	return reg.handlers[strings.ToLower(name)]
+}
+
+// pipeHandler returns the handler for the pipe with the given name, or nil.
+func (c *Conn) pipeHandler(name string) PipeHandler {
+	if c.Pipes == nil {
+		return nil
+	}
+	return c.Pipes.Handler(name)
+}
+
+// namedPipe is the state of an open of a named pipe.
+type namedPipe struct {
+	mutex   sync.Mutex
+	pipe    Pipe
+	pending [][]byte // Replies waiting to be read, in order
+}
+
+// write passes a message written by the client to the pipe and queues its
+// replies.
+func (p *namedPipe) write(message []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	replies, err := p.pipe.Transact(message)
+	if err != nil {
+		return err
+	}
+	p.queue(replies)
+	return nil
+}
+
+// read returns up to max bytes of the next reply waiting to be read. If the
+// reply is longer than max, the rest of it remains to be read and more is
+// true. It returns false if no replies are waiting.
+func (p *namedPipe) read(max int) (data []byte, more bool, ok bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.pending) == 0 {
+		return nil, false, false
+	}
+	data, more = p.next(max)
+	return data, more, true
+}
+
+// transceive passes a message written by the client to the pipe and
+// returns up to max bytes of its first reply. Any data that doesn't fit is
+// queued to be read. It returns errPipeBusy if earlier replies are still
+// waiting to be read.
+func (p *namedPipe) transceive(message []byte, max int) (data []byte, more bool, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.pending) > 0 {
+		return nil, false, errPipeBusy
+	}
+	replies, err := p.pipe.Transact(message)
+	if err != nil {
+		return nil, false, err
+	}
+	p.queue(replies)
+	if len(p.pending) == 0 {
+		return nil, false, nil
+	}
+	data, more = p.next(max)
+	return data, more, nil
+}
+
+// peek returns the number of bytes and messages waiting to be read, along
+// with the first message, without consuming them.
+func (p *namedPipe) peek() (available, messages int, first []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, reply := range p.pending {
+		available += len(reply)
+	}
+	if len(p.pending) > 0 {
+		first = p.pending[0]
+	}
+	return available, len(p.pending), first
+}
+
+// queue appends non-empty replies to the pending replies.
+func (p *namedPipe) queue(replies [][]byte) {
+	for _, reply := range replies {
+		if len(reply) > 0 {
+			p.pending = append(p.pending, reply)
+		}
+	}
+}
+
+// next consumes up to max bytes of the first pending reply. It must be
+// called with p.mutex held and at least one reply pending.
+func (p *namedPipe) next(max int) (data []byte, more bool) {
+	reply := p.pending[0]
+	if len(reply) > max {
+		p.pending[0] = reply[max:]
+		return reply[:max], true
+	}
+	p.pending = p.pending[1:]
+	return reply, false
+}
+
+// close closes the pipe and discards its pending replies.
+func (p *namedPipe) close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pending = nil
+	return p.pipe.Close()
+}
+
+// CreatePipe processes an SMB2 CREATE request within a pipe share. It opens
+// a new instance of the named pipe registered with the name of the
+// request, which may carry a PIPE\ prefix. Creates of pipes that haven't
+// been registered fail with STATUS_OBJECT_NAME_NOT_FOUND.
+//
+// Reads, writes and pipe control requests made through the open are passed
+// to the pipe instead of a file.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.9
+func (c *Conn) CreatePipe(r *Request) Response {
+	request := smbcreate.Request(r.Data())
+	if !request.Valid() {
+		return createError(smbstatus.InvalidParameter)
+	}
+	if c.Opens == nil {
+		return createError(smbstatus.InsufficientResources)
+	}
+
+	name, ok := pipeName(request.Name())
+	if !ok {
+		return createError(smbstatus.ObjectNameInvalid)
+	}
+	h := c.pipeHandler(name)
+	if h == nil {
+		return createError(smbstatus.ObjectNameNotFound)
+	}
+
+	access := request.DesiredAccess().MapGeneric()
+	if access.Match(smbaccess.MaximumAllowed) {
+		access = access&^smbaccess.MaximumAllowed | smbaccess.FileAllAccess
+	}
+
+	p, err := h.OpenPipe(c, name)
+	if err != nil {
+		return createError(fileStatus(err))
+	}
+
+	// Each open is a separate instance of the pipe, so opens never
+	// conflict with one another
+	open := &Open{
+		SessionID:     r.SessionID,
+		TreeID:        r.TreeID,
+		Name:          name,
+		GrantedAccess: access,
+		ShareAccess:   smbcreate.ShareRead | smbcreate.ShareWrite | smbcreate.ShareDelete,
+		ClientGUID:    c.ClientGUID,
+		conn:          c,
+		pipe:          &namedPipe{pipe: p},
+	}
+	id, err := c.Opens.Add(open)
+	if err != nil {
+		p.Close()
+		return createError(createStatus(err))
+	}
+	r.SetFileID(id)
+
+	return smbproto.CreateResponse{
+		Action:     smbcreate.Opened,
+		Attributes: smbfile.Normal,
+		FileID:     id,
+	}
+}
+
+// readPipe reads the next reply waiting to be read from the pipe of open.
+// Replies that are longer than the read are returned in parts, each of
+// which but the last is returned with STATUS_BUFFER_OVERFLOW. Pipes are
+// served synchronously, so reads of a pipe that has no replies waiting
+// fail with STATUS_PIPE_EMPTY rather than waiting for one.
+func (c *Conn) readPipe(open *Open, length uint32) Response {
+	data, more, ok := open.pipe.read(int(length))
+	if !ok {
+		return readError(smbstatus.PipeEmpty)
+	}
+	response := smbproto.ReadResponse{Data: data}
+	if more {
+		response.Code = smbstatus.BufferOverflow
+	}
+	return response
+}
+
+// writePipe writes a message to the pipe of open.
+func (c *Conn) writePipe(open *Open, message []byte) Response {
+	if err := open.pipe.write(message); err != nil {
+		return writeError(smbstatus.PipeBroken)
+	}
+	return smbproto.WriteResponse{Count: uint32(len(message))}
+}
+
+// lookupPipe returns the open of a named pipe that a pipe control request
+// refers to, or the response that should be sent if there is none.
+func (c *Conn) lookupPipe(r *Request, ctl FSCTL) (*Open, Response) {
+	open := c.lookupOpen(r, ctl.FileID)
+	if open == nil {
+		return nil, ioctlError(smbstatus.FileClosed)
+	}
+	if open.pipe == nil {
+		return nil, ioctlError(smbstatus.InvalidDeviceRequest)
+	}
+	return open, nil
+}
+
+// pipeTransceive processes an FSCTL_PIPE_TRANSCEIVE request. It writes the
+// input of the request to a named pipe and returns the first reply as its
+// output. Replies that don't fit in the output are returned in part with
+// STATUS_BUFFER_OVERFLOW, and the rest can be read from the pipe. Requests
+// made while earlier replies are waiting to be read fail with
+// STATUS_PIPE_BUSY.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.15.3
+func (c *Conn) pipeTransceive(r *Request, ctl FSCTL) Response {
+	open, response := c.lookupPipe(r, ctl)
+	if open == nil {
+		return response
+	}
+
+	data, more, err := open.pipe.transceive(ctl.Input, int(ctl.MaxOutput))
+	switch {
+	case err == errPipeBusy:
+		return ioctlError(smbstatus.PipeBusy)
+	case err != nil:
+		return ioctlError(smbstatus.PipeBroken)
+	}
+
+	ioctl := smbproto.IoctlResponse{
+		CtlCode: ctl.Code,
+		FileID:  ctl.FileID,
+		Output:  data,
+	}
+	if more {
+		ioctl.Code = smbstatus.BufferOverflow
+	}
+	return ioctl
+}
+
+// pipePeek processes an FSCTL_PIPE_PEEK request. It describes the replies
+// waiting to be read from a named pipe and returns as much of the first
+// one as fits, without consuming them. If the first reply doesn't fit the
+// response carries STATUS_BUFFER_OVERFLOW.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.15.4
+func (c *Conn) pipePeek(r *Request, ctl FSCTL) Response {
+	open, response := c.lookupPipe(r, ctl)
+	if open == nil {
+		return response
+	}
+	if ctl.MaxOutput < smbpipe.PeekReplySize {
+		return ioctlError(smbstatus.BufferTooSmall)
+	}
+
+	available, messages, first := open.pipe.peek()
+	n := len(first)
+	if max := int(ctl.MaxOutput) - smbpipe.PeekReplySize; n > max {
+		n = max
+	}
+
+	reply := smbpipe.PeekReply(make([]byte, smbpipe.PeekReplySize+n))
+	reply.SetState(smbpipe.Connected)
+	reply.SetReadDataAvailable(uint32(available))
+	reply.SetNumberOfMessages(uint32(messages))
+	reply.SetMessageLength(uint32(len(first)))
+	copy(reply.Data(), first)
+
+	ioctl := smbproto.IoctlResponse{
+		CtlCode: ctl.Code,
+		FileID:  ctl.FileID,
+		Output:  reply,
+	}
+	if n < len(first) {
+		ioctl.Code = smbstatus.BufferOverflow
+	}
+	return ioctl
+}
+
+// pipeWait processes an FSCTL_PIPE_WAIT request, which is sent within the
+// IPC$ share and doesn't refer to an open. A new instance of a registered
+// pipe is created for each open, so registered pipes are always available
+// and the request succeeds immediately. Waits for pipes that haven't been
+// registered fail with STATUS_OBJECT_NAME_NOT_FOUND.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.15.10
+func (c *Conn) pipeWait(r *Request, ctl FSCTL) Response {
+	if tree := c.lookupTree(r); tree != nil && tree.Share.Type != smbtree.Pipe {
+		return ioctlError(smbstatus.InvalidDeviceRequest)
+	}
+
+	request := smbpipe.WaitRequest(ctl.Input)
+	if !request.Valid() {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	name, ok := pipeName(request.Name())
+	if !ok || c.pipeHandler(name) == nil {
+		return ioctlError(smbstatus.ObjectNameNotFound)
+	}
+
+	return smbproto.IoctlResponse{
+		CtlCode: ctl.Code,
+		FileID:  ctl.FileID,
+	}
+}
+
+// pipeName returns the name of a pipe within the IPC$ share, without any
+// leading backslash or PIPE\ prefix. It returns false if the name is
+// invalid.
+func pipeName(name string) (string, bool) {
+	name = strings.TrimPrefix(name, `\`)
+	if len(name) > 5 && strings.EqualFold(name[:5], `PIPE\`) {
+		name = name[5:]
+	}
+	if name == "" || strings.ContainsAny(name, "\\/\x00") {
+		return "", false
+	}
+	return name, true
+}
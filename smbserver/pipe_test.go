@@ -0,0 +1,303 @@
+package smbserver_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbclose"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbpipe"
+	"github.com/gentlemanautomaton/smb/smbread"
+	"github.com/gentlemanautomaton/smb/smbrpc"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbwrite"
+)
+
+// echoPipe replies to each message with a copy of the message.
+type echoPipe struct {
+	closed *int32
+}
+
+func (p echoPipe) Transact(message []byte) ([][]byte, error) {
+	return [][]byte{append([]byte(nil), message...)}, nil
+}
+
+func (p echoPipe) Close() error {
+	atomic.AddInt32(p.closed, 1)
+	return nil
+}
+
+// openPipe opens the named pipe with the given name within the current
+// tree.
+func (tt *treeTest) openPipe(name string) smbfile.ID {
+	tt.t.Helper()
+	spec := createSpec{Name: name, Access: readWrite, Share: shareAll, Disposition: smbcreate.Open}
+	packet := tt.send(smbcommand.Create, createBody(spec))
+	tt.check("open "+name, packet, smbstatus.Success)
+	return smbcreate.Response(packet.Data()).FileID()
+}
+
+// readPipe reads up to length bytes from a pipe and checks the status of
+// the read.
+func (tt *treeTest) readPipe(id smbfile.ID, length uint32, status smbstatus.Code) []byte {
+	tt.t.Helper()
+	body := make([]byte, smbread.RequestSize+1)
+	request := smbread.Request(body)
+	request.SetSize(49)
+	request.SetLength(length)
+	request.SetFileID(id)
+	packet := tt.send(smbcommand.Read, body)
+	tt.check("read", packet, status)
+	if status.Failure() {
+		return nil
+	}
+	return smbread.Response(packet.Data()).Data()
+}
+
+// writePipe writes a message to a pipe.
+func (tt *treeTest) writePipe(id smbfile.ID, message []byte) {
+	tt.t.Helper()
+	body := make([]byte, smbwrite.RequestSize+len(message))
+	request := smbwrite.Request(body)
+	request.SetSize(49)
+	request.SetFileID(id)
+	copy(request.SetDataLayout(len(message)), message)
+	tt.check("write", tt.send(smbcommand.Write, body), smbstatus.Success)
+}
+
+// fsctl sends a file system control request and checks its status. It
+// returns the output of the response.
+func (tt *treeTest) fsctl(desc string, code smbioctl.Code, id smbfile.ID, input []byte, maxOutput uint32, status smbstatus.Code) []byte {
+	tt.t.Helper()
+	packet := tt.send(smbcommand.IOCTL, ioctlBody(code, id, input, maxOutput))
+	tt.check(desc, packet, status)
+	response := smbioctl.Response(packet.Data())
+	if !response.Valid() {
+		return nil
+	}
+	return response.Output()
+}
+
+func TestNamedPipe(t *testing.T) {
+	tt := newTreeTest(t)
+	var closed int32
+	tt.conn.Pipes.Register("echo", smbserver.PipeHandlerFunc(func(c *smbserver.Conn, name string) (smbserver.Pipe, error) {
+		return echoPipe{closed: &closed}, nil
+	}))
+	tt.connect(`\\server\IPC$`, smbstatus.Success)
+
+	id := tt.openPipe(`PIPE\Echo`)
+	tt.readPipe(id, 64, smbstatus.PipeEmpty)
+
+	// Messages that don't fit in a read are returned in parts
+	tt.writePipe(id, []byte("hello world"))
+	if data := tt.readPipe(id, 5, smbstatus.BufferOverflow); string(data) != "hello" {
+		t.Fatalf("first part of a message: %q", data)
+	}
+	if data := tt.readPipe(id, 64, smbstatus.Success); string(data) != " world" {
+		t.Fatalf("last part of a message: %q", data)
+	}
+
+	// Peeks describe the pending messages without consuming them
+	tt.fsctl("peek with a small buffer", smbioctl.PipePeek, id, nil, 8, smbstatus.BufferTooSmall)
+	tt.writePipe(id, []byte("abc"))
+	tt.writePipe(id, []byte("de"))
+	reply := smbpipe.PeekReply(tt.fsctl("peek", smbioctl.PipePeek, id, nil, 64, smbstatus.Success))
+	if !reply.Valid() || reply.State() != smbpipe.Connected || reply.ReadDataAvailable() != 5 || reply.NumberOfMessages() != 2 || reply.MessageLength() != 3 || string(reply.Data()) != "abc" {
+		t.Fatalf("peek: unexpected reply %v", []byte(reply))
+	}
+	reply = smbpipe.PeekReply(tt.fsctl("partial peek", smbioctl.PipePeek, id, nil, smbpipe.PeekReplySize+1, smbstatus.BufferOverflow))
+	if string(reply.Data()) != "a" {
+		t.Fatalf("partial peek: returned %q", reply.Data())
+	}
+
+	// Transactions can't be made while replies are waiting to be read
+	tt.fsctl("busy transceive", smbioctl.PipeTransceive, id, []byte("ping"), 64, smbstatus.PipeBusy)
+	tt.readPipe(id, 64, smbstatus.Success)
+	tt.readPipe(id, 64, smbstatus.Success)
+	if output := tt.fsctl("transceive", smbioctl.PipeTransceive, id, []byte("ping"), 64, smbstatus.Success); string(output) != "ping" {
+		t.Fatalf("transceive: returned %q", output)
+	}
+	if output := tt.fsctl("partial transceive", smbioctl.PipeTransceive, id, []byte("pong"), 2, smbstatus.BufferOverflow); string(output) != "po" {
+		t.Fatalf("partial transceive: returned %q", output)
+	}
+	if data := tt.readPipe(id, 64, smbstatus.Success); string(data) != "ng" {
+		t.Fatalf("rest of a partial transceive: %q", data)
+	}
+
+	wait := func(name string) []byte {
+		input := make([]byte, smbpipe.WaitRequestBufferSize(name))
+		smbpipe.WaitRequest(input).SetName(name)
+		return input
+	}
+	tt.fsctl("wait for a registered pipe", smbioctl.PipeWait, smbfile.ID{}, wait("echo"), 0, smbstatus.Success)
+	tt.fsctl("wait for a missing pipe", smbioctl.PipeWait, smbfile.ID{}, wait("missing"), 0, smbstatus.ObjectNameNotFound)
+
+	tt.close(id)
+	if atomic.LoadInt32(&closed) != 1 {
+		t.Fatal("the pipe wasn't closed with its open")
+	}
+}
+
+// close closes an open within the current tree.
+func (tt *treeTest) close(id smbfile.ID) {
+	tt.t.Helper()
+	body := make([]byte, smbclose.RequestSize)
+	request := smbclose.Request(body)
+	request.SetSize(24)
+	request.SetFileID(id)
+	tt.check("close", tt.send(smbcommand.Close, body), smbstatus.Success)
+}
+
+// testInterface is an RPC interface whose first operation echoes its
+// input and whose second operation returns n bytes, where n is its input.
+type testInterface struct{}
+
+var testSyntax = smbrpc.SyntaxID{
+	UUID:    smbid.ID{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+	Version: 1,
+}
+
+func (testInterface) Syntax() smbrpc.SyntaxID {
+	return testSyntax
+}
+
+func (testInterface) Call(c *smbserver.Conn, opnum uint16, stub []byte) ([]byte, smbrpc.FaultStatus) {
+	switch opnum {
+	case 0:
+		return stub, 0
+	case 1:
+		if len(stub) != 4 {
+			return nil, smbrpc.BadStubData
+		}
+		n := int(stub[0]) | int(stub[1])<<8
+		out := make([]byte, n)
+		for i := range out {
+			out[i] = byte(i)
+		}
+		return out, 0
+	default:
+		return nil, smbrpc.OpRangeError
+	}
+}
+
+// rpcRequest returns a request PDU.
+func rpcRequest(callID uint32, context, opnum uint16, flags smbrpc.Flags, stub []byte) []byte {
+	r := smbrpc.Request(make([]byte, smbrpc.RequestSize+len(stub)))
+	r.Header().Init(smbrpc.TypeRequest, flags, uint16(len(r)), callID)
+	r.SetAllocHint(uint32(len(stub)))
+	r.SetContextID(context)
+	r.SetOpnum(opnum)
+	copy(r.Stub(), stub)
+	return r
+}
+
+// checkFault fails the test if pdu isn't a fault PDU with the given status.
+func checkFault(t *testing.T, desc string, pdu []byte, status smbrpc.FaultStatus) {
+	t.Helper()
+	fault := smbrpc.Fault(pdu)
+	if !fault.Valid() {
+		t.Fatalf("%s: reply is not a fault", desc)
+	}
+	if s := fault.Status(); s != status {
+		t.Fatalf("%s: fault status is %s (want %s)", desc, s, status)
+	}
+}
+
+func TestRPCPipe(t *testing.T) {
+	tt := newTreeTest(t)
+	tt.conn.Pipes.Register("test", smbserver.RPCPipe(testInterface{}))
+	tt.connect(`\\server\IPC$`, smbstatus.Success)
+	id := tt.openPipe("test")
+
+	unknown := testSyntax
+	unknown.Version = 2
+	contexts := []smbrpc.Context{
+		smbrpc.NewContext(0, testSyntax, smbrpc.NDR64, smbrpc.NDR),
+		smbrpc.NewContext(1, unknown, smbrpc.NDR),
+		smbrpc.NewContext(2, testSyntax, smbrpc.NDR64),
+	}
+	bind := smbrpc.Bind(make([]byte, smbrpc.BindBufferSize(contexts...)))
+	bind.Header().Init(smbrpc.TypeBind, smbrpc.FirstFrag|smbrpc.LastFrag, uint16(len(bind)), 1)
+	bind.SetMaxXmitFrag(1024)
+	bind.SetMaxRecvFrag(1024)
+	bind.SetContexts(contexts...)
+
+	ack := smbrpc.BindAck(tt.fsctl("bind", smbioctl.PipeTransceive, id, bind, 4280, smbstatus.Success))
+	if !ack.Valid() || ack.Header().Type() != smbrpc.TypeBindAck {
+		t.Fatal("bind: reply is not a bind_ack")
+	}
+	if addr := ack.Address(); addr != `\PIPE\test` {
+		t.Fatalf("bind: secondary address is %q", addr)
+	}
+	if ack.MaxXmitFrag() != 1024 || ack.AssocGroupID() == 0 || ack.Header().CallID() != 1 {
+		t.Fatalf("bind: max_xmit_frag %d, assoc_group_id %d, call_id %d", ack.MaxXmitFrag(), ack.AssocGroupID(), ack.Header().CallID())
+	}
+	want := []smbrpc.Result{
+		{Result: smbrpc.Acceptance, TransferSyntax: smbrpc.NDR},
+		{Result: smbrpc.ProviderRejection, Reason: smbrpc.AbstractSyntaxNotSupported},
+		{Result: smbrpc.ProviderRejection, Reason: smbrpc.ProposedTransferSyntaxesNotSupported},
+	}
+	if n := ack.ResultCount(); n != len(want) {
+		t.Fatalf("bind: %d results (want %d)", n, len(want))
+	}
+	for i := range want {
+		if result := ack.Result(i); result != want[i] {
+			t.Fatalf("bind: result %d is %+v (want %+v)", i, result, want[i])
+		}
+	}
+
+	// A call carried by a single fragment
+	response := smbrpc.Response(tt.fsctl("call", smbioctl.PipeTransceive, id, rpcRequest(2, 0, 0, smbrpc.FirstFrag|smbrpc.LastFrag, []byte("hello")), 4280, smbstatus.Success))
+	if !response.Valid() || response.Header().CallID() != 2 || string(response.Stub()) != "hello" {
+		t.Fatalf("call: unexpected reply %v", []byte(response))
+	}
+
+	// A call carried by several fragments is invoked once all of them
+	// have arrived
+	tt.writePipe(id, rpcRequest(3, 0, 0, smbrpc.FirstFrag, []byte("frag")))
+	tt.readPipe(id, 4280, smbstatus.PipeEmpty)
+	response = smbrpc.Response(tt.fsctl("fragmented call", smbioctl.PipeTransceive, id, rpcRequest(3, 0, 0, smbrpc.LastFrag, []byte("mented")), 4280, smbstatus.Success))
+	if !response.Valid() || string(response.Stub()) != "fragmented" {
+		t.Fatalf("fragmented call: unexpected reply %v", []byte(response))
+	}
+
+	// Results that don't fit in a fragment are returned in several
+	// fragments, each of which is a separate message
+	const size = 3000
+	pdu := tt.fsctl("large call", smbioctl.PipeTransceive, id, rpcRequest(4, 0, 1, smbrpc.FirstFrag|smbrpc.LastFrag, []byte{size & 0xff, size >> 8, 0, 0}), 4280, smbstatus.Success)
+	var stub []byte
+	for i := 0; ; i++ {
+		response := smbrpc.Response(pdu)
+		if !response.Valid() || len(response) > 1024 {
+			t.Fatalf("large call: fragment %d is invalid or too long (%d bytes)", i, len(response))
+		}
+		flags := response.Header().Flags()
+		if flags.Match(smbrpc.FirstFrag) != (i == 0) {
+			t.Fatalf("large call: fragment %d has flags %#x", i, flags)
+		}
+		stub = append(stub, response.Stub()...)
+		if flags.Match(smbrpc.LastFrag) {
+			break
+		}
+		pdu = tt.readPipe(id, 4280, smbstatus.Success)
+	}
+	if len(stub) != size {
+		t.Fatalf("large call: returned %d bytes (want %d)", len(stub), size)
+	}
+	for i := range stub {
+		if stub[i] != byte(i) {
+			t.Fatalf("large call: byte %d is %d", i, stub[i])
+		}
+	}
+	tt.readPipe(id, 4280, smbstatus.PipeEmpty)
+
+	checkFault(t, "call to a rejected context", tt.fsctl("call to a rejected context", smbioctl.PipeTransceive, id, rpcRequest(5, 1, 0, smbrpc.FirstFrag|smbrpc.LastFrag, nil), 4280, smbstatus.Success), smbrpc.UnknownInterface)
+	checkFault(t, "call to an unknown operation", tt.fsctl("call to an unknown operation", smbioctl.PipeTransceive, id, rpcRequest(6, 0, 9, smbrpc.FirstFrag|smbrpc.LastFrag, nil), 4280, smbstatus.Success), smbrpc.OpRangeError)
+	tt.fsctl("malformed PDU", smbioctl.PipeTransceive, id, []byte("not a PDU"), 4280, smbstatus.PipeBroken)
+}
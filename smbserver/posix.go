@@ -0,0 +1,174 @@
+package smbserver
+
+import (
+	"os"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbinfo"
+	"github.com/gentlemanautomaton/smb/smbnego"
+	"github.com/gentlemanautomaton/smb/smbposix"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbreparse"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// NegotiatePOSIX processes the SMB2_POSIX_EXTENSIONS_AVAILABLE negotiate
+// context of an SMB2 NEGOTIATE request, which must be valid. It must be
+// called once the dialect of the connection has been selected, which
+// Negotiate does before it calls it.
+//
+// If SMB 3.1.1 was selected, the server supports the POSIX extensions and
+// the client offered the version implemented by smbposix, the extensions
+// are enabled for the connection and the context that acknowledges them is
+// returned, which belongs in the negotiate response. Otherwise it returns
+// nil.
+func (c *Conn) NegotiatePOSIX(request smbnego.Request) smbnego.Context {
+	c.POSIXExtensions = false
+	if !c.POSIXExtensionsSupported || c.Dialect.Revision() != smbdialect.SMB311 {
+		return nil
+	}
+	context, ok := request.ContextList().Find(request.ContextCount(), smbnego.POSIXExtensions)
+	if !ok || !context.POSIXExtensions(smbposix.ExtensionsID) {
+		return nil
+	}
+	c.POSIXExtensions = true
+	return smbnego.NewContext(smbnego.POSIXExtensions, smbposix.ExtensionsID)
+}
+
+// posixFile describes a file to clients that have negotiated the POSIX
+// extensions.
+type posixFile struct {
+	info     os.FileInfo
+	attrs    smbfile.Attributes
+	identity smbfs.Identity
+	owner    smbsecurity.SID
+	group    smbsecurity.SID
+	links    uint32
+}
+
+// describePOSIX returns the POSIX description of the file with the given
+// name and information within fsys.
+//
+// Files are owned by the POSIX users and groups that own them within fsys
+// if it implements smbfs.Owner, and by the owner and group of their
+// security descriptors otherwise.
+func describePOSIX(fsys smbfs.FileSystem, name string, info os.FileInfo, attrs smbfile.Attributes) posixFile {
+	f := posixFile{info: info, attrs: attrs, links: 1}
+	if identifier, ok := fsys.(smbfs.Identifier); ok {
+		f.identity, _ = identifier.Identify(info)
+	}
+	if owner, ok := fsys.(smbfs.Owner); ok {
+		if ownership, ok := owner.Ownership(info); ok {
+			f.owner = smbsecurity.UnixUser(ownership.UID)
+			f.group = smbsecurity.UnixGroup(ownership.GID)
+			f.links = ownership.Links
+			return f
+		}
+	}
+	sd, err := fileSecurity(fsys, name)
+	if err != nil || sd.Owner == nil || sd.Group == nil {
+		sd = defaultSecurity()
+	}
+	f.owner, f.group = sd.Owner, sd.Group
+	return f
+}
+
+// size returns the number of bytes required for the POSIX information of
+// the file.
+func (f posixFile) size() int {
+	return smbposix.InfoSize(f.owner, f.group)
+}
+
+// mode returns the POSIX mode of the file.
+func (f posixFile) mode() smbposix.Mode {
+	return smbposix.ModeOf(f.info.Mode())
+}
+
+// reparseTag returns the reparse tag of the file, which is only set for
+// symbolic links.
+func (f posixFile) reparseTag() smbreparse.Tag {
+	if isSymlink(f.info) {
+		return smbreparse.Symlink
+	}
+	return 0
+}
+
+// put writes the POSIX information of the file to i, which must be at least
+// f.size() bytes long.
+func (f posixFile) put(i smbposix.Info) {
+	i.SetCreationTime(f.info.ModTime())
+	i.SetLastAccessTime(f.info.ModTime())
+	i.SetLastWriteTime(f.info.ModTime())
+	i.SetChangeTime(f.info.ModTime())
+	i.SetEndOfFile(endOfFile(f.info))
+	i.SetAllocationSize(allocationSize(f.info))
+	i.SetAttributes(f.attrs)
+	i.SetInode(f.identity.Inode)
+	i.SetDevice(uint32(f.identity.Device))
+	i.SetLinks(f.links)
+	i.SetReparseTag(f.reparseTag())
+	i.SetMode(f.mode())
+	i.SetOwnerGroup(f.owner, f.group)
+}
+
+// createContext returns the data of the POSIX create context that answers
+// a create of the file.
+func (f posixFile) createContext() []byte {
+	data := smbposix.CreateResponse(make([]byte, smbposix.CreateResponseSize(f.owner, f.group)))
+	data.SetLinks(f.links)
+	data.SetReparseTag(f.reparseTag())
+	data.SetMode(f.mode())
+	data.SetOwnerGroup(f.owner, f.group)
+	return data
+}
+
+// parsePOSIXContext returns the permissions carried by the POSIX create
+// context in contexts, if there is one and the POSIX extensions were
+// negotiated. It returns false if the context is invalid.
+func (c *Conn) parsePOSIXContext(contexts smbcreate.ContextList) (perm *os.FileMode, ok bool) {
+	if !c.POSIXExtensions {
+		return nil, true
+	}
+	context, found := contexts.Find(smbcreate.POSIX)
+	if !found {
+		return nil, true
+	}
+	data := smbposix.CreateRequest(context.Data())
+	if !data.Valid() {
+		return nil, false
+	}
+	mode := data.Mode().FileMode()
+	return &mode, true
+}
+
+// queryPOSIX returns the POSIX information of the file of open. If the
+// information doesn't fit in the output buffer it is truncated, and the
+// query returns STATUS_BUFFER_OVERFLOW.
+func (c *Conn) queryPOSIX(open *Open, request smbinfo.QueryRequest) Response {
+	if open.pipe != nil {
+		return queryInfoError(smbstatus.NotSupported)
+	}
+	if !open.GrantedAccess.Match(smbaccess.ReadAttributes) {
+		return queryInfoError(smbstatus.AccessDenied)
+	}
+	if request.OutputBufferLength() < smbposix.InfoHeaderSize {
+		return queryInfoError(smbstatus.InfoLengthMismatch)
+	}
+
+	info, err := open.stat()
+	if err != nil {
+		return queryInfoError(fileStatus(err))
+	}
+	f := describePOSIX(open.FS, open.Name, info, fileAttributes(open, info))
+	output := make([]byte, f.size())
+	f.put(smbposix.Info(output))
+	if max := int(request.OutputBufferLength()); len(output) > max {
+		return smbproto.QueryInfoResponse{Code: smbstatus.BufferOverflow, Output: output[:max]}
+	}
+	return smbproto.QueryInfoResponse{Output: output}
+}
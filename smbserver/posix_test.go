@@ -0,0 +1,240 @@
+package smbserver_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbdir"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbinfo"
+	"github.com/gentlemanautomaton/smb/smbnego"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+	"github.com/gentlemanautomaton/smb/smbposix"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+func TestNegotiatePOSIX(t *testing.T) {
+	conn, _ := newTestConn(1)
+	offer := smbnego.NewContext(smbnego.POSIXExtensions, smbposix.ExtensionsID)
+	other := smbnego.NewContext(smbnego.ContextType(0x0001), make([]byte, 38))
+	unknown := smbnego.NewContext(smbnego.POSIXExtensions, make([]byte, 16))
+
+	tests := []struct {
+		desc      string
+		supported bool
+		dialect   smbdialect.State
+		body      []byte
+		want      bool
+	}{
+		{"unsupported", false, smbdialect.SMB311, negotiateBody(other, offer), false},
+		{"offered", true, smbdialect.SMB311, negotiateBody(other, offer), true},
+		{"not offered", true, smbdialect.SMB311, negotiateBody(other), false},
+		{"unknown version", true, smbdialect.SMB311, negotiateBody(unknown), false},
+		{"SMB 3.0.2", true, smbdialect.SMB302, negotiateBody(offer), false},
+	}
+	for _, test := range tests {
+		request := smbnego.Request(test.body)
+		if !request.Valid() {
+			t.Fatalf("%s: invalid request", test.desc)
+		}
+		conn.POSIXExtensionsSupported = test.supported
+		conn.Dialect = test.dialect
+		ack := conn.NegotiatePOSIX(request)
+		if got := ack != nil; got != test.want || conn.POSIXExtensions != test.want {
+			t.Fatalf("%s: acknowledged %t, enabled %t (want %t)", test.desc, got, conn.POSIXExtensions, test.want)
+		}
+		if ack != nil && (ack.Type() != smbnego.POSIXExtensions || !bytes.Equal(ack.Data(), smbposix.ExtensionsID)) {
+			t.Fatalf("%s: the acknowledgement is %x", test.desc, []byte(ack))
+		}
+	}
+}
+
+// posixContext returns a POSIX create context that carries mode.
+func posixContext(mode smbposix.Mode) []byte {
+	data := smbposix.CreateRequest(make([]byte, smbposix.CreateRequestSize))
+	data.SetMode(mode)
+	return smbcreate.AppendContext(nil, smbcreate.POSIX, data)
+}
+
+// queryPOSIX queries the POSIX information of a file.
+func (tt *treeTest) queryPOSIX(desc string, id smbfile.ID, length uint32, status smbstatus.Code) smbposix.Info {
+	tt.t.Helper()
+	body := make([]byte, smbinfo.QueryRequestSize+1)
+	request := smbinfo.QueryRequest(body)
+	request.SetSize(41)
+	request.SetInfoType(smbinfo.File)
+	request.SetFileInfoClass(smbposix.InfoClass)
+	request.SetOutputBufferLength(length)
+	request.SetFileID(id)
+	packet := tt.send(smbcommand.QueryInfo, body)
+	tt.check(desc, packet, status)
+	if status != smbstatus.Success {
+		return nil
+	}
+	info := smbposix.Info(smbinfo.QueryResponse(packet.Data()).Output())
+	if !info.Valid() {
+		tt.t.Fatalf("%s: invalid POSIX information", desc)
+	}
+	return info
+}
+
+// queryDirectory enumerates a directory with POSIX information and returns
+// the names of the entries that were returned.
+func (tt *treeTest) queryDirectory(desc string, id smbfile.ID, flags smbdir.Flags, pattern string, length uint32, status smbstatus.Code) []string {
+	tt.t.Helper()
+	body := make([]byte, smbdir.RequestBufferSize(pattern))
+	request := smbdir.Request(body)
+	request.SetSize(33)
+	request.SetFileInfoClass(smbposix.InfoClass)
+	request.SetFlags(flags)
+	request.SetFileID(id)
+	request.SetFileName(pattern)
+	request.SetOutputBufferLength(length)
+	packet := tt.send(smbcommand.QueryDirectory, body)
+	tt.check(desc, packet, status)
+	if status != smbstatus.Success {
+		return nil
+	}
+	response := smbdir.Response(packet.Data())
+	if !response.Valid() {
+		tt.t.Fatalf("%s: invalid response", desc)
+	}
+	list := smbposix.DirectoryInfoList(response.Output())
+	if !list.Valid() {
+		tt.t.Fatalf("%s: invalid directory information", desc)
+	}
+	var names []string
+	for offset := uint32(0); ; {
+		names = append(names, list.Member(offset).FileName())
+		if offset = list.Next(offset); offset == 0 {
+			return names
+		}
+	}
+}
+
+func TestPOSIXCreateAndQueryInfo(t *testing.T) {
+	tt := newTreeTest(t)
+	dir := t.TempDir()
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Data", Type: smbtree.Disk, FS: smbosfs.New(dir)})
+	tt.connect(`\\server\Data`, smbstatus.Success)
+
+	// The context is ignored until the extensions have been negotiated
+	spec := createSpec{Name: "a.txt", Access: readWrite | smbaccess.ReadAttributes, Share: shareAll, Disposition: smbcreate.Create, Contexts: posixContext(0600)}
+	packet := tt.send(smbcommand.Create, createBody(spec))
+	tt.check("create without the extensions", packet, smbstatus.Success)
+	response := smbcreate.Response(packet.Data())
+	if _, ok := smbcreate.ContextList(response.CreateContexts()).Find(smbcreate.POSIX); ok {
+		t.Fatal("create without the extensions: a POSIX context was returned")
+	}
+	tt.queryPOSIX("query without the extensions", response.FileID(), 4096, smbstatus.NotSupported)
+
+	tt.conn.POSIXExtensions = true
+	spec = createSpec{Name: "b.txt", Access: readWrite | smbaccess.ReadAttributes, Share: shareAll, Disposition: smbcreate.Create, Contexts: posixContext(0640)}
+	packet = tt.send(smbcommand.Create, createBody(spec))
+	tt.check("create with a mode", packet, smbstatus.Success)
+	response = smbcreate.Response(packet.Data())
+	fi, err := os.Stat(filepath.Join(dir, "b.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0640 {
+		t.Fatalf("create with a mode: the file has permissions %v", perm)
+	}
+
+	owner := smbsecurity.UnixUser(uint32(os.Getuid()))
+	context, ok := smbcreate.ContextList(response.CreateContexts()).Find(smbcreate.POSIX)
+	if !ok {
+		t.Fatal("create with a mode: no POSIX context was returned")
+	}
+	created := smbposix.CreateResponse(context.Data())
+	if !created.Valid() {
+		t.Fatal("create with a mode: invalid POSIX context")
+	}
+	if mode, links := created.Mode(), created.Links(); mode != 0640 || links != 1 {
+		t.Fatalf("create with a mode: the context has mode %v and %d links", mode, links)
+	}
+	if !created.Owner().Equal(owner) {
+		t.Fatalf("create with a mode: the owner is %s (want %s)", created.Owner(), owner)
+	}
+
+	id := response.FileID()
+	tt.writeFile("write", id, smbstatus.Success)
+	info := tt.queryPOSIX("query", id, 4096, smbstatus.Success)
+	if size, mode := info.EndOfFile(), info.Mode(); size != 4 || mode != 0640 {
+		t.Fatalf("query: the file has size %d and mode %v", size, mode)
+	}
+	if !info.Owner().Equal(owner) || !info.Group().Equal(smbsecurity.UnixGroup(uint32(os.Getgid()))) {
+		t.Fatalf("query: the file is owned by %s and %s", info.Owner(), info.Group())
+	}
+	if info.Inode() == 0 || info.Attributes().Match(smbfile.Directory) {
+		t.Fatalf("query: the file has inode %d and attributes %v", info.Inode(), info.Attributes())
+	}
+	tt.queryPOSIX("query with a short buffer", id, 8, smbstatus.InfoLengthMismatch)
+
+	// The information can only be queried with FILE_READ_ATTRIBUTES
+	spec = createSpec{Name: "b.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open}
+	packet = tt.send(smbcommand.Create, createBody(spec))
+	tt.check("open without read attributes", packet, smbstatus.Success)
+	tt.queryPOSIX("query without read attributes", smbcreate.Response(packet.Data()).FileID(), 4096, smbstatus.AccessDenied)
+}
+
+func TestPOSIXQueryDirectory(t *testing.T) {
+	tt := newTreeTest(t)
+	dir := t.TempDir()
+	for _, name := range []string{"b.txt", "a.txt", "C.TXT"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Data", Type: smbtree.Disk, FS: smbosfs.New(dir)})
+	tt.connect(`\\server\Data`, smbstatus.Success)
+
+	spec := createSpec{Name: "", Access: smbaccess.ListDirectory, Share: shareAll, Disposition: smbcreate.Open, Options: smbcreate.DirectoryFile}
+	packet := tt.send(smbcommand.Create, createBody(spec))
+	tt.check("open of the root", packet, smbstatus.Success)
+	id := smbcreate.Response(packet.Data()).FileID()
+
+	tt.queryDirectory("query without the extensions", id, 0, "*", 4096, smbstatus.NotSupported)
+	tt.conn.POSIXExtensions = true
+
+	check := func(desc string, got []string, want ...string) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: returned %q (want %q)", desc, got, want)
+		}
+	}
+	check("query", tt.queryDirectory("query", id, 0, "*", 4096, smbstatus.Success), ".", "..", "C.TXT", "a.txt", "b.txt", "sub")
+	tt.queryDirectory("query after the last entry", id, 0, "*", 4096, smbstatus.NoMoreFiles)
+
+	// Restarted scans keep their pattern, and reopened scans replace it.
+	// Patterns are case-sensitive.
+	check("single entry", tt.queryDirectory("single entry", id, smbdir.RestartScans|smbdir.ReturnSingleEntry, "", 4096, smbstatus.Success), ".")
+	check("reopen", tt.queryDirectory("reopen", id, smbdir.Reopen, "?.txt", 4096, smbstatus.Success), "a.txt", "b.txt")
+	check("restart", tt.queryDirectory("restart", id, smbdir.RestartScans, "", 4096, smbstatus.Success), "a.txt", "b.txt")
+	tt.queryDirectory("reopen without a match", id, smbdir.Reopen, "*.md", 4096, smbstatus.NoSuchFile)
+
+	// Entries that don't fit are returned by the next query
+	tt.queryDirectory("query with a short buffer", id, smbdir.Reopen, "*", 8, smbstatus.InfoLengthMismatch)
+	tt.queryDirectory("query with a small buffer", id, smbdir.Reopen, "*", 100, smbstatus.BufferOverflow)
+	check("query of two entries", tt.queryDirectory("query of two entries", id, smbdir.Reopen, "*", 300, smbstatus.Success), ".", "..")
+	check("query of the rest", tt.queryDirectory("query of the rest", id, 0, "*", 4096, smbstatus.Success), "C.TXT", "a.txt", "b.txt", "sub")
+
+	// Directories must be opened with FILE_LIST_DIRECTORY to be enumerated
+	spec.Access = smbaccess.ReadAttributes
+	packet = tt.send(smbcommand.Create, createBody(spec))
+	tt.check("open without list directory", packet, smbstatus.Success)
+	tt.queryDirectory("query without list directory", smbcreate.Response(packet.Data()).FileID(), 0, "*", 4096, smbstatus.AccessDenied)
+}
@@ -0,0 +1,263 @@
+package smbserver
+
+import (
+	"errors"
+
+	"github.com/gentlemanautomaton/smb"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbsign"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+var (
+	// ErrBadRequest is returned by Process when a message contains a
+	// request with an invalid header. The connection should be closed.
+	ErrBadRequest = errors.New("smbserver: request has an invalid header")
+
+	// ErrBadSequence is returned by Process when a message contains a
+	// request with a message ID that has already been used or has not been
+	// granted. The connection should be closed.
+	ErrBadSequence = errors.New("smbserver: request has an invalid message sequence number")
+)
+
+// Process processes a message containing one or more SMB requests. The
+// requests may be compounded, in which case each request in the chain is
+// passed to h in order and the responses are assembled into a single
+// compounded response message.
+//
+// Related operations within a chain inherit the session ID, tree ID and
+// file ID of the previous operation. If the previous operation failed,
+// a related operation fails with the same status without being passed to h.
+//
+// A handler can process a request asynchronously by calling c.GoAsync and
+// returning the resulting command, in which case an interim response is
+// sent. SMB2 CANCEL requests are handled by Process itself.
+//
+// If Process returns an error the connection should be closed.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/9a639360-87be-4d49-a1dd-4c6be0c020bd
+func (c *Conn) Process(msg smb.Message, h CommandHandler) error {
+	chain := smbpacket.Compound(msg.Bytes())
+
+	var (
+		responses []chainResponse
+		previous  Request
+		status    smbstatus.Code
+		offset    uint32
+	)
+	for i := 0; ; i++ {
+		packet := chain.Member(offset)
+		if packet == nil || !packet.Header().Valid() {
+			return ErrBadRequest
+		}
+		hdr := packet.Header()
+		if hdr.Command() == smbcommand.Cancel {
+			// Cancel requests don't consume sequence numbers and don't
+			// receive responses
+			c.cancel(hdr)
+		} else if !c.charge(hdr) {
+			return ErrBadSequence
+		}
+
+		next, err := chain.Next(offset)
+
+		r := Request{
+			Packet:    packet,
+			SessionID: hdr.SessionID(),
+			TreeID:    hdr.TreeID(),
+		}
+
+		var response Response
+		switch {
+		case hdr.Command() == smbcommand.Cancel:
+		case err != nil:
+			// The rest of the chain can't be trusted
+			response = smbproto.ErrorResponse{Cmd: hdr.Command(), Code: smbstatus.InvalidParameter}
+		case r.Related() && i == 0:
+			// The first operation in a chain can't be related
+			response = smbproto.ErrorResponse{Cmd: hdr.Command(), Code: smbstatus.InvalidParameter}
+		case r.Related():
+			r.SessionID = previous.SessionID
+			r.TreeID = previous.TreeID
+			r.fileID = previous.fileID
+			r.hasFileID = previous.hasFileID
+			if status.Failure() {
+				// Cascade the failure of the previous operation
+				response = smbproto.ErrorResponse{Cmd: hdr.Command(), Code: status}
+				break
+			}
+			fallthrough
+		default:
+			key, code := c.checkSession(&r)
+			if code != smbstatus.Success {
+				response = smbproto.ErrorResponse{Cmd: hdr.Command(), Code: code}
+				break
+			}
+			r.signingKey = key
+			response = h.ServeCommand(c, &r)
+		}
+
+		if response != nil {
+			status = response.Status()
+			entry := chainResponse{
+				Response:  response,
+				MessageID: hdr.MessageID(),
+				SessionID: r.SessionID,
+				TreeID:    r.TreeID,
+				Flags:     hdr.Flags() & smbpacket.Related,
+				Credits:   c.grant(hdr.CreditRequest()),
+			}
+			if a, ok := response.(*AsyncCommand); ok {
+				// Interim responses aren't signed
+				entry.Flags |= smbpacket.Async
+				entry.AsyncID = a.ID()
+			} else {
+				entry.SigningKey = r.signingKey
+			}
+			responses = append(responses, entry)
+		} else {
+			status = smbstatus.Success
+		}
+		previous = r
+
+		if err != nil || next == 0 {
+			break
+		}
+		offset = next
+	}
+
+	err := c.send(responses)
+
+	// Release final responses for commands that completed before their
+	// interim responses were sent
+	for i := range responses {
+		if a, ok := responses[i].Response.(*AsyncCommand); ok {
+			a.sentInterim()
+		}
+	}
+
+	return err
+}
+
+// chainResponse is a response within a compounded response chain.
+type chainResponse struct {
+	Response
+	MessageID uint64
+	SessionID uint64
+	TreeID    uint32
+	AsyncID   uint64
+	Flags     smbpacket.Flags
+	Credits   uint16
+
+	// SigningKey is the key used to sign the response, if it is signed
+	SigningKey []byte
+}
+
+// sentHook is implemented by responses that need to observe the bytes of
+// the packet that carried them once it has been assembled.
+type sentHook interface {
+	sent(packet []byte)
+}
+
+// send assembles a chain of responses into a single message and sends it to
+// the client. Each response except the last is padded to an 8-byte
+// boundary.
+func (c *Conn) send(responses []chainResponse) error {
+	if len(responses) == 0 {
+		return nil
+	}
+
+	last := len(responses) - 1
+	total := 0
+	for i := range responses {
+		length := smbpacket.HeaderSize + responses[i].Size()
+		if i < last {
+			length = smbpacket.Align(length)
+		}
+		total += length
+	}
+
+	msg := c.Create(total)
+	defer msg.Close()
+
+	b := msg.Bytes()
+	offset := 0
+	for i := range responses {
+		r := &responses[i]
+		length := smbpacket.HeaderSize + r.Size()
+		end := offset + length
+		if i < last {
+			end = offset + smbpacket.Align(length)
+		}
+
+		packet := smbpacket.Response(b[offset:end:end])
+		hdr := packet.Header()
+		writeHeader(hdr, r.Response)
+		hdr.SetCreditResponse(r.Credits)
+		flags := smbpacket.ServerToClient | r.Flags
+		if r.SigningKey != nil {
+			flags |= smbpacket.Signed
+		}
+		hdr.SetFlags(flags)
+		hdr.SetMessageID(r.MessageID)
+		hdr.SetSessionID(r.SessionID)
+		if r.Flags.Match(smbpacket.Async) {
+			hdr.SetAsyncID(r.AsyncID)
+		} else {
+			hdr.SetTreeID(r.TreeID)
+		}
+		if i < last {
+			hdr.SetNextCommand(uint32(end - offset))
+		}
+
+		r.Marshal(packet[smbpacket.HeaderSize:length])
+		if r.SigningKey != nil {
+			smbsign.Sign(r.SigningKey, c.Dialect.Revision(), packet)
+		}
+		if hook, ok := r.Response.(sentHook); ok {
+			hook.sent(packet)
+		}
+
+		offset = end
+	}
+
+	return c.Send(msg)
+}
+
+// charge consumes the sequence numbers charged by the request header.
+// It returns false if any of them are not outstanding.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/0326f784-0baf-45fd-9687-626859ef5a9b
+func (c *Conn) charge(hdr smbpacket.RequestHeader) bool {
+	charge := uint64(1)
+	if c.SupportMultiCredit {
+		if n := hdr.CreditCharge(); n > 1 {
+			charge = uint64(n)
+		}
+	}
+	first := hdr.MessageID()
+	for i := uint64(0); i < charge; i++ {
+		if !c.Consume(smb.SeqNum(first + i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// grant attempts to grant the number of credits requested by the client.
+// If the request can't be satisfied it attempts to grant a single credit.
+// It returns the number of credits granted.
+func (c *Conn) grant(requested uint16) uint16 {
+	if requested == 0 {
+		requested = 1
+	}
+	if c.Expand(int(requested)) {
+		return requested
+	}
+	if requested > 1 && c.Expand(1) {
+		return 1
+	}
+	return 0
+}
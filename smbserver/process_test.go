@@ -0,0 +1,213 @@
+package smbserver_test
+
+import (
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+var testFileID = smbfile.ID{Persistent: 1, Volatile: 2}
+
+// fileHandler simulates commands that generate and consume file IDs. The
+// create command generates testFileID unless its body starts with 1. The
+// echo command ignores file IDs. Other commands read a file ID from the first
+// 16 bytes of the body.
+func fileHandler(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+	switch cmd := r.Header().Command(); cmd {
+	case smbcommand.Create:
+		if data := r.Data(); len(data) > 0 && data[0] == 1 {
+			return testResponse{cmd: cmd, status: smbstatus.AccessDenied, size: 9}
+		}
+		r.SetFileID(testFileID)
+		return testResponse{cmd: cmd, size: 88}
+	case smbcommand.Echo:
+		return testResponse{cmd: cmd, size: 4}
+	default:
+		var id smbfile.ID
+		id.Read(r.Data())
+		id, ok := r.ResolveFileID(id)
+		if !ok {
+			return testResponse{cmd: cmd, status: smbstatus.InvalidParameter, size: 9}
+		}
+		if id != testFileID {
+			return testResponse{cmd: cmd, status: smbstatus.FileClosed, size: 9}
+		}
+		return testResponse{cmd: cmd, size: 60}
+	}
+}
+
+func fileIDBody(id smbfile.ID) []byte {
+	b := make([]byte, 24)
+	id.Write(b)
+	return b
+}
+
+type responseWant struct {
+	Command   smbcommand.Code
+	MessageID uint64
+	Status    smbstatus.Code
+	Related   bool
+	SessionID uint64
+	TreeID    uint32
+}
+
+type processTest struct {
+	Name     string
+	Requests []testRequest
+	Want     []responseWant
+}
+
+var processTests = []processTest{
+	{
+		Name: "single",
+		Requests: []testRequest{
+			{Command: smbcommand.Create, MessageID: 0, SessionID: 7, TreeID: 3},
+		},
+		Want: []responseWant{
+			{Command: smbcommand.Create, MessageID: 0, SessionID: 7, TreeID: 3},
+		},
+	},
+	{
+		Name: "related",
+		Requests: []testRequest{
+			{Command: smbcommand.Create, MessageID: 0, SessionID: 7, TreeID: 3},
+			{Command: smbcommand.QueryInfo, MessageID: 1, Flags: smbpacket.Related, SessionID: ^uint64(0), TreeID: ^uint32(0), Body: fileIDBody(smbfile.RelatedID)},
+			{Command: smbcommand.Close, MessageID: 2, Flags: smbpacket.Related, SessionID: ^uint64(0), TreeID: ^uint32(0), Body: fileIDBody(smbfile.RelatedID)},
+		},
+		Want: []responseWant{
+			{Command: smbcommand.Create, MessageID: 0, SessionID: 7, TreeID: 3},
+			{Command: smbcommand.QueryInfo, MessageID: 1, Related: true, SessionID: 7, TreeID: 3},
+			{Command: smbcommand.Close, MessageID: 2, Related: true, SessionID: 7, TreeID: 3},
+		},
+	},
+	{
+		Name: "cascade",
+		Requests: []testRequest{
+			{Command: smbcommand.Create, MessageID: 0, SessionID: 7, TreeID: 3, Body: []byte{1}},
+			{Command: smbcommand.QueryInfo, MessageID: 1, Flags: smbpacket.Related, Body: fileIDBody(smbfile.RelatedID)},
+			{Command: smbcommand.Close, MessageID: 2, Flags: smbpacket.Related, Body: fileIDBody(smbfile.RelatedID)},
+		},
+		Want: []responseWant{
+			{Command: smbcommand.Create, MessageID: 0, Status: smbstatus.AccessDenied, SessionID: 7, TreeID: 3},
+			{Command: smbcommand.QueryInfo, MessageID: 1, Status: smbstatus.AccessDenied, Related: true, SessionID: 7, TreeID: 3},
+			{Command: smbcommand.Close, MessageID: 2, Status: smbstatus.AccessDenied, Related: true, SessionID: 7, TreeID: 3},
+		},
+	},
+	{
+		Name: "unrelated",
+		Requests: []testRequest{
+			{Command: smbcommand.Create, MessageID: 0, SessionID: 7, TreeID: 3, Body: []byte{1}},
+			{Command: smbcommand.Read, MessageID: 1, SessionID: 7, TreeID: 4, Body: fileIDBody(testFileID)},
+			{Command: smbcommand.Close, MessageID: 2, SessionID: 7, TreeID: 4, Body: fileIDBody(smbfile.RelatedID)},
+		},
+		Want: []responseWant{
+			{Command: smbcommand.Create, MessageID: 0, Status: smbstatus.AccessDenied, SessionID: 7, TreeID: 3},
+			{Command: smbcommand.Read, MessageID: 1, SessionID: 7, TreeID: 4},
+			{Command: smbcommand.Close, MessageID: 2, Status: smbstatus.FileClosed, SessionID: 7, TreeID: 4},
+		},
+	},
+	{
+		Name: "related-first",
+		Requests: []testRequest{
+			{Command: smbcommand.Close, MessageID: 0, Flags: smbpacket.Related, Body: fileIDBody(smbfile.RelatedID)},
+		},
+		Want: []responseWant{
+			{Command: smbcommand.Close, MessageID: 0, Status: smbstatus.InvalidParameter, Related: true},
+		},
+	},
+	{
+		Name: "related-without-file",
+		Requests: []testRequest{
+			{Command: smbcommand.Echo, MessageID: 0, SessionID: 7},
+			{Command: smbcommand.Close, MessageID: 1, Flags: smbpacket.Related, Body: fileIDBody(smbfile.RelatedID)},
+		},
+		Want: []responseWant{
+			{Command: smbcommand.Echo, MessageID: 0, SessionID: 7},
+			{Command: smbcommand.Close, MessageID: 1, Status: smbstatus.InvalidParameter, Related: true, SessionID: 7},
+		},
+	},
+}
+
+func TestProcess(t *testing.T) {
+	for _, tt := range processTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			conn, transport := newTestConn(len(tt.Requests))
+			msg := makeMessage(tt.Requests...)
+			if err := conn.Process(msg, smbserver.CommandHandlerFunc(fileHandler)); err != nil {
+				t.Fatalf("Process returned %v", err)
+			}
+			b := transport.Next()
+			if b == nil {
+				t.Fatal("no response was sent")
+			}
+			responses := splitResponses(b)
+			if len(responses) != len(tt.Want) {
+				t.Fatalf("received %d responses (want %d)", len(responses), len(tt.Want))
+			}
+			for i, want := range tt.Want {
+				r := responses[i]
+				hdr := r.Header()
+				if i < len(responses)-1 && len(r)%smbpacket.Alignment != 0 {
+					t.Errorf("response %d: length %d is not 8-byte aligned", i, len(r))
+				}
+				if cmd := hdr.Command(); cmd != want.Command {
+					t.Errorf("response %d: command %s (want %s)", i, cmd, want.Command)
+				}
+				if id := hdr.MessageID(); id != want.MessageID {
+					t.Errorf("response %d: message ID %d (want %d)", i, id, want.MessageID)
+				}
+				if status := hdr.Status(); status != want.Status {
+					t.Errorf("response %d: status %s (want %s)", i, status, want.Status)
+				}
+				if related := hdr.Flags().Match(smbpacket.Related); related != want.Related {
+					t.Errorf("response %d: related %t (want %t)", i, related, want.Related)
+				}
+				if !hdr.Flags().Match(smbpacket.ServerToClient) {
+					t.Errorf("response %d: missing server to client flag", i)
+				}
+				if id := hdr.SessionID(); id != want.SessionID {
+					t.Errorf("response %d: session ID %d (want %d)", i, id, want.SessionID)
+				}
+				if id := hdr.TreeID(); id != want.TreeID {
+					t.Errorf("response %d: tree ID %d (want %d)", i, id, want.TreeID)
+				}
+				if credits := hdr.CreditResponse(); credits != 1 {
+					t.Errorf("response %d: granted %d credits (want 1)", i, credits)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessMisaligned(t *testing.T) {
+	conn, transport := newTestConn(2)
+	msg := makeMessage(
+		testRequest{Command: smbcommand.Create, MessageID: 0},
+		testRequest{Command: smbcommand.Close, MessageID: 1, Body: fileIDBody(testFileID)},
+	)
+	b := msg.Bytes()
+	smbtype.PutUint32(b[20:24], smbpacket.HeaderSize+4)
+	if err := conn.Process(msg, smbserver.CommandHandlerFunc(fileHandler)); err != nil {
+		t.Fatalf("Process returned %v", err)
+	}
+	responses := splitResponses(transport.Next())
+	if len(responses) != 1 {
+		t.Fatalf("received %d responses (want 1)", len(responses))
+	}
+	if status := responses[0].Header().Status(); status != smbstatus.InvalidParameter {
+		t.Fatalf("misaligned request returned %s (want %s)", status, smbstatus.Code(smbstatus.InvalidParameter))
+	}
+}
+
+func TestProcessBadSequence(t *testing.T) {
+	conn, _ := newTestConn(1)
+	msg := makeMessage(testRequest{Command: smbcommand.Echo, MessageID: 5})
+	if err := conn.Process(msg, smbserver.CommandHandlerFunc(fileHandler)); err != smbserver.ErrBadSequence {
+		t.Fatalf("Process returned %v (want %v)", err, smbserver.ErrBadSequence)
+	}
+}
@@ -0,0 +1,73 @@
+package smbserver
+
+import (
+	"io"
+	"math"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbread"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// Read processes an SMB2 READ request. It reads data from the file referred
+// to by the request. Reads from ranges that are locked exclusively by other
+// opens fail with STATUS_FILE_LOCK_CONFLICT. Reads of named pipes return
+// the next reply waiting to be read from the pipe. Opens that weren't
+// granted FILE_READ_DATA or FILE_EXECUTE fail with STATUS_ACCESS_DENIED.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.12
+func (c *Conn) Read(r *Request) Response {
+	request := smbread.Request(r.Data())
+	if !request.Valid() {
+		return readError(smbstatus.InvalidParameter)
+	}
+
+	id, ok := r.ResolveFileID(request.FileID())
+	if !ok {
+		return readError(smbstatus.InvalidParameter)
+	}
+	open := c.lookupOpen(r, id)
+	if open == nil {
+		return readError(smbstatus.FileClosed)
+	}
+	r.SetFileID(id)
+
+	if !open.GrantedAccess.Any(smbaccess.ReadData | smbaccess.Execute) {
+		return readError(smbstatus.AccessDenied)
+	}
+	if open.pipe != nil {
+		return c.readPipe(open, request.Length())
+	}
+	if open.Directory || open.File == nil {
+		return readError(smbstatus.InvalidDeviceRequest)
+	}
+
+	length, offset := request.Length(), request.Offset()
+	if c.MaxReadSize > 0 && length > c.MaxReadSize {
+		return readError(smbstatus.InvalidParameter)
+	}
+	if offset > math.MaxInt64 {
+		return readError(smbstatus.InvalidParameter)
+	}
+
+	if !open.checkLock(offset, uint64(length), false) {
+		return readError(smbstatus.FileLockConflict)
+	}
+
+	data := make([]byte, length)
+	n, err := open.File.ReadAt(data, int64(offset))
+	if err != nil && err != io.EOF {
+		return readError(fileStatus(err))
+	}
+	if (n == 0 && length > 0) || uint32(n) < request.MinimumCount() {
+		return readError(smbstatus.EndOfFile)
+	}
+
+	return smbproto.ReadResponse{Data: data[:n]}
+}
+
+func readError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.Read, Code: code}
+}
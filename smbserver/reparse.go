@@ -0,0 +1,191 @@
+package smbserver
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smberror"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbreparse"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// isSymlink returns true if info describes a symbolic link.
+func isSymlink(info os.FileInfo) bool {
+	return info.Mode()&os.ModeSymlink != 0
+}
+
+// findSymlink returns the first element of name that is a symbolic link,
+// and the rest of name that follows it. It returns an empty link if none
+// of the elements of name that exist are symbolic links.
+func findSymlink(fsys smbfs.Linker, name string) (link, rest string, err error) {
+	if name == "." {
+		return "", "", nil
+	}
+	elements := strings.Split(name, "/")
+	for i := range elements {
+		link = strings.Join(elements[:i+1], "/")
+		info, err := fsys.Lstat(link)
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", "", nil
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if isSymlink(info) {
+			return link, strings.Join(elements[i+1:], "/"), nil
+		}
+	}
+	return "", "", nil
+}
+
+// symlinkTarget converts the target of a symbolic link to the form used
+// by SMB, and returns the flags that describe it.
+func symlinkTarget(target string) (string, smbreparse.Flags) {
+	var flags smbreparse.Flags
+	if !strings.HasPrefix(target, "/") {
+		flags |= smbreparse.Relative
+	}
+	return strings.ReplaceAll(target, "/", `\`), flags
+}
+
+// stoppedOnSymlink returns the response to a create that encountered a
+// symbolic link. It tells the client where the link points and how much
+// of the name it opened follows the link, so that the client can follow
+// the link itself. In the SMB 3.1.1 dialect the symbolic link error
+// response is carried by an error context.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.9
+func (c *Conn) stoppedOnSymlink(fsys smbfs.Linker, link, rest string) Response {
+	target, err := fsys.Readlink(link)
+	if err != nil {
+		return createError(fileStatus(err))
+	}
+	substitute, flags := symlinkTarget(target)
+
+	data := make([]byte, smberror.SymlinkBufferSize(substitute, substitute))
+	response := smberror.Symlink(data)
+	response.SetNames(substitute, substitute)
+	response.SetFlags(flags)
+	if rest != "" {
+		response.SetUnparsedPathLength(uint16(smbtype.StringLen(`\` + rest)))
+	}
+
+	if c.Dialect.Revision() != smbdialect.SMB311 {
+		return smbproto.ErrorResponse{Cmd: smbcommand.Create, Code: smbstatus.StoppedOnSymlink, Data: data}
+	}
+	buf := make([]byte, smberror.ContextBufferSize(len(data)))
+	context := smberror.Context(buf)
+	context.SetID(smberror.DefaultContext)
+	context.SetData(data)
+	return smbproto.ErrorResponse{Cmd: smbcommand.Create, Code: smbstatus.StoppedOnSymlink, ContextCount: 1, Data: buf}
+}
+
+// stat returns information about the file of o. Opens of symbolic links
+// don't have a file, in which case the link is described.
+func (o *Open) stat() (os.FileInfo, error) {
+	if o.File != nil {
+		return o.File.Stat()
+	}
+	if linker, ok := o.FS.(smbfs.Linker); ok {
+		return linker.Lstat(o.Name)
+	}
+	return nil, fs.ErrInvalid
+}
+
+// getReparsePoint processes an FSCTL_GET_REPARSE_POINT request. Symbolic
+// links are returned as IO_REPARSE_TAG_SYMLINK reparse points. Links can
+// only be queried by opens made with FILE_OPEN_REPARSE_POINT, since
+// other opens of links are stopped by the link.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.3.31
+func (c *Conn) getReparsePoint(r *Request, ctl FSCTL) Response {
+	open := c.lookupOpen(r, ctl.FileID)
+	if open == nil {
+		return ioctlError(smbstatus.FileClosed)
+	}
+	linker, ok := open.FS.(smbfs.Linker)
+	if !ok {
+		return ioctlError(smbstatus.NotAReparsePoint)
+	}
+	info, err := linker.Lstat(open.Name)
+	if err != nil {
+		return ioctlError(fileStatus(err))
+	}
+	if !isSymlink(info) {
+		return ioctlError(smbstatus.NotAReparsePoint)
+	}
+	target, err := linker.Readlink(open.Name)
+	if err != nil {
+		return ioctlError(fileStatus(err))
+	}
+	substitute, flags := symlinkTarget(target)
+
+	size := smbreparse.SymlinkBufferSize(substitute, substitute)
+	if int64(ctl.MaxOutput) < int64(size) {
+		return ioctlError(smbstatus.BufferTooSmall)
+	}
+	output := make([]byte, size)
+	buffer := smbreparse.SymlinkBuffer(output)
+	buffer.SetTag(smbreparse.Symlink)
+	buffer.SetNames(substitute, substitute)
+	buffer.SetFlags(flags)
+
+	return smbproto.IoctlResponse{CtlCode: ctl.Code, FileID: ctl.FileID, Output: output}
+}
+
+// setReparsePoint processes an FSCTL_SET_REPARSE_POINT request. Only
+// relative IO_REPARSE_TAG_SYMLINK reparse points are supported. The empty
+// file or directory of the open is replaced by a symbolic link.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.3.65
+func (c *Conn) setReparsePoint(r *Request, ctl FSCTL) Response {
+	open := c.lookupOpen(r, ctl.FileID)
+	if open == nil {
+		return ioctlError(smbstatus.FileClosed)
+	}
+	linker, ok := open.FS.(smbfs.Linker)
+	if !ok {
+		return ioctlError(smbstatus.InvalidDeviceRequest)
+	}
+	if !open.GrantedAccess.Any(smbaccess.WriteData | smbaccess.WriteAttributes) {
+		return ioctlError(smbstatus.AccessDenied)
+	}
+
+	buffer := smbreparse.SymlinkBuffer(ctl.Input)
+	switch {
+	case len(buffer) < 4:
+		return ioctlError(smbstatus.ReparseDataInvalid)
+	case buffer.Tag() != smbreparse.Symlink:
+		return ioctlError(smbstatus.ReparseTagNotHandled)
+	case !buffer.Valid() || buffer.SubstituteName() == "":
+		return ioctlError(smbstatus.ReparseDataInvalid)
+	case !buffer.Flags().Match(smbreparse.Relative):
+		// Absolute targets can't be expressed within the file system
+		return ioctlError(smbstatus.NotSupported)
+	}
+
+	info, err := open.stat()
+	if err != nil {
+		return ioctlError(fileStatus(err))
+	}
+	if !info.IsDir() && !isSymlink(info) && info.Size() > 0 {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	if err := open.FS.Remove(open.Name); err != nil {
+		return ioctlError(fileStatus(err))
+	}
+	target := strings.ReplaceAll(buffer.SubstituteName(), `\`, "/")
+	if err := linker.Symlink(target, open.Name); err != nil {
+		return ioctlError(fileStatus(err))
+	}
+
+	return smbproto.IoctlResponse{CtlCode: ctl.Code, FileID: ctl.FileID}
+}
@@ -0,0 +1,135 @@
+package smbserver_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smberror"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbreparse"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// newReparseTest returns a create test whose directory holds a directory
+// with a file in it, and a relative symbolic link to the directory.
+func newReparseTest(t *testing.T) *createTest {
+	ct := newCreateTest(t)
+	if err := os.Mkdir(filepath.Join(ct.root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ct.root, "dir", "file.txt"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir", filepath.Join(ct.root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	return ct
+}
+
+// stoppedOnSymlink opens the named file and returns the symbolic link
+// error response that the create is expected to fail with.
+func (ct *createTest) stoppedOnSymlink(name string) smberror.Symlink {
+	ct.t.Helper()
+	packet := ct.send(1, smbcommand.Create, createBody(createSpec{Name: name, Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open}))
+	if s := packet.Header().Status(); s != smbstatus.StoppedOnSymlink {
+		ct.t.Fatalf("open of %s returned %s (want StoppedOnSymlink)", name, s)
+	}
+	response := smberror.Response(packet.Data())
+	if !response.Valid() {
+		ct.t.Fatalf("open of %s returned an invalid error response", name)
+	}
+	data := response.Data()
+	if ct.conn.Dialect.Revision() == smbdialect.SMB311 {
+		context := smberror.Context(data)
+		if response.ContextCount() != 1 || !context.Valid() || context.ID() != smberror.DefaultContext {
+			ct.t.Fatalf("open of %s returned an invalid error context", name)
+		}
+		data = context.Data()
+	}
+	symlink := smberror.Symlink(data)
+	if !symlink.Valid() || symlink.ReparseTag() != smbreparse.Symlink {
+		ct.t.Fatalf("open of %s returned an invalid symbolic link error response", name)
+	}
+	return symlink
+}
+
+func TestStoppedOnSymlink(t *testing.T) {
+	for _, dialect := range []smbdialect.State{smbdialect.SMB311, smbdialect.SMB302} {
+		t.Run(dialect.String(), func(t *testing.T) {
+			ct := newReparseTest(t)
+			ct.conn.Dialect = dialect
+
+			symlink := ct.stoppedOnSymlink(`link\file.txt`)
+			if name := symlink.SubstituteName(); name != "dir" {
+				t.Errorf("substitute name is %q (want %q)", name, "dir")
+			}
+			if !symlink.Flags().Match(smbreparse.Relative) {
+				t.Error("relative link is not flagged as relative")
+			}
+			if n := symlink.UnparsedPathLength(); n != uint16(len(`\file.txt`)*2) {
+				t.Errorf("unparsed path length is %d (want %d)", n, len(`\file.txt`)*2)
+			}
+
+			symlink = ct.stoppedOnSymlink("link")
+			if n := symlink.UnparsedPathLength(); n != 0 {
+				t.Errorf("unparsed path length of link is %d (want 0)", n)
+			}
+		})
+	}
+}
+
+func TestReparsePoint(t *testing.T) {
+	ct := newReparseTest(t)
+
+	packet := ct.send(1, smbcommand.Create, createBody(createSpec{Name: "link", Access: smbaccess.ReadAttributes | smbaccess.Delete, Share: shareAll, Disposition: smbcreate.Open, Options: smbcreate.OpenReparsePoint | smbcreate.DeleteOnClose}))
+	response := ct.checkCreate("open of link as reparse point", packet, smbstatus.Success)
+	if !response.FileAttributes().Match(smbfile.ReparsePoint) {
+		t.Errorf("link has attributes %s", response.FileAttributes())
+	}
+	id := response.FileID()
+
+	buffer := smbreparse.SymlinkBuffer(ct.fsctl("get reparse point", smbioctl.GetReparsePoint, id, nil, 1024, smbstatus.Success))
+	if !buffer.Valid() || buffer.Tag() != smbreparse.Symlink || buffer.SubstituteName() != "dir" || !buffer.Flags().Match(smbreparse.Relative) {
+		t.Errorf("get reparse point returned an unexpected buffer")
+	}
+	ct.fsctl("get reparse point with small buffer", smbioctl.GetReparsePoint, id, nil, smbreparse.SymlinkSize, smbstatus.BufferTooSmall)
+
+	// Deleting the link leaves its target in place
+	ct.close(1, id)
+	if _, err := os.Lstat(filepath.Join(ct.root, "link")); !os.IsNotExist(err) {
+		t.Errorf("link still exists after it was deleted (err %v)", err)
+	}
+	if _, err := os.Stat(filepath.Join(ct.root, "dir", "file.txt")); err != nil {
+		t.Errorf("target of link is missing after the link was deleted: %v", err)
+	}
+
+	file := ct.create("open of file", 1, createSpec{Name: `dir\file.txt`, Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+	ct.fsctl("get reparse point of file", smbioctl.GetReparsePoint, file, nil, 1024, smbstatus.NotAReparsePoint)
+}
+
+func TestSetReparsePoint(t *testing.T) {
+	ct := newReparseTest(t)
+	id := ct.create("create", 1, createSpec{Name: "new", Access: readWrite, Share: shareAll, Disposition: smbcreate.Create}, smbstatus.Success)
+
+	input := make([]byte, smbreparse.SymlinkBufferSize(`dir\file.txt`, `dir\file.txt`))
+	buffer := smbreparse.SymlinkBuffer(input)
+	buffer.SetTag(smbreparse.Symlink)
+	buffer.SetNames(`dir\file.txt`, `dir\file.txt`)
+	ct.fsctl("set absolute reparse point", smbioctl.SetReparsePoint, id, input, 0, smbstatus.NotSupported)
+	buffer.SetFlags(smbreparse.Relative)
+	ct.fsctl("set reparse point", smbioctl.SetReparsePoint, id, input, 0, smbstatus.Success)
+	ct.close(1, id)
+
+	if target, err := os.Readlink(filepath.Join(ct.root, "new")); err != nil || target != "dir/file.txt" {
+		t.Errorf("set reparse point created a link to %q (err %v)", target, err)
+	}
+	if name := ct.stoppedOnSymlink("new").SubstituteName(); name != `dir\file.txt` {
+		t.Errorf("open of new link reported target %q", name)
+	}
+}
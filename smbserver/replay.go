@@ -0,0 +1,96 @@
+package smbserver
+
+import (
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// channelState tracks the channel sequence number of an open and the
+// requests that are outstanding for it. It is protected by the open's
+// mutex.
+type channelState struct {
+	sequence    uint16
+	outstanding uint64 // Requests sent with the current sequence number
+	previous    uint64 // Requests sent with earlier sequence numbers
+}
+
+// verifyChannelSequence checks the channel sequence number of a WRITE,
+// SET_INFO, IOCTL or LOCK request that modifies o. Clients increment the
+// sequence number when they fail over to another channel, which makes
+// requests that are still in flight on the old channel stale.
+//
+// It returns false if the request must fail with STATUS_FILE_NOT_AVAILABLE,
+// either because its sequence number is stale or because it is a replay of
+// a request that may still be outstanding under an earlier sequence
+// number. Otherwise it returns a function that must be called when the
+// request completes.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.2.10
+func (c *Conn) verifyChannelSequence(r *Request, o *Open) (done func(), ok bool) {
+	if c.Dialect.Revision().Major() != 3 {
+		return func() {}, true
+	}
+
+	hdr := r.Header()
+	sequence := hdr.ChannelSequence()
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	state := &o.channel
+	switch diff := sequence - state.sequence; {
+	case diff == 0:
+		if hdr.Flags().Match(smbpacket.Replay) && state.previous > 0 {
+			return nil, false
+		}
+		state.outstanding++
+	case diff <= 0x7FFF:
+		state.previous += state.outstanding
+		state.outstanding = 1
+		state.sequence = sequence
+	default:
+		return nil, false
+	}
+
+	return func() {
+		o.mutex.Lock()
+		defer o.mutex.Unlock()
+		if sequence == state.sequence && state.outstanding > 0 {
+			state.outstanding--
+		} else if state.previous > 0 {
+			state.previous--
+		}
+	}, true
+}
+
+// cacheCreate records the response to the create request that made o, so
+// that it can be served to replays of the request.
+func (o *Open) cacheCreate(response smbproto.CreateResponse) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.replay = response
+}
+
+// replayCreate processes a replayed create request that carries the create
+// GUID of o, which is an existing durable open. Clients replay creates
+// after failing over to another channel when they don't know whether the
+// original request succeeded. The response to the original request is
+// served again instead of opening the file a second time.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.9.10
+func (c *Conn) replayCreate(r *Request, o *Open, p *createParams) Response {
+	if o.ClientGUID != c.ClientGUID || o.Name != p.name || !o.belongsTo(r.SessionID, r.TreeID) {
+		return createError(smbstatus.DuplicateObjectID)
+	}
+
+	o.mutex.Lock()
+	response := o.replay
+	o.mutex.Unlock()
+	if response.FileID != o.ID {
+		return createError(smbstatus.DuplicateObjectID)
+	}
+
+	r.SetFileID(response.FileID)
+	return response
+}
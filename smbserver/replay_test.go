@@ -0,0 +1,77 @@
+package smbserver_test
+
+import (
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smboplock"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+func TestChannelSequence(t *testing.T) {
+	ct := newOplockTest(t)
+	ct.channel = 5
+	id := ct.create("open", 1, createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+
+	for _, test := range []struct {
+		channel uint16
+		status  smbstatus.Code
+	}{
+		{5, smbstatus.Success},
+		{4, smbstatus.FileNotAvailable},
+		{6, smbstatus.Success},
+		{5, smbstatus.FileNotAvailable},
+		{6, smbstatus.Success},
+		{0x8005, smbstatus.Success},
+		{6, smbstatus.FileNotAvailable},
+		{0x0005, smbstatus.FileNotAvailable}, // 0x8000 ahead, which wraps to behind
+	} {
+		ct.channel = test.channel
+		if s := ct.send(1, smbcommand.Write, writeBody(id, "data")).Header().Status(); s != test.status {
+			t.Errorf("write with channel sequence %#x returned %s (want %s)", test.channel, s, test.status)
+		}
+	}
+	// Locks and control requests on the open are checked too
+	ct.channel = 6
+	if s := ct.send(1, smbcommand.Lock, lockBody(id, 0, 0, lockRange{Offset: 0, Length: 4, Flags: exclusiveImmediate})).Header().Status(); s != smbstatus.FileNotAvailable {
+		t.Errorf("lock with a stale channel sequence returned %s (want %s)", s, smbstatus.Code(smbstatus.FileNotAvailable))
+	}
+	if s := ct.send(1, smbcommand.IOCTL, ioctlBody(smbioctl.SrvRequestResumeKey, id, nil, 32)).Header().Status(); s != smbstatus.FileNotAvailable {
+		t.Errorf("control request with a stale channel sequence returned %s (want %s)", s, smbstatus.Code(smbstatus.FileNotAvailable))
+	}
+	ct.channel = 0x8005
+	if s := ct.send(1, smbcommand.Lock, lockBody(id, 0, 0, lockRange{Offset: 0, Length: 4, Flags: exclusiveImmediate})).Header().Status(); s != smbstatus.Success {
+		t.Errorf("lock with the current channel sequence returned %s", s)
+	}
+}
+
+func TestReplayCreate(t *testing.T) {
+	a, _ := newDurableTest(t)
+
+	spec := createSpec{Name: "doc.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Oplock: smboplock.Batch, Contexts: durableContextV2(nil, createGUID, 0)}
+	original := a.checkCreate("original create", a.send(1, smbcommand.Create, createBody(spec)), smbstatus.Success)
+
+	// Without the replay flag the create GUID is a duplicate
+	a.create("duplicate create", 1, spec, smbstatus.DuplicateObjectID)
+
+	a.flags = smbpacket.Replay
+	replayed := a.checkCreate("replayed create", a.send(1, smbcommand.Create, createBody(spec)), smbstatus.Success)
+	if got, want := replayed.FileID(), original.FileID(); got != want {
+		t.Errorf("replayed create returned file ID %v (want %v)", got, want)
+	}
+	if level := replayed.OplockLevel(); level != smboplock.Batch {
+		t.Errorf("replayed create returned oplock level %s (want Batch)", level)
+	}
+	if _, ok := a.durableResponse(replayed, smbcreate.DurableHandleRequestV2); !ok {
+		t.Error("replayed create did not return a durable handle context")
+	}
+	if n := a.conn.Opens.Len(); n != 1 {
+		t.Errorf("open table holds %d opens after the replay (want 1)", n)
+	}
+
+	// Replays from another session are rejected
+	a.create("replay from another session", 2, spec, smbstatus.DuplicateObjectID)
+}
@@ -0,0 +1,69 @@
+package smbserver
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+)
+
+// Request is an individual SMB request that is being processed by the
+// server. When the request is part of a compounded chain it carries the
+// state inherited from the previous operation in the chain.
+type Request struct {
+	// Packet holds the bytes of the request, including its header. When the
+	// request is part of a compounded chain the packet is truncated to the
+	// length indicated by its NextCommand field.
+	Packet smbpacket.Request
+
+	// SessionID is the session ID that applies to the request. For related
+	// operations it is inherited from the previous operation in the chain.
+	SessionID uint64
+
+	// TreeID is the tree ID that applies to the request. For related
+	// operations it is inherited from the previous operation in the chain.
+	TreeID uint32
+
+	fileID     smbfile.ID // File ID carried over from the previous operation
+	hasFileID  bool       // True if fileID has been set
+	signingKey []byte     // Key used to sign the response, if any
+}
+
+// Header returns the packet header of the request.
+func (r *Request) Header() smbpacket.RequestHeader {
+	return r.Packet.Header()
+}
+
+// Data returns the request data that follows the header.
+func (r *Request) Data() []byte {
+	return r.Packet.Data()
+}
+
+// Related returns true if the request is a related operation within a
+// compounded chain.
+func (r *Request) Related() bool {
+	return r.Header().Flags().Match(smbpacket.Related)
+}
+
+// ResolveFileID returns the file ID that the request should operate on.
+//
+// When id is smbfile.RelatedID and r is a related operation, it returns the
+// file ID that was used or generated by the previous operation in the chain.
+// If there is no such file ID it returns false, in which case the request
+// should fail with STATUS_INVALID_PARAMETER.
+//
+// Any other id is returned unchanged.
+func (r *Request) ResolveFileID(id smbfile.ID) (resolved smbfile.ID, ok bool) {
+	if !id.IsRelated() || !r.Related() {
+		return id, true
+	}
+	if !r.hasFileID {
+		return id, false
+	}
+	return r.fileID, true
+}
+
+// SetFileID records the file ID that the request used or generated, so that
+// subsequent related operations in the chain can refer to it.
+func (r *Request) SetFileID(id smbfile.ID) {
+	r.fileID = id
+	r.hasFileID = true
+}
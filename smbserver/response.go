@@ -1,11 +1,14 @@
 package smbserver
 
-import "github.com/gentlemanautomaton/smb/smbcommand"
+import (
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
 
 // Response can be marshaled into a message.
 type Response interface {
 	Command() smbcommand.Code
-	Status() uint32 // TODO: Make this strongly typed
+	Status() smbstatus.Code
 	Size() int
 	Marshal([]byte)
 }
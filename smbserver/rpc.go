@@ -0,0 +1,308 @@
+package smbserver
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+
+	"github.com/gentlemanautomaton/smb/smbrpc"
+)
+
+// errRPCProtocol is returned when a message written to an RPC pipe doesn't
+// hold well-formed PDUs.
+var errRPCProtocol = errors.New("smbserver: the message is not a valid DCE/RPC PDU")
+
+const (
+	// rpcMaxFrag is the largest fragment that RPC pipes send or receive.
+	rpcMaxFrag = 4280
+
+	// rpcMaxStub is the largest amount of stub data that RPC pipes accept
+	// for a single call.
+	rpcMaxStub = 4 << 20
+)
+
+// rpcFeatureNegotiation is the prefix of the abstract syntaxes that clients
+// use to negotiate bind time features.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-rpce/ section 3.3.1.5.3
+var rpcFeatureNegotiation = []byte{0x6c, 0xb7, 0x1c, 0x2c, 0x98, 0x12, 0x45, 0x40}
+
+// rpcAssocGroups is the last association group ID issued by RPC pipes.
+var rpcAssocGroups uint32
+
+// RPCInterface is a DCE/RPC interface that is served over a named pipe by
+// RPCPipe.
+type RPCInterface interface {
+	// Syntax returns the abstract syntax that identifies the interface.
+	// Clients can bind to the interface with the same major version and
+	// the same or an earlier minor version.
+	Syntax() smbrpc.SyntaxID
+
+	// Call invokes the operation with the given operation number. The
+	// stub holds the NDR-encoded input parameters of the call, and the
+	// returned stub holds its NDR-encoded output parameters. If the call
+	// fails it returns a nonzero fault status instead.
+	Call(c *Conn, opnum uint16, stub []byte) ([]byte, smbrpc.FaultStatus)
+}
+
+// RPCPipe returns a pipe handler that serves the given interfaces over
+// connection-oriented DCE/RPC with the NDR transfer syntax.
+//
+// Clients bind presentation contexts to the interfaces with bind or
+// alter_context PDUs, and then call them with request PDUs, which may be
+// fragmented. Each call is answered with response PDUs that are fragmented
+// to fit the client's max_recv_frag, or with a fault PDU. Authenticated
+// binds are not supported.
+func RPCPipe(ifaces ...RPCInterface) PipeHandler {
+	return PipeHandlerFunc(func(c *Conn, name string) (Pipe, error) {
+		return &rpcPipe{
+			conn:     c,
+			address:  `\PIPE\` + name,
+			ifaces:   ifaces,
+			contexts: make(map[uint16]RPCInterface),
+			maxXmit:  rpcMaxFrag,
+		}, nil
+	})
+}
+
+// rpcPipe is an instance of a named pipe that serves DCE/RPC interfaces.
+type rpcPipe struct {
+	conn     *Conn
+	address  string // The secondary address reported to clients
+	ifaces   []RPCInterface
+	contexts map[uint16]RPCInterface // Presentation contexts, by ID
+	maxXmit  int                     // The largest fragment the client can receive
+	assoc    uint32                  // The association group of the client
+	call     *rpcCall                // A fragmented call being received
+}
+
+// rpcCall is a call whose request fragments are being received.
+type rpcCall struct {
+	id      uint32
+	context uint16
+	opnum   uint16
+	stub    []byte
+}
+
+// Transact processes the PDUs written to the pipe by the client and returns
+// the PDUs sent in reply.
+func (p *rpcPipe) Transact(message []byte) (replies [][]byte, err error) {
+	for len(message) > 0 {
+		hdr := smbrpc.Header(message)
+		if !hdr.Valid() {
+			return replies, errRPCProtocol
+		}
+		pdu := message[:hdr.FragLength()]
+		message = message[len(pdu):]
+
+		switch hdr.Type() {
+		case smbrpc.TypeBind, smbrpc.TypeAlterContext:
+			replies = append(replies, p.bind(smbrpc.Bind(pdu)))
+		case smbrpc.TypeRequest:
+			replies = append(replies, p.request(smbrpc.Request(pdu))...)
+		case smbrpc.TypeAuth3, smbrpc.TypeCoCancel, smbrpc.TypeOrphaned:
+			// These PDUs don't receive replies
+		default:
+			replies = append(replies, rpcFault(hdr.CallID(), 0, smbrpc.ProtocolError, true))
+		}
+	}
+	return replies, nil
+}
+
+// Close closes the pipe.
+func (p *rpcPipe) Close() error {
+	return nil
+}
+
+// bind processes a bind or alter_context PDU and returns the bind_ack or
+// alter_context_resp PDU that reports the results of its presentation
+// contexts.
+//
+// See C706 section 12.6.4.3.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-rpce/ section 3.3.1.5.3
+func (p *rpcPipe) bind(b smbrpc.Bind) []byte {
+	hdr := b.Header()
+	if !b.Valid() {
+		return rpcFault(hdr.CallID(), 0, smbrpc.ProtocolError, true)
+	}
+
+	replyType, address := smbrpc.PacketType(smbrpc.TypeAlterContextResp), ""
+	if hdr.Type() == smbrpc.TypeBind {
+		replyType, address = smbrpc.TypeBindAck, p.address
+		p.maxXmit = rpcMaxFrag
+		if max := int(b.MaxRecvFrag()); max < p.maxXmit {
+			p.maxXmit = max
+		}
+		p.assoc = b.AssocGroupID()
+		if p.assoc == 0 {
+			p.assoc = atomic.AddUint32(&rpcAssocGroups, 1)
+		}
+	}
+
+	contexts := b.Contexts()
+	results := make([]smbrpc.Result, len(contexts))
+	for i, context := range contexts {
+		results[i] = p.bindContext(context)
+	}
+
+	ack := smbrpc.BindAck(make([]byte, smbrpc.BindAckBufferSize(address, len(results))))
+	ack.Header().Init(replyType, smbrpc.FirstFrag|smbrpc.LastFrag, uint16(len(ack)), hdr.CallID())
+	ack.SetMaxXmitFrag(uint16(p.maxXmit))
+	ack.SetMaxRecvFrag(rpcMaxFrag)
+	ack.SetAssocGroupID(p.assoc)
+	ack.SetAddress(address)
+	ack.SetResultCount(len(results))
+	for i, result := range results {
+		ack.SetResult(i, result)
+	}
+	return ack
+}
+
+// bindContext binds a proposed presentation context to the interface with
+// its abstract syntax if the context can use the NDR transfer syntax.
+func (p *rpcPipe) bindContext(context smbrpc.Context) smbrpc.Result {
+	abstract := context.AbstractSyntax()
+	if bytes.HasPrefix(abstract.UUID[:], rpcFeatureNegotiation) {
+		// No bind time features are supported
+		return smbrpc.Result{Result: smbrpc.NegotiateAck}
+	}
+
+	iface := p.lookupInterface(abstract)
+	if iface == nil {
+		return smbrpc.Result{
+			Result: smbrpc.ProviderRejection,
+			Reason: smbrpc.AbstractSyntaxNotSupported,
+		}
+	}
+	for i := 0; i < context.TransferSyntaxCount(); i++ {
+		if context.TransferSyntax(i) == smbrpc.NDR {
+			p.contexts[context.ID()] = iface
+			return smbrpc.Result{
+				Result:         smbrpc.Acceptance,
+				TransferSyntax: smbrpc.NDR,
+			}
+		}
+	}
+	return smbrpc.Result{
+		Result: smbrpc.ProviderRejection,
+		Reason: smbrpc.ProposedTransferSyntaxesNotSupported,
+	}
+}
+
+// lookupInterface returns the interface that serves the given abstract
+// syntax, or nil.
+func (p *rpcPipe) lookupInterface(abstract smbrpc.SyntaxID) RPCInterface {
+	for _, iface := range p.ifaces {
+		syntax := iface.Syntax()
+		if syntax.UUID == abstract.UUID && syntax.Version == abstract.Version && abstract.MinorVersion <= syntax.MinorVersion {
+			return iface
+		}
+	}
+	return nil
+}
+
+// request processes a request PDU. Once the last fragment of a call has
+// been received the call is invoked and the PDUs that carry its result are
+// returned.
+//
+// See C706 section 12.6.4.9.
+func (p *rpcPipe) request(r smbrpc.Request) [][]byte {
+	hdr := r.Header()
+	if !r.Valid() {
+		p.call = nil
+		return [][]byte{rpcFault(hdr.CallID(), 0, smbrpc.ProtocolError, true)}
+	}
+
+	flags := hdr.Flags()
+	switch {
+	case flags.Match(smbrpc.FirstFrag):
+		p.call = &rpcCall{
+			id:      hdr.CallID(),
+			context: r.ContextID(),
+			opnum:   r.Opnum(),
+			stub:    append([]byte(nil), r.Stub()...),
+		}
+	case p.call == nil || p.call.id != hdr.CallID():
+		p.call = nil
+		return [][]byte{rpcFault(hdr.CallID(), r.ContextID(), smbrpc.ProtocolError, true)}
+	default:
+		p.call.stub = append(p.call.stub, r.Stub()...)
+	}
+	if len(p.call.stub) > rpcMaxStub {
+		p.call = nil
+		return [][]byte{rpcFault(hdr.CallID(), r.ContextID(), smbrpc.ProtocolError, true)}
+	}
+	if !flags.Match(smbrpc.LastFrag) {
+		return nil
+	}
+
+	call := p.call
+	p.call = nil
+
+	iface := p.contexts[call.context]
+	if iface == nil {
+		return [][]byte{rpcFault(call.id, call.context, smbrpc.UnknownInterface, true)}
+	}
+	stub, status := iface.Call(p.conn, call.opnum, call.stub)
+	if status != 0 {
+		return [][]byte{rpcFault(call.id, call.context, status, false)}
+	}
+	return rpcResponse(call.id, call.context, stub, p.maxXmit)
+}
+
+// rpcResponse returns the response PDUs that carry the stub data returned
+// by a call, each of which is no longer than maxFrag. Fragments other than
+// the last carry a multiple of 8 bytes of stub data.
+func rpcResponse(callID uint32, context uint16, stub []byte, maxFrag int) [][]byte {
+	maxStub := (maxFrag - smbrpc.ResponseSize) &^ 7
+	if maxStub < 8 {
+		maxStub = 8
+	}
+
+	var fragments [][]byte
+	remaining := stub
+	for {
+		n := len(remaining)
+		if n > maxStub {
+			n = maxStub
+		}
+		var flags smbrpc.Flags
+		if len(fragments) == 0 {
+			flags |= smbrpc.FirstFrag
+		}
+		if n == len(remaining) {
+			flags |= smbrpc.LastFrag
+		}
+
+		response := smbrpc.Response(make([]byte, smbrpc.ResponseSize+n))
+		response.Header().Init(smbrpc.TypeResponse, flags, uint16(len(response)), callID)
+		response.SetAllocHint(uint32(len(remaining)))
+		response.SetContextID(context)
+		response.SetCancelCount(0)
+		copy(response.Stub(), remaining[:n])
+		fragments = append(fragments, response)
+
+		remaining = remaining[n:]
+		if flags.Match(smbrpc.LastFrag) {
+			return fragments
+		}
+	}
+}
+
+// rpcFault returns a fault PDU that reports the failure of a call.
+//
+// See C706 section 12.6.4.7.
+func rpcFault(callID uint32, context uint16, status smbrpc.FaultStatus, didNotExecute bool) []byte {
+	flags := smbrpc.Flags(smbrpc.FirstFrag | smbrpc.LastFrag)
+	if didNotExecute {
+		flags |= smbrpc.DidNotExecute
+	}
+	fault := smbrpc.Fault(make([]byte, smbrpc.FaultSize))
+	fault.Header().Init(smbrpc.TypeFault, flags, smbrpc.FaultSize, callID)
+	fault.SetAllocHint(0)
+	fault.SetContextID(context)
+	fault.SetCancelCount(0)
+	fault.SetStatus(status)
+	return fault
+}
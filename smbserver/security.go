@@ -0,0 +1,114 @@
+package smbserver
+
+import (
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbinfo"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// defaultSecurity returns the security descriptor of files whose file
+// systems don't store security descriptors, and of named pipes. The files
+// are owned by the Administrators group, and everyone has full access to
+// them.
+func defaultSecurity() *smbsecurity.Descriptor {
+	return &smbsecurity.Descriptor{
+		Owner: smbsecurity.Administrators,
+		Group: smbsecurity.LocalSystem,
+		DACL: &smbsecurity.ACL{
+			ACEs: []smbsecurity.ACE{{
+				Type: smbsecurity.AccessAllowed,
+				Mask: smbaccess.FileAllAccess,
+				SID:  smbsecurity.Everyone,
+			}},
+		},
+	}
+}
+
+// security returns the security descriptor of the file of o.
+func (o *Open) security() (*smbsecurity.Descriptor, error) {
+	if o.pipe != nil {
+		return defaultSecurity(), nil
+	}
+	return fileSecurity(o.FS, o.Name)
+}
+
+// securityAccess returns the access an open needs to query or set the
+// parts of its file's security descriptor selected by info.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ sections 3.3.5.20.3 and 3.3.5.21.3
+func securityAccess(info smbsecurity.Information, set bool) smbaccess.Mask {
+	var access smbaccess.Mask
+	info = info.Selected()
+	if info.Match(smbsecurity.SACLSecurityInformation) {
+		access |= smbaccess.SystemSecurity
+	}
+	if !set {
+		if info.Any(smbsecurity.OwnerSecurityInformation | smbsecurity.GroupSecurityInformation | smbsecurity.DACLSecurityInformation | smbsecurity.LabelSecurityInformation) {
+			access |= smbaccess.ReadControl
+		}
+		return access
+	}
+	if info.Any(smbsecurity.OwnerSecurityInformation | smbsecurity.GroupSecurityInformation | smbsecurity.LabelSecurityInformation) {
+		access |= smbaccess.WriteOwner
+	}
+	if info.Match(smbsecurity.DACLSecurityInformation) {
+		access |= smbaccess.WriteDAC
+	}
+	return access
+}
+
+// querySecurity returns the parts of the security descriptor of the file
+// of open that are selected by the additional information of the request.
+// If the descriptor doesn't fit in the output buffer the query fails with
+// STATUS_BUFFER_TOO_SMALL, and the error data holds the required length.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.20.3
+func (c *Conn) querySecurity(open *Open, request smbinfo.QueryRequest) Response {
+	info := smbsecurity.Information(request.AdditionalInformation())
+	if !open.GrantedAccess.Match(securityAccess(info, false)) {
+		return queryInfoError(smbstatus.AccessDenied)
+	}
+
+	sd, err := open.security()
+	if err != nil {
+		return queryInfoError(fileStatus(err))
+	}
+	output := sd.Filter(info).Marshal()
+	if len(output) > int(request.OutputBufferLength()) {
+		required := make([]byte, 4)
+		smbtype.PutUint32(required, uint32(len(output)))
+		return smbproto.ErrorResponse{Cmd: smbcommand.QueryInfo, Code: smbstatus.BufferTooSmall, Data: required}
+	}
+	return smbproto.QueryInfoResponse{Output: output}
+}
+
+// setSecurity replaces the parts of the security descriptor of the file of
+// open that are selected by the additional information of the request.
+// Security descriptors can only be set within file systems that store
+// them.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.21.3
+func (c *Conn) setSecurity(open *Open, request smbinfo.SetRequest) Response {
+	info := smbsecurity.Information(request.AdditionalInformation())
+	if !open.GrantedAccess.Match(securityAccess(info, true)) {
+		return setInfoError(smbstatus.AccessDenied)
+	}
+
+	var sd smbsecurity.Descriptor
+	if err := sd.Unmarshal(request.Buffer()); err != nil {
+		return setInfoError(smbstatus.InvalidSecurityDescr)
+	}
+	fsys, ok := open.FS.(smbfs.SecurityDescriptors)
+	if !ok || open.pipe != nil {
+		return setInfoError(smbstatus.NotSupported)
+	}
+	if err := fsys.SetSecurity(open.Name, info, &sd); err != nil {
+		return setInfoError(fileStatus(err))
+	}
+	return smbproto.SetInfoResponse{}
+}
@@ -0,0 +1,211 @@
+package smbserver_test
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smberror"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbinfo"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtree"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// securityFS is a file system that keeps the security descriptors of its
+// files in memory. Files are owned by testOwner and grant full access to
+// everyone until their descriptors are changed.
+type securityFS struct {
+	smbfs.FileSystem
+
+	mutex       sync.Mutex
+	descriptors map[string]*smbsecurity.Descriptor
+}
+
+var testOwner = smbsecurity.NewSID(5, 21, 1, 2, 3, 1000)
+
+func newSecurityFS(root string) *securityFS {
+	return &securityFS{
+		FileSystem:  smbosfs.New(root),
+		descriptors: make(map[string]*smbsecurity.Descriptor),
+	}
+}
+
+func (fsys *securityFS) Security(name string) (*smbsecurity.Descriptor, error) {
+	if _, err := fsys.Stat(name); err != nil {
+		return nil, err
+	}
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	if sd, ok := fsys.descriptors[name]; ok {
+		return sd, nil
+	}
+	return &smbsecurity.Descriptor{
+		Owner: testOwner,
+		Group: smbsecurity.Users,
+		DACL: &smbsecurity.ACL{ACEs: []smbsecurity.ACE{
+			{Type: smbsecurity.AccessAllowed, Mask: smbaccess.FileAllAccess, SID: smbsecurity.Everyone},
+		}},
+	}, nil
+}
+
+func (fsys *securityFS) SetSecurity(name string, info smbsecurity.Information, sd *smbsecurity.Descriptor) error {
+	current, err := fsys.Security(name)
+	if err != nil {
+		return err
+	}
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	fsys.descriptors[name] = current.Merge(info, sd)
+	return nil
+}
+
+var _ smbfs.SecurityDescriptors = (*securityFS)(nil)
+
+// querySecurity queries the parts of the security descriptor of a file
+// selected by info.
+func (tt *treeTest) querySecurity(desc string, id smbfile.ID, info smbsecurity.Information, length uint32, status smbstatus.Code) []byte {
+	tt.t.Helper()
+	body := make([]byte, smbinfo.QueryRequestSize+1)
+	request := smbinfo.QueryRequest(body)
+	request.SetSize(41)
+	request.SetInfoType(smbinfo.Security)
+	request.SetOutputBufferLength(length)
+	request.SetAdditionalInformation(uint32(info))
+	request.SetFileID(id)
+	packet := tt.send(smbcommand.QueryInfo, body)
+	tt.check(desc, packet, status)
+	switch status {
+	case smbstatus.Success:
+		response := smbinfo.QueryResponse(packet.Data())
+		if !response.Valid() {
+			tt.t.Fatalf("%s: invalid response", desc)
+		}
+		return response.Output()
+	case smbstatus.BufferTooSmall:
+		return smberror.Response(packet.Data()).Data()
+	}
+	return nil
+}
+
+// setSecurity sets the parts of the security descriptor of a file
+// selected by info.
+func (tt *treeTest) setSecurity(desc string, id smbfile.ID, info smbsecurity.Information, sd []byte, status smbstatus.Code) {
+	tt.t.Helper()
+	body := make([]byte, smbinfo.SetRequestSize+len(sd))
+	request := smbinfo.SetRequest(body)
+	request.SetSize(33)
+	request.SetInfoType(smbinfo.Security)
+	request.SetAdditionalInformation(uint32(info))
+	request.SetFileID(id)
+	copy(request.SetBufferLayout(len(sd)), sd)
+	tt.check(desc, tt.send(smbcommand.SetInfo, body), status)
+}
+
+// open opens or creates a file within the current tree.
+func (tt *treeTest) open(name string, access smbaccess.Mask) smbfile.ID {
+	tt.t.Helper()
+	packet := tt.send(smbcommand.Create, createBody(createSpec{Name: name, Access: access, Share: shareAll, Disposition: smbcreate.OpenIf}))
+	tt.check("open of "+name, packet, smbstatus.Success)
+	return smbcreate.Response(packet.Data()).FileID()
+}
+
+func TestSecurityInfo(t *testing.T) {
+	tt := newTreeTest(t)
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Data", Type: smbtree.Disk, FS: newSecurityFS(t.TempDir())})
+	tt.connect(`\\server\Data`, smbstatus.Success)
+
+	const ownerGroupDACL = smbsecurity.OwnerSecurityInformation | smbsecurity.GroupSecurityInformation | smbsecurity.DACLSecurityInformation
+	id := tt.open("a.txt", smbaccess.ReadControl|smbaccess.WriteDAC)
+	other := tt.open("a.txt", smbaccess.ReadData)
+
+	// A query with a buffer that is too small reports the required length
+	required := tt.querySecurity("query with a small buffer", id, ownerGroupDACL, 8, smbstatus.BufferTooSmall)
+	if len(required) != 4 {
+		t.Fatalf("query with a small buffer: %d bytes of error data (want 4)", len(required))
+	}
+	output := tt.querySecurity("query", id, ownerGroupDACL, smbtype.Uint32(required), smbstatus.Success)
+	var sd smbsecurity.Descriptor
+	if err := sd.Unmarshal(output); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if !sd.Owner.Equal(testOwner) || !sd.Group.Equal(smbsecurity.Users) || sd.DACL == nil || len(sd.DACL.ACEs) != 1 {
+		t.Fatalf("query: returned owner %s, group %s", sd.Owner, sd.Group)
+	}
+
+	// Only the requested parts are returned
+	output = tt.querySecurity("query of the owner", id, smbsecurity.OwnerSecurityInformation, 1024, smbstatus.Success)
+	if err := sd.Unmarshal(output); err != nil || sd.Group != nil || sd.DACL != nil || !sd.Owner.Equal(testOwner) {
+		t.Fatalf("query of the owner: returned %+v (%v)", sd, err)
+	}
+
+	// Replacing the DACL leaves the owner intact
+	users := smbsecurity.NewSID(5, 21, 1, 2, 3, 1001)
+	update := smbsecurity.Descriptor{DACL: &smbsecurity.ACL{ACEs: []smbsecurity.ACE{
+		{Type: smbsecurity.AccessDenied, Mask: smbaccess.WriteData, SID: users},
+		{Type: smbsecurity.AccessAllowed, Flags: smbsecurity.ObjectInherit, Mask: smbaccess.GenericRead | smbaccess.GenericWrite, SID: users},
+	}}}
+	tt.setSecurity("set of the DACL", id, smbsecurity.DACLSecurityInformation, update.Marshal(), smbstatus.Success)
+	output = tt.querySecurity("query after set", id, ownerGroupDACL, 1024, smbstatus.Success)
+	if err := sd.Unmarshal(output); err != nil {
+		t.Fatalf("query after set: %v", err)
+	}
+	if !sd.Owner.Equal(testOwner) || sd.DACL == nil || len(sd.DACL.ACEs) != 2 {
+		t.Fatalf("query after set: unexpected descriptor %+v", sd)
+	}
+	if ace := sd.DACL.ACEs[1]; ace.Type != smbsecurity.AccessAllowed || ace.Flags != smbsecurity.ObjectInherit || !ace.SID.Equal(users) {
+		t.Fatalf("query after set: second ACE is %+v", ace)
+	}
+
+	// The descriptor is evaluated in order, so the denied right isn't
+	// granted by the later ACE
+	token := &smbsecurity.Token{User: users, Groups: []smbsecurity.SID{smbsecurity.Everyone}}
+	if _, ok := smbsecurity.AccessCheck(&sd, token, smbaccess.ReadData|smbaccess.WriteData); ok {
+		t.Fatal("access check: denied right was granted")
+	}
+	granted, ok := smbsecurity.AccessCheck(&sd, token, smbaccess.MaximumAllowed)
+	if !ok || granted.Any(smbaccess.WriteData) || !granted.Match(smbaccess.FileGenericRead|smbaccess.AppendData) {
+		t.Fatalf("access check: maximum allowed access is %s", granted)
+	}
+	if granted, ok := smbsecurity.AccessCheck(&sd, &smbsecurity.Token{User: testOwner}, smbaccess.MaximumAllowed); !ok || granted != smbaccess.ReadControl|smbaccess.WriteDAC {
+		t.Fatalf("access check: owner is granted %s", granted)
+	}
+
+	// Malformed descriptors are rejected
+	tt.setSecurity("set of a malformed descriptor", id, smbsecurity.DACLSecurityInformation, []byte{1, 0, 4, 0x80}, smbstatus.InvalidSecurityDescr)
+
+	// The access of the open determines which parts can be queried or set
+	tt.querySecurity("query of the SACL", id, smbsecurity.SACLSecurityInformation, 1024, smbstatus.AccessDenied)
+	tt.setSecurity("set of the owner", id, smbsecurity.OwnerSecurityInformation, update.Marshal(), smbstatus.AccessDenied)
+	tt.querySecurity("query without READ_CONTROL", other, ownerGroupDACL, 1024, smbstatus.AccessDenied)
+}
+
+func TestSecurityInfoDefault(t *testing.T) {
+	tt := newTreeTest(t)
+	dir := t.TempDir()
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Data", Type: smbtree.Disk, FS: smbosfs.New(dir)})
+	tt.connect(`\\server\Data`, smbstatus.Success)
+	if err := os.WriteFile(dir+"/a.txt", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	id := tt.open("a.txt", smbaccess.ReadControl|smbaccess.WriteDAC)
+
+	// File systems without security descriptors report a default
+	// descriptor that can't be changed
+	var sd smbsecurity.Descriptor
+	if err := sd.Unmarshal(tt.querySecurity("query", id, smbsecurity.OwnerSecurityInformation|smbsecurity.DACLSecurityInformation, 1024, smbstatus.Success)); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if granted, ok := smbsecurity.AccessCheck(&sd, &smbsecurity.Token{User: testOwner, Groups: []smbsecurity.SID{smbsecurity.Everyone}}, smbaccess.GenericAll); !ok || granted != smbaccess.FileAllAccess {
+		t.Fatalf("default descriptor grants %s to everyone", granted)
+	}
+	tt.setSecurity("set", id, smbsecurity.DACLSecurityInformation, sd.Marshal(), smbstatus.NotSupported)
+}
@@ -7,21 +7,90 @@ import (
 	"github.com/gentlemanautomaton/smb"
 	"github.com/gentlemanautomaton/smb/smbdialect"
 	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbioctl"
 	"github.com/gentlemanautomaton/smb/smbsequencer"
+	"github.com/gentlemanautomaton/smb/smbstore"
 )
 
 // Server responds to SMB connection requests.
 type Server struct {
-	handler Handler
-	id      smbid.ID
+	handler  Handler
+	id       smbid.ID
+	opens    *OpenTable
+	leases   *LeaseTable
+	durable  *DurableTable
+	sessions *SessionTable
+	store    smbstore.Store
+	auth     Authenticator
+	ifaces   InterfaceFunc
+	fsctls   *FSCTLRegistry
+	shares   *ShareTable
+	trees    *TreeTable
+	pipes    *PipeRegistry
+	posix    bool
 }
 
 // New returns a new SMB server with message handler h.
+//
+// The server serves the srvsvc named pipe with ServerService, so that
+// clients can enumerate its shares. It can be replaced with HandlePipe.
 func New(id smbid.ID, h Handler) *Server {
-	return &Server{
+	s := &Server{
 		handler: h,
 		id:      id,
+		opens:   NewOpenTable(),
+		leases:  NewLeaseTable(),
+		durable: NewDurableTable(),
+		fsctls:  NewFSCTLRegistry(),
+		shares:  NewShareTable(),
+		trees:   NewTreeTable(),
+		pipes:   NewPipeRegistry(),
 	}
+	s.pipes.Register("srvsvc", RPCPipe(ServerService{}))
+	return s
+}
+
+// AddShare causes s to serve the given share to clients, replacing any
+// share with the same name. The IPC$ share is always served.
+func (s *Server) AddShare(share *Share) {
+	s.shares.Add(share)
+}
+
+// HandlePipe causes h to serve the named pipe with the given name within
+// the IPC$ share, replacing any handler registered for the name.
+func (s *Server) HandlePipe(name string, h PipeHandler) {
+	s.pipes.Register(name, h)
+}
+
+// HandleFSCTL causes h to handle file system control requests with the
+// given control code, replacing any built-in handler for the code.
+func (s *Server) HandleFSCTL(code smbioctl.Code, h FSCTLHandler) {
+	s.fsctls.Register(code, h)
+}
+
+// AdvertiseInterfaces causes s to report the network interfaces returned
+// by f to clients that query them, instead of the local interfaces of the
+// host.
+func (s *Server) AdvertiseInterfaces(f InterfaceFunc) {
+	s.ifaces = f
+}
+
+// Authenticate causes s to authenticate the users of new sessions with a.
+// Sessions, message signing and multichannel are enabled once an
+// authenticator has been set. It should be called before s starts serving
+// connections.
+func (s *Server) Authenticate(a Authenticator) {
+	s.auth = a
+	if s.sessions == nil {
+		s.sessions = NewSessionTable()
+	}
+}
+
+// EnablePOSIXExtensions causes s to offer the SMB3 POSIX extensions to
+// clients that negotiate SMB 3.1.1 and ask for them. It should be called
+// before s starts serving connections.
+func (s *Server) EnablePOSIXExtensions() {
+	s.posix = true
 }
 
 // Serve starts serving connections on l with the given handler.
@@ -50,15 +119,33 @@ func (s Server) Serve(l smb.Listener) error {
 
 func (s Server) serve(transport smb.Conn) {
 	defer transport.Close()
+	async := NewAsyncCommandList()
+	defer async.Abort()
 	s.handler.ServeSMB(Conn{
 		Conn:      transport,
 		Sequencer: smbsequencer.New(128),
 		ConnState: ConnState{
-			Dialect:      smbdialect.Uninitialized,
-			CreationTime: time.Now(),
+			Dialect:       smbdialect.Uninitialized,
+			CreationTime:  time.Now(),
+			AsyncCommands: async,
+			Notifications: new(NotifyQueue),
 		},
 		GlobalState: GlobalState{
-			Server: s.id,
+			Server:  s.id,
+			Opens:   s.opens,
+			Leases:  s.leases,
+			Durable: s.durable,
+			Store:   s.store,
+
+			Sessions:      s.sessions,
+			Authenticator: s.auth,
+			Interfaces:    s.ifaces,
+			FSCTLs:        s.fsctls,
+			Shares:        s.shares,
+			Trees:         s.trees,
+			Pipes:         s.pipes,
+
+			POSIXExtensionsSupported: s.posix,
 		},
 	})
 }
@@ -0,0 +1,466 @@
+package smbserver
+
+import (
+	"crypto/sha512"
+	"sync"
+
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbsecmode"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbsession"
+	"github.com/gentlemanautomaton/smb/smbsign"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// Authenticator authenticates the users that establish sessions with the
+// server. Each session setup exchange, including the exchanges that bind
+// additional channels to a session, is carried out by a separate
+// AuthExchange.
+type Authenticator interface {
+	Begin() AuthExchange
+}
+
+// AuthExchange is an authentication exchange that is carried out over one
+// or more session setup requests.
+type AuthExchange interface {
+	// Step processes the security token of a session setup request. It
+	// returns the security token for the response, and whether the
+	// exchange is complete. If it returns an error the exchange fails.
+	Step(token []byte) (response []byte, complete bool, err error)
+
+	// SessionKey returns the session key produced by a completed exchange.
+	SessionKey() []byte
+}
+
+// IdentifiedExchange is an AuthExchange that identifies the user it has
+// authenticated. Sessions established by other exchanges are anonymous.
+type IdentifiedExchange interface {
+	AuthExchange
+
+	// Token returns the security token of the user authenticated by a
+	// completed exchange, which access checks are evaluated against.
+	Token() *smbsecurity.Token
+}
+
+// SessionTable holds the sessions established on the server. Sessions are
+// shared by every connection that has been bound to them as a channel. It
+// must be created with NewSessionTable.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.1.1
+type SessionTable struct {
+	mutex    sync.Mutex
+	last     uint64 // The last session ID issued
+	sessions map[uint64]*Session
+}
+
+// NewSessionTable returns an empty session table that is ready for use.
+func NewSessionTable() *SessionTable {
+	return &SessionTable{
+		sessions: make(map[uint64]*Session),
+	}
+}
+
+// Lookup returns the session with the given ID, or nil.
+func (t *SessionTable) Lookup(id uint64) *Session {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.sessions[id]
+}
+
+// Len returns the number of sessions in the table, including sessions that
+// are still being set up.
+func (t *SessionTable) Len() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.sessions)
+}
+
+// create adds a new session to the table for a client that has negotiated
+// the given dialect.
+func (t *SessionTable) create(dialect smbdialect.Revision, client smbid.ID, signing bool) *Session {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Session IDs are never zero
+	t.last++
+	s := &Session{
+		ID:              t.last,
+		Dialect:         dialect,
+		ClientGUID:      client,
+		signingRequired: signing,
+		channels:        make(map[*Conn]*sessionChannel),
+		pending:         make(map[*Conn]*sessionSetup),
+	}
+	t.sessions[s.ID] = s
+	return s
+}
+
+// removeIfUnused removes s from the table if it has no channels and no
+// session setup exchanges in progress.
+func (t *SessionTable) removeIfUnused(s *Session) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.channels) == 0 && len(s.pending) == 0 {
+		delete(t.sessions, s.ID)
+	}
+}
+
+// unbind removes c from the channels of every session. Sessions that are
+// left without channels are removed from the table. For each session that
+// is still bound to other connections it returns one of them, keyed by
+// session ID.
+func (t *SessionTable) unbind(c *Conn) map[uint64]*Conn {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	survivors := make(map[uint64]*Conn)
+	for id, s := range t.sessions {
+		s.mutex.Lock()
+		_, bound := s.channels[c]
+		delete(s.channels, c)
+		delete(s.pending, c)
+		if bound {
+			for other := range s.channels {
+				survivors[id] = other
+				break
+			}
+		}
+		if len(s.channels) == 0 && len(s.pending) == 0 {
+			delete(t.sessions, id)
+		}
+		s.mutex.Unlock()
+	}
+	return survivors
+}
+
+// Session is an authenticated session. A session is bound to one or more
+// connections, each of which is a channel of the session with its own
+// signing key. Opens made within the session can be used through any of
+// its channels.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.1.8
+type Session struct {
+	ID         uint64
+	Dialect    smbdialect.Revision
+	ClientGUID smbid.ID
+
+	mutex           sync.Mutex
+	valid           bool               // True once the first exchange has completed
+	signingRequired bool               // True if every request must be signed
+	signingKey      []byte             // The signing key of the session
+	token           *smbsecurity.Token // The token of the user, or nil if the user is anonymous
+	channels        map[*Conn]*sessionChannel
+	pending         map[*Conn]*sessionSetup
+}
+
+// Channels returns the number of connections bound to the session.
+func (s *Session) Channels() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.channels)
+}
+
+// sessionChannel is a connection that is bound to a session.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.1.14
+type sessionChannel struct {
+	signingKey []byte
+}
+
+// sessionSetup is a session setup exchange in progress on a connection.
+type sessionSetup struct {
+	exchange AuthExchange
+	binding  bool
+	preauth  []byte // Preauthentication integrity hash, for SMB 3.1.1
+}
+
+// update extends the preauthentication integrity hash of the exchange with
+// the bytes of a session setup request or response.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.5
+func (setup *sessionSetup) update(packet []byte) {
+	if setup.preauth == nil {
+		return
+	}
+	setup.preauth = extendPreauth(setup.preauth, packet)
+}
+
+// begin returns the exchange in progress on c, or starts a new one. A new
+// exchange is only started on a connection that is already bound to the
+// session, or if the session is new or being bound to c. It returns nil
+// otherwise.
+func (s *Session) begin(c *Conn, binding, created bool) *sessionSetup {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if setup := s.pending[c]; setup != nil {
+		if setup.binding != binding {
+			return nil
+		}
+		return setup
+	}
+
+	_, bound := s.channels[c]
+	switch {
+	case binding && bound:
+		return nil
+	case !binding && !bound && !created:
+		return nil
+	}
+
+	setup := &sessionSetup{
+		exchange: c.Authenticator.Begin(),
+		binding:  binding,
+	}
+	if s.Dialect == smbdialect.SMB311 {
+		setup.preauth = append([]byte(nil), c.PreauthIntegrityHash...)
+		if setup.preauth == nil {
+			setup.preauth = make([]byte, sha512.Size)
+		}
+	}
+	s.pending[c] = setup
+	return setup
+}
+
+// abandon discards the exchange in progress on c.
+func (s *Session) abandon(c *Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.pending, c)
+}
+
+// establish completes the exchange in progress on c and binds c to the
+// session as a channel. The first exchange of a session establishes the
+// session's signing key, which is also the signing key of its first
+// channel. Each additional channel derives its own signing key from the
+// session key of the exchange that bound it. Exchanges that reauthenticate
+// an existing channel leave its keys unchanged.
+//
+// It returns the signing key of the channel.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.5.3
+func (s *Session) establish(c *Conn, setup *sessionSetup) []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.pending, c)
+	if ch := s.channels[c]; ch != nil {
+		return ch.signingKey
+	}
+
+	key := smbsign.SigningKey(setup.exchange.SessionKey(), s.Dialect, setup.preauth)
+	if !setup.binding {
+		s.valid = true
+		s.signingKey = key
+		if identified, ok := setup.exchange.(IdentifiedExchange); ok {
+			s.token = identified.Token()
+		}
+	}
+	s.channels[c] = &sessionChannel{signingKey: key}
+	return key
+}
+
+// Token returns the security token of the user of the session. Sessions
+// whose users haven't been identified by their authentication exchange
+// have an anonymous token.
+func (s *Session) Token() *smbsecurity.Token {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.token == nil {
+		return anonymousToken
+	}
+	return s.token
+}
+
+// signing returns the signing key of the session, or false if the session
+// hasn't been established.
+func (s *Session) signing() ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.signingKey, s.valid
+}
+
+// channel returns the channel of the session that is bound to c, or nil.
+func (s *Session) channel(c *Conn) *sessionChannel {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.channels[c]
+}
+
+// SessionSetup processes an SMB2 SESSION_SETUP request. It carries out an
+// authentication exchange using the server's authenticator, which may take
+// several round trips. When the exchange completes the session is
+// established and the connection becomes its first channel.
+//
+// Requests with SMB2_SESSION_FLAG_BINDING set bind the connection to an
+// existing session as an additional channel. Binding requires SMB 3.x and
+// requests that are signed with the session's signing key. Once bound, the
+// connection signs and verifies messages with its own channel signing key
+// and can use the opens of the session.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.5
+func (c *Conn) SessionSetup(r *Request) Response {
+	request := smbsession.Request(r.Data())
+	if !request.Valid() {
+		return sessionSetupError(smbstatus.InvalidParameter)
+	}
+	if c.Sessions == nil || c.Authenticator == nil {
+		return sessionSetupError(smbstatus.NotSupported)
+	}
+
+	if request.Flags().Match(smbsession.Binding) {
+		return c.bindSession(r, request)
+	}
+
+	var (
+		s       *Session
+		created bool
+	)
+	if r.SessionID == 0 {
+		signing := c.RequireMessageSigning || c.ClientSecurity.Match(smbsecmode.SigningRequired)
+		s = c.Sessions.create(c.Dialect.Revision(), c.ClientGUID, signing)
+		created = true
+		r.SessionID = s.ID
+	} else if s = c.Sessions.Lookup(r.SessionID); s == nil {
+		return sessionSetupError(smbstatus.UserSessionDeleted)
+	}
+
+	setup := s.begin(c, false, created)
+	if setup == nil {
+		return sessionSetupError(smbstatus.UserSessionDeleted)
+	}
+	return c.stepSession(r, s, setup, request)
+}
+
+// bindSession processes a session setup request that binds the connection
+// to an existing session.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.5.2
+func (c *Conn) bindSession(r *Request, request smbsession.Request) Response {
+	if c.Dialect.Revision().Major() < 3 {
+		return sessionSetupError(smbstatus.RequestNotAccepted)
+	}
+	s := c.Sessions.Lookup(r.SessionID)
+	if s == nil {
+		return sessionSetupError(smbstatus.UserSessionDeleted)
+	}
+	if s.Dialect != c.Dialect.Revision() {
+		return sessionSetupError(smbstatus.InvalidParameter)
+	}
+	if s.ClientGUID != c.ClientGUID {
+		return sessionSetupError(smbstatus.UserSessionDeleted)
+	}
+	if !r.Header().Flags().Match(smbpacket.Signed) {
+		return sessionSetupError(smbstatus.InvalidParameter)
+	}
+	key, valid := s.signing()
+	if !valid {
+		return sessionSetupError(smbstatus.RequestNotAccepted)
+	}
+	if !smbsign.Verify(key, s.Dialect, r.Packet) {
+		return sessionSetupError(smbstatus.AccessDenied)
+	}
+
+	setup := s.begin(c, true, false)
+	if setup == nil {
+		return sessionSetupError(smbstatus.RequestNotAccepted)
+	}
+	return c.stepSession(r, s, setup, request)
+}
+
+// stepSession passes the security token of a session setup request to the
+// exchange in progress and returns the response for the resulting step.
+func (c *Conn) stepSession(r *Request, s *Session, setup *sessionSetup, request smbsession.Request) Response {
+	setup.update(r.Packet)
+
+	token, complete, err := setup.exchange.Step(request.SecurityBuffer())
+	if err != nil {
+		s.abandon(c)
+		c.Sessions.removeIfUnused(s)
+		return sessionSetupError(smbstatus.LogonFailure)
+	}
+	if !complete {
+		return sessionSetupResponse{
+			SessionSetupResponse: smbproto.SessionSetupResponse{
+				Code:           smbstatus.MoreProcessingRequired,
+				SecurityBuffer: token,
+			},
+			setup: setup,
+		}
+	}
+
+	// The final response is always signed
+	r.signingKey = s.establish(c, setup)
+	return smbproto.SessionSetupResponse{
+		Code:           smbstatus.Success,
+		SecurityBuffer: token,
+	}
+}
+
+// sessionSetupResponse is an interim session setup response. The bytes of
+// the response are added to the preauthentication integrity hash of the
+// exchange once it has been assembled.
+type sessionSetupResponse struct {
+	smbproto.SessionSetupResponse
+	setup *sessionSetup
+}
+
+// sent updates the preauthentication integrity hash of the exchange with
+// the bytes of the response.
+func (r sessionSetupResponse) sent(packet []byte) {
+	r.setup.update(packet)
+}
+
+func sessionSetupError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.SessionSetup, Code: code}
+}
+
+// checkSession verifies that the request belongs to a session that is
+// bound to the connection and checks its signature. It returns the signing
+// key that must be used to sign the response, if any.
+//
+// Session setup and negotiate requests are checked by their handlers.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ sections 3.3.5.2.4 and 3.3.5.2.9
+func (c *Conn) checkSession(r *Request) (key []byte, status smbstatus.Code) {
+	if c.Sessions == nil {
+		return nil, smbstatus.Success
+	}
+	switch r.Header().Command() {
+	case smbcommand.Negotiate, smbcommand.SessionSetup:
+		return nil, smbstatus.Success
+	}
+
+	s := c.Sessions.Lookup(r.SessionID)
+	if s == nil {
+		return nil, smbstatus.UserSessionDeleted
+	}
+	ch := s.channel(c)
+	if ch == nil {
+		return nil, smbstatus.UserSessionDeleted
+	}
+
+	signed := r.Header().Flags().Match(smbpacket.Signed)
+	switch {
+	case signed && !smbsign.Verify(ch.signingKey, s.Dialect, r.Packet):
+		return nil, smbstatus.AccessDenied
+	case !signed && s.signingRequired:
+		return nil, smbstatus.AccessDenied
+	case signed || s.signingRequired:
+		return ch.signingKey, smbstatus.Success
+	default:
+		return nil, smbstatus.Success
+	}
+}
+
+// multiChannel returns true if connections can be bound to existing
+// sessions. Multichannel requires SMB 3.x.
+func (c *Conn) multiChannel() bool {
+	return c.Sessions != nil && c.Authenticator != nil && c.Dialect.Ready() && c.Dialect.Revision().Major() == 3
+}
@@ -0,0 +1,384 @@
+package smbserver_test
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/msgpool"
+	"github.com/gentlemanautomaton/smb/smbcap"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbread"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbsession"
+	"github.com/gentlemanautomaton/smb/smbsign"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtcp"
+)
+
+// testAuthenticator carries out a two step exchange. The first token must
+// be "hello". The second token is used as the session key.
+type testAuthenticator struct{}
+
+func (testAuthenticator) Begin() smbserver.AuthExchange {
+	return &testExchange{}
+}
+
+type testExchange struct {
+	step int
+	key  []byte
+}
+
+func (e *testExchange) Step(token []byte) ([]byte, bool, error) {
+	e.step++
+	switch {
+	case e.step == 1 && string(token) == "hello":
+		return []byte("challenge"), false, nil
+	case e.step == 2 && len(token) == smbsign.KeySize:
+		e.key = token
+		return nil, true, nil
+	default:
+		return nil, false, errors.New("authentication failed")
+	}
+}
+
+func (e *testExchange) SessionKey() []byte {
+	return e.key
+}
+
+var testClientGUID = smbid.ID{1, 2, 3, 4}
+
+// sessionTest runs a server that accepts connections on a local TCP
+// listener.
+type sessionTest struct {
+	t        *testing.T
+	dialect  smbdialect.State
+	address  string
+	sessions chan *smbserver.SessionTable
+	opens    chan *smbserver.OpenTable
+}
+
+func newSessionTest(t *testing.T, dialect smbdialect.State) *sessionTest {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	st := &sessionTest{
+		t:        t,
+		dialect:  dialect,
+		address:  l.Addr().String(),
+		sessions: make(chan *smbserver.SessionTable, 8),
+		opens:    make(chan *smbserver.OpenTable, 8),
+	}
+
+	fs := smbosfs.New(t.TempDir())
+	handler := smbserver.CommandHandlerFunc(func(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+		switch r.Header().Command() {
+		case smbcommand.SessionSetup:
+			return c.SessionSetup(r)
+		case smbcommand.Create:
+			return c.CreateFile(r, fs)
+		case smbcommand.Read:
+			return c.Read(r)
+		case smbcommand.Write:
+			return c.Write(r)
+		case smbcommand.IOCTL:
+			return c.Ioctl(r)
+		}
+		return nil
+	})
+
+	s := smbserver.New(smbid.ID{9}, smbserver.HandlerFunc(func(conn smbserver.Conn) {
+		defer conn.Disconnect()
+		conn.Dialect = st.dialect
+		conn.ClientGUID = testClientGUID
+		conn.Expand(32)
+		st.sessions <- conn.Sessions
+		st.opens <- conn.Opens
+		for {
+			msg, err := conn.Receive()
+			if err != nil {
+				return
+			}
+			err = conn.Process(msg, handler)
+			msg.Close()
+			if err != nil {
+				return
+			}
+		}
+	}))
+	s.Authenticate(testAuthenticator{})
+	go s.Serve(smbtcp.NewListener(l, msgpool.New()))
+	return st
+}
+
+// tables returns the session and open tables of the server.
+func (st *sessionTest) tables() (*smbserver.SessionTable, *smbserver.OpenTable) {
+	return <-st.sessions, <-st.opens
+}
+
+// testClient is a client connection to a sessionTest server.
+type testClient struct {
+	t         *testing.T
+	dialect   smbdialect.Revision
+	nc        net.Conn
+	messageID uint64
+	sessionID uint64
+	preauth   []byte // Preauthentication integrity hash of the exchange
+	key       []byte // Signing key of the channel
+}
+
+func (st *sessionTest) dial() *testClient {
+	st.t.Helper()
+	nc, err := net.Dial("tcp", st.address)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	st.t.Cleanup(func() { nc.Close() })
+	return &testClient{t: st.t, dialect: st.dialect.Revision(), nc: nc}
+}
+
+// send sends a request signed with key, if it isn't nil, and returns the
+// response.
+func (tc *testClient) send(cmd smbcommand.Code, flags smbpacket.Flags, key []byte, body []byte) smbpacket.Response {
+	tc.t.Helper()
+	if key != nil {
+		flags |= smbpacket.Signed
+	}
+	r := testRequest{Command: cmd, MessageID: tc.messageID, Flags: flags, SessionID: tc.sessionID, TreeID: 1, Body: body}
+	tc.messageID++
+	packet := makeMessage(r).Bytes()
+	if key != nil {
+		smbsign.Sign(key, tc.dialect, packet)
+	}
+	if cmd == smbcommand.SessionSetup {
+		tc.update(packet)
+	}
+
+	frame := append([]byte{0, byte(len(packet) >> 16), byte(len(packet) >> 8), byte(len(packet))}, packet...)
+	if _, err := tc.nc.Write(frame); err != nil {
+		tc.t.Fatal(err)
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(tc.nc, header); err != nil {
+		tc.t.Fatal(err)
+	}
+	response := make([]byte, smbtcp.Header(header).Length())
+	if _, err := io.ReadFull(tc.nc, response); err != nil {
+		tc.t.Fatal(err)
+	}
+	return smbpacket.Response(response)
+}
+
+// update extends the preauthentication integrity hash of the client.
+func (tc *testClient) update(packet []byte) {
+	if tc.dialect != smbdialect.SMB311 {
+		return
+	}
+	if tc.preauth == nil {
+		tc.preauth = make([]byte, sha512.Size)
+	}
+	h := sha512.New()
+	h.Write(tc.preauth)
+	h.Write(packet)
+	tc.preauth = h.Sum(nil)
+}
+
+// setup sends a session setup request carrying token.
+func (tc *testClient) setup(flags smbsession.Flags, key []byte, token string) smbpacket.Response {
+	tc.t.Helper()
+	body := make([]byte, smbsession.RequestSize+len(token))
+	request := smbsession.Request(body)
+	request.SetSize(25)
+	request.SetFlags(flags)
+	copy(request.SetSecurityBufferLayout(len(token)), token)
+	return tc.send(smbcommand.SessionSetup, 0, key, body)
+}
+
+// authenticate carries out a session setup exchange that establishes a new
+// session or binds a channel to an existing one. The session key of the
+// exchange is sessionKey. Binding requests are signed with bindingKey.
+func (tc *testClient) authenticate(flags smbsession.Flags, bindingKey, sessionKey []byte) {
+	tc.t.Helper()
+	tc.preauth = nil
+
+	response := tc.setup(flags, bindingKey, "hello")
+	if s := response.Header().Status(); s != smbstatus.MoreProcessingRequired {
+		tc.t.Fatalf("first session setup returned %s", s)
+	}
+	if token := smbsession.Response(response.Data()).SecurityBuffer(); string(token) != "challenge" {
+		tc.t.Fatalf("first session setup returned token %q", token)
+	}
+	tc.sessionID = response.Header().SessionID()
+	tc.update(response)
+
+	response = tc.setup(flags, bindingKey, string(sessionKey))
+	if s := response.Header().Status(); s != smbstatus.Success {
+		tc.t.Fatalf("second session setup returned %s", s)
+	}
+	tc.key = smbsign.SigningKey(sessionKey, tc.dialect, tc.preauth)
+	tc.verify("session setup", response)
+}
+
+// verify checks that a response has been signed with the channel's key.
+func (tc *testClient) verify(desc string, response smbpacket.Response) {
+	tc.t.Helper()
+	if !response.Header().Flags().Match(smbpacket.Signed) {
+		tc.t.Fatalf("%s: response isn't signed", desc)
+	}
+	if !smbsign.Verify(tc.key, tc.dialect, response) {
+		tc.t.Fatalf("%s: response signature is invalid", desc)
+	}
+}
+
+// expect sends a signed request and checks the status of its response.
+func (tc *testClient) expect(desc string, cmd smbcommand.Code, body []byte, status smbstatus.Code) smbpacket.Response {
+	tc.t.Helper()
+	response := tc.send(cmd, 0, tc.key, body)
+	if s := response.Header().Status(); s != status {
+		tc.t.Fatalf("%s: returned %s (want %s)", desc, s, status)
+	}
+	if status == smbstatus.Success {
+		tc.verify(desc, response)
+	}
+	return response
+}
+
+func readBody(id smbfile.ID, length uint32) []byte {
+	body := make([]byte, smbread.RequestSize+1)
+	request := smbread.Request(body)
+	request.SetSize(49)
+	request.SetLength(length)
+	request.SetFileID(id)
+	return body
+}
+
+func TestSessionCapabilities(t *testing.T) {
+	conn, _ := newTestConn(1)
+	if conn.Capabilities().Match(smbcap.MultiChannel) {
+		t.Errorf("multichannel advertised without sessions")
+	}
+	conn.Sessions = smbserver.NewSessionTable()
+	conn.Authenticator = testAuthenticator{}
+	if !conn.Capabilities().Match(smbcap.MultiChannel) {
+		t.Errorf("multichannel not advertised for %s", conn.Dialect)
+	}
+	conn.Dialect = smbdialect.SMB21
+	if conn.Capabilities().Match(smbcap.MultiChannel) {
+		t.Errorf("multichannel advertised for %s", conn.Dialect)
+	}
+}
+
+func TestSessionSetup(t *testing.T) {
+	st := newSessionTest(t, smbdialect.SMB311)
+	a := st.dial()
+	sessions, _ := st.tables()
+
+	// Requests outside of a session are rejected
+	a.expect("read without session", smbcommand.Read, readBody(smbfile.ID{}, 1), smbstatus.UserSessionDeleted)
+
+	// Failed exchanges discard the session
+	if s := a.setup(0, nil, "goodbye").Header().Status(); s != smbstatus.LogonFailure {
+		t.Fatalf("session setup with bad token returned %s", s)
+	}
+	if n := sessions.Len(); n != 0 {
+		t.Fatalf("%d sessions remain after failed authentication", n)
+	}
+
+	a.authenticate(0, nil, bytes.Repeat([]byte{0xa}, 16))
+	if s := sessions.Lookup(a.sessionID); s == nil || s.Channels() != 1 {
+		t.Fatalf("session %d wasn't established", a.sessionID)
+	}
+
+	// Requests with bad signatures are rejected
+	key := a.key
+	a.key = bytes.Repeat([]byte{0xb}, 16)
+	a.expect("read with bad signature", smbcommand.Read, readBody(smbfile.ID{}, 1), smbstatus.AccessDenied)
+	a.key = key
+	a.expect("read of missing file", smbcommand.Read, readBody(smbfile.ID{}, 1), smbstatus.FileClosed)
+}
+
+func TestMultiChannel(t *testing.T) {
+	for _, dialect := range []smbdialect.State{smbdialect.SMB3, smbdialect.SMB311} {
+		t.Run(dialect.String(), func(t *testing.T) {
+			st := newSessionTest(t, dialect)
+
+			a := st.dial()
+			sessions, opens := st.tables()
+			a.authenticate(0, nil, bytes.Repeat([]byte{0xa}, 16))
+			session := sessions.Lookup(a.sessionID)
+
+			response := a.expect("create", smbcommand.Create, createBody(createSpec{
+				Name:        "a.txt",
+				Access:      readWrite,
+				Share:       shareAll,
+				Disposition: smbcreate.Create,
+			}), smbstatus.Success)
+			id := smbcreate.Response(response.Data()).FileID()
+
+			// Binding requests must be signed with the session's key
+			b := st.dial()
+			<-st.sessions
+			<-st.opens
+			b.sessionID = a.sessionID
+			if s := b.setup(smbsession.Binding, nil, "hello").Header().Status(); s != smbstatus.InvalidParameter {
+				t.Fatalf("unsigned binding request returned %s", s)
+			}
+			if s := b.setup(smbsession.Binding, bytes.Repeat([]byte{0xb}, 16), "hello").Header().Status(); s != smbstatus.AccessDenied {
+				t.Fatalf("binding request with bad signature returned %s", s)
+			}
+
+			b.authenticate(smbsession.Binding, a.key, bytes.Repeat([]byte{0xb}, 16))
+			if b.sessionID != a.sessionID {
+				t.Fatalf("binding returned session %d (want %d)", b.sessionID, a.sessionID)
+			}
+			if bytes.Equal(a.key, b.key) {
+				t.Fatalf("channels share a signing key")
+			}
+			if n := session.Channels(); n != 2 {
+				t.Fatalf("session has %d channels (want 2)", n)
+			}
+
+			// A connection can't be bound to the same session twice
+			if s := a.setup(smbsession.Binding, a.key, "hello").Header().Status(); s != smbstatus.RequestNotAccepted {
+				t.Fatalf("second binding of a channel returned %s", s)
+			}
+
+			// Each channel signs with its own key
+			key := b.key
+			b.key = a.key
+			b.expect("write signed with another channel's key", smbcommand.Write, writeBody(id, "data"), smbstatus.AccessDenied)
+			b.key = key
+
+			// Opens are shared by the channels of the session
+			b.expect("write through second channel", smbcommand.Write, writeBody(id, "data"), smbstatus.Success)
+
+			// The session survives the loss of a channel
+			a.nc.Close()
+			waitFor(t, func() bool { return session.Channels() == 1 })
+			if n := opens.Len(); n != 1 {
+				t.Fatalf("%d opens remain after losing a channel (want 1)", n)
+			}
+			response = b.expect("read through remaining channel", smbcommand.Read, readBody(id, 4), smbstatus.Success)
+			if data := smbread.Response(response.Data()).Data(); string(data) != "data" {
+				t.Fatalf("read returned %q", data)
+			}
+
+			// The session ends with its last channel
+			b.nc.Close()
+			waitFor(t, func() bool { return sessions.Len() == 0 && opens.Len() == 0 })
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package smbserver
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gentlemanautomaton/smb/smbcase"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+// IPC is the name of the interprocess communication share, which holds the
+// named pipes of the server.
+const IPC = "IPC$"
+
+// Share is a resource that clients connect to with tree connect requests.
+// Disk shares are backed by a file system. Pipe shares hold the named
+// pipes registered with the server.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.1.6
+type Share struct {
+	Name    string
+	Type    smbtree.ShareType
+	Comment string           // A description of the share for share enumeration
+	FS      smbfs.FileSystem // The file system of a disk share
+	Flags   smbtree.ShareFlags
+
+	// Security holds the share permissions, which limit the access that
+	// users are granted to everything within the share. Shares without
+	// permissions grant full access to everyone.
+	Security *smbsecurity.Descriptor
+
+	// CaseInsensitive causes the names of files within a disk share to be
+	// compared case-insensitively, while the case of the names of new
+	// files is preserved. It's intended for shares whose file systems are
+	// case-sensitive, and must be set before the share is added to a
+	// share table.
+	CaseInsensitive bool
+
+	namesOnce sync.Once
+	names     *smbcase.Resolver // Resolves names within FS if CaseInsensitive is set
+}
+
+// resolver returns the resolver of names within the file system of the
+// share, or nil if names are case-sensitive.
+func (s *Share) resolver() *smbcase.Resolver {
+	if !s.CaseInsensitive || s.FS == nil {
+		return nil
+	}
+	s.namesOnce.Do(func() {
+		s.names = smbcase.NewResolver(s.FS, 0)
+	})
+	return s.names
+}
+
+// capabilities returns the capabilities of the share.
+func (s *Share) capabilities() smbtree.Capabilities {
+	var caps smbtree.Capabilities
+	if s.Type == smbtree.Disk && continuouslyAvailable(s.FS) {
+		caps |= smbtree.CapContinuousAvailability
+	}
+	return caps
+}
+
+// ShareTable holds the shares of the server, keyed by case-insensitive
+// name. It holds values for the Server.ShareList variable in the SMB
+// protocol. It must be created with NewShareTable.
+type ShareTable struct {
+	mutex  sync.RWMutex
+	shares map[string]*Share
+}
+
+// NewShareTable returns a share table that holds the IPC$ share.
+func NewShareTable() *ShareTable {
+	t := &ShareTable{
+		shares: make(map[string]*Share),
+	}
+	t.Add(&Share{
+		Name:    IPC,
+		Type:    smbtree.Pipe,
+		Comment: "Remote IPC",
+		Flags:   smbtree.NoCaching,
+	})
+	return t
+}
+
+// Add adds s to the table, replacing any share with the same name.
+func (t *ShareTable) Add(s *Share) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.shares[strings.ToLower(s.Name)] = s
+}
+
+// Remove removes the share with the given name from the table. Trees that
+// are connected to the share are unaffected.
+func (t *ShareTable) Remove(name string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.shares, strings.ToLower(name))
+}
+
+// Lookup returns the share with the given name, or nil.
+func (t *ShareTable) Lookup(name string) *Share {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.shares[strings.ToLower(name)]
+}
+
+// List returns the shares in the table, sorted by name.
+func (t *ShareTable) List() []*Share {
+	t.mutex.RLock()
+	shares := make([]*Share, 0, len(t.shares))
+	for _, s := range t.shares {
+		shares = append(shares, s)
+	}
+	t.mutex.RUnlock()
+	sort.Slice(shares, func(i, j int) bool {
+		return strings.ToLower(shares[i].Name) < strings.ToLower(shares[j].Name)
+	})
+	return shares
+}
@@ -0,0 +1,90 @@
+package smbserver
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbsnapshot"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// enumerateSnapshotsMinOutput is the smallest output buffer accepted by
+// FSCTL_SRV_ENUMERATE_SNAPSHOTS.
+const enumerateSnapshotsMinOutput = 16
+
+// enumerateSnapshots processes an FSCTL_SRV_ENUMERATE_SNAPSHOTS request.
+// It returns the @GMT tokens of the snapshots of the file system of the
+// open, newest first. If they don't fit in the output buffer, only the
+// number of snapshots and the size of the array that would hold them are
+// returned.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.15.1
+func (c *Conn) enumerateSnapshots(r *Request, ctl FSCTL) Response {
+	open := c.lookupOpen(r, ctl.FileID)
+	if open == nil {
+		return ioctlError(smbstatus.FileClosed)
+	}
+	if ctl.MaxOutput < enumerateSnapshotsMinOutput {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	fsys, ok := open.FS.(smbfs.Snapshotter)
+	if !ok {
+		return ioctlError(smbstatus.InvalidDeviceRequest)
+	}
+	times, err := fsys.Snapshots()
+	if err != nil {
+		return ioctlError(fileStatus(err))
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].After(times[j]) })
+	tokens := make([]string, len(times))
+	for i, t := range times {
+		tokens[i] = smbsnapshot.FormatToken(t)
+	}
+
+	size := smbsnapshot.ArraySize(tokens)
+	if int64(smbsnapshot.ArrayHeaderSize+size) > int64(ctl.MaxOutput) {
+		output := make([]byte, smbsnapshot.ArrayHeaderSize)
+		array := smbsnapshot.Array(output)
+		array.SetCount(uint32(len(tokens)))
+		array.SetArraySize(uint32(size))
+		return smbproto.IoctlResponse{CtlCode: ctl.Code, FileID: ctl.FileID, Output: output}
+	}
+	output := make([]byte, smbsnapshot.ArrayHeaderSize+size)
+	array := smbsnapshot.Array(output)
+	array.SetCount(uint32(len(tokens)))
+	array.SetTokens(tokens)
+
+	return smbproto.IoctlResponse{CtlCode: ctl.Code, FileID: ctl.FileID, Output: output}
+}
+
+// timewarp returns the snapshot of fsys that is requested by a timewarp
+// token create context, if fsys implements smbfs.Snapshotter. The file is
+// then opened within the snapshot, and files within snapshots are
+// read-only.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.9.6
+func timewarp(fsys smbfs.FileSystem, context smbcreate.Context) (smbfs.FileSystem, smbstatus.Code) {
+	data := context.Data()
+	if len(data) < 8 {
+		return nil, smbstatus.InvalidParameter
+	}
+	snapshotter, ok := fsys.(smbfs.Snapshotter)
+	if !ok {
+		return nil, smbstatus.ObjectNameNotFound
+	}
+	t := smbtype.Time(data[0:8]).UTC().Truncate(time.Second)
+	snapshot, err := snapshotter.Snapshot(t)
+	switch {
+	case errors.Is(err, smbfs.ErrSnapshotNotFound):
+		return nil, smbstatus.ObjectNameNotFound
+	case err != nil:
+		return nil, fileStatus(err)
+	}
+	return snapshot, smbstatus.Success
+}
@@ -0,0 +1,75 @@
+package smbserver_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbsnapshot"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// timewarpContext returns a create context list holding a timewarp token
+// for the snapshot taken at t.
+func timewarpContext(t time.Time) []byte {
+	data := make([]byte, 8)
+	smbtype.PutTime(data, t)
+	return smbcreate.AppendContext(nil, smbcreate.TimewarpToken, data)
+}
+
+func TestSnapshots(t *testing.T) {
+	ct := newCreateTest(t)
+	ct.fs.SetSnapshotDir(".snapshots", "")
+	older := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	newer := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	files := map[string]string{
+		"file.txt": "current",
+		".snapshots/@GMT-2024.01.02-03.04.05/file.txt": "old",
+		".snapshots/@GMT-2024.02.01-00.00.00/file.txt": "newer",
+		".snapshots/unrelated/file.txt":                "ignored",
+	}
+	for name, data := range files {
+		p := filepath.Join(ct.root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := ct.create("open root", 1, createSpec{Name: "", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Options: smbcreate.DirectoryFile}, smbstatus.Success)
+	array := smbsnapshot.Array(ct.fsctl("enumerate snapshots", smbioctl.SrvEnumerateSnapshots, root, nil, 1024, smbstatus.Success))
+	want := []string{smbsnapshot.FormatToken(newer), smbsnapshot.FormatToken(older)}
+	if tokens := array.Tokens(); !array.Valid() || array.Count() != 2 || len(tokens) != 2 || tokens[0] != want[0] || tokens[1] != want[1] {
+		t.Errorf("enumerate snapshots returned %q (want %q)", tokens, want)
+	}
+
+	array = smbsnapshot.Array(ct.fsctl("enumerate snapshots with small buffer", smbioctl.SrvEnumerateSnapshots, root, nil, 16, smbstatus.Success))
+	if !array.Valid() || array.Count() != 2 || array.Returned() != 0 || int(array.ArraySize()) != smbsnapshot.ArraySize(want) {
+		t.Errorf("enumerate snapshots with small buffer returned count %d, returned %d, size %d", array.Count(), array.Returned(), array.ArraySize())
+	}
+	ct.fsctl("enumerate snapshots with tiny buffer", smbioctl.SrvEnumerateSnapshots, root, nil, 8, smbstatus.InvalidParameter)
+
+	for _, test := range []struct {
+		Time time.Time
+		Data string
+	}{{older, "old"}, {newer, "newer"}} {
+		packet := ct.send(1, smbcommand.Create, createBody(createSpec{Name: "file.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Contexts: timewarpContext(test.Time)}))
+		response := ct.checkCreate("open of "+smbsnapshot.FormatToken(test.Time), packet, smbstatus.Success)
+		if size := response.EndOfFile(); size != uint64(len(test.Data)) {
+			t.Errorf("open of %s returned a file of %d bytes (want %d)", smbsnapshot.FormatToken(test.Time), size, len(test.Data))
+		}
+		ct.close(1, response.FileID())
+	}
+
+	ct.create("write open in snapshot", 1, createSpec{Name: "file.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open, Contexts: timewarpContext(older)}, smbstatus.AccessDenied)
+	ct.create("create in snapshot", 1, createSpec{Name: "new.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Create, Contexts: timewarpContext(older)}, smbstatus.AccessDenied)
+	ct.create("open in missing snapshot", 1, createSpec{Name: "file.txt", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open, Contexts: timewarpContext(older.Add(time.Hour))}, smbstatus.ObjectNameNotFound)
+}
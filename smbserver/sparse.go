@@ -0,0 +1,211 @@
+package smbserver
+
+import (
+	"errors"
+	"math"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbsparse"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// zeroBufferSize is the size of the buffer of zeros written by the server
+// when the file system of a file can't zero a range itself.
+const zeroBufferSize = 64 << 10
+
+// sparse returns true if the file of o is sparse.
+func (o *Open) sparse() bool {
+	fsys, ok := o.FS.(smbfs.SparseFiles)
+	if !ok || o.File == nil {
+		return false
+	}
+	sparse, err := fsys.Sparse(o.File)
+	return err == nil && sparse
+}
+
+// lookupFileOpen returns the open of a file system control request, or an
+// error response if the request doesn't refer to an open file that holds
+// the required access.
+func (c *Conn) lookupFileOpen(r *Request, ctl FSCTL, access smbaccess.Mask) (*Open, Response) {
+	open := c.lookupOpen(r, ctl.FileID)
+	if open == nil {
+		return nil, ioctlError(smbstatus.FileClosed)
+	}
+	if open.Directory || open.File == nil {
+		return nil, ioctlError(smbstatus.InvalidDeviceRequest)
+	}
+	if !open.GrantedAccess.Any(access) {
+		return nil, ioctlError(smbstatus.AccessDenied)
+	}
+	return open, nil
+}
+
+// setSparse processes an FSCTL_SET_SPARSE request, which determines
+// whether a file is sparse. Files that stop being sparse have their
+// unallocated ranges allocated.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.3.64
+func (c *Conn) setSparse(r *Request, ctl FSCTL) Response {
+	open, failed := c.lookupFileOpen(r, ctl, smbaccess.WriteData|smbaccess.WriteAttributes|smbaccess.AppendData)
+	if failed != nil {
+		return failed
+	}
+	fsys, ok := open.FS.(smbfs.SparseFiles)
+	if !ok {
+		return ioctlError(smbstatus.InvalidDeviceRequest)
+	}
+
+	err := fsys.SetSparse(open.File, smbsparse.SetSparseRequest(ctl.Input).Sparse())
+	switch {
+	case errors.Is(err, smbfs.ErrSparseNotSupported):
+		return ioctlError(smbstatus.InvalidDeviceRequest)
+	case err != nil:
+		return ioctlError(fileStatus(err))
+	}
+
+	return smbproto.IoctlResponse{CtlCode: ctl.Code, FileID: ctl.FileID}
+}
+
+// setZeroData processes an FSCTL_SET_ZERO_DATA request, which sets a range
+// of a file to zero. The range is deallocated if the file is sparse. If the
+// file system of the file can't zero the range, the server writes zeros to
+// the part of the range that lies within the file.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.3.67
+func (c *Conn) setZeroData(r *Request, ctl FSCTL) Response {
+	open, failed := c.lookupFileOpen(r, ctl, smbaccess.WriteData)
+	if failed != nil {
+		return failed
+	}
+	request := smbsparse.ZeroDataRequest(ctl.Input)
+	if !request.Valid() {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	offset, end := request.FileOffset(), request.BeyondFinalZero()
+	if offset < 0 || end < offset {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	if offset == end {
+		return smbproto.IoctlResponse{CtlCode: ctl.Code, FileID: ctl.FileID}
+	}
+
+	if !open.checkLock(uint64(offset), uint64(end-offset), true) {
+		return ioctlError(smbstatus.FileLockConflict)
+	}
+	open.file.breakReadCaching(open, c.oplockBreakTimeout())
+
+	if err := zeroRange(open, offset, end-offset); err != nil {
+		return ioctlError(fileStatus(err))
+	}
+
+	return smbproto.IoctlResponse{CtlCode: ctl.Code, FileID: ctl.FileID}
+}
+
+// zeroRange sets length bytes of the file of o starting at offset to zero
+// without changing its size.
+func zeroRange(o *Open, offset, length int64) error {
+	if fsys, ok := o.FS.(smbfs.SparseFiles); ok {
+		err := fsys.ZeroRange(o.File, offset, length)
+		if !errors.Is(err, smbfs.ErrSparseNotSupported) {
+			return err
+		}
+	}
+
+	info, err := o.File.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	if length < end-offset {
+		end = offset + length
+	}
+	zeros := make([]byte, zeroBufferSize)
+	for pos := offset; pos < end; {
+		b := zeros
+		if remaining := end - pos; remaining < int64(len(b)) {
+			b = b[:remaining]
+		}
+		n, err := o.File.WriteAt(b, pos)
+		if err != nil {
+			return err
+		}
+		pos += int64(n)
+	}
+	return nil
+}
+
+// queryAllocatedRanges processes an FSCTL_QUERY_ALLOCATED_RANGES request,
+// which returns the allocated ranges within a range of a file. If they
+// don't fit in the output buffer, as many as fit are returned with
+// STATUS_BUFFER_OVERFLOW. Files within file systems that don't support
+// sparse files are fully allocated.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.3.46
+func (c *Conn) queryAllocatedRanges(r *Request, ctl FSCTL) Response {
+	open, failed := c.lookupFileOpen(r, ctl, smbaccess.ReadData)
+	if failed != nil {
+		return failed
+	}
+	request := smbsparse.Range(ctl.Input)
+	if !request.Valid() {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	offset, length := request.FileOffset(), request.Length()
+	if offset < 0 || length < 0 || length > math.MaxInt64-offset {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	if ctl.MaxOutput < smbsparse.RangeSize {
+		return ioctlError(smbstatus.BufferTooSmall)
+	}
+
+	ranges, err := allocatedRanges(open, offset, length)
+	if err != nil {
+		return ioctlError(fileStatus(err))
+	}
+
+	code := smbstatus.Code(smbstatus.Success)
+	if max := int(ctl.MaxOutput / smbsparse.RangeSize); len(ranges) > max {
+		ranges = ranges[:max]
+		code = smbstatus.BufferOverflow
+	}
+	output := make([]byte, len(ranges)*smbsparse.RangeSize)
+	list := smbsparse.RangeList(output)
+	for i, rng := range ranges {
+		entry := list.Range(i)
+		entry.SetFileOffset(rng.Offset)
+		entry.SetLength(rng.Length)
+	}
+
+	return smbproto.IoctlResponse{
+		Code:    code,
+		CtlCode: ctl.Code,
+		FileID:  ctl.FileID,
+		Output:  output,
+	}
+}
+
+// allocatedRanges returns the allocated ranges of the file of o that fall
+// within length bytes starting at offset.
+func allocatedRanges(o *Open, offset, length int64) ([]smbfs.Range, error) {
+	if fsys, ok := o.FS.(smbfs.SparseFiles); ok {
+		ranges, err := fsys.AllocatedRanges(o.File, offset, length)
+		if !errors.Is(err, smbfs.ErrSparseNotSupported) {
+			return ranges, err
+		}
+	}
+
+	info, err := o.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	end := info.Size()
+	if length < end-offset {
+		end = offset + length
+	}
+	if end <= offset {
+		return nil, nil
+	}
+	return []smbfs.Range{{Offset: offset, Length: end - offset}}, nil
+}
@@ -0,0 +1,115 @@
+package smbserver_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbsparse"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// fsctl sends a file system control request and checks its status. It
+// returns the output of the response.
+func (ct *createTest) fsctl(desc string, code smbioctl.Code, id smbfile.ID, input []byte, maxOutput uint32, status smbstatus.Code) []byte {
+	ct.t.Helper()
+	packet := ct.send(1, smbcommand.IOCTL, ioctlBody(code, id, input, maxOutput))
+	if s := packet.Header().Status(); s != status {
+		ct.t.Fatalf("%s: returned %s (want %s)", desc, s, status)
+	}
+	response := smbioctl.Response(packet.Data())
+	if !response.Valid() {
+		return nil
+	}
+	return response.Output()
+}
+
+// sparseAttribute opens the named file and returns true if the create
+// response reports it as sparse.
+func (ct *createTest) sparseAttribute(name string) bool {
+	ct.t.Helper()
+	packet := ct.send(1, smbcommand.Create, createBody(createSpec{Name: name, Access: smbaccess.ReadAttributes, Share: shareAll, Disposition: smbcreate.Open}))
+	response := ct.checkCreate("open "+name, packet, smbstatus.Success)
+	ct.close(1, response.FileID())
+	return response.FileAttributes().Match(smbfile.SparseFile)
+}
+
+// allocatedRanges queries the allocated ranges of a file.
+func (ct *createTest) allocatedRanges(id smbfile.ID, offset, length int64, maxOutput uint32, status smbstatus.Code) (ranges []smbfs.Range) {
+	ct.t.Helper()
+	input := make([]byte, smbsparse.RangeSize)
+	smbsparse.Range(input).SetFileOffset(offset)
+	smbsparse.Range(input).SetLength(length)
+	list := smbsparse.RangeList(ct.fsctl("query allocated ranges", smbioctl.QueryAllocatedRanges, id, input, maxOutput, status))
+	for i := 0; i < list.Count(); i++ {
+		ranges = append(ranges, smbfs.Range{Offset: list.Range(i).FileOffset(), Length: list.Range(i).Length()})
+	}
+	return ranges
+}
+
+func TestSparseFiles(t *testing.T) {
+	ct := newCreateTest(t)
+	const size = 256 << 10
+	data := bytes.Repeat([]byte{0xAB}, size)
+	if err := os.WriteFile(filepath.Join(ct.root, "disk.img"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ct.sparseAttribute("disk.img") {
+		t.Fatal("fully allocated file is reported as sparse")
+	}
+
+	id := ct.create("open", 1, createSpec{Name: "disk.img", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+	reader := ct.create("open for reading", 1, createSpec{Name: "disk.img", Access: smbaccess.ReadData, Share: shareAll, Disposition: smbcreate.Open}, smbstatus.Success)
+	ct.fsctl("set sparse without access", smbioctl.SetSparse, reader, nil, 0, smbstatus.AccessDenied)
+	ct.fsctl("set sparse", smbioctl.SetSparse, id, nil, 0, smbstatus.Success)
+	if !ct.sparseAttribute("disk.img") {
+		t.Error("file is not reported as sparse after it was made sparse")
+	}
+
+	zero := make([]byte, smbsparse.ZeroDataRequestSize)
+	smbsparse.ZeroDataRequest(zero).SetFileOffset(64 << 10)
+	smbsparse.ZeroDataRequest(zero).SetBeyondFinalZero(128 << 10)
+	ct.fsctl("set zero data without access", smbioctl.SetZeroData, reader, zero, 0, smbstatus.AccessDenied)
+	ct.fsctl("set zero data", smbioctl.SetZeroData, id, zero, 0, smbstatus.Success)
+
+	b, err := os.ReadFile(filepath.Join(ct.root, "disk.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(data[64<<10:128<<10], make([]byte, 64<<10))
+	if !bytes.Equal(b, data) {
+		t.Error("set zero data didn't zero the range or changed the size of the file")
+	}
+
+	ranges := ct.allocatedRanges(reader, 0, size, 1024, smbstatus.Success)
+	want := []smbfs.Range{{Offset: 0, Length: 64 << 10}, {Offset: 128 << 10, Length: 128 << 10}}
+	if len(ranges) != len(want) || ranges[0] != want[0] || ranges[1] != want[1] {
+		t.Errorf("query returned %v (want %v)", ranges, want)
+	}
+	ranges = ct.allocatedRanges(reader, 0, size, smbsparse.RangeSize, smbstatus.BufferOverflow)
+	if len(ranges) != 1 || ranges[0] != want[0] {
+		t.Errorf("query with small buffer returned %v (want %v)", ranges, want[:1])
+	}
+	ranges = ct.allocatedRanges(reader, 96<<10, 64<<10, 1024, smbstatus.Success)
+	if len(ranges) != 1 || ranges[0] != (smbfs.Range{Offset: 128 << 10, Length: 32 << 10}) {
+		t.Errorf("query of partial range returned %v", ranges)
+	}
+	ct.allocatedRanges(reader, 0, size, smbsparse.RangeSize-1, smbstatus.BufferTooSmall)
+
+	notSparse := []byte{0}
+	ct.fsctl("clear sparse", smbioctl.SetSparse, id, notSparse, 0, smbstatus.Success)
+	if ct.sparseAttribute("disk.img") {
+		t.Error("file is reported as sparse after it was made not sparse")
+	}
+	ranges = ct.allocatedRanges(reader, 0, size, 1024, smbstatus.Success)
+	if len(ranges) != 1 || ranges[0] != (smbfs.Range{Offset: 0, Length: size}) {
+		t.Errorf("query after clearing sparse returned %v", ranges)
+	}
+}
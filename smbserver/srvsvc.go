@@ -0,0 +1,178 @@
+package smbserver
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gentlemanautomaton/smb/smbrpc"
+	"github.com/gentlemanautomaton/smb/smbsrvsvc"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+// ServerService is an RPC interface that implements the server service
+// remote protocol (SRVSVC). It is served over the srvsvc named pipe with
+// RPCPipe, and lets clients such as "net view" and Explorer list the
+// shares of the connection's share table.
+//
+// NetrShareEnum and NetrShareGetInfo are supported at levels 0, 1, 2, 501
+// and 502, and NetrServerGetInfo at levels 100 and 101. Other operations
+// fail with nca_op_rng_error.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4
+type ServerService struct {
+	Name    string // The name of the server; defaults to the host name
+	Comment string // A description of the server
+}
+
+// Syntax returns the abstract syntax of the srvsvc interface.
+func (s ServerService) Syntax() smbrpc.SyntaxID {
+	return smbsrvsvc.Syntax
+}
+
+// Call invokes a srvsvc operation.
+func (s ServerService) Call(c *Conn, opnum uint16, stub []byte) ([]byte, smbrpc.FaultStatus) {
+	switch opnum {
+	case smbsrvsvc.NetrShareEnum:
+		var request smbsrvsvc.ShareEnumRequest
+		if request.Unmarshal(stub) != nil {
+			return nil, smbrpc.BadStubData
+		}
+		response := s.shareEnum(c, &request)
+		return response.Marshal(), 0
+	case smbsrvsvc.NetrShareGetInfo:
+		var request smbsrvsvc.ShareGetInfoRequest
+		if request.Unmarshal(stub) != nil {
+			return nil, smbrpc.BadStubData
+		}
+		response := s.shareGetInfo(c, &request)
+		return response.Marshal(), 0
+	case smbsrvsvc.NetrServerGetInfo:
+		var request smbsrvsvc.ServerGetInfoRequest
+		if request.Unmarshal(stub) != nil {
+			return nil, smbrpc.BadStubData
+		}
+		response := s.serverGetInfo(&request)
+		return response.Marshal(), 0
+	default:
+		return nil, smbrpc.OpRangeError
+	}
+}
+
+// shareEnum lists the shares of the server. All of the shares after the
+// resume handle are returned in a single response, regardless of the
+// preferred maximum length.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4.8
+func (s ServerService) shareEnum(c *Conn, request *smbsrvsvc.ShareEnumRequest) *smbsrvsvc.ShareEnumResponse {
+	response := &smbsrvsvc.ShareEnumResponse{Level: request.Level}
+	if request.ResumeHandle != nil {
+		var resume uint32
+		response.ResumeHandle = &resume
+	}
+	if !smbsrvsvc.ShareLevelSupported(request.Level) {
+		response.Result = smbsrvsvc.InvalidLevel
+		return response
+	}
+
+	var shares []*Share
+	if c.Shares != nil {
+		shares = c.Shares.List()
+	}
+	response.TotalEntries = uint32(len(shares))
+	if request.ResumeHandle != nil {
+		if start := *request.ResumeHandle; start < uint32(len(shares)) {
+			shares = shares[start:]
+		} else {
+			shares = nil
+		}
+	}
+	response.Shares = make([]smbsrvsvc.ShareInfo, len(shares))
+	for i, share := range shares {
+		response.Shares[i] = shareInfo(c, share)
+	}
+	return response
+}
+
+// shareGetInfo describes a single share.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4.10
+func (s ServerService) shareGetInfo(c *Conn, request *smbsrvsvc.ShareGetInfoRequest) *smbsrvsvc.ShareGetInfoResponse {
+	response := &smbsrvsvc.ShareGetInfoResponse{Level: request.Level}
+	if !smbsrvsvc.ShareLevelSupported(request.Level) {
+		response.Result = smbsrvsvc.InvalidLevel
+		return response
+	}
+	var share *Share
+	if c.Shares != nil {
+		share = c.Shares.Lookup(request.NetName)
+	}
+	if share == nil {
+		response.Result = smbsrvsvc.NetNameNotFound
+		return response
+	}
+	info := shareInfo(c, share)
+	response.Share = &info
+	return response
+}
+
+// serverGetInfo describes the server.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4.17
+func (s ServerService) serverGetInfo(request *smbsrvsvc.ServerGetInfoRequest) *smbsrvsvc.ServerGetInfoResponse {
+	response := &smbsrvsvc.ServerGetInfoResponse{Level: request.Level}
+	if !smbsrvsvc.ServerLevelSupported(request.Level) {
+		response.Result = smbsrvsvc.InvalidLevel
+		return response
+	}
+	response.Server = &smbsrvsvc.ServerInfo{
+		PlatformID:   smbsrvsvc.PlatformNT,
+		Name:         s.name(),
+		VersionMajor: 10,
+		VersionMinor: 0,
+		Type:         smbsrvsvc.Workstation | smbsrvsvc.Server | smbsrvsvc.NT | smbsrvsvc.ServerNT,
+		Comment:      s.Comment,
+	}
+	return response
+}
+
+// name returns the name of the server, which is the first label of the
+// host name in upper case if s.Name is empty.
+func (s ServerService) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	if i := strings.IndexByte(host, '.'); i >= 0 {
+		host = host[:i]
+	}
+	return strings.ToUpper(host)
+}
+
+// shareInfo returns a description of share for share enumeration. Shares
+// don't limit their number of connections and don't report a local path.
+func shareInfo(c *Conn, share *Share) smbsrvsvc.ShareInfo {
+	info := smbsrvsvc.ShareInfo{
+		Name:    share.Name,
+		Remark:  share.Comment,
+		MaxUses: smbsrvsvc.UnlimitedUses,
+		Flags:   uint32(share.Flags), // Most SMB2 share flags match SHI1005 flags
+	}
+	switch share.Type {
+	case smbtree.Pipe:
+		info.Type = smbsrvsvc.IPC
+	case smbtree.Print:
+		info.Type = smbsrvsvc.PrintQueue
+	default:
+		info.Type = smbsrvsvc.DiskTree
+	}
+	if strings.EqualFold(share.Name, IPC) {
+		info.Type |= smbsrvsvc.Special
+	}
+	if c.Trees != nil {
+		info.CurrentUses = uint32(c.Trees.uses(share))
+	}
+	return info
+}
@@ -0,0 +1,167 @@
+package smbserver_test
+
+import (
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+	"github.com/gentlemanautomaton/smb/smbrpc"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbsrvsvc"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+// srvsvcTest holds an open of the srvsvc pipe that is bound to the srvsvc
+// interface.
+type srvsvcTest struct {
+	*treeTest
+	id     smbfile.ID
+	callID uint32
+}
+
+func newSrvsvcTest(t *testing.T) *srvsvcTest {
+	tt := newTreeTest(t)
+	tt.conn.Pipes.Register("srvsvc", smbserver.RPCPipe(smbserver.ServerService{Name: "TESTSERVER", Comment: "Test server"}))
+	tt.conn.Shares.Add(&smbserver.Share{
+		Name:    "Data",
+		Type:    smbtree.Disk,
+		Comment: "Shared data",
+		FS:      smbosfs.New(t.TempDir()),
+		Flags:   smbtree.AutoCaching,
+	})
+	tt.connect(`\\server\IPC$`, smbstatus.Success)
+	st := &srvsvcTest{treeTest: tt, id: tt.openPipe("srvsvc"), callID: 1}
+
+	contexts := []smbrpc.Context{smbrpc.NewContext(0, smbsrvsvc.Syntax, smbrpc.NDR)}
+	bind := smbrpc.Bind(make([]byte, smbrpc.BindBufferSize(contexts...)))
+	bind.Header().Init(smbrpc.TypeBind, smbrpc.FirstFrag|smbrpc.LastFrag, uint16(len(bind)), st.callID)
+	bind.SetMaxXmitFrag(4280)
+	bind.SetMaxRecvFrag(4280)
+	bind.SetContexts(contexts...)
+	ack := smbrpc.BindAck(tt.fsctl("bind", smbioctl.PipeTransceive, st.id, bind, 4280, smbstatus.Success))
+	if !ack.Valid() || ack.ResultCount() != 1 || ack.Result(0).Result != smbrpc.Acceptance {
+		t.Fatal("bind to srvsvc: the context was not accepted")
+	}
+	return st
+}
+
+// call invokes a srvsvc operation and returns its output stub.
+func (st *srvsvcTest) call(desc string, opnum uint16, stub []byte) []byte {
+	st.t.Helper()
+	st.callID++
+	reply := st.fsctl(desc, smbioctl.PipeTransceive, st.id, rpcRequest(st.callID, 0, opnum, smbrpc.FirstFrag|smbrpc.LastFrag, stub), 4280, smbstatus.Success)
+	response := smbrpc.Response(reply)
+	if !response.Valid() || response.Header().Type() != smbrpc.TypeResponse || !response.Header().Flags().Match(smbrpc.LastFrag) {
+		st.t.Fatalf("%s: unexpected reply %v", desc, reply)
+	}
+	return response.Stub()
+}
+
+func (st *srvsvcTest) shareEnum(level uint32) smbsrvsvc.ShareEnumResponse {
+	st.t.Helper()
+	request := smbsrvsvc.ShareEnumRequest{ServerName: `\\TESTSERVER`, Level: level, PreferredMaximumLength: 0xFFFFFFFF}
+	var response smbsrvsvc.ShareEnumResponse
+	if err := response.Unmarshal(st.call("NetrShareEnum", smbsrvsvc.NetrShareEnum, request.Marshal())); err != nil {
+		st.t.Fatalf("NetrShareEnum at level %d: %v", level, err)
+	}
+	return response
+}
+
+func (st *srvsvcTest) shareGetInfo(name string, level uint32) smbsrvsvc.ShareGetInfoResponse {
+	st.t.Helper()
+	request := smbsrvsvc.ShareGetInfoRequest{NetName: name, Level: level}
+	var response smbsrvsvc.ShareGetInfoResponse
+	if err := response.Unmarshal(st.call("NetrShareGetInfo", smbsrvsvc.NetrShareGetInfo, request.Marshal())); err != nil {
+		st.t.Fatalf("NetrShareGetInfo of %s at level %d: %v", name, level, err)
+	}
+	return response
+}
+
+func TestServerServiceShareEnum(t *testing.T) {
+	st := newSrvsvcTest(t)
+
+	for _, level := range []uint32{0, 1, 2, 501, 502} {
+		response := st.shareEnum(level)
+		if response.Result != smbsrvsvc.Success || response.Level != level {
+			t.Fatalf("level %d: returned %s at level %d", level, response.Result, response.Level)
+		}
+		if len(response.Shares) != 2 || response.TotalEntries != 2 {
+			t.Fatalf("level %d: %d shares of %d (want 2)", level, len(response.Shares), response.TotalEntries)
+		}
+		data, ipc := response.Shares[0], response.Shares[1]
+		if data.Name != "Data" || ipc.Name != "IPC$" {
+			t.Fatalf("level %d: shares are %q and %q", level, data.Name, ipc.Name)
+		}
+		if level == 0 {
+			continue
+		}
+		if data.Type != smbsrvsvc.DiskTree || data.Remark != "Shared data" {
+			t.Fatalf("level %d: Data share has type %#x and remark %q", level, data.Type, data.Remark)
+		}
+		if ipc.Type != smbsrvsvc.IPC|smbsrvsvc.Special || ipc.Remark != "Remote IPC" {
+			t.Fatalf("level %d: IPC$ share has type %#x and remark %q", level, ipc.Type, ipc.Remark)
+		}
+		switch level {
+		case 2, 502:
+			if data.CurrentUses != 0 || ipc.CurrentUses != 1 || ipc.MaxUses != smbsrvsvc.UnlimitedUses {
+				t.Fatalf("level %d: current uses are %d and %d", level, data.CurrentUses, ipc.CurrentUses)
+			}
+		case 501:
+			if data.Flags != smbtree.AutoCaching {
+				t.Fatalf("level %d: Data share has flags %#x", level, data.Flags)
+			}
+		}
+	}
+
+	if response := st.shareEnum(7); response.Result != smbsrvsvc.InvalidLevel {
+		t.Fatalf("level 7: returned %s (want %s)", response.Result, smbsrvsvc.Status(smbsrvsvc.InvalidLevel))
+	}
+
+	// Enumeration resumes after the shares that have been returned
+	resume := uint32(1)
+	request := smbsrvsvc.ShareEnumRequest{Level: 1, PreferredMaximumLength: 0xFFFFFFFF, ResumeHandle: &resume}
+	var response smbsrvsvc.ShareEnumResponse
+	if err := response.Unmarshal(st.call("resumed NetrShareEnum", smbsrvsvc.NetrShareEnum, request.Marshal())); err != nil {
+		t.Fatalf("resumed NetrShareEnum: %v", err)
+	}
+	if len(response.Shares) != 1 || response.Shares[0].Name != "IPC$" || response.ResumeHandle == nil {
+		t.Fatalf("resumed NetrShareEnum: %d shares, resume handle %v", len(response.Shares), response.ResumeHandle)
+	}
+}
+
+func TestServerServiceGetInfo(t *testing.T) {
+	st := newSrvsvcTest(t)
+
+	share := st.shareGetInfo("data", 2)
+	if share.Result != smbsrvsvc.Success || share.Share == nil {
+		t.Fatalf("NetrShareGetInfo of data: returned %s", share.Result)
+	}
+	if share.Share.Name != "Data" || share.Share.Remark != "Shared data" || share.Share.MaxUses != smbsrvsvc.UnlimitedUses {
+		t.Fatalf("NetrShareGetInfo of data: unexpected info %+v", *share.Share)
+	}
+	if share = st.shareGetInfo("missing", 1); share.Result != smbsrvsvc.NetNameNotFound || share.Share != nil {
+		t.Fatalf("NetrShareGetInfo of missing: returned %s", share.Result)
+	}
+	if share = st.shareGetInfo("data", 1005); share.Result != smbsrvsvc.InvalidLevel {
+		t.Fatalf("NetrShareGetInfo at level 1005: returned %s", share.Result)
+	}
+
+	request := smbsrvsvc.ServerGetInfoRequest{ServerName: `\\TESTSERVER`, Level: 101}
+	var server smbsrvsvc.ServerGetInfoResponse
+	if err := server.Unmarshal(st.call("NetrServerGetInfo", smbsrvsvc.NetrServerGetInfo, request.Marshal())); err != nil {
+		t.Fatalf("NetrServerGetInfo: %v", err)
+	}
+	if server.Result != smbsrvsvc.Success || server.Server == nil {
+		t.Fatalf("NetrServerGetInfo: returned %s", server.Result)
+	}
+	if info := server.Server; info.PlatformID != smbsrvsvc.PlatformNT || info.Name != "TESTSERVER" || info.Comment != "Test server" || !info.Type.Match(smbsrvsvc.Server) {
+		t.Fatalf("NetrServerGetInfo: unexpected info %+v", *info)
+	}
+
+	// Operations that aren't supported fail with a fault
+	st.callID++
+	reply := st.fsctl("unsupported operation", smbioctl.PipeTransceive, st.id, rpcRequest(st.callID, 0, 1, smbrpc.FirstFrag|smbrpc.LastFrag, nil), 4280, smbstatus.Success)
+	checkFault(t, "unsupported operation", reply, smbrpc.OpRangeError)
+}
@@ -0,0 +1,28 @@
+package smbserver
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+)
+
+// fileStatus returns the status code that best describes err, which was
+// returned by a file system.
+func fileStatus(err error) smbstatus.Code {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return smbstatus.ObjectNameNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return smbstatus.AccessDenied
+	case errors.Is(err, fs.ErrClosed):
+		return smbstatus.FileClosed
+	case errors.Is(err, smbfs.ErrStreamTooLarge):
+		return smbstatus.DiskFull
+	case errors.Is(err, smbfs.ErrStreamsNotSupported):
+		return smbstatus.NotSupported
+	default:
+		return smbstatus.Unsuccessful
+	}
+}
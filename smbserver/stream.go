@@ -0,0 +1,126 @@
+package smbserver
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/gentlemanautomaton/smb/smbcase"
+	"github.com/gentlemanautomaton/smb/smbfs"
+	"github.com/gentlemanautomaton/smb/smbinfo"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbstream"
+)
+
+// findStream returns information about the named stream of the named file
+// within streamer. If insensitive is true, stream names are compared
+// case-insensitively and the name of the existing stream is returned.
+func findStream(streamer smbfs.Streamer, name, stream string, insensitive bool) (string, os.FileInfo, error) {
+	infos, err := streamer.Streams(name)
+	if err != nil {
+		return stream, nil, err
+	}
+	for _, info := range infos {
+		if info.Name() == stream {
+			return stream, info, nil
+		}
+	}
+	if insensitive {
+		for _, info := range infos {
+			if smbcase.Equal(info.Name(), stream) {
+				return info.Name(), info, nil
+			}
+		}
+	}
+	return stream, nil, fs.ErrNotExist
+}
+
+// openStream opens the named stream of the file described by p with the
+// given flags. If the file doesn't exist it is created first, and removed
+// again if the stream can't be opened.
+//
+// Streams are named file:stream:$DATA in create requests. Opens of streams
+// are granted access according to the security of their file, and are
+// never made durable.
+func openStream(streamer smbfs.Streamer, p *createParams, stream string, fileExists bool, flag int) (smbfs.File, error) {
+	if !fileExists {
+		perm := os.FileMode(0666)
+		if p.perm != nil {
+			perm = *p.perm
+		}
+		file, err := p.fsys.OpenFile(p.name, os.O_RDONLY|os.O_CREATE, perm)
+		if err != nil {
+			return nil, err
+		}
+		file.Close()
+	}
+	file, err := streamer.OpenStream(p.name, stream, flag)
+	if err != nil && !fileExists {
+		p.fsys.Remove(p.name)
+	}
+	return file, err
+}
+
+// queryStreams returns the FILE_STREAM_INFORMATION of the file of open,
+// which lists the default stream of files and the alternate data streams
+// of files and directories. Entries that don't fit in the output buffer
+// are left out, and the query returns STATUS_BUFFER_OVERFLOW.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.4.49
+func (c *Conn) queryStreams(open *Open, request smbinfo.QueryRequest) Response {
+	if open.pipe != nil {
+		return queryInfoError(smbstatus.NotSupported)
+	}
+	if request.OutputBufferLength() < smbstream.InfoHeaderSize {
+		return queryInfoError(smbstatus.InfoLengthMismatch)
+	}
+
+	info, err := open.stat()
+	if err == nil && open.Stream != "" {
+		info, err = open.FS.Stat(open.Name)
+	}
+	if err != nil {
+		return queryInfoError(fileStatus(err))
+	}
+
+	var streams []os.FileInfo
+	if streamer, ok := open.FS.(smbfs.Streamer); ok && !isSymlink(info) {
+		if streams, err = streamer.Streams(open.Name); err != nil {
+			return queryInfoError(fileStatus(err))
+		}
+	}
+	var (
+		entries []os.FileInfo
+		names   []string
+	)
+	if !info.IsDir() {
+		entries, names = append(entries, info), append(names, smbstream.DefaultName)
+	}
+	for _, stream := range streams {
+		entries, names = append(entries, stream), append(names, smbstream.Name(stream.Name()))
+	}
+
+	var output []byte
+	code := smbstatus.Code(smbstatus.Success)
+	last := -1 // The offset of the last entry in output
+	for i, stream := range entries {
+		size := smbstream.InfoSize(names[i])
+		start := align8(len(output))
+		if start+size > int(request.OutputBufferLength()) {
+			code = smbstatus.BufferOverflow
+			break
+		}
+
+		output = append(output, make([]byte, start+size-len(output))...)
+		if last >= 0 {
+			smbstream.Info(output[last:]).SetNextEntryOffset(uint32(start - last))
+		}
+		entry := smbstream.Info(output[start:])
+		entry.SetStreamSize(int64(endOfFile(stream)))
+		entry.SetStreamAllocationSize(int64(allocationSize(stream)))
+		entry.SetStreamName(names[i])
+		last = start
+	}
+
+	return smbproto.QueryInfoResponse{Code: code, Output: output}
+}
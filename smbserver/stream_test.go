@@ -0,0 +1,126 @@
+package smbserver_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbinfo"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbstream"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+// queryStreams queries the stream information of a file and returns the
+// names and sizes of its streams.
+func (tt *treeTest) queryStreams(desc string, id smbfile.ID, length uint32, status smbstatus.Code) map[string]int64 {
+	tt.t.Helper()
+	body := make([]byte, smbinfo.QueryRequestSize+1)
+	request := smbinfo.QueryRequest(body)
+	request.SetSize(41)
+	request.SetInfoType(smbinfo.File)
+	request.SetFileInfoClass(smbstream.InfoClass)
+	request.SetOutputBufferLength(length)
+	request.SetFileID(id)
+	packet := tt.send(smbcommand.QueryInfo, body)
+	tt.check(desc, packet, status)
+	if status.Failure() {
+		return nil
+	}
+	list := smbstream.InfoList(smbinfo.QueryResponse(packet.Data()).Output())
+	if !list.Valid() {
+		tt.t.Fatalf("%s: invalid stream information", desc)
+	}
+	streams := make(map[string]int64)
+	for offset := uint32(0); len(list) > 0; {
+		entry := list.Member(offset)
+		streams[entry.StreamName()] = entry.StreamSize()
+		if offset = list.Next(offset); offset == 0 {
+			break
+		}
+	}
+	return streams
+}
+
+func TestAlternateDataStreams(t *testing.T) {
+	tt := newTreeTest(t)
+	dir := t.TempDir()
+	fsys := smbosfs.New(dir)
+	fsys.SetStreamDir(t.TempDir())
+	tt.conn.Shares.Add(&smbserver.Share{Name: "Data", Type: smbtree.Disk, FS: fsys})
+	tt.connect(`\\server\Data`, smbstatus.Success)
+
+	create := func(desc, name string, access smbaccess.Mask, disposition smbcreate.Disposition, options smbcreate.Options, status smbstatus.Code) smbfile.ID {
+		t.Helper()
+		spec := createSpec{Name: name, Access: access, Share: shareAll, Disposition: disposition, Options: options}
+		packet := tt.send(smbcommand.Create, createBody(spec))
+		tt.check(desc, packet, status)
+		if status != smbstatus.Success {
+			return smbfile.ID{}
+		}
+		return smbcreate.Response(packet.Data()).FileID()
+	}
+	write := func(id smbfile.ID, data string) {
+		t.Helper()
+		tt.check("write", tt.send(smbcommand.Write, writeBody(id, data)), smbstatus.Success)
+	}
+
+	// Downloads are written and then marked with their zone
+	file := create("create of a file", "setup.exe", readWrite, smbcreate.Create, 0, smbstatus.Success)
+	write(file, "program")
+	zone := create("create of a stream", `setup.exe:Zone.Identifier:$DATA`, readWrite, smbcreate.Create, 0, smbstatus.Success)
+	write(zone, "[ZoneTransfer]\r\nZoneId=3\r\n")
+	tt.close(zone)
+
+	zone = create("open of a stream", "setup.exe:Zone.Identifier", readWrite, smbcreate.Open, 0, smbstatus.Success)
+	if data := tt.readPipe(zone, 64, smbstatus.Success); string(data) != "[ZoneTransfer]\r\nZoneId=3\r\n" {
+		t.Fatalf("the stream holds %q", data)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "setup.exe")); err != nil || string(data) != "program" {
+		t.Fatalf("the file holds %q, %v", data, err)
+	}
+
+	want := map[string]int64{"::$DATA": 7, ":Zone.Identifier:$DATA": 26}
+	if streams := tt.queryStreams("query of streams", file, 256, smbstatus.Success); !reflect.DeepEqual(streams, want) {
+		t.Fatalf("the file has streams %v (want %v)", streams, want)
+	}
+	if streams := tt.queryStreams("query of streams by a stream", zone, 256, smbstatus.Success); !reflect.DeepEqual(streams, want) {
+		t.Fatalf("the stream's file has streams %v (want %v)", streams, want)
+	}
+	want = map[string]int64{"::$DATA": 7}
+	if streams := tt.queryStreams("query of streams with a small buffer", file, 40, smbstatus.BufferOverflow); !reflect.DeepEqual(streams, want) {
+		t.Fatalf("the partial stream list holds %v (want %v)", streams, want)
+	}
+	tt.queryStreams("query of streams with a tiny buffer", file, 16, smbstatus.InfoLengthMismatch)
+	tt.close(zone)
+
+	// Names are validated and the default stream can be named explicitly
+	tt.close(create("open of the default stream", "setup.exe::$DATA", readWrite, smbcreate.Open, 0, smbstatus.Success))
+	create("open with an unknown stream type", "setup.exe:s:$INDEX_ALLOCATION", readWrite, smbcreate.Open, 0, smbstatus.ObjectNameInvalid)
+	create("open with an empty stream name", "setup.exe:", readWrite, smbcreate.Open, 0, smbstatus.ObjectNameInvalid)
+	create("open of a stream within a directory name", `dir:s\setup.exe`, readWrite, smbcreate.Open, 0, smbstatus.ObjectNameInvalid)
+	create("open of a missing stream", "setup.exe:missing", readWrite, smbcreate.Open, 0, smbstatus.ObjectNameNotFound)
+	create("exclusive create of an existing stream", "setup.exe:Zone.Identifier", readWrite, smbcreate.Create, 0, smbstatus.ObjectNameCollision)
+	create("open of a stream as a directory", "setup.exe:Zone.Identifier", readWrite, smbcreate.Open, smbcreate.DirectoryFile, smbstatus.NotADirectory)
+
+	// Creates of streams create their files
+	tt.close(create("create of a stream of a new file", "new.txt:extra", readWrite, smbcreate.Create, 0, smbstatus.Success))
+	if info, err := os.Stat(filepath.Join(dir, "new.txt")); err != nil || info.Size() != 0 {
+		t.Fatalf("the file of the stream wasn't created: %v", err)
+	}
+
+	// Streams that are deleted on close leave their files behind
+	tt.close(create("delete of a stream", "setup.exe:Zone.Identifier", readWrite|smbaccess.Delete, smbcreate.Open, smbcreate.DeleteOnClose, smbstatus.Success))
+	want = map[string]int64{"::$DATA": 7}
+	if streams := tt.queryStreams("query of streams after a delete", file, 256, smbstatus.Success); !reflect.DeepEqual(streams, want) {
+		t.Fatalf("the file has streams %v (want %v)", streams, want)
+	}
+	tt.close(file)
+}
@@ -0,0 +1,239 @@
+package smbserver
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+// Tree is a tree connect, which connects a session to a share.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.1.9
+type Tree struct {
+	ID            uint32
+	SessionID     uint64
+	Share         *Share
+	MaximalAccess smbaccess.Mask
+}
+
+// treeKey identifies a tree connect within a tree table.
+type treeKey struct {
+	session uint64
+	tree    uint32
+}
+
+// TreeTable holds the tree connects of every session on the server. It
+// must be created with NewTreeTable.
+type TreeTable struct {
+	mutex sync.Mutex
+	last  uint32 // The last tree ID issued
+	trees map[treeKey]*Tree
+}
+
+// NewTreeTable returns an empty tree table that is ready for use.
+func NewTreeTable() *TreeTable {
+	return &TreeTable{
+		trees: make(map[treeKey]*Tree),
+	}
+}
+
+// Lookup returns the tree with the given ID within the given session, or
+// nil.
+func (t *TreeTable) Lookup(sessionID uint64, treeID uint32) *Tree {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.trees[treeKey{session: sessionID, tree: treeID}]
+}
+
+// Len returns the number of trees in the table.
+func (t *TreeTable) Len() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.trees)
+}
+
+// uses returns the number of trees that are connected to s.
+func (t *TreeTable) uses(s *Share) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	n := 0
+	for _, tree := range t.trees {
+		if tree.Share == s {
+			n++
+		}
+	}
+	return n
+}
+
+// connect adds a tree that connects the given session to s.
+func (t *TreeTable) connect(sessionID uint64, s *Share, access smbaccess.Mask) *Tree {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Tree IDs are never zero or 0xFFFFFFFF, which is reserved for
+	// related operations
+	t.last++
+	if t.last == 0xFFFFFFFF {
+		t.last = 1
+	}
+	tree := &Tree{
+		ID:            t.last,
+		SessionID:     sessionID,
+		Share:         s,
+		MaximalAccess: access,
+	}
+	t.trees[treeKey{session: sessionID, tree: tree.ID}] = tree
+	return tree
+}
+
+// disconnect removes the tree with the given ID within the given session
+// from the table and returns it. It returns nil if there is no such tree.
+func (t *TreeTable) disconnect(sessionID uint64, treeID uint32) *Tree {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	key := treeKey{session: sessionID, tree: treeID}
+	tree := t.trees[key]
+	delete(t.trees, key)
+	return tree
+}
+
+// TreeConnect processes an SMB2 TREE_CONNECT request. It connects the
+// session of the request to the share named by its path, which takes the
+// form \\server\share. The server name is ignored. Connections to shares
+// that don't exist fail with STATUS_BAD_NETWORK_NAME.
+//
+// The IPC$ share, which holds the server's named pipes, is always
+// available.
+//
+// The maximal access of the tree is the access that the share permissions
+// grant to the user of the session. Users that are granted no access fail
+// with STATUS_ACCESS_DENIED.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.7
+func (c *Conn) TreeConnect(r *Request) Response {
+	request := smbtree.ConnectRequest(r.Data())
+	if !request.Valid() {
+		return treeConnectError(smbstatus.InvalidParameter)
+	}
+	if c.Trees == nil || c.Shares == nil {
+		return treeConnectError(smbstatus.BadNetworkName)
+	}
+
+	name, ok := shareName(request.Path())
+	if !ok {
+		return treeConnectError(smbstatus.BadNetworkName)
+	}
+	share := c.Shares.Lookup(name)
+	if share == nil {
+		return treeConnectError(smbstatus.BadNetworkName)
+	}
+
+	access := shareAccess(share, c.token(r.SessionID))
+	if access == 0 {
+		return treeConnectError(smbstatus.AccessDenied)
+	}
+	tree := c.Trees.connect(r.SessionID, share, access)
+	r.TreeID = tree.ID
+
+	return smbproto.TreeConnectResponse{
+		ShareType:     share.Type,
+		ShareFlags:    share.Flags,
+		Capabilities:  share.capabilities(),
+		MaximalAccess: tree.MaximalAccess,
+	}
+}
+
+// TreeDisconnect processes an SMB2 TREE_DISCONNECT request. It disconnects
+// the tree of the request and closes every open made within it.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.8
+func (c *Conn) TreeDisconnect(r *Request) Response {
+	request := smbtree.DisconnectRequest(r.Data())
+	if !request.Valid() {
+		return treeDisconnectError(smbstatus.InvalidParameter)
+	}
+	if c.Trees == nil {
+		return treeDisconnectError(smbstatus.NetworkNameDeleted)
+	}
+
+	tree := c.Trees.disconnect(r.SessionID, r.TreeID)
+	if tree == nil {
+		return treeDisconnectError(smbstatus.NetworkNameDeleted)
+	}
+
+	if c.Opens != nil {
+		for _, open := range c.Opens.within(tree.SessionID, tree.ID) {
+			if open = c.Opens.Remove(open.ID); open != nil {
+				c.releaseDurable(open)
+				open.Close()
+			}
+		}
+	}
+
+	return smbproto.TreeDisconnectResponse{}
+}
+
+// CreateInTree processes an SMB2 CREATE request within the tree of the
+// request. Creates within disk shares open files with CreateFile, and
+// creates within pipe shares open named pipes with CreatePipe. Names
+// within disk shares that are case-insensitive are resolved to the names
+// of the files they match, except in creates that carry a POSIX create
+// context, which are always case-sensitive. Creates
+// within trees that aren't connected fail with
+// STATUS_NETWORK_NAME_DELETED.
+func (c *Conn) CreateInTree(r *Request) Response {
+	tree := c.lookupTree(r)
+	if tree == nil {
+		return createError(smbstatus.NetworkNameDeleted)
+	}
+	switch tree.Share.Type {
+	case smbtree.Disk:
+		if tree.Share.FS == nil {
+			return createError(smbstatus.BadNetworkName)
+		}
+		return c.createFile(r, tree.Share.FS, tree.Share.resolver())
+	case smbtree.Pipe:
+		return c.CreatePipe(r)
+	default:
+		return createError(smbstatus.NotSupported)
+	}
+}
+
+// lookupTree returns the tree of r, or nil.
+func (c *Conn) lookupTree(r *Request) *Tree {
+	if c.Trees == nil {
+		return nil
+	}
+	return c.Trees.Lookup(r.SessionID, r.TreeID)
+}
+
+// shareName returns the name of the share within a path of the form
+// \\server\share. It returns false if the path is malformed.
+func shareName(path string) (string, bool) {
+	if !strings.HasPrefix(path, `\\`) {
+		return "", false
+	}
+	path = path[2:]
+	i := strings.IndexByte(path, '\\')
+	if i <= 0 {
+		return "", false
+	}
+	name := path[i+1:]
+	if name == "" || strings.ContainsRune(name, '\\') {
+		return "", false
+	}
+	return name, true
+}
+
+func treeConnectError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.TreeConnect, Code: code}
+}
+
+func treeDisconnectError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.TreeDisconnect, Code: code}
+}
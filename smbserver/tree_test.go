@@ -0,0 +1,156 @@
+package smbserver_test
+
+import (
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbosfs"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbserver"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbtree"
+)
+
+// treeTest holds a connection with a share table, a tree table and a pipe
+// registry. Requests are sent within the most recently connected tree.
+type treeTest struct {
+	t         *testing.T
+	conn      *smbserver.Conn
+	transport *testTransport
+	messageID uint64
+	treeID    uint32
+}
+
+func newTreeTest(t *testing.T) *treeTest {
+	conn, transport := newTestConn(64)
+	conn.Opens = smbserver.NewOpenTable()
+	conn.Shares = smbserver.NewShareTable()
+	conn.Trees = smbserver.NewTreeTable()
+	conn.Pipes = smbserver.NewPipeRegistry()
+	return &treeTest{t: t, conn: conn, transport: transport}
+}
+
+// send sends a request within the current tree and returns its response.
+func (tt *treeTest) send(cmd smbcommand.Code, body []byte) smbpacket.Response {
+	tt.t.Helper()
+	r := testRequest{Command: cmd, MessageID: tt.messageID, SessionID: 1, TreeID: tt.treeID, Body: body}
+	tt.messageID++
+	handler := smbserver.CommandHandlerFunc(func(c *smbserver.Conn, r *smbserver.Request) smbserver.Response {
+		switch r.Header().Command() {
+		case smbcommand.TreeConnect:
+			return c.TreeConnect(r)
+		case smbcommand.TreeDisconnect:
+			return c.TreeDisconnect(r)
+		case smbcommand.Create:
+			return c.CreateInTree(r)
+		case smbcommand.Close:
+			return c.CloseFile(r)
+		case smbcommand.Read:
+			return c.Read(r)
+		case smbcommand.Write:
+			return c.Write(r)
+		case smbcommand.QueryDirectory:
+			return c.QueryDirectory(r)
+		case smbcommand.QueryInfo:
+			return c.QueryInfo(r)
+		case smbcommand.SetInfo:
+			return c.SetInfo(r)
+		case smbcommand.IOCTL:
+			return c.Ioctl(r)
+		}
+		return nil
+	})
+	if err := tt.conn.Process(makeMessage(r), handler); err != nil {
+		tt.t.Fatalf("Process returned %v", err)
+	}
+	b := tt.transport.Next()
+	if b == nil {
+		tt.t.Fatal("no response was sent")
+	}
+	return smbpacket.Response(b)
+}
+
+// check fails the test if packet doesn't carry the given status.
+func (tt *treeTest) check(desc string, packet smbpacket.Response, status smbstatus.Code) {
+	tt.t.Helper()
+	if s := packet.Header().Status(); s != status {
+		tt.t.Fatalf("%s: returned %s (want %s)", desc, s, status)
+	}
+}
+
+// connect connects to the share with the given path. If it succeeds the
+// new tree becomes the current tree.
+func (tt *treeTest) connect(path string, status smbstatus.Code) smbtree.ConnectResponse {
+	tt.t.Helper()
+	body := make([]byte, smbtree.ConnectRequestBufferSize(path))
+	request := smbtree.ConnectRequest(body)
+	request.SetSize(9)
+	request.SetPath(path)
+	packet := tt.send(smbcommand.TreeConnect, body)
+	tt.check("connect to "+path, packet, status)
+	if status != smbstatus.Success {
+		return nil
+	}
+	tt.treeID = packet.Header().TreeID()
+	response := smbtree.ConnectResponse(packet.Data())
+	if !response.Valid() {
+		tt.t.Fatalf("connect to %s: invalid response", path)
+	}
+	return response
+}
+
+// disconnect disconnects the current tree.
+func (tt *treeTest) disconnect(status smbstatus.Code) {
+	tt.t.Helper()
+	body := make([]byte, smbtree.DisconnectSize)
+	smbtree.DisconnectRequest(body).SetSize(4)
+	tt.check("disconnect", tt.send(smbcommand.TreeDisconnect, body), status)
+}
+
+func TestTreeConnect(t *testing.T) {
+	tt := newTreeTest(t)
+	tt.conn.Shares.Add(&smbserver.Share{
+		Name: "Data",
+		Type: smbtree.Disk,
+		FS:   smbosfs.New(t.TempDir()),
+	})
+
+	tt.connect(`\\server\missing`, smbstatus.BadNetworkName)
+	tt.connect(`\\server`, smbstatus.BadNetworkName)
+
+	response := tt.connect(`\\server\DATA`, smbstatus.Success)
+	if tt.treeID == 0 {
+		t.Fatal("connect to a disk share: tree ID is zero")
+	}
+	if st := response.ShareType(); st != smbtree.Disk {
+		t.Fatalf("connect to a disk share: share type is %s", st)
+	}
+	if access := response.MaximalAccess(); access != smbaccess.FileAllAccess {
+		t.Fatalf("connect to a disk share: maximal access is %#x", access)
+	}
+
+	spec := createSpec{Name: "a.txt", Access: readWrite, Share: shareAll, Disposition: smbcreate.Create}
+	tt.check("create within a disk share", tt.send(smbcommand.Create, createBody(spec)), smbstatus.Success)
+	if n := tt.conn.Opens.Len(); n != 1 {
+		t.Fatalf("after create: %d opens (want 1)", n)
+	}
+
+	tt.disconnect(smbstatus.Success)
+	if n := tt.conn.Opens.Len(); n != 0 {
+		t.Fatalf("after disconnect: %d opens (want 0)", n)
+	}
+	spec.Disposition = smbcreate.Open
+	tt.check("create after disconnect", tt.send(smbcommand.Create, createBody(spec)), smbstatus.NetworkNameDeleted)
+	tt.disconnect(smbstatus.NetworkNameDeleted)
+
+	response = tt.connect(`\\server\ipc$`, smbstatus.Success)
+	if st := response.ShareType(); st != smbtree.Pipe {
+		t.Fatalf("connect to IPC$: share type is %s", st)
+	}
+	tt.check("create of an unregistered pipe", tt.send(smbcommand.Create, createBody(createSpec{Name: "srvsvc", Access: readWrite, Share: shareAll, Disposition: smbcreate.Open})), smbstatus.ObjectNameNotFound)
+	if n := tt.conn.Trees.Len(); n != 1 {
+		t.Fatalf("%d trees are connected (want 1)", n)
+	}
+}
@@ -0,0 +1,54 @@
+package smbserver
+
+import (
+	"github.com/gentlemanautomaton/smb/smbioctl"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbvalidate"
+)
+
+// validateNegotiateInfo processes an FSCTL_VALIDATE_NEGOTIATE_INFO request.
+// The client sends the parameters of its negotiate request in a signed
+// request so that a tampered negotiation can be detected. If they don't
+// match the parameters recorded for the connection, the connection is
+// closed without a response. Otherwise the server returns the parameters
+// of its own negotiate response in a signed response.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.15.12
+func (c *Conn) validateNegotiateInfo(r *Request, ctl FSCTL) Response {
+	if !ctl.FileID.IsRelated() {
+		// The file ID must be 0xFFFFFFFFFFFFFFFF
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+	if c.Sessions != nil && r.signingKey == nil {
+		// The request and response must be signed
+		return ioctlError(smbstatus.AccessDenied)
+	}
+	request := smbvalidate.Request(ctl.Input)
+	if !request.Valid() || ctl.MaxOutput < smbvalidate.ResponseSize {
+		return ioctlError(smbstatus.InvalidParameter)
+	}
+
+	dialect, ok := selectDialect(request.Dialects())
+	switch {
+	case request.Capabilities() != c.ClientCapabilities,
+		request.ClientID() != c.ClientGUID,
+		request.SecurityMode() != c.ClientSecurity,
+		!ok || dialect != c.Dialect.Revision():
+		c.Close()
+		return nil
+	}
+
+	output := make([]byte, smbvalidate.ResponseSize)
+	response := smbvalidate.Response(output)
+	response.SetCapabilities(c.Capabilities())
+	response.SetServerID(c.Server)
+	response.SetSecurityMode(c.SecurityMode())
+	response.SetDialect(c.Dialect.Revision())
+
+	return smbproto.IoctlResponse{
+		CtlCode: smbioctl.ValidateNegotiateInfo,
+		FileID:  ctl.FileID,
+		Output:  output,
+	}
+}
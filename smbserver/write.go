@@ -0,0 +1,82 @@
+package smbserver
+
+import (
+	"math"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcommand"
+	"github.com/gentlemanautomaton/smb/smbproto"
+	"github.com/gentlemanautomaton/smb/smbstatus"
+	"github.com/gentlemanautomaton/smb/smbwrite"
+)
+
+// Write processes an SMB2 WRITE request. It writes data to the file
+// referred to by the request. Writes to ranges that are locked exclusively
+// by other opens, or that are locked shared by any open, fail with
+// STATUS_FILE_LOCK_CONFLICT. Level II oplocks and read caching leases held
+// by other opens of the file are broken before the data is written. Writes
+// to named pipes pass the data to the pipe as a message. Opens that weren't
+// granted FILE_WRITE_DATA or FILE_APPEND_DATA fail with
+// STATUS_ACCESS_DENIED.
+//
+// Writes sent with a stale channel sequence number fail with
+// STATUS_FILE_NOT_AVAILABLE.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.13
+func (c *Conn) Write(r *Request) Response {
+	request := smbwrite.Request(r.Data())
+	if !request.Valid() {
+		return writeError(smbstatus.InvalidParameter)
+	}
+
+	id, ok := r.ResolveFileID(request.FileID())
+	if !ok {
+		return writeError(smbstatus.InvalidParameter)
+	}
+	open := c.lookupOpen(r, id)
+	if open == nil {
+		return writeError(smbstatus.FileClosed)
+	}
+	r.SetFileID(id)
+
+	if !open.GrantedAccess.Any(smbaccess.WriteData | smbaccess.AppendData) {
+		return writeError(smbstatus.AccessDenied)
+	}
+
+	done, ok := c.verifyChannelSequence(r, open)
+	if !ok {
+		return writeError(smbstatus.FileNotAvailable)
+	}
+	defer done()
+
+	if open.pipe != nil {
+		return c.writePipe(open, request.Data())
+	}
+	if open.Directory || open.File == nil {
+		return writeError(smbstatus.InvalidDeviceRequest)
+	}
+
+	length, offset := request.Length(), request.Offset()
+	if c.MaxWriteSize > 0 && length > c.MaxWriteSize {
+		return writeError(smbstatus.InvalidParameter)
+	}
+	if offset > math.MaxInt64 {
+		return writeError(smbstatus.InvalidParameter)
+	}
+
+	if !open.checkLock(offset, uint64(length), true) {
+		return writeError(smbstatus.FileLockConflict)
+	}
+	open.file.breakReadCaching(open, c.oplockBreakTimeout())
+
+	n, err := open.File.WriteAt(request.Data(), int64(offset))
+	if err != nil {
+		return writeError(fileStatus(err))
+	}
+
+	return smbproto.WriteResponse{Count: uint32(n)}
+}
+
+func writeError(code smbstatus.Code) Response {
+	return smbproto.ErrorResponse{Cmd: smbcommand.Write, Code: code}
+}
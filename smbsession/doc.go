@@ -0,0 +1,3 @@
+// Package smbsession provides types for SMB session setup requests and
+// responses.
+package smbsession
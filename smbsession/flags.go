@@ -0,0 +1,31 @@
+package smbsession
+
+// Flags are the flags of a session setup request.
+type Flags uint8
+
+// Session setup request flags.
+const (
+	// Binding indicates that the request binds the connection to an
+	// existing session as an additional channel.
+	Binding = 0x01 // SMB2_SESSION_FLAG_BINDING
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f Flags) Match(c Flags) bool {
+	return f&c == c
+}
+
+// SessionFlags describe a session that has been established.
+type SessionFlags uint16
+
+// Session flags.
+const (
+	IsGuest     = 0x0001 // SMB2_SESSION_FLAG_IS_GUEST
+	IsNull      = 0x0002 // SMB2_SESSION_FLAG_IS_NULL
+	EncryptData = 0x0004 // SMB2_SESSION_FLAG_ENCRYPT_DATA
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f SessionFlags) Match(c SessionFlags) bool {
+	return f&c == c
+}
@@ -0,0 +1,144 @@
+package smbsession
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcap"
+	"github.com/gentlemanautomaton/smb/smbsecmode"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for the fixed portion of an
+// SMB session setup request.
+const RequestSize = 24
+
+// headerSize is the number of bytes in an SMB packet header. It's needed by
+// this package to calculate buffer offsets relative to the start of the
+// packet.
+const headerSize = 64
+
+// Request interprets a slice of bytes as an SMB session setup request
+// packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.5
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 25
+	if r.Size() != 25 {
+		return false
+	}
+
+	// The security buffer must not overflow
+	if length := int(r.SecurityBufferLength()); length > 0 {
+		start := int(r.SecurityBufferOffset()) - headerSize
+		if start < RequestSize || start+length > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r Request) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r Request) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// Flags returns the flags of the request.
+func (r Request) Flags() Flags {
+	return Flags(r[2])
+}
+
+// SetFlags sets the flags of the request.
+func (r Request) SetFlags(flags Flags) {
+	r[2] = byte(flags)
+}
+
+// SecurityMode returns the security mode of the client.
+func (r Request) SecurityMode() smbsecmode.Flags {
+	return smbsecmode.Flags(r[3])
+}
+
+// SetSecurityMode sets the security mode of the client.
+func (r Request) SetSecurityMode(mode smbsecmode.Flags) {
+	r[3] = byte(mode)
+}
+
+// Capabilities returns the capabilities of the client.
+func (r Request) Capabilities() smbcap.Flags {
+	return smbcap.Flags(smbtype.Uint32(r[4:8]))
+}
+
+// SetCapabilities sets the capabilities of the client.
+func (r Request) SetCapabilities(caps smbcap.Flags) {
+	smbtype.PutUint32(r[4:8], uint32(caps))
+}
+
+// SecurityBufferOffset returns the offset of the security buffer in bytes
+// from the start of the packet header.
+func (r Request) SecurityBufferOffset() uint16 {
+	return smbtype.Uint16(r[12:14])
+}
+
+// SetSecurityBufferOffset sets the offset of the security buffer in bytes
+// from the start of the packet header.
+func (r Request) SetSecurityBufferOffset(offset uint16) {
+	smbtype.PutUint16(r[12:14], offset)
+}
+
+// SecurityBufferLength returns the length of the security buffer in bytes.
+func (r Request) SecurityBufferLength() uint16 {
+	return smbtype.Uint16(r[14:16])
+}
+
+// SetSecurityBufferLength sets the length of the security buffer in bytes.
+func (r Request) SetSecurityBufferLength(length uint16) {
+	smbtype.PutUint16(r[14:16], length)
+}
+
+// PreviousSessionID returns the ID of a previous session of the client
+// that the server should clean up.
+func (r Request) PreviousSessionID() uint64 {
+	return smbtype.Uint64(r[16:24])
+}
+
+// SetPreviousSessionID sets the ID of a previous session of the client
+// that the server should clean up.
+func (r Request) SetPreviousSessionID(id uint64) {
+	smbtype.PutUint64(r[16:24], id)
+}
+
+// SecurityBuffer returns the security token carried by the request.
+func (r Request) SecurityBuffer() []byte {
+	length := uint(r.SecurityBufferLength())
+	if length == 0 {
+		return nil
+	}
+	start := uint(r.SecurityBufferOffset()) - headerSize
+	end := start + length
+	return r[start:end:end]
+}
+
+// SetSecurityBufferLayout sets the offset and length of the security
+// buffer. The buffer is placed immediately after the fixed portion of the
+// request. It returns the security buffer so that it can be populated.
+//
+// If the request is too small to hold length bytes the call will panic.
+func (r Request) SetSecurityBufferLayout(length int) []byte {
+	if len(r)-RequestSize < length {
+		panic("smbsession: request: security buffer is too large to fit in request")
+	}
+	r.SetSecurityBufferOffset(headerSize + RequestSize)
+	r.SetSecurityBufferLength(uint16(length))
+	end := RequestSize + length
+	return r[RequestSize:end:end]
+}
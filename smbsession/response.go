@@ -0,0 +1,108 @@
+package smbsession
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ResponseSize is the number of bytes required for the fixed portion of an
+// SMB session setup response.
+const ResponseSize = 8
+
+// Response interprets a slice of bytes as an SMB session setup response
+// packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.6
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	if len(r) < ResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 9
+	if r.Size() != 9 {
+		return false
+	}
+
+	// The security buffer must not overflow
+	if length := int(r.SecurityBufferLength()); length > 0 {
+		start := int(r.SecurityBufferOffset()) - headerSize
+		if start < ResponseSize || start+length > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r Response) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r Response) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// SessionFlags returns the flags that describe the session.
+func (r Response) SessionFlags() SessionFlags {
+	return SessionFlags(smbtype.Uint16(r[2:4]))
+}
+
+// SetSessionFlags sets the flags that describe the session.
+func (r Response) SetSessionFlags(flags SessionFlags) {
+	smbtype.PutUint16(r[2:4], uint16(flags))
+}
+
+// SecurityBufferOffset returns the offset of the security buffer in bytes
+// from the start of the packet header.
+func (r Response) SecurityBufferOffset() uint16 {
+	return smbtype.Uint16(r[4:6])
+}
+
+// SetSecurityBufferOffset sets the offset of the security buffer in bytes
+// from the start of the packet header.
+func (r Response) SetSecurityBufferOffset(offset uint16) {
+	smbtype.PutUint16(r[4:6], offset)
+}
+
+// SecurityBufferLength returns the length of the security buffer in bytes.
+func (r Response) SecurityBufferLength() uint16 {
+	return smbtype.Uint16(r[6:8])
+}
+
+// SetSecurityBufferLength sets the length of the security buffer in bytes.
+func (r Response) SetSecurityBufferLength(length uint16) {
+	smbtype.PutUint16(r[6:8], length)
+}
+
+// SecurityBuffer returns the security token carried by the response.
+func (r Response) SecurityBuffer() []byte {
+	length := uint(r.SecurityBufferLength())
+	if length == 0 {
+		return nil
+	}
+	start := uint(r.SecurityBufferOffset()) - headerSize
+	end := start + length
+	return r[start:end:end]
+}
+
+// SetSecurityBufferLayout sets the offset and length of the security
+// buffer. The buffer is placed immediately after the fixed portion of the
+// response. It returns the security buffer so that it can be populated.
+//
+// If the response is too small to hold length bytes the call will panic.
+func (r Response) SetSecurityBufferLayout(length int) []byte {
+	if len(r)-ResponseSize < length {
+		panic("smbsession: response: security buffer is too large to fit in response")
+	}
+	if length == 0 {
+		r.SetSecurityBufferOffset(0)
+		r.SetSecurityBufferLength(0)
+		return nil
+	}
+	r.SetSecurityBufferOffset(headerSize + ResponseSize)
+	r.SetSecurityBufferLength(uint16(length))
+	end := ResponseSize + length
+	return r[ResponseSize:end:end]
+}
@@ -0,0 +1,60 @@
+package smbsign
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+)
+
+// cmac computes the AES-CMAC of msg using key.
+//
+// See RFC 4493.
+func cmac(key, msg []byte) ([aes.BlockSize]byte, error) {
+	var mac [aes.BlockSize]byte
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return mac, err
+	}
+
+	// Generate the subkeys
+	var k1, k2 [aes.BlockSize]byte
+	block.Encrypt(k1[:], k1[:])
+	shift(&k1)
+	k2 = k1
+	shift(&k2)
+
+	// Process every complete block except the last
+	n := len(msg)
+	for len(msg) > aes.BlockSize {
+		subtle.XORBytes(mac[:], mac[:], msg[:aes.BlockSize])
+		block.Encrypt(mac[:], mac[:])
+		msg = msg[aes.BlockSize:]
+	}
+
+	// The last block is padded if it's incomplete
+	var last [aes.BlockSize]byte
+	copy(last[:], msg)
+	if n > 0 && len(msg) == aes.BlockSize {
+		subtle.XORBytes(last[:], last[:], k1[:])
+	} else {
+		last[len(msg)] = 0x80
+		subtle.XORBytes(last[:], last[:], k2[:])
+	}
+	subtle.XORBytes(mac[:], mac[:], last[:])
+	block.Encrypt(mac[:], mac[:])
+
+	return mac, nil
+}
+
+// shift shifts b left by one bit and conditionally XORs it with the
+// constant Rb, as required to generate CMAC subkeys.
+func shift(b *[aes.BlockSize]byte) {
+	carry := b[0] >> 7
+	for i := 0; i < aes.BlockSize-1; i++ {
+		b[i] = b[i]<<1 | b[i+1]>>7
+	}
+	b[aes.BlockSize-1] <<= 1
+	if carry != 0 {
+		b[aes.BlockSize-1] ^= 0x87
+	}
+}
@@ -0,0 +1,3 @@
+// Package smbsign computes and verifies SMB packet signatures and derives
+// the keys that are used to sign them.
+package smbsign
@@ -0,0 +1,4 @@
+package smbsign
+
+// CMAC exposes cmac to the tests.
+var CMAC = cmac
@@ -0,0 +1,43 @@
+package smbsign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// KeySize is the size in bytes of SMB signing keys.
+const KeySize = 16
+
+// Labels and contexts used to derive signing keys.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.1.4.2
+var (
+	// SMB 3.0 and 3.0.2
+	label30   = []byte("SMB2AESCMAC\x00")
+	context30 = []byte("SmbSign\x00")
+
+	// SMB 3.1.1, which uses the preauthentication integrity hash as its
+	// context
+	label311 = []byte("SMBSigningKey\x00")
+)
+
+// DeriveKey derives a 128-bit key from key using the counter mode key
+// derivation function of SP800-108 with HMAC-SHA256 as its pseudorandom
+// function.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.1.4.2
+func DeriveKey(key, label, context []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], 1) // Counter
+	mac.Write(buf[:])
+	mac.Write(label)
+	mac.Write([]byte{0})
+	mac.Write(context)
+	binary.BigEndian.PutUint32(buf[:], KeySize*8) // Length in bits
+	mac.Write(buf[:])
+
+	return mac.Sum(nil)[:KeySize]
+}
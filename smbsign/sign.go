@@ -0,0 +1,82 @@
+package smbsign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+)
+
+// signatureOffset is the offset of the signature field within an SMB
+// packet header.
+const signatureOffset = 48
+
+// SigningKey derives the signing key of a session or channel from the
+// session key produced by authentication. SMB 2.x uses the session key
+// itself. SMB 3.0 and 3.0.2 derive the signing key with fixed labels, and
+// SMB 3.1.1 derives it from the preauthentication integrity hash of the
+// session setup exchange.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.3.5.5.3
+func SigningKey(sessionKey []byte, dialect smbdialect.Revision, preauthHash []byte) []byte {
+	key := make([]byte, KeySize)
+	copy(key, sessionKey)
+	switch {
+	case dialect.Major() < 3:
+		return key
+	case dialect == smbdialect.SMB311:
+		return DeriveKey(key, label311, preauthHash)
+	default:
+		return DeriveKey(key, label30, context30)
+	}
+}
+
+// Compute computes the signature of packet using key. The signature field
+// of the packet header is treated as zero. SMB 2.x signatures are computed
+// with HMAC-SHA256 and SMB 3.x signatures with AES-CMAC.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 3.1.4.1
+func Compute(key []byte, dialect smbdialect.Revision, packet []byte) smbpacket.Signature {
+	var sig smbpacket.Signature
+	if len(packet) < smbpacket.HeaderSize {
+		return sig
+	}
+
+	if dialect.Major() < 3 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(packet[:signatureOffset])
+		mac.Write(sig[:])
+		mac.Write(packet[signatureOffset+len(sig):])
+		copy(sig[:], mac.Sum(nil))
+		return sig
+	}
+
+	b := make([]byte, len(packet))
+	copy(b, packet)
+	copy(b[signatureOffset:], sig[:])
+	mac, err := cmac(key, b)
+	if err != nil {
+		return sig
+	}
+	copy(sig[:], mac[:])
+	return sig
+}
+
+// Sign computes the signature of packet using key and writes it to the
+// packet header. The packet's Signed flag must already be set.
+func Sign(key []byte, dialect smbdialect.Revision, packet []byte) {
+	smbpacket.ResponseHeader(packet).SetSignature(Compute(key, dialect, packet))
+}
+
+// Verify returns true if the signature in the header of packet is valid
+// for key.
+func Verify(key []byte, dialect smbdialect.Revision, packet []byte) bool {
+	if len(packet) < smbpacket.HeaderSize {
+		return false
+	}
+	expected := Compute(key, dialect, packet)
+	actual := smbpacket.RequestHeader(packet).Signature()
+	return subtle.ConstantTimeCompare(expected[:], actual[:]) == 1
+}
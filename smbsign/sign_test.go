@@ -0,0 +1,92 @@
+package smbsign_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbpacket"
+	"github.com/gentlemanautomaton/smb/smbsign"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TestCMAC checks the AES-CMAC implementation against the test vectors of
+// RFC 4493.
+func TestCMAC(t *testing.T) {
+	key := unhex("2b7e151628aed2a6abf7158809cf4f3c")
+	msg := unhex("6bc1bee22e409f96e93d7e117393172a" +
+		"ae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52ef" +
+		"f69f2445df4f9b17ad2b417be66c3710")
+	tests := []struct {
+		length int
+		mac    string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+	for _, test := range tests {
+		mac, err := smbsign.CMAC(key, msg[:test.length])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(mac[:]); got != test.mac {
+			t.Errorf("length %d: got %s, want %s", test.length, got, test.mac)
+		}
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	sessionKey := unhex("0123456789abcdef0123456789abcdef")
+	preauth := bytes.Repeat([]byte{0x5a}, 64)
+
+	if key := smbsign.SigningKey(sessionKey, smbdialect.SMB21, nil); !bytes.Equal(key, sessionKey) {
+		t.Errorf("SMB 2.1 signing key differs from the session key")
+	}
+	k30 := smbsign.SigningKey(sessionKey, smbdialect.SMB3, nil)
+	k302 := smbsign.SigningKey(sessionKey, smbdialect.SMB302, nil)
+	k311 := smbsign.SigningKey(sessionKey, smbdialect.SMB311, preauth)
+	if len(k30) != smbsign.KeySize || len(k311) != smbsign.KeySize {
+		t.Fatalf("unexpected key sizes %d and %d", len(k30), len(k311))
+	}
+	if !bytes.Equal(k30, k302) {
+		t.Errorf("SMB 3.0 and 3.0.2 signing keys differ")
+	}
+	if bytes.Equal(k30, sessionKey) || bytes.Equal(k30, k311) {
+		t.Errorf("SMB 3.x signing keys were not derived")
+	}
+	if other := smbsign.SigningKey(sessionKey, smbdialect.SMB311, preauth[1:]); bytes.Equal(k311, other) {
+		t.Errorf("SMB 3.1.1 signing key doesn't depend on the preauthentication hash")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key := unhex("00112233445566778899aabbccddeeff")
+	for _, dialect := range []smbdialect.Revision{smbdialect.SMB202, smbdialect.SMB21, smbdialect.SMB3, smbdialect.SMB311} {
+		packet := make([]byte, smbpacket.HeaderSize+24)
+		for i := range packet {
+			packet[i] = byte(i)
+		}
+		smbsign.Sign(key, dialect, packet)
+		if !smbsign.Verify(key, dialect, packet) {
+			t.Errorf("%s: signature did not verify", dialect)
+		}
+		if smbsign.Verify(unhex("ffeeddccbbaa99887766554433221100"), dialect, packet) {
+			t.Errorf("%s: signature verified with the wrong key", dialect)
+		}
+		packet[len(packet)-1] ^= 1
+		if smbsign.Verify(key, dialect, packet) {
+			t.Errorf("%s: signature of modified packet verified", dialect)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package smbsnapshot
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ArrayHeaderSize is the number of bytes required for the fixed portion of
+// a snapshot array.
+const ArrayHeaderSize = 12
+
+// ArraySize returns the number of bytes required for the array of the
+// given tokens, excluding the fixed portion. Each token is terminated by
+// a null character, and the array is terminated by an additional null
+// character.
+func ArraySize(tokens []string) int {
+	size := 2
+	for _, token := range tokens {
+		size += smbtype.StringLen(token) + 2
+	}
+	return size
+}
+
+// Array interprets a slice of bytes as a snapshot array, which is the
+// output of FSCTL_SRV_ENUMERATE_SNAPSHOTS.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.32.2
+type Array []byte
+
+// Valid returns true if the array is valid.
+func (a Array) Valid() bool {
+	if len(a) < ArrayHeaderSize {
+		return false
+	}
+	if a.Returned() == 0 {
+		return true
+	}
+	return ArrayHeaderSize+uint64(a.ArraySize()) <= uint64(len(a))
+}
+
+// Count returns the number of snapshots that are available.
+func (a Array) Count() uint32 {
+	return smbtype.Uint32(a[0:4])
+}
+
+// SetCount sets the number of snapshots that are available.
+func (a Array) SetCount(count uint32) {
+	smbtype.PutUint32(a[0:4], count)
+}
+
+// Returned returns the number of snapshot tokens in the array.
+func (a Array) Returned() uint32 {
+	return smbtype.Uint32(a[4:8])
+}
+
+// ArraySize returns the size of the array in bytes. If the tokens didn't
+// fit in the output buffer it holds the size that is required.
+func (a Array) ArraySize() uint32 {
+	return smbtype.Uint32(a[8:12])
+}
+
+// SetArraySize sets the size of the array in bytes.
+func (a Array) SetArraySize(size uint32) {
+	smbtype.PutUint32(a[8:12], size)
+}
+
+// Tokens returns the snapshot tokens in the array.
+func (a Array) Tokens() (tokens []string) {
+	if a.Returned() == 0 {
+		return nil
+	}
+	b := a[ArrayHeaderSize : ArrayHeaderSize+uint(a.ArraySize())]
+	for start := 0; start+2 <= len(b); {
+		end := start
+		for end+2 <= len(b) && (b[end] != 0 || b[end+1] != 0) {
+			end += 2
+		}
+		if end == start {
+			break
+		}
+		tokens = append(tokens, smbtype.String(b[start:end]))
+		start = end + 2
+	}
+	return tokens
+}
+
+// SetTokens writes the tokens to the array and sets the number of tokens
+// returned and the size of the array.
+//
+// If the array is too small to hold the tokens the call will panic.
+func (a Array) SetTokens(tokens []string) {
+	size := ArraySize(tokens)
+	if len(a) < ArrayHeaderSize+size {
+		panic("smbsnapshot: array: tokens are too large to fit in array")
+	}
+	b := a[ArrayHeaderSize:]
+	n := 0
+	for _, token := range tokens {
+		n += smbtype.PutString(b[n:], token)
+		b[n], b[n+1] = 0, 0
+		n += 2
+	}
+	b[n], b[n+1] = 0, 0
+	smbtype.PutUint32(a[4:8], uint32(len(tokens)))
+	a.SetArraySize(uint32(size))
+}
@@ -0,0 +1,4 @@
+// Package smbsnapshot provides types for SMB snapshot enumeration, which is
+// carried by FSCTL_SRV_ENUMERATE_SNAPSHOTS, and for the @GMT tokens that
+// name snapshots.
+package smbsnapshot
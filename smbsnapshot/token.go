@@ -0,0 +1,20 @@
+package smbsnapshot
+
+import "time"
+
+// TokenLayout is the layout of a snapshot token, in the form used by
+// time.Format. Tokens are always expressed in UTC.
+const TokenLayout = "@GMT-2006.01.02-15.04.05"
+
+// TokenLength is the number of characters in a snapshot token.
+const TokenLength = len(TokenLayout)
+
+// FormatToken returns the token that names the snapshot taken at t.
+func FormatToken(t time.Time) string {
+	return t.UTC().Format(TokenLayout)
+}
+
+// ParseToken returns the time of the snapshot named by token.
+func ParseToken(token string) (time.Time, error) {
+	return time.Parse(TokenLayout, token)
+}
@@ -0,0 +1,4 @@
+// Package smbsparse provides types for the sparse file requests and
+// responses carried by FSCTL_SET_SPARSE, FSCTL_SET_ZERO_DATA and
+// FSCTL_QUERY_ALLOCATED_RANGES.
+package smbsparse
@@ -0,0 +1,53 @@
+package smbsparse
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// RangeSize is the number of bytes in an allocated range.
+const RangeSize = 16
+
+// Range interprets a slice of bytes as an allocated range. It is the input
+// of a query allocated ranges request, which holds the range to query, and
+// each element of its output.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.3.46
+type Range []byte
+
+// Valid returns true if the range is valid.
+func (r Range) Valid() bool {
+	return len(r) >= RangeSize
+}
+
+// FileOffset returns the offset of the start of the range.
+func (r Range) FileOffset() int64 {
+	return int64(smbtype.Uint64(r[0:8]))
+}
+
+// SetFileOffset sets the offset of the start of the range.
+func (r Range) SetFileOffset(offset int64) {
+	smbtype.PutUint64(r[0:8], uint64(offset))
+}
+
+// Length returns the number of bytes in the range.
+func (r Range) Length() int64 {
+	return int64(smbtype.Uint64(r[8:16]))
+}
+
+// SetLength sets the number of bytes in the range.
+func (r Range) SetLength(length int64) {
+	smbtype.PutUint64(r[8:16], uint64(length))
+}
+
+// RangeList interprets a slice of bytes as a list of allocated ranges.
+type RangeList []byte
+
+// Count returns the number of ranges in the list.
+func (list RangeList) Count() int {
+	return len(list) / RangeSize
+}
+
+// Range returns the range at index i.
+func (list RangeList) Range(i int) Range {
+	start := i * RangeSize
+	end := start + RangeSize
+	return Range(list[start:end:end])
+}
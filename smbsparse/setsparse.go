@@ -0,0 +1,25 @@
+package smbsparse
+
+// SetSparseRequest interprets a slice of bytes as a set sparse request. The
+// request is optional, in which case the slice is empty.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.3.64
+type SetSparseRequest []byte
+
+// Sparse returns true if the file should be made sparse. It returns true
+// if the request is empty.
+func (r SetSparseRequest) Sparse() bool {
+	if len(r) == 0 {
+		return true
+	}
+	return r[0] != 0
+}
+
+// SetSparse sets whether the file should be made sparse.
+func (r SetSparseRequest) SetSparse(sparse bool) {
+	if sparse {
+		r[0] = 1
+	} else {
+		r[0] = 0
+	}
+}
@@ -0,0 +1,39 @@
+package smbsparse
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ZeroDataRequestSize is the number of bytes required for a set zero data
+// request.
+const ZeroDataRequestSize = 16
+
+// ZeroDataRequest interprets a slice of bytes as a set zero data request.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.3.67
+type ZeroDataRequest []byte
+
+// Valid returns true if the request is valid.
+func (r ZeroDataRequest) Valid() bool {
+	return len(r) >= ZeroDataRequestSize
+}
+
+// FileOffset returns the offset of the first byte to be set to zero.
+func (r ZeroDataRequest) FileOffset() int64 {
+	return int64(smbtype.Uint64(r[0:8]))
+}
+
+// SetFileOffset sets the offset of the first byte to be set to zero.
+func (r ZeroDataRequest) SetFileOffset(offset int64) {
+	smbtype.PutUint64(r[0:8], uint64(offset))
+}
+
+// BeyondFinalZero returns the offset of the first byte beyond the range
+// to be set to zero.
+func (r ZeroDataRequest) BeyondFinalZero() int64 {
+	return int64(smbtype.Uint64(r[8:16]))
+}
+
+// SetBeyondFinalZero sets the offset of the first byte beyond the range
+// to be set to zero.
+func (r ZeroDataRequest) SetBeyondFinalZero(offset int64) {
+	smbtype.PutUint64(r[8:16], uint64(offset))
+}
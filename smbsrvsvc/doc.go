@@ -0,0 +1,10 @@
+// Package smbsrvsvc provides types for the server service remote protocol
+// (SRVSVC), which clients call over the srvsvc named pipe to enumerate the
+// shares of a server and to query information about the server.
+//
+// Requests and responses are NDR-encoded stub data that is carried by the
+// DCE/RPC request and response PDUs of the smbrpc package. Only the
+// operations that clients need to browse a server are supported.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/
+package smbsrvsvc
@@ -0,0 +1,137 @@
+package smbsrvsvc
+
+import "github.com/gentlemanautomaton/smb/smbndr"
+
+// PlatformNT is the platform ID reported by Windows NT and its successors.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 2.2.2.6
+const PlatformNT = 500
+
+// ServerType is a set of flags that describe the roles of a server.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 2.2.2.7
+type ServerType uint32
+
+// Server types.
+const (
+	Workstation = 0x00000001 // SV_TYPE_WORKSTATION
+	Server      = 0x00000002 // SV_TYPE_SERVER
+	NT          = 0x00001000 // SV_TYPE_NT
+	ServerNT    = 0x00008000 // SV_TYPE_SERVER_NT
+)
+
+// Match reports whether t has all of the flags in m.
+func (t ServerType) Match(m ServerType) bool {
+	return t&m == m
+}
+
+// ServerInfo describes a server. The fields that are encoded depend on the
+// information level.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ sections 2.2.4.40 and 2.2.4.41
+type ServerInfo struct {
+	PlatformID   uint32     // Levels 100 and 101
+	Name         string     // Levels 100 and 101
+	VersionMajor uint32     // Level 101
+	VersionMinor uint32     // Level 101
+	Type         ServerType // Level 101
+	Comment      string     // Level 101
+}
+
+// ServerLevelSupported reports whether server information can be encoded at
+// the given level, which is one of 100 or 101.
+func ServerLevelSupported(level uint32) bool {
+	return level == 100 || level == 101
+}
+
+// ServerGetInfoRequest holds the input parameters of NetrServerGetInfo.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4.17
+type ServerGetInfoRequest struct {
+	ServerName string
+	Level      uint32
+}
+
+// Marshal returns the NDR encoding of the request.
+func (r *ServerGetInfoRequest) Marshal() []byte {
+	var e smbndr.Encoder
+	encodeServerName(&e, r.ServerName)
+	e.Uint32(r.Level)
+	return e.Bytes()
+}
+
+// Unmarshal decodes the NDR encoding of the request from stub.
+func (r *ServerGetInfoRequest) Unmarshal(stub []byte) error {
+	d := smbndr.NewDecoder(stub)
+	r.ServerName = decodeServerName(d)
+	r.Level = d.Uint32()
+	return d.Err()
+}
+
+// ServerGetInfoResponse holds the output parameters and the return value
+// of NetrServerGetInfo.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4.17
+type ServerGetInfoResponse struct {
+	Level  uint32
+	Server *ServerInfo // Nil if the call failed
+	Result Status
+}
+
+// Marshal returns the NDR encoding of the response. If the level isn't
+// supported the server information is encoded as a null pointer.
+func (r *ServerGetInfoResponse) Marshal() []byte {
+	var e smbndr.Encoder
+	e.Uint32(r.Level) // Union discriminant
+	if e.Pointer(r.Server != nil && ServerLevelSupported(r.Level)) {
+		e.Defer(func() {
+			info := r.Server
+			e.Uint32(info.PlatformID)
+			e.Pointer(true)
+			e.Defer(func() { e.String(info.Name) })
+			if r.Level == 101 {
+				e.Uint32(info.VersionMajor)
+				e.Uint32(info.VersionMinor)
+				e.Uint32(uint32(info.Type))
+				e.Pointer(true)
+				e.Defer(func() { e.String(info.Comment) })
+			}
+		})
+	}
+	e.Flush()
+	e.Uint32(uint32(r.Result))
+	return e.Bytes()
+}
+
+// Unmarshal decodes the NDR encoding of the response from stub.
+func (r *ServerGetInfoResponse) Unmarshal(stub []byte) error {
+	d := smbndr.NewDecoder(stub)
+	r.Level = d.Uint32()
+	r.Server = nil
+	if d.Pointer() {
+		if !ServerLevelSupported(r.Level) {
+			d.Fail(smbndr.ErrInvalidUnion)
+			return d.Err()
+		}
+		info := new(ServerInfo)
+		r.Server = info
+		str := func(s *string) {
+			if d.Pointer() {
+				d.Defer(func() { *s = d.String() })
+			}
+		}
+		d.Defer(func() {
+			info.PlatformID = d.Uint32()
+			str(&info.Name)
+			if r.Level == 101 {
+				info.VersionMajor = d.Uint32()
+				info.VersionMinor = d.Uint32()
+				info.Type = ServerType(d.Uint32())
+				str(&info.Comment)
+			}
+		})
+	}
+	d.Flush()
+	r.Result = Status(d.Uint32())
+	return d.Err()
+}
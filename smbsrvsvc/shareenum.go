@@ -0,0 +1,145 @@
+package smbsrvsvc
+
+import "github.com/gentlemanautomaton/smb/smbndr"
+
+// ShareEnumRequest holds the input parameters of NetrShareEnum.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4.8
+type ShareEnumRequest struct {
+	ServerName             string
+	Level                  uint32
+	PreferredMaximumLength uint32
+	ResumeHandle           *uint32 // Optional
+}
+
+// Marshal returns the NDR encoding of the request. The request carries an
+// empty share container at its level.
+func (r *ShareEnumRequest) Marshal() []byte {
+	var e smbndr.Encoder
+	encodeServerName(&e, r.ServerName)
+	encodeShareContainer(&e, r.Level, nil)
+	e.Flush()
+	e.Uint32(r.PreferredMaximumLength)
+	encodeResumeHandle(&e, r.ResumeHandle)
+	return e.Bytes()
+}
+
+// Unmarshal decodes the NDR encoding of the request from stub. The shares
+// in the container sent by the client, if any, are discarded.
+func (r *ShareEnumRequest) Unmarshal(stub []byte) error {
+	d := smbndr.NewDecoder(stub)
+	r.ServerName = decodeServerName(d)
+	r.Level, _ = decodeShareContainer(d)
+	d.Flush()
+	r.PreferredMaximumLength = d.Uint32()
+	r.ResumeHandle = decodeResumeHandle(d)
+	return d.Err()
+}
+
+// ShareEnumResponse holds the output parameters and the return value of
+// NetrShareEnum.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4.8
+type ShareEnumResponse struct {
+	Level        uint32
+	Shares       []ShareInfo
+	TotalEntries uint32
+	ResumeHandle *uint32 // Optional
+	Result       Status
+}
+
+// Marshal returns the NDR encoding of the response. If the level isn't
+// supported the share container is encoded as a null pointer.
+func (r *ShareEnumResponse) Marshal() []byte {
+	var e smbndr.Encoder
+	encodeShareContainer(&e, r.Level, r.Shares)
+	e.Flush()
+	e.Uint32(r.TotalEntries)
+	encodeResumeHandle(&e, r.ResumeHandle)
+	e.Uint32(uint32(r.Result))
+	return e.Bytes()
+}
+
+// Unmarshal decodes the NDR encoding of the response from stub.
+func (r *ShareEnumResponse) Unmarshal(stub []byte) error {
+	d := smbndr.NewDecoder(stub)
+	var shares *[]ShareInfo
+	r.Level, shares = decodeShareContainer(d)
+	d.Flush()
+	r.Shares = *shares
+	r.TotalEntries = d.Uint32()
+	r.ResumeHandle = decodeResumeHandle(d)
+	r.Result = Status(d.Uint32())
+	return d.Err()
+}
+
+// encodeShareContainer encodes a SHARE_ENUM_STRUCT that holds the given
+// shares at the given level, and defers the encoding of its pointees.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ sections 2.2.4.38 and 2.2.4.33
+func encodeShareContainer(e *smbndr.Encoder, level uint32, shares []ShareInfo) {
+	e.Uint32(level)
+	e.Uint32(level) // Union discriminant
+	if !e.Pointer(ShareLevelSupported(level)) {
+		return
+	}
+	e.Defer(func() {
+		e.Uint32(uint32(len(shares))) // Entries read
+		if !e.Pointer(len(shares) > 0) {
+			return
+		}
+		e.Defer(func() {
+			e.Uint32(uint32(len(shares))) // Maximum count
+			for i := range shares {
+				encodeShareInfo(e, level, &shares[i])
+			}
+		})
+	})
+}
+
+// decodeShareContainer decodes a SHARE_ENUM_STRUCT and returns its level,
+// along with a pointer to the shares that are filled in when the deferred
+// pointees are decoded.
+func decodeShareContainer(d *smbndr.Decoder) (level uint32, shares *[]ShareInfo) {
+	shares = new([]ShareInfo)
+	level = d.Uint32()
+	d.Uint32() // Union discriminant
+	if !d.Pointer() {
+		return
+	}
+	if !ShareLevelSupported(level) {
+		d.Fail(smbndr.ErrInvalidUnion)
+		return
+	}
+	d.Defer(func() {
+		d.Uint32() // Entries read
+		if !d.Pointer() {
+			return
+		}
+		d.Defer(func() {
+			n := d.ArrayCount(shareInfoSize(level))
+			*shares = make([]ShareInfo, n)
+			for i := range *shares {
+				decodeShareInfo(d, level, &(*shares)[i])
+			}
+		})
+	})
+	return
+}
+
+// encodeResumeHandle encodes an optional resume handle as a unique pointer
+// to its value.
+func encodeResumeHandle(e *smbndr.Encoder, handle *uint32) {
+	if e.Pointer(handle != nil) {
+		e.Uint32(*handle)
+	}
+}
+
+// decodeResumeHandle decodes an optional resume handle.
+func decodeResumeHandle(d *smbndr.Decoder) *uint32 {
+	if !d.Pointer() {
+		return nil
+	}
+	handle := d.Uint32()
+	return &handle
+}
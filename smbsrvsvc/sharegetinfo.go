@@ -0,0 +1,71 @@
+package smbsrvsvc
+
+import "github.com/gentlemanautomaton/smb/smbndr"
+
+// ShareGetInfoRequest holds the input parameters of NetrShareGetInfo.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4.10
+type ShareGetInfoRequest struct {
+	ServerName string
+	NetName    string
+	Level      uint32
+}
+
+// Marshal returns the NDR encoding of the request.
+func (r *ShareGetInfoRequest) Marshal() []byte {
+	var e smbndr.Encoder
+	encodeServerName(&e, r.ServerName)
+	e.String(r.NetName)
+	e.Uint32(r.Level)
+	return e.Bytes()
+}
+
+// Unmarshal decodes the NDR encoding of the request from stub.
+func (r *ShareGetInfoRequest) Unmarshal(stub []byte) error {
+	d := smbndr.NewDecoder(stub)
+	r.ServerName = decodeServerName(d)
+	r.NetName = d.String()
+	r.Level = d.Uint32()
+	return d.Err()
+}
+
+// ShareGetInfoResponse holds the output parameters and the return value of
+// NetrShareGetInfo.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4.10
+type ShareGetInfoResponse struct {
+	Level  uint32
+	Share  *ShareInfo // Nil if the call failed
+	Result Status
+}
+
+// Marshal returns the NDR encoding of the response. If the level isn't
+// supported the share information is encoded as a null pointer.
+func (r *ShareGetInfoResponse) Marshal() []byte {
+	var e smbndr.Encoder
+	e.Uint32(r.Level) // Union discriminant
+	if e.Pointer(r.Share != nil && ShareLevelSupported(r.Level)) {
+		e.Defer(func() { encodeShareInfo(&e, r.Level, r.Share) })
+	}
+	e.Flush()
+	e.Uint32(uint32(r.Result))
+	return e.Bytes()
+}
+
+// Unmarshal decodes the NDR encoding of the response from stub.
+func (r *ShareGetInfoResponse) Unmarshal(stub []byte) error {
+	d := smbndr.NewDecoder(stub)
+	r.Level = d.Uint32()
+	r.Share = nil
+	if d.Pointer() {
+		if !ShareLevelSupported(r.Level) {
+			d.Fail(smbndr.ErrInvalidUnion)
+			return d.Err()
+		}
+		r.Share = new(ShareInfo)
+		d.Defer(func() { decodeShareInfo(d, r.Level, r.Share) })
+	}
+	d.Flush()
+	r.Result = Status(d.Uint32())
+	return d.Err()
+}
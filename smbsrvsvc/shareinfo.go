@@ -0,0 +1,134 @@
+package smbsrvsvc
+
+import "github.com/gentlemanautomaton/smb/smbndr"
+
+// ShareInfo describes a share. The fields that are encoded depend on the
+// information level.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 2.2.4
+type ShareInfo struct {
+	Name        string    // Levels 0, 1, 2, 501 and 502
+	Type        ShareType // Levels 1, 2, 501 and 502
+	Remark      string    // Levels 1, 2, 501 and 502
+	Permissions uint32    // Levels 2 and 502
+	MaxUses     uint32    // Levels 2 and 502
+	CurrentUses uint32    // Levels 2 and 502
+	Path        string    // Levels 2 and 502
+	Flags       uint32    // Level 501; SHI1005 flags
+}
+
+// UnlimitedUses is the MaxUses value of shares that don't limit the number
+// of concurrent connections.
+const UnlimitedUses = 0xFFFFFFFF
+
+// ShareLevelSupported reports whether share information can be encoded at
+// the given level, which is one of 0, 1, 2, 501 or 502.
+func ShareLevelSupported(level uint32) bool {
+	switch level {
+	case 0, 1, 2, 501, 502:
+		return true
+	}
+	return false
+}
+
+// encodeShareInfo encodes the fixed part of a SHARE_INFO structure at the
+// given level, and defers the pointees of its string fields. The password
+// of level 2 and 502 and the security descriptor of level 502 are always
+// encoded as null pointers.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ sections 2.2.4.22 through 2.2.4.26
+func encodeShareInfo(e *smbndr.Encoder, level uint32, info *ShareInfo) {
+	str := func(s string) {
+		e.Pointer(true)
+		e.Defer(func() { e.String(s) })
+	}
+
+	str(info.Name)
+	if level == 0 {
+		return
+	}
+	e.Uint32(uint32(info.Type))
+	str(info.Remark)
+	switch level {
+	case 501:
+		e.Uint32(info.Flags)
+	case 2, 502:
+		e.Uint32(info.Permissions)
+		e.Uint32(info.MaxUses)
+		e.Uint32(info.CurrentUses)
+		str(info.Path)
+		e.Pointer(false) // Password
+		if level == 502 {
+			e.Uint32(0)      // Security descriptor length
+			e.Pointer(false) // Security descriptor
+		}
+	}
+}
+
+// decodeShareInfo decodes the fixed part of a SHARE_INFO structure at the
+// given level into info, and defers the decoding of its pointees.
+func decodeShareInfo(d *smbndr.Decoder, level uint32, info *ShareInfo) {
+	str := func(s *string) {
+		if d.Pointer() {
+			d.Defer(func() { *s = d.String() })
+		}
+	}
+
+	str(&info.Name)
+	if level == 0 {
+		return
+	}
+	info.Type = ShareType(d.Uint32())
+	str(&info.Remark)
+	switch level {
+	case 501:
+		info.Flags = d.Uint32()
+	case 2, 502:
+		info.Permissions = d.Uint32()
+		info.MaxUses = d.Uint32()
+		info.CurrentUses = d.Uint32()
+		str(&info.Path)
+		var password string
+		str(&password)
+		if level == 502 {
+			d.Uint32() // Security descriptor length
+			if d.Pointer() {
+				d.Defer(func() { d.Skip(d.ArrayCount(1)) })
+			}
+		}
+	}
+}
+
+// shareInfoSize returns the minimum number of bytes in the fixed part of a
+// SHARE_INFO structure at the given level.
+func shareInfoSize(level uint32) int {
+	switch level {
+	case 0:
+		return 4
+	case 1:
+		return 12
+	case 501:
+		return 16
+	case 2:
+		return 32
+	default:
+		return 40
+	}
+}
+
+// encodeServerName encodes the SRVSVC_HANDLE parameter that starts every
+// request, which is a unique pointer to a string.
+func encodeServerName(e *smbndr.Encoder, name string) {
+	if e.Pointer(name != "") {
+		e.String(name)
+	}
+}
+
+// decodeServerName decodes the SRVSVC_HANDLE parameter that starts every
+// request.
+func decodeServerName(d *smbndr.Decoder) string {
+	if d.Pointer() {
+		return d.String()
+	}
+	return ""
+}
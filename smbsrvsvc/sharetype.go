@@ -0,0 +1,29 @@
+package smbsrvsvc
+
+// ShareType is the type of a share as reported by srvsvc operations.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 2.2.2.4
+type ShareType uint32
+
+// Share types.
+const (
+	DiskTree   = 0x00000000 // STYPE_DISKTREE
+	PrintQueue = 0x00000001 // STYPE_PRINTQ
+	Device     = 0x00000002 // STYPE_DEVICE
+	IPC        = 0x00000003 // STYPE_IPC
+	Temporary  = 0x40000000 // STYPE_TEMPORARY
+	Special    = 0x80000000 // STYPE_SPECIAL
+)
+
+// TypeMask selects the basic type of a share, without its modifiers.
+const TypeMask = 0x0000000F
+
+// Base returns the basic type of the share, without its modifiers.
+func (t ShareType) Base() ShareType {
+	return t & TypeMask
+}
+
+// Match reports whether t has all of the modifiers in m.
+func (t ShareType) Match(m ShareType) bool {
+	return t&m == m
+}
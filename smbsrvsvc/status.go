@@ -0,0 +1,38 @@
+package smbsrvsvc
+
+import "strconv"
+
+// Status is the Win32 error code returned by a srvsvc operation.
+type Status uint32
+
+// Status codes.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-erref/ section 2.2
+const (
+	Success          = 0    // ERROR_SUCCESS
+	AccessDenied     = 5    // ERROR_ACCESS_DENIED
+	InvalidParameter = 87   // ERROR_INVALID_PARAMETER
+	InvalidLevel     = 124  // ERROR_INVALID_LEVEL
+	MoreData         = 234  // ERROR_MORE_DATA
+	NetNameNotFound  = 2310 // NERR_NetNameNotFound
+)
+
+// String returns a string representation of the status.
+func (s Status) String() string {
+	switch s {
+	case Success:
+		return "Success"
+	case AccessDenied:
+		return "AccessDenied"
+	case InvalidParameter:
+		return "InvalidParameter"
+	case InvalidLevel:
+		return "InvalidLevel"
+	case MoreData:
+		return "MoreData"
+	case NetNameNotFound:
+		return "NetNameNotFound"
+	default:
+		return "Status " + strconv.Itoa(int(s))
+	}
+}
@@ -0,0 +1,24 @@
+package smbsrvsvc
+
+import (
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbrpc"
+)
+
+// Syntax is the abstract syntax of the srvsvc interface, which is
+// 4b324fc8-1670-01d3-1278-5a47bf6ee188 version 3.0.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 2.1
+var Syntax = smbrpc.SyntaxID{
+	UUID:    smbid.ID{0x4b, 0x32, 0x4f, 0xc8, 0x16, 0x70, 0x01, 0xd3, 0x12, 0x78, 0x5a, 0x47, 0xbf, 0x6e, 0xe1, 0x88},
+	Version: 3,
+}
+
+// Operation numbers of the srvsvc interface.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-srvs/ section 3.1.4
+const (
+	NetrShareEnum     = 15
+	NetrShareGetInfo  = 16
+	NetrServerGetInfo = 21
+)
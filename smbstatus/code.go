@@ -0,0 +1,190 @@
+package smbstatus
+
+import "strconv"
+
+// Code is an NTSTATUS code that indicates the success or failure of an
+// SMB command.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-erref/596a1078-e883-4972-9bbc-49e60bebca55
+type Code uint32
+
+// NTSTATUS codes.
+const (
+	Success                = 0x00000000 // STATUS_SUCCESS
+	Pending                = 0x00000103 // STATUS_PENDING
+	NotifyCleanup          = 0x0000010B // STATUS_NOTIFY_CLEANUP
+	NotifyEnumDir          = 0x0000010C // STATUS_NOTIFY_ENUM_DIR
+	BufferOverflow         = 0x80000005 // STATUS_BUFFER_OVERFLOW
+	StoppedOnSymlink       = 0x8000002D // STATUS_STOPPED_ON_SYMLINK
+	Unsuccessful           = 0xC0000001 // STATUS_UNSUCCESSFUL
+	NotImplemented         = 0xC0000002 // STATUS_NOT_IMPLEMENTED
+	InvalidHandle          = 0xC0000008 // STATUS_INVALID_HANDLE
+	InvalidParameter       = 0xC000000D // STATUS_INVALID_PARAMETER
+	AccessDenied           = 0xC0000022 // STATUS_ACCESS_DENIED
+	BufferTooSmall         = 0xC0000023 // STATUS_BUFFER_TOO_SMALL
+	LogonFailure           = 0xC000006D // STATUS_LOGON_FAILURE
+	InsufficientResources  = 0xC000009A // STATUS_INSUFFICIENT_RESOURCES
+	NotSupported           = 0xC00000BB // STATUS_NOT_SUPPORTED
+	Cancelled              = 0xC0000120 // STATUS_CANCELLED
+	FileClosed             = 0xC0000128 // STATUS_FILE_CLOSED
+	UserSessionDeleted     = 0xC0000203 // STATUS_USER_SESSION_DELETED
+	NetworkNameDeleted     = 0xC00000C9 // STATUS_NETWORK_NAME_DELETED
+	BadNetworkName         = 0xC00000CC // STATUS_BAD_NETWORK_NAME
+	PipeBusy               = 0xC00000AE // STATUS_PIPE_BUSY
+	PipeEmpty              = 0xC00000D9 // STATUS_PIPE_EMPTY
+	PipeBroken             = 0xC000014B // STATUS_PIPE_BROKEN
+	InternalError          = 0xC00000E5 // STATUS_INTERNAL_ERROR
+	RequestNotAccepted     = 0xC00000D0 // STATUS_REQUEST_NOT_ACCEPTED
+	InvalidDeviceRequest   = 0xC0000010 // STATUS_INVALID_DEVICE_REQUEST
+	MoreProcessingRequired = 0xC0000016 // STATUS_MORE_PROCESSING_REQUIRED
+	EndOfFile              = 0xC0000011 // STATUS_END_OF_FILE
+	InvalidViewSize        = 0xC000001F // STATUS_INVALID_VIEW_SIZE
+	FileLockConflict       = 0xC0000054 // STATUS_FILE_LOCK_CONFLICT
+	LockNotGranted         = 0xC0000055 // STATUS_LOCK_NOT_GRANTED
+	RangeNotLocked         = 0xC000007E // STATUS_RANGE_NOT_LOCKED
+	InvalidLockRange       = 0xC00001A1 // STATUS_INVALID_LOCK_RANGE
+	ObjectNameInvalid      = 0xC0000033 // STATUS_OBJECT_NAME_INVALID
+	ObjectNameNotFound     = 0xC0000034 // STATUS_OBJECT_NAME_NOT_FOUND
+	ObjectNameCollision    = 0xC0000035 // STATUS_OBJECT_NAME_COLLISION
+	ObjectPathNotFound     = 0xC000003A // STATUS_OBJECT_PATH_NOT_FOUND
+	SharingViolation       = 0xC0000043 // STATUS_SHARING_VIOLATION
+	DeletePending          = 0xC0000056 // STATUS_DELETE_PENDING
+	FileIsADirectory       = 0xC00000BA // STATUS_FILE_IS_A_DIRECTORY
+	NotADirectory          = 0xC0000103 // STATUS_NOT_A_DIRECTORY
+	InvalidOplockProtocol  = 0xC00000E3 // STATUS_INVALID_OPLOCK_PROTOCOL
+	DuplicateObjectID      = 0xC000022A // STATUS_DUPLICATE_OBJECTID
+	FileNotAvailable       = 0xC0000467 // STATUS_FILE_NOT_AVAILABLE
+	NotAReparsePoint       = 0xC0000275 // STATUS_NOT_A_REPARSE_POINT
+	ReparseDataInvalid     = 0xC0000278 // STATUS_IO_REPARSE_DATA_INVALID
+	ReparseTagNotHandled   = 0xC0000279 // STATUS_IO_REPARSE_TAG_NOT_HANDLED
+	InvalidInfoClass       = 0xC0000003 // STATUS_INVALID_INFO_CLASS
+	InvalidSecurityDescr   = 0xC0000079 // STATUS_INVALID_SECURITY_DESCR
+	NoMoreFiles            = 0x80000006 // STATUS_NO_MORE_FILES
+	NoSuchFile             = 0xC000000F // STATUS_NO_SUCH_FILE
+	InfoLengthMismatch     = 0xC0000004 // STATUS_INFO_LENGTH_MISMATCH
+	DiskFull               = 0xC000007F // STATUS_DISK_FULL
+)
+
+// Success returns true if c has a success or informational severity.
+func (c Code) Success() bool {
+	return c>>31 == 0
+}
+
+// Failure returns true if c has an error severity.
+func (c Code) Failure() bool {
+	return c>>30 == 3
+}
+
+// String returns a string representation of the status code.
+func (c Code) String() string {
+	switch c {
+	case Success:
+		return "Success"
+	case Pending:
+		return "Pending"
+	case NotifyCleanup:
+		return "NotifyCleanup"
+	case NotifyEnumDir:
+		return "NotifyEnumDir"
+	case BufferOverflow:
+		return "BufferOverflow"
+	case StoppedOnSymlink:
+		return "StoppedOnSymlink"
+	case Unsuccessful:
+		return "Unsuccessful"
+	case NotImplemented:
+		return "NotImplemented"
+	case InvalidHandle:
+		return "InvalidHandle"
+	case InvalidParameter:
+		return "InvalidParameter"
+	case AccessDenied:
+		return "AccessDenied"
+	case BufferTooSmall:
+		return "BufferTooSmall"
+	case LogonFailure:
+		return "LogonFailure"
+	case InsufficientResources:
+		return "InsufficientResources"
+	case NotSupported:
+		return "NotSupported"
+	case Cancelled:
+		return "Cancelled"
+	case FileClosed:
+		return "FileClosed"
+	case UserSessionDeleted:
+		return "UserSessionDeleted"
+	case NetworkNameDeleted:
+		return "NetworkNameDeleted"
+	case BadNetworkName:
+		return "BadNetworkName"
+	case PipeBusy:
+		return "PipeBusy"
+	case PipeEmpty:
+		return "PipeEmpty"
+	case PipeBroken:
+		return "PipeBroken"
+	case InternalError:
+		return "InternalError"
+	case RequestNotAccepted:
+		return "RequestNotAccepted"
+	case InvalidDeviceRequest:
+		return "InvalidDeviceRequest"
+	case MoreProcessingRequired:
+		return "MoreProcessingRequired"
+	case EndOfFile:
+		return "EndOfFile"
+	case InvalidViewSize:
+		return "InvalidViewSize"
+	case FileLockConflict:
+		return "FileLockConflict"
+	case LockNotGranted:
+		return "LockNotGranted"
+	case RangeNotLocked:
+		return "RangeNotLocked"
+	case InvalidLockRange:
+		return "InvalidLockRange"
+	case ObjectNameInvalid:
+		return "ObjectNameInvalid"
+	case ObjectNameNotFound:
+		return "ObjectNameNotFound"
+	case ObjectNameCollision:
+		return "ObjectNameCollision"
+	case ObjectPathNotFound:
+		return "ObjectPathNotFound"
+	case SharingViolation:
+		return "SharingViolation"
+	case DeletePending:
+		return "DeletePending"
+	case FileIsADirectory:
+		return "FileIsADirectory"
+	case NotADirectory:
+		return "NotADirectory"
+	case InvalidOplockProtocol:
+		return "InvalidOplockProtocol"
+	case DuplicateObjectID:
+		return "DuplicateObjectID"
+	case FileNotAvailable:
+		return "FileNotAvailable"
+	case NotAReparsePoint:
+		return "NotAReparsePoint"
+	case ReparseDataInvalid:
+		return "ReparseDataInvalid"
+	case ReparseTagNotHandled:
+		return "ReparseTagNotHandled"
+	case InvalidInfoClass:
+		return "InvalidInfoClass"
+	case InvalidSecurityDescr:
+		return "InvalidSecurityDescr"
+	case NoMoreFiles:
+		return "NoMoreFiles"
+	case NoSuchFile:
+		return "NoSuchFile"
+	case InfoLengthMismatch:
+		return "InfoLengthMismatch"
+	case DiskFull:
+		return "DiskFull"
+	default:
+		return "Status 0x" + strconv.FormatUint(uint64(c), 16)
+	}
+}
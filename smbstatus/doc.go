@@ -0,0 +1,2 @@
+// Package smbstatus defines NTSTATUS codes used by the SMB protocol.
+package smbstatus
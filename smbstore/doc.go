@@ -0,0 +1,8 @@
+// Package smbstore defines the interface through which an SMB server
+// persists the state of persistent handles.
+//
+// Persistent handles are opens on continuously available shares whose
+// state survives a restart of the server. The server writes the state of
+// each persistent open through a Store as it changes, and loads it when it
+// starts so that reconnecting clients can reclaim their handles.
+package smbstore
@@ -0,0 +1,59 @@
+package smbstore
+
+import (
+	"time"
+
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbcreate"
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smblease"
+	"github.com/gentlemanautomaton/smb/smbsecurity"
+)
+
+// Handle holds the state of a persistent open.
+type Handle struct {
+	FileID     smbfile.ID
+	CreateGUID smbid.ID        // Identifies the create request that made the open
+	ClientGUID smbid.ID        // Identifies the client that made the open
+	Owner      smbsecurity.SID // The user of the session that made the open
+	Share      string          // The name of the share the open was made in
+	Name       string          // Slash-separated path relative to the share root
+	Directory  bool
+
+	GrantedAccess smbaccess.Mask
+	ShareAccess   smbcreate.ShareAccess
+	DeleteOnClose bool
+
+	// Timeout is the amount of time the open is preserved while its client
+	// is disconnected.
+	Timeout time.Duration
+
+	Lease         *Lease // Nil if the open doesn't belong to a lease
+	Locks         []Lock
+	LockSequences []LockSequence
+}
+
+// Lease holds the state of the lease that a persistent open belongs to.
+type Lease struct {
+	Key       smbid.ID
+	ParentKey smbid.ID
+	HasParent bool
+	Version   int
+	State     smblease.State
+	Epoch     uint16
+}
+
+// Lock is a byte-range lock held by a persistent open.
+type Lock struct {
+	Offset    uint64
+	Length    uint64
+	Exclusive bool
+}
+
+// LockSequence records the lock sequence number of the last successful
+// lock request made with a lock sequence index.
+type LockSequence struct {
+	Index  int
+	Number uint8
+}
@@ -0,0 +1,16 @@
+package smbstore
+
+// Store holds the state of persistent handles. Implementations must be safe
+// for concurrent use.
+type Store interface {
+	// Save writes h to the store, replacing any handle with the same
+	// persistent file ID.
+	Save(h Handle) error
+
+	// Delete removes the handle with the given persistent file ID from the
+	// store. Deleting a handle that isn't in the store is not an error.
+	Delete(persistent uint64) error
+
+	// Load returns every handle in the store.
+	Load() ([]Handle, error)
+}
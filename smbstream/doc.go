@@ -0,0 +1,8 @@
+// Package smbstream provides alternate data stream naming and the
+// FILE_STREAM_INFORMATION structure that lists the streams of a file.
+//
+// Files may hold named streams of data in addition to their default,
+// unnamed stream. Streams are addressed by appending a colon, the stream
+// name and optionally a colon and the stream type to the name of the file,
+// as in "file.txt:Zone.Identifier:$DATA".
+package smbstream
@@ -0,0 +1,130 @@
+package smbstream
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// InfoClass is the FileInformationClass of FILE_STREAM_INFORMATION.
+const InfoClass = 22
+
+// InfoHeaderSize is the number of bytes required for the fixed portion of a
+// stream information entry.
+const InfoHeaderSize = 24
+
+// InfoAlignment is the byte alignment required for each entry within a
+// list of stream information entries.
+const InfoAlignment = 8
+
+// InfoSize returns the number of bytes required to hold a stream
+// information entry with the given name, excluding alignment padding.
+func InfoSize(name string) int {
+	return InfoHeaderSize + smbtype.StringLen(name)
+}
+
+// Info interprets a slice of bytes as a FILE_STREAM_INFORMATION entry,
+// which describes one stream of a file.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.4.49
+type Info []byte
+
+// Valid returns true if the entry and its name are in bounds.
+func (i Info) Valid() bool {
+	if len(i) < InfoHeaderSize {
+		return false
+	}
+	return InfoHeaderSize+uint64(i.StreamNameLength()) <= uint64(len(i))
+}
+
+// NextEntryOffset returns the offset of the next entry in the list. It
+// returns zero if this is the last entry.
+func (i Info) NextEntryOffset() uint32 {
+	return smbtype.Uint32(i[0:4])
+}
+
+// SetNextEntryOffset sets the offset of the next entry in the list.
+func (i Info) SetNextEntryOffset(offset uint32) {
+	smbtype.PutUint32(i[0:4], offset)
+}
+
+// StreamNameLength returns the length of the stream name in bytes.
+func (i Info) StreamNameLength() uint32 {
+	return smbtype.Uint32(i[4:8])
+}
+
+// StreamSize returns the size of the stream in bytes.
+func (i Info) StreamSize() int64 {
+	return int64(smbtype.Uint64(i[8:16]))
+}
+
+// SetStreamSize sets the size of the stream in bytes.
+func (i Info) SetStreamSize(size int64) {
+	smbtype.PutUint64(i[8:16], uint64(size))
+}
+
+// StreamAllocationSize returns the number of bytes allocated for the
+// stream.
+func (i Info) StreamAllocationSize() int64 {
+	return int64(smbtype.Uint64(i[16:24]))
+}
+
+// SetStreamAllocationSize sets the number of bytes allocated for the
+// stream.
+func (i Info) SetStreamAllocationSize(size int64) {
+	smbtype.PutUint64(i[16:24], uint64(size))
+}
+
+// StreamName returns the name of the stream, such as "::$DATA" for the
+// default stream.
+func (i Info) StreamName() string {
+	end := InfoHeaderSize + uint(i.StreamNameLength())
+	return smbtype.String(i[InfoHeaderSize:end])
+}
+
+// SetStreamName sets the name of the stream and updates the stream name
+// length.
+func (i Info) SetStreamName(name string) {
+	n := smbtype.PutString(i[InfoHeaderSize:], name)
+	smbtype.PutUint32(i[4:8], uint32(n))
+}
+
+// InfoList interprets a slice of bytes as a list of FILE_STREAM_INFORMATION
+// entries.
+type InfoList []byte
+
+// Valid returns true if every entry in the list is in bounds.
+func (k InfoList) Valid() bool {
+	if len(k) == 0 {
+		return true
+	}
+	offset := uint(0)
+	for {
+		if offset >= uint(len(k)) {
+			return false
+		}
+		entry := Info(k[offset:])
+		if !entry.Valid() {
+			return false
+		}
+		next := uint(entry.NextEntryOffset())
+		if next == 0 {
+			return true
+		}
+		if next%InfoAlignment != 0 || next < InfoHeaderSize {
+			return false
+		}
+		offset += next
+	}
+}
+
+// Member returns the entry at the given offset within the list.
+func (k InfoList) Member(offset uint32) Info {
+	return Info(k[offset:])
+}
+
+// Next returns the offset of the entry that follows the entry at the given
+// offset. It returns zero if the entry at offset is the last in the list.
+func (k InfoList) Next(offset uint32) uint32 {
+	next := k.Member(offset).NextEntryOffset()
+	if next == 0 {
+		return 0
+	}
+	return offset + next
+}
@@ -0,0 +1,53 @@
+package smbstream
+
+import "strings"
+
+// DataType is the type of data streams, which are the only type of stream
+// that files can hold.
+const DataType = "$DATA"
+
+// DefaultName is the name of the default stream of a file within
+// FILE_STREAM_INFORMATION.
+const DefaultName = "::" + DataType
+
+// Split splits a slash or backslash-separated name of the form
+// file:stream:type, in which the stream and type are optional, into the
+// name of the file and the name of the stream. The default stream has an
+// empty stream name, and may be named explicitly as file::$DATA.
+//
+// Only the last element of a name may refer to a stream. It returns false
+// if the stream name or type is invalid.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-fscc/ section 2.1.5
+func Split(name string) (file, stream string, ok bool) {
+	i := strings.IndexByte(name, ':')
+	if i < 0 {
+		return name, "", true
+	}
+	file, rest := name[:i], name[i+1:]
+	stream, kind, typed := strings.Cut(rest, ":")
+	if typed && !strings.EqualFold(kind, DataType) {
+		return "", "", false
+	}
+	if stream == "" && !typed {
+		return "", "", false
+	}
+	if !ValidName(stream) {
+		return "", "", false
+	}
+	return file, stream, true
+}
+
+// ValidName returns true if stream is a valid stream name, which may not
+// contain colons, path separators or null characters. The empty name of
+// the default stream is valid.
+func ValidName(stream string) bool {
+	return !strings.ContainsAny(stream, "\x00:/\\")
+}
+
+// Name returns the name of the data stream with the given name as it is
+// listed in FILE_STREAM_INFORMATION, which is DefaultName for the default
+// stream.
+func Name(stream string) string {
+	return ":" + stream + ":" + DataType
+}
@@ -30,13 +30,21 @@ func (c Conn) Create(length int) smb.Message {
 	return c.msgPool.Get(length)
 }
 
-// Send sends a message to the connection.
+// Send sends a message to the connection. The message is preceded by its
+// transport packet header.
 //
 // TODO: Support deadlines and/or cancellation.
 func (c Conn) Send(msg smb.Message) error {
-	// TODO: Determine whether net.Conn.Write returns a nil error for partial
-	// writes.
-	_, err := c.nc.Write(msg.Bytes())
+	length := msg.Length()
+	if length > MaxLength {
+		return ErrMessageTooLong
+	}
+	hdr := [4]byte{0, byte(length >> 16), byte(length >> 8), byte(length)}
+
+	// The header and message are written together so that messages sent
+	// concurrently aren't interleaved
+	buffers := net.Buffers{hdr[:], msg.Bytes()}
+	_, err := buffers.WriteTo(c.nc)
 	return err
 }
 
@@ -9,6 +9,10 @@ const MaxLength = 16777215
 // received from a connection.
 var ErrBadHeader = errors.New("bad smb2 tcp header")
 
+// ErrMessageTooLong is returned when a message is too long to be sent over
+// TCP.
+var ErrMessageTooLong = errors.New("smb2 message exceeds maximum tcp message length")
+
 // Header interprets the bytes of a transport packet header for messages sent
 // over TCP.
 //
@@ -0,0 +1,20 @@
+package smbtree
+
+// Capabilities declares the capabilities of a share.
+type Capabilities uint32
+
+// Share capabilities.
+const (
+	CapDFS                    = 0x00000008 // SMB2_SHARE_CAP_DFS
+	CapContinuousAvailability = 0x00000010 // SMB2_SHARE_CAP_CONTINUOUS_AVAILABILITY
+	CapScaleout               = 0x00000020 // SMB2_SHARE_CAP_SCALEOUT
+	CapCluster                = 0x00000040 // SMB2_SHARE_CAP_CLUSTER
+	CapAsymmetric             = 0x00000080 // SMB2_SHARE_CAP_ASYMMETRIC
+	CapRedirectToOwner        = 0x00000100 // SMB2_SHARE_CAP_REDIRECT_TO_OWNER
+)
+
+// Match reports whether c contains all of the capabilities specified by
+// other.
+func (c Capabilities) Match(other Capabilities) bool {
+	return c&other == other
+}
@@ -0,0 +1,16 @@
+package smbtree
+
+// ConnectFlags are the flags of a tree connect request.
+type ConnectFlags uint16
+
+// Tree connect flags, which are only used by the SMB 3.1.1 dialect.
+const (
+	ClusterReconnect = 0x0001 // SMB2_TREE_CONNECT_FLAG_CLUSTER_RECONNECT
+	RedirectToOwner  = 0x0002 // SMB2_TREE_CONNECT_FLAG_REDIRECT_TO_OWNER
+	ExtensionPresent = 0x0004 // SMB2_TREE_CONNECT_FLAG_EXTENSION_PRESENT
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f ConnectFlags) Match(c ConnectFlags) bool {
+	return f&c == c
+}
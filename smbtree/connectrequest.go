@@ -0,0 +1,125 @@
+package smbtree
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ConnectRequestSize is the number of bytes required for the fixed portion
+// of an SMB tree connect request.
+const ConnectRequestSize = 8
+
+// headerSize is the number of bytes in an SMB packet header. It's needed by
+// this package to calculate buffer offsets relative to the start of the
+// packet.
+const headerSize = 64
+
+// ConnectRequest interprets a slice of bytes as an SMB tree connect request
+// packet.
+//
+// Requests that carry a tree connect extension are valid, but the
+// extension itself is not interpreted.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.9
+type ConnectRequest []byte
+
+// Valid returns true if the request is valid.
+func (r ConnectRequest) Valid() bool {
+	if len(r) < ConnectRequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 9
+	if r.Size() != 9 {
+		return false
+	}
+
+	// The path of a request with an extension is held by the extension
+	if r.Flags().Match(ExtensionPresent) {
+		return true
+	}
+
+	// The path must not overflow and must hold whole utf16 code units
+	if length := int(r.PathLength()); length > 0 {
+		start := int(r.PathOffset()) - headerSize
+		if start < ConnectRequestSize || start+length > len(r) || length%2 != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r ConnectRequest) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r ConnectRequest) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// Flags returns the flags of the request.
+func (r ConnectRequest) Flags() ConnectFlags {
+	return ConnectFlags(smbtype.Uint16(r[2:4]))
+}
+
+// SetFlags sets the flags of the request.
+func (r ConnectRequest) SetFlags(flags ConnectFlags) {
+	smbtype.PutUint16(r[2:4], uint16(flags))
+}
+
+// PathOffset returns the offset of the share path in bytes from the start
+// of the packet header.
+func (r ConnectRequest) PathOffset() uint16 {
+	return smbtype.Uint16(r[4:6])
+}
+
+// SetPathOffset sets the offset of the share path in bytes from the start
+// of the packet header.
+func (r ConnectRequest) SetPathOffset(offset uint16) {
+	smbtype.PutUint16(r[4:6], offset)
+}
+
+// PathLength returns the length of the share path in bytes.
+func (r ConnectRequest) PathLength() uint16 {
+	return smbtype.Uint16(r[6:8])
+}
+
+// SetPathLength sets the length of the share path in bytes.
+func (r ConnectRequest) SetPathLength(length uint16) {
+	smbtype.PutUint16(r[6:8], length)
+}
+
+// Path returns the share path of the request, which takes the form
+// \\server\share. It returns an empty string if the request carries a tree
+// connect extension.
+func (r ConnectRequest) Path() string {
+	length := uint(r.PathLength())
+	if length == 0 || r.Flags().Match(ExtensionPresent) {
+		return ""
+	}
+	start := uint(r.PathOffset()) - headerSize
+	return smbtype.String(r[start : start+length])
+}
+
+// SetPath lays out the share path in the buffer that follows the fixed
+// portion of the request.
+//
+// If the request is too small to hold the path the call will panic. Use
+// ConnectRequestBufferSize to determine the size of the buffer.
+func (r ConnectRequest) SetPath(path string) {
+	length := smbtype.StringLen(path)
+	r.SetPathOffset(headerSize + ConnectRequestSize)
+	r.SetPathLength(uint16(length))
+	smbtype.PutString(r[ConnectRequestSize:ConnectRequestSize+length], path)
+}
+
+// ConnectRequestBufferSize returns the number of bytes required to hold a
+// request with the given share path.
+func ConnectRequestBufferSize(path string) int {
+	length := ConnectRequestSize + smbtype.StringLen(path)
+	if length == ConnectRequestSize {
+		// The buffer must be at least one byte long
+		length++
+	}
+	return length
+}
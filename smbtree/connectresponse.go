@@ -0,0 +1,81 @@
+package smbtree
+
+import (
+	"github.com/gentlemanautomaton/smb/smbaccess"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ConnectResponseSize is the number of bytes required for an SMB tree
+// connect response.
+const ConnectResponseSize = 16
+
+// ConnectResponse interprets a slice of bytes as an SMB tree connect
+// response packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.10
+type ConnectResponse []byte
+
+// Valid returns true if the response is valid.
+func (r ConnectResponse) Valid() bool {
+	if len(r) < ConnectResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 16
+	if r.Size() != 16 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r ConnectResponse) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r ConnectResponse) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// ShareType returns the type of share the tree is connected to.
+func (r ConnectResponse) ShareType() ShareType {
+	return ShareType(r[2])
+}
+
+// SetShareType sets the type of share the tree is connected to.
+func (r ConnectResponse) SetShareType(t ShareType) {
+	r[2] = byte(t)
+	r[3] = 0
+}
+
+// ShareFlags returns the properties of the share.
+func (r ConnectResponse) ShareFlags() ShareFlags {
+	return ShareFlags(smbtype.Uint32(r[4:8]))
+}
+
+// SetShareFlags sets the properties of the share.
+func (r ConnectResponse) SetShareFlags(flags ShareFlags) {
+	smbtype.PutUint32(r[4:8], uint32(flags))
+}
+
+// Capabilities returns the capabilities of the share.
+func (r ConnectResponse) Capabilities() Capabilities {
+	return Capabilities(smbtype.Uint32(r[8:12]))
+}
+
+// SetCapabilities sets the capabilities of the share.
+func (r ConnectResponse) SetCapabilities(caps Capabilities) {
+	smbtype.PutUint32(r[8:12], uint32(caps))
+}
+
+// MaximalAccess returns the maximal access the user has on the share.
+func (r ConnectResponse) MaximalAccess() smbaccess.Mask {
+	return smbaccess.Mask(smbtype.Uint32(r[12:16]))
+}
+
+// SetMaximalAccess sets the maximal access the user has on the share.
+func (r ConnectResponse) SetMaximalAccess(access smbaccess.Mask) {
+	smbtype.PutUint32(r[12:16], uint32(access))
+}
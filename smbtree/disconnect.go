@@ -0,0 +1,67 @@
+package smbtree
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// DisconnectSize is the number of bytes required for an SMB tree disconnect
+// request or response.
+const DisconnectSize = 4
+
+// DisconnectRequest interprets a slice of bytes as an SMB tree disconnect
+// request packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.11
+type DisconnectRequest []byte
+
+// Valid returns true if the request is valid.
+func (r DisconnectRequest) Valid() bool {
+	if len(r) < DisconnectSize {
+		return false
+	}
+
+	// The spec requires the size field to be 4
+	if r.Size() != 4 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r DisconnectRequest) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r DisconnectRequest) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// DisconnectResponse interprets a slice of bytes as an SMB tree disconnect
+// response packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.12
+type DisconnectResponse []byte
+
+// Valid returns true if the response is valid.
+func (r DisconnectResponse) Valid() bool {
+	if len(r) < DisconnectSize {
+		return false
+	}
+
+	// The spec requires the size field to be 4
+	if r.Size() != 4 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r DisconnectResponse) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r DisconnectResponse) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
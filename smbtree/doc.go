@@ -0,0 +1,3 @@
+// Package smbtree provides types for SMB tree connect and tree disconnect
+// requests and responses.
+package smbtree
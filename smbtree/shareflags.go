@@ -0,0 +1,37 @@
+package smbtree
+
+// ShareFlags declares the properties of a share.
+type ShareFlags uint32
+
+// Share flags. The caching flags are values rather than bits and are
+// selected by CachingMask.
+const (
+	ManualCaching            = 0x00000000 // SMB2_SHAREFLAG_MANUAL_CACHING
+	AutoCaching              = 0x00000010 // SMB2_SHAREFLAG_AUTO_CACHING
+	VDOCaching               = 0x00000020 // SMB2_SHAREFLAG_VDO_CACHING
+	NoCaching                = 0x00000030 // SMB2_SHAREFLAG_NO_CACHING
+	CachingMask              = 0x00000030
+	DFS                      = 0x00000001 // SMB2_SHAREFLAG_DFS
+	DFSRoot                  = 0x00000002 // SMB2_SHAREFLAG_DFS_ROOT
+	RestrictExclusiveOpens   = 0x00000100 // SMB2_SHAREFLAG_RESTRICT_EXCLUSIVE_OPENS
+	ForceSharedDelete        = 0x00000200 // SMB2_SHAREFLAG_FORCE_SHARED_DELETE
+	AllowNamespaceCaching    = 0x00000400 // SMB2_SHAREFLAG_ALLOW_NAMESPACE_CACHING
+	AccessBasedDirectoryEnum = 0x00000800 // SMB2_SHAREFLAG_ACCESS_BASED_DIRECTORY_ENUM
+	ForceLevelIIOplock       = 0x00001000 // SMB2_SHAREFLAG_FORCE_LEVELII_OPLOCK
+	EnableHashV1             = 0x00002000 // SMB2_SHAREFLAG_ENABLE_HASH_V1
+	EnableHashV2             = 0x00004000 // SMB2_SHAREFLAG_ENABLE_HASH_V2
+	EncryptData              = 0x00008000 // SMB2_SHAREFLAG_ENCRYPT_DATA
+	IdentityRemoting         = 0x00040000 // SMB2_SHAREFLAG_IDENTITY_REMOTING
+	CompressData             = 0x00100000 // SMB2_SHAREFLAG_COMPRESS_DATA
+)
+
+// Match reports whether f contains all of the flags specified by c.
+func (f ShareFlags) Match(c ShareFlags) bool {
+	return f&c == c
+}
+
+// Caching returns the caching policy of the share, which is one of
+// ManualCaching, AutoCaching, VDOCaching or NoCaching.
+func (f ShareFlags) Caching() ShareFlags {
+	return f & CachingMask
+}
@@ -0,0 +1,27 @@
+package smbtree
+
+import "strconv"
+
+// ShareType identifies the type of share a tree is connected to.
+type ShareType uint8
+
+// Share types.
+const (
+	Disk  = 0x01 // SMB2_SHARE_TYPE_DISK
+	Pipe  = 0x02 // SMB2_SHARE_TYPE_PIPE
+	Print = 0x03 // SMB2_SHARE_TYPE_PRINT
+)
+
+// String returns a string representation of the share type.
+func (t ShareType) String() string {
+	switch t {
+	case Disk:
+		return "Disk"
+	case Pipe:
+		return "Pipe"
+	case Print:
+		return "Print"
+	default:
+		return "ShareType " + strconv.Itoa(int(t))
+	}
+}
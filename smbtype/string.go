@@ -96,3 +96,38 @@ func isSurrogateHigh(r uint16) bool {
 func isSurrogateLow(r uint16) bool {
 	return surr2 <= r && r < surr3
 }
+
+// StringLen returns the number of bytes needed to represent s as utf16.
+func StringLen(s string) int {
+	length := 0
+	for _, r := range s {
+		if r >= 0x10000 && r <= utf8.MaxRune {
+			length += 4
+		} else {
+			length += 2
+		}
+	}
+	return length
+}
+
+// PutString writes s as utf16 in little-endian byte order to b and returns
+// the number of bytes written. If b is too small to hold all of s the call
+// will panic.
+func PutString(b []byte, s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 && r <= utf8.MaxRune {
+			r1, r2 := utf16.EncodeRune(r)
+			PutUint16(b[n:], uint16(r1))
+			PutUint16(b[n+2:], uint16(r2))
+			n += 4
+			continue
+		}
+		if surr1 <= r && r < surr3 {
+			r = replacementChar
+		}
+		PutUint16(b[n:], uint16(r))
+		n += 2
+	}
+	return n
+}
@@ -1,6 +1,7 @@
 package smbtype_test
 
 import (
+	"bytes"
 	"strconv"
 	"testing"
 	"unicode/utf16"
@@ -109,3 +110,20 @@ func stdlibString(b []byte) string {
 	}
 	return string(utf16.Decode(u))
 }
+
+func TestPutString(t *testing.T) {
+	for _, tt := range stringTests {
+		length := smbtype.StringLen(tt.String)
+		if length != len(tt.Bytes) {
+			t.Errorf("StringLen(%s) = %d; want %d", tt.String, length, len(tt.Bytes))
+			continue
+		}
+		b := make([]byte, length)
+		if n := smbtype.PutString(b, tt.String); n != length {
+			t.Errorf("PutString(%s) wrote %d bytes; want %d", tt.String, n, length)
+		}
+		if !bytes.Equal(b, tt.Bytes) {
+			t.Errorf("PutString(%s) = %x; want %x", tt.String, b, tt.Bytes)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package smbvalidate provides types for SMB validate negotiate info
+// requests and responses, which are carried by FSCTL_VALIDATE_NEGOTIATE_INFO.
+package smbvalidate
@@ -0,0 +1,75 @@
+package smbvalidate
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcap"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbsecmode"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for the fixed portion of a
+// validate negotiate info request.
+const RequestSize = 24
+
+// Request interprets a slice of bytes as a validate negotiate info request.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.31.4
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+	count := int(r.DialectCount())
+	return count > 0 && RequestSize+count*2 <= len(r)
+}
+
+// Capabilities returns the capabilities of the client.
+func (r Request) Capabilities() smbcap.Flags {
+	return smbcap.Flags(smbtype.Uint32(r[0:4]))
+}
+
+// SetCapabilities sets the capabilities of the client.
+func (r Request) SetCapabilities(caps smbcap.Flags) {
+	smbtype.PutUint32(r[0:4], uint32(caps))
+}
+
+// ClientID returns the GUID of the client.
+func (r Request) ClientID() (id smbid.ID) {
+	id.Read(r[4:20])
+	return
+}
+
+// SetClientID sets the GUID of the client.
+func (r Request) SetClientID(id smbid.ID) {
+	id.Write(r[4:20])
+}
+
+// SecurityMode returns the security mode of the client.
+func (r Request) SecurityMode() smbsecmode.Flags {
+	return smbsecmode.Flags(smbtype.Uint16(r[20:22]))
+}
+
+// SetSecurityMode sets the security mode of the client.
+func (r Request) SetSecurityMode(mode smbsecmode.Flags) {
+	smbtype.PutUint16(r[20:22], uint16(mode))
+}
+
+// DialectCount returns the number of dialects in the request.
+func (r Request) DialectCount() uint16 {
+	return smbtype.Uint16(r[22:24])
+}
+
+// SetDialectCount sets the number of dialects in the request.
+func (r Request) SetDialectCount(count uint16) {
+	smbtype.PutUint16(r[22:24], count)
+}
+
+// Dialects returns the dialects offered by the client.
+func (r Request) Dialects() smbdialect.List {
+	const start = uint(RequestSize)
+	end := start + uint(r.DialectCount())*2
+	return smbdialect.List(r[start:end:end])
+}
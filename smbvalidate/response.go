@@ -0,0 +1,65 @@
+package smbvalidate
+
+import (
+	"github.com/gentlemanautomaton/smb/smbcap"
+	"github.com/gentlemanautomaton/smb/smbdialect"
+	"github.com/gentlemanautomaton/smb/smbid"
+	"github.com/gentlemanautomaton/smb/smbsecmode"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// ResponseSize is the number of bytes required for a validate negotiate
+// info response.
+const ResponseSize = 24
+
+// Response interprets a slice of bytes as a validate negotiate info
+// response.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.32.6
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	return len(r) >= ResponseSize
+}
+
+// Capabilities returns the capabilities of the server.
+func (r Response) Capabilities() smbcap.Flags {
+	return smbcap.Flags(smbtype.Uint32(r[0:4]))
+}
+
+// SetCapabilities sets the capabilities of the server.
+func (r Response) SetCapabilities(caps smbcap.Flags) {
+	smbtype.PutUint32(r[0:4], uint32(caps))
+}
+
+// ServerID returns the GUID of the server.
+func (r Response) ServerID() (id smbid.ID) {
+	id.Read(r[4:20])
+	return
+}
+
+// SetServerID sets the GUID of the server.
+func (r Response) SetServerID(id smbid.ID) {
+	id.Write(r[4:20])
+}
+
+// SecurityMode returns the security mode of the server.
+func (r Response) SecurityMode() smbsecmode.Flags {
+	return smbsecmode.Flags(smbtype.Uint16(r[20:22]))
+}
+
+// SetSecurityMode sets the security mode of the server.
+func (r Response) SetSecurityMode(mode smbsecmode.Flags) {
+	smbtype.PutUint16(r[20:22], uint16(mode))
+}
+
+// Dialect returns the dialect negotiated on the connection.
+func (r Response) Dialect() smbdialect.Revision {
+	return smbdialect.Revision(smbtype.Uint16(r[22:24]))
+}
+
+// SetDialect sets the dialect negotiated on the connection.
+func (r Response) SetDialect(dialect smbdialect.Revision) {
+	smbtype.PutUint16(r[22:24], uint16(dialect))
+}
@@ -0,0 +1,2 @@
+// Package smbwrite provides types for SMB write requests and responses.
+package smbwrite
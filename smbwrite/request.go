@@ -0,0 +1,122 @@
+package smbwrite
+
+import (
+	"github.com/gentlemanautomaton/smb/smbfile"
+	"github.com/gentlemanautomaton/smb/smbtype"
+)
+
+// RequestSize is the number of bytes required for the fixed portion of an
+// SMB write request.
+const RequestSize = 48
+
+// headerSize is the number of bytes in an SMB packet header. It's needed by
+// this package to calculate buffer offsets relative to the start of the
+// packet.
+const headerSize = 64
+
+// Request interprets a slice of bytes as an SMB write request packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.21
+type Request []byte
+
+// Valid returns true if the request is valid.
+func (r Request) Valid() bool {
+	if len(r) < RequestSize {
+		return false
+	}
+
+	// The spec requires the size field to be 49
+	if r.Size() != 49 {
+		return false
+	}
+
+	// The data must not overflow
+	if r.Length() > 0 {
+		start := int(r.DataOffset()) - headerSize
+		if start < RequestSize || start+int(r.Length()) > len(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Size returns the structure size of the request.
+func (r Request) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the request.
+func (r Request) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// DataOffset returns the offset of the data in bytes from the start of the
+// packet header.
+func (r Request) DataOffset() uint16 {
+	return smbtype.Uint16(r[2:4])
+}
+
+// SetDataOffset sets the offset of the data in bytes from the start of the
+// packet header.
+func (r Request) SetDataOffset(offset uint16) {
+	smbtype.PutUint16(r[2:4], offset)
+}
+
+// Length returns the number of bytes to be written.
+func (r Request) Length() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetLength sets the number of bytes to be written.
+func (r Request) SetLength(length uint32) {
+	smbtype.PutUint32(r[4:8], length)
+}
+
+// Offset returns the offset in bytes within the file at which the write
+// begins.
+func (r Request) Offset() uint64 {
+	return smbtype.Uint64(r[8:16])
+}
+
+// SetOffset sets the offset in bytes within the file at which the write
+// begins.
+func (r Request) SetOffset(offset uint64) {
+	smbtype.PutUint64(r[8:16], offset)
+}
+
+// FileID returns the file ID of the file to be written.
+func (r Request) FileID() (id smbfile.ID) {
+	id.Read(r[16:32])
+	return
+}
+
+// SetFileID sets the file ID of the file to be written.
+func (r Request) SetFileID(id smbfile.ID) {
+	id.Write(r[16:32])
+}
+
+// Data returns the data to be written.
+func (r Request) Data() []byte {
+	if r.Length() == 0 {
+		return nil
+	}
+	start := uint(r.DataOffset()) - headerSize
+	end := start + uint(r.Length())
+	return r[start:end:end]
+}
+
+// SetDataLayout sets the offset and length of the data. The data is placed
+// immediately after the fixed portion of the request. It returns the data
+// buffer so that it can be populated.
+//
+// If the request is too small to hold length bytes the call will panic.
+func (r Request) SetDataLayout(length int) []byte {
+	if len(r)-RequestSize < length {
+		panic("smbwrite: request: data is too large to fit in request")
+	}
+	r.SetDataOffset(headerSize + RequestSize)
+	r.SetLength(uint32(length))
+	end := RequestSize + length
+	return r[RequestSize:end:end]
+}
@@ -0,0 +1,45 @@
+package smbwrite
+
+import "github.com/gentlemanautomaton/smb/smbtype"
+
+// ResponseSize is the number of bytes required for an SMB write response.
+const ResponseSize = 16
+
+// Response interprets a slice of bytes as an SMB write response packet.
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/ section 2.2.22
+type Response []byte
+
+// Valid returns true if the response is valid.
+func (r Response) Valid() bool {
+	if len(r) < ResponseSize {
+		return false
+	}
+
+	// The spec requires the size field to be 17
+	if r.Size() != 17 {
+		return false
+	}
+
+	return true
+}
+
+// Size returns the structure size of the response.
+func (r Response) Size() uint16 {
+	return smbtype.Uint16(r[0:2])
+}
+
+// SetSize sets the structure size of the response.
+func (r Response) SetSize(size uint16) {
+	smbtype.PutUint16(r[0:2], size)
+}
+
+// Count returns the number of bytes that were written.
+func (r Response) Count() uint32 {
+	return smbtype.Uint32(r[4:8])
+}
+
+// SetCount sets the number of bytes that were written.
+func (r Response) SetCount(count uint32) {
+	smbtype.PutUint32(r[4:8], count)
+}